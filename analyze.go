@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// projectPackage groups inputs that are not under node_modules
+const projectPackage = "(project)"
+
+// BundleAnalysis describes what a production bundle is made of
+type BundleAnalysis struct {
+	Entry      string             `json:"entry"`
+	Output     string             `json:"output"`
+	TotalBytes int                `json:"total_bytes"`
+	Packages   []PackageSize      `json:"packages"`
+	Duplicates []DuplicatePackage `json:"duplicates"`
+	Largest    []BundleFile       `json:"largest_files"`
+}
+
+// PackageSize is the contribution of one package to the bundle
+type PackageSize struct {
+	Name    string       `json:"name"`
+	Bytes   int          `json:"bytes"`
+	Percent float64      `json:"percent"`
+	Files   []BundleFile `json:"files"`
+}
+
+// DuplicatePackage is a package bundled from more than one install location
+type DuplicatePackage struct {
+	Name      string            `json:"name"`
+	Bytes     int               `json:"bytes"`
+	Instances []PackageInstance `json:"instances"`
+}
+
+// PackageInstance is one install location of a duplicated package
+type PackageInstance struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	Bytes   int    `json:"bytes"`
+}
+
+// BundleFile is an input file and the bytes it contributes to the output
+type BundleFile struct {
+	Path    string `json:"path"`
+	Package string `json:"package"`
+	Bytes   int    `json:"bytes"`
+}
+
+// esbuildMetafile is the subset of esbuild's metafile used for analysis
+type esbuildMetafile struct {
+	Outputs map[string]struct {
+		Bytes  int `json:"bytes"`
+		Inputs map[string]struct {
+			BytesInOutput int `json:"bytesInOutput"`
+		} `json:"inputs"`
+	} `json:"outputs"`
+}
+
+// packageOf returns the package an input belongs to and the directory it is
+// installed in, using the innermost node_modules segment of the path
+func packageOf(input string) (string, string) {
+	input = filepath.ToSlash(input)
+	i := strings.LastIndex(input, "node_modules/")
+	if i < 0 {
+		return projectPackage, ""
+	}
+	rest := input[i+len("node_modules/"):]
+	parts := strings.SplitN(rest, "/", 3)
+	name := parts[0]
+	if strings.HasPrefix(name, "@") && len(parts) > 1 {
+		name += "/" + parts[1]
+	}
+	return name, input[:i+len("node_modules/")+len(name)]
+}
+
+// packageVersion reads the version from an installed package's package.json
+func packageVersion(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	json.Unmarshal(data, &pkg)
+	return pkg.Version
+}
+
+// analyzeMetafile summarises an esbuild metafile. top limits the largest
+// files overall and per package.
+func analyzeMetafile(entry, metafile string, top int) (*BundleAnalysis, error) {
+	var meta esbuildMetafile
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %w", err)
+	}
+
+	analysis := &BundleAnalysis{Entry: entry, Packages: []PackageSize{}, Duplicates: []DuplicatePackage{}, Largest: []BundleFile{}}
+	packages := make(map[string]*PackageSize)
+	instances := make(map[string]map[string]int)
+
+	for _, output := range sortedKeys(meta.Outputs) {
+		// Source maps and other side outputs have no inputs
+		out := meta.Outputs[output]
+		if len(out.Inputs) == 0 {
+			continue
+		}
+		if analysis.Output == "" {
+			analysis.Output = output
+		}
+		analysis.TotalBytes += out.Bytes
+
+		for path, input := range out.Inputs {
+			name, dir := packageOf(path)
+			file := BundleFile{Path: path, Package: name, Bytes: input.BytesInOutput}
+			analysis.Largest = append(analysis.Largest, file)
+
+			pkg, ok := packages[name]
+			if !ok {
+				pkg = &PackageSize{Name: name}
+				packages[name] = pkg
+			}
+			pkg.Bytes += file.Bytes
+			pkg.Files = append(pkg.Files, file)
+
+			if dir != "" {
+				if instances[name] == nil {
+					instances[name] = make(map[string]int)
+				}
+				instances[name][dir] += file.Bytes
+			}
+		}
+	}
+
+	bySize := func(files []BundleFile) {
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].Bytes != files[j].Bytes {
+				return files[i].Bytes > files[j].Bytes
+			}
+			return files[i].Path < files[j].Path
+		})
+	}
+
+	for _, pkg := range packages {
+		bySize(pkg.Files)
+		if len(pkg.Files) > top {
+			pkg.Files = pkg.Files[:top]
+		}
+		if analysis.TotalBytes > 0 {
+			pkg.Percent = float64(pkg.Bytes) * 100 / float64(analysis.TotalBytes)
+		}
+		analysis.Packages = append(analysis.Packages, *pkg)
+	}
+	sort.Slice(analysis.Packages, func(i, j int) bool {
+		if analysis.Packages[i].Bytes != analysis.Packages[j].Bytes {
+			return analysis.Packages[i].Bytes > analysis.Packages[j].Bytes
+		}
+		return analysis.Packages[i].Name < analysis.Packages[j].Name
+	})
+
+	bySize(analysis.Largest)
+	if len(analysis.Largest) > top {
+		analysis.Largest = analysis.Largest[:top]
+	}
+
+	for _, name := range sortedKeys(instances) {
+		dirs := instances[name]
+		if len(dirs) < 2 {
+			continue
+		}
+		dup := DuplicatePackage{Name: name}
+		for _, dir := range sortedKeys(dirs) {
+			dup.Instances = append(dup.Instances, PackageInstance{Path: dir, Version: packageVersion(dir), Bytes: dirs[dir]})
+			dup.Bytes += dirs[dir]
+		}
+		analysis.Duplicates = append(analysis.Duplicates, dup)
+	}
+	return analysis, nil
+}
+
+// analyzeEntry builds an entry point for production and analyses the result
+func analyzeEntry(entry string, top int) (*BundleAnalysis, []string, error) {
+	result := buildWithEsbuild(entry, "app.js", false)
+	if len(result.Errors) > 0 {
+		return nil, formatBuildErrors(result.Errors), nil
+	}
+	analysis, err := analyzeMetafile(entry, result.Metafile, top)
+	return analysis, nil, err
+}
+
+// handleBuildAnalyze serves GET /api/build/analyze?entry=index.tsx&top=20
+func handleBuildAnalyze(w http.ResponseWriter, r *http.Request) {
+	entry := r.URL.Query().Get("entry")
+	if entry == "" {
+		entry = currentBuildConfig().entryPath()
+	}
+	cleanPath := filepath.Clean(entry)
+	if strings.Contains(cleanPath, "..") || filepath.IsAbs(cleanPath) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid entry", nil)
+		return
+	}
+	if _, err := os.Stat(cleanPath); err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Entry not found", map[string]string{"entry": entry})
+		return
+	}
+	top := 20
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			writeJSONError(w, r, http.StatusBadRequest, "top must be between 1 and 500", nil)
+			return
+		}
+		top = n
+	}
+
+	analysis, buildErrors, err := analyzeEntry("./"+cleanPath, top)
+	if buildErrors != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Build failed", buildErrors)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, analysis)
+}
+
+// formatSize renders a byte count for reports
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// sizeBar draws a fixed-width bar proportional to part of total
+func sizeBar(part, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = (part*width + total/2) / total
+	}
+	if filled == 0 && part > 0 {
+		filled = 1
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// printBundleReport renders the analysis as nested bars, packages first and
+// their largest files beneath them
+func printBundleReport(a *BundleAnalysis, filesPerPackage int) {
+	const barWidth = 30
+	fmt.Printf("📦 %s → %s: %s\n\n", a.Entry, a.Output, formatSize(a.TotalBytes))
+
+	duplicated := make(map[string]bool)
+	for _, dup := range a.Duplicates {
+		duplicated[dup.Name] = true
+	}
+
+	fmt.Println("Packages")
+	for _, pkg := range a.Packages {
+		fmt.Printf("  %-28s %s %9s %5.1f%%\n", pkg.Name, sizeBar(pkg.Bytes, a.TotalBytes, barWidth), formatSize(pkg.Bytes), pkg.Percent)
+		for i, file := range pkg.Files {
+			if i == filesPerPackage {
+				fmt.Printf("    … %d more\n", len(pkg.Files)-i)
+				break
+			}
+			fmt.Printf("    %-26s %s %9s\n", truncateTitle(fileLabel(file, duplicated), 26),
+				sizeBar(file.Bytes, pkg.Bytes, barWidth), formatSize(file.Bytes))
+		}
+	}
+
+	if len(a.Duplicates) > 0 {
+		fmt.Println("\n⚠️  Duplicated packages")
+		for _, dup := range a.Duplicates {
+			fmt.Printf("  %s (%s across %d copies)\n", dup.Name, formatSize(dup.Bytes), len(dup.Instances))
+			for _, inst := range dup.Instances {
+				version := inst.Version
+				if version == "" {
+					version = "?"
+				}
+				fmt.Printf("    %-10s %9s  %s\n", version, formatSize(inst.Bytes), inst.Path)
+			}
+		}
+	}
+
+	fmt.Println("\nLargest files")
+	for _, file := range a.Largest {
+		fmt.Printf("  %9s  %s\n", formatSize(file.Bytes), file.Path)
+	}
+}
+
+// fileLabel shortens an input path to its path within the package. Files of
+// duplicated packages keep the full path so the copies can be told apart.
+func fileLabel(file BundleFile, duplicated map[string]bool) string {
+	_, dir := packageOf(file.Path)
+	if dir == "" || duplicated[file.Package] {
+		return file.Path
+	}
+	return strings.TrimPrefix(file.Path, dir+"/")
+}
+
+// analyzeCommand prints what the production bundle is made of
+func analyzeCommand(c *cli.Context) error {
+	if err := resolveBuildConfig(c); err != nil {
+		return err
+	}
+
+	entry := c.String("entry")
+	if entry == "" {
+		entry = currentBuildConfig().entryPath()
+	}
+	analysis, buildErrors, err := analyzeEntry(entry, c.Int("top"))
+	if buildErrors != nil {
+		fmt.Println("❌ Build failed:")
+		for _, e := range buildErrors {
+			fmt.Printf("   • %s\n", e)
+		}
+		return fmt.Errorf("build failed with %d errors", len(buildErrors))
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(analysis)
+	}
+	printBundleReport(analysis, 3)
+	return nil
+}
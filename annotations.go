@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errAnnotationNotFound is returned when no annotation matches the requested ID
+var errAnnotationNotFound = errors.New("annotation not found")
+
+// Annotation is a user note, highlight or rating attached to a single message
+type Annotation struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	MessageUUID string    `json:"message_uuid"`
+	Note        string    `json:"note"`
+	Highlight   string    `json:"highlight,omitempty"`
+	Rating      *int      `json:"rating,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// annotationInput is the request body for creating or updating an annotation
+type annotationInput struct {
+	MessageUUID string `json:"message_uuid"`
+	Note        string `json:"note"`
+	Highlight   string `json:"highlight"`
+	Rating      *int   `json:"rating"`
+}
+
+func (in annotationInput) validate() error {
+	if in.Rating != nil && (*in.Rating < 1 || *in.Rating > 5) {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	if strings.TrimSpace(in.Note) == "" && in.Highlight == "" && in.Rating == nil {
+		return fmt.Errorf("annotation needs a note, highlight or rating")
+	}
+	return nil
+}
+
+const annotationColumns = `id, session_id, message_uuid, note, highlight, rating, created_at, updated_at`
+
+func scanAnnotation(row interface{ Scan(...interface{}) error }) (Annotation, error) {
+	var a Annotation
+	var rating sql.NullInt64
+	err := row.Scan(&a.ID, &a.SessionID, &a.MessageUUID, &a.Note, &a.Highlight, &rating, &a.CreatedAt, &a.UpdatedAt)
+	if rating.Valid {
+		r := int(rating.Int64)
+		a.Rating = &r
+	}
+	return a, err
+}
+
+// listAnnotations returns a session's annotations in creation order, optionally for one message
+func listAnnotations(db *sql.DB, sessionID, messageUUID string) ([]Annotation, error) {
+	query := `SELECT ` + annotationColumns + ` FROM message_annotations WHERE session_id = $1`
+	args := []interface{}{sessionID}
+	if messageUUID != "" {
+		query += ` AND message_uuid = $2`
+		args = append(args, messageUUID)
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := []Annotation{}
+	for rows.Next() {
+		a, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan annotation: %w", err)
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, rows.Err()
+}
+
+// sessionHasMessage reports whether the session exists and contains the message UUID
+func sessionHasMessage(db *sql.DB, sessionID, messageUUID string) (bool, error) {
+	var found bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM jsonb_array_elements(messages) AS m WHERE m->>'uuid' = $2
+		)
+		FROM claude_sessions
+		WHERE session_id = $1 AND deleted_at IS NULL`, sessionID, messageUUID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up message: %w", err)
+	}
+	return found, nil
+}
+
+// getAnnotation loads a single annotation of a session
+func getAnnotation(db *sql.DB, sessionID, id string) (Annotation, error) {
+	row := db.QueryRow(`SELECT `+annotationColumns+` FROM message_annotations WHERE session_id = $1 AND id::text = $2`, sessionID, id)
+	a, err := scanAnnotation(row)
+	if err == sql.ErrNoRows {
+		return a, fmt.Errorf("%w: %s", errAnnotationNotFound, id)
+	}
+	if err != nil {
+		return a, fmt.Errorf("failed to load annotation: %w", err)
+	}
+	return a, nil
+}
+
+// createAnnotation attaches a new annotation to a message
+func createAnnotation(db *sql.DB, sessionID string, in annotationInput) (Annotation, error) {
+	row := db.QueryRow(`
+		INSERT INTO message_annotations (session_id, message_uuid, note, highlight, rating)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+annotationColumns,
+		sessionID, in.MessageUUID, in.Note, in.Highlight, in.Rating)
+	a, err := scanAnnotation(row)
+	if err != nil {
+		return a, fmt.Errorf("failed to create annotation: %w", err)
+	}
+	return a, nil
+}
+
+// updateAnnotation replaces the note, highlight and rating of an annotation
+func updateAnnotation(db *sql.DB, sessionID, id string, in annotationInput) (Annotation, error) {
+	row := db.QueryRow(`
+		UPDATE message_annotations
+		SET note = $3, highlight = $4, rating = $5, updated_at = NOW()
+		WHERE session_id = $1 AND id::text = $2
+		RETURNING `+annotationColumns,
+		sessionID, id, in.Note, in.Highlight, in.Rating)
+	a, err := scanAnnotation(row)
+	if err == sql.ErrNoRows {
+		return a, fmt.Errorf("%w: %s", errAnnotationNotFound, id)
+	}
+	if err != nil {
+		return a, fmt.Errorf("failed to update annotation: %w", err)
+	}
+	return a, nil
+}
+
+// deleteAnnotation removes an annotation from a session
+func deleteAnnotation(db *sql.DB, sessionID, id string) error {
+	result, err := db.Exec(`DELETE FROM message_annotations WHERE session_id = $1 AND id::text = $2`, sessionID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete annotation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", errAnnotationNotFound, id)
+	}
+	return nil
+}
+
+// handleListAnnotations serves GET /api/sessions/{id}/annotations[?message=<uuid>]
+func (a *apiServer) handleListAnnotations(w http.ResponseWriter, r *http.Request) {
+	annotations, err := listAnnotations(a.db, r.PathValue("id"), r.URL.Query().Get("message"))
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, wholePage(annotations))
+}
+
+// handleCreateAnnotation serves POST /api/sessions/{id}/annotations
+func (a *apiServer) handleCreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	var in annotationInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if in.MessageUUID == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "message_uuid is required", nil)
+		return
+	}
+	if err := in.validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	found, err := sessionHasMessage(a.db, sessionID, in.MessageUUID)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	if !found {
+		writeJSONError(w, r, http.StatusNotFound, "Message not found in session", map[string]string{"message_uuid": in.MessageUUID})
+		return
+	}
+
+	annotation, err := createAnnotation(a.db, sessionID, in)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	a.audit(r, "annotation.create", "annotation", annotation.ID, nil, annotation)
+	writeJSON(w, http.StatusCreated, annotation)
+}
+
+// handleUpdateAnnotation serves PUT /api/sessions/{id}/annotations/{annotation}
+func (a *apiServer) handleUpdateAnnotation(w http.ResponseWriter, r *http.Request) {
+	var in annotationInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if err := in.validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	previous, err := getAnnotation(a.db, r.PathValue("id"), r.PathValue("annotation"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	annotation, err := updateAnnotation(a.db, r.PathValue("id"), r.PathValue("annotation"), in)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	a.audit(r, "annotation.update", "annotation", annotation.ID, previous, annotation)
+	writeJSON(w, http.StatusOK, annotation)
+}
+
+// handleDeleteAnnotation serves DELETE /api/sessions/{id}/annotations/{annotation}
+func (a *apiServer) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	previous, err := getAnnotation(a.db, r.PathValue("id"), r.PathValue("annotation"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	if err := deleteAnnotation(a.db, r.PathValue("id"), r.PathValue("annotation")); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	a.audit(r, "annotation.delete", "annotation", previous.ID, previous, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
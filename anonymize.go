@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// anonHostPattern matches domain names under common public and internal
+// suffixes; other dotted words are more often file names
+const anonHostPattern = `(?:[A-Za-z0-9-]+\.)+(?:com|net|org|io|dev|app|co|ai|cloud|local|lan|internal|corp|localdomain)`
+
+// anonHostname matches a whole domain name
+var anonHostname = regexp.MustCompile(`^` + anonHostPattern + `$`)
+
+// anonPatterns finds everything anonymizeText replaces in one pass, so a
+// pseudonym is never anonymized again. Each alternative is a named group:
+// email addresses, URLs, Windows paths, absolute and home relative Unix
+// paths, relative paths that name a file, and domain names.
+var anonPatterns = regexp.MustCompile(strings.Join([]string{
+	`(?P<email>[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,})`,
+	`(?P<url>[a-z][a-z0-9+.-]*://[A-Za-z0-9.-]+(?::\d+)?(?:/[\w.@+%-]*)*)`,
+	`(?P<windows>\b[A-Za-z]:\\(?:[^\\\s"'<>|]+\\)*[^\\\s"'<>|]+)`,
+	`(?P<path>(?:~|\B)/(?:[\w.@+-]+/)*[\w.@+-]+)`,
+	`(?P<relative>\b[\w.-]+(?:/[\w.@+-]+)+\.[A-Za-z0-9]+\b)`,
+	`(?P<host>\b` + anonHostPattern + `\b)`,
+}, "|"))
+
+// anonHomeDir finds the user names in home directories
+var anonHomeDir = regexp.MustCompile(`(?:/Users/|/home/|\\Users\\)([\w.-]+)`)
+
+// anonKeptSegments are path segments too common to identify a project
+var anonKeptSegments = map[string]bool{
+	"": true, ".": true, "..": true, "~": true,
+	"Users": true, "home": true, "root": true, "usr": true, "bin": true, "sbin": true, "lib": true, "lib64": true,
+	"etc": true, "tmp": true, "var": true, "opt": true, "private": true, "dev": true, "proc": true, "sys": true,
+	"local": true, "share": true, "include": true, "Library": true, "Applications": true, "System": true,
+	"Volumes": true, "mnt": true, "srv": true, "Windows": true, "Program Files": true,
+	".claude": true, "projects": true, ".git": true, ".github": true, ".vscode": true, "node_modules": true,
+	"src": true, "dist": true, "build": true, "test": true, "tests": true, "docs": true, "cmd": true,
+	"internal": true, "pkg": true, "scripts": true, "components": true, "api": true,
+	"package.json": true, "package-lock.json": true, "tsconfig.json": true, "go.mod": true, "go.sum": true,
+	"Cargo.toml": true, "Makefile": true, "Dockerfile": true, "README.md": true, "CLAUDE.md": true,
+	".gitignore": true, ".env": true, "index.ts": true, "index.tsx": true, "index.js": true, "main.go": true,
+	"env": true, "sh": true, "bash": true, "zsh": true, "node": true, "python3": true, "go": true, "git": true, "npm": true, "npx": true,
+}
+
+// anonKeptHosts are hosts that say nothing about who exported a session
+var anonKeptHosts = map[string]bool{
+	"localhost": true, "example.com": true, "github.com": true, "anthropic.com": true, "docs.anthropic.com": true,
+	"npmjs.com": true, "www.npmjs.com": true, "esm.sh": true, "pkg.go.dev": true,
+}
+
+// Anonymizer pseudonymizes the paths, user names, host names and email
+// addresses in sessions. One anonymizer is used for a whole export, so the
+// same name always gets the same pseudonym.
+type Anonymizer struct {
+	names map[string]map[string]string
+	// terms are user and host names replaced wherever they appear
+	terms   map[string]string
+	termsRe *regexp.Regexp
+}
+
+// NewAnonymizer returns an anonymizer that also hides the name of the
+// current user and machine, which often appear outside of paths
+func NewAnonymizer() *Anonymizer {
+	a := &Anonymizer{names: make(map[string]map[string]string), terms: make(map[string]string)}
+	if u, err := user.Current(); err == nil {
+		a.learn("user", u.Username)
+	}
+	if host, err := os.Hostname(); err == nil {
+		a.learn("host", strings.Split(host, ".")[0])
+	}
+	return a
+}
+
+// pseudonym returns the stable replacement of name, numbered per kind
+func (a *Anonymizer) pseudonym(kind, name string) string {
+	m := a.names[kind]
+	if m == nil {
+		m = make(map[string]string)
+		a.names[kind] = m
+	}
+	if p, ok := m[name]; ok {
+		return p
+	}
+	p := fmt.Sprintf("%s%d", kind, len(m)+1)
+	m[name] = p
+	return p
+}
+
+// learn adds a user or host name to replace as a whole word. Very short
+// names would match ordinary words and are only replaced inside paths.
+func (a *Anonymizer) learn(kind, name string) {
+	if len(name) < 3 || a.terms[name] != "" || anonKeptSegments[name] {
+		return
+	}
+	a.terms[name] = a.pseudonym(kind, name)
+	names := make([]string, 0, len(a.terms))
+	for term := range a.terms {
+		names = append(names, regexp.QuoteMeta(term))
+	}
+	// Longest first, so a name is never replaced by a prefix of it
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	a.termsRe = regexp.MustCompile(`\b(?:` + strings.Join(names, "|") + `)\b`)
+}
+
+// anonymizeSegments pseudonymizes the segments of a path split by sep. A
+// file keeps its extension so the language stays recognizable.
+func (a *Anonymizer) anonymizeSegments(p, sep string) string {
+	segments := strings.Split(p, sep)
+	for i, seg := range segments {
+		switch {
+		case i > 0 && (segments[i-1] == "Users" || segments[i-1] == "home"):
+			segments[i] = a.pseudonym("user", seg)
+		case anonKeptSegments[seg], i == 0 && strings.HasSuffix(seg, ":"):
+		case i == len(segments)-1 && path.Ext(seg) != "" && path.Ext(seg) != seg:
+			ext := path.Ext(seg)
+			segments[i] = a.pseudonym("file", strings.TrimSuffix(seg, ext)) + ext
+		default:
+			segments[i] = a.pseudonym("dir", seg)
+		}
+	}
+	return strings.Join(segments, sep)
+}
+
+// anonymizeText replaces everything identifying in a piece of text
+func (a *Anonymizer) anonymizeText(s string) string {
+	if s == "" {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	names := anonPatterns.SubexpNames()
+	for _, loc := range anonPatterns.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:loc[0]])
+		last = loc[1]
+		m := s[loc[0]:loc[1]]
+		for i := 1; i < len(names); i++ {
+			if loc[2*i] >= 0 {
+				b.WriteString(a.anonymizeMatch(names[i], m))
+				break
+			}
+		}
+	}
+	b.WriteString(s[last:])
+	s = b.String()
+	if a.termsRe != nil {
+		s = a.termsRe.ReplaceAllStringFunc(s, func(term string) string { return a.terms[term] })
+	}
+	return s
+}
+
+// anonymizeMatch replaces a match of the named alternative of anonPatterns
+func (a *Anonymizer) anonymizeMatch(kind, m string) string {
+	switch kind {
+	case "email":
+		return a.pseudonym("user", strings.ToLower(m)) + "@example.com"
+	case "url":
+		scheme, rest, _ := strings.Cut(m, "://")
+		hostPort, p, hasPath := strings.Cut(rest, "/")
+		host, port, hasPort := strings.Cut(hostPort, ":")
+		m = scheme + "://" + a.anonymizeHost(host)
+		if hasPort {
+			m += ":" + port
+		}
+		if hasPath {
+			m += "/" + a.anonymizeSegments(p, "/")
+		}
+		return m
+	case "windows":
+		return a.anonymizeSegments(m, `\`)
+	case "relative":
+		// Relative paths may start with a domain, as Go imports do
+		if first, rest, _ := strings.Cut(m, "/"); anonHostname.MatchString(first) {
+			return a.anonymizeHost(first) + "/" + a.anonymizeSegments(rest, "/")
+		}
+		return a.anonymizeSegments(m, "/")
+	case "host":
+		return a.anonymizeHost(m)
+	}
+	return a.anonymizeSegments(m, "/")
+}
+
+// anonymizeHost pseudonymizes a host name unless it is a well known public one
+func (a *Anonymizer) anonymizeHost(host string) string {
+	lower := strings.ToLower(host)
+	if anonKeptHosts[lower] || lower == "127.0.0.1" || strings.HasSuffix(lower, ".example.com") {
+		return host
+	}
+	return a.pseudonym("host", lower) + ".example"
+}
+
+// anonymizeJSON applies anonymizeText to every string value in a JSON document
+func (a *Anonymizer) anonymizeJSON(raw json.RawMessage) json.RawMessage {
+	out, err := rewriteJSONStrings(raw, a.anonymizeText)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+// Anonymize rewrites a session in place. User names found in home
+// directories anywhere in the session are learned first, so they are also
+// replaced where they appear on their own.
+func (a *Anonymizer) Anonymize(session *ClaudeSession) {
+	if a == nil {
+		return
+	}
+	metadata, _ := json.Marshal(session.Metadata)
+	texts := [][]byte{metadata}
+	for _, msg := range session.Messages {
+		texts = append(texts, msg.Message, []byte(msg.Content), []byte(msg.Cwd))
+	}
+	for _, text := range texts {
+		for _, m := range anonHomeDir.FindAllSubmatch(text, -1) {
+			a.learn("user", string(m[1]))
+		}
+	}
+	if host, ok := session.Metadata["host"].(string); ok {
+		a.learn("host", host)
+	}
+
+	session.Title = a.anonymizeText(session.Title)
+	if session.UserID != nil {
+		id := a.pseudonym("user", *session.UserID)
+		session.UserID = &id
+	}
+	for i := range session.Messages {
+		msg := &session.Messages[i]
+		msg.Content = a.anonymizeText(msg.Content)
+		msg.Thinking = a.anonymizeText(msg.Thinking)
+		msg.Summary = a.anonymizeText(msg.Summary)
+		msg.Cwd = a.anonymizeText(msg.Cwd)
+		if len(msg.Message) > 0 {
+			msg.Message = a.anonymizeJSON(msg.Message)
+		}
+	}
+	var anonymized map[string]interface{}
+	if json.Unmarshal(a.anonymizeJSON(metadata), &anonymized) == nil && anonymized != nil {
+		session.Metadata = anonymized
+	}
+}
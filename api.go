@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// apiServer serves the database-backed JSON API. db is nil when serve runs
+// without a configured database, in which case those endpoints return 503.
+type apiServer struct {
+	db     *sql.DB
+	config *Config
+	// configMu guards config, which a config reload replaces
+	configMu sync.RWMutex
+	// limiter throttles API requests per client; nil disables it
+	limiter *rateLimiter
+	// readonly is set by serve --readonly; handlers that would store derived
+	// data or call out to a model skip doing so
+	readonly bool
+}
+
+// currentConfig returns the local config, or nil when serve runs without one
+func (a *apiServer) currentConfig() *Config {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+	return a.config
+}
+
+// registerRoutes mounts the database-backed API endpoints on the mux
+func (a *apiServer) registerRoutes(mux routeMux) {
+	mux.HandleFunc("GET /api/compare", a.withDB(a.requireReadAll(a.handleCompareSessions)))
+	mux.HandleFunc("GET /api/sessions", a.withDB(a.requireRead(a.handleListSessions)))
+	mux.HandleFunc("GET /api/sessions/changes", a.withDB(a.requireRead(a.handleSessionChanges)))
+	mux.HandleFunc("GET /api/sessions/{id}", a.withDB(a.requireRead(a.handleGetSession)))
+	mux.HandleFunc("PATCH /api/sessions/{id}", a.withDB(a.requireWrite(a.handleUpdateSession)))
+	mux.HandleFunc("GET /api/blobs/{key}", a.requireReadAll(a.handleGetBlob))
+	mux.HandleFunc("GET /api/messages/{uuid}/full", a.withDB(a.requireRead(a.handleMessageFull)))
+	mux.HandleFunc("GET /api/sessions/{id}/raw", a.withDB(a.requireRead(a.handleSessionRaw)))
+	mux.HandleFunc("GET /api/sessions/{id}/attachments", a.withDB(a.requireRead(a.handleSessionAttachments)))
+	mux.HandleFunc("GET /attachments/{id}", a.requireReadAll(a.handleGetAttachment))
+	mux.HandleFunc("GET /api/sessions/{id}/files", a.withDB(a.requireRead(a.handleSessionFiles)))
+	mux.HandleFunc("GET /api/sessions/{id}/workstream", a.withDB(a.requireRead(a.handleSessionWorkstream)))
+	mux.HandleFunc("GET /api/sessions/{id}/timeline", a.withDB(a.requireRead(a.handleSessionTimeline)))
+	mux.HandleFunc("GET /api/sessions/{id}/outline", a.withDB(a.requireRead(a.handleSessionOutline)))
+	mux.HandleFunc("PUT /api/sessions/{id}/outcome", a.withDB(a.requireWrite(a.handleSetOutcome)))
+	mux.HandleFunc("DELETE /api/sessions/{id}/outcome", a.withDB(a.requireWrite(a.handleClearOutcome)))
+	mux.HandleFunc("GET /api/analytics/outcomes", a.withDB(a.requireRead(a.handleOutcomeAnalytics)))
+	mux.HandleFunc("GET /api/analytics/languages", a.withDB(a.requireRead(a.handleLanguageAnalytics)))
+	mux.HandleFunc("GET /api/analytics/errors", a.withDB(a.requireRead(a.handleErrorAnalytics)))
+	mux.HandleFunc("GET /api/analytics/turns", a.withDB(a.requireRead(a.handleTurnAnalytics)))
+	mux.HandleFunc("GET /api/files", a.withDB(a.requireReadAll(a.handleFileSessions)))
+	mux.HandleFunc("POST /api/ingest/sessions", a.withDB(a.requireIngest(a.handleIngestSession)))
+	mux.HandleFunc("GET /api/ingest/sessions/{id}", a.withDB(a.requireIngest(a.handleIngestStatus)))
+	mux.HandleFunc("GET /api/todos", a.withDB(a.requireReadAll(a.handleListTodos)))
+	mux.HandleFunc("GET /api/prompts", a.withDB(a.requireRead(a.handleListPrompts)))
+	mux.HandleFunc("GET /api/workspaces", a.withDB(a.requireRead(a.handleListWorkspaces)))
+	mux.HandleFunc("GET /api/workspaces/{slug}", a.withDB(a.requireRead(a.handleGetWorkspace)))
+	mux.HandleFunc("GET /api/views", a.withDB(a.requireRead(a.handleListViews)))
+	mux.HandleFunc("GET /api/views/{name}", a.withDB(a.requireRead(a.handleGetView)))
+	mux.HandleFunc("POST /api/views", a.withDB(a.requireWriteAll(a.handleCreateView)))
+	mux.HandleFunc("PUT /api/views/{name}", a.withDB(a.requireWriteAll(a.handleUpdateView)))
+	mux.HandleFunc("DELETE /api/views/{name}", a.withDB(a.requireWriteAll(a.handleDeleteView)))
+	mux.HandleFunc("POST /api/graphql", a.withDB(a.requireReadAll(a.graphqlHandler())))
+	mux.HandleFunc("GET /api/audit", a.withDB(a.requireAdmin(a.handleListAudit)))
+
+	mux.HandleFunc("DELETE /api/sessions", a.withDB(a.requireWrite(a.handleBulkDeleteSessions)))
+	mux.HandleFunc("DELETE /api/sessions/{id}", a.withDB(a.requireWrite(a.handleDeleteSession)))
+
+	mux.HandleFunc("GET /api/sessions/{id}/commits", a.withDB(a.requireRead(a.handleSessionCommits)))
+	mux.HandleFunc("GET /api/sessions/{id}/context", a.withDB(a.requireRead(a.handleSessionContext)))
+
+	mux.HandleFunc("GET /api/sessions/{id}/annotations", a.withDB(a.requireRead(a.handleListAnnotations)))
+	mux.HandleFunc("POST /api/sessions/{id}/annotations", a.withDB(a.requireWrite(a.handleCreateAnnotation)))
+	mux.HandleFunc("PUT /api/sessions/{id}/annotations/{annotation}", a.withDB(a.requireWrite(a.handleUpdateAnnotation)))
+	mux.HandleFunc("DELETE /api/sessions/{id}/annotations/{annotation}", a.withDB(a.requireWrite(a.handleDeleteAnnotation)))
+	mux.HandleFunc("GET /api/sessions/{id}/room", a.withDB(a.requireRead(a.handleSessionRoom)))
+	mux.HandleFunc("POST /api/sessions/{id}/room/pointer", a.withDB(a.requireRead(a.handleRoomPointer)))
+}
+
+// withDB rejects requests when no database is configured
+func (a *apiServer) withDB(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a == nil || a.db == nil {
+			writeJSONError(w, r, http.StatusServiceUnavailable, "Database is not configured", nil)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// writeLoadError maps lookup failures to HTTP responses
+func (a *apiServer) writeLoadError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errSessionNotFound) || errors.Is(err, errAnnotationNotFound) || errors.Is(err, errViewNotFound) {
+		writeJSONError(w, r, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+	writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+}
+
+// maxRequestBody caps the size of JSON request bodies
+const maxRequestBody = 1 << 20
+
+// decodeJSONBody decodes the request body into v, writing a 400 and returning
+// false if it is not valid JSON
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid request body", err.Error())
+		return false
+	}
+	return true
+}
+
+// openOptionalDatabase connects to the configured database for the dev
+// server, returning nil if no config is present so the server still starts
+func openOptionalDatabase() (*sql.DB, *Config) {
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		log.Printf("Database API disabled: %v", err)
+		return nil, nil
+	}
+	return db, config
+}
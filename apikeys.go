@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// API key scopes. Read keys open the GET endpoints, write keys those and
+// the endpoints changing sessions, annotations and views, ingest keys the
+// ingest API.
+const (
+	apiKeyRead   = "read"
+	apiKeyWrite  = "write"
+	apiKeyIngest = "ingest"
+)
+
+// apiKeyPrefix starts every generated key, telling keys apart from the
+// admin and ingest tokens of ignored/config.json
+const apiKeyPrefix = "cmdk_"
+
+// apiKeyShownLength is how much of a key is stored to identify it in listings
+const apiKeyShownLength = len(apiKeyPrefix) + 8
+
+type apiKeyContextKey struct{}
+
+// APIKey is a row of the api_keys table. The key itself is only shown once,
+// when it is created.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scope      string     `json:"scope"`
+	Project    string     `json:"project,omitempty"`
+	Workspace  string     `json:"workspace,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// hashAPIKey is what the api_keys table stores of a key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIKey generates a key and stores its hash, returning the key
+func createAPIKey(db *sql.DB, name, scope, project, workspace string) (APIKey, string, error) {
+	if scope != apiKeyRead && scope != apiKeyWrite && scope != apiKeyIngest {
+		return APIKey{}, "", fmt.Errorf("scope must be read, write or ingest")
+	}
+	if !ingestProject.MatchString(project) || strings.Contains(project, "..") {
+		return APIKey{}, "", fmt.Errorf("invalid project %q", project)
+	}
+	if workspace != "" {
+		if _, err := getWorkspace(db, workspace); err != nil {
+			return APIKey{}, "", err
+		}
+	}
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return APIKey{}, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(random)
+
+	key := APIKey{Name: name, Prefix: secret[:apiKeyShownLength], Scope: scope, Project: project, Workspace: workspace}
+	err := db.QueryRow(`
+		INSERT INTO api_keys (name, prefix, key_hash, scope, project, workspace)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		name, key.Prefix, hashAPIKey(secret), scope, project, workspace).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("failed to store key: %w", err)
+	}
+	return key, secret, nil
+}
+
+// listAPIKeys returns every key, including revoked ones, oldest first
+func listAPIKeys(db *sql.DB) ([]APIKey, error) {
+	rows, err := db.Query(`
+		SELECT id, name, prefix, scope, project, workspace, created_at, last_used_at, revoked_at
+		FROM api_keys
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scope, &k.Project, &k.Workspace, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// revokeAPIKey revokes a key by ID or prefix, reporting whether one matched
+func revokeAPIKey(db *sql.DB, idOrPrefix string) (bool, error) {
+	id, _ := strconv.ParseInt(idOrPrefix, 10, 64)
+	result, err := db.Exec(`
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE (id = $1 OR prefix = $2) AND revoked_at IS NULL`, id, idOrPrefix)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke key: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// lookupAPIKey returns the unrevoked key matching secret, or nil. Use is
+// recorded at most once a minute per key.
+func lookupAPIKey(db *sql.DB, secret string) (*APIKey, error) {
+	var k APIKey
+	err := db.QueryRow(`
+		SELECT id, name, prefix, scope, project, workspace, created_at, last_used_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`, hashAPIKey(secret)).Scan(
+		&k.ID, &k.Name, &k.Prefix, &k.Scope, &k.Project, &k.Workspace, &k.CreatedAt, &k.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+	if k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > time.Minute {
+		if _, err := db.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, k.ID); err != nil {
+			log.Printf("Failed to record use of api key %s: %v", k.Prefix, err)
+		}
+	}
+	return &k, nil
+}
+
+// apiKeyFromContext returns the key a request was authorized with, if any
+func apiKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// authorizeAPIKey checks a bearer key against the scope an endpoint needs,
+// returning the request with the key in its context
+func (a *apiServer) authorizeAPIKey(w http.ResponseWriter, r *http.Request, secret, scope string) (*http.Request, bool) {
+	if a.db == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, "Database is not configured", nil)
+		return nil, false
+	}
+	key, err := lookupAPIKey(a.db, secret)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return nil, false
+	}
+	if key == nil {
+		writeJSONError(w, r, http.StatusUnauthorized, "A valid bearer token is required", nil)
+		return nil, false
+	}
+	if key.Scope != scope && !(scope == apiKeyRead && key.Scope == apiKeyWrite) {
+		writeJSONError(w, r, http.StatusForbidden, fmt.Sprintf("This endpoint needs a key with %s scope", scope), nil)
+		return nil, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)), true
+}
+
+// keyCanAccessSession reports whether a project or workspace scoped key may
+// use a session. Sessions that do not exist yet are allowed when ingesting,
+// so keys can push new sessions.
+func (a *apiServer) keyCanAccessSession(key *APIKey, sessionID string, allowNew bool) (bool, error) {
+	if key == nil || (key.Project == "" && key.Workspace == "") {
+		return true, nil
+	}
+	inProject, err := countSessions(a.db, SessionFilter{IDs: []string{sessionID}, Project: key.Project, Workspace: key.Workspace, IncludeDeleted: true})
+	if err != nil {
+		return false, err
+	}
+	if inProject > 0 {
+		return true, nil
+	}
+	if !allowNew {
+		return false, nil
+	}
+	anywhere, err := countSessions(a.db, SessionFilter{IDs: []string{sessionID}, IncludeDeleted: true})
+	return anywhere == 0, err
+}
+
+// requireRead guards the read endpoints. A bearer token must be a read key
+// or the admin token. Without one, requests are allowed unless
+// require_api_keys is set, in which case only this machine may read.
+// Project and workspace scoped keys may only read sessions of their
+// project or workspace; endpoints spanning them filter by it or use
+// requireReadAll.
+func (a *apiServer) requireRead(h http.HandlerFunc) http.HandlerFunc {
+	return a.requireScope(apiKeyRead, h)
+}
+
+// requireWrite guards the endpoints that change data like requireRead
+// guards reads, with write keys in place of read keys. Requests without a
+// key are only allowed from this machine, whether or not require_api_keys
+// is set, and not from pages of other sites.
+func (a *apiServer) requireWrite(h http.HandlerFunc) http.HandlerFunc {
+	return a.requireScope(apiKeyWrite, h)
+}
+
+// requireScope implements requireRead and requireWrite
+func (a *apiServer) requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config := a.currentConfig()
+		secret, ok := bearerToken(r)
+		if !ok {
+			if scope == apiKeyWrite || config != nil && config.RequireAPIKeys {
+				if ip := net.ParseIP(clientIP(r)); ip == nil || !ip.IsLoopback() {
+					writeJSONError(w, r, http.StatusUnauthorized, fmt.Sprintf("A %s API key is required", scope), nil)
+					return
+				}
+			}
+			if scope == apiKeyWrite && refuseCrossSite(w, r) {
+				return
+			}
+			h(w, r)
+			return
+		}
+		if config != nil && config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(config.AdminToken)) == 1 {
+			h(w, r)
+			return
+		}
+		r, ok = a.authorizeAPIKey(w, r, secret, scope)
+		if !ok {
+			return
+		}
+		if id := r.PathValue("id"); id != "" {
+			allowed, err := a.keyCanAccessSession(apiKeyFromContext(r.Context()), id, false)
+			if err != nil {
+				writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+				return
+			}
+			if !allowed {
+				a.writeLoadError(w, r, fmt.Errorf("%w: %s", errSessionNotFound, id))
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// requireReadAll guards read endpoints that span projects and cannot be
+// narrowed to the project or workspace of a scoped key
+func (a *apiServer) requireReadAll(h http.HandlerFunc) http.HandlerFunc {
+	return a.requireRead(requireUnscopedKey(h))
+}
+
+// requireWriteAll guards write endpoints that span projects, such as the
+// shared saved views
+func (a *apiServer) requireWriteAll(h http.HandlerFunc) http.HandlerFunc {
+	return a.requireWrite(requireUnscopedKey(h))
+}
+
+// requireUnscopedKey refuses keys limited to a project or workspace
+func requireUnscopedKey(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := apiKeyFromContext(r.Context()); key != nil && (key.Project != "" || key.Workspace != "") {
+			writeJSONError(w, r, http.StatusForbidden, "This endpoint needs a key that is not limited to a project or workspace", nil)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// scopeSessionFilter narrows a session filter to the project and workspace
+// of the request's key
+func scopeSessionFilter(r *http.Request, filter *SessionFilter) error {
+	key := apiKeyFromContext(r.Context())
+	if key == nil {
+		return nil
+	}
+	if key.Project != "" {
+		if filter.Project != "" && filter.Project != key.Project {
+			return fmt.Errorf("this key can only read project %s", key.Project)
+		}
+		filter.Project = key.Project
+	}
+	if key.Workspace != "" {
+		if filter.Workspace != "" && filter.Workspace != key.Workspace {
+			return fmt.Errorf("this key can only read workspace %s", key.Workspace)
+		}
+		filter.Workspace = key.Workspace
+	}
+	return nil
+}
+
+// apikeyCommand groups the API key subcommands
+func apikeyCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "apikey",
+		Usage: "Create, list and revoke API keys for reading and ingesting sessions",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "create",
+				Usage: "Create a key and print it once",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "scope",
+						Usage:    "What the key may do: read, write or ingest",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "Limit the key to this ~/.claude/projects directory",
+					},
+					&cli.StringFlag{
+						Name:  "workspace",
+						Usage: "Limit the key to this workspace",
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "Who or what the key is for",
+					},
+				},
+				Action: apikeyCreateCommand,
+			},
+			{
+				Name:   "list",
+				Usage:  "List keys and when they were last used",
+				Flags:  sessionOutputFlags(),
+				Action: apikeyListCommand,
+			},
+			{
+				Name:      "revoke",
+				Usage:     "Revoke a key by ID or prefix",
+				ArgsUsage: "<id|prefix>",
+				Action:    apikeyRevokeCommand,
+			},
+		},
+	}
+}
+
+// apikeyCreateCommand implements `apikey create`
+func apikeyCreateCommand(c *cli.Context) error {
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	key, secret, err := createAPIKey(db, c.String("name"), c.String("scope"), c.String("project"), c.String("workspace"))
+	if err != nil {
+		return err
+	}
+	project := key.Project
+	if project == "" {
+		project = "all projects"
+	}
+	if key.Workspace != "" {
+		project += " in workspace " + key.Workspace
+	}
+	fmt.Printf("🔑 Created %s key %d for %s:\n\n   %s\n\n", key.Scope, key.ID, project, secret)
+	fmt.Println("⚠️  Store it now; it cannot be shown again. Send it as an Authorization: Bearer header.")
+	return nil
+}
+
+// apikeyListCommand implements `apikey list`
+func apikeyListCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	keys, err := listAPIKeys(db)
+	if err != nil {
+		return err
+	}
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	}
+	header := []string{"ID", "PREFIX", "SCOPE", "PROJECT", "WORKSPACE", "NAME", "CREATED", "LAST USED", "REVOKED"}
+	rows := make([][]string, len(keys))
+	for i, k := range keys {
+		project, ws := k.Project, k.Workspace
+		if project == "" {
+			project = "*"
+		}
+		if ws == "" {
+			ws = "*"
+		}
+		rows[i] = []string{strconv.FormatInt(k.ID, 10), k.Prefix, k.Scope, project, ws, k.Name,
+			formatTime(&k.CreatedAt), formatTime(k.LastUsedAt), formatTime(k.RevokedAt)}
+	}
+	return writeOutput(os.Stdout, format, keys, header, rows)
+}
+
+// apikeyRevokeCommand implements `apikey revoke`
+func apikeyRevokeCommand(c *cli.Context) error {
+	target := c.Args().First()
+	if target == "" {
+		return fmt.Errorf("key ID or prefix is required")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	revoked, err := revokeAPIKey(db, target)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return fmt.Errorf("no active key matches %s", target)
+	}
+	fmt.Printf("✅ Revoked key %s\n", target)
+	return nil
+}
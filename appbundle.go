@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// appBundlePath is where the compiled main app is served
+const appBundlePath = "/app.js"
+
+// appBundle is the main app compiled in memory. It is built when the server
+// starts and again once one of its source files changes, so / loads a
+// single cacheable script instead of building the entry on every visit.
+type appBundle struct {
+	mu      sync.Mutex
+	entry   string
+	data    []byte
+	hash    string
+	errors  []string
+	stale   bool
+	inputs  map[string]bool
+	watched map[string]bool
+	watcher *fsnotify.Watcher
+}
+
+// mainBundle is the bundle served at /app.js, nil when the app is embedded
+// or built per request
+var mainBundle *appBundle
+
+// startAppBundle builds the configured entry and keeps it up to date until
+// the returned function is called
+func startAppBundle() (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	b := &appBundle{inputs: make(map[string]bool), watched: make(map[string]bool), watcher: watcher}
+	b.mu.Lock()
+	b.rebuild()
+	b.mu.Unlock()
+	mainBundle = b
+
+	done := make(chan struct{})
+	go b.watch(done)
+	return func() {
+		close(done)
+		watcher.Close()
+	}, nil
+}
+
+// rebuild builds the configured entry. The caller holds b.mu.
+func (b *appBundle) rebuild() {
+	b.entry = strings.TrimPrefix(currentBuildConfig().entryPath(), "./")
+	b.stale = false
+	start := time.Now()
+	result, _, err := moduleBuilds.Build(context.Background(), b.entry)
+	if err != nil {
+		b.errors = []string{err.Error()}
+		log.Printf("App bundle build of %s failed: %v", b.entry, err)
+		return
+	}
+	stats.RecordBuild(b.entry, time.Since(start), len(result.Errors) > 0)
+	if len(result.Errors) > 0 {
+		b.errors = formatBuildErrors(result.Errors)
+		log.Printf("App bundle build of %s failed in %s: %s", b.entry, time.Since(start), strings.Join(b.errors, "; "))
+		// A failed build has no metafile, so keep watching the previous inputs
+		b.track([]string{b.entry})
+		return
+	}
+	if len(result.OutputFiles) == 0 {
+		b.errors = []string{"No output generated from build"}
+		return
+	}
+
+	b.data = result.OutputFiles[0].Contents
+	sum := sha256.Sum256(b.data)
+	b.hash = hex.EncodeToString(sum[:8])
+	b.errors = nil
+	clear(b.inputs)
+	b.track(buildInputs(b.entry, result.Metafile))
+	log.Printf("Built %s into %s (%s) in %s", b.entry, appBundlePath, formatSize(len(b.data)), time.Since(start))
+}
+
+// track watches the directories of files, since editors often save by
+// replacing the file
+func (b *appBundle) track(files []string) {
+	for _, file := range files {
+		b.inputs[file] = true
+		dir := filepath.Dir(file)
+		if !b.watched[dir] {
+			if err := b.watcher.Add(dir); err == nil {
+				b.watched[dir] = true
+			}
+		}
+	}
+}
+
+// watch marks the bundle stale when an input changes and rebuilds it once
+// the burst of events is over
+func (b *appBundle) watch(done <-chan struct{}) {
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-b.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			b.mu.Lock()
+			if b.inputs[filepath.Clean(event.Name)] {
+				b.stale = true
+				debounce.Reset(rebuildDebounce)
+			}
+			b.mu.Unlock()
+		case <-debounce.C:
+			b.mu.Lock()
+			if b.stale {
+				b.rebuild()
+			}
+			b.mu.Unlock()
+		case err, ok := <-b.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("App bundle watcher error: %v", err)
+		}
+	}
+}
+
+// current returns the bundle, rebuilding first if a change has not been
+// picked up yet or the configured entry changed. A page reloaded right
+// after a save therefore never gets the old bundle.
+func (b *appBundle) current() (entry string, data []byte, hash string, errors []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stale || b.entry != strings.TrimPrefix(currentBuildConfig().entryPath(), "./") {
+		b.rebuild()
+	}
+	return b.entry, b.data, b.hash, b.errors
+}
+
+// servePage serves the main app page, which loads the bundle by its hash
+func (b *appBundle) servePage(w http.ResponseWriter, r *http.Request) {
+	entry, _, hash, errors := b.current()
+	if _, err := os.Stat(entry); os.IsNotExist(err) {
+		serveReactApp(w, r, entry, "ClaudeDocApp")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-cache")
+	if len(errors) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(generateErrorHTML(entry, errors, traceIDFromContext(r.Context()))))
+		return
+	}
+	w.Write([]byte(generateModuleHTML("ClaudeDocApp", appBundlePath+"?v="+hash, entry)))
+}
+
+// handleAppBundle serves GET /app.js. Requests naming the current hash, as
+// the app page does, may cache the bundle forever; others revalidate.
+func handleAppBundle(w http.ResponseWriter, r *http.Request) {
+	if embeddedApp != nil {
+		serveEmbedded(w, r)
+		return
+	}
+	if mainBundle == nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, data, hash, errors := mainBundle.current()
+	if len(errors) > 0 {
+		writeJSONError(w, r, http.StatusBadRequest, "Build failed", errors)
+		return
+	}
+	cacheControl := "no-cache"
+	if r.URL.Query().Get("v") == hash {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	serveModuleContent(w, r, data, cacheControl)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// attachmentSourceType marks an image source whose data was moved to the blob store
+const attachmentSourceType = "attachment"
+
+// imageSource is the source of an image content block. Base64 sources carry
+// the image; attachment sources point at it in the blob store.
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Size      int    `json:"size,omitempty"`
+}
+
+// attachmentExtractor moves base64 images out of messages into a blob store
+type attachmentExtractor struct {
+	store blobStore
+}
+
+// newAttachmentExtractor returns nil when image extraction is disabled or
+// blobs are discarded, since images cannot be kept as a preview
+func newAttachmentExtractor(config BlobConfig) (*attachmentExtractor, error) {
+	if config.Images != nil && !*config.Images {
+		return nil, nil
+	}
+	store, err := newBlobStore(config)
+	if err != nil || store == nil {
+		return nil, err
+	}
+	return &attachmentExtractor{store: store}, nil
+}
+
+// Extract rewrites the messages of a session in place, recording how many
+// images were moved and their decoded size in the session metadata
+func (x *attachmentExtractor) Extract(session *ClaudeSession) error {
+	if x == nil {
+		return nil
+	}
+	count, size := 0, 0
+	for i := range session.Messages {
+		msg := &session.Messages[i]
+		if !bytes.Contains(msg.Message, []byte(`"base64"`)) {
+			continue
+		}
+		var envelope map[string]json.RawMessage
+		if json.Unmarshal(msg.Message, &envelope) != nil {
+			continue
+		}
+		content, n, extracted, err := x.extractBlocks(envelope["content"])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		envelope["content"] = content
+		if msg.Message, err = json.Marshal(envelope); err != nil {
+			return err
+		}
+		count += n
+		size += extracted
+	}
+	if count > 0 {
+		session.Metadata["attachments"] = map[string]int{"count": count, "bytes": size}
+	}
+	return nil
+}
+
+// extractBlocks stores the base64 images of a content array, including
+// those nested in tool results, and returns the rewritten array
+func (x *attachmentExtractor) extractBlocks(content json.RawMessage) (json.RawMessage, int, int, error) {
+	var blocks []map[string]json.RawMessage
+	if json.Unmarshal(content, &blocks) != nil {
+		return content, 0, 0, nil
+	}
+	count, size := 0, 0
+	for _, block := range blocks {
+		switch string(block["type"]) {
+		case `"image"`:
+			var source imageSource
+			if json.Unmarshal(block["source"], &source) != nil || source.Type != "base64" {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(source.Data)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(data)
+			key := hex.EncodeToString(sum[:])
+			if err := x.store.Put(key, data); err != nil {
+				return nil, 0, 0, err
+			}
+			block["source"], _ = json.Marshal(imageSource{
+				Type:      attachmentSourceType,
+				MediaType: source.MediaType,
+				ID:        key,
+				Size:      len(data),
+			})
+			count++
+			size += len(data)
+		case `"tool_result"`:
+			nested, n, extracted, err := x.extractBlocks(block["content"])
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			if n > 0 {
+				block["content"] = nested
+				count += n
+				size += extracted
+			}
+		}
+	}
+	if count == 0 {
+		return content, 0, 0, nil
+	}
+	out, err := json.Marshal(blocks)
+	return out, count, size, err
+}
+
+// Attachment is an image found in a session
+type Attachment struct {
+	ID           string `json:"id"`
+	MediaType    string `json:"media_type,omitempty"`
+	Size         int    `json:"size"`
+	MessageUUID  string `json:"message_uuid,omitempty"`
+	MessageIndex int    `json:"message_index"`
+	// ToolUseID is set for images returned by a tool
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	// URL serves the image; it is empty for images still stored inline
+	URL string `json:"url,omitempty"`
+}
+
+// listAttachments returns the images of a session in message order. Images
+// synced before extraction was enabled are listed without a URL.
+func listAttachments(session *ClaudeSession) []Attachment {
+	attachments := []Attachment{}
+	var walk func(index int, uuid, toolUseID string, content json.RawMessage)
+	walk = func(index int, uuid, toolUseID string, content json.RawMessage) {
+		var blocks []map[string]json.RawMessage
+		if json.Unmarshal(content, &blocks) != nil {
+			return
+		}
+		for _, block := range blocks {
+			switch string(block["type"]) {
+			case `"image"`:
+				var source imageSource
+				if json.Unmarshal(block["source"], &source) != nil {
+					continue
+				}
+				a := Attachment{MediaType: source.MediaType, MessageUUID: uuid, MessageIndex: index, ToolUseID: toolUseID}
+				switch source.Type {
+				case attachmentSourceType:
+					if !blobKeyPattern.MatchString(source.ID) {
+						continue
+					}
+					a.ID, a.Size, a.URL = source.ID, source.Size, "/attachments/"+source.ID
+				case "base64":
+					data, err := base64.StdEncoding.DecodeString(source.Data)
+					if err != nil {
+						continue
+					}
+					sum := sha256.Sum256(data)
+					a.ID, a.Size = hex.EncodeToString(sum[:]), len(data)
+				default:
+					continue
+				}
+				attachments = append(attachments, a)
+			case `"tool_result"`:
+				var id string
+				json.Unmarshal(block["tool_use_id"], &id)
+				walk(index, uuid, id, block["content"])
+			}
+		}
+	}
+	for i, msg := range session.Messages {
+		if len(msg.Message) == 0 {
+			continue
+		}
+		var envelope struct {
+			Content json.RawMessage `json:"content"`
+		}
+		if json.Unmarshal(msg.Message, &envelope) == nil {
+			walk(i, msg.UUID, "", envelope.Content)
+		}
+	}
+	return attachments
+}
+
+// handleSessionAttachments serves GET /api/sessions/{id}/attachments
+func (a *apiServer) handleSessionAttachments(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wholePage(listAttachments(session)))
+}
+
+// handleGetAttachment serves GET /attachments/{id}, an extracted image with
+// a content type sniffed from its data so browsers render it inline
+func (a *apiServer) handleGetAttachment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !blobKeyPattern.MatchString(id) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid attachment id", nil)
+		return
+	}
+	var config BlobConfig
+	if settings := a.currentConfig(); settings != nil {
+		config = settings.Blobs
+	}
+	store, err := newBlobStore(config)
+	if err != nil || store == nil {
+		writeJSONError(w, r, http.StatusNotFound, "Blob storage is not configured", nil)
+		return
+	}
+	data, err := store.Get(id)
+	if err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Attachment not found", nil)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		// Never let stored data render as a page on this origin
+		contentType = "application/octet-stream"
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Write(data)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AuditEntry records a single mutation made through the API
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Actor      string          `json:"actor"`
+	IP         string          `json:"ip"`
+	TraceID    string          `json:"trace_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	OldValue   json.RawMessage `json:"old_value,omitempty"`
+	NewValue   json.RawMessage `json:"new_value,omitempty"`
+}
+
+// audit records a mutation. Failures are logged rather than failing the request,
+// since the change itself has already been made.
+func (a *apiServer) audit(r *http.Request, action, targetType, targetID string, oldValue, newValue interface{}) {
+	encode := func(v interface{}) []byte {
+		if v == nil {
+			return nil
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	_, err := a.db.Exec(`
+		INSERT INTO audit_log (actor, ip, trace_id, action, target_type, target_id, old_value, new_value)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		clientKey(r), clientIP(r), traceIDFromContext(r.Context()),
+		action, targetType, targetID, encode(oldValue), encode(newValue))
+	if err != nil {
+		log.Printf("[trace=%s] Failed to write audit log for %s %s/%s: %v", traceIDFromContext(r.Context()), action, targetType, targetID, err)
+	}
+}
+
+// listAuditEntries returns entries newest first, optionally filtered by action
+// or target, starting below beforeID when it is set
+func listAuditEntries(db *sql.DB, action, targetID string, beforeID int64, limit int) ([]AuditEntry, error) {
+	query := `
+		SELECT id, created_at, actor, ip, trace_id, action, target_type, target_id, old_value, new_value
+		FROM audit_log
+		WHERE ($1 = '' OR action = $1)
+		  AND ($2 = '' OR target_id = $2)
+		  AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4`
+
+	rows, err := db.Query(query, action, targetID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var oldValue, newValue []byte
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Actor, &e.IP, &e.TraceID, &e.Action, &e.TargetType, &e.TargetID, &oldValue, &newValue); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.OldValue, e.NewValue = oldValue, newValue
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// authorizeBearer checks the request against a configured token. Without a
+// token configured, only requests from this machine are allowed. setting
+// names the config key so the error tells the user how to open access.
+func authorizeBearer(w http.ResponseWriter, r *http.Request, token, setting string) bool {
+	if token != "" {
+		got, _ := bearerToken(r)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeJSONError(w, r, http.StatusUnauthorized, "A valid bearer token is required", nil)
+			return false
+		}
+		return true
+	}
+	if ip := net.ParseIP(clientIP(r)); ip == nil || !ip.IsLoopback() {
+		writeJSONError(w, r, http.StatusForbidden, fmt.Sprintf("This endpoint is only available locally unless %s is configured", setting), nil)
+		return false
+	}
+	return true
+}
+
+// requireAdmin allows requests carrying the configured admin token. Without
+// one, changes must also come from a page of this server, since any site
+// open in a local browser reaches it from this machine.
+func (a *apiServer) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if config := a.currentConfig(); config != nil {
+			token = config.AdminToken
+		}
+		if token == "" && refuseCrossSite(w, r) {
+			return
+		}
+		if authorizeBearer(w, r, token, "admin_token") {
+			h(w, r)
+		}
+	}
+}
+
+// refuseCrossSite rejects browser requests that change data and were sent
+// by a page of another origin. Browsers send such requests without asking
+// when the body is plain text or empty. Clients other than browsers send
+// neither header and are allowed.
+func refuseCrossSite(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	crossSite := false
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		crossSite = site != "same-origin" && site != "none"
+	} else if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		crossSite = err != nil || u.Host != r.Host
+	}
+	if crossSite {
+		writeJSONError(w, r, http.StatusForbidden, "Cross-site requests are not allowed", nil)
+	}
+	return crossSite
+}
+
+// handleListAudit serves GET /api/audit?action=&target=&limit=&cursor=. An
+// entry ID in before starts the list below that entry, like a cursor.
+func (a *apiServer) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, err := pageFromQuery(q, 100, 1000)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var beforeID int64
+	if _, err := page.decode(&beforeID); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if v := q.Get("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, "before must be an audit entry ID", nil)
+			return
+		}
+		beforeID = n
+	}
+
+	entries, err := listAuditEntries(a.db, q.Get("action"), q.Get("target"), beforeID, page.Limit+1)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPage(entries, page, func(e AuditEntry) string {
+		return encodeCursor(e.ID)
+	}))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// BenchmarkExtract compares parsing session lines with the scanner against
+// the map decoding it replaced, over testdata/session.jsonl or a real
+// session file named by CLAUDEMD_BENCH_FILE:
+//
+//	CLAUDEMD_BENCH_FILE=session.jsonl go test -run '^$' -bench Extract -benchmem
+func BenchmarkExtract(b *testing.B) {
+	path := os.Getenv("CLAUDEMD_BENCH_FILE")
+	if path == "" {
+		path = "testdata/session.jsonl"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatalf("failed to read file: %v", err)
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		b.Fatalf("no lines in %s", path)
+	}
+
+	b.Run("scanner", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			for _, line := range lines {
+				parseSessionLine(line)
+			}
+		}
+	})
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			for _, line := range lines {
+				mapParseSessionLine(line)
+			}
+		}
+	})
+}
+
+// mapSessionMessage is a session line as it was decoded before the scanner
+type mapSessionMessage struct {
+	Type      string                 `json:"type"`
+	Summary   string                 `json:"summary,omitempty"`
+	LeafUUID  string                 `json:"leafUuid,omitempty"`
+	Message   map[string]interface{} `json:"message,omitempty"`
+	Content   string                 `json:"content,omitempty"`
+	UUID      string                 `json:"uuid,omitempty"`
+	Timestamp string                 `json:"timestamp,omitempty"`
+	Cwd       string                 `json:"cwd,omitempty"`
+}
+
+// mapParseSessionLine is parseSessionLine as it was before the scanner,
+// decoding the whole message into maps to summarize its content
+func mapParseSessionLine(line []byte) (mapSessionMessage, error) {
+	var msg mapSessionMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return msg, err
+	}
+	msg.Content = mapMessageContent(msg)
+	return msg, nil
+}
+
+// mapMessageContent is extractMessageContent as it was before the scanner
+func mapMessageContent(msg mapSessionMessage) string {
+	if msg.Summary != "" {
+		return msg.Summary
+	}
+	if msg.Message == nil {
+		return ""
+	}
+	switch c := msg.Message["content"].(type) {
+	case string:
+		return c
+	case []interface{}:
+		var textParts []string
+		for _, item := range c {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				if text, ok := block["text"].(string); ok {
+					textParts = append(textParts, text)
+				}
+			case "tool_use":
+				toolName := "unknown tool"
+				if name, ok := block["name"].(string); ok {
+					toolName = name
+				}
+				var inputDesc string
+				if input, ok := block["input"].(map[string]interface{}); ok {
+					if desc, ok := input["description"].(string); ok {
+						inputDesc = desc
+					} else if prompt, ok := input["prompt"].(string); ok {
+						inputDesc = prompt
+					} else {
+						inputDesc = "with parameters"
+					}
+				}
+				textParts = append(textParts, fmt.Sprintf("Used %s %s", toolName, inputDesc))
+			case "tool_result":
+				if result, ok := block["content"].(string); ok {
+					if len(result) > 200 {
+						result = result[:200] + "..."
+					}
+					textParts = append(textParts, fmt.Sprintf("Tool result: %s", result))
+				} else if _, ok := block["content"].([]interface{}); ok {
+					textParts = append(textParts, "Tool result received")
+				}
+			}
+		}
+		return strings.Join(textParts, " ")
+	}
+	return ""
+}
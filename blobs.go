@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BlobConfig moves oversized tool results and images out of the messages column
+type BlobConfig struct {
+	// ToolResultLimit is the size in bytes above which a tool result is
+	// offloaded. Zero disables offloading.
+	ToolResultLimit int `json:"tool_result_limit,omitempty"`
+	// PreviewBytes of the result text are kept inline
+	PreviewBytes int `json:"preview_bytes,omitempty"`
+	// Store is local, supabase, or none to truncate without keeping the full result
+	Store string `json:"store,omitempty"`
+	// Dir is where the local store writes blobs
+	Dir      string                `json:"dir,omitempty"`
+	Supabase SupabaseStorageConfig `json:"supabase"`
+	// Images moves base64 image blocks into the store, leaving a reference
+	// in the message. Defaults to true; the none store disables it.
+	Images *bool `json:"images,omitempty"`
+}
+
+// SupabaseStorageConfig points at a Supabase Storage bucket
+type SupabaseStorageConfig struct {
+	URL    string `json:"url,omitempty"`
+	Bucket string `json:"bucket,omitempty"`
+	// ServiceKeyEnv names the environment variable holding the service role key
+	ServiceKeyEnv string `json:"service_key_env,omitempty"`
+}
+
+const (
+	defaultBlobPreview = 2048
+	defaultBlobDir     = "ignored/blobs"
+	defaultSupabaseEnv = "SUPABASE_SERVICE_ROLE_KEY"
+)
+
+// blobKeyPattern matches the content hashes blobs are stored under
+var blobKeyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// BlobRef replaces the content of an offloaded tool result
+type BlobRef struct {
+	Store string `json:"store"`
+	Key   string `json:"key"`
+	Size  int    `json:"size"`
+}
+
+// blobStore keeps offloaded content addressed by its SHA-256
+type blobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+}
+
+// newBlobStore returns the configured store, or nil when blobs are discarded
+func newBlobStore(config BlobConfig) (blobStore, error) {
+	switch config.Store {
+	case "", "local":
+		dir := config.Dir
+		if dir == "" {
+			dir = defaultBlobDir
+		}
+		return localBlobStore{dir: dir}, nil
+	case "supabase":
+		s := config.Supabase
+		if s.URL == "" || s.Bucket == "" {
+			return nil, fmt.Errorf("blobs.supabase.url and blobs.supabase.bucket are required")
+		}
+		env := s.ServiceKeyEnv
+		if env == "" {
+			env = defaultSupabaseEnv
+		}
+		key := os.Getenv(env)
+		if key == "" {
+			return nil, fmt.Errorf("%s is not set", env)
+		}
+		return &supabaseBlobStore{
+			baseURL: strings.TrimRight(s.URL, "/") + "/storage/v1/object/" + s.Bucket,
+			key:     key,
+			client:  &http.Client{Timeout: time.Minute},
+		}, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q (valid: local, supabase, none)", config.Store)
+	}
+}
+
+// localBlobStore writes gzip-compressed blobs under dir, sharded by hash prefix
+type localBlobStore struct {
+	dir string
+}
+
+func (s localBlobStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key+".gz")
+}
+
+func (s localBlobStore) Put(key string, data []byte) error {
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress blob: %w", err)
+	}
+	// Write to a temp file first so readers never see a partial blob
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s localBlobStore) Get(key string) ([]byte, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob %s: %w", key, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", key, err)
+	}
+	return io.ReadAll(gz)
+}
+
+// keys lists the keys of every stored blob
+func (s localBlobStore) keys() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", "*.gz"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, path := range paths {
+		key := strings.TrimSuffix(filepath.Base(path), ".gz")
+		if blobKeyPattern.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// supabaseBlobStore uses the Supabase Storage REST API
+type supabaseBlobStore struct {
+	baseURL string
+	key     string
+	client  *http.Client
+}
+
+func (s *supabaseBlobStore) newRequest(method, key string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, s.baseURL+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.key)
+	req.Header.Set("apikey", s.key)
+	return req, nil
+}
+
+func (s *supabaseBlobStore) Put(key string, data []byte) error {
+	req, err := s.newRequest("POST", key, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Blobs are content addressed, so an existing object already holds this data
+	req.Header.Set("x-upsert", "true")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload blob: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to upload blob: %s: %s", resp.Status, detail)
+	}
+	return nil
+}
+
+func (s *supabaseBlobStore) Get(key string) ([]byte, error) {
+	req, err := s.newRequest("GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download blob %s: %s", key, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// blobOffloader replaces oversized tool results with a preview and a reference
+type blobOffloader struct {
+	limit   int
+	preview int
+	name    string
+	store   blobStore
+}
+
+// newBlobOffloader returns nil when offloading is disabled
+func newBlobOffloader(config BlobConfig) (*blobOffloader, error) {
+	if config.ToolResultLimit <= 0 {
+		return nil, nil
+	}
+	store, err := newBlobStore(config)
+	if err != nil {
+		return nil, err
+	}
+	o := &blobOffloader{limit: config.ToolResultLimit, preview: config.PreviewBytes, name: config.Store, store: store}
+	if o.preview <= 0 {
+		o.preview = defaultBlobPreview
+	}
+	if o.name == "" {
+		o.name = "local"
+	}
+	return o, nil
+}
+
+// Offload rewrites the messages of a session in place, recording how many
+// results were moved and how many bytes that saved in the session metadata
+func (o *blobOffloader) Offload(session *ClaudeSession) error {
+	if o == nil {
+		return nil
+	}
+	count, saved := 0, 0
+	for i := range session.Messages {
+		n, size, err := o.offloadMessage(&session.Messages[i])
+		if err != nil {
+			return err
+		}
+		count += n
+		saved += size
+	}
+	if count > 0 {
+		session.Metadata["offloaded_blobs"] = map[string]int{"count": count, "bytes": saved}
+	}
+	return nil
+}
+
+// offloadMessage moves the oversized tool results of one message
+func (o *blobOffloader) offloadMessage(msg *SessionMessage) (int, int, error) {
+	if len(msg.Message) < o.limit {
+		return 0, 0, nil
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Message, &envelope); err != nil {
+		return 0, 0, nil
+	}
+	var blocks []map[string]json.RawMessage
+	if err := json.Unmarshal(envelope["content"], &blocks); err != nil {
+		return 0, 0, nil
+	}
+
+	count, saved := 0, 0
+	for _, block := range blocks {
+		content := block["content"]
+		if string(block["type"]) != `"tool_result"` || len(content) <= o.limit {
+			continue
+		}
+
+		preview := toolResultText(content)
+		if len(preview) > o.preview {
+			preview = strings.ToValidUTF8(preview[:o.preview], "")
+		}
+		preview += fmt.Sprintf("\n… [%d bytes stored externally]", len(content))
+		block["content"], _ = json.Marshal(preview)
+
+		if o.store != nil {
+			sum := sha256.Sum256(content)
+			ref := BlobRef{Store: o.name, Key: hex.EncodeToString(sum[:]), Size: len(content)}
+			if err := o.store.Put(ref.Key, content); err != nil {
+				return 0, 0, err
+			}
+			block["blob"], _ = json.Marshal(ref)
+		}
+		count++
+		saved += len(content) - len(block["content"])
+	}
+	if count == 0 {
+		return 0, 0, nil
+	}
+
+	var err error
+	if envelope["content"], err = json.Marshal(blocks); err != nil {
+		return 0, 0, err
+	}
+	if msg.Message, err = json.Marshal(envelope); err != nil {
+		return 0, 0, err
+	}
+	return count, saved, nil
+}
+
+// rehydrateSession restores offloaded tool results from the blob store.
+// Results whose blob cannot be read keep their preview.
+func rehydrateSession(session *ClaudeSession, store blobStore) {
+	if store == nil {
+		return
+	}
+	for i := range session.Messages {
+		msg := &session.Messages[i]
+		if !bytes.Contains(msg.Message, []byte(`"blob"`)) {
+			continue
+		}
+		var envelope map[string]json.RawMessage
+		var blocks []map[string]json.RawMessage
+		if json.Unmarshal(msg.Message, &envelope) != nil || json.Unmarshal(envelope["content"], &blocks) != nil {
+			continue
+		}
+
+		changed := false
+		for _, block := range blocks {
+			var ref BlobRef
+			if json.Unmarshal(block["blob"], &ref) != nil || !blobKeyPattern.MatchString(ref.Key) {
+				continue
+			}
+			data, err := store.Get(ref.Key)
+			if err != nil || !json.Valid(data) {
+				continue
+			}
+			block["content"] = data
+			delete(block, "blob")
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		envelope["content"], _ = json.Marshal(blocks)
+		msg.Message, _ = json.Marshal(envelope)
+	}
+}
+
+// handleGetSession serves GET /api/sessions/{id}. Offloaded tool results are
+// restored unless ?blobs=ref asks for the stored references, and
+// ?thinking=exclude leaves out extended thinking. previous and next link to
+// the sessions this one continues and is continued by.
+func (a *apiServer) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	includeThinking, ok := thinkingParam(w, r)
+	if !ok {
+		return
+	}
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	if config := a.currentConfig(); r.URL.Query().Get("blobs") != "ref" && config != nil {
+		store, err := newBlobStore(config.Blobs)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		rehydrateSession(session, store)
+	}
+	if !includeThinking {
+		excludeThinking(session.Messages)
+	}
+	if session.Previous, session.Next, err = sessionLinks(a.db, session.SessionID); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	if session.Outcome, err = loadOutcome(a.db, session.SessionID); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// handleGetBlob serves GET /api/blobs/{key}, the raw JSON content of an offloaded tool result
+func (a *apiServer) handleGetBlob(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !blobKeyPattern.MatchString(key) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid blob key", nil)
+		return
+	}
+	var config BlobConfig
+	if settings := a.currentConfig(); settings != nil {
+		config = settings.Blobs
+	}
+	store, err := newBlobStore(config)
+	if err != nil || store == nil {
+		writeJSONError(w, r, http.StatusNotFound, "Blob storage is not configured", nil)
+		return
+	}
+	data, err := store.Get(key)
+	if err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Blob not found", nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Write(data)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// productionBuilds serialises builds that write to disk, so two requests
+// never write the same output files at once
+var productionBuilds sync.Mutex
+
+// productionBuild bundles entry into outDir/app.js and writes the
+// index.html that loads it. Build errors are returned in the result; err is
+// only set when the HTML cannot be written.
+func productionBuild(config BuildConfig, entry, outDir string) (api.BuildResult, error) {
+	productionBuilds.Lock()
+	defer productionBuilds.Unlock()
+
+	result := buildWithConfig(config, entry, filepath.Join(outDir, "app.js"), true)
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+	htmlPath := filepath.Join(outDir, "index.html")
+	if err := os.WriteFile(htmlPath, []byte(generateProductionHTML(config, "./app.js")), 0644); err != nil {
+		return result, fmt.Errorf("failed to write HTML file: %v", err)
+	}
+	return result, nil
+}
+
+// buildRequest is the body of POST /api/build. Options override the
+// server's build configuration for this build only, and Profile applies a
+// configured build profile over them.
+type buildRequest struct {
+	Entry   string       `json:"entry"`
+	OutDir  string       `json:"outdir"`
+	Profile string       `json:"profile"`
+	Options *BuildConfig `json:"options"`
+}
+
+// BuildOutput is a file written by a build
+type BuildOutput struct {
+	Path  string `json:"path"`
+	Bytes int    `json:"bytes"`
+}
+
+// BuildReport describes a build run through the API
+type BuildReport struct {
+	Entry      string        `json:"entry"`
+	OutDir     string        `json:"outdir"`
+	Target     string        `json:"target"`
+	Profile    string        `json:"profile,omitempty"`
+	DurationMS int64         `json:"duration_ms"`
+	Outputs    []BuildOutput `json:"outputs"`
+	Warnings   []string      `json:"warnings"`
+	Errors     []string      `json:"errors,omitempty"`
+}
+
+// handleBuild serves POST /api/build, running a production build like the
+// build command does. An empty body builds the configured entry into the
+// working directory.
+func handleBuild(w http.ResponseWriter, r *http.Request) {
+	// Options are decoded over a copy of the active config, so defines and
+	// import map entries add to the configured ones
+	config := currentBuildConfig()
+	config.Define = copyStringMap(config.Define)
+	config.ImportMap = copyStringMap(config.ImportMap)
+	req := buildRequest{Options: &config}
+	if r.ContentLength != 0 && !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Options == nil {
+		req.Options = &config
+	}
+	if err := req.Options.applyProfile(req.Profile); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := req.Options.validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	entry := req.Options.entryPath()
+	if req.Entry != "" {
+		entry = req.Entry
+	}
+	outDir := req.OutDir
+	if outDir == "" {
+		outDir = "."
+	}
+	for _, p := range []string{entry, outDir} {
+		if clean := filepath.Clean(p); strings.Contains(clean, "..") || filepath.IsAbs(clean) {
+			writeJSONError(w, r, http.StatusBadRequest, "Paths must be inside the project", map[string]string{"path": p})
+			return
+		}
+	}
+	entry = "./" + filepath.Clean(entry)
+	if _, err := os.Stat(entry); err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Entry not found", map[string]string{"entry": strings.TrimPrefix(entry, "./")})
+		return
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to create output directory: %v", err), nil)
+		return
+	}
+
+	start := time.Now()
+	var writeErr error
+	result := publishBuild(r.Context(), entry, func() api.BuildResult {
+		result, err := productionBuild(*req.Options, entry, outDir)
+		writeErr = err
+		return result
+	})
+	if writeErr != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, writeErr.Error(), nil)
+		return
+	}
+
+	target := req.Options.Target
+	if target == "" {
+		target = defaultBuildTarget
+	}
+	report := BuildReport{
+		Entry:      strings.TrimPrefix(entry, "./"),
+		OutDir:     outDir,
+		Target:     strings.ToLower(target),
+		Profile:    req.Options.Profile,
+		DurationMS: time.Since(start).Milliseconds(),
+		Outputs:    buildOutputs(result),
+		Warnings:   formatBuildErrors(result.Warnings),
+	}
+	if len(result.Errors) > 0 {
+		report.Errors = formatBuildErrors(result.Errors)
+		writeJSONError(w, r, http.StatusBadRequest, "Build failed", report)
+		return
+	}
+	htmlPath := filepath.Join(outDir, "index.html")
+	if info, err := os.Stat(htmlPath); err == nil {
+		report.Outputs = append(report.Outputs, BuildOutput{Path: htmlPath, Bytes: int(info.Size())})
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// buildOutputs lists the files a build wrote, from its metafile
+func buildOutputs(result api.BuildResult) []BuildOutput {
+	var meta struct {
+		Outputs map[string]struct {
+			Bytes int `json:"bytes"`
+		} `json:"outputs"`
+	}
+	outputs := []BuildOutput{}
+	if err := json.Unmarshal([]byte(result.Metafile), &meta); err != nil {
+		return outputs
+	}
+	for path, out := range meta.Outputs {
+		outputs = append(outputs, BuildOutput{Path: path, Bytes: out.Bytes})
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Path < outputs[j].Path })
+	return outputs
+}
+
+// copyStringMap returns a copy of m that can be modified independently
+func copyStringMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
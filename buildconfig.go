@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/urfave/cli/v2"
+)
+
+// BuildConfig controls esbuild output for the build command and the dev server endpoints
+type BuildConfig struct {
+	// Target is an ECMAScript version such as "es2020" or "esnext"
+	Target string `json:"target,omitempty"`
+	// Minify enables full minification. When unset, production builds only
+	// strip whitespace and dev server builds are left unminified.
+	Minify      *bool             `json:"minify,omitempty"`
+	DropConsole bool              `json:"drop_console,omitempty"`
+	Define      map[string]string `json:"define,omitempty"`
+	// LegalComments is one of none, inline, eof, linked or external
+	LegalComments string `json:"legal_comments,omitempty"`
+	// Entry is the app entry point served at / and built by the build command
+	Entry string `json:"entry,omitempty"`
+	// ImportMap adds to or overrides the import map of served pages
+	ImportMap map[string]string `json:"import_map,omitempty"`
+	// External packages are left to the import map instead of being bundled
+	External []string `json:"external,omitempty"`
+	// Sourcemap is none (default), inline, linked or external. Dev server
+	// builds have no file to link a sourcemap from, so they inline it.
+	Sourcemap string `json:"sourcemap,omitempty"`
+	// Profile names the profile used when --profile is not given
+	Profile string `json:"profile,omitempty"`
+	// Profiles are named option sets, such as dev, staging and prod,
+	// applied over the options above
+	Profiles map[string]BuildProfile `json:"profiles,omitempty"`
+	// RequiredEnv names environment variables the app reads as
+	// process.env.NAME. Each is defined from the environment of the build,
+	// which fails when one is unset rather than shipping it undefined.
+	RequiredEnv []string `json:"required_env,omitempty"`
+}
+
+// BuildProfile overrides build options for one environment. Fields left
+// empty keep the base option.
+type BuildProfile struct {
+	Target        string `json:"target,omitempty"`
+	Minify        *bool  `json:"minify,omitempty"`
+	DropConsole   *bool  `json:"drop_console,omitempty"`
+	LegalComments string `json:"legal_comments,omitempty"`
+	Sourcemap     string `json:"sourcemap,omitempty"`
+	// Define adds to the base defines
+	Define map[string]string `json:"define,omitempty"`
+	// Env defines process.env.NAME as each value, quoted as a string
+	Env map[string]string `json:"env,omitempty"`
+	// RequiredEnv adds to the base required environment variables
+	RequiredEnv []string `json:"required_env,omitempty"`
+}
+
+// defaultBuildTarget is shared by every endpoint so dev and production output match
+const defaultBuildTarget = "es2020"
+
+// defaultBuildEntry is the app entry point when none is configured
+const defaultBuildEntry = "index.tsx"
+
+// envNamePattern matches names usable as process.env.NAME
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// defaultImportMap resolves the shared runtime dependencies of served pages
+var defaultImportMap = map[string]string{
+	"react":                 "https://esm.sh/react@18",
+	"react-dom":             "https://esm.sh/react-dom@18",
+	"react-dom/client":      "https://esm.sh/react-dom@18/client",
+	"react/jsx-runtime":     "https://esm.sh/react@18/jsx-runtime",
+	"@supabase/supabase-js": "https://esm.sh/@supabase/supabase-js@2",
+}
+
+var buildTargets = map[string]api.Target{
+	"esnext": api.ESNext,
+	"es2015": api.ES2015,
+	"es2016": api.ES2016,
+	"es2017": api.ES2017,
+	"es2018": api.ES2018,
+	"es2019": api.ES2019,
+	"es2020": api.ES2020,
+	"es2021": api.ES2021,
+	"es2022": api.ES2022,
+	"es2023": api.ES2023,
+	"es2024": api.ES2024,
+}
+
+var sourcemapModes = map[string]api.SourceMap{
+	"":         api.SourceMapNone,
+	"none":     api.SourceMapNone,
+	"inline":   api.SourceMapInline,
+	"linked":   api.SourceMapLinked,
+	"external": api.SourceMapExternal,
+}
+
+var legalCommentModes = map[string]api.LegalComments{
+	"":         api.LegalCommentsDefault,
+	"none":     api.LegalCommentsNone,
+	"inline":   api.LegalCommentsInline,
+	"eof":      api.LegalCommentsEndOfFile,
+	"linked":   api.LegalCommentsLinked,
+	"external": api.LegalCommentsExternal,
+}
+
+// buildConfig is the active build configuration, set when a command starts
+// and replaced when the config is reloaded
+var (
+	buildConfig   = BuildConfig{Target: defaultBuildTarget}
+	buildConfigMu sync.RWMutex
+)
+
+// currentBuildConfig returns the active build configuration for requests
+// that may run during a config reload
+func currentBuildConfig() BuildConfig {
+	buildConfigMu.RLock()
+	defer buildConfigMu.RUnlock()
+	return buildConfig
+}
+
+// buildFlags are the esbuild options shared by the build, serve and daemon commands
+func buildFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "target",
+			Usage: "ECMAScript target for compiled output (es2015-es2024, esnext)",
+		},
+		&cli.BoolFlag{
+			Name:  "minify",
+			Usage: "Minify whitespace, identifiers and syntax",
+		},
+		&cli.BoolFlag{
+			Name:  "drop-console",
+			Usage: "Remove console.* calls from compiled output",
+		},
+		&cli.StringSliceFlag{
+			Name:  "define",
+			Usage: "Replace a global identifier with a constant expression (KEY=VALUE, repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "legal-comments",
+			Usage: "Where to keep legal comments: none, inline, eof, linked or external",
+		},
+		&cli.StringFlag{
+			Name:  "sourcemap",
+			Usage: "Sourcemap mode: none, inline, linked or external",
+		},
+		&cli.StringFlag{
+			Name:  "profile",
+			Usage: "Build profile from claudemd.config.json to apply, such as dev, staging or prod",
+		},
+	}
+}
+
+// resolveBuildConfig merges claudemd.config.json, the selected profile and
+// command line flags, in that order, and makes the result the active build
+// configuration
+func resolveBuildConfig(c *cli.Context) error {
+	config := workspace.Build
+	// Copy the defines so flags do not leak into the workspace config
+	config.Define = copyStringMap(config.Define)
+
+	if c.IsSet("profile") {
+		config.Profile = c.String("profile")
+	}
+	if err := config.applyProfile(config.Profile); err != nil {
+		return err
+	}
+	if c.IsSet("target") {
+		config.Target = c.String("target")
+	}
+	if c.IsSet("minify") {
+		minify := c.Bool("minify")
+		config.Minify = &minify
+	}
+	if c.IsSet("drop-console") {
+		config.DropConsole = c.Bool("drop-console")
+	}
+	if c.IsSet("legal-comments") {
+		config.LegalComments = c.String("legal-comments")
+	}
+	if c.IsSet("sourcemap") {
+		config.Sourcemap = c.String("sourcemap")
+	}
+	for _, define := range c.StringSlice("define") {
+		key, value, ok := strings.Cut(define, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --define %q, expected KEY=VALUE", define)
+		}
+		config.Define[key] = value
+	}
+
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if err := config.defineRequiredEnv(); err != nil {
+		return err
+	}
+	buildConfigMu.Lock()
+	buildConfig = config
+	buildConfigMu.Unlock()
+	return nil
+}
+
+// applyProfile sets the options of the named profile over b. An empty name
+// applies no profile.
+func (b *BuildConfig) applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := b.Profiles[name]
+	if !ok {
+		if len(b.Profiles) == 0 {
+			return fmt.Errorf("unknown build profile %q: no profiles are configured in %s", name, projectConfigFile)
+		}
+		return fmt.Errorf("unknown build profile %q (valid: %s)", name, strings.Join(sortedKeys(b.Profiles), ", "))
+	}
+	b.Profile = name
+	if profile.Target != "" {
+		b.Target = profile.Target
+	}
+	if profile.Minify != nil {
+		b.Minify = profile.Minify
+	}
+	if profile.DropConsole != nil {
+		b.DropConsole = *profile.DropConsole
+	}
+	if profile.LegalComments != "" {
+		b.LegalComments = profile.LegalComments
+	}
+	if profile.Sourcemap != "" {
+		b.Sourcemap = profile.Sourcemap
+	}
+	if len(profile.Define)+len(profile.Env) > 0 {
+		b.Define = copyStringMap(b.Define)
+	}
+	for key, value := range profile.Define {
+		b.Define[key] = value
+	}
+	for name, value := range profile.Env {
+		quoted, _ := json.Marshal(value)
+		b.Define["process.env."+name] = string(quoted)
+	}
+	b.RequiredEnv = append(append([]string(nil), b.RequiredEnv...), profile.RequiredEnv...)
+	return nil
+}
+
+// defineRequiredEnv defines process.env.NAME for each required variable
+// from the environment. Variables a profile env or --define already set are
+// left as they are. All missing variables are reported at once.
+func (b *BuildConfig) defineRequiredEnv() error {
+	var missing []string
+	for _, name := range b.RequiredEnv {
+		key := "process.env." + name
+		if _, ok := b.Define[key]; ok || slices.Contains(missing, name) {
+			continue
+		}
+		value := os.Getenv(name)
+		if value == "" {
+			missing = append(missing, name)
+			continue
+		}
+		if b.Define == nil {
+			b.Define = map[string]string{}
+		}
+		quoted, _ := json.Marshal(value)
+		b.Define[key] = string(quoted)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "missing required environment variables:\n")
+	for _, name := range missing {
+		fmt.Fprintf(&report, "   • %s\n", name)
+	}
+	profile := "<profile>"
+	if b.Profile != "" {
+		profile = b.Profile
+	}
+	fmt.Fprintf(&report, "Set them in the environment, in build.profiles.%s.env of %s, or with --define process.env.NAME='\"value\"'", profile, projectConfigFile)
+	return fmt.Errorf("%s", report.String())
+}
+
+// validate checks the target, legal comments mode and import map
+func (b BuildConfig) validate() error {
+	if _, ok := buildTargets[strings.ToLower(b.Target)]; !ok {
+		return fmt.Errorf("unknown build target %q (valid: %s)", b.Target, strings.Join(sortedKeys(buildTargets), ", "))
+	}
+	if _, ok := legalCommentModes[b.LegalComments]; !ok {
+		return fmt.Errorf("unknown legal comments mode %q", b.LegalComments)
+	}
+	if _, ok := sourcemapModes[b.Sourcemap]; !ok {
+		return fmt.Errorf("unknown sourcemap mode %q (valid: none, inline, linked, external)", b.Sourcemap)
+	}
+	for name, target := range b.ImportMap {
+		if name == "" || target == "" {
+			return fmt.Errorf("import_map entries need a package name and a URL")
+		}
+	}
+	for _, name := range b.External {
+		if name == "" {
+			return fmt.Errorf("external package names cannot be empty")
+		}
+	}
+	for _, name := range b.RequiredEnv {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("required_env: %q is not a valid environment variable name", name)
+		}
+	}
+	for name, profile := range b.Profiles {
+		if _, ok := buildTargets[strings.ToLower(profile.Target)]; profile.Target != "" && !ok {
+			return fmt.Errorf("build profile %s: unknown build target %q", name, profile.Target)
+		}
+		if _, ok := legalCommentModes[profile.LegalComments]; !ok {
+			return fmt.Errorf("build profile %s: unknown legal comments mode %q", name, profile.LegalComments)
+		}
+		if _, ok := sourcemapModes[profile.Sourcemap]; !ok {
+			return fmt.Errorf("build profile %s: unknown sourcemap mode %q", name, profile.Sourcemap)
+		}
+		for _, env := range profile.RequiredEnv {
+			if !envNamePattern.MatchString(env) {
+				return fmt.Errorf("build profile %s: required_env: %q is not a valid environment variable name", name, env)
+			}
+		}
+	}
+	return nil
+}
+
+// entryPath is the configured app entry point as an esbuild input path
+func (b BuildConfig) entryPath() string {
+	entry := b.Entry
+	if entry == "" {
+		entry = defaultBuildEntry
+	}
+	if filepath.IsAbs(entry) || strings.HasPrefix(entry, ".") {
+		return entry
+	}
+	return "./" + entry
+}
+
+// imports merges the configured import map over the defaults
+func (b BuildConfig) imports() map[string]string {
+	imports := make(map[string]string, len(defaultImportMap)+len(b.ImportMap))
+	for name, target := range defaultImportMap {
+		imports[name] = target
+	}
+	for name, target := range b.ImportMap {
+		imports[name] = target
+	}
+	return imports
+}
+
+// importMap returns the import map of served pages as JSON
+func (b BuildConfig) importMap() string {
+	data, _ := json.MarshalIndent(map[string]interface{}{"imports": b.imports()}, "    ", "    ")
+	return string(data)
+}
+
+// apply sets the configured options on an esbuild invocation. production
+// selects the default minification when none is configured.
+func (b BuildConfig) apply(opts *api.BuildOptions, production bool) {
+	target := strings.ToLower(b.Target)
+	if target == "" {
+		target = defaultBuildTarget
+	}
+	opts.Target = buildTargets[target]
+
+	if b.Minify != nil {
+		opts.MinifyWhitespace = *b.Minify
+		opts.MinifyIdentifiers = *b.Minify
+		opts.MinifySyntax = *b.Minify
+	} else {
+		opts.MinifyWhitespace = production
+	}
+	if b.DropConsole {
+		opts.Drop |= api.DropConsole
+	}
+	opts.Define = b.Define
+	opts.External = append(opts.External, b.External...)
+	opts.LegalComments = legalCommentModes[b.LegalComments]
+	opts.Sourcemap = sourcemapModes[b.Sourcemap]
+	if !production && opts.Sourcemap != api.SourceMapNone {
+		opts.Sourcemap = api.SourceMapInline
+	}
+	opts.TsconfigRaw = tsconfigFor(target)
+}
+
+// tsconfigFor returns the tsconfig passed to esbuild for the given target
+func tsconfigFor(target string) string {
+	lib := strings.ToUpper(target[:2]) + target[2:]
+	if target == "esnext" {
+		lib = "ESNext"
+	}
+	return fmt.Sprintf(`{
+			"compilerOptions": {
+				"jsx": "react-jsx",
+				"allowSyntheticDefaultImports": true,
+				"esModuleInterop": true,
+				"moduleResolution": "node",
+				"target": %[1]q,
+				"lib": [%[1]q, "DOM", "DOM.Iterable"],
+				"allowJs": true,
+				"skipLibCheck": true,
+				"strict": false,
+				"forceConsistentCasingInFileNames": true,
+				"noEmit": true,
+				"incremental": true,
+				"resolveJsonModule": true,
+				"isolatedModules": true
+			}
+		}`, lib)
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// buildCall is an in-progress build that other requests can wait on
+type buildCall struct {
+	done   chan struct{}
+	result api.BuildResult
+	err    error
+}
+
+// buildFlight deduplicates concurrent builds of the same source file.
+// Successful builds are kept in devCache until one of their inputs changes.
+type buildFlight struct {
+	mu    sync.Mutex
+	calls map[string]*buildCall
+}
+
+var moduleBuilds = &buildFlight{calls: make(map[string]*buildCall)}
+
+// buildKey identifies a version of a source file. An edit changes the
+// mtime, so a request made after saving never joins a stale build.
+func buildKey(srcPath string) (string, error) {
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@%d:%d", srcPath, info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// Build builds srcPath as an ES module, sharing the result with any identical
+// build already running or cached. shared reports whether this caller
+// reused another's build rather than building.
+func (f *buildFlight) Build(ctx context.Context, srcPath string) (result api.BuildResult, shared bool, err error) {
+	key, err := buildKey(srcPath)
+	if err != nil {
+		return api.BuildResult{}, false, err
+	}
+	if result, ok := devCache.cachedBuild("module", srcPath); ok {
+		return result, true, nil
+	}
+
+	f.mu.Lock()
+	if call, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		<-call.done
+		return call.result, true, call.err
+	}
+	call := &buildCall{done: make(chan struct{})}
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+
+	sourceCode, err := os.ReadFile(srcPath)
+	if err != nil {
+		call.err = fmt.Errorf("failed to read source file: %w", err)
+		return api.BuildResult{}, false, call.err
+	}
+	call.result = publishBuild(ctx, srcPath, func() api.BuildResult {
+		return buildAsESModule(string(sourceCode), filepath.Dir(srcPath), filepath.Base(srcPath))
+	})
+	devCache.storeBuild("module", srcPath, call.result)
+	return call.result, false, nil
+}
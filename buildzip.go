@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// artifactDir is the directory of imported assets inside a build archive
+const artifactDir = "assets"
+
+// assetLoaders copy files imported by components into the archive, so
+// images and fonts ship with the build
+var assetLoaders = map[string]api.Loader{
+	".png":   api.LoaderFile,
+	".jpg":   api.LoaderFile,
+	".jpeg":  api.LoaderFile,
+	".gif":   api.LoaderFile,
+	".svg":   api.LoaderFile,
+	".webp":  api.LoaderFile,
+	".ico":   api.LoaderFile,
+	".woff":  api.LoaderFile,
+	".woff2": api.LoaderFile,
+	".ttf":   api.LoaderFile,
+}
+
+// buildArtifact is a file of a packaged build
+type buildArtifact struct {
+	Path     string
+	Contents []byte
+}
+
+// buildArtifacts runs a production build in memory. Every file gets a
+// content hashed name so it can be cached forever. Scripts and stylesheets
+// sit beside index.html, because asset URLs in the bundle resolve against
+// the page, and imported assets go in assets/.
+func buildArtifacts(config BuildConfig, entry string) (api.BuildResult, []buildArtifact) {
+	outDir, _ := filepath.Abs("dist")
+	opts := productionOptions(config, entry)
+	opts.Loader = make(map[string]api.Loader, len(sourceLoaders)+len(assetLoaders))
+	for ext, loader := range sourceLoaders {
+		opts.Loader[ext] = loader
+	}
+	for ext, loader := range assetLoaders {
+		opts.Loader[ext] = loader
+	}
+	opts.Outdir = outDir
+	opts.EntryNames = "[name]-[hash]"
+	opts.AssetNames = artifactDir + "/[name]-[hash]"
+	opts.Write = false
+
+	result := api.Build(opts)
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	var artifacts []buildArtifact
+	var script string
+	var stylesheets []string
+	for _, file := range result.OutputFiles {
+		rel, err := filepath.Rel(outDir, file.Path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		artifacts = append(artifacts, buildArtifact{Path: rel, Contents: file.Contents})
+		switch filepath.Ext(rel) {
+		case ".js":
+			script = "./" + rel
+		case ".css":
+			stylesheets = append(stylesheets, "./"+rel)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path < artifacts[j].Path })
+	index := buildArtifact{Path: "index.html", Contents: []byte(generateProductionHTML(config, script, stylesheets...))}
+	return result, append([]buildArtifact{index}, artifacts...)
+}
+
+// writeBuildZip writes the artifacts of a build as a zip archive
+func writeBuildZip(w io.Writer, artifacts []buildArtifact) error {
+	zw := zip.NewWriter(w)
+	modified := time.Now()
+	for _, artifact := range artifacts {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: artifact.Path, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", artifact.Path, err)
+		}
+		if _, err := f.Write(artifact.Contents); err != nil {
+			return fmt.Errorf("failed to add %s: %w", artifact.Path, err)
+		}
+	}
+	return zw.Close()
+}
+
+// buildZipName names a downloaded archive after the project directory
+func buildZipName() string {
+	name := filepath.Base(getCurrentDir())
+	if name == "." || name == "/" || name == "unknown" {
+		name = "claudemd"
+	}
+	return name + "-build.zip"
+}
+
+// handleBuildDownload serves GET /api/build/download?entry=, a production
+// build packaged as a zip that can be unpacked straight onto static hosting.
+// It is admin only: the zip carries the values of the required env vars.
+func handleBuildDownload(w http.ResponseWriter, r *http.Request) {
+	entry := r.URL.Query().Get("entry")
+	if entry == "" {
+		entry = currentBuildConfig().entryPath()
+	}
+	cleanPath := filepath.Clean(entry)
+	if strings.Contains(cleanPath, "..") || filepath.IsAbs(cleanPath) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid entry", nil)
+		return
+	}
+	if _, err := os.Stat(cleanPath); err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Entry not found", map[string]string{"entry": entry})
+		return
+	}
+
+	var artifacts []buildArtifact
+	result := publishBuild(r.Context(), "./"+cleanPath, func() api.BuildResult {
+		var result api.BuildResult
+		result, artifacts = buildArtifacts(currentBuildConfig(), "./"+cleanPath)
+		return result
+	})
+	if len(result.Errors) > 0 {
+		writeJSONError(w, r, http.StatusBadRequest, "Build failed", formatBuildErrors(result.Errors))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", buildZipName()))
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := writeBuildZip(w, artifacts); err != nil {
+		// Headers are already sent, so the client sees a truncated archive
+		log.Printf("[trace=%s] build download failed: %v", traceIDFromContext(r.Context()), err)
+	}
+}
+
+// writeBuildZipFile packages a production build into a zip file
+func writeBuildZipFile(config BuildConfig, entry, path string) (api.BuildResult, error) {
+	result, artifacts := buildArtifacts(config, entry)
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return result, fmt.Errorf("failed to create zip file: %w", err)
+	}
+	defer f.Close()
+	if err := writeBuildZip(f, artifacts); err != nil {
+		return result, err
+	}
+	fmt.Printf("📦 Files in %s:\n", path)
+	for _, artifact := range artifacts {
+		fmt.Printf("   • %s (%s)\n", artifact.Path, formatSize(len(artifact.Contents)))
+	}
+	return result, f.Close()
+}
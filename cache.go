@@ -0,0 +1,434 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/fsnotify/fsnotify"
+)
+
+// CacheConfig sizes the dev server's cache of compiled modules, page shells
+// and Markdown renders
+type CacheConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// MemoryMB caps the in-memory tier, least recently used entries going first
+	MemoryMB int `json:"memory_mb,omitempty"`
+	// Disk keeps entries under .claudemd/cache/content so a restarted server
+	// does not compile everything again
+	Disk bool `json:"disk,omitempty"`
+	// DiskMB caps the disk tier, pruned of its oldest entries at startup
+	DiskMB int `json:"disk_mb,omitempty"`
+}
+
+const (
+	defaultCacheMemoryMB = 64
+	defaultCacheDiskMB   = 256
+	contentCacheDir      = ".claudemd/cache/content"
+)
+
+// cacheDep is a file an entry was built from, as it was when built
+type cacheDep struct {
+	Path    string `json:"path"`
+	ModTime int64  `json:"mod_time"`
+	Size    int64  `json:"size"`
+}
+
+// current reports whether the file is unchanged
+func (d cacheDep) current() bool {
+	info, err := os.Stat(d.Path)
+	return err == nil && info.ModTime().UnixNano() == d.ModTime && info.Size() == d.Size
+}
+
+// statDeps records the current version of each file
+func statDeps(paths []string) ([]cacheDep, bool) {
+	deps := make([]cacheDep, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, false
+		}
+		deps = append(deps, cacheDep{Path: path, ModTime: info.ModTime().UnixNano(), Size: info.Size()})
+	}
+	return deps, true
+}
+
+// cacheEntry is a cached render. Meta holds side data such as the esbuild
+// metafile of a compiled module.
+type cacheEntry struct {
+	Key  string     `json:"key"`
+	Data []byte     `json:"data"`
+	Meta string     `json:"meta,omitempty"`
+	Deps []cacheDep `json:"deps,omitempty"`
+}
+
+func (e *cacheEntry) size() int {
+	return len(e.Key) + len(e.Data) + len(e.Meta)
+}
+
+// CacheStats is reported by /api/metrics
+type CacheStats struct {
+	Entries       int   `json:"entries"`
+	Bytes         int   `json:"bytes"`
+	MaxBytes      int   `json:"max_bytes"`
+	Hits          int64 `json:"hits"`
+	DiskHits      int64 `json:"disk_hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+// contentCache is an LRU of rendered content with an optional disk tier.
+// Entries are dropped when a file they were built from changes: the watcher
+// catches edits as they happen and every read checks the files again, so
+// a page reloaded right after a save never gets the old render.
+type contentCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	lru      *list.List
+	entries  map[string]*list.Element
+	// byDep maps a source file to the keys of the entries built from it
+	byDep   map[string]map[string]bool
+	dir     string
+	watcher *fsnotify.Watcher
+	watched map[string]bool
+	stats   CacheStats
+}
+
+// devCache is the cache of the running server, nil when caching is off
+var devCache *contentCache
+
+// startContentCache creates the cache and watches the files of its entries
+// until the returned function is called
+func startContentCache(config CacheConfig) (func(), error) {
+	if config.Disabled {
+		return func() {}, nil
+	}
+	c := newContentCache(config)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	c.watcher = watcher
+	if c.dir != "" {
+		maxDisk := config.DiskMB
+		if maxDisk <= 0 {
+			maxDisk = defaultCacheDiskMB
+		}
+		pruneCacheDir(c.dir, int64(maxDisk)<<20)
+	}
+	devCache = c
+
+	done := make(chan struct{})
+	go c.watch(done)
+	return func() {
+		close(done)
+		watcher.Close()
+	}, nil
+}
+
+// newContentCache creates a cache that is only checked on reads
+func newContentCache(config CacheConfig) *contentCache {
+	maxMB := config.MemoryMB
+	if maxMB <= 0 {
+		maxMB = defaultCacheMemoryMB
+	}
+	c := &contentCache{
+		maxBytes: maxMB << 20,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		byDep:    make(map[string]map[string]bool),
+		watched:  make(map[string]bool),
+	}
+	c.stats.MaxBytes = c.maxBytes
+	if config.Disk {
+		c.dir = contentCacheDir
+	}
+	return c
+}
+
+// Get returns the entry for key if none of its files changed
+func (c *contentCache) Get(key string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	var entry *cacheEntry
+	if ok {
+		entry = elem.Value.(*cacheEntry)
+	}
+	c.mu.Unlock()
+
+	if entry == nil {
+		if entry = c.readDisk(key); entry != nil {
+			c.mu.Lock()
+			c.stats.DiskHits++
+			c.insert(entry)
+			c.mu.Unlock()
+			return entry, true
+		}
+		c.mu.Lock()
+		c.stats.Misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	for _, dep := range entry.Deps {
+		if !dep.current() {
+			c.mu.Lock()
+			c.stats.Misses++
+			c.invalidate(dep.Path)
+			c.mu.Unlock()
+			return nil, false
+		}
+	}
+	c.mu.Lock()
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	c.mu.Unlock()
+	return entry, true
+}
+
+// Put stores data under key. Entries with deps are dropped once one of
+// those files changes; the files are read now to record their versions.
+func (c *contentCache) Put(key string, data []byte, meta string, deps []string) {
+	c.put(key, data, meta, deps, true)
+}
+
+// PutMemory stores data under key in memory only, for content such as
+// session transcripts that must not be left on disk
+func (c *contentCache) PutMemory(key string, data []byte) {
+	c.put(key, data, "", nil, false)
+}
+
+func (c *contentCache) put(key string, data []byte, meta string, deps []string, disk bool) {
+	if c == nil {
+		return
+	}
+	versions, ok := statDeps(deps)
+	if !ok {
+		return
+	}
+	entry := &cacheEntry{Key: key, Data: data, Meta: meta, Deps: versions}
+	if entry.size() > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	c.insert(entry)
+	c.mu.Unlock()
+	if disk {
+		c.writeDisk(entry)
+	}
+}
+
+// insert adds an entry, evicting the least recently used ones to make
+// room. The caller holds c.mu.
+func (c *contentCache) insert(entry *cacheEntry) {
+	if elem, ok := c.entries[entry.Key]; ok {
+		c.remove(elem)
+	}
+	c.entries[entry.Key] = c.lru.PushFront(entry)
+	c.bytes += entry.size()
+	for _, dep := range entry.Deps {
+		if c.byDep[dep.Path] == nil {
+			c.byDep[dep.Path] = make(map[string]bool)
+		}
+		c.byDep[dep.Path][entry.Key] = true
+		if dir := filepath.Dir(dep.Path); c.watcher != nil && !c.watched[dir] {
+			// Directories rather than files, since editors often save by
+			// replacing the file
+			if err := c.watcher.Add(dir); err == nil {
+				c.watched[dir] = true
+			}
+		}
+	}
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an entry from memory. The caller holds c.mu.
+func (c *contentCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.Key)
+	c.bytes -= entry.size()
+	for _, dep := range entry.Deps {
+		delete(c.byDep[dep.Path], entry.Key)
+		if len(c.byDep[dep.Path]) == 0 {
+			delete(c.byDep, dep.Path)
+		}
+	}
+}
+
+// invalidate drops every entry built from path, from memory and disk. The
+// caller holds c.mu.
+func (c *contentCache) invalidate(path string) {
+	for key := range c.byDep[path] {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+		if c.dir != "" {
+			os.Remove(c.diskPath(key))
+		}
+		c.stats.Invalidations++
+	}
+}
+
+// watch invalidates entries as their files change
+func (c *contentCache) watch(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			c.mu.Lock()
+			c.invalidate(filepath.Clean(event.Name))
+			c.mu.Unlock()
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Cache watcher error: %v", err)
+		}
+	}
+}
+
+// Stats returns the counters and current size of the cache
+func (c *contentCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries, s.Bytes = len(c.entries), c.bytes
+	return s
+}
+
+// diskPath is where an entry is stored in the disk tier
+func (c *contentCache) diskPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readDisk loads an entry from the disk tier, discarding it when a file it
+// was built from changed while the server was not watching
+func (c *contentCache) readDisk(key string) *cacheEntry {
+	if c.dir == "" {
+		return nil
+	}
+	path := c.diskPath(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil || entry.Key != key {
+		os.Remove(path)
+		return nil
+	}
+	for _, dep := range entry.Deps {
+		if !dep.current() {
+			os.Remove(path)
+			return nil
+		}
+	}
+	return &entry
+}
+
+// writeDisk stores an entry in the disk tier
+func (c *contentCache) writeDisk(entry *cacheEntry) {
+	if c.dir == "" {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Printf("Failed to create cache directory: %v", err)
+		return
+	}
+	// Write to a temp file first so readers never see a partial entry
+	path := c.diskPath(entry.Key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("Failed to write cache entry: %v", err)
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// pruneCacheDir removes the oldest entries of the disk tier until it fits
+// in maxBytes
+func pruneCacheDir(dir string, maxBytes int64) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return
+	}
+	type file struct {
+		path string
+		info os.FileInfo
+	}
+	var files []file
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			files = append(files, file{path, info})
+			total += info.Size()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ModTime().Before(files[j].info.ModTime()) })
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.info.Size()
+		}
+	}
+}
+
+// buildFingerprint identifies the build options and import map renders
+// depend on, so changing the config never serves output of the old one
+func buildFingerprint() string {
+	data, _ := json.Marshal(currentBuildConfig())
+	sum := sha256.Sum256(append(data, pageImportMap()...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cachedBuild returns a cached build of srcPath made by kind of build
+func (c *contentCache) cachedBuild(kind, srcPath string) (api.BuildResult, bool) {
+	entry, ok := c.Get(kind + ":" + srcPath + "@" + buildFingerprint())
+	if !ok {
+		return api.BuildResult{}, false
+	}
+	return api.BuildResult{
+		OutputFiles: []api.OutputFile{{Contents: entry.Data}},
+		Metafile:    entry.Meta,
+	}, true
+}
+
+// storeBuild caches a successful build of srcPath, to be dropped when any
+// file in its metafile changes
+func (c *contentCache) storeBuild(kind, srcPath string, result api.BuildResult) {
+	if c == nil || len(result.Errors) > 0 || len(result.OutputFiles) == 0 || result.Metafile == "" {
+		return
+	}
+	c.Put(kind+":"+srcPath+"@"+buildFingerprint(), result.OutputFiles[0].Contents, result.Metafile, buildInputs(srcPath, result.Metafile))
+}
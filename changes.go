@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// changesSettle holds back sessions updated in the last moments, whose
+	// transactions may commit after one with a later timestamp; without it
+	// a poller could move its cursor past a change it never saw
+	changesSettle = 2 * time.Second
+	// changesPollInterval is how often a waiting request checks for changes
+	changesPollInterval = time.Second
+	// changesMaxWait bounds how long a request may wait for a change
+	changesMaxWait = 60 * time.Second
+)
+
+// SessionChange is a session created, updated or deleted after a cursor.
+// Purged sessions are gone from the database and are never reported.
+type SessionChange struct {
+	SessionSummary
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// SessionChanges is the response of GET /api/sessions/changes. Cursor is
+// passed as since on the next poll and stays the same when nothing changed;
+// HasMore asks the client to fetch again right away.
+type SessionChanges struct {
+	Changes []SessionChange `json:"changes"`
+	Cursor  string          `json:"cursor"`
+	HasMore bool            `json:"has_more"`
+}
+
+// listSessionChanges returns sessions changed after the cursor in update
+// order, oldest first
+func listSessionChanges(db *sql.DB, filter SessionFilter, after *sessionCursor, limit int) ([]SessionChange, error) {
+	filter.IncludeDeleted = true
+	where, args := filter.where()
+	if after != nil {
+		args = append(args, after.At, after.SessionID)
+		where += fmt.Sprintf(" AND (updated_at, session_id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, changesSettle.Seconds(), limit)
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT session_id, title, COALESCE(metadata->>'source_file', ''), workspace, jsonb_array_length(messages),
+		       created_at, updated_at, deleted_at IS NOT NULL
+		FROM claude_sessions
+		WHERE %s AND updated_at < NOW() - make_interval(secs => $%d)
+		ORDER BY updated_at, session_id
+		LIMIT $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []SessionChange{}
+	for rows.Next() {
+		var c SessionChange
+		var sourceFile string
+		if err := rows.Scan(&c.SessionID, &c.Title, &sourceFile, &c.Workspace, &c.Messages, &c.CreatedAt, &c.UpdatedAt, &c.Deleted); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		if sourceFile != "" {
+			c.Project = filepath.Base(filepath.Dir(sourceFile))
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// handleSessionChanges serves GET /api/sessions/changes?since=&limit=&wait=
+// with the filters of sessionFilterFromQuery. Without since every session is
+// returned, oldest change first. With wait (in seconds) the request is held
+// until something changes or the time is up, so clients can long-poll.
+func (a *apiServer) handleSessionChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := sessionFilterFromQuery(q)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	page, err := pageFromQuery(q, 100, 1000)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	page.Cursor = q.Get("since")
+	var cursor sessionCursor
+	var after *sessionCursor
+	if ok, err := page.decode(&cursor.At, &cursor.SessionID); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "invalid since cursor", nil)
+		return
+	} else if ok {
+		after = &cursor
+	}
+	var wait time.Duration
+	if v := q.Get("wait"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > changesMaxWait {
+			writeJSONError(w, r, http.StatusBadRequest, fmt.Sprintf("wait must be between 0 and %d seconds", int(changesMaxWait.Seconds())), nil)
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		changes, err := listSessionChanges(a.db, filter, after, page.Limit+1)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		if len(changes) > 0 || !time.Now().Add(changesPollInterval).Before(deadline) {
+			result := SessionChanges{Changes: changes, Cursor: page.Cursor}
+			if len(changes) > page.Limit {
+				result.Changes, result.HasMore = changes[:page.Limit], true
+			}
+			if n := len(result.Changes); n > 0 {
+				result.Cursor = encodeCursor(result.Changes[n-1].UpdatedAt, result.Changes[n-1].SessionID)
+			}
+			writeJSON(w, http.StatusOK, result)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(changesPollInterval):
+		}
+	}
+}
@@ -2,29 +2,113 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SessionMessage struct {
-	Type      string                 `json:"type"`
-	Summary   string                 `json:"summary,omitempty"`
-	LeafUUID  string                 `json:"leafUuid,omitempty"`
-	Message   map[string]interface{} `json:"message,omitempty"`
-	Content   string                 `json:"content,omitempty"`   // Extracted content for easy access
-	UUID      string                 `json:"uuid,omitempty"`
-	Timestamp string                 `json:"timestamp,omitempty"`
+	Type      string          `json:"type"`
+	Summary   string          `json:"summary,omitempty"`
+	LeafUUID  string          `json:"leafUuid,omitempty"`
+	Message   json.RawMessage `json:"message,omitempty"`
+	Content   string          `json:"content,omitempty"` // Extracted content for easy access
+	UUID      string          `json:"uuid,omitempty"`
+	Timestamp string          `json:"timestamp,omitempty"`
+	Cwd       string          `json:"cwd,omitempty"`
+	// Thinking is the text of extended thinking blocks, kept apart from Content
+	Thinking     string `json:"thinking,omitempty"`
+	ThinkingHash string `json:"thinking_sha256,omitempty"`
+	// Truncated is set when Content keeps only the start and end of a long
+	// tool result; GET /api/messages/{uuid}/full returns all of it
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ContentBlock is a typed view of a single block in a message's content array
+type ContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+}
+
+// messageEnvelope is the subset of the nested message object needed for extraction
+type messageEnvelope struct {
+	Role    string          `json:"role"`
+	Model   string          `json:"model,omitempty"`
+	Content json.RawMessage `json:"content"`
+}
+
+// toolInputSummary holds the tool input fields used to describe a tool call
+type toolInputSummary struct {
+	Description string `json:"description"`
+	Prompt      string `json:"prompt"`
+}
+
+// envelope decodes the nested message object without building a generic map
+func (msg SessionMessage) envelope() messageEnvelope {
+	var env messageEnvelope
+	if len(msg.Message) > 0 {
+		json.Unmarshal(msg.Message, &env)
+	}
+	return env
+}
+
+// messageRole returns the author role of a message, falling back to its type
+func messageRole(msg SessionMessage) string {
+	if role := msg.envelope().Role; role != "" {
+		return role
+	}
+	return msg.Type
+}
+
+// messageBlocks returns the content blocks of a message. Plain string content
+// is returned as a single text block.
+func messageBlocks(msg SessionMessage) []ContentBlock {
+	return decodeContentBlocks(msg.envelope().Content)
+}
+
+// decodeContentBlocks decodes message content that is either a string or an array of blocks
+func decodeContentBlocks(content json.RawMessage) []ContentBlock {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil
+	}
+	if content[0] == '"' {
+		var text string
+		if err := json.Unmarshal(content, &text); err != nil {
+			return nil
+		}
+		return []ContentBlock{{Type: "text", Text: text}}
+	}
+
+	var blocks []ContentBlock
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return nil
+	}
+	return blocks
 }
 
 // ClaudeSession represents a Claude Code session stored in PostgreSQL
@@ -37,35 +121,143 @@ type ClaudeSession struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 	CreatedAt time.Time              `json:"created_at"`
 	UpdatedAt time.Time              `json:"updated_at"`
+	// SessionTimes are derived from the message timestamps when stored
+	SessionTimes
+	// Workspace groups the session with those of a team. Syncing with no
+	// workspace selected leaves it empty, which keeps the stored one.
+	Workspace string `json:"workspace,omitempty"`
+	// ContentHash is set by sync to the hash of what it writes
+	ContentHash string `json:"-"`
+	// Previous and Next link resumed sessions into a workstream. They are
+	// only filled in by the session API.
+	Previous []SessionLink `json:"previous,omitempty"`
+	Next     []SessionLink `json:"next,omitempty"`
+	// Outcome is the user's verdict on the session, also only filled in
+	// by the session API
+	Outcome *SessionOutcome `json:"outcome,omitempty"`
 }
 
 type ClaudeSessionSync struct {
 	db          *sql.DB
 	claudeDir   string
-	syncedFiles map[string]time.Time
+	syncedFiles map[string]*fileSyncState
+	// retries receives files whose last line was still being written
+	retries chan string
+	// snapshotRaw stores a compressed copy of each raw JSONL file so sessions
+	// can be restored even if ~/.claude is wiped
+	snapshotRaw bool
+	// snapshotContext stores the project's CLAUDE.md and other contextFiles
+	// as they were when each session started
+	snapshotContext bool
+	contextFiles    []string
+	// settingsMu guards the settings below, which a config reload replaces
+	// while sync runs
+	settingsMu sync.RWMutex
+	// reloaded is signalled when the settings change, so newly included
+	// projects are watched and rescanned
+	reloaded chan struct{}
+	// filter skips projects or session files matching ignore patterns
+	filter *PathFilter
+	// redactor masks sensitive content before it is written to the database
+	redactor *Redactor
+	// titler picks the title of each session
+	titler *Titler
+	// plugins post-process sessions after redaction
+	plugins pluginChain
+	// blobs moves oversized tool results out of the messages column
+	blobs *blobOffloader
+	// attachments moves pasted and tool result images to the blob store
+	attachments *attachmentExtractor
+	// thinking is the storage mode for extended thinking blocks
+	thinking string
+	// conflicts settles fields edited both in the database and in the file
+	conflicts ConflictConfig
+	// sourceDeletes is what happens to sessions whose file is removed
+	sourceDeletes string
+	// sink receives synced sessions; it writes to db unless Supabase REST mode is used
+	sink sessionSink
+	// interval schedules full reconciliation syncs on top of watcher
+	// events; zero disables them
+	interval time.Duration
+	// extraSources are the ~/.claude directories synced next to the
+	// sessions, and sourceFiles tracks their files like syncedFiles
+	extraSources []string
+	sourceFiles  map[string]*fileSyncState
+	// workspace is where synced sessions go; empty keeps each session's
+	// stored workspace, new ones landing in the default
+	workspace string
+	// mirrors copy what is written to sink to the configured extra sinks
+	mirrors mirrorSet
+}
+
+// sessionSink is where synced sessions and the data derived from them are written
+type sessionSink interface {
+	UpsertSession(session ClaudeSession) error
+	StoreTodos(sessionID string, todos []SessionTodo) error
+	StoreFileManifest(sessionID string, files []SessionFile) error
+	MarkSourceRemoved(sessionID, sourceFile string, softDelete bool) (bool, error)
+	StoreSourceFile(file SourceFile) error
+	DeleteSourceFile(source, name string) error
+}
+
+// postgresSink writes sessions straight to the database
+type postgresSink struct {
+	db *sql.DB
 }
 
 func NewClaudeSessionSync(db *sql.DB) *ClaudeSessionSync {
-	homeDir, err := os.UserHomeDir()
+	claudeDir, err := defaultClaudeDir()
 	if err != nil {
 		log.Fatalf("Failed to get home directory: %v", err)
 	}
 
+	titler, _ := NewTitler(TitleConfig{}, db)
 	return &ClaudeSessionSync{
 		db:          db,
-		claudeDir:   filepath.Join(homeDir, ".claude"),
-		syncedFiles: make(map[string]time.Time),
+		claudeDir:   claudeDir,
+		syncedFiles: make(map[string]*fileSyncState),
+		sourceFiles: make(map[string]*fileSyncState),
+		reloaded:    make(chan struct{}, 1),
+		titler:      titler,
+		sink:        storageDriverOf(db).Sink(db),
+	}
+}
+
+// defaultClaudeDir returns the ~/.claude directory of the current user
+func defaultClaudeDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".claude"), nil
+}
+
+// findSessionFile locates the JSONL file for a session ID under the projects directory
+func findSessionFile(claudeDir, sessionID string) (string, error) {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || strings.Contains(sessionID, "..") {
+		return "", fmt.Errorf("invalid session id %q", sessionID)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(claudeDir, "projects", "*", sessionID+".jsonl"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", os.ErrNotExist
 	}
+	return matches[0], nil
 }
 
 func (c *ClaudeSessionSync) Start() error {
+	c.retries = make(chan string, 64)
+
 	// Initial sync of existing files
 	if err := c.syncExistingFiles(); err != nil {
 		return fmt.Errorf("failed to sync existing files: %w", err)
 	}
 
-	// Set up file watcher
-	watcher, err := fsnotify.NewWatcher()
+	// Set up file watcher; the buffer absorbs bursts of events
+	watcher, err := fsnotify.NewBufferedWatcher(1024)
 	if err != nil {
 		return fmt.Errorf("failed to create watcher: %w", err)
 	}
@@ -78,19 +270,34 @@ func (c *ClaudeSessionSync) Start() error {
 		return fmt.Errorf("failed to read projects directory: %w", err)
 	}
 
-	for _, dir := range dirs {
-		if dir.IsDir() {
-			dirPath := filepath.Join(projectsDir, dir.Name())
-			if err := watcher.Add(dirPath); err != nil {
-				log.Printf("Failed to watch directory %s: %v", dirPath, err)
-			}
-		}
-	}
+	c.watchProjects(watcher, dirs)
 
 	// Also watch the projects directory itself for new projects
 	if err := watcher.Add(projectsDir); err != nil {
 		return fmt.Errorf("failed to watch projects directory: %w", err)
 	}
+	c.watchExtraSources(watcher)
+
+	// The watcher only queues changed files; a separate goroutine writes
+	// them to the database so slow writes never back up fsnotify
+	queue := newSyncQueue()
+	consumed := make(chan struct{})
+	go func() {
+		c.consume(queue)
+		close(consumed)
+	}()
+	defer func() {
+		queue.Close()
+		<-consumed
+	}()
+
+	// Scheduled syncs catch changes fsnotify never reports, as on network
+	// filesystems. They go through the rescan signal, so one never starts
+	// while another is running or pending.
+	var reconcile <-chan time.Time
+	if c.interval > 0 {
+		reconcile = time.After(jitteredInterval(c.interval))
+	}
 
 	log.Println("Claude session sync started, watching for changes...")
 
@@ -102,41 +309,100 @@ func (c *ClaudeSessionSync) Start() error {
 				return nil
 			}
 
+			if c.extraSource(event.Name) != "" {
+				queue.Enqueue(event.Name)
+				continue
+			}
 			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
 				if strings.HasSuffix(event.Name, ".jsonl") {
-					log.Printf("File changed: %s", event.Name)
-					if err := c.syncFile(event.Name); err != nil {
-						log.Printf("Failed to sync file %s: %v", event.Name, err)
+					if !c.pathFilter().AllowFile(c.relativePath(event.Name)) {
+						continue
 					}
+					log.Printf("File changed: %s", event.Name)
+					queue.Enqueue(event.Name)
 				} else if event.Op&fsnotify.Create == fsnotify.Create {
 					// Check if it's a new directory
 					info, err := os.Stat(event.Name)
-					if err == nil && info.IsDir() {
-						if err := watcher.Add(event.Name); err != nil {
-							log.Printf("Failed to watch new directory %s: %v", event.Name, err)
-						}
+					rel := c.relativePath(event.Name)
+					if err == nil && info.IsDir() && watchDepth(rel) <= maxWatchDepth && c.pathFilter().AllowDir(rel) {
+						// Directories inside it may have been created before the
+						// watch was added, so the whole tree is registered
+						c.watchTree(watcher, event.Name)
+						// Files may have been written before the watch was added
+						queue.Rescan()
+					}
+				}
+			} else if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				if strings.HasSuffix(event.Name, ".jsonl") {
+					if c.pathFilter().AllowFile(c.relativePath(event.Name)) {
+						log.Printf("File removed: %s", event.Name)
+						queue.Enqueue(event.Name)
 					}
+				} else if filepath.Dir(event.Name) == projectsDir || slices.Contains(watcher.WatchList(), event.Name) {
+					// A renamed directory keeps its watches under the old names,
+					// so drop them. The new name arrives as a Create, and a
+					// rescan settles the sessions that moved or went away.
+					unwatchTree(watcher, event.Name)
+					queue.Rescan()
 				}
 			}
 
+		case path := <-c.retries:
+			queue.Enqueue(path)
+
+		case <-reconcile:
+			log.Println("Running scheduled reconciliation sync")
+			queue.Rescan()
+			reconcile = time.After(jitteredInterval(c.interval))
+
+		case <-c.reloaded:
+			// Projects the new patterns include were never watched or synced
+			if dirs, err := os.ReadDir(projectsDir); err == nil {
+				c.watchProjects(watcher, dirs)
+			}
+			queue.Rescan()
+
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return nil
 			}
 			log.Printf("Watcher error: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// The kernel dropped events, so look for the changes they described
+				stats.RecordQueueEvent(true)
+				queue.Rescan()
+			}
+		}
+	}
+}
+
+// watchProjects watches the project directories allowed by the filter and
+// the directories nested in them
+func (c *ClaudeSessionSync) watchProjects(watcher *fsnotify.Watcher, dirs []os.DirEntry) {
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			c.watchTree(watcher, filepath.Join(c.claudeDir, "projects", dir.Name()))
 		}
 	}
 }
 
 func (c *ClaudeSessionSync) syncExistingFiles() error {
 	projectsDir := filepath.Join(c.claudeDir, "projects")
+	filter := c.pathFilter()
 
-	return filepath.Walk(projectsDir, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(projectsDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if !info.IsDir() && strings.HasSuffix(path, ".jsonl") {
+		if info.IsDir() {
+			if !filter.AllowDir(c.relativePath(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasSuffix(path, ".jsonl") && filter.AllowFile(c.relativePath(path)) {
 			if err := c.syncFile(path); err != nil {
 				log.Printf("Failed to sync file %s: %v", path, err)
 			}
@@ -144,164 +410,539 @@ func (c *ClaudeSessionSync) syncExistingFiles() error {
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	// Files synced earlier that are gone were removed while no event arrived
+	for path := range c.syncedFiles {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := c.syncRemovedFile(path); err != nil {
+				log.Printf("Failed to sync removed file %s: %v", path, err)
+			}
+		}
+	}
+	c.syncExtraSources()
+	return nil
 }
 
-// extractMessageContent extracts readable content from complex message structures
+// relativePath returns a path relative to the projects directory
+func (c *ClaudeSessionSync) relativePath(path string) string {
+	rel, err := filepath.Rel(filepath.Join(c.claudeDir, "projects"), path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// extractMessageContent extracts readable content from complex message structures.
+// It walks the raw message with jsonScanner so only the strings that end up
+// in the summary are ever decoded.
 func extractMessageContent(msg SessionMessage) string {
 	// If summary exists (for summary type), use it
 	if msg.Summary != "" {
 		return msg.Summary
 	}
-	
+
 	// If no message data, return empty
-	if msg.Message == nil {
+	if len(msg.Message) == 0 {
 		return ""
 	}
-	
-	// Extract content from message field
-	if content, ok := msg.Message["content"]; ok {
-		switch c := content.(type) {
-		case string:
+
+	s := jsonScanner{data: msg.Message}
+	content, _ := scanMessageContent(&s, &contentScan{})
+	return content
+}
+
+// scanMessageContent consumes a message value and summarizes its content field
+func scanMessageContent(s *jsonScanner, cs *contentScan) (string, error) {
+	if s.peek() != '{' {
+		return "", s.skipValue()
+	}
+	s.pos++
+
+	var content string
+	for first := true; ; first = false {
+		key, more, err := s.nextKey(first)
+		if err != nil || !more {
+			return content, err
+		}
+		if string(key) != "content" {
+			if err := s.skipValue(); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		switch s.peek() {
+		case '"':
 			// User messages have content as string
-			return c
-		case []interface{}:
+			content, err = s.stringValue()
+		case '[':
 			// Assistant messages have content as array of content blocks
-			var textParts []string
-			for _, item := range c {
-				if block, ok := item.(map[string]interface{}); ok {
-					if blockType, ok := block["type"].(string); ok {
-						switch blockType {
-						case "text":
-							if text, ok := block["text"].(string); ok {
-								textParts = append(textParts, text)
-							}
-						case "tool_use":
-							// Extract tool name and input for tool_use messages
-							toolName := "unknown tool"
-							if name, ok := block["name"].(string); ok {
-								toolName = name
-							}
-							var inputDesc string
-							if input, ok := block["input"].(map[string]interface{}); ok {
-								// Try to extract meaningful description from input
-								if desc, ok := input["description"].(string); ok {
-									inputDesc = desc
-								} else if prompt, ok := input["prompt"].(string); ok {
-									inputDesc = prompt
-								} else {
-									inputDesc = "with parameters"
-								}
-							}
-							textParts = append(textParts, fmt.Sprintf("Used %s %s", toolName, inputDesc))
-						case "tool_result":
-							// Extract tool result content
-							if result, ok := block["content"].(string); ok {
-								// Truncate very long results
-								if len(result) > 200 {
-									result = result[:200] + "..."
-								}
-								textParts = append(textParts, fmt.Sprintf("Tool result: %s", result))
-							} else if _, ok := block["content"].([]interface{}); ok {
-								// Handle structured results
-								textParts = append(textParts, "Tool result received")
-							}
-						}
-					}
-				}
+			content, err = extractBlocksContent(s, cs)
+		default:
+			err = s.skipValue()
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// extractBlocksContent consumes an array of content blocks and summarizes them
+func extractBlocksContent(s *jsonScanner, cs *contentScan) (string, error) {
+	var sb strings.Builder
+	if err := s.beginArray(); err != nil {
+		return "", err
+	}
+	for first := true; ; first = false {
+		more, err := s.nextElem(first)
+		if err != nil {
+			return "", err
+		}
+		if !more {
+			break
+		}
+		if s.peek() != '{' {
+			if err := s.skipValue(); err != nil {
+				return "", err
 			}
-			return strings.Join(textParts, " ")
+			continue
+		}
+
+		part, ok, err := extractBlockContent(s, cs)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
 		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(part)
 	}
-	
-	return ""
+	return sb.String(), nil
 }
 
-func (c *ClaudeSessionSync) syncFile(filePath string) error {
-	// Check if file was recently synced
-	if lastSync, ok := c.syncedFiles[filePath]; ok {
-		info, err := os.Stat(filePath)
+// extractBlockContent summarizes a single content block object
+func extractBlockContent(s *jsonScanner, cs *contentScan) (string, bool, error) {
+	var blockType, name string
+	var text, input, content []byte
+	textEscaped := false
+
+	s.beginObject()
+	for first := true; ; first = false {
+		key, more, err := s.nextKey(first)
 		if err != nil {
-			return err
+			return "", false, err
+		}
+		if !more {
+			break
+		}
+		switch string(key) {
+		case "type":
+			blockType, err = s.stringValue()
+		case "name":
+			name, err = s.stringValue()
+		case "text":
+			if s.peek() == '"' {
+				text, textEscaped, err = s.stringSpan()
+			} else {
+				err = s.skipValue()
+			}
+		case "input":
+			input, err = s.valueSpan()
+		case "content":
+			content, err = s.valueSpan()
+		default:
+			err = s.skipValue()
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	switch blockType {
+	case "text":
+		return decodeJSONString(text, textEscaped, -1), true, nil
+	case "tool_use":
+		// Extract tool name and input for tool_use messages
+		toolName := "unknown tool"
+		if name != "" {
+			toolName = name
+		}
+		return "Used " + toolName + " " + describeToolInput(input), true, nil
+	case "tool_result":
+		// Extract tool result content
+		if len(content) > 0 && content[0] == '"' {
+			rs := jsonScanner{data: content}
+			raw, escaped, err := rs.stringSpan()
+			if err != nil {
+				return "", false, nil
+			}
+			return "Tool result: " + cs.preview(decodeJSONString(raw, escaped, -1)), true, nil
+		} else if len(content) > 0 && content[0] == '[' {
+			// Structured results are summarized by their text blocks
+			return "Tool result: " + cs.preview(toolResultText(content)), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// describeToolInput picks a meaningful description from a tool_use input object
+func describeToolInput(input []byte) string {
+	if len(input) == 0 || input[0] != '{' {
+		return ""
+	}
+
+	var description, prompt string
+	s := jsonScanner{data: input}
+	s.beginObject()
+	for first := true; ; first = false {
+		key, more, err := s.nextKey(first)
+		if err != nil || !more {
+			break
+		}
+		switch string(key) {
+		case "description":
+			description, err = s.stringValue()
+		case "prompt":
+			prompt, err = s.stringValue()
+		default:
+			err = s.skipValue()
 		}
-		if !info.ModTime().After(lastSync) {
-			return nil // File hasn't changed since last sync
+		if err != nil {
+			break
 		}
 	}
 
+	if description != "" {
+		return description
+	} else if prompt != "" {
+		return prompt
+	}
+	return "with parameters"
+}
+
+// parseSessionLine decodes a single JSONL line and extracts its readable content.
+// The top-level object is scanned in one pass; the nested message is kept raw.
+func parseSessionLine(line []byte) (SessionMessage, error) {
+	var msg SessionMessage
+	s := jsonScanner{data: line}
+	if err := s.beginObject(); err != nil {
+		return msg, err
+	}
+	for first := true; ; first = false {
+		key, more, err := s.nextKey(first)
+		if err != nil {
+			return msg, err
+		}
+		if !more {
+			break
+		}
+		switch string(key) {
+		case "type":
+			msg.Type, err = s.stringValue()
+		case "summary":
+			msg.Summary, err = s.stringValue()
+		case "leafUuid":
+			msg.LeafUUID, err = s.stringValue()
+		case "uuid":
+			msg.UUID, err = s.stringValue()
+		case "timestamp":
+			msg.Timestamp, err = s.stringValue()
+		case "cwd":
+			msg.Cwd, err = s.stringValue()
+		case "message":
+			// Summarize the content while scanning past the message once
+			s.ws()
+			start := s.pos
+			var cs contentScan
+			if msg.Content, err = scanMessageContent(&s, &cs); err == nil {
+				msg.Message = append(json.RawMessage(nil), line[start:s.pos]...)
+				msg.Truncated = cs.truncated
+			}
+		default:
+			err = s.skipValue()
+		}
+		if err != nil {
+			return msg, err
+		}
+	}
+	if !s.end() {
+		return msg, errJSONSyntax
+	}
+
+	// Summary lines use their summary as content
+	if msg.Summary != "" {
+		msg.Content = msg.Summary
+	}
+	msg.Thinking = extractThinking(msg)
+	return msg, nil
+}
+
+func (c *ClaudeSessionSync) syncFile(filePath string) (err error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Check if file changed since it was last synced
+	state := c.syncedFiles[filePath]
+	if state.unchanged(info.ModTime(), info.Size()) {
+		if state.partial {
+			c.scheduleRetry(filePath, state)
+		}
+		return nil
+	}
+
 	// Extract session ID from filename
 	baseName := filepath.Base(filePath)
 	sessionID := strings.TrimSuffix(baseName, ".jsonl")
 
+	ctx, span := tracer.Start(context.Background(), "syncFile", trace.WithAttributes(
+		attribute.String("claudemd.session_id", sessionID),
+		attribute.String("claudemd.file", filePath),
+	))
+	defer func() { endSpan(span, err) }()
+
 	// Read the file
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	var messages []SessionMessage
-	var title string
+	_, parseSpan := tracer.Start(ctx, "parseSession", trace.WithAttributes(attribute.Int("claudemd.bytes", len(data))))
+	messages, lineCount, complete, partial, err := parseSessionData(filePath, data)
+	parseSpan.SetAttributes(attribute.Int("claudemd.lines", lineCount), attribute.Int("claudemd.messages", len(messages)))
+	endSpan(parseSpan, err)
+	if err != nil {
+		return err
+	}
+
+	// Create or update the session in PostgreSQL
+	session := ClaudeSession{
+		SessionID: sessionID,
+		Messages:  messages,
+		Metadata: map[string]interface{}{
+			"source_file": filePath,
+			"last_synced": time.Now().Format(time.RFC3339),
+			"line_count":  lineCount,
+		},
+	}
+	if err := c.saveSession(ctx, &session, filePath); err != nil {
+		return err
+	}
+
+	if c.snapshotRaw {
+		_, snapshotSpan := tracer.Start(ctx, "storeRawSnapshot")
+		err := c.storeRawSnapshot(sessionID, filePath, c.settings().redactor.RedactRaw(complete))
+		endSpan(snapshotSpan, err)
+		if err != nil {
+			log.Printf("Failed to store raw snapshot for %s: %v", sessionID, err)
+		}
+	}
+
+	// Remember how far the file was read so a partial last line is picked up later
+	next := &fileSyncState{
+		modTime: info.ModTime(),
+		size:    int64(len(data)),
+		offset:  int64(len(complete)),
+		partial: len(partial) > 0,
+	}
+	if next.partial {
+		if state != nil && state.partial && state.offset == next.offset {
+			next.attempts = state.attempts
+		}
+		log.Printf("Last line of %s is incomplete at offset %d, will retry", filePath, next.offset)
+		c.scheduleRetry(filePath, next)
+	}
+	c.syncedFiles[filePath] = next
+
+	log.Printf("Synced session %s with %d messages", sessionID, len(messages))
+	return nil
+}
+
+// parseSessionData parses the complete lines of a session file. Claude Code
+// may still be writing the last line, so an unterminated line is only
+// included if it parses on its own; otherwise it is returned as partial.
+func parseSessionData(filePath string, data []byte) (messages []SessionMessage, lineCount int, complete, partial []byte, err error) {
+	complete, partial = splitCompleteLines(data)
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(complete))
 	// Increase buffer size to handle large JSON lines (10MB max)
 	const maxTokenSize = 10 * 1024 * 1024 // 10MB
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, maxTokenSize)
-	
-	lineCount := 0
+
 	for scanner.Scan() {
 		lineCount++
-		var msg SessionMessage
-		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+		msg, err := parseSessionLine(scanner.Bytes())
+		if err != nil {
 			log.Printf("Failed to parse line %d in %s: %v", lineCount, filePath, err)
 			continue
 		}
-		
-		// Extract content for easy access
-		msg.Content = extractMessageContent(msg)
-		
 		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
 
-		// Use the first summary as the title
-		if title == "" && msg.Type == "summary" && msg.Summary != "" {
-			title = msg.Summary
+	if len(bytes.TrimSpace(partial)) > 0 {
+		if msg, err := parseSessionLine(partial); err == nil {
+			lineCount++
+			messages = append(messages, msg)
+			complete, partial = data, nil
 		}
 	}
+	return messages, lineCount, complete, partial, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// saveSession enriches, redacts and titles a parsed session, then stores it
+// along with the data derived from it. Each step is traced in a span of ctx.
+func (c *ClaudeSessionSync) saveSession(ctx context.Context, session *ClaudeSession, filePath string) error {
+	sessionID := session.SessionID
+	settings := c.settings()
+
+	_, span := tracer.Start(ctx, "prepareSession")
+	err := c.prepareSession(session, filePath, settings)
+	endSpan(span, err)
+	if err != nil {
+		return err
 	}
 
-	// If no title found, use a default
-	if title == "" {
-		title = fmt.Sprintf("Session %s", sessionID)
+	var stored *storedSession
+	if reader, ok := c.sink.(sessionReader); ok {
+		_, span := tracer.Start(ctx, "storedSession", trace.WithAttributes(sinkAttribute(c.sink)))
+		stored, err = reader.StoredSession(sessionID)
+		endSpan(span, err)
+		if err != nil {
+			stats.RecordSync(sessionID, 0, err)
+			publishSync(sessionID, 0, err)
+			return err
+		}
+		for _, conflict := range resolveConflicts(settings.conflicts, stored, session) {
+			log.Printf("Sync conflict on %s of %s: kept the %s value", conflict.Field, sessionID, conflict.Resolution)
+		}
 	}
 
-	// Create or update the session in PostgreSQL
-	session := ClaudeSession{
-		SessionID: sessionID,
-		Title:     title,
-		Messages:  messages,
-		Metadata: map[string]interface{}{
-			"source_file": filePath,
-			"last_synced": time.Now().Format(time.RFC3339),
-			"line_count":  lineCount,
-		},
+	var contextFiles []ContextFile
+	contextStore, ok := c.sink.(contextFileStore)
+	if c.snapshotContext && ok {
+		_, span := tracer.Start(ctx, "snapshotContextFiles")
+		var linked []ContextFile
+		if stored != nil {
+			if linked, err = linkedContextFiles(stored.Metadata); err != nil {
+				log.Printf("Ignoring context files stored for %s: %v", sessionID, err)
+			}
+		}
+		contextFiles = snapshotContextFiles(session, c.contextFiles, settings.redactor, linked)
+		span.SetAttributes(attribute.Int("claudemd.files", len(contextFiles)))
+		span.End()
+	}
+
+	hash, err := sessionContentHash(session)
+	if err != nil {
+		return err
+	}
+	session.ContentHash = hash
+	// Mirrors skip what they already hold, so they are fed even when the
+	// stored session is current
+	c.mirrors.enqueueSession(*session, contextFiles)
+	if reader, ok := c.sink.(contentHashReader); ok {
+		_, span := tracer.Start(ctx, "storedContentHash", trace.WithAttributes(sinkAttribute(c.sink)))
+		stored, err := reader.StoredContentHash(sessionID)
+		endSpan(span, err)
+		if err != nil {
+			stats.RecordSync(sessionID, 0, err)
+			publishSync(sessionID, 0, err)
+			return err
+		}
+		// The stored row, and everything derived from it, is already current
+		if stored == hash {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("claudemd.unchanged", true))
+			stats.RecordSyncUnchanged()
+			return nil
+		}
 	}
 
-	// Try to upsert the session
-	if err := c.upsertSession(session); err != nil {
+	// Contents go first so the links in metadata always resolve
+	if len(contextFiles) > 0 {
+		if err := contextStore.StoreContextFiles(contextFiles); err != nil {
+			stats.RecordSync(sessionID, 0, err)
+			publishSync(sessionID, 0, err)
+			return err
+		}
+	}
+
+	_, span = tracer.Start(ctx, "upsertSession", trace.WithAttributes(
+		sinkAttribute(c.sink),
+		attribute.Int("claudemd.messages", len(session.Messages)),
+	))
+	err = c.sink.UpsertSession(*session)
+	endSpan(span, err)
+	if err != nil {
+		stats.RecordSync(sessionID, 0, err)
+		publishSync(sessionID, 0, err)
 		return fmt.Errorf("failed to save session to database: %w", err)
 	}
+	stats.RecordSync(sessionID, len(session.Messages), nil)
+	publishSync(sessionID, len(session.Messages), nil)
 
-	// Update sync timestamp
-	c.syncedFiles[filePath] = time.Now()
+	_, span = tracer.Start(ctx, "storeDerivedData")
+	storeDerivedData(c.sink, session)
+	span.End()
+	return nil
+}
 
-	log.Printf("Synced session %s with %d messages", sessionID, len(messages))
+// prepareSession enriches, redacts and titles a parsed session before it is
+// compared with the stored one
+func (c *ClaudeSessionSync) prepareSession(session *ClaudeSession, filePath string, settings syncSettings) error {
+	session.Workspace = settings.workspace
+	enrichSession(session, filePath)
+	applyThinkingMode(session, settings.thinking)
+	// Images are moved before redaction, which must not rewrite their data
+	if err := c.attachments.Extract(session); err != nil {
+		return fmt.Errorf("failed to extract attachments: %w", err)
+	}
+	settings.redactor.Redact(session)
+	if err := settings.plugins.Process(session, filePath); err != nil {
+		return err
+	}
+	if err := c.blobs.Offload(session); err != nil {
+		return fmt.Errorf("failed to offload tool results: %w", err)
+	}
+	// Titles are derived from redacted content so they never leak masked text
+	settings.titler.Title(session, filePath)
 	return nil
 }
 
-func (c *ClaudeSessionSync) upsertSession(session ClaudeSession) error {
+// storeDerivedData replaces the todos, file manifest, continuations and
+// prompts derived from a stored session. Failures are logged, not returned.
+func storeDerivedData(sink sessionSink, session *ClaudeSession) {
+	sessionID := session.SessionID
+	if err := sink.StoreTodos(sessionID, extractTodos(sessionID, session.Messages)); err != nil {
+		log.Printf("Failed to store todos for %s: %v", sessionID, err)
+	}
+	projectPath, _ := session.Metadata["project_path"].(string)
+	if err := sink.StoreFileManifest(sessionID, extractFileManifest(sessionID, projectPath, session.Messages)); err != nil {
+		log.Printf("Failed to store file manifest for %s: %v", sessionID, err)
+	}
+	if linker, ok := sink.(continuationLinker); ok {
+		if err := linker.StoreContinuations(sessionID, session.Messages); err != nil {
+			log.Printf("Failed to detect continuations of %s: %v", sessionID, err)
+		}
+	}
+	if recorder, ok := sink.(promptRecorder); ok {
+		if err := recorder.StorePrompts(sessionID, extractPrompts(session.Messages)); err != nil {
+			log.Printf("Failed to store prompts for %s: %v", sessionID, err)
+		}
+	}
+}
+
+func (p postgresSink) UpsertSession(session ClaudeSession) error {
 	// Serialize messages and metadata to JSON
 	messagesJSON, err := json.Marshal(session.Messages)
 	if err != nil {
@@ -313,15 +954,21 @@ func (c *ClaudeSessionSync) upsertSession(session ClaudeSession) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	// Use PostgreSQL UPSERT (INSERT ... ON CONFLICT)
+	// Use PostgreSQL UPSERT (INSERT ... ON CONFLICT). Without a workspace,
+	// new sessions get the column default and existing ones keep theirs.
 	query := `
-		INSERT INTO claude_sessions (id, session_id, user_id, title, messages, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO claude_sessions (id, session_id, user_id, title, messages, metadata, created_at, updated_at, workspace, started_at, ended_at, duration_ms, content_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE(NULLIF($9, ''), $10), $11, $12, $13, NULLIF($14, ''))
 		ON CONFLICT (session_id) DO UPDATE SET
 			title = EXCLUDED.title,
 			messages = EXCLUDED.messages,
 			metadata = EXCLUDED.metadata,
-			updated_at = EXCLUDED.updated_at
+			updated_at = EXCLUDED.updated_at,
+			started_at = EXCLUDED.started_at,
+			ended_at = EXCLUDED.ended_at,
+			duration_ms = EXCLUDED.duration_ms,
+			content_hash = EXCLUDED.content_hash,
+			workspace = CASE WHEN $9 = '' THEN claude_sessions.workspace ELSE EXCLUDED.workspace END
 		RETURNING id, created_at`
 
 	now := time.Now()
@@ -329,10 +976,16 @@ func (c *ClaudeSessionSync) upsertSession(session ClaudeSession) error {
 	if sessionID == "" {
 		sessionID = uuid.NewString()
 	}
+	times := sessionTimes(session.Messages)
 
 	var returnedID string
 	var createdAt time.Time
-	err = c.db.QueryRow(query, sessionID, session.SessionID, session.UserID, session.Title, string(messagesJSON), string(metadataJSON), now, now).Scan(&returnedID, &createdAt)
+	ctx, cancel := statementContext()
+	defer cancel()
+	start := time.Now()
+	err = p.db.QueryRowContext(ctx, query, sessionID, session.SessionID, session.UserID, session.Title, string(messagesJSON), string(metadataJSON), now, now, session.Workspace, defaultWorkspace,
+		times.StartedAt, times.EndedAt, times.DurationMS, session.ContentHash).Scan(&returnedID, &createdAt)
+	stats.RecordDBLatency(time.Since(start))
 	if err != nil {
 		return fmt.Errorf("failed to upsert session: %w", err)
 	}
@@ -347,62 +1000,54 @@ func (c *ClaudeSessionSync) SyncAll() error {
 
 // InitializeDatabase sets up the database connection and runs migrations
 func InitializeDatabase(config *Config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", config.DatabaseURL)
+	db, err := openDatabase(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	// Create the table if it doesn't exist
-	if err := createClaudeSessionsTable(db); err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
+	if _, err := migrateDatabase(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	log.Println("Database connection established and migrations completed")
 	return db, nil
 }
 
-// createClaudeSessionsTable creates the claude_sessions table if it doesn't exist
-func createClaudeSessionsTable(db *sql.DB) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS claude_sessions (
-			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-			session_id VARCHAR(255) UNIQUE NOT NULL,
-			user_id UUID,
-			title TEXT NOT NULL,
-			messages JSONB NOT NULL DEFAULT '[]',
-			metadata JSONB DEFAULT '{}',
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-		);
-
-		-- Create indexes for better performance
-		CREATE INDEX IF NOT EXISTS idx_claude_sessions_session_id ON claude_sessions(session_id);
-		CREATE INDEX IF NOT EXISTS idx_claude_sessions_user_id ON claude_sessions(user_id);
-		CREATE INDEX IF NOT EXISTS idx_claude_sessions_created_at ON claude_sessions(created_at);
-		CREATE INDEX IF NOT EXISTS idx_claude_sessions_title_gin ON claude_sessions USING gin(to_tsvector('english', title));
-
-		-- Create trigger for updating updated_at timestamp
-		CREATE OR REPLACE FUNCTION update_updated_at_column()
-		RETURNS TRIGGER AS $$
-		BEGIN
-			NEW.updated_at = NOW();
-			RETURN NEW;
-		END;
-		$$ language 'plpgsql';
-
-		DROP TRIGGER IF EXISTS update_claude_sessions_updated_at ON claude_sessions;
-		CREATE TRIGGER update_claude_sessions_updated_at
-			BEFORE UPDATE ON claude_sessions
-			FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
-	`
-
-	_, err := db.Exec(query)
-	return err
+// openDatabase connects to the configured database without touching the schema
+func openDatabase(config *Config) (*sql.DB, error) {
+	dsn := config.DatabaseURL
+	if dsn == "" {
+		if config.Supabase.restMode() {
+			return nil, fmt.Errorf("supabase rest mode has no database connection; set %s to use this command", supabaseDBPasswordEnv)
+		}
+		var err error
+		if dsn, err = supabaseConnectionString(config.Supabase); err != nil {
+			return nil, err
+		}
+	}
+
+	driver, dsn, err := storageDriverFor(dsn)
+	if err != nil {
+		return nil, err
+	}
+	timeout, _ := parseDatabaseDuration(config.Database.StatementTimeout)
+	if dsn, err = tuneDSN(driver, dsn, timeout, config.usesPgbouncer()); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driver.Name(), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	configurePool(db, config.Database)
+	statementTimeout = timeout
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
 }
 
 // CLI command to sync Claude sessions
@@ -413,19 +1058,180 @@ func syncSessionsCommand(c *cli.Context) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Initialize database
-	db, err := InitializeDatabase(config)
+	sync, err := openSessionSync(c, config)
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return err
 	}
 
-	sync := NewClaudeSessionSync(db)
+	defer sync.mirrors.close()
 
 	if c.Bool("watch") {
 		log.Println("Starting Claude session sync in watch mode...")
+		if stopReload, err := watchConfig(c, config, nil, sync); err != nil {
+			log.Printf("Config reload disabled: %v", err)
+		} else {
+			defer stopReload()
+		}
 		return sync.Start()
+	} else if sync.interval > 0 {
+		log.Printf("Syncing all Claude sessions every %s...", sync.interval)
+		if stopReload, err := watchConfig(c, config, nil, sync); err != nil {
+			log.Printf("Config reload disabled: %v", err)
+		} else {
+			defer stopReload()
+		}
+		return sync.SyncEvery(sync.interval)
 	} else {
 		log.Println("Performing one-time sync of all Claude sessions...")
-		return sync.SyncAll()
+		err := sync.SyncAll()
+		sync.mirrors.flush(mirrorFlushTimeout)
+		return err
+	}
+}
+
+// syncFlags are the flags shared by every command that runs the session sync
+func syncFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "snapshot-raw",
+			Usage: "Store a compressed copy of each raw JSONL file for restore",
+		},
+		&cli.BoolFlag{
+			Name:  "snapshot-context",
+			Usage: "Store each project's CLAUDE.md and settings as they were when its sessions started",
+		},
+		&cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "Only sync session files matching this glob (relative to ~/.claude/projects)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Skip projects or session files matching this glob",
+		},
+		&cli.BoolFlag{
+			Name:  "redact",
+			Usage: "Mask secrets and emails in messages before upload",
+		},
+		&cli.StringSliceFlag{
+			Name:  "extra-source",
+			Usage: "Also sync this ~/.claude directory: todos or shell-snapshots (repeatable)",
+		},
+		&cli.DurationFlag{
+			Name:  "interval",
+			Usage: "Run a full reconciliation sync this often (e.g. 15m), catching changes the watcher missed",
+		},
+		&cli.StringFlag{
+			Name:  "workspace",
+			Usage: "Sync sessions into this workspace (see `claudemd workspace create`)",
+		},
+	}
+}
+
+// openSessionSync connects to wherever sessions are synced: the database,
+// or PostgREST in Supabase REST mode
+func openSessionSync(c *cli.Context, config *Config) (*ClaudeSessionSync, error) {
+	if config.DatabaseURL != "" || !config.Supabase.restMode() {
+		db, err := InitializeDatabase(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database: %w", err)
+		}
+		sync, err := newConfiguredSync(c, config, db)
+		if err != nil {
+			return nil, err
+		}
+		sync.mirrors, err = startMirrors(config.Sinks, config)
+		return sync, err
+	}
+
+	sink, err := newSupabaseRestSink(config.Supabase)
+	if err != nil {
+		return nil, err
+	}
+	sync, err := newConfiguredSync(c, config, nil)
+	if err != nil {
+		return nil, err
+	}
+	sync.sink = sink
+	if sync.snapshotRaw {
+		log.Println("Raw snapshots need a database connection and are disabled in Supabase REST mode")
+		sync.snapshotRaw = false
 	}
-}
\ No newline at end of file
+	sync.mirrors, err = startMirrors(config.Sinks, config)
+	return sync, err
+}
+
+// newConfiguredSync creates a session sync with settings from config and CLI flags
+func newConfiguredSync(c *cli.Context, config *Config, db *sql.DB) (*ClaudeSessionSync, error) {
+	sync := NewClaudeSessionSync(db)
+	sync.snapshotRaw = config.SnapshotRaw || workspace.Sync.SnapshotRaw || c.Bool("snapshot-raw")
+	sync.snapshotContext = workspace.Sync.SnapshotContext || c.Bool("snapshot-context")
+	sync.contextFiles = workspace.Sync.ContextFiles
+	sync.thinking = config.Thinking
+	sync.conflicts = config.Conflicts
+	sync.sourceDeletes = config.SourceDeletes
+	sync.interval = c.Duration("interval")
+	if err := validateSyncInterval(sync.interval); err != nil {
+		return nil, err
+	}
+	extra, err := extraSources(workspace.Sync.ExtraSources, c.StringSlice("extra-source"))
+	if err != nil {
+		return nil, err
+	}
+	sync.extraSources = extra
+
+	var wsSettings WorkspaceSettings
+	if sync.workspace = selectedWorkspace(c, config); sync.workspace != "" {
+		if err := validateWorkspaceSlug(sync.workspace); err != nil {
+			return nil, err
+		}
+		// Other drivers and Supabase REST mode have no workspaces table to check
+		if db != nil && requirePostgres(db) == nil {
+			ws, err := getWorkspace(db, sync.workspace)
+			if err != nil {
+				return nil, fmt.Errorf("%w; create it with `claudemd workspace create %s`", err, sync.workspace)
+			}
+			wsSettings = ws.Settings
+		}
+	}
+
+	filter, err := NewPathFilter(syncPatterns(c, config))
+	if err != nil {
+		return nil, err
+	}
+	sync.filter = filter
+
+	if config.Redaction.Enabled || workspace.Sync.Redact || wsSettings.Redact || c.Bool("redact") {
+		redactor, err := NewRedactor(config.Redaction)
+		if err != nil {
+			return nil, err
+		}
+		sync.redactor = redactor
+	}
+
+	titleDB := db
+	if db != nil && requirePostgres(db) != nil {
+		// Only the sink speaks the SQL of other drivers
+		titleDB = nil
+		if sync.snapshotRaw {
+			log.Printf("Raw snapshots need PostgreSQL and are disabled for %s databases", storageDriverOf(db).Name())
+			sync.snapshotRaw = false
+		}
+	}
+	titler, err := NewTitler(config.Titles, titleDB)
+	if err != nil {
+		return nil, err
+	}
+	sync.titler = titler
+
+	if sync.plugins, err = newPluginChain(config.Plugins); err != nil {
+		return nil, err
+	}
+	if sync.blobs, err = newBlobOffloader(config.Blobs); err != nil {
+		return nil, err
+	}
+	if sync.attachments, err = newAttachmentExtractor(config.Blobs); err != nil {
+		return nil, err
+	}
+
+	return sync, nil
+}
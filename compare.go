@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// ComparedSession summarizes one side of a comparison
+type ComparedSession struct {
+	SessionID string `json:"session_id"`
+	Title     string `json:"title"`
+	Messages  int    `json:"messages"`
+	ToolCalls int    `json:"tool_calls"`
+}
+
+// MessageRef points at a message within a session
+type MessageRef struct {
+	Index   int    `json:"index"`
+	UUID    string `json:"uuid,omitempty"`
+	Role    string `json:"role"`
+	Preview string `json:"preview"`
+}
+
+// ToolCountDiff reports how often each side used a tool
+type ToolCountDiff struct {
+	Name string `json:"name"`
+	A    int    `json:"a"`
+	B    int    `json:"b"`
+}
+
+// SessionComparison is a structured diff of two sessions working on the same task
+type SessionComparison struct {
+	A ComparedSession `json:"a"`
+	B ComparedSession `json:"b"`
+	// CommonPrefix is the number of leading messages shared by both sessions
+	CommonPrefix int  `json:"common_prefix"`
+	Identical    bool `json:"identical"`
+	// DivergenceA and DivergenceB are the first differing messages on each side
+	DivergenceA *MessageRef     `json:"divergence_a,omitempty"`
+	DivergenceB *MessageRef     `json:"divergence_b,omitempty"`
+	ToolCallsA  []string        `json:"tool_calls_a"`
+	ToolCallsB  []string        `json:"tool_calls_b"`
+	ToolCounts  []ToolCountDiff `json:"tool_counts"`
+}
+
+// conversationMessages drops summary lines, which are not part of the exchange
+func conversationMessages(messages []SessionMessage) []SessionMessage {
+	var out []SessionMessage
+	for _, msg := range messages {
+		if msg.Type != "summary" {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// sameMessage aligns messages by UUID, falling back to role and content for re-runs
+func sameMessage(a, b SessionMessage) bool {
+	if a.UUID != "" && a.UUID == b.UUID {
+		return true
+	}
+	return messageRole(a) == messageRole(b) && a.Content == b.Content
+}
+
+// compareSessions aligns two sessions and reports where and how they diverge
+func compareSessions(a, b *ClaudeSession) SessionComparison {
+	msgsA := conversationMessages(a.Messages)
+	msgsB := conversationMessages(b.Messages)
+
+	prefix := 0
+	for prefix < len(msgsA) && prefix < len(msgsB) && sameMessage(msgsA[prefix], msgsB[prefix]) {
+		prefix++
+	}
+
+	callsA := extractToolCalls(msgsA)
+	callsB := extractToolCalls(msgsB)
+
+	result := SessionComparison{
+		A:            ComparedSession{SessionID: a.SessionID, Title: a.Title, Messages: len(msgsA), ToolCalls: len(callsA)},
+		B:            ComparedSession{SessionID: b.SessionID, Title: b.Title, Messages: len(msgsB), ToolCalls: len(callsB)},
+		CommonPrefix: prefix,
+		Identical:    prefix == len(msgsA) && prefix == len(msgsB),
+		ToolCallsA:   toolCallsAfter(callsA, prefix),
+		ToolCallsB:   toolCallsAfter(callsB, prefix),
+		ToolCounts:   diffToolCounts(callsA, callsB),
+	}
+	if prefix < len(msgsA) {
+		result.DivergenceA = newMessageRef(msgsA[prefix], prefix)
+	}
+	if prefix < len(msgsB) {
+		result.DivergenceB = newMessageRef(msgsB[prefix], prefix)
+	}
+	return result
+}
+
+func newMessageRef(msg SessionMessage, index int) *MessageRef {
+	return &MessageRef{Index: index, UUID: msg.UUID, Role: messageRole(msg), Preview: truncateText(msg.Content, 160)}
+}
+
+// toolCallsAfter describes the tool calls made after the divergence point
+func toolCallsAfter(calls []ToolCall, from int) []string {
+	out := []string{}
+	for _, call := range calls {
+		if call.MessageIndex >= from {
+			out = append(out, describeToolCall(call))
+		}
+	}
+	return out
+}
+
+// describeToolCall renders a tool call as "Name: short input"
+func describeToolCall(call ToolCall) string {
+	desc := describeToolInput(call.Input)
+	var input map[string]interface{}
+	if json.Unmarshal(call.Input, &input) == nil {
+		for _, key := range []string{"file_path", "command", "pattern", "path", "url"} {
+			if v, ok := input[key].(string); ok && v != "" {
+				desc = v
+				break
+			}
+		}
+	}
+	return call.Name + ": " + truncateText(desc, 100)
+}
+
+func diffToolCounts(a, b []ToolCall) []ToolCountDiff {
+	counts := make(map[string]*ToolCountDiff)
+	for _, call := range a {
+		if counts[call.Name] == nil {
+			counts[call.Name] = &ToolCountDiff{Name: call.Name}
+		}
+		counts[call.Name].A++
+	}
+	for _, call := range b {
+		if counts[call.Name] == nil {
+			counts[call.Name] = &ToolCountDiff{Name: call.Name}
+		}
+		counts[call.Name].B++
+	}
+
+	out := make([]ToolCountDiff, 0, len(counts))
+	for _, c := range counts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// truncateText shortens text to at most n runes, collapsing whitespace
+func truncateText(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
+
+// compareCommand prints a comparison of two sessions
+func compareCommand(c *cli.Context) error {
+	if c.NArg() != 2 && c.NArg() != 0 {
+		return fmt.Errorf("usage: claudemd compare <session_a> <session_b>")
+	}
+
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ids, err := sessionArgs(db, c.Args().Slice(), "Session A", "Session B")
+	if err != nil {
+		return err
+	}
+	a, err := loadSession(db, ids[0])
+	if err != nil {
+		return err
+	}
+	b, err := loadSession(db, ids[1])
+	if err != nil {
+		return err
+	}
+
+	result := compareSessions(a, b)
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	fmt.Printf("A: %s (%d messages, %d tool calls) %s\n", result.A.SessionID, result.A.Messages, result.A.ToolCalls, result.A.Title)
+	fmt.Printf("B: %s (%d messages, %d tool calls) %s\n", result.B.SessionID, result.B.Messages, result.B.ToolCalls, result.B.Title)
+	if result.Identical {
+		fmt.Println("\nSessions are identical.")
+		return nil
+	}
+
+	fmt.Printf("\nShared prefix: %d messages\n", result.CommonPrefix)
+	for _, side := range []struct {
+		label string
+		ref   *MessageRef
+		calls []string
+	}{{"A", result.DivergenceA, result.ToolCallsA}, {"B", result.DivergenceB, result.ToolCallsB}} {
+		fmt.Printf("\n── %s after divergence ──\n", side.label)
+		if side.ref == nil {
+			fmt.Println("  (ends at the shared prefix)")
+			continue
+		}
+		fmt.Printf("  first message [%s]: %s\n", side.ref.Role, side.ref.Preview)
+		for _, call := range side.calls {
+			fmt.Printf("  • %s\n", call)
+		}
+	}
+
+	fmt.Println("\nTool usage (A vs B):")
+	for _, diff := range result.ToolCounts {
+		marker := " "
+		if diff.A != diff.B {
+			marker = "*"
+		}
+		fmt.Printf(" %s %-20s %4d %4d\n", marker, diff.Name, diff.A, diff.B)
+	}
+	return nil
+}
+
+// handleCompareSessions serves GET /api/compare?a=<id>&b=<id>
+func (a *apiServer) handleCompareSessions(w http.ResponseWriter, r *http.Request) {
+	idA, idB := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	if idA == "" || idB == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "Query parameters a and b are required", nil)
+		return
+	}
+
+	sessionA, err := loadSession(a.db, idA)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	sessionB, err := loadSession(a.db, idB)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, compareSessions(sessionA, sessionB))
+}
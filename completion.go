@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// completeShellEnv tells a completion request which shell asked, so zsh and
+// fish get descriptions alongside the values
+const completeShellEnv = "CLAUDEMD_COMPLETE"
+
+// completionSessions is how many recent sessions are offered as completions
+const completionSessions = 200
+
+// completionScripts load completions into each shell. They ask the binary
+// for candidates with urfave/cli's --generate-bash-completion flag.
+var completionScripts = map[string]string{
+	"bash": `# claudemd bash completion; load with: source <(claudemd completion bash)
+_claudemd_complete() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local words=("${COMP_WORDS[@]:0:COMP_CWORD}")
+  local opts
+  if [[ "$cur" == -* ]]; then
+    opts=$(CLAUDEMD_COMPLETE=bash "${words[@]}" "$cur" --generate-bash-completion 2>/dev/null)
+  else
+    opts=$(CLAUDEMD_COMPLETE=bash "${words[@]}" --generate-bash-completion 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+}
+complete -o bashdefault -o default -F _claudemd_complete claudemd
+`,
+	"zsh": `#compdef claudemd
+# claudemd zsh completion; load with: source <(claudemd completion zsh)
+_claudemd() {
+  local -a opts args
+  local cur=${words[CURRENT]}
+  args=(${words[1,CURRENT-1]})
+  if [[ "$cur" == -* ]]; then
+    args+=("$cur")
+  fi
+  opts=("${(@f)$(CLAUDEMD_COMPLETE=zsh ${args[@]} --generate-bash-completion 2>/dev/null)}")
+  if [[ -n "${opts[1]}" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+compdef _claudemd claudemd
+`,
+	"fish": `# claudemd fish completion; load with: claudemd completion fish | source
+function __claudemd_complete
+    set -l args (commandline -opc)
+    set -l cur (commandline -ct)
+    if string match -q -- '-*' $cur
+        set args $args $cur
+    end
+    env CLAUDEMD_COMPLETE=fish $args --generate-bash-completion 2>/dev/null
+end
+complete -c claudemd -f -a '(__claudemd_complete)'
+`,
+}
+
+// flagValueCompleters list the values of flags that name local things
+var flagValueCompleters = map[string]func() []completion{
+	"project": completeProjects,
+	"session": completeSessions,
+}
+
+// completion is a candidate with an optional description
+type completion struct {
+	value       string
+	description string
+}
+
+// printCompletions writes candidates in the format the shell expects
+func printCompletions(shell string, candidates []completion) {
+	for _, c := range candidates {
+		description := strings.Join(strings.Fields(c.description), " ")
+		switch {
+		case description == "":
+			fmt.Println(c.value)
+		case shell == "zsh":
+			fmt.Printf("%s:%s\n", strings.ReplaceAll(c.value, ":", `\:`), description)
+		case shell == "fish":
+			fmt.Printf("%s\t%s\n", c.value, description)
+		default:
+			fmt.Println(c.value)
+		}
+	}
+}
+
+// completeProjects lists the directories under ~/.claude/projects
+func completeProjects() []completion {
+	claudeDir, err := defaultClaudeDir()
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Join(claudeDir, "projects"))
+	if err != nil {
+		return nil
+	}
+	var projects []completion
+	for _, entry := range entries {
+		if entry.IsDir() {
+			projects = append(projects, completion{value: entry.Name()})
+		}
+	}
+	return projects
+}
+
+// completeSessions lists the most recently modified session files under
+// ~/.claude/projects, described by their project and age. Reading the
+// files, rather than the database, keeps completion instant.
+func completeSessions() []completion {
+	claudeDir, err := defaultClaudeDir()
+	if err != nil {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(claudeDir, "projects", "*", "*.jsonl"))
+	type sessionFile struct {
+		id, project string
+		modified    time.Time
+	}
+	sessions := make([]sessionFile, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, sessionFile{strings.TrimSuffix(filepath.Base(file), ".jsonl"),
+			filepath.Base(filepath.Dir(file)), info.ModTime()})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].modified.After(sessions[j].modified) })
+	if len(sessions) > completionSessions {
+		sessions = sessions[:completionSessions]
+	}
+	completions := make([]completion, len(sessions))
+	for i, s := range sessions {
+		completions[i] = completion{value: s.id, description: s.project + ", " + s.modified.Format("2006-01-02 15:04")}
+	}
+	return completions
+}
+
+// takesSessionArgs reports whether a command's arguments are session IDs
+func takesSessionArgs(cmd *cli.Command) bool {
+	return strings.Contains(cmd.ArgsUsage, "session_")
+}
+
+// findFlag returns the flag with the given name or alias
+func findFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, flag := range flags {
+		for _, n := range flag.Names() {
+			if n == name {
+				return flag
+			}
+		}
+	}
+	return nil
+}
+
+// completeCommand offers subcommands, flags, flag values or session IDs,
+// depending on what is being typed after cmd, which is nil for the app itself
+func completeCommand(cmd *cli.Command) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		shell := os.Getenv(completeShellEnv)
+		flags, commands := c.App.Flags, c.App.Commands
+		if cmd != nil {
+			flags, commands = cmd.Flags, cmd.Subcommands
+		}
+		// The last argument is --generate-bash-completion
+		var last string
+		if len(os.Args) > 2 {
+			last = os.Args[len(os.Args)-2]
+		}
+
+		if strings.HasPrefix(last, "-") {
+			name := strings.TrimLeft(last, "-")
+			if flag, ok := findFlag(flags, name).(cli.DocGenerationFlag); ok && flag.TakesValue() {
+				if values := flagValueCompleters[name]; values != nil {
+					printCompletions(shell, values())
+				}
+				return
+			}
+			var candidates []completion
+			for _, flag := range flags {
+				if visible, ok := flag.(cli.VisibleFlag); ok && !visible.IsVisible() {
+					continue
+				}
+				usage := ""
+				if doc, ok := flag.(cli.DocGenerationFlag); ok {
+					usage = doc.GetUsage()
+				}
+				for _, n := range flag.Names() {
+					prefix := "--"
+					if len(n) == 1 {
+						prefix = "-"
+					}
+					if strings.HasPrefix(prefix+n, last) {
+						candidates = append(candidates, completion{value: prefix + n, description: usage})
+					}
+				}
+			}
+			printCompletions(shell, candidates)
+			return
+		}
+
+		var candidates []completion
+		for _, sub := range commands {
+			// urfave/cli adds a help command to every command it runs
+			if sub.Hidden || (cmd != nil && sub.Name == "help") {
+				continue
+			}
+			for _, name := range sub.Names() {
+				candidates = append(candidates, completion{value: name, description: sub.Usage})
+			}
+		}
+		if len(candidates) > 0 {
+			printCompletions(shell, candidates)
+			return
+		}
+		if cmd != nil && takesSessionArgs(cmd) {
+			printCompletions(shell, completeSessions())
+		}
+	}
+}
+
+// enableCompletion turns on shell completion for the app and its commands
+func enableCompletion(app *cli.App) {
+	app.EnableBashCompletion = true
+	app.BashComplete = completeCommand(nil)
+	var walk func(commands []*cli.Command)
+	walk = func(commands []*cli.Command) {
+		for _, cmd := range commands {
+			if cmd.BashComplete == nil {
+				cmd.BashComplete = completeCommand(cmd)
+			}
+			walk(cmd.Subcommands)
+		}
+	}
+	walk(app.Commands)
+}
+
+// CommandInfo describes a command for tools, as printed by
+// `claudemd completion json`
+type CommandInfo struct {
+	Name        string        `json:"name"`
+	Aliases     []string      `json:"aliases,omitempty"`
+	Usage       string        `json:"usage,omitempty"`
+	ArgsUsage   string        `json:"args_usage,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Flags       []FlagInfo    `json:"flags,omitempty"`
+	Commands    []CommandInfo `json:"commands,omitempty"`
+}
+
+// FlagInfo describes a command line flag
+type FlagInfo struct {
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Usage      string   `json:"usage,omitempty"`
+	TakesValue bool     `json:"takes_value"`
+	Repeatable bool     `json:"repeatable,omitempty"`
+	Default    string   `json:"default,omitempty"`
+	EnvVars    []string `json:"env_vars,omitempty"`
+}
+
+// flagInfos describes the visible flags of a command
+func flagInfos(flags []cli.Flag) []FlagInfo {
+	var infos []FlagInfo
+	for _, flag := range flags {
+		if visible, ok := flag.(cli.VisibleFlag); ok && !visible.IsVisible() {
+			continue
+		}
+		names := flag.Names()
+		info := FlagInfo{Name: names[0], Aliases: names[1:]}
+		if doc, ok := flag.(cli.DocGenerationFlag); ok {
+			info.Usage = doc.GetUsage()
+			info.TakesValue = doc.TakesValue()
+			info.Default = doc.GetValue()
+			info.EnvVars = doc.GetEnvVars()
+		}
+		if slice, ok := flag.(cli.DocGenerationSliceFlag); ok {
+			info.Repeatable = slice.IsSliceFlag()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// commandInfos describes the visible commands and their subcommands
+func commandInfos(commands []*cli.Command) []CommandInfo {
+	var infos []CommandInfo
+	for _, cmd := range commands {
+		if cmd.Hidden || cmd.Name == "help" {
+			continue
+		}
+		infos = append(infos, CommandInfo{Name: cmd.Name, Aliases: cmd.Aliases, Usage: cmd.Usage, ArgsUsage: cmd.ArgsUsage,
+			Description: cmd.Description, Flags: flagInfos(cmd.Flags), Commands: commandInfos(cmd.Subcommands)})
+	}
+	return infos
+}
+
+// completionCommand prints the completion script of a shell, or the command
+// tree as JSON
+func completionCommand(c *cli.Context) error {
+	shell := c.Args().First()
+	if shell == "json" {
+		app := CommandInfo{Name: c.App.Name, Usage: c.App.Usage, Flags: flagInfos(c.App.Flags), Commands: commandInfos(c.App.Commands)}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(app)
+	}
+	script, ok := completionScripts[shell]
+	if !ok {
+		return fmt.Errorf("usage: claudemd completion bash|zsh|fish|json")
+	}
+	fmt.Print(script)
+	return nil
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Kinds of rule the compliance check understands. Other directives in
+// CLAUDE.md are reported as unchecked.
+const (
+	ruleForbidEdit     = "forbid_edit"
+	ruleForbidCommand  = "forbid_command"
+	ruleForbidContent  = "forbid_content"
+	ruleRequireCommand = "require_command"
+)
+
+// Outcomes of checking a rule against a session
+const (
+	compliancePass          = "pass"
+	complianceViolated      = "violated"
+	complianceNotApplicable = "not_applicable"
+	complianceUnchecked     = "unchecked"
+)
+
+// generatedRule stands for "generated files" in a forbid_edit rule
+const generatedRule = "(generated files)"
+
+// testsRule stands for "the tests" in a require_command rule
+const testsRule = "(tests)"
+
+var (
+	guidanceNegative = regexp.MustCompile(`(?i)\b(never|don'?t|do not|must not|mustn'?t|should not|shouldn'?t|avoid)\b`)
+	guidancePositive = regexp.MustCompile(`(?i)\b(always|must|make sure|ensure|remember to)\b`)
+	guidanceEdit     = regexp.MustCompile(`(?i)\b(edit|edits|editing|modify|modifying|change|changing|touch|touching|overwrite|hand-edit|write to)\b`)
+	guidanceRun      = regexp.MustCompile(`(?i)\b(run|running|execute|executing|invoke)\b`)
+	guidanceUse      = regexp.MustCompile(`(?i)\b(use|using)\b`)
+	guidanceTests    = regexp.MustCompile(`(?i)\btests?\b|\btest suite\b`)
+	guidanceCode     = regexp.MustCompile("`([^`]+)`")
+	guidanceGen      = regexp.MustCompile(`(?i)\b(auto-?)?generated\b`)
+	// guidancePath matches words that name a file, directory or glob
+	guidancePath = regexp.MustCompile(`^[\w.*/-]*(/[\w.*-]*|\.[A-Za-z][\w]{1,7}|\*[\w.*/-]*)$`)
+	// guidanceBullet strips list markers and emphasis from the start of a line
+	guidanceBullet = regexp.MustCompile(`^(\s*([-*+]|\d+[.)])\s+)+|\*\*|__`)
+	// guidanceSentence splits a line into sentences
+	guidanceSentence = regexp.MustCompile(`[.!?;]\s+`)
+
+	// generatedPath matches paths that are conventionally generated
+	generatedPath = regexp.MustCompile(`(?i)(^|/)(__generated__|generated|gen)/|[._-](gen|generated)\.\w+$|\.pb\.go$|_pb2\.py$|\.min\.(js|css)$|(^|/)(package-lock\.json|yarn\.lock|pnpm-lock\.yaml|go\.sum)$`)
+	// generatedMarkers appear in the header of generated files
+	generatedMarkers = []string{"Code generated", "@generated", "DO NOT EDIT", "auto-generated", "autogenerated"}
+	// testCommand matches the common ways of running a test suite
+	testCommand = regexp.MustCompile(`\b(go test|(npm|pnpm|yarn|bun) (run )?test|npx (jest|vitest|playwright)|jest|vitest|pytest|cargo test|make (test|check)|mvn test|gradle test|rspec|phpunit|dotnet test|mix test)\b`)
+	// identifier matches code spans that are a single word
+	identifier = regexp.MustCompile(`^\w+$`)
+	// commandWords start code spans that are commands rather than code
+	commandWords = map[string]bool{"npm": true, "npx": true, "yarn": true, "pnpm": true, "bun": true, "go": true, "git": true,
+		"make": true, "cargo": true, "pip": true, "python": true, "docker": true, "rm": true, "sudo": true, "curl": true}
+)
+
+// guidancePhrases map directives written in prose to the code they forbid
+var guidancePhrases = map[string]string{
+	"default export": "export default",
+}
+
+// ComplianceRule is a directive from a CLAUDE.md file
+type ComplianceRule struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Text   string `json:"text"`
+	// Kind is empty for directives the check does not understand
+	Kind     string   `json:"kind,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// ComplianceEvidence is a tool call that broke, or satisfied, a rule
+type ComplianceEvidence struct {
+	Index  int    `json:"index"`
+	UUID   string `json:"uuid,omitempty"`
+	Tool   string `json:"tool"`
+	Detail string `json:"detail"`
+}
+
+// RuleResult is the outcome of checking one rule against a session
+type RuleResult struct {
+	ComplianceRule
+	Status   string               `json:"status"`
+	Evidence []ComplianceEvidence `json:"evidence,omitempty"`
+}
+
+// ComplianceReport checks a session against the CLAUDE.md files of its project
+type ComplianceReport struct {
+	SessionID   string       `json:"session_id"`
+	Title       string       `json:"title"`
+	ProjectPath string       `json:"project_path,omitempty"`
+	Sources     []string     `json:"sources"`
+	Rules       []RuleResult `json:"rules"`
+	Passed      int          `json:"passed"`
+	Violated    int          `json:"violated"`
+	Unchecked   int          `json:"unchecked"`
+}
+
+// findGuidanceFiles returns the CLAUDE.md files Claude Code would have read
+// in projectPath: the user's own, then those of each directory from the
+// root down to the project
+func findGuidanceFiles(projectPath string) []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		if path := filepath.Join(home, ".claude", "CLAUDE.md"); fileExists(path) {
+			files = append(files, path)
+		}
+	}
+	if projectPath == "" {
+		return files
+	}
+	var project []string
+	for dir := filepath.Clean(projectPath); ; dir = filepath.Dir(dir) {
+		if path := filepath.Join(dir, "CLAUDE.md"); fileExists(path) {
+			project = append([]string{path}, project...)
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return append(files, project...)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// extractGuidanceRules reads the directives of a CLAUDE.md file: sentences
+// outside code blocks that say never, always, must and the like
+func extractGuidanceRules(source string, content []byte) []ComplianceRule {
+	var rules []ComplianceRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inCode := false
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "<!--") {
+			continue
+		}
+		line = strings.TrimSpace(guidanceBullet.ReplaceAllString(line, ""))
+		for _, sentence := range guidanceSentence.Split(line, -1) {
+			sentence = strings.TrimSpace(sentence)
+			if !guidanceNegative.MatchString(sentence) && !guidancePositive.MatchString(sentence) {
+				continue
+			}
+			rule := ComplianceRule{Source: source, Line: lineNo, Text: sentence}
+			rule.Kind, rule.Patterns = classifyGuidance(sentence)
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// classifyGuidance works out what a directive asks for and what it names
+func classifyGuidance(sentence string) (string, []string) {
+	var spans, paths []string
+	for _, m := range guidanceCode.FindAllStringSubmatch(sentence, -1) {
+		spans = append(spans, strings.TrimSpace(m[1]))
+	}
+	for _, word := range strings.Fields(guidanceCode.ReplaceAllString(sentence, " ")) {
+		word = strings.Trim(word, `"'(),:;!?`)
+		word = strings.TrimSuffix(word, ".")
+		if guidancePath.MatchString(word) && !strings.HasPrefix(word, "http") {
+			paths = append(paths, word)
+		}
+	}
+	var commands, code []string
+	for _, span := range spans {
+		switch {
+		case commandWords[strings.Fields(span + " x")[0]]:
+			commands = append(commands, span)
+		case guidancePath.MatchString(span):
+			paths = append(paths, span)
+		default:
+			code = append(code, span)
+		}
+	}
+
+	negative := guidanceNegative.MatchString(sentence)
+	switch {
+	case negative && guidanceEdit.MatchString(sentence) && len(paths) > 0:
+		return ruleForbidEdit, paths
+	case negative && guidanceEdit.MatchString(sentence) && guidanceGen.MatchString(sentence):
+		return ruleForbidEdit, []string{generatedRule}
+	case negative && (guidanceRun.MatchString(sentence) || guidanceUse.MatchString(sentence)) && len(commands) > 0:
+		return ruleForbidCommand, commands
+	case negative && guidanceUse.MatchString(sentence) && len(code) > 0:
+		return ruleForbidContent, code
+	case negative:
+		lower := strings.ToLower(sentence)
+		for phrase, forbidden := range guidancePhrases {
+			if strings.Contains(lower, phrase) {
+				return ruleForbidContent, []string{forbidden}
+			}
+		}
+	case guidanceRun.MatchString(sentence) && len(commands)+len(code) > 0:
+		return ruleRequireCommand, append(commands, code...)
+	case guidanceRun.MatchString(sentence) && guidanceTests.MatchString(sentence):
+		return ruleRequireCommand, []string{testsRule}
+	}
+	return "", nil
+}
+
+// editAction is a successful Edit, MultiEdit or Write call
+type editAction struct {
+	call    ToolCall
+	order   int
+	path    string
+	rel     string
+	added   []string
+	removed []string
+}
+
+// shellAction is a Bash call, whether or not it succeeded
+type shellAction struct {
+	call    ToolCall
+	order   int
+	command string
+}
+
+// sessionActions splits a session's tool calls into file edits and commands
+func sessionActions(messages []SessionMessage, projectPath string) ([]editAction, []shellAction) {
+	var edits []editAction
+	var commands []shellAction
+	for i, call := range extractToolCalls(messages) {
+		switch call.Name {
+		case "Bash":
+			var input struct {
+				Command string `json:"command"`
+			}
+			if json.Unmarshal(call.Input, &input) == nil && input.Command != "" {
+				commands = append(commands, shellAction{call: call, order: i, command: input.Command})
+			}
+		case "Edit", "MultiEdit", "Write":
+			var input fileEditInput
+			if call.IsError || json.Unmarshal(call.Input, &input) != nil || input.FilePath == "" {
+				continue
+			}
+			edit := editAction{call: call, order: i, path: input.FilePath, rel: filepath.ToSlash(input.FilePath)}
+			if projectPath != "" {
+				if rel, err := filepath.Rel(projectPath, input.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+					edit.rel = filepath.ToSlash(rel)
+				}
+			}
+			if call.Name == "Write" {
+				edit.added = []string{input.Content}
+			} else {
+				if call.Name == "Edit" {
+					input.Edits = []textEdit{input.textEdit}
+				}
+				for _, e := range input.Edits {
+					edit.added = append(edit.added, e.NewString)
+					edit.removed = append(edit.removed, e.OldString)
+				}
+			}
+			edits = append(edits, edit)
+		}
+	}
+	return edits, commands
+}
+
+// pathMatcher matches edited paths against the paths named by a rule. Names
+// without a slash match at any depth, and directories match what they contain.
+func pathMatcher(patterns []string) func(rel string) bool {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "./")
+		if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+			pattern = "**/" + pattern
+		}
+		for _, p := range []string{pattern, strings.TrimSuffix(pattern, "/") + "/**"} {
+			if re, err := globToRegexp(p); err == nil {
+				res = append(res, re)
+			}
+		}
+	}
+	return func(rel string) bool {
+		for _, re := range res {
+			if re.MatchString(rel) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isGeneratedEdit reports whether an edit touched a file that looks generated
+func isGeneratedEdit(edit editAction) bool {
+	if generatedPath.MatchString(edit.rel) {
+		return true
+	}
+	for _, text := range append(append([]string{}, edit.removed...), edit.added...) {
+		head := text
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		for _, marker := range generatedMarkers {
+			if strings.Contains(head, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkRule checks one rule against the edits and commands of a session
+func checkRule(rule ComplianceRule, edits []editAction, commands []shellAction) RuleResult {
+	result := RuleResult{ComplianceRule: rule, Status: compliancePass}
+	evidence := func(call ToolCall, detail string) {
+		result.Evidence = append(result.Evidence, ComplianceEvidence{Index: call.MessageIndex, UUID: call.MessageUUID, Tool: call.Name, Detail: detail})
+	}
+
+	switch rule.Kind {
+	case ruleForbidEdit:
+		generated := len(rule.Patterns) == 1 && rule.Patterns[0] == generatedRule
+		matches := pathMatcher(rule.Patterns)
+		for _, edit := range edits {
+			if (generated && isGeneratedEdit(edit)) || (!generated && matches(edit.rel)) {
+				evidence(edit.call, edit.rel)
+			}
+		}
+	case ruleForbidCommand:
+		for _, cmd := range commands {
+			for _, pattern := range rule.Patterns {
+				if strings.Contains(cmd.command, pattern) {
+					evidence(cmd.call, truncateText(cmd.command, 120))
+					break
+				}
+			}
+		}
+	case ruleForbidContent:
+		for _, edit := range edits {
+			for _, pattern := range rule.Patterns {
+				if containsAny(edit.added, pattern) && !containsAny(edit.removed, pattern) {
+					evidence(edit.call, fmt.Sprintf("%s adds %s", edit.rel, pattern))
+					break
+				}
+			}
+		}
+	case ruleRequireCommand:
+		// The command has to run after the last change it should cover
+		if len(edits) == 0 {
+			result.Status = complianceNotApplicable
+			return result
+		}
+		last := edits[len(edits)-1]
+		for _, cmd := range commands {
+			if cmd.order > last.order && commandMatches(cmd.command, rule.Patterns) {
+				return result
+			}
+		}
+		evidence(last.call, fmt.Sprintf("no matching command after the last edit of %s", last.rel))
+	default:
+		result.Status = complianceUnchecked
+	}
+	if len(result.Evidence) > 0 {
+		result.Status = complianceViolated
+	}
+	return result
+}
+
+// containsAny reports whether any text contains pattern, as a whole word
+// when it is one so that "any" does not match "many"
+func containsAny(texts []string, pattern string) bool {
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(pattern) + `\b`)
+	for _, text := range texts {
+		if strings.Contains(text, pattern) && (!identifier.MatchString(pattern) || word.MatchString(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandMatches reports whether a shell command runs any of the patterns
+func commandMatches(command string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if (pattern == testsRule && testCommand.MatchString(command)) || strings.Contains(command, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCompliance checks a session against the rules of the guidance files
+func checkCompliance(session *ClaudeSession, projectPath string, sources []string, rules []ComplianceRule) ComplianceReport {
+	report := ComplianceReport{SessionID: session.SessionID, Title: session.Title, ProjectPath: projectPath,
+		Sources: sources, Rules: []RuleResult{}}
+	edits, commands := sessionActions(session.Messages, projectPath)
+	for _, rule := range rules {
+		result := checkRule(rule, edits, commands)
+		switch result.Status {
+		case compliancePass:
+			report.Passed++
+		case complianceViolated:
+			report.Violated++
+		case complianceUnchecked:
+			report.Unchecked++
+		}
+		report.Rules = append(report.Rules, result)
+	}
+	return report
+}
+
+// loadGuidanceRules reads the rules of each file, caching them by path
+func loadGuidanceRules(paths []string, cache map[string][]ComplianceRule) ([]ComplianceRule, error) {
+	var rules []ComplianceRule
+	for _, path := range paths {
+		cached, ok := cache[path]
+		if !ok {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			cached = extractGuidanceRules(path, content)
+			cache[path] = cached
+		}
+		rules = append(rules, cached...)
+	}
+	return rules, nil
+}
+
+// complianceCommand checks sessions against the CLAUDE.md guidance of their
+// project, reporting which rules their edits and commands broke
+func complianceCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ids, err := sessionArgs(db, c.Args().Slice(), "Session")
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[string][]ComplianceRule)
+	reports := make([]ComplianceReport, 0, len(ids))
+	for _, id := range ids {
+		session, err := loadSession(db, id)
+		if err != nil {
+			return err
+		}
+		sourceFile, _ := session.Metadata["source_file"].(string)
+		projectPath := sessionProjectPath(session.Messages, sourceFile)
+		sources := c.StringSlice("claude-md")
+		if len(sources) == 0 {
+			sources = findGuidanceFiles(projectPath)
+		}
+		if len(sources) == 0 {
+			fmt.Fprintf(os.Stderr, "⚠️  No CLAUDE.md found for %s in %s\n", id, projectPath)
+		}
+		rules, err := loadGuidanceRules(sources, cache)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, checkCompliance(session, projectPath, sources, rules))
+	}
+
+	header := []string{"SESSION", "STATUS", "RULE", "EVIDENCE"}
+	var table [][]string
+	violated, sessions := 0, 0
+	for _, report := range reports {
+		if report.Violated > 0 {
+			violated += report.Violated
+			sessions++
+		}
+		for _, result := range report.Rules {
+			var evidence string
+			if len(result.Evidence) > 0 {
+				evidence = fmt.Sprintf("#%d %s", result.Evidence[0].Index, result.Evidence[0].Detail)
+				if more := len(result.Evidence) - 1; more > 0 {
+					evidence += fmt.Sprintf(" (+%d more)", more)
+				}
+			}
+			rule := filepath.Base(result.Source) + ":" + strconv.Itoa(result.Line) + " " + truncateText(result.Text, 80)
+			table = append(table, []string{report.SessionID, result.Status, rule, evidence})
+		}
+	}
+	if len(table) > 0 || format != "table" {
+		if err := writeOutput(os.Stdout, format, reports, header, table); err != nil {
+			return err
+		}
+	}
+
+	if violated == 0 {
+		fmt.Fprintf(os.Stderr, "✅ No CLAUDE.md rules broken in %d session(s)\n", len(reports))
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "❌ %d rule violation(s) in %d of %d session(s)\n", violated, sessions, len(reports))
+	if c.Bool("strict") {
+		return fmt.Errorf("sessions did not follow CLAUDE.md")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ import { AuthProvider } from '../auth';
 import { ClaudeDocBrowser } from './ClaudeDocBrowser';
 import { ClaudeSessionBrowser } from './ClaudeSessionBrowser';
 import { ClaudeDocEditor } from './ClaudeDocEditor';
+import { DevStatusBar } from './DevStatusBar';
 import type { ClaudeDocResponse } from '../types/database';
 
 type AppView = 'sessions' | 'documents' | 'create' | 'edit';
@@ -89,6 +90,8 @@ export const ClaudeDocApp: React.FC = () => {
             onCancel={handleCancel}
           />
         )}
+
+        <DevStatusBar />
       </div>
     </AuthProvider>
   );
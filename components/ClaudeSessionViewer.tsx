@@ -1,5 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { useSessions, useSession } from '../hooks/useSessions';
+import { useSessionRoom } from '../hooks/useSessionRoom';
+import type { RoomViewer } from '../hooks/useSessionRoom';
 import type { SessionSummary } from '../data/SessionRepository';
 import type { ClaudeSession, SessionMessage, CategorizedMessage } from '../types/session';
 
@@ -329,7 +331,66 @@ interface SessionDetailViewProps {
 
 function SessionDetailView({ session, loading, error, onBack, onCopyUrl }: SessionDetailViewProps) {
   const [showSecondaryMessages, setShowSecondaryMessages] = useState(false);
-  
+  const { viewers, sharePointer } = useSessionRoom(loading ? null : session.session_id);
+  // The viewer whose scroll position we follow, if any
+  const [following, setFollowing] = useState<string | null>(null);
+
+  // Share the message at the top of the viewport and any selected text
+  useEffect(() => {
+    if (loading) {
+      return;
+    }
+    const share = () => {
+      const scrollable = document.documentElement.scrollHeight - window.innerHeight;
+      const pointer = {
+        message: 0,
+        message_uuid: undefined as string | undefined,
+        scroll: scrollable > 0 ? Math.min(1, Math.max(0, window.scrollY / scrollable)) : 0,
+        selection: undefined as { message: number; start: number; end: number; text: string } | undefined,
+      };
+      for (const el of Array.from(document.querySelectorAll<HTMLElement>('[data-message-index]'))) {
+        if (el.getBoundingClientRect().bottom > 0) {
+          pointer.message = Number(el.dataset.messageIndex);
+          pointer.message_uuid = session.messages?.[pointer.message]?.uuid;
+          break;
+        }
+      }
+      const selection = window.getSelection();
+      const anchor = selection?.anchorNode?.parentElement?.closest<HTMLElement>('[data-message-index]');
+      if (selection && anchor && !selection.isCollapsed) {
+        const [start, end] = [selection.anchorOffset, selection.focusOffset].sort((a, b) => a - b);
+        pointer.selection = { message: Number(anchor.dataset.messageIndex), start, end, text: selection.toString() };
+      }
+      sharePointer(pointer);
+    };
+    window.addEventListener('scroll', share, { passive: true });
+    document.addEventListener('selectionchange', share);
+    return () => {
+      window.removeEventListener('scroll', share);
+      document.removeEventListener('selectionchange', share);
+    };
+  }, [loading, session, sharePointer]);
+
+  // Keep up with the followed viewer, stopping when they leave
+  const followed = viewers.find(v => v.id === following);
+  useEffect(() => {
+    if (following && !followed) {
+      setFollowing(null);
+      return;
+    }
+    const pointer = followed?.pointer;
+    if (!pointer) {
+      return;
+    }
+    const target = document.querySelector(`[data-message-index="${pointer.message}"]`);
+    if (target) {
+      target.scrollIntoView({ behavior: 'smooth', block: 'start' });
+    } else {
+      const scrollable = document.documentElement.scrollHeight - window.innerHeight;
+      window.scrollTo({ top: pointer.scroll * scrollable, behavior: 'smooth' });
+    }
+  }, [following, followed]);
+
   if (loading) {
     return (
       <div className="min-h-screen bg-gray-50 p-4">
@@ -377,6 +438,18 @@ function SessionDetailView({ session, loading, error, onBack, onCopyUrl }: Sessi
               ← Back to Sessions
             </button>
             <div className="flex items-center gap-3">
+              <RoomPresence
+                viewers={viewers}
+                following={following}
+                onFollow={(id) => setFollowing(current => (current === id ? null : id))}
+              />
+              <a
+                href={`/api/sessions/${encodeURIComponent(session.session_id)}/raw`}
+                download={`${session.session_id}.jsonl`}
+                className="px-4 py-2 bg-gray-100 text-gray-700 rounded-lg hover:bg-gray-200 transition-colors"
+              >
+                Download JSONL
+              </a>
               <button
                 onClick={onCopyUrl}
                 className="px-4 py-2 bg-blue-100 text-blue-700 rounded-lg hover:bg-blue-200 transition-colors"
@@ -409,17 +482,15 @@ function SessionDetailView({ session, loading, error, onBack, onCopyUrl }: Sessi
           <div className="space-y-4">
             {/* First show finalized plans */}
             {finalizedPlans.map((categorizedMsg) => (
-              <EducationalMessageCard 
-                key={categorizedMsg.index} 
-                categorizedMessage={categorizedMsg}
-              />
+              <ViewerMarkers key={categorizedMsg.index} index={categorizedMsg.index} viewers={viewers}>
+                <EducationalMessageCard categorizedMessage={categorizedMsg} />
+              </ViewerMarkers>
             ))}
             {/* Then show other primary messages */}
             {primaryMessages.filter(m => !m.isExitPlanMode).map((categorizedMsg) => (
-              <EducationalMessageCard 
-                key={categorizedMsg.index} 
-                categorizedMessage={categorizedMsg}
-              />
+              <ViewerMarkers key={categorizedMsg.index} index={categorizedMsg.index} viewers={viewers}>
+                <EducationalMessageCard categorizedMessage={categorizedMsg} />
+              </ViewerMarkers>
             ))}
           </div>
         </div>
@@ -443,10 +514,9 @@ function SessionDetailView({ session, loading, error, onBack, onCopyUrl }: Sessi
             {showSecondaryMessages && (
               <div className="space-y-2 bg-gray-50 rounded-lg p-4">
                 {secondaryMessages.map((categorizedMsg) => (
-                  <TechnicalMessageCard 
-                    key={categorizedMsg.index} 
-                    categorizedMessage={categorizedMsg}
-                  />
+                  <ViewerMarkers key={categorizedMsg.index} index={categorizedMsg.index} viewers={viewers}>
+                    <TechnicalMessageCard categorizedMessage={categorizedMsg} />
+                  </ViewerMarkers>
                 ))}
               </div>
             )}
@@ -457,6 +527,70 @@ function SessionDetailView({ session, loading, error, onBack, onCopyUrl }: Sessi
   );
 }
 
+interface RoomPresenceProps {
+  viewers: RoomViewer[];
+  following: string | null;
+  onFollow: (id: string) => void;
+}
+
+// RoomPresence shows who else is viewing the session; clicking someone
+// follows their scrolling
+function RoomPresence({ viewers, following, onFollow }: RoomPresenceProps) {
+  if (viewers.length === 0) {
+    return null;
+  }
+  return (
+    <div className="flex items-center -space-x-2">
+      {viewers.map(viewer => (
+        <button
+          key={viewer.id}
+          onClick={() => onFollow(viewer.id)}
+          title={following === viewer.id ? `Following ${viewer.name}, click to stop` : `Follow ${viewer.name}`}
+          className={`w-8 h-8 rounded-full border-2 text-white text-xs font-semibold flex items-center justify-center ${
+            following === viewer.id ? 'border-gray-900' : 'border-white'
+          }`}
+          style={{ backgroundColor: viewer.color }}
+        >
+          {viewer.name.slice(0, 2).toUpperCase()}
+        </button>
+      ))}
+    </div>
+  );
+}
+
+interface ViewerMarkersProps {
+  index: number;
+  viewers: RoomViewer[];
+  children: React.ReactNode;
+}
+
+// ViewerMarkers wraps a message card, outlining it in the color of each
+// viewer who is at it or has text selected in it
+function ViewerMarkers({ index, viewers, children }: ViewerMarkersProps) {
+  const here = viewers.filter(v => v.pointer && (v.pointer.message === index || v.pointer.selection?.message === index));
+  return (
+    <div data-message-index={index} className="relative">
+      {here.length > 0 && (
+        <div className="absolute -left-3 top-0 bottom-0 flex gap-0.5">
+          {here.map(viewer => (
+            <span key={viewer.id} className="w-1 rounded-full" style={{ backgroundColor: viewer.color }} />
+          ))}
+        </div>
+      )}
+      {children}
+      {here.map(viewer => viewer.pointer?.selection?.message === index && viewer.pointer.selection.text && (
+        <p
+          key={viewer.id}
+          className="mt-1 text-xs text-gray-600 truncate border-l-2 pl-2"
+          style={{ borderColor: viewer.color }}
+        >
+          {viewer.name} selected “{viewer.pointer.selection.text}”
+        </p>
+      ))}
+    </div>
+  );
+}
+
 interface SessionOverviewProps {
   session: ClaudeSession;
   userPrompts: CategorizedMessage[];
@@ -573,13 +707,27 @@ function EducationalMessageCard({ categorizedMessage }: EducationalMessageCardPr
   const [copied, setCopied] = useState(false);
   const [showRawJson, setShowRawJson] = useState(false);
   const [jsonCopied, setJsonCopied] = useState(false);
+  const [fullContent, setFullContent] = useState<string | null>(null);
   const { message, messageType, importance, isExitPlanMode } = categorizedMessage;
   
-  const content = message.content || message.summary || '';
+  const content = fullContent ?? (message.content || message.summary || '');
   const PREVIEW_LENGTH = 300;
-  const shouldShowExpansion = content.length > PREVIEW_LENGTH;
+  const shouldShowExpansion = content.length > PREVIEW_LENGTH || (message.truncated && fullContent === null);
   const contentToShow = isExpanded || !shouldShowExpansion ? content : content.substring(0, PREVIEW_LENGTH) + '...';
 
+  // Long tool results are stored as a preview; fetch the rest on first expand
+  const toggleExpanded = () => {
+    if (!isExpanded && message.truncated && message.uuid && fullContent === null) {
+      fetch(`/api/messages/${encodeURIComponent(message.uuid)}/full`)
+        .then(res => (res.ok ? res.json() : null))
+        .then(data => {
+          if (data?.content) setFullContent(data.content);
+        })
+        .catch(() => {});
+    }
+    setIsExpanded(!isExpanded);
+  };
+
   const getMessageStyle = () => {
     // Special styling for exit_plan_mode messages (finalized plans)
     if (isExitPlanMode) {
@@ -677,7 +825,7 @@ function EducationalMessageCard({ categorizedMessage }: EducationalMessageCardPr
         
         {shouldShowExpansion && (
           <button
-            onClick={() => setIsExpanded(!isExpanded)}
+            onClick={toggleExpanded}
             className="mt-3 text-sm text-blue-600 hover:text-blue-800 underline focus:outline-none"
           >
             {isExpanded ? 'Show less' : 'Show more'}
@@ -0,0 +1,78 @@
+import React, { useState, useEffect } from 'react';
+import { useDevStatus } from '../hooks/useDevStatus';
+
+const timeAgo = (time: string, now: number): string => {
+  const seconds = Math.max(0, Math.round((now - Date.parse(time)) / 1000));
+  if (seconds < 60) return `${seconds}s ago`;
+  const minutes = Math.round(seconds / 60);
+  if (minutes < 60) return `${minutes}m ago`;
+  return `${Math.round(minutes / 60)}h ago`;
+};
+
+// DevStatusBar shows live build and sync activity from the dev server
+export const DevStatusBar: React.FC = () => {
+  const { connected, building, lastBuild, lastSync, lastTypecheck, syncedCount } = useDevStatus();
+  const [now, setNow] = useState(Date.now());
+
+  // Keep the relative times fresh
+  useEffect(() => {
+    const timer = setInterval(() => setNow(Date.now()), 5000);
+    return () => clearInterval(timer);
+  }, []);
+
+  if (!connected) {
+    return null;
+  }
+
+  let build: React.ReactNode = null;
+  if (building.length > 0) {
+    build = <span className="text-blue-600">Rebuilding {building.length === 1 ? building[0] : `${building.length} files`}…</span>;
+  } else if (lastBuild?.type === 'build_error') {
+    build = (
+      <span className="text-red-600" title={lastBuild.errors?.join('\n')}>
+        Build failed: {lastBuild.path} ({lastBuild.errors?.length || 0} errors)
+      </span>
+    );
+  } else if (lastBuild) {
+    build = <span className="text-green-700">Built {lastBuild.path} in {lastBuild.duration_ms}ms</span>;
+  }
+
+  let sync: React.ReactNode = null;
+  if (lastSync?.type === 'sync_error') {
+    sync = (
+      <span className="text-red-600" title={lastSync.errors?.join('\n')}>
+        Sync of {lastSync.session_id} failed {timeAgo(lastSync.time, now)}
+      </span>
+    );
+  } else if (lastSync) {
+    sync = (
+      <span>
+        {syncedCount} session{syncedCount === 1 ? '' : 's'} synced {timeAgo(lastSync.time, now)}
+      </span>
+    );
+  }
+
+  let types: React.ReactNode = null;
+  const typeErrors = lastTypecheck?.errors || [];
+  if (typeErrors.length > 0) {
+    types = (
+      <span className="text-red-600" title={typeErrors.join('\n')}>
+        {typeErrors.length} type error{typeErrors.length === 1 ? '' : 's'}
+      </span>
+    );
+  }
+
+  if (!build && !sync && !types) {
+    return null;
+  }
+
+  return (
+    <div className="fixed bottom-0 inset-x-0 bg-white border-t text-xs text-gray-600 px-4 py-1 flex gap-6">
+      {build}
+      {types}
+      {sync}
+    </div>
+  );
+};
+
+export default DevStatusBar;
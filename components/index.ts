@@ -2,4 +2,5 @@
 export { ClaudeDocApp } from './ClaudeDocApp';
 export { ClaudeDocBrowser } from './ClaudeDocBrowser';
 export { ClaudeDocEditor } from './ClaudeDocEditor';
-export { UserProfile } from './UserProfile';
\ No newline at end of file
+export { UserProfile } from './UserProfile';
+export { DevStatusBar } from './DevStatusBar';
\ No newline at end of file
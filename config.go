@@ -9,32 +9,95 @@ import (
 
 type Config struct {
 	DatabaseURL string `json:"database_url"`
+	// SnapshotRaw stores a gzip copy of every raw session file in the database
+	SnapshotRaw bool `json:"snapshot_raw"`
+	// Include and Exclude are glob patterns relative to ~/.claude/projects
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Redaction masks secrets and personal data before sessions are uploaded
+	Redaction RedactionConfig `json:"redaction"`
+	// AdminToken is required as a bearer token for admin API endpoints.
+	// When empty, admin endpoints only accept requests from localhost.
+	AdminToken string `json:"admin_token,omitempty"`
+	// IngestToken is required as a bearer token to push sessions through
+	// /api/ingest. When empty, ingest only accepts requests from localhost.
+	IngestToken string `json:"ingest_token,omitempty"`
+	// Titles selects the strategies used to title synced sessions
+	Titles TitleConfig `json:"titles"`
+	// Blobs moves oversized tool results out of the database
+	Blobs BlobConfig `json:"blobs"`
+	// Supabase connects to a Supabase project in place of database_url
+	Supabase SupabaseConfig `json:"supabase"`
+	// Thinking is how extended thinking blocks are stored: store (default),
+	// hash to keep only a SHA-256 of the text, or discard
+	Thinking string `json:"thinking,omitempty"`
+	// Conflicts decides what sync does with sessions edited in the database
+	Conflicts ConflictConfig `json:"conflicts"`
+	// SourceDeletes is what sync does when a session file is removed: mark
+	// (default) records source_deleted_at, delete also soft deletes the session
+	SourceDeletes string `json:"source_deletes,omitempty"`
+	// RequireAPIKeys makes the read endpoints require a read API key, and the
+	// endpoints changing data a write key, or the admin token, except for
+	// requests from localhost
+	RequireAPIKeys bool `json:"require_api_keys,omitempty"`
+	// Workspace is where this machine syncs sessions; sync.workspace in
+	// claudemd.config.json and --workspace take precedence
+	Workspace string `json:"workspace,omitempty"`
+	// Database tunes the connection pool and statement timeouts
+	Database DatabaseConfig `json:"database"`
+	// Outlines configures the summaries of GET /api/sessions/{id}/outline
+	Outlines OutlineConfig `json:"outlines"`
+	// Plugins transform or enrich sessions during sync, before they are stored
+	Plugins []PluginConfig `json:"plugins,omitempty"`
+	// Sinks are where sync mirrors sessions next to database_url, each with
+	// its own queue and retries
+	Sinks []SinkConfig `json:"sinks,omitempty"`
 }
 
 // LoadConfig loads configuration from data/config.json
 func LoadConfig() (*Config, error) {
 	configPath := filepath.Join("ignored", "config.json")
 	
-	// Check if config file exists
+	// Check if config file exists. SUPABASE_URL alone is enough to run without one.
+	var config Config
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("config file not found at %s", configPath)
+		if os.Getenv(supabaseURLEnv) == "" {
+			return nil, fmt.Errorf("config file not found at %s", configPath)
+		}
+	} else {
+		// Read config file
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		// Parse JSON
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+		}
 	}
 	
-	// Read config file
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	if err := validateThinkingMode(config.Thinking); err != nil {
+		return nil, err
 	}
-	
-	// Parse JSON
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config JSON: %w", err)
+	if err := config.Conflicts.validate(); err != nil {
+		return nil, err
 	}
-	
+	if err := validateSourceDeletes(config.SourceDeletes); err != nil {
+		return nil, err
+	}
+	if err := config.Database.validate(); err != nil {
+		return nil, err
+	}
+
 	// Validate required fields
 	if config.DatabaseURL == "" {
-		return nil, fmt.Errorf("database_url is required in config")
+		if err := config.Supabase.validate(); err != nil {
+			return nil, err
+		}
+		if !config.Supabase.enabled() {
+			return nil, fmt.Errorf("database_url or supabase.url is required in config")
+		}
 	}
 	
 	return &config, nil
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Sync conflict policies, selected with conflicts.policy in the config
+const (
+	// conflictFileWins keeps the session file's value when both sides changed
+	conflictFileWins = "file-wins"
+	// conflictDBWins keeps the value edited in the database
+	conflictDBWins = "db-wins"
+	// conflictMerge unions list fields such as tags and keeps the database
+	// value of anything else
+	conflictMerge = "merge"
+)
+
+// defaultConflictFields are the fields a policy covers when none are configured
+var defaultConflictFields = []string{"title", "tags"}
+
+// Metadata keys used to detect and record conflicts
+const (
+	// syncBaseKey holds the file's value of each covered field at the last sync
+	syncBaseKey = "sync_base"
+	// syncConflictsKey lists conflicts awaiting review
+	syncConflictsKey = "sync_conflicts"
+)
+
+// maxRecordedConflicts bounds the conflicts kept in a session's metadata
+const maxRecordedConflicts = 20
+
+// ConflictConfig decides what a sync does with fields that were edited in
+// the database after the session was last synced
+type ConflictConfig struct {
+	// Policy is file-wins (default), db-wins or merge
+	Policy string `json:"policy,omitempty"`
+	// Fields are "title" or metadata keys; defaults to title and tags
+	Fields []string `json:"fields,omitempty"`
+}
+
+func (c ConflictConfig) validate() error {
+	switch c.Policy {
+	case "", conflictFileWins, conflictDBWins, conflictMerge:
+	default:
+		return fmt.Errorf("unknown conflict policy %q (valid: %s, %s, %s)", c.Policy, conflictFileWins, conflictDBWins, conflictMerge)
+	}
+	for _, field := range c.Fields {
+		if field == "" || field == syncBaseKey || field == syncConflictsKey {
+			return fmt.Errorf("invalid conflict field %q", field)
+		}
+	}
+	return nil
+}
+
+func (c ConflictConfig) fields() []string {
+	if len(c.Fields) == 0 {
+		return defaultConflictFields
+	}
+	return c.Fields
+}
+
+// SyncConflict is a field changed both in the database and in the session
+// file since the previous sync
+type SyncConflict struct {
+	Field string      `json:"field"`
+	DB    interface{} `json:"db"`
+	File  interface{} `json:"file"`
+	// Resolution is file, db or merged
+	Resolution string    `json:"resolution"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// storedSession is the editable part of a session as it is in the database
+type storedSession struct {
+	Title    string                 `json:"title"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// sessionReader is implemented by sinks that can read back a synced session.
+// It returns nil for sessions that have not been synced.
+type sessionReader interface {
+	StoredSession(sessionID string) (*storedSession, error)
+}
+
+func (p postgresSink) StoredSession(sessionID string) (*storedSession, error) {
+	var stored storedSession
+	var metadata []byte
+	err := p.db.QueryRow(`SELECT title, metadata FROM claude_sessions WHERE session_id = $1`, sessionID).
+		Scan(&stored.Title, &metadata)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &stored.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	return &stored, nil
+}
+
+func (s *supabaseRestSink) StoredSession(sessionID string) (*storedSession, error) {
+	var rows []storedSession
+	path := "/claude_sessions?select=title,metadata&session_id=eq." + url.QueryEscape(sessionID)
+	if err := s.do("GET", path, "", nil, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// resolveConflicts reconciles the session parsed from its file with the
+// stored copy. Each covered field is compared three ways against the file's
+// value at the last sync: a field only edited in the database keeps that
+// edit, a field only changed in the file takes the new value, and a field
+// changed on both sides is settled by the policy and recorded for review.
+func resolveConflicts(config ConflictConfig, stored *storedSession, session *ClaudeSession) []SyncConflict {
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]interface{})
+	}
+	if stored == nil {
+		session.Metadata[syncBaseKey] = conflictValues(session.Title, session.Metadata, config.fields())
+		return nil
+	}
+	base, _ := stored.Metadata[syncBaseKey].(map[string]interface{})
+	fileValues := conflictValues(session.Title, session.Metadata, config.fields())
+
+	var conflicts []SyncConflict
+	for _, field := range config.fields() {
+		fileValue := fileValues[field]
+		dbValue := normalizeJSON(fieldValue(stored.Title, stored.Metadata, field))
+		// Without a recorded base, assume the file has not changed so
+		// nothing edited in the database is lost
+		baseValue, known := base[field]
+		if !known {
+			baseValue = fileValue
+		}
+
+		switch {
+		case reflect.DeepEqual(dbValue, baseValue), reflect.DeepEqual(dbValue, fileValue):
+			continue
+		case reflect.DeepEqual(fileValue, baseValue):
+			setFieldValue(session, field, dbValue)
+			continue
+		}
+
+		conflict := SyncConflict{Field: field, DB: dbValue, File: fileValue, DetectedAt: time.Now().UTC()}
+		switch config.Policy {
+		case conflictDBWins:
+			conflict.Resolution = "db"
+			setFieldValue(session, field, dbValue)
+		case conflictMerge:
+			if merged, ok := unionLists(dbValue, fileValue); ok {
+				conflict.Resolution = "merged"
+				setFieldValue(session, field, merged)
+			} else {
+				conflict.Resolution = "db"
+				setFieldValue(session, field, dbValue)
+			}
+		default:
+			conflict.Resolution = "file"
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	// Conflicts stay in the metadata until they are cleared through the API
+	var recorded []interface{}
+	if previous, ok := stored.Metadata[syncConflictsKey].([]interface{}); ok {
+		recorded = previous
+	}
+	for _, conflict := range conflicts {
+		recorded = append(recorded, conflict)
+	}
+	if len(recorded) > maxRecordedConflicts {
+		recorded = recorded[len(recorded)-maxRecordedConflicts:]
+	}
+	if len(recorded) > 0 {
+		session.Metadata[syncConflictsKey] = recorded
+	}
+	session.Metadata[syncBaseKey] = fileValues
+	return conflicts
+}
+
+// conflictValues returns the covered fields of a session in JSON form
+func conflictValues(title string, metadata map[string]interface{}, fields []string) map[string]interface{} {
+	values := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		values[field] = normalizeJSON(fieldValue(title, metadata, field))
+	}
+	return values
+}
+
+func fieldValue(title string, metadata map[string]interface{}, field string) interface{} {
+	if field == "title" {
+		return title
+	}
+	return metadata[field]
+}
+
+func setFieldValue(session *ClaudeSession, field string, value interface{}) {
+	if field == "title" {
+		if title, ok := value.(string); ok {
+			session.Title = title
+		}
+		return
+	}
+	if value == nil {
+		delete(session.Metadata, field)
+		return
+	}
+	session.Metadata[field] = value
+}
+
+// normalizeJSON round-trips a value through JSON so values built in Go
+// compare equal to the same values decoded from the database
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// unionLists merges two JSON lists, keeping the database order first
+func unionLists(db, file interface{}) ([]interface{}, bool) {
+	dbList, ok1 := db.([]interface{})
+	fileList, ok2 := file.([]interface{})
+	if (!ok1 && db != nil) || (!ok2 && file != nil) {
+		return nil, false
+	}
+	merged := append([]interface{}{}, dbList...)
+	for _, item := range fileList {
+		found := false
+		for _, existing := range merged {
+			if reflect.DeepEqual(existing, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+	return merged, true
+}
+
+// sessionUpdate is the body of PATCH /api/sessions/{id}. Omitted fields are
+// left unchanged.
+type sessionUpdate struct {
+	Title *string   `json:"title"`
+	Tags  *[]string `json:"tags"`
+	// ClearConflicts marks the recorded sync conflicts as reviewed
+	ClearConflicts bool `json:"clear_conflicts"`
+}
+
+// handleUpdateSession serves PATCH /api/sessions/{id}, editing the title and
+// tags of a session. Later syncs keep these edits as the conflict policy says.
+func (a *apiServer) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
+	var in sessionUpdate
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if in.Title != nil {
+		if *in.Title = strings.TrimSpace(*in.Title); *in.Title == "" {
+			writeJSONError(w, r, http.StatusBadRequest, "title cannot be empty", nil)
+			return
+		}
+	}
+	var tags []byte
+	if in.Tags != nil {
+		for _, tag := range *in.Tags {
+			if strings.TrimSpace(tag) == "" {
+				writeJSONError(w, r, http.StatusBadRequest, "tags cannot be empty", nil)
+				return
+			}
+		}
+		tags, _ = json.Marshal(*in.Tags)
+	}
+
+	sessionID := r.PathValue("id")
+	if _, err := loadSession(a.db, sessionID); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	previous, err := postgresSink{a.db}.StoredSession(sessionID)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	var updated storedSession
+	var metadata []byte
+	err = a.db.QueryRow(`
+		UPDATE claude_sessions
+		SET title = COALESCE($2, title),
+		    metadata = CASE WHEN $3::jsonb IS NULL THEN COALESCE(metadata, '{}')
+		                    ELSE COALESCE(metadata, '{}') || jsonb_build_object('tags', $3::jsonb) END
+		               - CASE WHEN $4 THEN $5 ELSE '' END,
+		    updated_at = NOW()
+		WHERE session_id = $1 AND deleted_at IS NULL
+		RETURNING title, metadata`,
+		sessionID, in.Title, nullableJSON(tags), in.ClearConflicts, syncConflictsKey).Scan(&updated.Title, &metadata)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to update session: %v", err), nil)
+		return
+	}
+	json.Unmarshal(metadata, &updated.Metadata)
+
+	a.audit(r, "session.update", "session", sessionID, previous, updated)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// nullableJSON passes empty JSON to the database as NULL
+func nullableJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// volatileMetadata are metadata keys set on every sync, left out of the
+// content hash so they alone do not cause a write
+var volatileMetadata = []string{"last_synced"}
+
+// sessionContentHash hashes what a sync writes for a session, so a sync
+// whose payload matches the stored one can skip the write
+func sessionContentHash(session *ClaudeSession) (string, error) {
+	metadata := make(map[string]interface{}, len(session.Metadata))
+	for key, value := range session.Metadata {
+		metadata[key] = value
+	}
+	for _, key := range volatileMetadata {
+		delete(metadata, key)
+	}
+	// Map keys marshal sorted, so equal sessions hash the same
+	payload, err := json.Marshal(struct {
+		UserID    *string                `json:"user_id"`
+		Title     string                 `json:"title"`
+		Workspace string                 `json:"workspace"`
+		Messages  []SessionMessage       `json:"messages"`
+		Metadata  map[string]interface{} `json:"metadata"`
+	}{session.UserID, session.Title, session.Workspace, session.Messages, metadata})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash session: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// contentHashReader is implemented by sinks that store the content hash of
+// synced sessions. It returns "" for sessions that have not been synced or
+// were last written before hashes were stored.
+type contentHashReader interface {
+	StoredContentHash(sessionID string) (string, error)
+}
+
+func (p postgresSink) StoredContentHash(sessionID string) (string, error) {
+	return scanContentHash(p.db.QueryRow(`SELECT content_hash FROM claude_sessions WHERE session_id = $1`, sessionID))
+}
+
+func (m mysqlSink) StoredContentHash(sessionID string) (string, error) {
+	return scanContentHash(m.db.QueryRow(`SELECT content_hash FROM claude_sessions WHERE session_id = ?`, sessionID))
+}
+
+func (s *supabaseRestSink) StoredContentHash(sessionID string) (string, error) {
+	var rows []struct {
+		ContentHash *string `json:"content_hash"`
+	}
+	path := "/claude_sessions?select=content_hash&session_id=eq." + url.QueryEscape(sessionID)
+	if err := s.do("GET", path, "", nil, &rows); err != nil {
+		return "", fmt.Errorf("failed to read content hash: %w", err)
+	}
+	if len(rows) == 0 || rows[0].ContentHash == nil {
+		return "", nil
+	}
+	return *rows[0].ContentHash, nil
+}
+
+func scanContentHash(row *sql.Row) (string, error) {
+	var hash sql.NullString
+	if err := row.Scan(&hash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to read content hash: %w", err)
+	}
+	return hash.String, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+	"github.com/urfave/cli/v2"
+)
+
+// defaultContextFiles are the project files snapshotted when
+// sync.context_files is not set
+var defaultContextFiles = []string{"CLAUDE.md", "CLAUDE.local.md", ".claude/CLAUDE.md", ".claude/settings.json", "README.md"}
+
+// maxContextFileSize skips files too large to be instructions
+const maxContextFileSize = 256 << 10
+
+// ContextFile is a project file, such as CLAUDE.md, as it was when a session
+// started. Sessions list theirs in metadata.context_files.
+type ContextFile struct {
+	// Path is relative to the project directory
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+	// Source is file when read from disk, or git when the file changed after
+	// the session started and was read from the last commit before it
+	Source string `json:"source"`
+	Commit string `json:"commit,omitempty"`
+	// Content is stored once per hash, outside the session row
+	Content string `json:"content,omitempty"`
+}
+
+// contextFileStore is implemented by sinks that keep the contents of
+// snapshotted context files
+type contextFileStore interface {
+	StoreContextFiles(files []ContextFile) error
+}
+
+// snapshotContextFiles reads the context files of the session's project as
+// of its first message and links them from its metadata. Their contents are
+// returned for the sink to store. Files linked by an earlier sync are kept
+// as they were: a file changed since may no longer be readable as the
+// session saw it, such as an untracked CLAUDE.local.md.
+func snapshotContextFiles(session *ClaudeSession, patterns []string, redactor *Redactor, linked []ContextFile) []ContextFile {
+	if len(linked) > 0 {
+		session.Metadata["context_files"] = linked
+	}
+	projectPath, _ := session.Metadata["project_path"].(string)
+	start, _, ok := sessionWindow(session.Messages)
+	if projectPath == "" || !ok {
+		return nil
+	}
+	if info, err := os.Stat(projectPath); err != nil || !info.IsDir() {
+		// Synced from another machine
+		return nil
+	}
+	if len(patterns) == 0 {
+		patterns = defaultContextFiles
+	}
+
+	seen := map[string]bool{}
+	for _, file := range linked {
+		seen[filepath.FromSlash(file.Path)] = true
+	}
+	files := []ContextFile{}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(projectPath, filepath.FromSlash(pattern)))
+		// A file deleted since the session may still be in git
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			matches = []string{filepath.Join(projectPath, filepath.FromSlash(pattern))}
+		}
+		for _, path := range matches {
+			rel, err := filepath.Rel(projectPath, path)
+			if err != nil || strings.HasPrefix(rel, "..") || seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			file, ok := readContextFile(projectPath, filepath.ToSlash(rel), start)
+			if !ok {
+				continue
+			}
+			file.Content = string(redactor.RedactRaw([]byte(file.Content)))
+			sum := sha256.Sum256([]byte(file.Content))
+			file.SHA256 = hex.EncodeToString(sum[:])
+			file.Size = len(file.Content)
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	links := append([]ContextFile(nil), linked...)
+	for _, file := range files {
+		file.Content = ""
+		links = append(links, file)
+	}
+	session.Metadata["context_files"] = links
+	return files
+}
+
+// linkedContextFiles returns the context files linked from session metadata,
+// without their contents
+func linkedContextFiles(metadata map[string]interface{}) ([]ContextFile, error) {
+	files := []ContextFile{}
+	links, ok := metadata["context_files"]
+	if !ok {
+		return files, nil
+	}
+	// Metadata loaded from the database holds plain maps
+	data, err := json.Marshal(links)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("failed to decode context files: %w", err)
+	}
+	return files, nil
+}
+
+// readContextFile reads rel as it was at start: from disk if it has not been
+// modified since, otherwise from the last commit before start
+func readContextFile(projectPath, rel string, start time.Time) (ContextFile, bool) {
+	file := ContextFile{Path: rel, Source: "file"}
+	path := filepath.Join(projectPath, filepath.FromSlash(rel))
+	if info, err := os.Stat(path); err == nil && !info.ModTime().After(start) {
+		if info.IsDir() || info.Size() > maxContextFileSize {
+			return file, false
+		}
+		data, err := os.ReadFile(path)
+		file.Content = string(data)
+		return file, err == nil && utf8.Valid(data)
+	}
+
+	out, err := exec.Command("git", "-C", projectPath, "log", "-1", "--format=%H",
+		"--before="+start.Format(time.RFC3339), "--", rel).Output()
+	commit := strings.TrimSpace(string(out))
+	if err != nil || commit == "" {
+		// Untracked, or created after the session started
+		return file, false
+	}
+	data, err := exec.Command("git", "-C", projectPath, "show", commit+":./"+rel).Output()
+	if err != nil || len(data) > maxContextFileSize || !utf8.Valid(data) {
+		return file, false
+	}
+	file.Source, file.Commit, file.Content = "git", commit, string(data)
+	return file, true
+}
+
+// unlinkedContextFile matches rows c of context_files no session links to
+const unlinkedContextFile = `NOT EXISTS (SELECT 1 FROM claude_sessions s, jsonb_array_elements(
+	CASE WHEN jsonb_typeof(s.metadata->'context_files') = 'array' THEN s.metadata->'context_files' ELSE '[]' END
+) f WHERE f->>'sha256' = c.sha256)`
+
+// StoreContextFiles adds the contents of context files not stored yet
+func (p postgresSink) StoreContextFiles(files []ContextFile) error {
+	for _, file := range files {
+		if _, err := p.db.Exec(`
+			INSERT INTO context_files (sha256, content, size)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (sha256) DO NOTHING`,
+			file.SHA256, file.Content, file.Size); err != nil {
+			return fmt.Errorf("failed to store context file %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// sessionContextFiles returns the context files linked from a session, with
+// their contents
+func sessionContextFiles(db *sql.DB, session *ClaudeSession) ([]ContextFile, error) {
+	files, err := linkedContextFiles(session.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return files, nil
+	}
+
+	hashes := make([]string, len(files))
+	for i, file := range files {
+		hashes[i] = file.SHA256
+	}
+	rows, err := db.Query(`SELECT sha256, content FROM context_files WHERE sha256 = ANY($1)`, pq.Array(hashes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load context files: %w", err)
+	}
+	defer rows.Close()
+	contents := map[string]string{}
+	for rows.Next() {
+		var hash, content string
+		if err := rows.Scan(&hash, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan context file: %w", err)
+		}
+		contents[hash] = content
+	}
+	for i := range files {
+		files[i].Content = contents[files[i].SHA256]
+	}
+	return files, rows.Err()
+}
+
+// handleSessionContext serves GET /api/sessions/{id}/context[?path=]
+func (a *apiServer) handleSessionContext(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	files, err := sessionContextFiles(a.db, session)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeJSON(w, http.StatusOK, wholePage(files))
+		return
+	}
+	for _, file := range files {
+		if file.Path == path {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("ETag", `"`+file.SHA256+`"`)
+			w.Write([]byte(file.Content))
+			return
+		}
+	}
+	writeJSONError(w, r, http.StatusNotFound, fmt.Sprintf("No context file %s in session %s", path, session.SessionID), nil)
+}
+
+// sessionsContextCommand implements `sessions context`
+func sessionsContextCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ids, err := sessionArgs(db, c.Args().Slice()[:min(c.NArg(), 1)], "Show")
+	if err != nil {
+		return err
+	}
+	session, err := loadSession(db, ids[0])
+	if err != nil {
+		return err
+	}
+	files, err := sessionContextFiles(db, session)
+	if err != nil {
+		return err
+	}
+
+	if path := c.Args().Get(1); path != "" {
+		for _, file := range files {
+			if file.Path == path {
+				fmt.Print(file.Content)
+				return nil
+			}
+		}
+		return fmt.Errorf("no context file %s in session %s", path, session.SessionID)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "📭 No context files were snapshotted for %s; sync with --snapshot-context to store them\n", session.SessionID)
+		return nil
+	}
+	header := []string{"PATH", "SOURCE", "SIZE", "SHA256"}
+	rows := make([][]string, len(files))
+	for i, file := range files {
+		source := file.Source
+		if file.Commit != "" {
+			source += " " + file.Commit[:min(len(file.Commit), 12)]
+		}
+		rows[i] = []string{file.Path, source, formatSize(file.Size), file.SHA256[:12]}
+	}
+	return writeOutput(os.Stdout, format, files, header, rows)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Continuation detection methods recorded in session_links
+const (
+	// linkLeafUUID means the session's summary points at the predecessor's last message
+	linkLeafUUID = "leaf_uuid"
+	// linkSharedMessages means the session contains the predecessor's messages
+	linkSharedMessages = "shared_messages"
+)
+
+// maxWorkstreamLength bounds how far a workstream is followed in each direction
+const maxWorkstreamLength = 100
+
+// SessionLink references a session before or after another in a workstream
+type SessionLink struct {
+	SessionID string    `json:"session_id"`
+	Title     string    `json:"title"`
+	Method    string    `json:"method"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// sessionAnchors are the message UUIDs used to match a session with the
+// sessions it continues or that continue it
+type sessionAnchors struct {
+	head   string
+	tail   string
+	leaves []string
+	uuids  []string
+}
+
+// continuationAnchors collects the anchors of a session's messages
+func continuationAnchors(messages []SessionMessage) sessionAnchors {
+	var a sessionAnchors
+	for _, msg := range messages {
+		if msg.Type == "summary" && msg.LeafUUID != "" {
+			a.leaves = append(a.leaves, msg.LeafUUID)
+		}
+		if msg.UUID == "" {
+			continue
+		}
+		if a.head == "" {
+			a.head = msg.UUID
+		}
+		a.tail = msg.UUID
+		a.uuids = append(a.uuids, msg.UUID)
+	}
+	return a
+}
+
+// continuationLinker is implemented by sinks that can detect continuations
+type continuationLinker interface {
+	StoreContinuations(sessionID string, messages []SessionMessage) error
+}
+
+// StoreContinuations records a session's anchors and links it to the
+// sessions it continues and to those already synced that continue it.
+// A session continues another when its summary's leafUuid is the other's
+// last message, or when it contains the other's last message after copying
+// its history. Sessions copying the same history share their first message,
+// which narrows the search for successors synced earlier.
+func (p postgresSink) StoreContinuations(sessionID string, messages []SessionMessage) error {
+	a := continuationAnchors(messages)
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM session_anchors WHERE session_id = $1`, sessionID); err != nil {
+		return fmt.Errorf("failed to clear anchors: %w", err)
+	}
+	anchors := map[string][]string{"head": {a.head}, "tail": {a.tail}, "leaf": a.leaves}
+	for kind, uuids := range anchors {
+		for _, uuid := range uuids {
+			if uuid == "" {
+				continue
+			}
+			_, err := tx.Exec(`INSERT INTO session_anchors (session_id, kind, uuid) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+				sessionID, kind, uuid)
+			if err != nil {
+				return fmt.Errorf("failed to store anchors: %w", err)
+			}
+		}
+	}
+
+	// Predecessors are recomputed from scratch since the session may have changed
+	if _, err := tx.Exec(`DELETE FROM session_links WHERE session_id = $1`, sessionID); err != nil {
+		return fmt.Errorf("failed to clear links: %w", err)
+	}
+	link := func(successor, predecessor, method string) error {
+		_, err := tx.Exec(`
+			INSERT INTO session_links (session_id, predecessor_id, method) VALUES ($1, $2, $3)
+			ON CONFLICT (session_id, predecessor_id) DO UPDATE SET method = EXCLUDED.method`,
+			successor, predecessor, method)
+		return err
+	}
+
+	// The session's own last message is left out so that two copies of the
+	// same history with nothing new yet do not continue each other
+	shared := a.uuids
+	if len(shared) > 0 {
+		shared = shared[:len(shared)-1]
+	}
+	predecessors := []struct {
+		method string
+		uuids  []string
+	}{
+		{linkLeafUUID, a.leaves},
+		{linkSharedMessages, shared},
+	}
+	for _, pred := range predecessors {
+		if len(pred.uuids) == 0 {
+			continue
+		}
+		rows, err := tx.Query(`
+			SELECT DISTINCT session_id FROM session_anchors
+			WHERE kind = 'tail' AND uuid = ANY($1) AND session_id <> $2`, pq.Array(pred.uuids), sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to find predecessors: %w", err)
+		}
+		ids, err := scanIDs(rows)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := link(sessionID, id, pred.method); err != nil {
+				return fmt.Errorf("failed to store link: %w", err)
+			}
+		}
+	}
+
+	// Successors synced before this session point at its last message
+	if a.tail != "" {
+		rows, err := tx.Query(`
+			SELECT DISTINCT session_id FROM session_anchors
+			WHERE kind = 'leaf' AND uuid = $1 AND session_id <> $2`, a.tail, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to find successors: %w", err)
+		}
+		ids, err := scanIDs(rows)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := link(id, sessionID, linkLeafUUID); err != nil {
+				return fmt.Errorf("failed to store link: %w", err)
+			}
+		}
+
+		rows, err = tx.Query(`
+			SELECT s.session_id FROM claude_sessions s
+			JOIN session_anchors h ON h.session_id = s.session_id AND h.kind = 'head' AND h.uuid = $1
+			WHERE s.session_id <> $2 AND s.messages @> jsonb_build_array(jsonb_build_object('uuid', $3::text))
+			  AND NOT EXISTS (SELECT 1 FROM session_anchors t WHERE t.session_id = s.session_id AND t.kind = 'tail' AND t.uuid = $3)`,
+			a.head, sessionID, a.tail)
+		if err != nil {
+			return fmt.Errorf("failed to find successors: %w", err)
+		}
+		if ids, err = scanIDs(rows); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := link(id, sessionID, linkSharedMessages); err != nil {
+				return fmt.Errorf("failed to store link: %w", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// scanIDs reads a single text column
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// sessionLinks returns the live sessions a session continues and those that continue it
+func sessionLinks(db *sql.DB, sessionID string) (previous, next []SessionLink, err error) {
+	query := func(join, where string) ([]SessionLink, error) {
+		rows, err := db.Query(`
+			SELECT s.session_id, s.title, l.method, s.updated_at
+			FROM session_links l
+			JOIN claude_sessions s ON s.session_id = l.`+join+` AND s.deleted_at IS NULL
+			WHERE l.`+where+` = $1
+			ORDER BY s.created_at`, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query session links: %w", err)
+		}
+		defer rows.Close()
+		links := []SessionLink{}
+		for rows.Next() {
+			var l SessionLink
+			if err := rows.Scan(&l.SessionID, &l.Title, &l.Method, &l.UpdatedAt); err != nil {
+				return nil, err
+			}
+			links = append(links, l)
+		}
+		return links, rows.Err()
+	}
+	if previous, err = query("predecessor_id", "session_id"); err != nil {
+		return nil, nil, err
+	}
+	if next, err = query("session_id", "predecessor_id"); err != nil {
+		return nil, nil, err
+	}
+	return previous, next, nil
+}
+
+// handleSessionWorkstream serves GET /api/sessions/{id}/workstream, the chain
+// of sessions the session belongs to, oldest first. Where a session was
+// resumed more than once, every branch is included.
+func (a *apiServer) handleSessionWorkstream(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if _, err := loadSession(a.db, sessionID); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+
+	rows, err := a.db.Query(`
+		WITH RECURSIVE
+		back(session_id, depth) AS (
+			SELECT $1::text, 0
+			UNION
+			SELECT l.predecessor_id, b.depth + 1 FROM session_links l JOIN back b ON l.session_id = b.session_id
+			WHERE b.depth < $2
+		),
+		forward(session_id, depth) AS (
+			SELECT session_id, 0 FROM back
+			UNION
+			SELECT l.session_id, f.depth + 1 FROM session_links l JOIN forward f ON l.predecessor_id = f.session_id
+			WHERE f.depth < $2
+		)
+		SELECT s.session_id, s.title, s.created_at, s.updated_at, jsonb_array_length(s.messages),
+		       s.started_at, s.ended_at, s.duration_ms
+		FROM claude_sessions s
+		WHERE s.session_id IN (SELECT session_id FROM forward) AND s.deleted_at IS NULL
+		ORDER BY s.created_at, s.session_id`, sessionID, maxWorkstreamLength)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query workstream: %v", err), nil)
+		return
+	}
+	defer rows.Close()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var s SessionSummary
+		if err := rows.Scan(&s.SessionID, &s.Title, &s.CreatedAt, &s.UpdatedAt, &s.Messages,
+			&s.StartedAt, &s.EndedAt, &s.DurationMS); err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		sessions = append(sessions, s)
+	}
+	writeJSON(w, http.StatusOK, wholePage(sessions))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// daemonCommand runs the sync watcher and the development server in one process
+func daemonCommand(c *cli.Context) error {
+	if err := resolveBuildConfig(c); err != nil {
+		return err
+	}
+	if err := enableOffline(c); err != nil {
+		return err
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := InitializeDatabase(config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	sessionSync, err := newConfiguredSync(c, config, db)
+	if err != nil {
+		return err
+	}
+	if sessionSync.mirrors, err = startMirrors(config.Sinks, config); err != nil {
+		return err
+	}
+	defer sessionSync.mirrors.close()
+
+	var logs *logBuffer
+	if c.Bool("tui") {
+		// Keep log output from scribbling over the dashboard
+		logs = newLogBuffer(8)
+		log.SetOutput(logs)
+	}
+
+	port := serverPort(c)
+	limiter, err := rateLimiterFromFlags(c)
+	if err != nil {
+		return err
+	}
+	api := &apiServer{db: db, config: config, limiter: limiter}
+	if err := requirePostgres(db); err != nil {
+		log.Printf("Database API disabled: %v", err)
+		api.db = nil
+	}
+	server := &http.Server{Addr: ":" + port, Handler: createHTTPServer(api)}
+	if stopCache, err := startContentCache(workspace.Server.Cache); err != nil {
+		log.Printf("Cache disabled: %v", err)
+	} else {
+		defer stopCache()
+	}
+	if c.Bool("typecheck") || workspace.Server.Typecheck {
+		if stopTypecheck, err := startTypecheck(); err != nil {
+			log.Printf("Type checking disabled: %v", err)
+		} else {
+			defer stopTypecheck()
+		}
+	}
+	if stopBundle, err := startAppBundle(); err != nil {
+		log.Printf("App bundle disabled: %v", err)
+	} else {
+		defer stopBundle()
+	}
+	errs := make(chan error, 2)
+
+	if stopReload, err := watchConfig(c, config, api, sessionSync); err != nil {
+		log.Printf("Config reload disabled: %v", err)
+	} else {
+		defer stopReload()
+	}
+
+	if closeControl, err := startControlSocket("http://localhost:" + port); err != nil {
+		log.Printf("Preview control socket disabled: %v", err)
+	} else {
+		defer closeControl()
+	}
+
+	go func() {
+		log.Printf("Development server listening on http://localhost:%s", port)
+		errs <- server.ListenAndServe()
+	}()
+	go func() {
+		errs <- sessionSync.Start()
+	}()
+
+	if !c.Bool("tui") {
+		return <-errs
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	dashboard := &dashboard{db: db, logs: logs, port: port, out: os.Stdout}
+	return dashboard.Run(signals, errs)
+}
+
+// dashboard renders daemon health to the terminal, redrawing in place
+type dashboard struct {
+	db   *sql.DB
+	logs *logBuffer
+	port string
+	out  io.Writer
+
+	pingLatency time.Duration
+	pingErr     error
+	lastPing    time.Time
+}
+
+// Run redraws the dashboard every second until a signal or a fatal error arrives
+func (d *dashboard) Run(signals <-chan os.Signal, errs <-chan error) error {
+	// Hide the cursor while drawing and restore it on exit
+	fmt.Fprint(d.out, "\033[?25l")
+	defer fmt.Fprint(d.out, "\033[?25h\n")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	d.draw()
+	for {
+		select {
+		case <-signals:
+			return nil
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+			d.draw()
+		}
+	}
+}
+
+// draw renders a single frame of the dashboard
+func (d *dashboard) draw() {
+	if time.Since(d.lastPing) > 5*time.Second {
+		start := time.Now()
+		d.pingErr = d.db.Ping()
+		d.pingLatency = time.Since(start)
+		d.lastPing = time.Now()
+	}
+
+	s := stats.Snapshot()
+	var b strings.Builder
+
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "claudemd daemon  ·  http://localhost:%s  ·  up %s\n", d.port, time.Since(s.StartedAt).Truncate(time.Second))
+	b.WriteString(strings.Repeat("─", 72) + "\n")
+
+	fmt.Fprintf(&b, "Sync     queue %-5d synced %-6d errors %-6d dropped %-6d unchanged %d\n", s.QueueDepth, s.SyncCount, s.SyncErrors, s.QueueDropped, s.SyncUnchanged)
+	fmt.Fprintf(&b, "Builds   total %-5d failed %-6d\n", s.BuildCount, s.BuildErrors)
+	if d.pingErr != nil {
+		fmt.Fprintf(&b, "Database ping failed: %v\n", d.pingErr)
+	} else {
+		fmt.Fprintf(&b, "Database ping %-8s write last %-8s avg %s\n",
+			d.pingLatency.Round(time.Millisecond), s.DBLatency.Round(time.Millisecond), s.DBLatencyAvg.Round(time.Millisecond))
+	}
+
+	for _, sink := range mirrorStatuses() {
+		state := "ok"
+		if sink.Failures > 0 {
+			state = fmt.Sprintf("failing (%d attempts): %s", sink.Failures, truncateTitle(sink.LastError, 40))
+		}
+		fmt.Fprintf(&b, "Sink     %-12s pending %-5d %s\n", sink.Name, sink.Pending, state)
+	}
+
+	b.WriteString("\nRecent sessions\n")
+	if len(s.RecentSyncs) == 0 {
+		b.WriteString("  (none yet)\n")
+	}
+	for i := len(s.RecentSyncs) - 1; i >= 0; i-- {
+		r := s.RecentSyncs[i]
+		fmt.Fprintf(&b, "  %s  %-40s %5d msgs\n", r.At.Format("15:04:05"), r.SessionID, r.Messages)
+	}
+
+	b.WriteString("\nRecent builds\n")
+	if len(s.RecentBuilds) == 0 {
+		b.WriteString("  (none yet)\n")
+	}
+	for i := len(s.RecentBuilds) - 1; i >= 0; i-- {
+		r := s.RecentBuilds[i]
+		status := "ok"
+		if r.Failed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "  %s  %-4s %-40s %s\n", r.At.Format("15:04:05"), status, r.Path, r.Duration.Round(time.Millisecond))
+	}
+
+	b.WriteString("\nLog\n")
+	for _, line := range d.logs.Lines() {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+
+	io.WriteString(d.out, b.String())
+}
+
+// logBuffer is an io.Writer that keeps the last few log lines in memory
+type logBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	limit int
+}
+
+func newLogBuffer(limit int) *logBuffer {
+	return &logBuffer{limit: limit}
+}
+
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte{'\n'}) {
+		l.lines = append(l.lines, string(line))
+	}
+	if len(l.lines) > l.limit {
+		l.lines = l.lines[len(l.lines)-l.limit:]
+	}
+	return len(p), nil
+}
+
+// Lines returns a copy of the buffered log lines
+func (l *logBuffer) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DatabaseConfig tunes the connection pool. Durations are Go duration
+// strings such as "30s" or "5m"; empty fields keep the driver defaults.
+type DatabaseConfig struct {
+	MaxOpenConns    int    `json:"max_open_conns,omitempty"`
+	MaxIdleConns    int    `json:"max_idle_conns,omitempty"`
+	ConnMaxLifetime string `json:"conn_max_lifetime,omitempty"`
+	ConnMaxIdleTime string `json:"conn_max_idle_time,omitempty"`
+	// StatementTimeout cancels any single statement that runs longer
+	StatementTimeout string `json:"statement_timeout,omitempty"`
+	// Pgbouncer connects without prepared statements or startup parameters,
+	// which pgbouncer in transaction pooling mode does not support. It is
+	// implied by supabase.pool_mode transaction.
+	Pgbouncer bool `json:"pgbouncer,omitempty"`
+}
+
+// statementTimeout bounds the upsert of a session, which also holds behind
+// pgbouncer where the server side timeout cannot be set. Zero means none.
+var statementTimeout time.Duration
+
+// validate checks the pool settings
+func (d DatabaseConfig) validate() error {
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		return fmt.Errorf("database.max_open_conns and database.max_idle_conns must not be negative")
+	}
+	for name, value := range map[string]string{
+		"conn_max_lifetime":  d.ConnMaxLifetime,
+		"conn_max_idle_time": d.ConnMaxIdleTime,
+		"statement_timeout":  d.StatementTimeout,
+	} {
+		if _, err := parseDatabaseDuration(value); err != nil {
+			return fmt.Errorf("invalid database.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// parseDatabaseDuration parses an optional, non-negative duration
+func parseDatabaseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", value)
+	}
+	return d, nil
+}
+
+// usesPgbouncer reports whether connections go through transaction pooling
+func (c *Config) usesPgbouncer() bool {
+	return c.Database.Pgbouncer || (c.DatabaseURL == "" && c.Supabase.PoolMode == "transaction")
+}
+
+// configurePool applies the pool limits to an open database
+func configurePool(db *sql.DB, d DatabaseConfig) {
+	if d.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(d.MaxOpenConns)
+	}
+	if d.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(d.MaxIdleConns)
+	}
+	if lifetime, _ := parseDatabaseDuration(d.ConnMaxLifetime); lifetime > 0 {
+		db.SetConnMaxLifetime(lifetime)
+	}
+	if idle, _ := parseDatabaseDuration(d.ConnMaxIdleTime); idle > 0 {
+		db.SetConnMaxIdleTime(idle)
+	}
+}
+
+// tuneDSN adds the statement timeout and pgbouncer settings to a driver DSN
+func tuneDSN(driver storageDriver, dsn string, timeout time.Duration, pgbouncer bool) (string, error) {
+	if driver.Name() == "mysql" {
+		return tuneMySQLDSN(dsn, timeout)
+	}
+	params := map[string]string{}
+	// Each query is sent as one unnamed Parse, Bind and Execute, so it never
+	// depends on a statement prepared on another server connection
+	if pgbouncer {
+		params["binary_parameters"] = "yes"
+	}
+	// pgbouncer rejects unknown startup parameters
+	if timeout > 0 && !pgbouncer {
+		params["statement_timeout"] = fmt.Sprint(timeout.Milliseconds())
+	}
+	return setPostgresParams(dsn, params)
+}
+
+// setPostgresParams sets connection parameters in a postgres:// URL or a
+// key=value connection string, keeping any the user already set
+func setPostgresParams(dsn string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return dsn, nil
+	}
+	if !strings.Contains(dsn, "://") {
+		for key, value := range params {
+			if !strings.Contains(dsn, key+"=") {
+				dsn = strings.TrimSpace(dsn + " " + key + "=" + value)
+			}
+		}
+		return dsn, nil
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid database_url: %w", err)
+	}
+	query := u.Query()
+	for key, value := range params {
+		if query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// tuneMySQLDSN sets the connection's read and write timeouts. MySQL and
+// MariaDB name their statement time limits differently, so these are what
+// covers every statement on both.
+func tuneMySQLDSN(dsn string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return dsn, nil
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid database_url: %w", err)
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = timeout
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = timeout
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// statementContext returns a context that ends after the statement timeout
+func statementContext() (context.Context, context.CancelFunc) {
+	if statementTimeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), statementTimeout)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+// sessionChildTables hold rows keyed by session_id that must be removed when a
+// session is purged. Tables added for per-session data should be listed here.
+var sessionChildTables = []string{
+	"message_annotations",
+	"claude_session_raw",
+	"session_todos",
+	"session_files",
+	"session_uploads",
+	"session_anchors",
+	"session_links",
+	"session_outlines",
+	"session_prompts",
+	"claude_source_files",
+}
+
+// deleteSessions soft deletes the matching sessions, or removes them and their
+// child rows entirely when purge is set. It returns the number of sessions affected.
+func deleteSessions(db *sql.DB, filter SessionFilter, purge bool) (int, error) {
+	if !purge {
+		where, args := filter.where()
+		result, err := db.Exec(`UPDATE claude_sessions SET deleted_at = NOW(), updated_at = NOW() WHERE `+where, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete sessions: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		return int(n), nil
+	}
+
+	// Purging also removes sessions that were already soft deleted
+	filter.IncludeDeleted = true
+	where, args := filter.where()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT session_id FROM claude_sessions WHERE `+where+` FOR UPDATE`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select sessions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, table := range sessionChildTables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE session_id = ANY($1)`, pq.Array(ids)); err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+	}
+	// Links also reference purged sessions as predecessors
+	if _, err := tx.Exec(`DELETE FROM session_links WHERE predecessor_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, fmt.Errorf("failed to purge session_links: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM claude_sessions WHERE session_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, fmt.Errorf("failed to purge sessions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return len(ids), nil
+}
+
+// handleDeleteSession serves DELETE /api/sessions/{id}[?purge=true]
+func (a *apiServer) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	purge := r.URL.Query().Get("purge") == "true"
+
+	n, err := deleteSessions(a.db, SessionFilter{IDs: []string{id}}, purge)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	if n == 0 {
+		a.writeLoadError(w, r, fmt.Errorf("%w: %s", errSessionNotFound, id))
+		return
+	}
+	a.audit(r, deleteAction(purge), "session", id, nil, map[string]interface{}{"purged": purge})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": n, "purged": purge})
+}
+
+// handleBulkDeleteSessions serves DELETE /api/sessions with id, project, q, text, tag,
+// before and after filters. At least one filter is required.
+func (a *apiServer) handleBulkDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	filter, err := sessionFilterFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if filter.empty() {
+		writeJSONError(w, r, http.StatusBadRequest, "At least one filter is required for bulk delete", nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	purge := r.URL.Query().Get("purge") == "true"
+
+	n, err := deleteSessions(a.db, filter, purge)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	a.audit(r, deleteAction(purge)+".bulk", "session", "", nil, map[string]interface{}{"filter": r.URL.Query(), "deleted": n})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": n, "purged": purge})
+}
+
+// deleteAction names a delete in the audit log
+func deleteAction(purge bool) string {
+	if purge {
+		return "session.purge"
+	}
+	return "session.delete"
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// rebuildDebounce coalesces the bursts of events editors produce on save
+const rebuildDebounce = 100 * time.Millisecond
+
+// buildStatus is streamed to the error overlay after every rebuild, and
+// again when the background type check of its files changes
+type buildStatus struct {
+	OK     bool     `json:"ok"`
+	Errors []string `json:"errors,omitempty"`
+	// TypeErrors are tsc diagnostics of the component's files; they do not
+	// stop it from rendering
+	TypeErrors []string `json:"type_errors,omitempty"`
+}
+
+// buildForWatch builds a component and returns its outcome plus the local
+// files it was built from, taken from the esbuild metafile
+func buildForWatch(ctx context.Context, srcPath string) (buildStatus, []string) {
+	// Every open tab rebuilds on save, so share the build between them
+	result, _, err := moduleBuilds.Build(ctx, srcPath)
+	if err != nil {
+		return buildStatus{Errors: []string{err.Error()}}, []string{srcPath}
+	}
+	status := buildStatus{OK: len(result.Errors) == 0, Errors: formatBuildErrors(result.Errors)}
+	return status, buildInputs(srcPath, result.Metafile)
+}
+
+// buildInputs lists srcPath and the local files in a build's metafile
+func buildInputs(srcPath, metafile string) []string {
+	inputs := []string{srcPath}
+	var meta struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+	}
+	if json.Unmarshal([]byte(metafile), &meta) == nil {
+		for input := range meta.Inputs {
+			// Skip the stdin entry, virtual modules and dependencies
+			if strings.HasPrefix(input, "<") || strings.Contains(input, ":") || strings.Contains(input, "node_modules") {
+				continue
+			}
+			inputs = append(inputs, filepath.Clean(input))
+		}
+	}
+	return inputs
+}
+
+// handleRenderWatch serves GET /api/render/watch?path=<component>, an SSE
+// stream that rebuilds the component whenever one of its source files changes
+// and reports the type errors of those files as tsc finds them
+func handleRenderWatch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, r, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+
+	cleanPath := filepath.Clean(r.URL.Query().Get("path"))
+	if cleanPath == "." || strings.Contains(cleanPath, "..") || filepath.IsAbs(cleanPath) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid path", nil)
+		return
+	}
+	srcPath := filepath.Join(".", cleanPath)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to create watcher: %v", err), nil)
+		return
+	}
+	defer watcher.Close()
+
+	// Watch directories rather than files so atomic saves are observed
+	watched := make(map[string]bool)
+	inputs := make(map[string]bool)
+	track := func(status buildStatus, files []string) {
+		// A failed build has no metafile, so keep watching the previous inputs
+		if status.OK {
+			clear(inputs)
+		}
+		for _, file := range files {
+			inputs[file] = true
+			dir := filepath.Dir(file)
+			if !watched[dir] {
+				if err := watcher.Add(dir); err == nil {
+					watched[dir] = true
+				}
+			}
+		}
+	}
+
+	status, files := buildForWatch(r.Context(), srcPath)
+	track(status, files)
+	status.TypeErrors = typecheck.errorsFor(inputs)
+	events, unsubscribe := devEvents.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(status buildStatus) {
+		payload, _ := json.Marshal(status)
+		fmt.Fprintf(w, "event: build\ndata: %s\n\n", payload)
+		flusher.Flush()
+	}
+	send(status)
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if inputs[filepath.Clean(event.Name)] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce.Reset(rebuildDebounce)
+			}
+		case <-debounce.C:
+			start := time.Now()
+			var files []string
+			status, files = buildForWatch(r.Context(), srcPath)
+			stats.RecordBuild(srcPath, time.Since(start), !status.OK)
+			log.Printf("[trace=%s] rebuilt %s after change in %s (ok=%t)", traceIDFromContext(r.Context()), srcPath, time.Since(start), status.OK)
+			track(status, files)
+			status.TypeErrors = typecheck.errorsFor(inputs)
+			send(status)
+		case event := <-events:
+			if event.Type != eventTypecheck {
+				continue
+			}
+			if typeErrors := typecheck.errorsFor(inputs); !slices.Equal(typeErrors, status.TypeErrors) {
+				status.TypeErrors = typeErrors
+				send(status)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Render watcher error: %v", err)
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// devOverlayScript returns the error overlay shown over rendered components.
+// It displays build errors and uncaught runtime errors in a dismissible panel
+// and, when watchPath is set, reloads the page once the component builds again
+// and shows type errors of a component that builds until they are fixed.
+func devOverlayScript(watchPath string, buildErrors []string) string {
+	initial, _ := json.Marshal(buildErrors)
+	watch, _ := json.Marshal(watchPath)
+	return `
+    <style>
+        #claudemd-overlay { position: fixed; inset: 0; z-index: 2147483647; background: rgba(17, 24, 39, 0.92); color: #f9fafb; font-family: ui-monospace, monospace; overflow: auto; padding: 2rem; }
+        #claudemd-overlay h2 { color: #f87171; margin: 0 0 1rem; font-size: 18px; }
+        #claudemd-overlay pre { background: #1f2937; border-left: 4px solid #f87171; padding: 1rem; white-space: pre-wrap; word-break: break-word; font-size: 13px; }
+        #claudemd-overlay button { position: absolute; top: 1rem; right: 1rem; background: none; border: 1px solid #6b7280; color: #f9fafb; border-radius: 4px; cursor: pointer; padding: 0.25rem 0.75rem; }
+        #claudemd-overlay .hint { color: #9ca3af; font-size: 12px; }
+    </style>
+    <script>
+    (function () {
+        var overlay = null;
+        var typeOverlay = false;
+        function hide() { if (overlay) { overlay.remove(); overlay = null; } typeOverlay = false; }
+        function show(title, errors, hintText) {
+            hide();
+            overlay = document.createElement('div');
+            overlay.id = 'claudemd-overlay';
+            var close = document.createElement('button');
+            close.textContent = 'Dismiss (Esc)';
+            close.onclick = hide;
+            var heading = document.createElement('h2');
+            heading.textContent = title;
+            overlay.appendChild(close);
+            overlay.appendChild(heading);
+            errors.forEach(function (text) {
+                var pre = document.createElement('pre');
+                pre.textContent = text;
+                overlay.appendChild(pre);
+            });
+            var hint = document.createElement('p');
+            hint.className = 'hint';
+            hint.textContent = hintText || 'The page reloads automatically when the file is fixed.';
+            overlay.appendChild(hint);
+            (document.body || document.documentElement).appendChild(overlay);
+        }
+        window.__claudemdOverlay = { show: show, hide: hide };
+        document.addEventListener('keydown', function (e) { if (e.key === 'Escape') hide(); });
+        window.addEventListener('error', function (e) {
+            show('Runtime error', [e.error && e.error.stack ? e.error.stack : String(e.message)]);
+        });
+        window.addEventListener('unhandledrejection', function (e) {
+            show('Unhandled promise rejection', [e.reason && e.reason.stack ? e.reason.stack : String(e.reason)]);
+        });
+
+        var initialErrors = ` + string(initial) + `;
+        var broken = initialErrors && initialErrors.length > 0;
+        if (broken) {
+            document.addEventListener('DOMContentLoaded', function () { show('Build failed', initialErrors); });
+        }
+
+        var watchPath = ` + string(watch) + `;
+        if (!watchPath || !window.EventSource) return;
+        var first = true;
+        var source = new EventSource('/api/render/watch?path=' + encodeURIComponent(watchPath));
+        // Type errors do not stop the component from rendering, so the panel
+        // only comes and goes with them and never reloads the page
+        function showTypes(status) {
+            var errors = status.type_errors || [];
+            if (errors.length > 0) {
+                if (!overlay || typeOverlay) {
+                    show('Type errors', errors, 'The component still renders; this panel closes when tsc reports no errors.');
+                    typeOverlay = true;
+                }
+            } else if (typeOverlay) {
+                hide();
+            }
+        }
+        source.addEventListener('build', function (e) {
+            var status = JSON.parse(e.data);
+            // The first event reports the build the page was served with
+            if (first) { first = false; if (status.ok === !broken) { if (status.ok) showTypes(status); return; } }
+            if (status.ok && broken) { location.reload(); return; }
+            if (status.ok) { showTypes(status); return; }
+            broken = true;
+            show('Build failed', (status.errors || []).concat(status.type_errors || []));
+        });
+    })();
+    </script>`
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// storageDriver is a database sessions can be synced into. PostgreSQL is
+// the full backend; MySQL and MariaDB store synced sessions and the tables
+// derived from them, while the API and the query commands need PostgreSQL,
+// whose JSONB operators their queries use. SQLite is not supported: there
+// is no driver for it yet, and serving from it would need those queries
+// rewritten for each dialect.
+type storageDriver interface {
+	// Name is the database/sql driver name
+	Name() string
+	// MigrationsDir is the embedded directory holding the driver's migrations
+	MigrationsDir() string
+	// MigrationsTableSQL creates the schema_migrations table
+	MigrationsTableSQL() string
+	// RecordMigrationSQL inserts a (version, name) row into schema_migrations
+	RecordMigrationSQL() string
+	// Lock and Unlock keep two processes from migrating at once. Both run
+	// on the same pinned connection.
+	Lock(ctx context.Context, conn migrationConn) error
+	Unlock(ctx context.Context, conn migrationConn)
+	// Sink writes synced sessions to db
+	Sink(db *sql.DB) sessionSink
+}
+
+// storageDriverFor picks the driver for a database URL by its scheme and
+// returns the connection string to open it with. Anything that is not a
+// known scheme is handed to PostgreSQL, which also accepts key=value DSNs.
+func storageDriverFor(databaseURL string) (storageDriver, string, error) {
+	scheme, _, ok := strings.Cut(databaseURL, "://")
+	if !ok {
+		return postgresDriver{}, databaseURL, nil
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return postgresDriver{}, databaseURL, nil
+	case "mysql", "mariadb":
+		dsn, err := mysqlDSN(databaseURL)
+		return mysqlDriver{}, dsn, err
+	case "sqlite", "sqlite3", "file":
+		return nil, "", fmt.Errorf("SQLite databases are not supported; use postgres:// or mysql://")
+	default:
+		return nil, "", fmt.Errorf("unsupported database_url scheme %q (use postgres:// or mysql://)", scheme)
+	}
+}
+
+// storageDriverOf returns the driver behind an open database
+func storageDriverOf(db *sql.DB) storageDriver {
+	if db != nil {
+		if _, ok := db.Driver().(*mysql.MySQLDriver); ok {
+			return mysqlDriver{}
+		}
+	}
+	return postgresDriver{}
+}
+
+// requirePostgres fails for databases that only receive synced sessions
+func requirePostgres(db *sql.DB) error {
+	if name := storageDriverOf(db).Name(); name != "postgres" {
+		return fmt.Errorf("this needs a PostgreSQL database; %s databases only receive synced sessions", name)
+	}
+	return nil
+}
+
+// postgresDriver is the default storage driver
+type postgresDriver struct{}
+
+func (postgresDriver) Name() string          { return "postgres" }
+func (postgresDriver) MigrationsDir() string { return "schema/migrations" }
+
+func (postgresDriver) MigrationsTableSQL() string {
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`
+}
+
+func (postgresDriver) RecordMigrationSQL() string {
+	return `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+}
+
+// Lock takes an advisory lock, which is held by the connection
+func (postgresDriver) Lock(ctx context.Context, conn migrationConn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID)
+	return err
+}
+
+func (postgresDriver) Unlock(ctx context.Context, conn migrationConn) {
+	conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+}
+
+func (postgresDriver) Sink(db *sql.DB) sessionSink {
+	return postgresSink{db: db}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/urfave/cli/v2"
+)
+
+// archiveDir is where du --archive moves old transcripts, relative to
+// ~/.claude. Archived files keep their path below the projects directory so
+// the project of a session can still be read from its source_file.
+const archiveDir = "archive"
+
+// toolResultMarker identifies transcript lines carrying tool results
+var toolResultMarker = []byte(`"type":"tool_result"`)
+
+// UsageEntry is the size of a directory or table
+type UsageEntry struct {
+	Name  string `json:"name"`
+	Bytes int    `json:"bytes"`
+	Files int    `json:"files,omitempty"`
+}
+
+// SessionUsage is the size of one transcript and how much of it is tool
+// results. RowBytes is the size of its stored row, when it was synced.
+type SessionUsage struct {
+	SessionID       string    `json:"session_id"`
+	Project         string    `json:"project"`
+	Path            string    `json:"path"`
+	Bytes           int       `json:"bytes"`
+	ToolResultBytes int       `json:"tool_result_bytes"`
+	RowBytes        int       `json:"row_bytes,omitempty"`
+	ModifiedAt      time.Time `json:"modified_at"`
+}
+
+// DatabaseUsage is the size of the database tables and the largest rows
+type DatabaseUsage struct {
+	TotalBytes int          `json:"total_bytes"`
+	Tables     []UsageEntry `json:"tables"`
+	Largest    []UsageEntry `json:"largest_sessions"`
+}
+
+// ArchiveSuggestion counts the transcripts old enough to archive
+type ArchiveSuggestion struct {
+	Days     int `json:"days"`
+	Sessions int `json:"sessions"`
+	Bytes    int `json:"bytes"`
+}
+
+// DiskUsage is the report of claudemd du
+type DiskUsage struct {
+	ClaudeDir  string            `json:"claude_dir"`
+	TotalBytes int               `json:"total_bytes"`
+	Dirs       []UsageEntry      `json:"dirs"`
+	Projects   []UsageEntry      `json:"projects"`
+	Largest    []SessionUsage    `json:"largest_sessions"`
+	ToolHeavy  []SessionUsage    `json:"tool_heavy_sessions"`
+	Database   *DatabaseUsage    `json:"database,omitempty"`
+	Archivable ArchiveSuggestion `json:"archivable"`
+
+	// sessions are every transcript in scope, for archiving
+	sessions []SessionUsage
+}
+
+// toolResultBytes counts the bytes of the lines of a transcript that carry
+// tool results
+func toolResultBytes(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	total := 0
+	reader := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if bytes.Contains(line, toolResultMarker) {
+			total += len(line)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// measureClaudeDir walks ~/.claude, sizing its top level directories and,
+// within projects, each project and transcript. project limits the
+// transcripts to one project directory.
+func measureClaudeDir(claudeDir, project string) (*DiskUsage, error) {
+	usage := &DiskUsage{ClaudeDir: claudeDir}
+	dirs := make(map[string]*UsageEntry)
+	projects := make(map[string]*UsageEntry)
+
+	err := filepath.WalkDir(claudeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(claudeDir, path)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		size := int(info.Size())
+		usage.TotalBytes += size
+
+		top := parts[0]
+		if len(parts) == 1 {
+			top = "(files)"
+		}
+		if dirs[top] == nil {
+			dirs[top] = &UsageEntry{Name: top}
+		}
+		dirs[top].Bytes += size
+		dirs[top].Files++
+
+		if top != "projects" || len(parts) < 3 || (project != "" && parts[1] != project) {
+			return nil
+		}
+		if projects[parts[1]] == nil {
+			projects[parts[1]] = &UsageEntry{Name: parts[1]}
+		}
+		projects[parts[1]].Bytes += size
+		projects[parts[1]].Files++
+		if len(parts) != 3 || !strings.HasSuffix(parts[2], ".jsonl") {
+			return nil
+		}
+		tools, err := toolResultBytes(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		usage.sessions = append(usage.sessions, SessionUsage{SessionID: strings.TrimSuffix(parts[2], ".jsonl"), Project: parts[1],
+			Path: path, Bytes: size, ToolResultBytes: tools, ModifiedAt: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure %s: %w", claudeDir, err)
+	}
+
+	usage.Dirs = sortedUsage(dirs)
+	usage.Projects = sortedUsage(projects)
+	return usage, nil
+}
+
+// sortedUsage lists entries largest first
+func sortedUsage(entries map[string]*UsageEntry) []UsageEntry {
+	list := make([]UsageEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, *entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Bytes != list[j].Bytes {
+			return list[i].Bytes > list[j].Bytes
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// topSessions returns the n transcripts that sort first by less
+func topSessions(sessions []SessionUsage, n int, less func(a, b SessionUsage) bool) []SessionUsage {
+	sorted := append([]SessionUsage(nil), sessions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// measureDatabase sizes the tables of the database and its largest session
+// rows, and records the row size of the given transcripts
+func measureDatabase(db *sql.DB, filter SessionFilter, top int, sessions []SessionUsage) (*DatabaseUsage, error) {
+	usage := &DatabaseUsage{}
+	rows, err := db.Query(`
+		SELECT relname, pg_total_relation_size(relid)
+		FROM pg_statio_user_tables
+		ORDER BY 2 DESC, 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to size tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table UsageEntry
+		if err := rows.Scan(&table.Name, &table.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan table size: %w", err)
+		}
+		usage.TotalBytes += table.Bytes
+		usage.Tables = append(usage.Tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const rowSize = `pg_column_size(messages) + COALESCE(pg_column_size(metadata), 0)`
+	where, args := filter.where()
+	largest, err := db.Query(`SELECT session_id, `+rowSize+` FROM claude_sessions WHERE `+where+
+		fmt.Sprintf(` ORDER BY 2 DESC LIMIT $%d`, len(args)+1), append(args, top)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to size sessions: %w", err)
+	}
+	defer largest.Close()
+	for largest.Next() {
+		var row UsageEntry
+		if err := largest.Scan(&row.Name, &row.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan session size: %w", err)
+		}
+		usage.Largest = append(usage.Largest, row)
+	}
+	if err := largest.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.SessionID
+	}
+	sized, err := db.Query(`SELECT session_id, `+rowSize+` FROM claude_sessions WHERE session_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to size sessions: %w", err)
+	}
+	defer sized.Close()
+	rowBytes := make(map[string]int)
+	for sized.Next() {
+		var id string
+		var n int
+		if err := sized.Scan(&id, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan session size: %w", err)
+		}
+		rowBytes[id] = n
+	}
+	for i := range sessions {
+		sessions[i].RowBytes = rowBytes[sessions[i].SessionID]
+	}
+	return usage, sized.Err()
+}
+
+// archivePath is where a transcript under ~/.claude/projects is archived
+func archivePath(claudeDir, path string) (string, error) {
+	rel, err := filepath.Rel(claudeDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is not under %s", path, claudeDir)
+	}
+	return filepath.Join(claudeDir, archiveDir, rel+".gz"), nil
+}
+
+// readArchivedFile inflates a transcript archived by du --archive
+func readArchivedFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", path, err)
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// archiveSession compresses a transcript into the archive and points its
+// session at the archived copy before removing the original, so a running
+// sync does not see the removal as a deleted session. Transcripts that were
+// never synced are left in place. It returns the size of the archived copy,
+// or 0 when the file was left alone.
+func archiveSession(db *sql.DB, claudeDir string, s SessionUsage) (int, error) {
+	dest, err := archivePath(claudeDir, s.Path)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", s.Path, err)
+	}
+	compressed, err := compressSnapshot(data)
+	if err != nil {
+		return 0, err
+	}
+	sum := sha256.Sum256(data)
+	if _, err := decompressSnapshot(compressed, hex.EncodeToString(sum[:])); err != nil {
+		return 0, fmt.Errorf("failed to verify archive of %s: %w", s.Path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, compressed, 0o644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	// A transcript written to since it was measured is in use again
+	if info, err := os.Stat(s.Path); err != nil || info.Size() != int64(len(data)) || !info.ModTime().Equal(s.ModifiedAt) {
+		os.Remove(dest)
+		return 0, fmt.Errorf("%s changed while it was archived", s.Path)
+	}
+
+	// The session keeps its updated_at, as archiving changes nothing in it
+	result, err := db.Exec(`
+		UPDATE claude_sessions
+		SET metadata = metadata || jsonb_build_object('source_file', $3::text, 'archived_from', $2::text, 'archived_at', $4::text)
+		WHERE session_id = $1 AND metadata->>'source_file' = $2`,
+		s.SessionID, s.Path, dest, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		os.Remove(dest)
+		return 0, fmt.Errorf("failed to update session %s: %w", s.SessionID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		os.Remove(dest)
+		return 0, nil
+	}
+	if err := os.Remove(s.Path); err != nil {
+		return 0, fmt.Errorf("failed to remove %s: %w", s.Path, err)
+	}
+	return len(compressed), nil
+}
+
+// printDiskUsage renders the report as bars, like the bundle report
+func printDiskUsage(u *DiskUsage) {
+	const barWidth = 30
+	fmt.Printf("💾 %s: %s\n\n", u.ClaudeDir, formatSize(u.TotalBytes))
+
+	fmt.Println("Directories")
+	for _, dir := range u.Dirs {
+		fmt.Printf("  %-28s %s %9s %6d files\n", dir.Name, sizeBar(dir.Bytes, u.TotalBytes, barWidth), formatSize(dir.Bytes), dir.Files)
+	}
+
+	if len(u.Projects) > 0 {
+		fmt.Println("\nProjects")
+		for _, p := range u.Projects {
+			fmt.Printf("  %-28s %s %9s %6d files\n", truncateText(p.Name, 28), sizeBar(p.Bytes, u.TotalBytes, barWidth), formatSize(p.Bytes), p.Files)
+		}
+	}
+
+	printSessions := func(title string, sessions []SessionUsage) {
+		if len(sessions) == 0 {
+			return
+		}
+		fmt.Printf("\n%s\n", title)
+		for _, s := range sessions {
+			row := ""
+			if s.RowBytes > 0 {
+				row = fmt.Sprintf(", %s in the database", formatSize(s.RowBytes))
+			}
+			fmt.Printf("  %s %9s, %3d%% tool results%s  %s\n", s.SessionID, formatSize(s.Bytes),
+				s.ToolResultBytes*100/max(s.Bytes, 1), row, s.Project)
+		}
+	}
+	printSessions("Largest sessions", u.Largest)
+	printSessions("Most tool results", u.ToolHeavy)
+
+	if u.Database != nil {
+		fmt.Printf("\nDatabase: %s\n", formatSize(u.Database.TotalBytes))
+		for _, table := range u.Database.Tables {
+			if table.Bytes == 0 {
+				continue
+			}
+			fmt.Printf("  %-28s %s %9s\n", table.Name, sizeBar(table.Bytes, u.Database.TotalBytes, barWidth), formatSize(table.Bytes))
+		}
+		if len(u.Database.Largest) > 0 {
+			fmt.Println("\nLargest session rows")
+			for _, row := range u.Database.Largest {
+				fmt.Printf("  %s %9s\n", row.Name, formatSize(row.Bytes))
+			}
+		}
+	}
+
+	if a := u.Archivable; a.Sessions > 0 {
+		fmt.Printf("\n💡 %d session file(s) (%s) were not modified in %d days; archive them with claudemd du --archive\n",
+			a.Sessions, formatSize(a.Bytes), a.Days)
+	}
+}
+
+// duCommand reports what takes up space under ~/.claude and in the database,
+// and with --archive compresses old transcripts into ~/.claude/archive
+func duCommand(c *cli.Context) error {
+	if c.Int("top") <= 0 || c.Int("days") <= 0 {
+		return fmt.Errorf("--top and --days must be positive")
+	}
+	claudeDir, err := defaultClaudeDir()
+	if err != nil {
+		return err
+	}
+	usage, err := measureClaudeDir(claudeDir, c.String("project"))
+	if err != nil {
+		return err
+	}
+
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		// Archiving has to repoint the sessions it moves
+		if c.Bool("archive") {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Skipping database sizes: %v\n", err)
+	} else {
+		defer db.Close()
+		if usage.Database, err = measureDatabase(db, SessionFilter{Project: c.String("project")}, c.Int("top"), usage.sessions); err != nil {
+			return err
+		}
+	}
+
+	top := c.Int("top")
+	usage.Largest = topSessions(usage.sessions, top, func(a, b SessionUsage) bool { return a.Bytes > b.Bytes })
+	usage.ToolHeavy = topSessions(usage.sessions, top, func(a, b SessionUsage) bool { return a.ToolResultBytes > b.ToolResultBytes })
+	cutoff := time.Now().AddDate(0, 0, -c.Int("days"))
+	usage.Archivable.Days = c.Int("days")
+	var old []SessionUsage
+	for _, s := range usage.sessions {
+		if s.ModifiedAt.Before(cutoff) {
+			old = append(old, s)
+			usage.Archivable.Sessions++
+			usage.Archivable.Bytes += s.Bytes
+		}
+	}
+
+	if !c.Bool("archive") {
+		if c.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(usage)
+		}
+		printDiskUsage(usage)
+		return nil
+	}
+
+	if len(old) == 0 {
+		fmt.Fprintf(os.Stderr, "✅ No session files older than %d days\n", c.Int("days"))
+		return nil
+	}
+	if c.Bool("dry-run") {
+		for _, s := range old {
+			fmt.Printf("  %s %9s  %s\n", s.SessionID, formatSize(s.Bytes), s.ModifiedAt.Format("2006-01-02"))
+		}
+		fmt.Fprintf(os.Stderr, "🔍 %d session file(s) (%s) would be archived to %s\n",
+			len(old), formatSize(usage.Archivable.Bytes), filepath.Join(claudeDir, archiveDir))
+		return nil
+	}
+
+	archived, unsynced, failed, before, after := 0, 0, 0, 0, 0
+	for _, s := range old {
+		n, err := archiveSession(db, claudeDir, s)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+			failed++
+		case n == 0:
+			unsynced++
+		default:
+			archived++
+			before += s.Bytes
+			after += n
+		}
+	}
+	if unsynced > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  Left %d session file(s) that are not synced from here in place; sync them first\n", unsynced)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Archived %d session file(s) to %s: %s compressed to %s\n",
+		archived, filepath.Join(claudeDir, archiveDir), formatSize(before), formatSize(after))
+	if failed > 0 {
+		return fmt.Errorf("failed to archive %d session file(s)", failed)
+	}
+	return nil
+}
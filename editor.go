@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// monacoVersion is the Monaco release loaded by the editor page
+const monacoVersion = "0.52.2"
+
+// editorLanguages maps the extensions that can be edited in the browser to
+// their Monaco language
+var editorLanguages = map[string]string{
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".css":  "css",
+	".json": "json",
+	".md":   "markdown",
+}
+
+// editablePath checks that a request path names an existing source file
+// inside the project and returns it relative to the working directory.
+// Dotfiles and the local config directory, which holds tokens and the
+// database URL, are never editable.
+func editablePath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	cleanPath := filepath.Clean(r.PathValue("path"))
+	if cleanPath == "." || strings.Contains(cleanPath, "..") || filepath.IsAbs(cleanPath) ||
+		strings.Contains(string(filepath.Separator)+cleanPath, string(filepath.Separator)+"node_modules"+string(filepath.Separator)) {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid path", nil)
+		return "", false
+	}
+	parts := strings.Split(filepath.ToSlash(cleanPath), "/")
+	for _, part := range parts {
+		if strings.HasPrefix(part, ".") || parts[0] == localConfigDir {
+			writeJSONError(w, r, http.StatusForbidden, "This file cannot be edited", map[string]string{"path": cleanPath})
+			return "", false
+		}
+	}
+	if _, ok := editorLanguages[filepath.Ext(cleanPath)]; !ok {
+		writeJSONError(w, r, http.StatusBadRequest, "Only source files can be edited", map[string]string{"path": cleanPath})
+		return "", false
+	}
+	info, err := os.Stat(cleanPath)
+	if err != nil || !info.Mode().IsRegular() {
+		writeJSONError(w, r, http.StatusNotFound, "Source file not found", map[string]string{"path": cleanPath})
+		return "", false
+	}
+	return cleanPath, true
+}
+
+// contentETag identifies a version of a file, so a save can be refused when
+// the file changed on disk after the editor loaded it
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// handleEditor serves GET /editor/{path}, a Monaco editor for a source file
+// beside a live render of it
+func handleEditor(w http.ResponseWriter, r *http.Request) {
+	srcPath, ok := editablePath(w, r)
+	if !ok {
+		return
+	}
+	source, err := os.ReadFile(srcPath)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to read source file: %v", err), nil)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(generateEditorHTML(filepath.ToSlash(srcPath), string(source), contentETag(source))))
+}
+
+// fileUpdate is the body of PUT /api/files/{path}
+type fileUpdate struct {
+	Content string `json:"content"`
+}
+
+// fileSaveResult reports a saved file and the rebuild that followed
+type fileSaveResult struct {
+	Path  string      `json:"path"`
+	Bytes int         `json:"bytes"`
+	ETag  string      `json:"etag"`
+	Build buildStatus `json:"build"`
+}
+
+// handleSaveFile serves PUT /api/files/{path}, replacing an existing source
+// file and rebuilding it straight away. Open render pages reload through the
+// render watcher. An If-Match header makes the save fail with 412 when the
+// file was changed by someone else since it was loaded.
+func handleSaveFile(w http.ResponseWriter, r *http.Request) {
+	srcPath, ok := editablePath(w, r)
+	if !ok {
+		return
+	}
+	var in fileUpdate
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+
+	current, err := os.ReadFile(srcPath)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to read source file: %v", err), nil)
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && match != "*" && match != contentETag(current) {
+		writeJSONError(w, r, http.StatusPreconditionFailed, "The file changed on disk since it was loaded", map[string]string{"etag": contentETag(current)})
+		return
+	}
+
+	if err := writeFileAtomic(srcPath, []byte(in.Content)); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	start := time.Now()
+	status, _ := buildForWatch(r.Context(), "./"+srcPath)
+	stats.RecordBuild(srcPath, time.Since(start), !status.OK)
+	log.Printf("[trace=%s] saved %s from the editor, rebuilt in %s (ok=%t)", traceIDFromContext(r.Context()), srcPath, time.Since(start), status.OK)
+
+	writeJSON(w, http.StatusOK, fileSaveResult{
+		Path:  filepath.ToSlash(srcPath),
+		Bytes: len(in.Content),
+		ETag:  contentETag([]byte(in.Content)),
+		Build: status,
+	})
+}
+
+// writeFileAtomic replaces a file through a temporary file in the same
+// directory, so watchers never build a half-written file
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
+
+// generateEditorHTML renders the editor page. Monaco is loaded from the CDN,
+// so the editor needs network access even when serving with --offline.
+func generateEditorHTML(srcPath, source, etag string) string {
+	monacoBase := "https://cdn.jsdelivr.net/npm/monaco-editor@" + monacoVersion + "/min/vs"
+	config, _ := json.Marshal(map[string]string{
+		"path":     srcPath,
+		"source":   source,
+		"etag":     etag,
+		"language": editorLanguages[filepath.Ext(srcPath)],
+		"monaco":   monacoBase,
+	})
+	// Keep a closing script tag in the source from ending the inline script
+	safeConfig := strings.ReplaceAll(string(config), "</", `<\/`)
+	preview := ""
+	if ext := filepath.Ext(srcPath); ext == ".jsx" || ext == ".tsx" || ext == ".js" || ext == ".ts" {
+		preview = `<iframe id="preview" src="/render/` + html.EscapeString(srcPath) + `"></iframe>`
+	}
+
+	return `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Edit ` + html.EscapeString(srcPath) + `</title>
+    <style>
+        html, body { margin: 0; height: 100%; font-family: system-ui, sans-serif; background: #1e1e1e; color: #d4d4d4; }
+        header { display: flex; align-items: center; gap: 1rem; height: 40px; padding: 0 1rem; background: #252526; border-bottom: 1px solid #3c3c3c; font-size: 13px; }
+        header .path { font-family: ui-monospace, monospace; flex: 1; }
+        header button { background: #0e639c; color: #fff; border: none; border-radius: 3px; padding: 0.3rem 0.9rem; cursor: pointer; }
+        header button:disabled { background: #3c3c3c; cursor: default; }
+        #status.error { color: #f87171; }
+        #status.ok { color: #4ade80; }
+        main { display: flex; height: calc(100% - 41px); }
+        #editor { flex: 1; min-width: 0; }
+        #preview { flex: 1; border: none; border-left: 1px solid #3c3c3c; background: #fff; }
+        #errors { position: fixed; bottom: 0; left: 0; right: 0; max-height: 35%; overflow: auto; margin: 0; padding: 0.75rem 1rem; background: #2d1b1b; color: #fca5a5; font: 12px ui-monospace, monospace; white-space: pre-wrap; display: none; }
+    </style>
+</head>
+<body>
+    <header>
+        <span class="path">` + html.EscapeString(srcPath) + `</span>
+        <span id="status">Ctrl+S to save</span>
+        <button id="save" disabled>Save</button>
+    </header>
+    <main>
+        <div id="editor"></div>
+        ` + preview + `
+    </main>
+    <pre id="errors"></pre>
+    <script>window.__claudemdEditor = ` + safeConfig + `;</script>
+    <script src="` + monacoBase + `/loader.js"></script>
+    <script>
+    (function () {
+        var config = window.__claudemdEditor;
+        var etag = config.etag;
+        var saved = config.source;
+        var statusEl = document.getElementById('status');
+        var saveButton = document.getElementById('save');
+        var errorsEl = document.getElementById('errors');
+
+        function setStatus(text, kind) {
+            statusEl.textContent = text;
+            statusEl.className = kind || '';
+        }
+        function showErrors(errors) {
+            errorsEl.textContent = (errors || []).join('\n\n');
+            errorsEl.style.display = errors && errors.length ? 'block' : 'none';
+        }
+
+        require.config({ paths: { vs: config.monaco } });
+        require(['vs/editor/editor.main'], function () {
+            // Components import packages Monaco has no types for, so only
+            // syntax errors are reported; the build reports the rest
+            [monaco.languages.typescript.typescriptDefaults, monaco.languages.typescript.javascriptDefaults].forEach(function (defaults) {
+                defaults.setCompilerOptions({ jsx: monaco.languages.typescript.JsxEmit.React, allowJs: true, allowNonTsExtensions: true, target: monaco.languages.typescript.ScriptTarget.ESNext });
+                defaults.setDiagnosticsOptions({ noSemanticValidation: true });
+            });
+            var uri = monaco.Uri.file(config.path);
+            var model = monaco.editor.createModel(config.source, config.language, uri);
+            var editor = monaco.editor.create(document.getElementById('editor'), {
+                model: model, theme: 'vs-dark', automaticLayout: true, minimap: { enabled: false }, fontSize: 13
+            });
+
+            model.onDidChangeContent(function () {
+                var dirty = model.getValue() !== saved;
+                saveButton.disabled = !dirty;
+                setStatus(dirty ? 'Unsaved changes' : 'Saved');
+            });
+            window.addEventListener('beforeunload', function (e) {
+                if (model.getValue() !== saved) { e.preventDefault(); e.returnValue = ''; }
+            });
+
+            function save(force) {
+                var content = model.getValue();
+                var headers = { 'Content-Type': 'application/json' };
+                if (!force) headers['If-Match'] = etag;
+                var token = localStorage.getItem('claudemd-admin-token');
+                if (token) headers['Authorization'] = 'Bearer ' + token;
+                setStatus('Saving…');
+                fetch('/api/files/' + config.path.split('/').map(encodeURIComponent).join('/'), {
+                    method: 'PUT', headers: headers, body: JSON.stringify({ content: content })
+                }).then(function (res) {
+                    return res.json().then(function (body) { return { res: res, body: body }; });
+                }).then(function (r) {
+                    if (r.res.status === 401) {
+                        var entered = prompt('Admin token');
+                        if (entered) { localStorage.setItem('claudemd-admin-token', entered); save(force); }
+                        else setStatus('Not saved: a token is required', 'error');
+                        return;
+                    }
+                    if (r.res.status === 412) {
+                        if (confirm('The file changed on disk since it was opened. Overwrite it?')) save(true);
+                        else setStatus('Not saved: file changed on disk', 'error');
+                        return;
+                    }
+                    if (!r.res.ok) {
+                        setStatus('Not saved: ' + (r.body.error || r.res.statusText), 'error');
+                        return;
+                    }
+                    saved = content;
+                    etag = r.body.etag;
+                    saveButton.disabled = model.getValue() === saved;
+                    if (r.body.build.ok) {
+                        setStatus('Saved and rebuilt', 'ok');
+                        showErrors([]);
+                    } else {
+                        setStatus('Saved, build failed', 'error');
+                        showErrors(r.body.build.errors);
+                    }
+                }).catch(function (err) {
+                    setStatus('Not saved: ' + err.message, 'error');
+                });
+            }
+
+            editor.addCommand(monaco.KeyMod.CtrlCmd | monaco.KeyCode.KeyS, function () { save(false); });
+            saveButton.addEventListener('click', function () { save(false); });
+        });
+    })();
+    </script>
+</body>
+</html>`
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// embeddedDir holds the production build that build --embed compiles into
+// the binary. Only its README is checked in.
+const embeddedDir = "embedded"
+
+// embeddedReadme is the checked-in placeholder that keeps go:embed building
+// before the frontend has been built
+const embeddedReadme = "README.md"
+
+//go:embed embedded
+var embeddedFiles embed.FS
+
+// embeddedApp is the frontend served by serve --embedded, nil when the app
+// is built from source on request
+var embeddedApp fs.FS
+
+// enableEmbedded serves the frontend compiled into the binary in place of
+// the source files in the working directory
+func enableEmbedded() error {
+	app, err := fs.Sub(embeddedFiles, embeddedDir)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.Stat(app, "index.html"); err != nil {
+		return fmt.Errorf("this binary has no embedded frontend; run `claudemd build --embed` to compile one in")
+	}
+	embeddedApp = app
+	fmt.Println("📦 Serving the frontend embedded in this binary")
+	return nil
+}
+
+// serveEmbedded serves a file of the embedded build. Scripts and assets have
+// content hashed names and are cached forever; unknown paths without an
+// extension get index.html so client-side routes survive a reload.
+func serveEmbedded(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name != "" && name != "index.html" {
+		if info, err := fs.Stat(embeddedApp, name); err == nil && !info.IsDir() {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			http.ServeFileFS(w, r, embeddedApp, name)
+			return
+		}
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	index, err := fs.ReadFile(embeddedApp, "index.html")
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, "Embedded index.html is missing", nil)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(index)
+}
+
+// writeEmbeddedBuild replaces the previous build in the embedded directory
+func writeEmbeddedBuild(artifacts []buildArtifact) error {
+	entries, err := os.ReadDir(embeddedDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", embeddedDir, err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == embeddedReadme {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(embeddedDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", embeddedDir, err)
+		}
+	}
+	for _, artifact := range artifacts {
+		target := filepath.Join(embeddedDir, filepath.FromSlash(artifact.Path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, artifact.Contents, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// embedBuild writes a production build into the embedded directory and, in
+// a checkout of this module with a Go toolchain, compiles a binary with it
+func embedBuild(config BuildConfig, entry, output string) (api.BuildResult, error) {
+	result, artifacts := buildArtifacts(config, entry)
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+	if err := writeEmbeddedBuild(artifacts); err != nil {
+		return result, err
+	}
+	fmt.Printf("📦 Files in %s:\n", embeddedDir)
+	for _, artifact := range artifacts {
+		fmt.Printf("   • %s (%s)\n", artifact.Path, formatSize(len(artifact.Contents)))
+	}
+
+	goTool, err := exec.LookPath("go")
+	if _, statErr := os.Stat("go.mod"); err != nil || statErr != nil {
+		fmt.Printf("💡 Run `go build` in the claudemd source directory to compile %s into the binary\n", embeddedDir)
+		return result, nil
+	}
+	fmt.Printf("🔨 Compiling %s...\n", output)
+	cmd := exec.Command(goTool, "build", "-o", output, ".")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return result, fmt.Errorf("failed to compile the binary: %w", err)
+	}
+	fmt.Printf("🚀 Run `%s serve --embedded` to serve the app without any files on disk\n", output)
+	return result, nil
+}
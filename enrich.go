@@ -0,0 +1,39 @@
+package main
+
+// extractorVersion is recorded in the metadata of every synced session. Bump
+// it when message extraction or the message derived metadata changes, so
+// `claudemd reprocess` brings stored sessions up to date.
+const extractorVersion = 2
+
+// sessionEnricher derives extra metadata for a session from its source file
+// and environment. Enrichers run during sync, before redaction and upsert.
+type sessionEnricher func(session *ClaudeSession, filePath string)
+
+// sessionEnrichers are applied in order to every synced session
+var sessionEnrichers = []sessionEnricher{
+	enrichGitCommits,
+	enrichLanguages,
+	enrichErrors,
+	enrichTurnDurations,
+	enrichExtractorVersion,
+}
+
+// messageEnrichers only read the messages, so reprocess can run them again
+// without the source file. Each owns the metadata key it is listed under.
+var messageEnrichers = map[string]sessionEnricher{
+	"languages": enrichLanguages,
+	"errors":    enrichErrors,
+	"turns":     enrichTurnDurations,
+}
+
+// enrichSession runs all registered enrichers
+func enrichSession(session *ClaudeSession, filePath string) {
+	for _, enrich := range sessionEnrichers {
+		enrich(session, filePath)
+	}
+}
+
+// enrichExtractorVersion records which extractor produced the message content
+func enrichExtractorVersion(session *ClaudeSession, filePath string) {
+	session.Metadata["extractor_version"] = extractorVersion
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Dev event types published on the event bus
+const (
+	eventBuildStart  = "build_start"
+	eventBuildFinish = "build_finish"
+	eventBuildError  = "build_error"
+	eventSync        = "sync"
+	eventSyncError   = "sync_error"
+	eventTypecheck   = "typecheck"
+)
+
+// DevEvent is a build or sync notification for the dev status channel
+type DevEvent struct {
+	Type       string    `json:"type"`
+	Path       string    `json:"path,omitempty"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Messages   int       `json:"messages,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Errors     []string  `json:"errors,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// DevStatus is the state a new subscriber starts from
+type DevStatus struct {
+	Building  []string  `json:"building"`
+	LastBuild *DevEvent `json:"last_build,omitempty"`
+	LastSync  *DevEvent `json:"last_sync,omitempty"`
+	// LastTypecheck lists the errors of the last background tsc check
+	LastTypecheck *DevEvent `json:"last_typecheck,omitempty"`
+	// RecentSyncs counts sessions synced within devStatusWindow
+	RecentSyncs int `json:"recent_syncs"`
+}
+
+// devStatusWindow is how far back RecentSyncs looks
+const devStatusWindow = time.Minute
+
+// eventBus fans dev events out to subscribers. Slow subscribers miss events
+// rather than blocking builds or syncs.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan DevEvent]struct{}
+	building    map[string]int
+	lastBuild   *DevEvent
+	lastSync    *DevEvent
+	lastCheck   *DevEvent
+	syncTimes   []time.Time
+}
+
+var devEvents = &eventBus{
+	subscribers: make(map[chan DevEvent]struct{}),
+	building:    make(map[string]int),
+}
+
+// Publish records an event and delivers it to every subscriber
+func (b *eventBus) Publish(e DevEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch e.Type {
+	case eventBuildStart:
+		b.building[e.Path]++
+	case eventBuildFinish, eventBuildError:
+		if b.building[e.Path]--; b.building[e.Path] <= 0 {
+			delete(b.building, e.Path)
+		}
+		b.lastBuild = &e
+	case eventSync, eventSyncError:
+		b.lastSync = &e
+		if e.Type == eventSync {
+			b.syncTimes = append(b.syncTimes, e.Time)
+			b.pruneSyncTimes()
+		}
+	case eventTypecheck:
+		b.lastCheck = &e
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events and a function that ends the subscription
+func (b *eventBus) Subscribe() (<-chan DevEvent, func()) {
+	ch := make(chan DevEvent, 64)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Status summarises builds in progress and recent activity
+func (b *eventBus) Status() DevStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pruneSyncTimes()
+	return DevStatus{
+		Building:      sortedKeys(b.building),
+		LastBuild:     b.lastBuild,
+		LastSync:      b.lastSync,
+		LastTypecheck: b.lastCheck,
+		RecentSyncs:   len(b.syncTimes),
+	}
+}
+
+// pruneSyncTimes drops syncs older than the status window, so a server
+// that is never asked for its status does not keep every sync. The caller
+// holds b.mu.
+func (b *eventBus) pruneSyncTimes() {
+	cutoff := time.Now().Add(-devStatusWindow)
+	i := sort.Search(len(b.syncTimes), func(i int) bool { return b.syncTimes[i].After(cutoff) })
+	b.syncTimes = b.syncTimes[i:]
+}
+
+// publishBuild runs an esbuild build of path, announcing its start and
+// outcome, in a span of ctx
+func publishBuild(ctx context.Context, path string, build func() api.BuildResult) api.BuildResult {
+	_, span := tracer.Start(ctx, "esbuild", trace.WithAttributes(attribute.String("claudemd.build.path", path)))
+	defer span.End()
+	devEvents.Publish(DevEvent{Type: eventBuildStart, Path: path})
+	start := time.Now()
+	result := build()
+
+	outputBytes := 0
+	for _, file := range result.OutputFiles {
+		outputBytes += len(file.Contents)
+	}
+	span.SetAttributes(
+		attribute.Int("claudemd.build.errors", len(result.Errors)),
+		attribute.Int("claudemd.build.warnings", len(result.Warnings)),
+		attribute.Int("claudemd.build.output_bytes", outputBytes),
+	)
+	if len(result.Errors) > 0 {
+		span.SetStatus(codes.Error, "build failed")
+	}
+
+	event := DevEvent{Type: eventBuildFinish, Path: path, DurationMS: time.Since(start).Milliseconds()}
+	if len(result.Errors) > 0 {
+		event.Type = eventBuildError
+		event.Errors = formatBuildErrors(result.Errors)
+	}
+	devEvents.Publish(event)
+	return result
+}
+
+// publishSync announces the outcome of syncing a session
+func publishSync(sessionID string, messages int, err error) {
+	event := DevEvent{Type: eventSync, SessionID: sessionID, Messages: messages}
+	if err != nil {
+		event.Type = eventSyncError
+		event.Errors = []string{err.Error()}
+	}
+	devEvents.Publish(event)
+}
+
+// handleDevStatus serves GET /api/devstatus, an SSE stream that starts with
+// a status event and then relays build and sync events as they happen
+func handleDevStatus(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, r, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+
+	// Subscribe before taking the snapshot so no event falls in between
+	events, unsubscribe := devEvents.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(name string, v interface{}) {
+		payload, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+		flusher.Flush()
+	}
+	send("status", devEvents.Status())
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			send(event.Type, event)
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
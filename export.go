@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/urfave/cli/v2"
+)
+
+// pdfTimeout bounds loading and printing a transcript as PDF
+const pdfTimeout = 2 * time.Minute
+
+// sessionExporter writes a session in a particular output format
+type sessionExporter func(w io.Writer, session *ClaudeSession) error
+
+// exportFormats maps --format values to their exporters
+var exportFormats = map[string]sessionExporter{
+	"json":     exportJSON,
+	"markdown": exportMarkdown,
+	"html":     exportHTML,
+
+	// Fine-tuning formats, one JSON record per session. The -tools variants
+	// keep tool calls as function-call records instead of stripping them.
+	"openai":         openAIExporter(false),
+	"openai-tools":   openAIExporter(true),
+	"sharegpt":       shareGPTExporter(false),
+	"sharegpt-tools": shareGPTExporter(true),
+}
+
+// jsonlFormats are the formats that can write several sessions to one file
+var jsonlFormats = map[string]bool{
+	"openai":         true,
+	"openai-tools":   true,
+	"sharegpt":       true,
+	"sharegpt-tools": true,
+}
+
+// exportCommand writes synced sessions to a file or stdout
+func exportCommand(c *cli.Context) error {
+	if c.Bool("to-repo") {
+		return exportToRepo(c)
+	}
+	format := c.String("format")
+	out := c.String("out")
+	if c.NArg() > 1 && !tableFormats[format] && !jsonlFormats[format] {
+		return fmt.Errorf("format %q exports a single session; use a JSONL format for several", format)
+	}
+
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// Table formats export every session when none are named
+	var prompts []string
+	if !tableFormats[format] {
+		prompts = []string{"Export"}
+	}
+	sessionIDs, err := sessionArgs(db, c.Args().Slice(), prompts...)
+	if err != nil {
+		return err
+	}
+	blobs, err := newBlobStore(config.Blobs)
+	if err != nil {
+		return err
+	}
+	// One anonymizer for the whole export keeps pseudonyms consistent
+	// across sessions
+	var anonymizer *Anonymizer
+	if c.Bool("anonymize") {
+		anonymizer = NewAnonymizer()
+	}
+	if tableFormats[format] {
+		return exportTables(db, blobs, sessionIDs, format, out, anonymizer)
+	}
+
+	sessions := make([]*ClaudeSession, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := loadSession(db, sessionID)
+		if err != nil {
+			return err
+		}
+		// Exports include the full tool results, not the inline previews
+		rehydrateSession(session, blobs)
+		anonymizer.Anonymize(session)
+		sessions = append(sessions, session)
+	}
+
+	if format == "pdf" {
+		if out == "" {
+			out = sessionIDs[0] + ".pdf"
+		}
+		if err := exportPDF(sessions[0], out); err != nil {
+			return err
+		}
+		fmt.Printf("📄 Exported %s to %s\n", sessionIDs[0], out)
+		return nil
+	}
+
+	exporter, ok := exportFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	for _, session := range sessions {
+		if err := exporter(w, session); err != nil {
+			return err
+		}
+	}
+	if out != "" {
+		fmt.Printf("📄 Exported %s to %s\n", strings.Join(sessionIDs, ", "), out)
+	}
+	return nil
+}
+
+// exportJSON writes the session as indented JSON
+func exportJSON(w io.Writer, session *ClaudeSession) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(session)
+}
+
+// exportMarkdown writes a readable Markdown transcript
+func exportMarkdown(w io.Writer, session *ClaudeSession) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", session.Title)
+	fmt.Fprintf(&b, "Session `%s`\n\n", session.SessionID)
+
+	for _, msg := range session.Messages {
+		if msg.Type == "summary" {
+			continue
+		}
+		fmt.Fprintf(&b, "## %s", messageRole(msg))
+		if msg.Timestamp != "" {
+			fmt.Fprintf(&b, " · %s", msg.Timestamp)
+		}
+		b.WriteString("\n\n")
+
+		for _, block := range messageBlocks(msg) {
+			switch block.Type {
+			case "text":
+				b.WriteString(block.Text + "\n\n")
+			case "tool_use":
+				fmt.Fprintf(&b, "**Tool: %s**\n\n```json\n%s\n```\n\n", block.Name, indentJSON(block.Input))
+			case "tool_result":
+				fmt.Fprintf(&b, "**Tool result**\n\n```\n%s\n```\n\n", toolResultText(block.Content))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// exportHTML writes a self-contained HTML transcript with collapsible tool calls
+func exportHTML(w io.Writer, session *ClaudeSession) error {
+	return writeHTMLTranscript(w, session, false)
+}
+
+// writeHTMLTranscript writes the HTML export, with every tool call expanded
+// when open is set. Browsers print nothing of a closed details element, so
+// the PDF export needs them open.
+func writeHTMLTranscript(w io.Writer, session *ClaudeSession, open bool) error {
+	details := `<details class="%s">`
+	if open {
+		details = `<details class="%s" open>`
+	}
+	var b strings.Builder
+	for _, msg := range session.Messages {
+		if msg.Type == "summary" {
+			continue
+		}
+		role := messageRole(msg)
+		fmt.Fprintf(&b, `<section class="msg %s"><header><span class="role">%s</span><time>%s</time></header>`,
+			html.EscapeString(role), html.EscapeString(role), html.EscapeString(msg.Timestamp))
+
+		for _, block := range messageBlocks(msg) {
+			switch block.Type {
+			case "text":
+				fmt.Fprintf(&b, `<div class="text">%s</div>`, html.EscapeString(block.Text))
+			case "tool_use":
+				fmt.Fprintf(&b, details+`<summary>Tool: %s</summary><pre>%s</pre></details>`,
+					"tool", html.EscapeString(block.Name), html.EscapeString(indentJSON(block.Input)))
+			case "tool_result":
+				class := "tool"
+				if block.IsError {
+					class = "tool error"
+				}
+				fmt.Fprintf(&b, details+`<summary>Tool result</summary><pre>%s</pre></details>`,
+					class, html.EscapeString(toolResultText(block.Content)))
+			}
+		}
+		b.WriteString("</section>\n")
+	}
+
+	_, err := fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>%s</title>
+    <style>
+        body { font-family: system-ui, -apple-system, sans-serif; max-width: 900px; margin: 0 auto; padding: 2rem; color: #1a202c; }
+        h1 { margin-bottom: 0.25rem; }
+        .meta { color: #718096; font-size: 13px; margin-bottom: 2rem; }
+        .msg { border-left: 3px solid #cbd5e0; padding: 0.5rem 1rem; margin: 1rem 0; page-break-inside: avoid; }
+        .msg.user { border-color: #3182ce; }
+        .msg.assistant { border-color: #805ad5; }
+        .msg header { display: flex; justify-content: space-between; font-size: 12px; color: #718096; margin-bottom: 0.5rem; }
+        .role { font-weight: bold; text-transform: uppercase; }
+        .text { white-space: pre-wrap; line-height: 1.5; }
+        details.tool { background: #f7fafc; border-radius: 4px; margin: 0.5rem 0; padding: 0.25rem 0.5rem; }
+        details.tool.error { background: #fff5f5; }
+        details.tool summary { cursor: pointer; font-size: 13px; color: #4a5568; }
+        pre { white-space: pre-wrap; word-break: break-word; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>%s</h1>
+    <div class="meta">Session %s · exported from claudemd</div>
+    %s
+</body>
+</html>`, html.EscapeString(session.Title), html.EscapeString(session.Title), html.EscapeString(session.SessionID), b.String())
+	return err
+}
+
+// exportPDF prints the HTML export, tool calls expanded, with a headless
+// Chrome/Chromium
+func exportPDF(session *ClaudeSession, out string) error {
+	path, err := findHeadlessBrowser()
+	if err != nil {
+		return err
+	}
+	browser, closeBrowser, err := startHeadlessBrowser(path, 1280, 800)
+	if err != nil {
+		return err
+	}
+	defer closeBrowser()
+
+	tmpDir, err := os.MkdirTemp("", "claudemd-export-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	htmlPath := filepath.Join(tmpDir, "session.html")
+	var buf bytes.Buffer
+	if err := writeHTMLTranscript(&buf, session, true); err != nil {
+		return err
+	}
+	if err := os.WriteFile(htmlPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(browser, pdfTimeout)
+	defer cancel()
+	var pdf []byte
+	err = chromedp.Run(ctx,
+		chromedp.Navigate("file://"+filepath.ToSlash(htmlPath)),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdf, _, err = page.PrintToPDF().WithPrintBackground(true).WithDisplayHeaderFooter(false).Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to render PDF: %w", err)
+	}
+	if err := os.WriteFile(out, pdf, 0644); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// startHeadlessBrowser launches a headless browser at path, in which
+// chromedp.NewContext opens tabs
+func startHeadlessBrowser(path string, width, height int) (context.Context, context.CancelFunc, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ExecPath(path),
+		chromedp.WindowSize(width, height),
+		chromedp.Flag("hide-scrollbars", true),
+	)
+	allocator, cancelAllocator := chromedp.NewExecAllocator(context.Background(), opts...)
+	browser, cancelBrowser := chromedp.NewContext(allocator)
+	cancel := func() {
+		cancelBrowser()
+		cancelAllocator()
+	}
+	// The first Run starts the browser, so a missing one fails here
+	if err := chromedp.Run(browser); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("failed to start %s: %w", path, err)
+	}
+	return browser, cancel, nil
+}
+
+// findHeadlessBrowser locates a Chrome or Chromium binary, honouring CHROME_PATH
+func findHeadlessBrowser() (string, error) {
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		return path, nil
+	}
+
+	candidates := []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable"}
+	if runtime.GOOS == "darwin" {
+		candidates = append(candidates,
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium")
+	}
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no Chrome or Chromium found; install one or set CHROME_PATH")
+}
+
+// indentJSON pretty-prints raw JSON, returning it unchanged if invalid
+func indentJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// toolResultText flattens tool_result content (a string or an array of blocks) into text
+func toolResultText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var blocks []ContentBlock
+	if err := json.Unmarshal(raw, &blocks); err == nil {
+		var parts []string
+		for _, block := range blocks {
+			if block.Type == "text" {
+				parts = append(parts, block.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[%s]", block.Type))
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return string(raw)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// repoExportDir is where --to-repo writes, relative to the repository root
+const repoExportDir = "docs/claude-sessions"
+
+// repoIndexFile is the index page of the exported sessions
+const repoIndexFile = "README.md"
+
+// repoExportPage is how many tagged sessions are listed per query
+const repoExportPage = 500
+
+// slugSeparators are the runs of characters replaced in file name slugs
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportToRepo writes sessions as Markdown into docs/claude-sessions of the
+// git repository in the current directory and rebuilds the index page.
+// Without session IDs it exports the sessions of that repository carrying
+// every --tag. Files are named by start date, title and session ID, so
+// exporting again updates them in place.
+func exportToRepo(c *cli.Context) error {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return fmt.Errorf("--to-repo must be run inside a git repository")
+	}
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	root := strings.TrimSpace(string(out))
+	dir := filepath.Join(root, filepath.FromSlash(repoExportDir))
+	if c.String("out") != "" {
+		dir = c.String("out")
+	}
+
+	tags := c.StringSlice("tag")
+	var sessionIDs []string
+	if c.NArg() > 0 {
+		if sessionIDs, err = sessionArgs(db, c.Args().Slice()); err != nil {
+			return err
+		}
+	} else {
+		if len(tags) == 0 {
+			return fmt.Errorf("--to-repo needs the --tag of the sessions to export, or their IDs")
+		}
+		if sessionIDs, err = taggedSessionIDs(db, tags); err != nil {
+			return err
+		}
+	}
+
+	blobs, err := newBlobStore(config.Blobs)
+	if err != nil {
+		return err
+	}
+	var anonymizer *Anonymizer
+	if c.Bool("anonymize") {
+		anonymizer = NewAnonymizer()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	exported := 0
+	for _, sessionID := range sessionIDs {
+		session, err := loadSession(db, sessionID)
+		if err != nil {
+			return err
+		}
+		// Tagged sessions of other projects belong in their own repository
+		if c.NArg() == 0 && !pathInRepo(root, session) {
+			continue
+		}
+		rehydrateSession(session, blobs)
+		anonymizer.Anonymize(session)
+		if err := writeRepoSession(dir, session); err != nil {
+			return err
+		}
+		exported++
+	}
+	if exported == 0 {
+		fmt.Fprintf(os.Stderr, "📭 No sessions of %s are tagged %s\n", root, strings.Join(tags, ", "))
+		return nil
+	}
+	if err := writeRepoIndex(dir); err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = dir
+	}
+	fmt.Printf("📚 Exported %d sessions to %s; commit them with `git add %s`\n", exported, rel, rel)
+	return nil
+}
+
+// taggedSessionIDs lists every session carrying all the tags, oldest first.
+// Sessions of other repositories are only left out once loaded, so the list
+// is paged rather than cut off before they are.
+func taggedSessionIDs(db *sql.DB, tags []string) ([]string, error) {
+	filter := SessionFilter{Tags: tags, Sort: "created_asc"}
+	var ids []string
+	var after *sessionCursor
+	for {
+		summaries, err := listSessionSummaries(db, filter, after, repoExportPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range summaries {
+			ids = append(ids, s.SessionID)
+		}
+		if len(summaries) < repoExportPage {
+			return ids, nil
+		}
+		last := summaries[len(summaries)-1]
+		after = &sessionCursor{At: last.CreatedAt, SessionID: last.SessionID}
+	}
+}
+
+// pathInRepo reports whether the session ran in the repository at root or
+// one of its subdirectories
+func pathInRepo(root string, session *ClaudeSession) bool {
+	projectPath, _ := session.Metadata["project_path"].(string)
+	if projectPath == "" {
+		return false
+	}
+	// git resolves symlinks in the root, so the session's path must be too
+	if resolved, err := filepath.EvalSymlinks(projectPath); err == nil {
+		projectPath = resolved
+	}
+	rel, err := filepath.Rel(root, projectPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// repoSessionFile names a session's file after its start date, title and ID
+func repoSessionFile(session *ClaudeSession) string {
+	date := session.CreatedAt
+	if session.StartedAt != nil {
+		date = *session.StartedAt
+	}
+	slug := strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(session.Title), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = "session"
+	}
+	return fmt.Sprintf("%s-%s-%s.md", date.Format("2006-01-02"), slug, session.SessionID[:min(len(session.SessionID), 8)])
+}
+
+// writeRepoSession writes the session's transcript, removing the file of an
+// earlier export when its title has changed since
+func writeRepoSession(dir string, session *ClaudeSession) error {
+	name := repoSessionFile(session)
+	id := session.SessionID[:min(len(session.SessionID), 8)]
+	previous, _ := filepath.Glob(filepath.Join(dir, "*-"+id+".md"))
+	for _, path := range previous {
+		if filepath.Base(path) != name {
+			os.Remove(path)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := exportMarkdown(&b, session); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeRepoIndex lists every exported session in the index page, newest
+// first. It is rebuilt from the files, so removing one drops it from the
+// index on the next export.
+func writeRepoIndex(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	var b strings.Builder
+	b.WriteString("# Claude sessions\n\n")
+	b.WriteString("Transcripts of Claude Code sessions kept for the decisions made in them, newest first. ")
+	b.WriteString("Exported with `claudemd export --to-repo`; edits to these files are overwritten by the next export.\n\n")
+	b.WriteString("| Date | Session |\n|---|---|\n")
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if name == repoIndexFile {
+			continue
+		}
+		title := strings.TrimSuffix(name, ".md")
+		if file, err := os.Open(path); err == nil {
+			if scanner := bufio.NewScanner(file); scanner.Scan() && strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "#")) != "" {
+				title = strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "#"))
+			}
+			file.Close()
+		}
+		date := ""
+		if len(name) > 10 {
+			date = name[:10]
+		}
+		fmt.Fprintf(&b, "| %s | [%s](%s) |\n", date, strings.ReplaceAll(title, "|", `\|`), name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, repoIndexFile), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	return nil
+}
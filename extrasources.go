@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Extra sources are ~/.claude directories synced next to the sessions when
+// listed in sync.extra_sources or --extra-source
+const (
+	// sourceTodos holds the current todo list of each session and subagent
+	sourceTodos = "todos"
+	// sourceShellSnapshots holds the shell environment Claude Code captures
+	// for its Bash tool
+	sourceShellSnapshots = "shell-snapshots"
+)
+
+// maxSourceFileSize bounds the extra source files stored in the database
+const maxSourceFileSize = 1 << 20
+
+// SourceFile is the latest content of a file from an extra source
+type SourceFile struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+	// SessionID is the session a todos file belongs to
+	SessionID  string    `json:"session_id"`
+	Content    string    `json:"content"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// extraSources merges and checks the extra sources from the workspace and
+// the command line
+func extraSources(configured, flags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var sources []string
+	for _, source := range append(append([]string{}, configured...), flags...) {
+		if source != sourceTodos && source != sourceShellSnapshots {
+			return nil, fmt.Errorf("unknown extra source %q (valid: %s, %s)", source, sourceTodos, sourceShellSnapshots)
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+// extraSource returns the extra source a file belongs to, or "" for files
+// outside them
+func (c *ClaudeSessionSync) extraSource(path string) string {
+	dir := filepath.Dir(path)
+	for _, source := range c.extraSources {
+		if dir == filepath.Join(c.claudeDir, source) {
+			return source
+		}
+	}
+	return ""
+}
+
+// watchExtraSources watches the extra source directories that exist
+func (c *ClaudeSessionSync) watchExtraSources(watcher *fsnotify.Watcher) {
+	for _, source := range c.extraSources {
+		dir := filepath.Join(c.claudeDir, source)
+		if _, err := os.Stat(dir); err != nil {
+			log.Printf("Extra source %s not found, skipping it", dir)
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			log.Printf("Failed to watch directory %s: %v", dir, err)
+		}
+	}
+}
+
+// syncExtraSources syncs every file of the extra sources, and removes the
+// ones that are gone since the last pass
+func (c *ClaudeSessionSync) syncExtraSources() {
+	for _, source := range c.extraSources {
+		entries, err := os.ReadDir(filepath.Join(c.claudeDir, source))
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to read extra source %s: %v", source, err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				path := filepath.Join(c.claudeDir, source, entry.Name())
+				if err := c.syncSourceFile(source, path); err != nil {
+					log.Printf("Failed to sync file %s: %v", path, err)
+				}
+			}
+		}
+	}
+	for path := range c.sourceFiles {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := c.syncSourceFile(c.extraSource(path), path); err != nil {
+				log.Printf("Failed to sync removed file %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// syncSourceFile stores the content of an extra source file, or deletes
+// it from the database once the file is gone
+func (c *ClaudeSessionSync) syncSourceFile(source, path string) error {
+	name := filepath.Base(path)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		delete(c.sourceFiles, path)
+		c.mirrors.enqueueSourceFile(SourceFile{Source: source, Name: name}, true)
+		if err := c.sink.DeleteSourceFile(source, name); err != nil {
+			return fmt.Errorf("failed to delete %s/%s: %w", source, name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || c.sourceFiles[path].unchanged(info.ModTime(), info.Size()) {
+		return nil
+	}
+	state := &fileSyncState{modTime: info.ModTime(), size: info.Size()}
+	if info.Size() > maxSourceFileSize {
+		c.sourceFiles[path] = state
+		log.Printf("Skipping %s: larger than %d bytes", path, maxSourceFileSize)
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(data) {
+		c.sourceFiles[path] = state
+		return nil
+	}
+	if redactor := c.settings().redactor; redactor != nil {
+		data = redactor.RedactRaw(data)
+	}
+	file := SourceFile{Source: source, Name: name, Content: string(data), Size: info.Size(), ModifiedAt: info.ModTime()}
+	if source == sourceTodos {
+		file.SessionID = todoFileSessionID(name)
+	}
+	c.mirrors.enqueueSourceFile(file, false)
+	if err := c.sink.StoreSourceFile(file); err != nil {
+		return fmt.Errorf("failed to store %s/%s: %w", source, name, err)
+	}
+	c.sourceFiles[path] = state
+	return nil
+}
+
+// todoFileSessionID returns the session of a todos file, which is named
+// <session>-agent-<agent>.json
+func todoFileSessionID(name string) string {
+	sessionID, _, ok := strings.Cut(strings.TrimSuffix(name, ".json"), "-agent-")
+	if !ok {
+		return ""
+	}
+	return sessionID
+}
+
+// StoreSourceFile upserts the latest content of an extra source file
+func (p postgresSink) StoreSourceFile(file SourceFile) error {
+	_, err := p.db.Exec(`
+		INSERT INTO claude_source_files (source, name, session_id, content, size, modified_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (source, name) DO UPDATE
+		SET session_id = EXCLUDED.session_id, content = EXCLUDED.content, size = EXCLUDED.size,
+		    modified_at = EXCLUDED.modified_at, synced_at = NOW()`,
+		file.Source, file.Name, file.SessionID, file.Content, file.Size, file.ModifiedAt)
+	return err
+}
+
+// DeleteSourceFile removes an extra source file that no longer exists
+func (p postgresSink) DeleteSourceFile(source, name string) error {
+	_, err := p.db.Exec(`DELETE FROM claude_source_files WHERE source = $1 AND name = $2`, source, name)
+	return err
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathFilter decides which session files are synced based on glob patterns.
+// Patterns are matched against paths relative to ~/.claude/projects using
+// forward slashes. `*` matches within a path segment, `**` matches across
+// segments, and a pattern without a slash matches any single segment.
+type PathFilter struct {
+	include []globPattern
+	exclude []globPattern
+}
+
+// globPattern is a compiled glob; patterns without a slash match single segments
+type globPattern struct {
+	re      *regexp.Regexp
+	segment bool
+}
+
+// NewPathFilter compiles include and exclude glob patterns
+func NewPathFilter(include, exclude []string) (*PathFilter, error) {
+	f := &PathFilter{}
+	for _, pattern := range include {
+		g, err := compileGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		f.include = append(f.include, g)
+	}
+	for _, pattern := range exclude {
+		g, err := compileGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		f.exclude = append(f.exclude, g)
+	}
+	return f, nil
+}
+
+// AllowFile reports whether a session file should be synced
+func (f *PathFilter) AllowFile(relPath string) bool {
+	if f == nil {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	if matchAnyPrefix(f.exclude, relPath) {
+		return false
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	return matchAnyPrefix(f.include, relPath)
+}
+
+// AllowDir reports whether a directory should be walked or watched.
+// Include patterns are not applied here since a child may still match.
+func (f *PathFilter) AllowDir(relPath string) bool {
+	if f == nil {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." || relPath == "" {
+		return true
+	}
+	return !matchAnyPrefix(f.exclude, relPath)
+}
+
+// matchAnyPrefix checks the path and each of its parent directories against the patterns
+func matchAnyPrefix(patterns []globPattern, relPath string) bool {
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		for _, g := range patterns {
+			if g.re.MatchString(prefix) || g.segment && g.re.MatchString(segments[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileGlob compiles a single glob pattern
+func compileGlob(pattern string) (globPattern, error) {
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return globPattern{}, err
+	}
+	return globPattern{re: re, segment: !strings.Contains(strings.TrimSuffix(pattern, "/"), "/")}, nil
+}
+
+// globToRegexp converts a glob pattern with ** support into an anchored regexp
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch ch {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					// "**/" matches zero or more directories
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
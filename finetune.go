@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// chatTurn is a single turn of a session normalised for fine-tuning formats
+type chatTurn struct {
+	Role       string // user, assistant or tool
+	Text       string
+	ToolCalls  []ToolCall
+	ToolCallID string
+}
+
+// chatTurns flattens a session into alternating turns. Consecutive log lines
+// from the same role are merged, and tool blocks are either dropped or kept
+// as tool calls and tool results.
+func chatTurns(session *ClaudeSession, keepTools bool) []chatTurn {
+	var turns []chatTurn
+	appendText := func(role, text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		if n := len(turns); n > 0 && turns[n-1].Role == role && (role != "assistant" || len(turns[n-1].ToolCalls) == 0) {
+			turns[n-1].Text += "\n\n" + text
+			return
+		}
+		turns = append(turns, chatTurn{Role: role, Text: text})
+	}
+
+	for _, msg := range conversationMessages(session.Messages) {
+		role := messageRole(msg)
+		if role != "user" && role != "assistant" {
+			continue
+		}
+		for _, block := range messageBlocks(msg) {
+			switch block.Type {
+			case "text":
+				appendText(role, block.Text)
+			case "tool_use":
+				if !keepTools {
+					continue
+				}
+				call := ToolCall{ID: block.ID, Name: block.Name, Input: block.Input}
+				if n := len(turns); n > 0 && turns[n-1].Role == "assistant" {
+					turns[n-1].ToolCalls = append(turns[n-1].ToolCalls, call)
+				} else {
+					turns = append(turns, chatTurn{Role: "assistant", ToolCalls: []ToolCall{call}})
+				}
+			case "tool_result":
+				if !keepTools {
+					continue
+				}
+				turns = append(turns, chatTurn{Role: "tool", Text: toolResultText(block.Content), ToolCallID: block.ToolUseID})
+			}
+		}
+	}
+	return turns
+}
+
+// trainable reports whether the turns hold at least one user and one assistant reply
+func trainable(turns []chatTurn) bool {
+	var user, assistant bool
+	for _, turn := range turns {
+		user = user || turn.Role == "user"
+		assistant = assistant || turn.Role == "assistant"
+	}
+	return user && assistant
+}
+
+// toolArguments returns tool input as the JSON string OpenAI expects for arguments
+func toolArguments(input json.RawMessage) string {
+	if !json.Valid(input) {
+		return "{}"
+	}
+	return string(input)
+}
+
+// openAIExporter writes one OpenAI chat fine-tuning record per session
+func openAIExporter(keepTools bool) sessionExporter {
+	return func(w io.Writer, session *ClaudeSession) error {
+		turns := chatTurns(session, keepTools)
+		if !trainable(turns) {
+			return nil
+		}
+
+		messages := make([]map[string]interface{}, 0, len(turns))
+		for _, turn := range turns {
+			m := map[string]interface{}{"role": turn.Role, "content": turn.Text}
+			if turn.ToolCallID != "" {
+				m["tool_call_id"] = turn.ToolCallID
+			}
+			if len(turn.ToolCalls) > 0 {
+				calls := make([]map[string]interface{}, 0, len(turn.ToolCalls))
+				for _, call := range turn.ToolCalls {
+					calls = append(calls, map[string]interface{}{
+						"id":   call.ID,
+						"type": "function",
+						"function": map[string]string{
+							"name":      call.Name,
+							"arguments": toolArguments(call.Input),
+						},
+					})
+				}
+				m["tool_calls"] = calls
+				if turn.Text == "" {
+					m["content"] = nil
+				}
+			}
+			messages = append(messages, m)
+		}
+		return json.NewEncoder(w).Encode(map[string]interface{}{"messages": messages})
+	}
+}
+
+// shareGPTExporter writes one ShareGPT conversation record per session
+func shareGPTExporter(keepTools bool) sessionExporter {
+	return func(w io.Writer, session *ClaudeSession) error {
+		turns := chatTurns(session, keepTools)
+		if !trainable(turns) {
+			return nil
+		}
+
+		var conversation []map[string]string
+		for _, turn := range turns {
+			switch turn.Role {
+			case "user":
+				conversation = append(conversation, map[string]string{"from": "human", "value": turn.Text})
+			case "assistant":
+				if turn.Text != "" {
+					conversation = append(conversation, map[string]string{"from": "gpt", "value": turn.Text})
+				}
+				for _, call := range turn.ToolCalls {
+					value, err := json.Marshal(map[string]interface{}{"name": call.Name, "arguments": json.RawMessage(toolArguments(call.Input))})
+					if err != nil {
+						return fmt.Errorf("failed to encode tool call: %w", err)
+					}
+					conversation = append(conversation, map[string]string{"from": "function_call", "value": string(value)})
+				}
+			case "tool":
+				conversation = append(conversation, map[string]string{"from": "observation", "value": turn.Text})
+			}
+		}
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            session.SessionID,
+			"conversations": conversation,
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// gcChecks are the kinds of orphans gc looks for, in the order they run.
+// Sessions go first so rows and blobs they leave behind are collected too.
+var gcChecks = []string{"sessions", "rows", "prompts", "context", "blobs"}
+
+// gcUploadGrace keeps uploads that have no session yet because their first
+// lines held no messages, as long as the client may still be sending more
+const gcUploadGrace = 24 * time.Hour
+
+// gcBlobKeyPattern finds blob and attachment keys in the JSON text of messages
+const gcBlobKeyPattern = `"(?:key|id)": "([0-9a-f]{64})"`
+
+// gcRun holds the options of one gc run
+type gcRun struct {
+	db     *sql.DB
+	dryRun bool
+}
+
+// removed prints what a check found and whether it was removed
+func (g *gcRun) removed(n int, what string) {
+	switch {
+	case n == 0:
+		fmt.Printf("  ✓ No %s\n", what)
+	case g.dryRun:
+		fmt.Printf("  🔍 %d %s would be removed\n", n, what)
+	default:
+		fmt.Printf("  🧹 Removed %d %s\n", n, what)
+	}
+}
+
+// orphanSessions finds sessions synced from this machine whose project
+// directory is gone, or with missingFiles whose own transcript is gone.
+// Claude Code deletes old transcripts by itself, so a missing file alone
+// does not mean the session should go.
+func orphanSessions(db *sql.DB, claudeDir string, filter SessionFilter, missingFiles bool) ([]string, error) {
+	projectsDir := filepath.Join(claudeDir, "projects")
+	if _, err := os.Stat(projectsDir); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", projectsDir, err)
+	}
+
+	where, args := filter.where()
+	rows, err := db.Query(`SELECT session_id, COALESCE(metadata->>'source_file', '') FROM claude_sessions WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id, sourceFile string
+		if err := rows.Scan(&id, &sourceFile); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		// Uploads and sessions synced from other machines have no file here
+		rel, err := filepath.Rel(projectsDir, sourceFile)
+		if sourceFile == "" || err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		check := filepath.Dir(sourceFile)
+		if missingFiles {
+			check = sourceFile
+		}
+		if _, err := os.Stat(check); os.IsNotExist(err) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// collectSessions soft deletes or purges sessions whose source is gone
+func (g *gcRun) collectSessions(filter SessionFilter, missingFiles, purge bool) error {
+	claudeDir, err := defaultClaudeDir()
+	if err != nil {
+		return err
+	}
+	// Purging also takes sessions that were already soft deleted
+	filter.IncludeDeleted = purge
+	ids, err := orphanSessions(g.db, claudeDir, filter, missingFiles)
+	if err != nil {
+		return err
+	}
+	what := "sessions of deleted projects"
+	if missingFiles {
+		what = "sessions with missing transcripts"
+	}
+	if len(ids) > 0 && !g.dryRun {
+		if _, err := deleteSessions(g.db, SessionFilter{IDs: ids}, purge); err != nil {
+			return err
+		}
+	}
+	g.removed(len(ids), what)
+	return nil
+}
+
+// collectRows removes rows of session tables whose session no longer exists
+func (g *gcRun) collectRows() error {
+	total := 0
+	for _, table := range sessionChildTables {
+		cond := `NOT EXISTS (SELECT 1 FROM claude_sessions s WHERE s.session_id = t.session_id)`
+		switch table {
+		case "session_uploads":
+			cond += fmt.Sprintf(` AND t.updated_at < NOW() - make_interval(secs => %d)`, int(gcUploadGrace.Seconds()))
+		case "session_links":
+			cond = `(` + cond + ` OR NOT EXISTS (SELECT 1 FROM claude_sessions s WHERE s.session_id = t.predecessor_id))`
+		case "claude_source_files":
+			// Files not tied to any session have an empty session_id
+			cond += ` AND t.session_id <> ''`
+		}
+		query := `DELETE FROM ` + table + ` t WHERE ` + cond
+		if g.dryRun {
+			query = `SELECT COUNT(*) FROM ` + table + ` t WHERE ` + cond
+		}
+		n, err := g.count(query)
+		if err != nil {
+			return fmt.Errorf("failed to collect %s: %w", table, err)
+		}
+		if n > 0 {
+			fmt.Printf("    %s: %d\n", table, n)
+		}
+		total += n
+	}
+	g.removed(total, "orphaned session rows")
+	return nil
+}
+
+// collectPrompts removes prompts no session uses any more
+func (g *gcRun) collectPrompts() error {
+	cond := unusedPrompt
+	query := `DELETE FROM prompts p WHERE ` + cond
+	if g.dryRun {
+		query = `SELECT COUNT(*) FROM prompts p WHERE ` + cond
+	}
+	n, err := g.count(query)
+	if err != nil {
+		return fmt.Errorf("failed to collect prompts: %w", err)
+	}
+	g.removed(n, "unused prompts")
+	return nil
+}
+
+// collectContextFiles removes context file contents no session links to
+func (g *gcRun) collectContextFiles() error {
+	cond := unlinkedContextFile
+	query := `DELETE FROM context_files c WHERE ` + cond
+	if g.dryRun {
+		query = `SELECT COUNT(*) FROM context_files c WHERE ` + cond
+	}
+	n, err := g.count(query)
+	if err != nil {
+		return fmt.Errorf("failed to collect context files: %w", err)
+	}
+	g.removed(n, "unused context files")
+	return nil
+}
+
+// count runs a COUNT query, or a statement whose affected rows are counted
+func (g *gcRun) count(query string) (int, error) {
+	if g.dryRun {
+		var n int
+		err := g.db.QueryRow(query).Scan(&n)
+		return n, err
+	}
+	result, err := g.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// collectBlobs removes blobs and attachments no session refers to. Only the
+// local store can be listed; soft deleted sessions keep their blobs.
+func (g *gcRun) collectBlobs(config BlobConfig) error {
+	store, err := newBlobStore(config)
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		fmt.Printf("  ⏭️  Skipping blobs: blobs are not stored\n")
+		return nil
+	}
+	local, ok := store.(localBlobStore)
+	if !ok {
+		fmt.Printf("  ⏭️  Skipping blobs: the %s store cannot be listed\n", config.Store)
+		return nil
+	}
+
+	keys, err := local.keys()
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{}
+	rows, err := g.db.Query(`SELECT DISTINCT m[1] FROM claude_sessions, regexp_matches(messages::text, $1, 'g') AS m`, gcBlobKeyPattern)
+	if err != nil {
+		return fmt.Errorf("failed to list blob references: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return fmt.Errorf("failed to scan blob reference: %w", err)
+		}
+		referenced[key] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	n := 0
+	for _, key := range keys {
+		if referenced[key] {
+			continue
+		}
+		n++
+		if !g.dryRun {
+			if err := os.Remove(local.path(key)); err != nil {
+				return fmt.Errorf("failed to remove blob %s: %w", key, err)
+			}
+		}
+	}
+	g.removed(n, "unreferenced blobs")
+	return nil
+}
+
+// gcCommand finds and removes what deleted projects and purged sessions
+// leave behind in the database and the blob store
+func gcCommand(c *cli.Context) error {
+	only := map[string]bool{}
+	for _, check := range c.StringSlice("only") {
+		valid := false
+		for _, known := range gcChecks {
+			valid = valid || check == known
+		}
+		if !valid {
+			return fmt.Errorf("unknown check %q (valid: %s)", check, strings.Join(gcChecks, ", "))
+		}
+		only[check] = true
+	}
+	before, err := parseFilterTime(c.String("before"), time.UTC)
+	if err != nil {
+		return fmt.Errorf("invalid before: %w", err)
+	}
+	filter := SessionFilter{Project: c.String("project"), Before: before}
+
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	g := &gcRun{db: db, dryRun: c.Bool("dry-run")}
+	for _, check := range gcChecks {
+		if len(only) > 0 && !only[check] {
+			continue
+		}
+		fmt.Printf("🔎 Checking %s...\n", check)
+		switch check {
+		case "sessions":
+			err = g.collectSessions(filter, c.Bool("missing-files"), c.Bool("purge"))
+		case "rows":
+			err = g.collectRows()
+		case "prompts":
+			err = g.collectPrompts()
+		case "context":
+			err = g.collectContextFiles()
+		case "blobs":
+			err = g.collectBlobs(config.Blobs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if g.dryRun {
+		fmt.Printf("🔍 Dry run, nothing was removed\n")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commitWindowSlack extends the session window to catch commits made just after it ended
+const commitWindowSlack = 15 * time.Minute
+
+// GitCommit is a commit made in the project while a session was active
+type GitCommit struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// enrichGitCommits records the project path and the commits made during the session
+func enrichGitCommits(session *ClaudeSession, filePath string) {
+	projectPath := sessionProjectPath(session.Messages, filePath)
+	if projectPath == "" {
+		return
+	}
+	session.Metadata["project_path"] = projectPath
+
+	start, end, ok := sessionWindow(session.Messages)
+	if !ok {
+		return
+	}
+	commits, err := gitCommitsBetween(projectPath, start, end.Add(commitWindowSlack))
+	if err != nil {
+		// Not a git repository, or git is not installed
+		return
+	}
+	session.Metadata["commits"] = commits
+}
+
+// sessionProjectPath returns the working directory recorded in the session,
+// falling back to decoding the project directory name
+func sessionProjectPath(messages []SessionMessage, filePath string) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Cwd != "" {
+			return messages[i].Cwd
+		}
+	}
+	return decodeProjectDir(filepath.Base(filepath.Dir(filePath)))
+}
+
+// decodeProjectDir reverses Claude Code's project directory naming, where
+// path separators and dots become dashes. Because real names may contain
+// dashes too, candidates are checked against the filesystem.
+func decodeProjectDir(name string) string {
+	if !strings.HasPrefix(name, "-") {
+		return ""
+	}
+	parts := strings.Split(name[1:], "-")
+
+	var walk func(dir string, i int) string
+	walk = func(dir string, i int) string {
+		if i == len(parts) {
+			return dir
+		}
+		// An empty part comes from "/." so the next segment is a dot directory
+		prefix, k := "", i
+		if parts[i] == "" {
+			prefix, k = ".", i+1
+		}
+		// Try the longest segment first so "my-app" wins over "my/app"
+		for j := len(parts); j > k; j-- {
+			path := filepath.Join(dir, prefix+strings.Join(parts[k:j], "-"))
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				if found := walk(path, j); found != "" {
+					return found
+				}
+			}
+		}
+		return ""
+	}
+	return walk(string(filepath.Separator), 0)
+}
+
+// sessionWindow returns the first and last message timestamps
+func sessionWindow(messages []SessionMessage) (time.Time, time.Time, bool) {
+	var start, end time.Time
+	for _, msg := range messages {
+		t, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+		if err != nil {
+			continue
+		}
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+		if t.After(end) {
+			end = t
+		}
+	}
+	return start, end, !start.IsZero()
+}
+
+// gitCommitsBetween lists commits on any branch of the repository at dir
+// committed within the given window, oldest first
+func gitCommitsBetween(dir string, since, until time.Time) ([]GitCommit, error) {
+	cmd := exec.Command("git", "-C", dir, "log", "--all", "--reverse",
+		"--since="+since.Format(time.RFC3339), "--until="+until.Format(time.RFC3339),
+		"--format=%H%x1f%an%x1f%cI%x1f%s%x1e")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+
+	commits := []GitCommit{}
+	for _, record := range bytes.Split(out, []byte{0x1e}) {
+		fields := strings.Split(strings.TrimSpace(string(record)), "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, GitCommit{SHA: fields[0], Author: fields[1], Date: date, Subject: fields[3]})
+	}
+	return commits, nil
+}
+
+// handleSessionCommits serves GET /api/sessions/{id}/commits
+func (a *apiServer) handleSessionCommits(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+
+	commits := session.Metadata["commits"]
+	if commits == nil {
+		commits = []GitCommit{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"session_id":   session.SessionID,
+		"project_path": session.Metadata["project_path"],
+		"commits":      commits,
+	})
+}
@@ -0,0 +1,739 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/lib/pq"
+)
+
+// graphqlSchema describes the sessions, messages, tools, todos and outcome
+// analytics of POST /api/graphql. It mirrors the REST endpoints so the
+// frontend can fetch nested fields, such as each session's tags and last
+// message, in one round trip.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+scalar Time
+
+type Query {
+	# Sessions, most recently updated first. after is the nextCursor of the
+	# previous page; updatedBefore and updatedAfter bound the update time.
+	sessions(first: Int = 50, after: String, project: String, query: String, text: String, tag: String, updatedBefore: Time, updatedAfter: Time): SessionConnection!
+	session(id: ID!): Session
+	todos(status: String, session: ID, first: Int = 500, after: String): TodoConnection!
+	outcomes(project: String, tag: String, updatedBefore: Time, updatedAfter: Time): OutcomeReport!
+}
+
+type SessionConnection {
+	nodes: [Session!]!
+	nextCursor: String
+	# total is only counted for the first page
+	total: Int
+}
+
+type Session {
+	id: ID!
+	title: String!
+	project: String!
+	messageCount: Int!
+	createdAt: Time!
+	updatedAt: Time!
+	# startedAt and endedAt are the first and last message timestamps
+	startedAt: Time
+	endedAt: Time
+	# durationMs is a Float since sessions can outlast a 32-bit Int
+	durationMs: Float
+	# match is the first message containing the text filter
+	match: String
+	tags: [String!]!
+	lastMessage: Message
+	messages(offset: Int = 0, first: Int): [Message!]!
+	toolCalls: [ToolCall!]!
+	todos: [Todo!]!
+	outcome: Outcome
+}
+
+type Message {
+	index: Int!
+	uuid: String
+	type: String!
+	role: String!
+	content: String!
+	preview(length: Int = 120): String!
+	timestamp: Time
+}
+
+type ToolCall {
+	id: String!
+	name: String!
+	# input and result are JSON encoded
+	input: String
+	result: String
+	isError: Boolean!
+	messageIndex: Int!
+	messageUuid: String
+	timestamp: Time
+}
+
+type TodoConnection {
+	nodes: [Todo!]!
+	nextCursor: String
+}
+
+type Todo {
+	sessionId: ID!
+	sessionTitle: String
+	key: String!
+	position: Int!
+	content: String!
+	activeForm: String
+	priority: String
+	status: String!
+	createdAt: Time
+	updatedAt: Time
+	completedAt: Time
+}
+
+type Outcome {
+	outcome: String!
+	rating: Int
+	note: String
+	setAt: Time!
+}
+
+type OutcomeReport {
+	sessions: Int!
+	ratings: [RatingCount!]!
+	outcomes: [OutcomeStats!]!
+	tools: [ToolOutcomeStats!]!
+}
+
+type RatingCount {
+	rating: Int!
+	sessions: Int!
+}
+
+type OutcomeStats {
+	outcome: String!
+	sessions: Int!
+	rated: Int!
+	avgRating: Float!
+	avgInputTokens: Float!
+	avgOutputTokens: Float!
+	medianTotalTokens: Float!
+	avgDurationSeconds: Float!
+	medianDurationSeconds: Float!
+	avgToolCalls: Float!
+	toolErrorRate: Float!
+	tools: [OutcomeToolStats!]!
+}
+
+type OutcomeToolStats {
+	name: String!
+	sessions: Int!
+	calls: Int!
+}
+
+type ToolOutcomeStats {
+	name: String!
+	sessions: Int!
+	successRate: Float!
+}
+`
+
+// graphqlMaxDepth bounds query nesting, which the schema never needs deeper
+const graphqlMaxDepth = 8
+
+// graphqlRequest is the body of POST /api/graphql
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// graphqlHandler serves POST /api/graphql. Query errors are reported in the
+// errors field of a 200 response, as GraphQL clients expect.
+func (a *apiServer) graphqlHandler() http.HandlerFunc {
+	schema := graphql.MustParseSchema(graphqlSchema, &gqlQuery{api: a}, graphql.MaxDepth(graphqlMaxDepth))
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Query == "" {
+			writeJSONError(w, r, http.StatusBadRequest, "query is required", nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, schema.Exec(r.Context(), req.Query, req.OperationName, req.Variables))
+	}
+}
+
+// gqlQuery resolves the fields of the Query type
+type gqlQuery struct {
+	api *apiServer
+}
+
+// gqlTime converts an optional time argument to a filter bound
+func gqlTime(t *graphql.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.Time
+}
+
+// gqlString dereferences an optional string argument
+func gqlString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// gqlList returns an optional string as a list of it, empty when it is null
+func gqlList(s *string) []string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return []string{*s}
+}
+
+// gqlOptional returns nil for empty strings, which GraphQL reports as null
+func gqlOptional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// gqlOptionalTime returns nil for missing times
+func gqlOptionalTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+// Sessions resolves Query.sessions with the filters and cursor of GET /api/sessions
+func (q *gqlQuery) Sessions(ctx context.Context, args struct {
+	First         int32
+	After         *string
+	Project       *string
+	Query         *string
+	Text          *string
+	Tag           *string
+	UpdatedBefore *graphql.Time
+	UpdatedAfter  *graphql.Time
+}) (*gqlSessionConnection, error) {
+	if args.First < 1 || args.First > 500 {
+		return nil, fmt.Errorf("first must be between 1 and 500")
+	}
+	filter := SessionFilter{
+		Project: gqlString(args.Project),
+		Query:   gqlString(args.Query),
+		Text:    gqlString(args.Text),
+		Tags:    gqlList(args.Tag),
+		Before:  gqlTime(args.UpdatedBefore),
+		After:   gqlTime(args.UpdatedAfter),
+	}
+	page := pageRequest{Limit: int(args.First), Cursor: gqlString(args.After)}
+	var cursor sessionCursor
+	var after *sessionCursor
+	if ok, err := page.decode(&cursor.At, &cursor.SessionID); err != nil {
+		return nil, err
+	} else if ok {
+		after = &cursor
+	}
+
+	summaries, err := listSessionSummaries(q.api.db, filter, after, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	result := newPage(summaries, page, func(s SessionSummary) string {
+		return encodeCursor(s.UpdatedAt, s.SessionID)
+	})
+	conn := &gqlSessionConnection{nextCursor: result.NextCursor}
+	if after == nil {
+		total, err := countSessions(q.api.db, filter)
+		if err != nil {
+			return nil, err
+		}
+		n := int32(total)
+		conn.total = &n
+	}
+
+	batch := &gqlSessionBatch{db: q.api.db}
+	for _, s := range result.Data {
+		batch.ids = append(batch.ids, s.SessionID)
+		conn.nodes = append(conn.nodes, &gqlSession{db: q.api.db, summary: s, batch: batch})
+	}
+	return conn, nil
+}
+
+// Session resolves Query.session, null when the session does not exist
+func (q *gqlQuery) Session(ctx context.Context, args struct{ ID graphql.ID }) (*gqlSession, error) {
+	session, err := loadSession(q.api.db, string(args.ID))
+	if err != nil {
+		if errors.Is(err, errSessionNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	summary := SessionSummary{
+		SessionID:    session.SessionID,
+		Title:        session.Title,
+		Messages:     len(session.Messages),
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+		SessionTimes: session.SessionTimes,
+	}
+	if sourceFile, ok := session.Metadata["source_file"].(string); ok && sourceFile != "" {
+		summary.Project = filepath.Base(filepath.Dir(sourceFile))
+	}
+	return &gqlSession{db: q.api.db, summary: summary, full: session}, nil
+}
+
+// Todos resolves Query.todos with the filters and cursor of GET /api/todos
+func (q *gqlQuery) Todos(ctx context.Context, args struct {
+	Status  *string
+	Session *graphql.ID
+	First   int32
+	After   *string
+}) (*gqlTodoConnection, error) {
+	status := gqlString(args.Status)
+	if status != "" && !todoStatuses[status] {
+		return nil, fmt.Errorf("status must be one of pending, in_progress, completed or removed")
+	}
+	if args.First < 1 || args.First > 5000 {
+		return nil, fmt.Errorf("first must be between 1 and 5000")
+	}
+	var sessionID string
+	if args.Session != nil {
+		sessionID = string(*args.Session)
+	}
+	page := pageRequest{Limit: int(args.First), Cursor: gqlString(args.After)}
+	var cursor todoCursor
+	var after *todoCursor
+	if ok, err := page.decode(&cursor.UpdatedAt, &cursor.SessionID, &cursor.Position); err != nil {
+		return nil, err
+	} else if ok {
+		after = &cursor
+	}
+
+	todos, err := listTodos(q.api.db, status, sessionID, after, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	result := newPage(todos, page, func(t SessionTodo) string {
+		return encodeCursor(t.UpdatedAt, t.SessionID, t.Position)
+	})
+	conn := &gqlTodoConnection{nextCursor: result.NextCursor}
+	for _, todo := range result.Data {
+		conn.nodes = append(conn.nodes, &gqlTodo{todo})
+	}
+	return conn, nil
+}
+
+// Outcomes resolves Query.outcomes, the report of GET /api/analytics/outcomes
+func (q *gqlQuery) Outcomes(ctx context.Context, args struct {
+	Project       *string
+	Tag           *string
+	UpdatedBefore *graphql.Time
+	UpdatedAfter  *graphql.Time
+}) (*gqlOutcomeReport, error) {
+	report, err := queryOutcomeReport(q.api.db, SessionFilter{
+		Project: gqlString(args.Project),
+		Tags:    gqlList(args.Tag),
+		Before:  gqlTime(args.UpdatedBefore),
+		After:   gqlTime(args.UpdatedAfter),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &gqlOutcomeReport{report}, nil
+}
+
+// gqlSessionConnection is a page of sessions
+type gqlSessionConnection struct {
+	nodes      []*gqlSession
+	nextCursor string
+	total      *int32
+}
+
+func (c *gqlSessionConnection) Nodes() []*gqlSession { return c.nodes }
+func (c *gqlSessionConnection) NextCursor() *string  { return gqlOptional(c.nextCursor) }
+func (c *gqlSessionConnection) Total() *int32        { return c.total }
+
+// gqlSessionBatch loads the tags and last message of every session on a
+// page in one query, the first time any of them is asked for
+type gqlSessionBatch struct {
+	db   *sql.DB
+	ids  []string
+	once sync.Once
+	tags map[string][]string
+	last map[string]gqlLastMessage
+	err  error
+}
+
+// gqlLastMessage is the last message of a session and its position
+type gqlLastMessage struct {
+	index   int
+	message SessionMessage
+}
+
+func (b *gqlSessionBatch) load() error {
+	b.once.Do(func() {
+		b.tags = make(map[string][]string)
+		b.last = make(map[string]gqlLastMessage)
+		rows, err := b.db.Query(`
+			SELECT session_id, COALESCE(metadata->'tags', '[]'::jsonb), messages->-1, jsonb_array_length(messages)
+			FROM claude_sessions
+			WHERE session_id = ANY($1)`, pq.Array(b.ids))
+		if err != nil {
+			b.err = fmt.Errorf("failed to load session previews: %w", err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			var tags, last []byte
+			var count int
+			if err := rows.Scan(&id, &tags, &last, &count); err != nil {
+				b.err = fmt.Errorf("failed to scan session preview: %w", err)
+				return
+			}
+			var list []string
+			json.Unmarshal(tags, &list)
+			b.tags[id] = list
+			var msg SessionMessage
+			if len(last) > 0 && json.Unmarshal(last, &msg) == nil {
+				b.last[id] = gqlLastMessage{index: count - 1, message: msg}
+			}
+		}
+		b.err = rows.Err()
+	})
+	return b.err
+}
+
+// gqlSession resolves a session. Listed sessions start from their summary
+// and load their messages only when a field needs them.
+type gqlSession struct {
+	db      *sql.DB
+	summary SessionSummary
+	batch   *gqlSessionBatch
+	once    sync.Once
+	full    *ClaudeSession
+	err     error
+}
+
+// session loads the full session once, whichever field asks first
+func (s *gqlSession) session() (*ClaudeSession, error) {
+	s.once.Do(func() {
+		if s.full == nil {
+			s.full, s.err = loadSession(s.db, s.summary.SessionID)
+		}
+	})
+	return s.full, s.err
+}
+
+func (s *gqlSession) ID() graphql.ID          { return graphql.ID(s.summary.SessionID) }
+func (s *gqlSession) Title() string           { return s.summary.Title }
+func (s *gqlSession) Project() string         { return s.summary.Project }
+func (s *gqlSession) MessageCount() int32     { return int32(s.summary.Messages) }
+func (s *gqlSession) CreatedAt() graphql.Time { return graphql.Time{Time: s.summary.CreatedAt} }
+func (s *gqlSession) UpdatedAt() graphql.Time { return graphql.Time{Time: s.summary.UpdatedAt} }
+func (s *gqlSession) Match() *string          { return gqlOptional(s.summary.Match) }
+
+func (s *gqlSession) StartedAt() *graphql.Time { return gqlOptionalTime(s.summary.StartedAt) }
+func (s *gqlSession) EndedAt() *graphql.Time   { return gqlOptionalTime(s.summary.EndedAt) }
+
+func (s *gqlSession) DurationMs() *float64 {
+	if s.summary.DurationMS == nil {
+		return nil
+	}
+	duration := float64(*s.summary.DurationMS)
+	return &duration
+}
+
+func (s *gqlSession) Tags() ([]string, error) {
+	if s.batch == nil {
+		session, err := s.session()
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		if list, ok := session.Metadata["tags"].([]interface{}); ok {
+			for _, tag := range list {
+				if tag, ok := tag.(string); ok {
+					tags = append(tags, tag)
+				}
+			}
+		}
+		return tags, nil
+	}
+	if err := s.batch.load(); err != nil {
+		return nil, err
+	}
+	return s.batch.tags[s.summary.SessionID], nil
+}
+
+func (s *gqlSession) LastMessage() (*gqlMessage, error) {
+	if s.batch == nil {
+		session, err := s.session()
+		if err != nil || len(session.Messages) == 0 {
+			return nil, err
+		}
+		n := len(session.Messages) - 1
+		return &gqlMessage{index: n, msg: session.Messages[n]}, nil
+	}
+	if err := s.batch.load(); err != nil {
+		return nil, err
+	}
+	last, ok := s.batch.last[s.summary.SessionID]
+	if !ok {
+		return nil, nil
+	}
+	return &gqlMessage{index: last.index, msg: last.message}, nil
+}
+
+func (s *gqlSession) Messages(args struct {
+	Offset int32
+	First  *int32
+}) ([]*gqlMessage, error) {
+	session, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	if args.Offset < 0 || (args.First != nil && *args.First < 0) {
+		return nil, fmt.Errorf("offset and first cannot be negative")
+	}
+	start, end := int(args.Offset), len(session.Messages)
+	if start > end {
+		start = end
+	}
+	if args.First != nil && start+int(*args.First) < end {
+		end = start + int(*args.First)
+	}
+	messages := make([]*gqlMessage, 0, end-start)
+	for i := start; i < end; i++ {
+		messages = append(messages, &gqlMessage{index: i, msg: session.Messages[i]})
+	}
+	return messages, nil
+}
+
+func (s *gqlSession) ToolCalls() ([]*gqlToolCall, error) {
+	session, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	var calls []*gqlToolCall
+	for _, call := range extractToolCalls(session.Messages) {
+		calls = append(calls, &gqlToolCall{call})
+	}
+	return calls, nil
+}
+
+func (s *gqlSession) Todos() ([]*gqlTodo, error) {
+	todos, err := listTodos(s.db, "", s.summary.SessionID, nil, 5000)
+	if err != nil {
+		return nil, err
+	}
+	var list []*gqlTodo
+	for _, todo := range todos {
+		list = append(list, &gqlTodo{todo})
+	}
+	return list, nil
+}
+
+func (s *gqlSession) Outcome() (*gqlOutcome, error) {
+	outcome, err := loadOutcome(s.db, s.summary.SessionID)
+	if err != nil || outcome == nil {
+		return nil, err
+	}
+	return &gqlOutcome{outcome}, nil
+}
+
+// gqlMessage resolves a message and its position in the session
+type gqlMessage struct {
+	index int
+	msg   SessionMessage
+}
+
+func (m *gqlMessage) Index() int32    { return int32(m.index) }
+func (m *gqlMessage) UUID() *string   { return gqlOptional(m.msg.UUID) }
+func (m *gqlMessage) Type() string    { return m.msg.Type }
+func (m *gqlMessage) Role() string    { return messageRole(m.msg) }
+func (m *gqlMessage) Content() string { return m.msg.Content }
+
+func (m *gqlMessage) Preview(args struct{ Length int32 }) string {
+	if args.Length < 1 {
+		return ""
+	}
+	return truncateText(m.msg.Content, int(args.Length))
+}
+
+func (m *gqlMessage) Timestamp() *graphql.Time {
+	if t, ok := messageTime(m.msg.Timestamp).(time.Time); ok {
+		return &graphql.Time{Time: t}
+	}
+	return nil
+}
+
+// gqlToolCall resolves a tool call
+type gqlToolCall struct {
+	call ToolCall
+}
+
+func (t *gqlToolCall) ID() string          { return t.call.ID }
+func (t *gqlToolCall) Name() string        { return t.call.Name }
+func (t *gqlToolCall) Input() *string      { return gqlOptional(string(t.call.Input)) }
+func (t *gqlToolCall) Result() *string     { return gqlOptional(string(t.call.Result)) }
+func (t *gqlToolCall) IsError() bool       { return t.call.IsError }
+func (t *gqlToolCall) MessageIndex() int32 { return int32(t.call.MessageIndex) }
+func (t *gqlToolCall) MessageUUID() *string {
+	return gqlOptional(t.call.MessageUUID)
+}
+
+func (t *gqlToolCall) Timestamp() *graphql.Time {
+	if at, ok := messageTime(t.call.Timestamp).(time.Time); ok {
+		return &graphql.Time{Time: at}
+	}
+	return nil
+}
+
+// gqlTodoConnection is a page of todos
+type gqlTodoConnection struct {
+	nodes      []*gqlTodo
+	nextCursor string
+}
+
+func (c *gqlTodoConnection) Nodes() []*gqlTodo   { return c.nodes }
+func (c *gqlTodoConnection) NextCursor() *string { return gqlOptional(c.nextCursor) }
+
+// gqlTodo resolves a todo
+type gqlTodo struct {
+	todo SessionTodo
+}
+
+func (t *gqlTodo) SessionID() graphql.ID      { return graphql.ID(t.todo.SessionID) }
+func (t *gqlTodo) SessionTitle() *string      { return gqlOptional(t.todo.SessionTitle) }
+func (t *gqlTodo) Key() string                { return t.todo.Key }
+func (t *gqlTodo) Position() int32            { return int32(t.todo.Position) }
+func (t *gqlTodo) Content() string            { return t.todo.Content }
+func (t *gqlTodo) ActiveForm() *string        { return gqlOptional(t.todo.ActiveForm) }
+func (t *gqlTodo) Priority() *string          { return gqlOptional(t.todo.Priority) }
+func (t *gqlTodo) Status() string             { return t.todo.Status }
+func (t *gqlTodo) CreatedAt() *graphql.Time   { return gqlOptionalTime(t.todo.CreatedAt) }
+func (t *gqlTodo) UpdatedAt() *graphql.Time   { return gqlOptionalTime(t.todo.UpdatedAt) }
+func (t *gqlTodo) CompletedAt() *graphql.Time { return gqlOptionalTime(t.todo.CompletedAt) }
+
+// gqlOutcome resolves a session's recorded outcome
+type gqlOutcome struct {
+	outcome *SessionOutcome
+}
+
+func (o *gqlOutcome) Outcome() string     { return o.outcome.Outcome }
+func (o *gqlOutcome) Note() *string       { return gqlOptional(o.outcome.Note) }
+func (o *gqlOutcome) SetAt() graphql.Time { return graphql.Time{Time: o.outcome.SetAt} }
+func (o *gqlOutcome) Rating() *int32 {
+	if o.outcome.Rating == nil {
+		return nil
+	}
+	r := int32(*o.outcome.Rating)
+	return &r
+}
+
+// gqlOutcomeReport resolves the outcome analytics
+type gqlOutcomeReport struct {
+	report OutcomeReport
+}
+
+// gqlRatingCount is how many sessions got a rating
+type gqlRatingCount struct {
+	rating, sessions int32
+}
+
+func (c gqlRatingCount) Rating() int32   { return c.rating }
+func (c gqlRatingCount) Sessions() int32 { return c.sessions }
+
+func (r *gqlOutcomeReport) Sessions() int32 { return int32(r.report.Sessions) }
+
+func (r *gqlOutcomeReport) Ratings() []gqlRatingCount {
+	counts := []gqlRatingCount{}
+	for rating, n := range r.report.Ratings {
+		counts = append(counts, gqlRatingCount{int32(rating), int32(n)})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].rating < counts[j].rating })
+	return counts
+}
+
+func (r *gqlOutcomeReport) Outcomes() []*gqlOutcomeStats {
+	var stats []*gqlOutcomeStats
+	for _, s := range r.report.Outcomes {
+		stats = append(stats, &gqlOutcomeStats{s})
+	}
+	return stats
+}
+
+func (r *gqlOutcomeReport) Tools() []*gqlToolOutcomeStats {
+	var stats []*gqlToolOutcomeStats
+	for _, s := range r.report.Tools {
+		stats = append(stats, &gqlToolOutcomeStats{s})
+	}
+	return stats
+}
+
+// gqlOutcomeStats resolves the statistics of one outcome
+type gqlOutcomeStats struct {
+	s OutcomeStats
+}
+
+func (o *gqlOutcomeStats) Outcome() string                { return o.s.Outcome }
+func (o *gqlOutcomeStats) Sessions() int32                { return int32(o.s.Sessions) }
+func (o *gqlOutcomeStats) Rated() int32                   { return int32(o.s.Rated) }
+func (o *gqlOutcomeStats) AvgRating() float64             { return o.s.AvgRating }
+func (o *gqlOutcomeStats) AvgInputTokens() float64        { return o.s.AvgInputTokens }
+func (o *gqlOutcomeStats) AvgOutputTokens() float64       { return o.s.AvgOutputTokens }
+func (o *gqlOutcomeStats) MedianTotalTokens() float64     { return float64(o.s.MedianTotalTokens) }
+func (o *gqlOutcomeStats) AvgDurationSeconds() float64    { return o.s.AvgDurationSeconds }
+func (o *gqlOutcomeStats) MedianDurationSeconds() float64 { return o.s.MedianDurationSeconds }
+func (o *gqlOutcomeStats) AvgToolCalls() float64          { return o.s.AvgToolCalls }
+func (o *gqlOutcomeStats) ToolErrorRate() float64         { return o.s.ToolErrorRate }
+
+func (o *gqlOutcomeStats) Tools() []*gqlOutcomeToolStats {
+	var tools []*gqlOutcomeToolStats
+	for _, t := range o.s.Tools {
+		tools = append(tools, &gqlOutcomeToolStats{t})
+	}
+	return tools
+}
+
+// gqlOutcomeToolStats resolves a tool's use within one outcome
+type gqlOutcomeToolStats struct {
+	t OutcomeToolStats
+}
+
+func (t *gqlOutcomeToolStats) Name() string    { return t.t.Name }
+func (t *gqlOutcomeToolStats) Sessions() int32 { return int32(t.t.Sessions) }
+func (t *gqlOutcomeToolStats) Calls() int32    { return int32(t.t.Calls) }
+
+// gqlToolOutcomeStats resolves how often sessions using a tool succeeded
+type gqlToolOutcomeStats struct {
+	t ToolOutcomeStats
+}
+
+func (t *gqlToolOutcomeStats) Name() string         { return t.t.Name }
+func (t *gqlToolOutcomeStats) Sessions() int32      { return int32(t.t.Sessions) }
+func (t *gqlToolOutcomeStats) SuccessRate() float64 { return t.t.SuccessRate }
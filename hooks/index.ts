@@ -8,8 +8,12 @@ export {
   useStarRealtime, 
   useDocumentListRealtime 
 } from './useRealtime';
+export { useDevStatus } from './useDevStatus';
+export { useSessionRoom } from './useSessionRoom';
+export { useSavedViews } from './useSavedViews';
 
 export type { UseClaudeDocsOptions, UseClaudeDocsReturn } from './useClaudeDocs';
 export type { UseTagsReturn } from './useTags';
 export type { UseSearchReturn } from './useSearch';
-export type { UseRealtimeOptions, UseRealtimeReturn } from './useRealtime';
\ No newline at end of file
+export type { UseRealtimeOptions, UseRealtimeReturn } from './useRealtime';
+export type { DevEvent, UseDevStatusReturn } from './useDevStatus';
\ No newline at end of file
@@ -0,0 +1,85 @@
+import { useState, useEffect, useRef } from 'react';
+
+export interface DevEvent {
+  type: 'build_start' | 'build_finish' | 'build_error' | 'sync' | 'sync_error' | 'typecheck';
+  path?: string;
+  session_id?: string;
+  messages?: number;
+  duration_ms?: number;
+  errors?: string[];
+  time: string;
+}
+
+export interface UseDevStatusReturn {
+  connected: boolean;
+  building: string[];
+  lastBuild: DevEvent | null;
+  lastSync: DevEvent | null;
+  // The last background type check, when serve runs with --typecheck
+  lastTypecheck: DevEvent | null;
+  // Sessions synced since the last pause in sync activity
+  syncedCount: number;
+}
+
+// A gap longer than this between syncs starts a new count
+const SYNC_BATCH_GAP_MS = 60_000;
+
+// useDevStatus follows the server's /api/devstatus stream of build and sync events
+export const useDevStatus = (): UseDevStatusReturn => {
+  const [connected, setConnected] = useState(false);
+  const [building, setBuilding] = useState<string[]>([]);
+  const [lastBuild, setLastBuild] = useState<DevEvent | null>(null);
+  const [lastSync, setLastSync] = useState<DevEvent | null>(null);
+  const [lastTypecheck, setLastTypecheck] = useState<DevEvent | null>(null);
+  const [syncedCount, setSyncedCount] = useState(0);
+  const lastSyncAt = useRef(0);
+
+  useEffect(() => {
+    if (typeof EventSource === 'undefined') {
+      return;
+    }
+    const source = new EventSource('/api/devstatus');
+    const parse = (e: MessageEvent) => JSON.parse(e.data);
+
+    source.onopen = () => setConnected(true);
+    source.onerror = () => setConnected(false);
+
+    source.addEventListener('status', (e) => {
+      const status = parse(e as MessageEvent);
+      setBuilding(status.building || []);
+      setLastBuild(status.last_build || null);
+      setLastSync(status.last_sync || null);
+      setLastTypecheck(status.last_typecheck || null);
+      setSyncedCount(status.recent_syncs || 0);
+      lastSyncAt.current = status.last_sync ? Date.parse(status.last_sync.time) : 0;
+    });
+    source.addEventListener('build_start', (e) => {
+      const event: DevEvent = parse(e as MessageEvent);
+      setBuilding((paths) => (event.path && !paths.includes(event.path) ? [...paths, event.path] : paths));
+    });
+    const finishBuild = (e: Event) => {
+      const event: DevEvent = parse(e as MessageEvent);
+      setBuilding((paths) => paths.filter((p) => p !== event.path));
+      setLastBuild(event);
+    };
+    source.addEventListener('build_finish', finishBuild);
+    source.addEventListener('build_error', finishBuild);
+    const finishSync = (e: Event) => {
+      const event: DevEvent = parse(e as MessageEvent);
+      setLastSync(event);
+      if (event.type === 'sync') {
+        const at = Date.parse(event.time);
+        const gap = at - lastSyncAt.current;
+        lastSyncAt.current = at;
+        setSyncedCount((count) => (gap > SYNC_BATCH_GAP_MS ? 1 : count + 1));
+      }
+    };
+    source.addEventListener('sync', finishSync);
+    source.addEventListener('sync_error', finishSync);
+    source.addEventListener('typecheck', (e) => setLastTypecheck(parse(e as MessageEvent)));
+
+    return () => source.close();
+  }, []);
+
+  return { connected, building, lastBuild, lastSync, lastTypecheck, syncedCount };
+};
@@ -0,0 +1,100 @@
+import { useState, useEffect, useCallback } from 'react';
+
+// ViewFilter mirrors the filters of /api/sessions. Dates may be ages such as
+// 14d, which stay relative to when the view is applied.
+export interface ViewFilter {
+  project?: string;
+  q?: string;
+  text?: string;
+  tags?: string[];
+  workspace?: string;
+  after?: string;
+  before?: string;
+  tz?: string;
+  sort?: 'updated' | 'updated_asc' | 'created' | 'created_asc';
+}
+
+export interface SavedView {
+  name: string;
+  description?: string;
+  filter: ViewFilter;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface UseSavedViewsReturn {
+  views: SavedView[];
+  loading: boolean;
+  error: string | null;
+  refresh: () => Promise<void>;
+  // Saves a view, replacing the one of the same name
+  saveView: (name: string, filter: ViewFilter, description?: string) => Promise<SavedView>;
+  deleteView: (name: string) => Promise<void>;
+  // URL of the session list filtered by a view, for fetching or linking
+  viewSessionsUrl: (name: string, limit?: number) => string;
+}
+
+const request = async (url: string, init?: RequestInit) => {
+  const res = await fetch(url, init);
+  if (!res.ok) {
+    const body = await res.json().catch(() => null);
+    throw Object.assign(new Error(body?.error || `Request failed with ${res.status}`), { status: res.status });
+  }
+  return res.status === 204 ? null : res.json();
+};
+
+// useSavedViews lists the saved session filters on /api/views, the same ones
+// `claudemd views` manages from the command line
+export const useSavedViews = (): UseSavedViewsReturn => {
+  const [views, setViews] = useState<SavedView[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+
+  const refresh = useCallback(async () => {
+    setLoading(true);
+    try {
+      const page = await request('/api/views');
+      setViews(page.data);
+      setError(null);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to load views');
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  useEffect(() => {
+    refresh();
+  }, [refresh]);
+
+  const saveView = useCallback(async (name: string, filter: ViewFilter, description = '') => {
+    const init = (method: string, url: string, body: object) =>
+      request(url, { method, headers: { 'Content-Type': 'application/json' }, body: JSON.stringify(body) });
+    let view: SavedView;
+    try {
+      view = await init('POST', '/api/views', { name, description, filter });
+    } catch (err) {
+      if ((err as { status?: number }).status !== 409) {
+        throw err;
+      }
+      view = await init('PUT', `/api/views/${encodeURIComponent(name)}`, { description, filter });
+    }
+    setViews(prev => [...prev.filter(v => v.name !== name), view].sort((a, b) => a.name.localeCompare(b.name)));
+    return view;
+  }, []);
+
+  const deleteView = useCallback(async (name: string) => {
+    await request(`/api/views/${encodeURIComponent(name)}`, { method: 'DELETE' });
+    setViews(prev => prev.filter(v => v.name !== name));
+  }, []);
+
+  const viewSessionsUrl = useCallback((name: string, limit?: number) => {
+    const params = new URLSearchParams({ view: name });
+    if (limit) {
+      params.set('limit', String(limit));
+    }
+    return `/api/sessions?${params}`;
+  }, []);
+
+  return { views, loading, error, refresh, saveView, deleteView, viewSessionsUrl };
+};
@@ -0,0 +1,126 @@
+import { useState, useEffect, useRef, useCallback } from 'react';
+
+export interface ViewerSelection {
+  message: number;
+  start: number;
+  end: number;
+  text?: string;
+}
+
+export interface ViewerPointer {
+  // Index of the message at the top of the viewport
+  message: number;
+  message_uuid?: string;
+  // How far down the page the viewer is, from 0 to 1
+  scroll: number;
+  selection?: ViewerSelection;
+}
+
+export interface RoomViewer {
+  id: string;
+  name: string;
+  color: string;
+  joined_at: string;
+  pointer?: ViewerPointer;
+  updated_at: string;
+}
+
+export interface UseSessionRoomReturn {
+  connected: boolean;
+  // Our own viewer ID in the room
+  you: string | null;
+  // Everyone else viewing the session, in the order they joined
+  viewers: RoomViewer[];
+  sharePointer: (pointer: ViewerPointer) => void;
+}
+
+// Pointer updates are sent at most this often
+const POINTER_INTERVAL_MS = 150;
+
+// useSessionRoom joins the room of a session on /api/sessions/{id}/room,
+// following who else is viewing it and sharing where we are
+export const useSessionRoom = (sessionId: string | null, name?: string): UseSessionRoomReturn => {
+  const [connected, setConnected] = useState(false);
+  const [you, setYou] = useState<string | null>(null);
+  const [viewers, setViewers] = useState<RoomViewer[]>([]);
+  const youRef = useRef<string | null>(null);
+  const pending = useRef<ViewerPointer | null>(null);
+  const timer = useRef<ReturnType<typeof setTimeout> | null>(null);
+
+  useEffect(() => {
+    if (!sessionId || typeof EventSource === 'undefined') {
+      return;
+    }
+    const params = name ? `?name=${encodeURIComponent(name)}` : '';
+    const source = new EventSource(`/api/sessions/${encodeURIComponent(sessionId)}/room${params}`);
+    const parse = (e: Event) => JSON.parse((e as MessageEvent).data);
+
+    source.onopen = () => setConnected(true);
+    source.onerror = () => setConnected(false);
+
+    // A reconnect joins the room again as a new viewer, starting with state
+    source.addEventListener('state', (e) => {
+      const event = parse(e);
+      youRef.current = event.you;
+      setYou(event.you);
+      setViewers((event.viewers || []).filter((v: RoomViewer) => v.id !== event.you));
+    });
+    source.addEventListener('join', (e) => {
+      const { viewer } = parse(e);
+      setViewers((current) => [...current.filter((v) => v.id !== viewer.id), viewer]);
+    });
+    source.addEventListener('leave', (e) => {
+      const { viewer } = parse(e);
+      setViewers((current) => current.filter((v) => v.id !== viewer.id));
+    });
+    source.addEventListener('pointer', (e) => {
+      const { viewer } = parse(e);
+      setViewers((current) => current.map((v) => (v.id === viewer.id ? viewer : v)));
+    });
+
+    return () => {
+      source.close();
+      youRef.current = null;
+      setYou(null);
+      setViewers([]);
+      setConnected(false);
+    };
+  }, [sessionId, name]);
+
+  const flush = useCallback(() => {
+    timer.current = null;
+    const pointer = pending.current;
+    pending.current = null;
+    if (!pointer || !sessionId || !youRef.current) {
+      return;
+    }
+    fetch(`/api/sessions/${encodeURIComponent(sessionId)}/room/pointer`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ viewer: youRef.current, pointer }),
+    }).catch(() => {
+      // The next pointer update tries again
+    });
+  }, [sessionId]);
+
+  const sharePointer = useCallback(
+    (pointer: ViewerPointer) => {
+      pending.current = pointer;
+      if (!timer.current) {
+        timer.current = setTimeout(flush, POINTER_INTERVAL_MS);
+      }
+    },
+    [flush]
+  );
+
+  useEffect(
+    () => () => {
+      if (timer.current) {
+        clearTimeout(timer.current);
+      }
+    },
+    []
+  );
+
+  return { connected, you, viewers, sharePointer };
+};
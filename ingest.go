@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// maxIngestChunk bounds a single upload request
+	maxIngestChunk = 8 << 20
+	// pushChunkSize is how much the push command sends per request
+	pushChunkSize = 4 << 20
+)
+
+var (
+	ingestSessionID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+	ingestProject   = regexp.MustCompile(`^[A-Za-z0-9._-]{0,255}$`)
+)
+
+// IngestStatus reports how much of a session has been uploaded
+type IngestStatus struct {
+	SessionID string `json:"session_id"`
+	Offset    int64  `json:"offset"`
+	// Checksum is the hex SHA-256 of everything uploaded so far
+	Checksum string `json:"checksum"`
+	Messages int    `json:"messages,omitempty"`
+}
+
+// ingestSourceFile is recorded as the source_file of uploaded sessions. It
+// keeps the /projects/<dir>/ segment so project filters match uploads too.
+func ingestSourceFile(host, project, sessionID string) string {
+	if host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("ingest://%s/projects/%s/%s.jsonl", host, project, sessionID)
+}
+
+// newIngestSync builds a sync that applies the server's redaction and title
+// settings to uploaded sessions, saving them into ws when one is given
+func newIngestSync(db *sql.DB, config *Config, ws *Workspace) (*ClaudeSessionSync, error) {
+	sync := NewClaudeSessionSync(db)
+	if ws != nil {
+		sync.workspace = ws.Slug
+	}
+	if config == nil {
+		return sync, nil
+	}
+	sync.thinking = config.Thinking
+	sync.conflicts = config.Conflicts
+	redactor, err := ingestRedactor(config, ws)
+	if err != nil {
+		return nil, err
+	}
+	sync.redactor = redactor
+	titler, err := NewTitler(config.Titles, db)
+	if err != nil {
+		return nil, err
+	}
+	sync.titler = titler
+	if sync.plugins, err = newPluginChain(config.Plugins); err != nil {
+		return nil, err
+	}
+	if sync.blobs, err = newBlobOffloader(config.Blobs); err != nil {
+		return nil, err
+	}
+	if sync.attachments, err = newAttachmentExtractor(config.Blobs); err != nil {
+		return nil, err
+	}
+	return sync, nil
+}
+
+// ingestRedactor returns the redactor for sessions uploaded into ws, or nil
+// when neither the config nor the workspace asks for redaction
+func ingestRedactor(config *Config, ws *Workspace) (*Redactor, error) {
+	if config == nil || !(config.Redaction.Enabled || (ws != nil && ws.Settings.Redact)) {
+		return nil, nil
+	}
+	return NewRedactor(config.Redaction)
+}
+
+// requireIngest allows requests carrying the configured ingest token or an
+// API key with ingest scope. Without a token, uploads from this machine are
+// allowed unless a page of another site sent them.
+func (a *apiServer) requireIngest(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if secret, ok := bearerToken(r); ok && strings.HasPrefix(secret, apiKeyPrefix) {
+			if r, ok = a.authorizeAPIKey(w, r, secret, apiKeyIngest); ok && a.authorizeIngestProject(w, r) {
+				h(w, r)
+			}
+			return
+		}
+		token := ""
+		if config := a.currentConfig(); config != nil {
+			token = config.IngestToken
+		}
+		if token == "" && refuseCrossSite(w, r) {
+			return
+		}
+		if authorizeBearer(w, r, token, "ingest_token") {
+			h(w, r)
+		}
+	}
+}
+
+// authorizeIngestProject keeps a project or workspace scoped key to
+// uploads into its project and workspace, and away from sessions already
+// synced from another one
+func (a *apiServer) authorizeIngestProject(w http.ResponseWriter, r *http.Request) bool {
+	key := apiKeyFromContext(r.Context())
+	if key.Project == "" && key.Workspace == "" {
+		return true
+	}
+	sessionID := r.PathValue("id")
+	if r.Method == http.MethodPost {
+		q := r.URL.Query()
+		sessionID = q.Get("session_id")
+		if project := q.Get("project"); key.Project != "" && project != key.Project {
+			writeJSONError(w, r, http.StatusForbidden, fmt.Sprintf("This key can only ingest into project %s", key.Project), nil)
+			return false
+		}
+		if ws := q.Get("workspace"); key.Workspace != "" && ws != "" && ws != key.Workspace {
+			writeJSONError(w, r, http.StatusForbidden, fmt.Sprintf("This key can only ingest into workspace %s", key.Workspace), nil)
+			return false
+		}
+	}
+
+	var project string
+	err := a.db.QueryRow(`SELECT project FROM session_uploads WHERE session_id = $1`, sessionID).Scan(&project)
+	if err != nil && err != sql.ErrNoRows {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to read upload: %v", err), nil)
+		return false
+	}
+	allowed := err == sql.ErrNoRows || key.Project == "" || project == key.Project
+	if allowed {
+		if allowed, err = a.keyCanAccessSession(key, sessionID, true); err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return false
+		}
+	}
+	if !allowed {
+		writeJSONError(w, r, http.StatusForbidden, fmt.Sprintf("Session %s belongs to another project or workspace", sessionID), nil)
+		return false
+	}
+	return true
+}
+
+// handleIngestStatus serves GET /api/ingest/sessions/{id} so clients know where to resume
+func (a *apiServer) handleIngestStatus(w http.ResponseWriter, r *http.Request) {
+	status := IngestStatus{SessionID: r.PathValue("id")}
+	var content, pending, state []byte
+	err := a.db.QueryRow(`
+		SELECT size, content, pending, hash_state FROM session_uploads WHERE session_id = $1`,
+		status.SessionID).Scan(&status.Offset, &content, &pending, &state)
+	if err != nil && err != sql.ErrNoRows {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to read upload: %v", err), nil)
+		return
+	}
+	h, err := uploadHash(content, pending, state)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	status.Checksum = hex.EncodeToString(h.Sum(nil))
+	writeJSON(w, http.StatusOK, status)
+}
+
+// uploadHash resumes the SHA-256 of everything uploaded from its saved
+// state. Uploads stored before redaction have none and hold the bytes as
+// received, so they are hashed instead.
+func uploadHash(content, pending, state []byte) (hash.Hash, error) {
+	h := sha256.New()
+	if state == nil {
+		h.Write(content)
+		h.Write(pending)
+		return h, nil
+	}
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, fmt.Errorf("failed to resume upload checksum: %w", err)
+	}
+	return h, nil
+}
+
+// errOffsetMismatch is returned when a chunk does not start where the stored upload ends
+var errOffsetMismatch = errors.New("offset does not match uploaded size")
+
+// appendUpload appends a chunk at offset and returns the whole upload, its
+// size as received and the checksum of what was received. Complete lines
+// are redacted before they are stored; the end of a line still being
+// uploaded waits in pending until the rest of it arrives.
+func appendUpload(db *sql.DB, sessionID, project, host string, offset int64, chunk []byte, redactor *Redactor) ([]byte, int64, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer tx.Rollback()
+
+	var size int64
+	var content, pending, state []byte
+	read := func() error {
+		return tx.QueryRow(`
+			SELECT size, content, pending, hash_state FROM session_uploads WHERE session_id = $1 FOR UPDATE`,
+			sessionID).Scan(&size, &content, &pending, &state)
+	}
+	err = read()
+	if err == sql.ErrNoRows {
+		if _, err := tx.Exec(`INSERT INTO session_uploads (session_id) VALUES ($1) ON CONFLICT DO NOTHING`, sessionID); err != nil {
+			return nil, 0, "", fmt.Errorf("failed to create upload: %w", err)
+		}
+		err = read()
+	}
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	if offset != size {
+		return nil, size, "", errOffsetMismatch
+	}
+
+	h, err := uploadHash(content, pending, state)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	h.Write(chunk)
+	if state, err = h.(encoding.BinaryMarshaler).MarshalBinary(); err != nil {
+		return nil, 0, "", fmt.Errorf("failed to save upload checksum: %w", err)
+	}
+	data := append(pending, chunk...)
+	end := bytes.LastIndexByte(data, '\n') + 1
+	lines, pending := redactor.RedactRaw(data[:end]), data[end:]
+
+	err = tx.QueryRow(`
+		UPDATE session_uploads
+		SET content = content || $2, pending = $3, hash_state = $4, size = size + $5, project = $6, host = $7, updated_at = NOW()
+		WHERE session_id = $1
+		RETURNING content, size`, sessionID, lines, pending, state, len(chunk), project, host).Scan(&content, &size)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to append upload: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, 0, "", err
+	}
+	// A last line without a newline is parsed too; sync redacts it
+	return append(content, pending...), size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// handleIngestSession serves POST /api/ingest/sessions?session_id=&offset=&checksum=&project=&host=&workspace=
+// The body is the next chunk of the session's JSONL file starting at offset,
+// and checksum is its hex SHA-256. A chunk may end mid-line; the line is
+// parsed once the rest arrives. Keys limited to a workspace always ingest
+// into it.
+func (a *apiServer) handleIngestSession(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sessionID, project, host := q.Get("session_id"), q.Get("project"), q.Get("host")
+	var ws *Workspace
+	slug := q.Get("workspace")
+	if key := apiKeyFromContext(r.Context()); key != nil && key.Workspace != "" {
+		slug = key.Workspace
+	}
+	if slug != "" {
+		var err error
+		ws, err = getWorkspace(a.db, slug)
+		if errors.Is(err, errWorkspaceNotFound) {
+			writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+	}
+	if !ingestSessionID.MatchString(sessionID) {
+		writeJSONError(w, r, http.StatusBadRequest, "session_id is required and may only contain letters, digits, - and _", nil)
+		return
+	}
+	if !ingestProject.MatchString(project) || !ingestProject.MatchString(host) || strings.Contains(project, "..") {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid project or host", nil)
+		return
+	}
+	offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeJSONError(w, r, http.StatusBadRequest, "offset must be a non-negative integer", nil)
+		return
+	}
+
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestChunk))
+	if err != nil {
+		writeJSONError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Chunks are limited to %d bytes", maxIngestChunk), nil)
+		return
+	}
+	sum := sha256.Sum256(chunk)
+	if !strings.EqualFold(q.Get("checksum"), hex.EncodeToString(sum[:])) {
+		writeJSONError(w, r, http.StatusBadRequest, "checksum does not match the uploaded chunk", nil)
+		return
+	}
+
+	redactor, err := ingestRedactor(a.currentConfig(), ws)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	content, size, checksum, err := appendUpload(a.db, sessionID, project, host, offset, chunk, redactor)
+	if errors.Is(err, errOffsetMismatch) {
+		writeJSONError(w, r, http.StatusConflict, err.Error(), map[string]int64{"offset": size})
+		return
+	}
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	sourceFile := ingestSourceFile(host, project, sessionID)
+	messages, lineCount, _, _, err := parseSessionData(sourceFile, content)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if len(messages) > 0 {
+		sync, err := newIngestSync(a.db, a.currentConfig(), ws)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		session := ClaudeSession{
+			SessionID: sessionID,
+			Messages:  messages,
+			Metadata: map[string]interface{}{
+				"source_file": sourceFile,
+				"last_synced": time.Now().Format(time.RFC3339),
+				"line_count":  lineCount,
+				"ingested_by": clientKey(r),
+			},
+		}
+		if err := sync.saveSession(r.Context(), &session, sourceFile); err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, IngestStatus{
+		SessionID: sessionID,
+		Offset:    size,
+		Checksum:  checksum,
+		Messages:  len(messages),
+	})
+}
+
+// ingestClient pushes session files to a server's ingest API
+type ingestClient struct {
+	server    string
+	token     string
+	host      string
+	workspace string
+	http      *http.Client
+}
+
+func (ic *ingestClient) do(method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, ic.server+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if ic.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ic.token)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	return ic.http.Do(req)
+}
+
+// decodeIngestResponse decodes a status or reports the server's error
+func decodeIngestResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+	return fmt.Errorf("server returned %s: %s", resp.Status, apiErr.Error)
+}
+
+// push uploads whatever the server does not have yet, returning the bytes sent
+func (ic *ingestClient) push(sessionID, project string, data []byte) (int, error) {
+	resp, err := ic.do("GET", "/api/ingest/sessions/"+url.PathEscape(sessionID), nil)
+	if err != nil {
+		return 0, err
+	}
+	var status IngestStatus
+	if err := decodeIngestResponse(resp, &status); err != nil {
+		return 0, err
+	}
+
+	// Only resume if the server holds an exact prefix of the local file
+	if status.Offset > int64(len(data)) {
+		return 0, fmt.Errorf("server has %d bytes but the local file has %d", status.Offset, len(data))
+	}
+	prefix := sha256.Sum256(data[:status.Offset])
+	if hex.EncodeToString(prefix[:]) != status.Checksum {
+		return 0, fmt.Errorf("local file no longer matches the uploaded copy")
+	}
+
+	sent := 0
+	for offset := status.Offset; offset < int64(len(data)); {
+		chunk := data[offset:min(offset+pushChunkSize, int64(len(data)))]
+		sum := sha256.Sum256(chunk)
+		query := url.Values{
+			"session_id": {sessionID},
+			"project":    {project},
+			"host":       {ic.host},
+			"workspace":  {ic.workspace},
+			"offset":     {strconv.FormatInt(offset, 10)},
+			"checksum":   {hex.EncodeToString(sum[:])},
+		}
+		resp, err := ic.do("POST", "/api/ingest/sessions?"+query.Encode(), chunk)
+		if err != nil {
+			return sent, err
+		}
+		if err := decodeIngestResponse(resp, &status); err != nil {
+			return sent, err
+		}
+		sent += len(chunk)
+		offset = status.Offset
+	}
+	return sent, nil
+}
+
+// pushCommand uploads local sessions to a claudemd server over HTTP, for
+// machines without direct database access
+func pushCommand(c *cli.Context) error {
+	server := strings.TrimRight(c.String("server"), "/")
+	hostname, _ := os.Hostname()
+	client := &ingestClient{
+		server:    server,
+		token:     c.String("token"),
+		host:      hostname,
+		workspace: c.String("workspace"),
+		http:      &http.Client{Timeout: 2 * time.Minute},
+	}
+
+	claudeDir, err := defaultClaudeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	projectsDir := filepath.Join(claudeDir, "projects")
+	filter, err := NewPathFilter(syncPatterns(c, nil))
+	if err != nil {
+		return err
+	}
+
+	pushed, failed := 0, 0
+	err = filepath.Walk(projectsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(projectsDir, path)
+		if info.IsDir() {
+			if !filter.AllowDir(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".jsonl") || !filter.AllowFile(rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sessionID := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		sent, err := client.push(sessionID, filepath.Base(filepath.Dir(path)), data)
+		if err != nil {
+			log.Printf("Failed to push %s: %v", sessionID, err)
+			failed++
+			return nil
+		}
+		if sent > 0 {
+			fmt.Printf("  ⬆️  %s (%d bytes)\n", sessionID, sent)
+			pushed++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Pushed %d session(s) to %s\n", pushed, server)
+	if failed > 0 {
+		return fmt.Errorf("%d session(s) failed to push", failed)
+	}
+	return nil
+}
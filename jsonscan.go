@@ -0,0 +1,347 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errJSONSyntax is returned by jsonScanner for malformed or truncated input
+var errJSONSyntax = errors.New("invalid JSON")
+
+// jsonScanner is a minimal single-pass JSON reader used on the sync hot path.
+// It validates as it goes, so spans it returns are safe to store as
+// json.RawMessage, but it only materializes the values callers ask for.
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+// ws skips insignificant whitespace
+func (s *jsonScanner) ws() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next significant byte without consuming it, or 0 at EOF
+func (s *jsonScanner) peek() byte {
+	s.ws()
+	if s.pos >= len(s.data) {
+		return 0
+	}
+	return s.data[s.pos]
+}
+
+// end reports whether only whitespace remains
+func (s *jsonScanner) end() bool {
+	return s.peek() == 0 && s.pos >= len(s.data)
+}
+
+// beginObject consumes an opening brace
+func (s *jsonScanner) beginObject() error {
+	if s.peek() != '{' {
+		return errJSONSyntax
+	}
+	s.pos++
+	return nil
+}
+
+// nextKey advances to the next object key, consuming the colon after it.
+// It returns more=false once the closing brace has been consumed.
+func (s *jsonScanner) nextKey(first bool) (key []byte, more bool, err error) {
+	c := s.peek()
+	if c == '}' {
+		s.pos++
+		return nil, false, nil
+	}
+	if !first {
+		if c != ',' {
+			return nil, false, errJSONSyntax
+		}
+		s.pos++
+	}
+	key, _, err = s.stringSpan()
+	if err != nil {
+		return nil, false, err
+	}
+	if s.peek() != ':' {
+		return nil, false, errJSONSyntax
+	}
+	s.pos++
+	return key, true, nil
+}
+
+// beginArray consumes an opening bracket
+func (s *jsonScanner) beginArray() error {
+	if s.peek() != '[' {
+		return errJSONSyntax
+	}
+	s.pos++
+	return nil
+}
+
+// nextElem advances to the next array element. It returns more=false once
+// the closing bracket has been consumed.
+func (s *jsonScanner) nextElem(first bool) (more bool, err error) {
+	c := s.peek()
+	if c == ']' {
+		s.pos++
+		return false, nil
+	}
+	if !first {
+		if c != ',' {
+			return false, errJSONSyntax
+		}
+		s.pos++
+	}
+	return true, nil
+}
+
+// stringSpan consumes a string and returns the raw bytes between the quotes
+func (s *jsonScanner) stringSpan() (raw []byte, escaped bool, err error) {
+	if s.peek() != '"' {
+		return nil, false, errJSONSyntax
+	}
+	// Work on locals so the loop stays in registers
+	data := s.data
+	start := s.pos + 1
+	for i := start; i < len(data); {
+		c := data[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			i++
+			continue
+		}
+		switch {
+		case c == '"':
+			s.pos = i + 1
+			return data[start:i], escaped, nil
+		case c == '\\':
+			escaped = true
+			if i+1 >= len(data) {
+				return nil, false, errJSONSyntax
+			}
+			switch data[i+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				i += 2
+			case 'u':
+				if i+6 > len(data) || !isHex4(data[i+2:i+6]) {
+					return nil, false, errJSONSyntax
+				}
+				i += 6
+			default:
+				return nil, false, errJSONSyntax
+			}
+		default:
+			// Unescaped control characters are not allowed in JSON strings
+			return nil, false, errJSONSyntax
+		}
+	}
+	return nil, false, errJSONSyntax
+}
+
+// stringValue consumes a string (or null) and decodes it
+func (s *jsonScanner) stringValue() (string, error) {
+	if s.peek() == 'n' {
+		return "", s.literal("null")
+	}
+	raw, escaped, err := s.stringSpan()
+	if err != nil {
+		return "", err
+	}
+	return decodeJSONString(raw, escaped, -1), nil
+}
+
+// valueSpan consumes any value and returns its raw bytes
+func (s *jsonScanner) valueSpan() ([]byte, error) {
+	s.ws()
+	start := s.pos
+	if err := s.skipValue(); err != nil {
+		return nil, err
+	}
+	return s.data[start:s.pos], nil
+}
+
+// skipValue consumes and validates any value
+func (s *jsonScanner) skipValue() error {
+	switch c := s.peek(); {
+	case c == '"':
+		_, _, err := s.stringSpan()
+		return err
+	case c == '{':
+		s.pos++
+		for first := true; ; first = false {
+			_, more, err := s.nextKey(first)
+			if err != nil || !more {
+				return err
+			}
+			if err := s.skipValue(); err != nil {
+				return err
+			}
+		}
+	case c == '[':
+		s.pos++
+		for first := true; ; first = false {
+			more, err := s.nextElem(first)
+			if err != nil || !more {
+				return err
+			}
+			if err := s.skipValue(); err != nil {
+				return err
+			}
+		}
+	case c == 't':
+		return s.literal("true")
+	case c == 'f':
+		return s.literal("false")
+	case c == 'n':
+		return s.literal("null")
+	case c == '-' || c >= '0' && c <= '9':
+		return s.number()
+	}
+	return errJSONSyntax
+}
+
+// literal consumes the exact keyword lit
+func (s *jsonScanner) literal(lit string) error {
+	if s.pos+len(lit) > len(s.data) || string(s.data[s.pos:s.pos+len(lit)]) != lit {
+		return errJSONSyntax
+	}
+	s.pos += len(lit)
+	return nil
+}
+
+// number consumes a number following the JSON grammar
+func (s *jsonScanner) number() error {
+	if s.pos < len(s.data) && s.data[s.pos] == '-' {
+		s.pos++
+	}
+	if s.pos >= len(s.data) {
+		return errJSONSyntax
+	}
+	if s.data[s.pos] == '0' {
+		s.pos++
+	} else if !s.digits() {
+		return errJSONSyntax
+	}
+	if s.pos < len(s.data) && s.data[s.pos] == '.' {
+		s.pos++
+		if !s.digits() {
+			return errJSONSyntax
+		}
+	}
+	if s.pos < len(s.data) && (s.data[s.pos] == 'e' || s.data[s.pos] == 'E') {
+		s.pos++
+		if s.pos < len(s.data) && (s.data[s.pos] == '+' || s.data[s.pos] == '-') {
+			s.pos++
+		}
+		if !s.digits() {
+			return errJSONSyntax
+		}
+	}
+	return nil
+}
+
+// digits consumes one or more decimal digits
+func (s *jsonScanner) digits() bool {
+	start := s.pos
+	for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+		s.pos++
+	}
+	return s.pos > start
+}
+
+func isHex4(b []byte) bool {
+	for _, c := range b {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJSONString unescapes a raw string span already validated by stringSpan.
+// With limit >= 0 decoding stops once the output exceeds limit bytes, at the
+// end of a rune so a multibyte character is never split.
+func decodeJSONString(raw []byte, escaped bool, limit int) string {
+	if !escaped {
+		if limit >= 0 && len(raw) > limit+1 {
+			end := limit + 1
+			for end < len(raw) && !utf8.RuneStart(raw[end]) {
+				end++
+			}
+			raw = raw[:end]
+		}
+		return string(raw)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(raw))
+	for i := 0; i < len(raw); {
+		if limit >= 0 && sb.Len() > limit && utf8.RuneStart(raw[i]) {
+			break
+		}
+		c := raw[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		switch raw[i+1] {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'u':
+			r := rune(hexValue(raw[i+2 : i+6]))
+			i += 6
+			if utf16.IsSurrogate(r) {
+				if i+6 <= len(raw) && raw[i] == '\\' && raw[i+1] == 'u' {
+					if pair := utf16.DecodeRune(r, rune(hexValue(raw[i+2:i+6]))); pair != utf8.RuneError {
+						r = pair
+						i += 6
+					} else {
+						r = utf8.RuneError
+					}
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			sb.WriteRune(r)
+			continue
+		default:
+			// \" \\ \/
+			sb.WriteByte(raw[i+1])
+		}
+		i += 2
+	}
+	return sb.String()
+}
+
+func hexValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v <<= 4
+		switch {
+		case c >= '0' && c <= '9':
+			v |= int(c - '0')
+		case c >= 'a' && c <= 'f':
+			v |= int(c - 'a' + 10)
+		default:
+			v |= int(c - 'A' + 10)
+		}
+	}
+	return v
+}
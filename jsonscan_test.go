@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// referenceContent summarizes a message like extractMessageContent, but with
+// encoding/json
+func referenceContent(message json.RawMessage) string {
+	var m struct {
+		Content json.RawMessage `json:"content"`
+	}
+	if len(message) == 0 || json.Unmarshal(message, &m) != nil || len(m.Content) == 0 {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(m.Content, &text) == nil {
+		return text
+	}
+	var blocks []json.RawMessage
+	if json.Unmarshal(m.Content, &blocks) != nil {
+		return ""
+	}
+	var parts []string
+	for _, raw := range blocks {
+		var block ContentBlock
+		if json.Unmarshal(raw, &block) != nil {
+			continue
+		}
+		switch block.Type {
+		case "text":
+			parts = append(parts, block.Text)
+		case "tool_use":
+			name := block.Name
+			if name == "" {
+				name = "unknown tool"
+			}
+			description := ""
+			var input map[string]interface{}
+			if json.Unmarshal(block.Input, &input) == nil && input != nil {
+				description = "with parameters"
+				if d, _ := input["description"].(string); d != "" {
+					description = d
+				} else if p, _ := input["prompt"].(string); p != "" {
+					description = p
+				}
+			}
+			parts = append(parts, "Used "+name+" "+description)
+		case "tool_result":
+			if len(block.Content) > 0 && (block.Content[0] == '"' || block.Content[0] == '[') {
+				parts = append(parts, "Tool result: "+toolResultText(block.Content))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// checkParsedLine compares parseSessionLine with encoding/json on one line
+func checkParsedLine(t *testing.T, line []byte) {
+	t.Helper()
+	var want SessionMessage
+	wantErr := json.Unmarshal(line, &want)
+	got, err := parseSessionLine(line)
+	if (err != nil) != (wantErr != nil) {
+		t.Fatalf("error = %v, encoding/json error = %v", err, wantErr)
+	}
+	if err != nil {
+		return
+	}
+	if got.Type != want.Type || got.Summary != want.Summary || got.LeafUUID != want.LeafUUID ||
+		got.UUID != want.UUID || got.Timestamp != want.Timestamp || got.Cwd != want.Cwd {
+		t.Errorf("fields = %+v, want %+v", got, want)
+	}
+	if !bytes.Equal(got.Message, want.Message) {
+		t.Errorf("message = %s, want %s", got.Message, want.Message)
+	}
+	wantContent := referenceContent(want.Message)
+	if want.Summary != "" {
+		wantContent = want.Summary
+	}
+	if got.Content != wantContent {
+		t.Errorf("content = %q, want %q", got.Content, wantContent)
+	}
+}
+
+func TestParseSessionLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"summary", `{"type":"summary","summary":"Fix \"it\"","leafUuid":"a"}`},
+		{"string message", `{"type":"user","uuid":"a","message":{"role":"user","content":"hello"}}`},
+		{"message is a string", `{"type":"user","message":"hello"}`},
+		{"null message", `{"type":"system","message":null}`},
+		{"escapes", `{"type":"user","message":{"content":"q\" b\\ s\/ \b\f\n\r\t \u00e9 \u0041"}}`},
+		{"surrogate pair", `{"type":"user","message":{"content":"smile \ud83d\ude00!"}}`},
+		{"lone surrogate", `{"type":"user","message":{"content":"half \ud800 and \udc00A"}}`},
+		{"raw multibyte", `{"type":"user","cwd":"/tmp/日本","message":{"content":"café 😀"}}`},
+		{"text and tool use", `{"type":"assistant","message":{"content":[{"type":"text","text":"a"},{"type":"tool_use","name":"Bash","input":{"description":"run it"}},{"type":"tool_use","input":{"prompt":"p"}},{"type":"tool_use","name":"Read","input":{"file_path":"x"}},{"type":"tool_use","name":"X","input":[1]}]}}`},
+		{"nested tool result", `{"type":"user","message":{"content":[{"type":"tool_result","content":[{"type":"text","text":"one"},{"type":"image","source":{"data":"x"}},{"type":"text","text":"two\n"}]},{"type":"tool_result","content":"plain"},{"type":"tool_result","content":null}]}}`},
+		{"non-object blocks", `{"type":"assistant","message":{"content":["x",1,null,{"type":"text","text":"y"}]}}`},
+		{"duplicate keys", `{"type":"user","type":"assistant","uuid":"a","uuid":"b","message":{"content":"first"},"message":{"content":"second","content":"third"}}`},
+		{"whitespace", " {\n\t\"type\" : \"user\" ,\r\n \"message\" : { \"content\" : [ { \"type\" : \"text\" , \"text\" : \"x\" } ] } } \n"},
+		{"unknown fields", `{"type":"user","n":-1.5e+3,"b":[true,false,null],"o":{"k":{}},"message":{"content":"x"}}`},
+		{"truncated", `{"type":"user","message":{"content":"hel`},
+		{"trailing data", `{"type":"user"} {}`},
+		{"control character", "{\"type\":\"us\ner\"}"},
+		{"bad escape", `{"type":"\x"}`},
+		{"bad number", `{"type":"user","n":01}`},
+		{"not an object", `["type"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkParsedLine(t, []byte(tt.line))
+		})
+	}
+}
+
+func TestParseSessionLineFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte{'\n'}) {
+		checkParsedLine(t, line)
+	}
+}
+
+func TestDecodeJSONStringLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"plain multibyte", "café 日本語 😀 end"},
+		{"escaped multibyte", `café 日本語 \ud83d\ude00 😀 \n end`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			escaped := strings.Contains(tt.raw, `\`)
+			full := decodeJSONString([]byte(tt.raw), escaped, -1)
+			for limit := 0; limit <= len(full); limit++ {
+				got := decodeJSONString([]byte(tt.raw), escaped, limit)
+				if !utf8.ValidString(got) {
+					t.Fatalf("limit %d split a rune: %q", limit, got)
+				}
+				if !strings.HasPrefix(full, got) {
+					t.Fatalf("limit %d: %q is not a prefix of %q", limit, got, full)
+				}
+				if got != full && len(got) <= limit {
+					t.Fatalf("limit %d stopped early at %q", limit, got)
+				}
+			}
+		})
+	}
+}
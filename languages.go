@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LanguageStats counts the code of one language in a session: fenced code
+// blocks in messages, and files written or edited through tools
+type LanguageStats struct {
+	Blocks int `json:"blocks"`
+	Lines  int `json:"lines"`
+}
+
+// languageAliases maps code fence info strings to language names
+var languageAliases = map[string]string{
+	"js": "javascript", "jsx": "javascript", "mjs": "javascript", "cjs": "javascript", "node": "javascript",
+	"ts": "typescript", "tsx": "typescript",
+	"py": "python", "python3": "python",
+	"sh": "shell", "bash": "shell", "zsh": "shell", "console": "shell", "shell-session": "shell",
+	"golang": "go",
+	"rs":     "rust",
+	"rb":     "ruby",
+	"yml":    "yaml",
+	"md":     "markdown",
+	"htm":    "html",
+	"c++":    "cpp", "cc": "cpp", "hpp": "cpp",
+	"cs": "csharp", "c#": "csharp",
+	"kt":   "kotlin",
+	"psql": "sql", "postgresql": "sql", "postgres": "sql",
+	"dockerfile": "docker",
+	"text":       "", "txt": "", "plaintext": "", "output": "",
+}
+
+// languageExtensions maps file extensions of written files to language names
+var languageExtensions = map[string]string{
+	".go": "go", ".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+	".ts": "typescript", ".tsx": "typescript", ".py": "python", ".rb": "ruby", ".rs": "rust",
+	".java": "java", ".kt": "kotlin", ".swift": "swift", ".c": "c", ".h": "c", ".cpp": "cpp",
+	".cc": "cpp", ".hpp": "cpp", ".cs": "csharp", ".php": "php", ".sh": "shell", ".bash": "shell",
+	".zsh": "shell", ".sql": "sql", ".html": "html", ".css": "css", ".scss": "css", ".json": "json",
+	".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".md": "markdown", ".vue": "vue", ".svelte": "svelte",
+	".ex": "elixir", ".exs": "elixir", ".lua": "lua", ".dart": "dart", ".scala": "scala", ".tf": "terraform",
+}
+
+// codeFence matches the opening line of a fenced code block and its info string
+var codeFence = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([^\\s`{]*)")
+
+// languageHints guess the language of an unlabelled code block. The first
+// matching hint wins, so specific ones come first.
+var languageHints = []struct {
+	language string
+	pattern  *regexp.Regexp
+}{
+	{"go", regexp.MustCompile(`(?m)^package \w+$|^func (\(\w+ \*?\w+\) )?\w+\(|:= `)},
+	{"python", regexp.MustCompile(`(?m)^(def|class) \w+.*:$|^from \w[\w.]* import |^import \w+$|^\s*elif .*:$`)},
+	{"shell", regexp.MustCompile(`(?m)^#!/bin/(ba|z)?sh|^\$ \w|^(npm|npx|go|git|pip|cd|ls|mkdir|curl|docker|make|yarn|pnpm|brew) `)},
+	{"sql", regexp.MustCompile(`(?i)^\s*(SELECT .+ FROM|INSERT INTO|UPDATE \w+ SET|CREATE (TABLE|INDEX)|ALTER TABLE|DELETE FROM)`)},
+	{"rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+|^use \w+::|let mut `)},
+	{"typescript", regexp.MustCompile(`(?m)^(export )?(interface|type) \w+|: (string|number|boolean)\b|<\w+>\(`)},
+	{"javascript", regexp.MustCompile(`(?m)^(import .* from |export (default )?|const \w+ = |function \w+\(|module\.exports)|=> \{`)},
+	{"html", regexp.MustCompile(`(?i)^\s*<(!doctype|html|div|body|head)\b`)},
+}
+
+// detectLanguage names the language of a code block from its fence info
+// string, falling back to guessing from the code. It returns "" for plain
+// text and code it cannot place.
+func detectLanguage(info, code string) string {
+	// Some writers add a file name, as in ```go:main.go
+	info, _, _ = strings.Cut(strings.ToLower(info), ":")
+	if info != "" {
+		if language, ok := languageAliases[info]; ok {
+			return language
+		}
+		return info
+	}
+	if json.Valid([]byte(code)) && strings.ContainsAny(strings.TrimSpace(code)[:1], "{[") {
+		return "json"
+	}
+	for _, hint := range languageHints {
+		if hint.pattern.MatchString(code) {
+			return hint.language
+		}
+	}
+	return ""
+}
+
+// codeBlock is a fenced block found in message text
+type codeBlock struct {
+	info string
+	code string
+}
+
+// fencedCodeBlocks returns the fenced code blocks of markdown text. An
+// unclosed fence runs to the end of the text.
+func fencedCodeBlocks(text string) []codeBlock {
+	var blocks []codeBlock
+	var open *codeBlock
+	var fence string
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if open == nil {
+			if m := codeFence.FindStringSubmatch(line); m != nil {
+				open, fence, lines = &codeBlock{info: m[2]}, m[1], nil
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			open.code = strings.Join(lines, "\n")
+			blocks = append(blocks, *open)
+			open = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if open != nil {
+		open.code = strings.Join(lines, "\n")
+		blocks = append(blocks, *open)
+	}
+	return blocks
+}
+
+// countCodeLines counts the non-blank lines of code
+func countCodeLines(code string) int {
+	n := 0
+	for _, line := range strings.Split(code, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// sessionLanguages tallies the code of a session by language
+func sessionLanguages(messages []SessionMessage) map[string]LanguageStats {
+	languages := make(map[string]LanguageStats)
+	add := func(language, code string) {
+		if language == "" {
+			return
+		}
+		stats := languages[language]
+		stats.Blocks++
+		stats.Lines += countCodeLines(code)
+		languages[language] = stats
+	}
+
+	for _, msg := range messages {
+		for _, block := range messageBlocks(msg) {
+			switch block.Type {
+			case "text":
+				for _, code := range fencedCodeBlocks(block.Text) {
+					add(detectLanguage(code.info, code.code), code.code)
+				}
+			case "tool_use":
+				if block.Name != "Write" && block.Name != "Edit" && block.Name != "MultiEdit" {
+					continue
+				}
+				var input fileEditInput
+				if json.Unmarshal(block.Input, &input) != nil {
+					continue
+				}
+				language := languageExtensions[strings.ToLower(path.Ext(input.FilePath))]
+				code := input.Content + input.NewString
+				for _, edit := range input.Edits {
+					code += "\n" + edit.NewString
+				}
+				add(language, code)
+			}
+		}
+	}
+	return languages
+}
+
+// enrichLanguages records the language distribution of a session's code
+func enrichLanguages(session *ClaudeSession, filePath string) {
+	if languages := sessionLanguages(session.Messages); len(languages) > 0 {
+		session.Metadata["languages"] = languages
+	}
+}
+
+// LanguageUsage is the code of one language across sessions
+type LanguageUsage struct {
+	Language string `json:"language"`
+	Sessions int    `json:"sessions"`
+	Blocks   int    `json:"blocks"`
+	Lines    int    `json:"lines"`
+	// Share is the fraction of all lines of code in this language
+	Share float64 `json:"share"`
+}
+
+// LanguageReport is the response of GET /api/analytics/languages
+type LanguageReport struct {
+	// Sessions counts the sessions with any code
+	Sessions  int             `json:"sessions"`
+	Languages []LanguageUsage `json:"languages"`
+}
+
+// handleLanguageAnalytics serves GET /api/analytics/languages, the languages
+// of the code in matching sessions, most lines first. It accepts the
+// filters of the session list. Sessions synced before language detection
+// was added have no stats until they are synced again.
+func (a *apiServer) handleLanguageAnalytics(w http.ResponseWriter, r *http.Request) {
+	filter, err := sessionFilterFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	where, args := filter.where()
+	report := LanguageReport{Languages: []LanguageUsage{}}
+	if err := a.db.QueryRow(`SELECT count(*) FROM claude_sessions WHERE metadata ? 'languages' AND `+where, args...).Scan(&report.Sessions); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to count sessions: %v", err), nil)
+		return
+	}
+	rows, err := a.db.Query(`
+		SELECT l.key, count(*), sum((l.value->>'blocks')::int), sum((l.value->>'lines')::int)
+		FROM claude_sessions, jsonb_each(metadata->'languages') AS l
+		WHERE jsonb_typeof(metadata->'languages') = 'object' AND `+where+`
+		GROUP BY l.key`, args...)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query languages: %v", err), nil)
+		return
+	}
+	defer rows.Close()
+
+	total := 0
+	for rows.Next() {
+		var usage LanguageUsage
+		if err := rows.Scan(&usage.Language, &usage.Sessions, &usage.Blocks, &usage.Lines); err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to scan language: %v", err), nil)
+			return
+		}
+		total += usage.Lines
+		report.Languages = append(report.Languages, usage)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query languages: %v", err), nil)
+		return
+	}
+	for i := range report.Languages {
+		if total > 0 {
+			report.Languages[i].Share = float64(report.Languages[i].Lines) / float64(total)
+		}
+	}
+	sort.Slice(report.Languages, func(i, j int) bool {
+		if report.Languages[i].Lines != report.Languages[j].Lines {
+			return report.Languages[i].Lines > report.Languages[j].Lines
+		}
+		return report.Languages[i].Language < report.Languages[j].Language
+	})
+	writeJSON(w, http.StatusOK, report)
+}
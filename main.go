@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"html"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/evanw/esbuild/pkg/api"
 	"github.com/urfave/cli/v2"
@@ -15,38 +18,438 @@ func main() {
 	app := &cli.App{
 		Name:  "claudemd",
 		Usage: "Claude Code Session Manager & Development Server",
+		// Every command reads claudemd.config.json for its defaults
+		Before: loadWorkspace,
+		After: func(c *cli.Context) error {
+			shutdownTracing()
+			return nil
+		},
 		Commands: []*cli.Command{
+			{
+				Name:  "init",
+				Usage: "Create claudemd.config.json and its JSON schema in the current directory",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite an existing claudemd.config.json",
+					},
+				},
+				Action: initCommand,
+			},
 			{
 				Name:  "serve",
 				Usage: "Start the development server",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "port",
 						Value: "3001",
 						Usage: "Port to run server on",
 					},
-				},
+					offlineFlag(),
+					typecheckFlag(),
+					&cli.BoolFlag{
+						Name:  "embedded",
+						Usage: "Serve the frontend compiled into the binary by build --embed",
+					},
+					&cli.BoolFlag{
+						Name:  "readonly",
+						Usage: "Serve only the embedded frontend and the read API, for publishing a session archive",
+					},
+				}, append(buildFlags(), rateLimitFlags()...)...),
 				Action: serveCommand,
 			},
 			{
-				Name:   "build",
-				Usage:  "Build the application for production",
+				Name:  "build",
+				Usage: "Build the application for production",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "zip",
+						Usage: "Package index.html and content hashed assets into a zip instead of writing app.js",
+					},
+					&cli.StringFlag{
+						Name:  "zip-file",
+						Value: "build.zip",
+						Usage: "Path of the zip written with --zip",
+					},
+					&cli.BoolFlag{
+						Name:  "embed",
+						Usage: "Write the build into embedded/ and compile a binary that serves it",
+					},
+					&cli.StringFlag{
+						Name:  "embed-output",
+						Value: "./claudemd",
+						Usage: "Path of the binary compiled with --embed",
+					},
+				}, buildFlags()...),
 				Action: buildCommand,
 			},
 			{
 				Name:  "sync-sessions",
 				Usage: "Sync Claude Code sessions to Supabase",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.BoolFlag{
 						Name:  "watch",
 						Usage: "Watch for changes and sync continuously",
 					},
-				},
+				}, syncFlags()...),
 				Action: syncSessionsCommand,
 			},
+			{
+				Name:  "daemon",
+				Usage: "Run the session sync watcher and the development server together",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "port",
+						Value: "3001",
+						Usage: "Port to run server on",
+					},
+					&cli.BoolFlag{
+						Name:  "tui",
+						Usage: "Render a live terminal dashboard instead of log output",
+					},
+					offlineFlag(),
+					typecheckFlag(),
+				}, append(append(syncFlags(), buildFlags()...), rateLimitFlags()...)...),
+				Action: daemonCommand,
+			},
+			{
+				Name:      "preview",
+				Usage:     "Render a TSX snippet from stdin or a file without saving it",
+				ArgsUsage: "-",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "file",
+						Usage: "Read the snippet from a file instead of stdin",
+					},
+					&cli.StringFlag{
+						Name:  "component",
+						Value: "App",
+						Usage: "Exported component to render (falls back to the default export)",
+					},
+					&cli.StringFlag{
+						Name:  "port",
+						Value: "0",
+						Usage: "Port for the ephemeral server when none is running (0 picks a free port)",
+					},
+					&cli.BoolFlag{
+						Name:  "no-open",
+						Usage: "Print the preview URL without opening a browser",
+					},
+				}, buildFlags()...),
+				Action: previewCommand,
+			},
+			{
+				Name:        "screenshot",
+				Usage:       "Render components matching glob patterns and save PNG screenshots",
+				ArgsUsage:   "<glob>...",
+				Description: "Each matching component is rendered through /render in headless Chrome or Chromium (set CHROME_PATH to choose one) and saved under the output directory at its own path, for visual regression baselines. A component is captured once its root element has rendered content and its fonts have loaded; one that logs a console error, throws, or does not render within --wait fails instead, so a broken render never becomes a baseline. Patterns support ** across directories.",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "out",
+						Value: "screenshots",
+						Usage: "Directory to write PNGs into",
+					},
+					&cli.StringFlag{
+						Name:  "url",
+						Usage: "Base URL of a running server to render with (defaults to starting one for the run)",
+					},
+					&cli.StringFlag{
+						Name:  "component",
+						Usage: "Exported component to render (defaults to App, falling back to the default export)",
+					},
+					&cli.IntFlag{
+						Name:  "width",
+						Value: 1280,
+						Usage: "Viewport width in pixels",
+					},
+					&cli.IntFlag{
+						Name:  "height",
+						Value: 800,
+						Usage: "Viewport height in pixels",
+					},
+					&cli.DurationFlag{
+						Name:  "wait",
+						Value: 30 * time.Second,
+						Usage: "How long a component may take to load and render before its capture fails",
+					},
+					&cli.IntFlag{
+						Name:  "parallel",
+						Value: 2,
+						Usage: "Number of components capturing at once, each in its own browser tab",
+					},
+				}, buildFlags()...),
+				Action: screenshotCommand,
+			},
+			{
+				Name:  "migrate",
+				Usage: "Apply pending database schema migrations",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "status",
+						Usage: "List applied and pending migrations without applying them",
+					},
+				},
+				Action: migrateCommand,
+			},
+			{
+				Name:  "restore",
+				Usage: "Reconstruct session JSONL files from raw snapshots in the database",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "session",
+						Usage: "Only restore the session with this ID",
+					},
+					&cli.StringFlag{
+						Name:  "dir",
+						Usage: "Claude directory to restore into (defaults to ~/.claude)",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite files that already exist",
+					},
+				},
+				Action: restoreCommand,
+			},
+			{
+				Name:  "push",
+				Usage: "Upload local sessions to a claudemd server without database access",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "server",
+						Usage:    "Base URL of the claudemd server",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						Usage:   "Ingest token configured on the server, or an API key with ingest scope",
+						EnvVars: []string{"CLAUDEMD_INGEST_TOKEN"},
+					},
+					&cli.StringFlag{
+						Name:  "workspace",
+						Usage: "Workspace to push sessions into; keys limited to a workspace always use theirs",
+					},
+					&cli.StringSliceFlag{
+						Name:  "include",
+						Usage: "Only push session files matching this glob (relative to ~/.claude/projects)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Skip projects or session files matching this glob",
+					},
+				},
+				Action: pushCommand,
+			},
+			sessionsCommand(),
+			apikeyCommand(),
+			promptsCommand(),
+			viewsCommand(),
+			workspaceCommand(),
+			{
+				Name:        "mcp",
+				Usage:       "Serve synced sessions to Claude Code as an MCP server over stdio",
+				Description: "Register it with `claude mcp add claudemd -- claudemd mcp` to let Claude search and read past sessions.",
+				Action:      mcpCommand,
+			},
+			serviceCommand(),
+			{
+				Name:  "analyze",
+				Usage: "Report what the production bundle is made of",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "entry",
+						Usage: "Entry point to build (defaults to build.entry)",
+					},
+					&cli.IntFlag{
+						Name:  "top",
+						Value: 20,
+						Usage: "Number of largest files to list",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the analysis as JSON",
+					},
+				}, buildFlags()...),
+				Action: analyzeCommand,
+			},
+			{
+				Name:  "retitle",
+				Usage: "Recompute session titles with the configured title strategies",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "strategy",
+						Usage: "Title strategy to try, in order (summary, first_message, project_date, llm); overrides config",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Retitle every session, not only those with the default title",
+					},
+					&cli.BoolFlag{
+						Name:  "refresh",
+						Usage: "Regenerate llm titles instead of reusing stored ones",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Show the new titles without saving them",
+					},
+				},
+				Action: retitleCommand,
+			},
+			{
+				Name:      "outline",
+				Usage:     "Summarize long sessions into outlines of sections and chunks",
+				ArgsUsage: "[session_id...]",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "min-messages",
+						Value: 200,
+						Usage: "Without session IDs, outline every session with at least this many messages",
+					},
+					&cli.BoolFlag{
+						Name:  "refresh",
+						Usage: "Summarize again instead of reusing stored summaries",
+					},
+				},
+				Action: outlineCommand,
+			},
+			{
+				Name:  "gc",
+				Usage: "Find and remove sessions of deleted projects, orphaned rows, unused prompts and unreferenced blobs",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Report what would be removed without removing it",
+					},
+					&cli.StringSliceFlag{
+						Name:  "only",
+						Usage: "Run only these checks (sessions, rows, prompts, context, blobs)",
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "Only check sessions of this ~/.claude/projects directory",
+					},
+					&cli.StringFlag{
+						Name:  "before",
+						Usage: "Only check sessions last updated before this date (YYYY-MM-DD or RFC 3339)",
+					},
+					&cli.BoolFlag{
+						Name:  "missing-files",
+						Usage: "Also remove sessions whose own transcript is gone, not only those of deleted projects",
+					},
+					&cli.BoolFlag{
+						Name:  "purge",
+						Usage: "Remove sessions and their rows entirely instead of soft deleting them",
+					},
+				},
+				Action: gcCommand,
+			},
+			{
+				Name:        "du",
+				Usage:       "Report disk usage under ~/.claude and in the database, and archive old session files",
+				Description: "Sizes each directory, project and session file under ~/.claude, the database tables and the largest session rows, and lists the sessions taken up most by tool results. With --archive, session files not modified for --days are gzipped into ~/.claude/archive and their sessions point at the archived copy.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "project", Usage: "Only sessions of this ~/.claude/projects directory"},
+					&cli.IntFlag{Name: "top", Value: 10, Usage: "Number of sessions to list"},
+					&cli.IntFlag{Name: "days", Value: 90, Usage: "Suggest archiving session files not modified for this many days"},
+					&cli.BoolFlag{Name: "archive", Usage: "Compress old session files into ~/.claude/archive"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "With --archive, list the files that would be archived"},
+					&cli.BoolFlag{Name: "json", Usage: "Print JSON"},
+				},
+				Action: duCommand,
+			},
+			{
+				Name:        "scan",
+				Usage:       "Scan synced sessions for secrets and personal data, optionally redacting them in place",
+				ArgsUsage:   "[session_id...]",
+				Description: "Runs the builtin and configured redaction rules over sessions already in the database, whether or not redaction is enabled for syncing. Matches are reported masked.",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{Name: "project", Usage: "Only sessions from this ~/.claude/projects directory"},
+					&cli.StringFlag{Name: "workspace", Usage: "Only sessions in this workspace"},
+					&cli.StringFlag{Name: "after", Usage: "Only sessions updated on or after this date (YYYY-MM-DD or RFC 3339)"},
+					&cli.StringFlag{Name: "before", Usage: "Only sessions updated before this date (YYYY-MM-DD or RFC 3339)"},
+					&cli.StringSliceFlag{Name: "rule", Usage: "Only scan with these rules (e.g. aws_access_key, email)"},
+					&cli.BoolFlag{Name: "redact", Usage: "Mask the findings in the stored messages and raw snapshots"},
+				}, sessionOutputFlags()...),
+				Action: scanCommand,
+			},
+			{
+				Name:        "reprocess",
+				Usage:       "Re-run message extraction and derived analytics over stored sessions",
+				Description: "Rebuilds each message's content, the languages, error and turn timing metadata, session times, todos, file manifests and prompts from the stored messages, without the original files. Sessions already at the current extractor version are skipped unless --all is given, so an interrupted run continues where it stopped. Changed sessions get a new update time, so change feeds pick them up and they move to the top of lists sorted by update.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "project", Usage: "Only sessions from this ~/.claude/projects directory"},
+					&cli.StringFlag{Name: "workspace", Usage: "Only sessions in this workspace"},
+					&cli.BoolFlag{Name: "all", Usage: "Reprocess sessions already at the current extractor version"},
+					&cli.StringFlag{Name: "resume", Usage: "Continue an interrupted --all run after this session ID"},
+					&cli.IntFlag{Name: "batch", Value: 100, Usage: "Sessions to load per query"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "Report what would change without writing"},
+				},
+				Action: reprocessCommand,
+			},
+			{
+				Name:        "export",
+				Usage:       "Export synced session transcripts, or analytics tables of every session",
+				ArgsUsage:   "[session_id...]",
+				Description: "Session IDs may be shortened to a unique prefix of at least 4 characters. Without any, an interactive picker lists recent sessions to choose from.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "markdown",
+						Usage: "Output format: markdown, json, html, pdf, a fine-tuning JSONL format (openai, openai-tools, sharegpt, sharegpt-tools), or csv or parquet tables (sessions, messages, tool invocations, token usage)",
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "Output file (defaults to stdout, or <session_id>.pdf for pdf), or directory for csv and parquet (defaults to claudemd-export)",
+					},
+					&cli.BoolFlag{
+						Name:  "anonymize",
+						Usage: "Replace file paths, user names, host names and email addresses with pseudonyms that stay consistent across the export",
+					},
+					&cli.BoolFlag{
+						Name:  "to-repo",
+						Usage: "Write Markdown transcripts into docs/claude-sessions of the git repository in the current directory, with an index page, to commit next to the code (--out changes the directory)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "With --to-repo and no session IDs, export the repository's sessions with all of these tags",
+					},
+				},
+				Action: exportCommand,
+			},
+			{
+				Name:        "compare",
+				Usage:       "Compare two sessions working on the same task",
+				ArgsUsage:   "[session_a session_b]",
+				Description: "Session IDs may be shortened to a unique prefix of at least 4 characters. Without any, an interactive picker lists recent sessions to choose from.",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the comparison as JSON",
+					},
+				},
+				Action: compareCommand,
+			},
+			{
+				Name:        "compliance",
+				Usage:       "Check sessions against the rules in their project's CLAUDE.md",
+				ArgsUsage:   "[session_id...]",
+				Description: "Extracts directives such as \"never edit generated files\" or \"always run the tests\" from the CLAUDE.md files Claude Code reads for the session's working directory, and checks the session's edits and shell commands against them. Directives it cannot check are listed as unchecked. Session IDs may be shortened to a unique prefix of at least 4 characters; without any, an interactive picker lists recent sessions.",
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{Name: "claude-md", Usage: "CLAUDE.md file to check against instead of the project's"},
+					&cli.BoolFlag{Name: "strict", Usage: "Exit with an error when any rule is broken"},
+				}, sessionOutputFlags()...),
+				Action: complianceCommand,
+			},
+			{
+				Name:        "completion",
+				Usage:       "Print shell completions, or the commands and flags as JSON",
+				ArgsUsage:   "bash|zsh|fish|json",
+				Description: "Load completions with `source <(claudemd completion bash)`, `source <(claudemd completion zsh)` or `claudemd completion fish | source`. Besides commands and flags they complete session IDs from the session files under ~/.claude/projects and --project names. `json` prints every command with its flags, for tools that drive claudemd.",
+				Action:      completionCommand,
+			},
 		},
 	}
 
+	enableCompletion(app)
 	if err := app.Run(os.Args); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -55,43 +458,198 @@ func main() {
 
 // serveCommand starts the development server
 func serveCommand(c *cli.Context) error {
-	port := c.String("port")
+	port := serverPort(c)
 
-	mux := createHTTPServer()
+	if err := resolveBuildConfig(c); err != nil {
+		return err
+	}
+	if c.Bool("readonly") {
+		return serveReadonly(c, port)
+	}
+	if err := enableOffline(c); err != nil {
+		return err
+	}
+	if stopCache, err := startContentCache(workspace.Server.Cache); err != nil {
+		log.Printf("Cache disabled: %v", err)
+	} else {
+		defer stopCache()
+	}
+	if c.Bool("typecheck") || workspace.Server.Typecheck {
+		if stopTypecheck, err := startTypecheck(); err != nil {
+			log.Printf("Type checking disabled: %v", err)
+		} else {
+			defer stopTypecheck()
+		}
+	}
+	if c.Bool("embedded") {
+		if err := enableEmbedded(); err != nil {
+			return err
+		}
+	} else if stopBundle, err := startAppBundle(); err != nil {
+		log.Printf("App bundle disabled: %v", err)
+	} else {
+		defer stopBundle()
+	}
+
+	limiter, err := rateLimiterFromFlags(c)
+	if err != nil {
+		return err
+	}
+	db, config := openOptionalDatabase()
+	api := &apiServer{db: db, config: config, limiter: limiter}
+	mux := createHTTPServer(api)
+
+	if stopReload, err := watchConfig(c, config, api, nil); err != nil {
+		log.Printf("Config reload disabled: %v", err)
+	} else {
+		defer stopReload()
+	}
+
+	listener, err := listenServer(port)
+	if err != nil {
+		return err
+	}
+	closeControl := func() {}
+	openControl := func() {
+		var err error
+		if closeControl, err = startControlSocket("http://localhost:" + port); err != nil {
+			log.Printf("Preview control socket disabled: %v", err)
+			closeControl = func() {}
+		}
+	}
+	openControl()
+	defer func() { closeControl() }()
 
 	fmt.Printf("🚀 Claude.md Platform Server starting on http://localhost:%s\n", port)
 	fmt.Printf("📁 Serving from: %s\n", getCurrentDir())
 	fmt.Printf("🔧 Development mode with esbuild integration\n")
+	if devCache != nil {
+		cache := fmt.Sprintf("%s in memory", formatSize(devCache.maxBytes))
+		if devCache.dir != "" {
+			cache += ", on disk under " + devCache.dir
+		}
+		fmt.Printf("🗄️  Cache: %s\n", cache)
+	}
+	if typecheck != nil {
+		fmt.Printf("🔎 Type checking with tsc in the background\n")
+	}
+	if profile := currentBuildConfig().Profile; profile != "" {
+		fmt.Printf("🧩 Build profile: %s\n", profile)
+	}
 	fmt.Printf("🎯 Available endpoints:\n")
 	fmt.Printf("   • GET  /              - Main Claude.md app\n")
+	fmt.Printf("   • GET  /app.js        - Main app bundle, compiled in memory\n")
 	fmt.Printf("   • GET  /render/{path} - Component debugging\n")
 	fmt.Printf("   • GET  /module/{path} - ES module serving\n")
+	fmt.Printf("   • GET  /api/typecheck?path= - Type errors from the background tsc (serve --typecheck)\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/tail - Live session stream (SSE)\n")
+	fmt.Printf("   • GET  /api/metrics   - Runtime and per-endpoint metrics\n")
+	fmt.Printf("   • GET  /api/compare?a=&b= - Compare two sessions\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/commits - Git commits made during a session\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/context?path= - CLAUDE.md and settings as they were when a session started\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/annotations - Message annotations (POST, PUT, DELETE)\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/room?name= - Who is viewing a session and where (SSE, POST .../room/pointer)\n")
+	fmt.Printf("   • DELETE /api/sessions/{id} - Soft delete a session (?purge=true to remove)\n")
+	fmt.Printf("   • DELETE /api/sessions?project=&q=&before= - Bulk delete matching sessions\n")
+	fmt.Printf("   • GET  /api/sessions?limit=&cursor= - Session list, paged by next_cursor\n")
+	fmt.Printf("   • GET  /api/sessions?view=&sort= - Session list filtered by a saved view\n")
+	fmt.Printf("   • GET  /api/views - Saved session filters (POST, and PUT, DELETE /api/views/{name})\n")
+	fmt.Printf("   • GET  /api/sessions/changes?since=&wait= - Sessions changed after a cursor (long-poll)\n")
+	fmt.Printf("   • GET  /api/sessions/{id} - Session with offloaded tool results restored\n")
+	fmt.Printf("   • PATCH /api/sessions/{id} - Edit the title and tags of a session\n")
+	fmt.Printf("   • GET  /api/blobs/{key} - Offloaded tool result content\n")
+	fmt.Printf("   • GET  /api/messages/{uuid}/full?session= - Message with tool results that were cut to a preview\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/raw?source= - Session transcript as a JSONL download\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/attachments - Images pasted into or returned in a session\n")
+	fmt.Printf("   • GET  /attachments/{id} - An extracted image\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/workstream - Resumed sessions chained with this one\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/timeline - Turns split into thinking, tool and answer phases\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/outline - Chunk, section and session summaries for navigation\n")
+	fmt.Printf("   • PUT  /api/sessions/{id}/outcome - Record success, failure or abandoned and a 1-5 rating\n")
+	fmt.Printf("   • GET  /api/analytics/outcomes - Outcomes against token usage, duration and tools\n")
+	fmt.Printf("   • GET  /api/analytics/languages - Languages of the code in sessions\n")
+	fmt.Printf("   • GET  /api/analytics/errors - Failing tools, error types and daily error trend in ?tz=\n")
+	fmt.Printf("   • GET  /api/analytics/turns - Time to first response and turn duration per project and model\n")
+	fmt.Printf("   • GET  /api/sessions/{id}/files - Files modified during a session\n")
+	fmt.Printf("   • GET  /api/files?path= - Sessions that modified a file\n")
+	fmt.Printf("   • GET  /api/todos?status= - Todos from TodoWrite calls across sessions\n")
+	fmt.Printf("   • GET  /api/prompts?search= - Prompt library, most used first\n")
+	fmt.Printf("   • GET  /api/workspaces - List workspaces\n")
+	fmt.Printf("   • GET  /api/workspaces/{slug} - Workspace with members\n")
+	fmt.Printf("   • POST /api/graphql - GraphQL queries over sessions, messages, tools, todos and outcomes\n")
+	fmt.Printf("   • POST /api/ingest/sessions?session_id=&offset=&checksum= - Push a chunk of session JSONL\n")
+	fmt.Printf("   • GET  /api/build/analyze?entry= - Bundle composition from the esbuild metafile\n")
+	fmt.Printf("   • POST /api/build     - Run a production build (admin)\n")
+	fmt.Printf("   • GET  /api/build/download?entry= - Production build as a zip (admin)\n")
+	fmt.Printf("   • GET  /editor/{path} - Edit a component in the browser (admin)\n")
+	fmt.Printf("   • PUT  /api/files/{path} - Save a source file and rebuild it (admin)\n")
+	fmt.Printf("   • GET  /api/devstatus - Build and sync events (SSE)\n")
+	fmt.Printf("   • GET  /api/audit     - Audit log of API mutations (admin)\n")
+	fmt.Printf("🔄 Send SIGHUP (kill -HUP %d) to restart without dropping connections\n", os.Getpid())
+	if tracingEndpoint != "" {
+		fmt.Printf("🔭 Exporting traces of requests, builds and syncs to %s\n", tracingEndpoint)
+	}
 
-	return http.ListenAndServe(":"+port, mux)
+	server := &gracefulServer{
+		server:   &http.Server{Handler: mux},
+		listener: listener,
+		release: func() {
+			closeControl()
+			closeControl = func() {}
+		},
+		reacquire: openControl,
+	}
+	notifyRestartReady()
+	return server.Serve()
 }
 
 // buildCommand builds the application for production
 func buildCommand(c *cli.Context) error {
-	fmt.Println("🏗️ Starting production build...")
+	if err := resolveBuildConfig(c); err != nil {
+		return err
+	}
 
-	buildDir := "./"
+	fmt.Println("🏗️ Starting production build...")
+	fmt.Printf("🎯 Target: %s\n", buildConfig.Target)
+	if buildConfig.Profile != "" {
+		fmt.Printf("🧩 Profile: %s\n", buildConfig.Profile)
+	}
+	if len(buildConfig.RequiredEnv) > 0 {
+		fmt.Printf("🔑 Env: %s\n", strings.Join(buildConfig.RequiredEnv, ", "))
+	}
 
-	// Build main app bundle
-	result := buildWithEsbuild("./index.tsx", filepath.Join(buildDir, "app.js"), true)
+	if c.Bool("zip") {
+		result, err := writeBuildZipFile(buildConfig, buildConfig.entryPath(), c.String("zip-file"))
+		if err != nil {
+			return err
+		}
+		if err := reportBuildErrors(result); err != nil {
+			return err
+		}
+		fmt.Println("✅ Production build packaged successfully!")
+		return nil
+	}
 
-	if len(result.Errors) > 0 {
-		fmt.Println("❌ Production build failed:")
-		for _, err := range result.Errors {
-			fmt.Printf("   • %s\n", err.Text)
+	if c.Bool("embed") {
+		result, err := embedBuild(buildConfig, buildConfig.entryPath(), c.String("embed-output"))
+		if err != nil {
+			return err
+		}
+		if err := reportBuildErrors(result); err != nil {
+			return err
 		}
-		return fmt.Errorf("build failed with %d errors", len(result.Errors))
+		fmt.Println("✅ Production build embedded successfully!")
+		return nil
 	}
 
-	// Generate production HTML
-	htmlContent := generateProductionHTML()
-	htmlPath := filepath.Join(buildDir, "index.html")
-	if err := os.WriteFile(htmlPath, []byte(htmlContent), 0644); err != nil {
-		return fmt.Errorf("failed to write HTML file: %v", err)
+	buildDir := "./"
+
+	result, err := productionBuild(buildConfig, buildConfig.entryPath(), buildDir)
+	if err != nil {
+		return err
+	}
+	if err := reportBuildErrors(result); err != nil {
+		return err
 	}
 
 	fmt.Println("✅ Production build completed successfully!")
@@ -99,10 +657,25 @@ func buildCommand(c *cli.Context) error {
 	fmt.Printf("📄 Files generated:\n")
 	fmt.Printf("   • index.html\n")
 	fmt.Printf("   • app.js\n")
+	if buildConfig.Sourcemap == "linked" || buildConfig.Sourcemap == "external" {
+		fmt.Printf("   • app.js.map\n")
+	}
 
 	return nil
 }
 
+// reportBuildErrors prints the errors of a failed production build
+func reportBuildErrors(result api.BuildResult) error {
+	if len(result.Errors) == 0 {
+		return nil
+	}
+	fmt.Println("❌ Production build failed:")
+	for _, err := range result.Errors {
+		fmt.Printf("   • %s\n", err.Text)
+	}
+	return fmt.Errorf("build failed with %d errors", len(result.Errors))
+}
+
 // getCurrentDir returns the current working directory for logging
 func getCurrentDir() string {
 	dir, err := os.Getwd()
@@ -112,22 +685,78 @@ func getCurrentDir() string {
 	return dir
 }
 
-// createHTTPServer creates the HTTP server with only essential endpoints
-func createHTTPServer() *http.ServeMux {
+// createHTTPServer creates the HTTP server. Every endpoint is mounted on the
+// mux here and served through the shared middleware chain.
+func createHTTPServer(api *apiServer) http.Handler {
 	mux := http.NewServeMux()
 
 	// Main Claude.md app page
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		serveReactApp(w, r, "index.tsx", "ClaudeDocApp")
+		if embeddedApp != nil {
+			serveEmbedded(w, r)
+			return
+		}
+		if mainBundle != nil {
+			mainBundle.servePage(w, r)
+			return
+		}
+		serveReactApp(w, r, strings.TrimPrefix(currentBuildConfig().entryPath(), "./"), "ClaudeDocApp")
 	})
 
+	// Main app compiled in memory
+	mux.HandleFunc("GET "+appBundlePath, handleAppBundle)
+
 	// Component renderer endpoint for debugging
 	mux.HandleFunc("/render/", handleRenderComponent)
 
 	// ES Module endpoint for serving compiled JavaScript
 	mux.HandleFunc("/module/", handleServeModule)
 
-	return mux
+	// Rebuild notifications for the error overlay
+	mux.HandleFunc("GET /api/render/watch", handleRenderWatch)
+
+	// Diagnostics of the background type check
+	mux.HandleFunc("GET /api/typecheck", handleTypecheck)
+
+	// Unsaved snippets registered by the preview command
+	mux.HandleFunc("GET /render/__preview/{id}", handleRenderPreview)
+	mux.HandleFunc("GET /module/__preview/{id}", handlePreviewModule)
+
+	// Dependencies vendored by serve --offline
+	mux.HandleFunc("GET /vendor/{file}", handleVendorModule)
+
+	// Live stream of messages appended to a session file
+	mux.HandleFunc("GET /api/sessions/{id}/tail", api.requireRead(api.handleSessionTail))
+
+	// Runtime and per-endpoint metrics
+	mux.HandleFunc("GET /api/metrics", handleMetrics)
+
+	// Bundle composition from the esbuild metafile
+	mux.HandleFunc("GET /api/build/analyze", handleBuildAnalyze)
+
+	// Production builds for editor plugins and scripts
+	mux.HandleFunc("POST /api/build", api.requireAdmin(handleBuild))
+	mux.HandleFunc("GET /api/build/download", api.requireAdmin(handleBuildDownload))
+
+	// In-browser editing of components, rebuilt on save
+	mux.HandleFunc("GET /editor/{path...}", api.requireAdmin(handleEditor))
+	mux.HandleFunc("PUT /api/files/{path...}", api.requireAdmin(handleSaveFile))
+
+	// Build and sync events for the app's status bar
+	mux.HandleFunc("GET /api/devstatus", handleDevStatus)
+
+	// Database-backed session API
+	api.registerRoutes(mux)
+
+	return chainMiddleware(mux,
+		recoveryMiddleware,
+		traceMiddleware,
+		loggingMiddleware,
+		rateLimitMiddleware(api.limiter),
+		streamDrainMiddleware,
+		gzipMiddleware,
+		instrumentMiddleware,
+	)
 }
 
 // handleRenderComponent builds and renders a React component in a simple HTML page
@@ -167,16 +796,24 @@ func handleRenderComponent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build with esbuild for rendering
-	result := buildComponentForRendering(string(sourceCode), filepath.Dir(srcPath), filepath.Base(srcPath))
+	traceID := traceIDFromContext(r.Context())
+
+	// Build with esbuild for rendering, unless no input changed since the last render
+	start := time.Now()
+	result, cached := devCache.cachedBuild("render", srcPath)
+	if !cached {
+		result = publishBuild(r.Context(), srcPath, func() api.BuildResult {
+			return buildComponentForRendering(string(sourceCode), filepath.Dir(srcPath), filepath.Base(srcPath))
+		})
+		stats.RecordBuild(srcPath, time.Since(start), len(result.Errors) > 0)
+		devCache.storeBuild("render", srcPath, result)
+	}
 
 	if len(result.Errors) > 0 {
-		errorMessages := make([]string, len(result.Errors))
-		for i, err := range result.Errors {
-			errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", err.Location.File, err.Location.Line, err.Location.Column, err.Text)
-		}
+		errorMessages := formatBuildErrors(result.Errors)
+		log.Printf("[trace=%s] render build of %s failed in %s: %s", traceID, srcPath, time.Since(start), strings.Join(errorMessages, "; "))
 
-		errorHTML := generateErrorHTML(componentPath, errorMessages)
+		errorHTML := generateErrorHTML(componentPath, errorMessages, traceID)
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(errorHTML))
@@ -188,15 +825,21 @@ func handleRenderComponent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if cached {
+		log.Printf("[trace=%s] render build of %s reused from cache", traceID, srcPath)
+	} else {
+		log.Printf("[trace=%s] render build of %s succeeded in %s", traceID, srcPath, time.Since(start))
+	}
+
 	// Generate HTML page for component rendering
-	htmlPage := generateComponentHTML(componentName, componentPath)
+	htmlPage := generateComponentHTML(componentName, componentPath, componentPath)
 	w.Header().Set("Content-Type", "text/html")
 	w.Write([]byte(htmlPage))
 }
 
 // handleServeModule builds and serves a React component as an ES module
 func handleServeModule(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -220,182 +863,152 @@ func handleServeModule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sourceCode, err := os.ReadFile(srcPath)
+	traceID := traceIDFromContext(r.Context())
+
+	// Build as ES module for browser consumption, joining an identical build
+	// if another tab requested this file at the same time
+	start := time.Now()
+	result, shared, err := moduleBuilds.Build(r.Context(), srcPath)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to read source file: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	// Build as ES module for browser consumption
-	result := buildAsESModule(string(sourceCode), filepath.Dir(srcPath), filepath.Base(srcPath))
+	if shared {
+		log.Printf("[trace=%s] module build of %s reused from a concurrent request or the cache", traceID, srcPath)
+	} else {
+		stats.RecordBuild(srcPath, time.Since(start), len(result.Errors) > 0)
+	}
 
 	if len(result.Errors) > 0 {
-		errorMessages := make([]string, len(result.Errors))
-		for i, err := range result.Errors {
-			errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", err.Location.File, err.Location.Line, err.Location.Column, err.Text)
-		}
+		errorMessages := formatBuildErrors(result.Errors)
+		log.Printf("[trace=%s] module build of %s failed in %s: %s", traceID, srcPath, time.Since(start), strings.Join(errorMessages, "; "))
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, `{"error": "Build failed", "details": %q}`, errorMessages)
+		writeJSONError(w, r, http.StatusBadRequest, "Build failed", errorMessages)
 		return
 	}
 
 	if len(result.OutputFiles) == 0 {
-		http.Error(w, "No output generated from build", http.StatusInternalServerError)
+		writeJSONError(w, r, http.StatusInternalServerError, "No output generated from build", nil)
 		return
 	}
 
-	compiledJS := string(result.OutputFiles[0].Contents)
+	log.Printf("[trace=%s] module build of %s succeeded in %s", traceID, srcPath, time.Since(start))
+
+	serveModuleContent(w, r, result.OutputFiles[0].Contents, "no-cache")
+}
+
+// formatBuildErrors renders esbuild messages as file:line:col: text
+func formatBuildErrors(messages []api.Message) []string {
+	errorMessages := make([]string, len(messages))
+	for i, msg := range messages {
+		if msg.Location == nil {
+			errorMessages[i] = msg.Text
+			continue
+		}
+		errorMessages[i] = fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column, msg.Text)
+	}
+	return errorMessages
+}
 
-	w.Header().Set("Content-Type", "application/javascript")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Write([]byte(compiledJS))
+// sourceLoaders maps file extensions to esbuild loaders
+var sourceLoaders = map[string]api.Loader{
+	".js":  api.LoaderJS,
+	".jsx": api.LoaderJSX,
+	".ts":  api.LoaderTS,
+	".tsx": api.LoaderTSX,
+	".css": api.LoaderCSS,
 }
 
 // buildWithEsbuild performs esbuild compilation with platform-specific settings
 func buildWithEsbuild(inputPath, outputPath string, writeToDisk bool) api.BuildResult {
-	return api.Build(api.BuildOptions{
-		EntryPoints: []string{inputPath},
-		Loader: map[string]api.Loader{
-			".js":  api.LoaderJS,
-			".jsx": api.LoaderJSX,
-			".ts":  api.LoaderTS,
-			".tsx": api.LoaderTSX,
-			".css": api.LoaderCSS,
-		},
-		Outfile:          outputPath,
-		Format:           api.FormatESModule,
-		Bundle:           true,
-		Write:            writeToDisk,
-		MinifyWhitespace: true,
-		TreeShaking:      api.TreeShakingTrue,
-		Target:           api.ES2020,
-		JSX:              api.JSXAutomatic,
-		JSXImportSource:  "react",
-		LogLevel:         api.LogLevelInfo,
+	return buildWithConfig(currentBuildConfig(), inputPath, outputPath, writeToDisk)
+}
+
+// buildWithConfig is buildWithEsbuild with explicit build options
+func buildWithConfig(config BuildConfig, inputPath, outputPath string, writeToDisk bool) api.BuildResult {
+	opts := productionOptions(config, inputPath)
+	opts.Outfile = outputPath
+	opts.Write = writeToDisk
+	return api.Build(opts)
+}
+
+// productionOptions are the esbuild options of a production build of inputPath
+func productionOptions(config BuildConfig, inputPath string) api.BuildOptions {
+	opts := api.BuildOptions{
+		EntryPoints:     []string{inputPath},
+		Loader:          sourceLoaders,
+		Format:          api.FormatESModule,
+		Bundle:          true,
+		TreeShaking:     api.TreeShakingTrue,
+		JSX:             api.JSXAutomatic,
+		JSXImportSource: "react",
+		LogLevel:        api.LogLevelInfo,
 		// Bundle all dependencies for self-contained production build
 		External: []string{},
-		TsconfigRaw: `{
-			"compilerOptions": {
-				"jsx": "react-jsx",
-				"allowSyntheticDefaultImports": true,
-				"esModuleInterop": true,
-				"moduleResolution": "node",
-				"target": "ES2020",
-				"lib": ["ES2020", "DOM", "DOM.Iterable"],
-				"allowJs": true,
-				"skipLibCheck": true,
-				"strict": false,
-				"forceConsistentCasingInFileNames": true,
-				"noEmit": true,
-				"incremental": true,
-				"resolveJsonModule": true,
-				"isolatedModules": true
-			}
-		}`,
-	})
+		// The metafile is what the analyze command reports on
+		Metafile: true,
+	}
+	config.apply(&opts, true)
+	return opts
 }
 
 // buildComponentForRendering builds a component for HTML page rendering
 func buildComponentForRendering(sourceCode, resolveDir, sourcefile string) api.BuildResult {
-	return api.Build(api.BuildOptions{
+	opts := api.BuildOptions{
 		Stdin: &api.StdinOptions{
 			Contents:   sourceCode,
 			ResolveDir: resolveDir,
 			Sourcefile: sourcefile,
 			Loader:     api.LoaderTSX,
 		},
-		Loader: map[string]api.Loader{
-			".js":  api.LoaderJS,
-			".jsx": api.LoaderJSX,
-			".ts":  api.LoaderTS,
-			".tsx": api.LoaderTSX,
-			".css": api.LoaderCSS,
-		},
+		Loader:          sourceLoaders,
 		Format:          api.FormatESModule,
 		Bundle:          true,
 		Write:           false,
 		TreeShaking:     api.TreeShakingTrue,
-		Target:          api.ESNext,
 		JSX:             api.JSXAutomatic,
 		JSXImportSource: "react",
 		LogLevel:        api.LogLevelSilent,
 		// Bundle all dependencies for self-contained production build
 		External: []string{},
-		TsconfigRaw: `{
-			"compilerOptions": {
-				"jsx": "react-jsx",
-				"allowSyntheticDefaultImports": true,
-				"esModuleInterop": true,
-				"moduleResolution": "node",
-				"target": "ESNext",
-				"lib": ["ESNext", "DOM", "DOM.Iterable"],
-				"allowJs": true,
-				"skipLibCheck": true,
-				"strict": false,
-				"forceConsistentCasingInFileNames": true,
-				"noEmit": true,
-				"incremental": true,
-				"resolveJsonModule": true,
-				"isolatedModules": true
-			}
-		}`,
-	})
+		// The metafile lists the inputs the cached result depends on
+		Metafile: true,
+	}
+	currentBuildConfig().apply(&opts, false)
+	return api.Build(opts)
 }
 
 // buildAsESModule builds source code as an ES module for direct browser consumption
 func buildAsESModule(sourceCode, resolveDir, sourcefile string) api.BuildResult {
-	return api.Build(api.BuildOptions{
+	opts := api.BuildOptions{
 		Stdin: &api.StdinOptions{
 			Contents:   sourceCode,
 			ResolveDir: resolveDir,
 			Sourcefile: sourcefile,
 			Loader:     api.LoaderTSX,
 		},
-		Loader: map[string]api.Loader{
-			".js":  api.LoaderJS,
-			".jsx": api.LoaderJSX,
-			".ts":  api.LoaderTS,
-			".tsx": api.LoaderTSX,
-			".css": api.LoaderCSS,
-		},
+		Loader:          sourceLoaders,
 		Format:          api.FormatESModule,
 		Bundle:          true,
 		Write:           false,
 		TreeShaking:     api.TreeShakingTrue,
-		Target:          api.ES2020,
 		JSX:             api.JSXAutomatic,
 		JSXImportSource: "react",
 		LogLevel:        api.LogLevelSilent,
-		// Bundle all dependencies for self-contained production build
+		// The metafile lists every input so watchers know what to observe
+		Metafile: true,
+		// Leave shared runtime dependencies to the import map
 		External: []string{"react", "react-dom", "react/jsx-runtime", "@supabase/supabase-js"},
-		TsconfigRaw: `{
-			"compilerOptions": {
-				"jsx": "react-jsx",
-				"allowSyntheticDefaultImports": true,
-				"esModuleInterop": true,
-				"moduleResolution": "node",
-				"target": "ES2020",
-				"lib": ["ES2020", "DOM", "DOM.Iterable"],
-				"allowJs": true,
-				"skipLibCheck": true,
-				"strict": false,
-				"forceConsistentCasingInFileNames": true,
-				"noEmit": true,
-				"incremental": true,
-				"resolveJsonModule": true,
-				"isolatedModules": true
-			}
-		}`,
-	})
+	}
+	currentBuildConfig().apply(&opts, false)
+	return api.Build(opts)
 }
 
 // generateErrorHTML creates an HTML page for displaying build errors
-func generateErrorHTML(componentPath string, errors []string) string {
+func generateErrorHTML(componentPath string, errors []string, traceID string) string {
 	errorItems := ""
 	for _, err := range errors {
-		errorItems += fmt.Sprintf(`<div class="error-item">%s</div>`, err)
+		errorItems += fmt.Sprintf(`<div class="error-item">%s</div>`, html.EscapeString(err))
 	}
 
 	return fmt.Sprintf(`
@@ -411,7 +1024,9 @@ func generateErrorHTML(componentPath string, errors []string) string {
         .error h1 { color: #c53030; margin-top: 0; }
         .error-list { margin: 10px 0; }
         .error-item { margin: 5px 0; padding: 5px; background: #ffffff; border-radius: 3px; }
+        .trace { color: #718096; font-size: 12px; }
     </style>
+    %s
 </head>
 <body>
     <div class="error">
@@ -420,6 +1035,7 @@ func generateErrorHTML(componentPath string, errors []string) string {
         <div class="error-list">
             %s
         </div>
+        <p class="trace">Trace ID: <code>%s</code> (include this when reporting the problem)</p>
         <h4>🔧 Troubleshooting:</h4>
         <ul>
             <li>Check TypeScript syntax and imports</li>
@@ -429,11 +1045,29 @@ func generateErrorHTML(componentPath string, errors []string) string {
         </ul>
     </div>
 </body>
-</html>`, componentPath, errorItems)
+</html>`, devOverlayScript(componentPath, errors), html.EscapeString(componentPath), errorItems, traceID)
 }
 
 // generateComponentHTML creates an HTML page for rendering individual components
-func generateComponentHTML(componentName, componentPath string) string {
+// watchPath enables rebuild-on-save; pass "" for sources that are not files.
+func generateComponentHTML(componentName, componentPath, watchPath string) string {
+	return generateModuleHTML(componentName, "/module/"+componentPath, watchPath)
+}
+
+// generateModuleHTML creates an HTML page that imports the module at
+// moduleURL and renders its component. Pages are cached per build config.
+func generateModuleHTML(componentName, moduleURL, watchPath string) string {
+	key := "html:" + componentName + "|" + moduleURL + "|" + watchPath + "@" + buildFingerprint()
+	if entry, ok := devCache.Get(key); ok {
+		return string(entry.Data)
+	}
+	page := renderModuleHTML(componentName, moduleURL, watchPath)
+	devCache.Put(key, []byte(page), "", nil)
+	return page
+}
+
+// renderModuleHTML fills in the page template of generateModuleHTML
+func renderModuleHTML(componentName, moduleURL, watchPath string) string {
 	return fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
@@ -442,18 +1076,9 @@ func generateComponentHTML(componentName, componentPath string) string {
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>%s - Claude.md Platform</title>
     <script type="importmap">
-    {
-        "imports": {
-            "react": "https://esm.sh/react@18",
-            "react-dom": "https://esm.sh/react-dom@18",
-            "react-dom/client": "https://esm.sh/react-dom@18/client",
-            "react/jsx-runtime": "https://esm.sh/react@18/jsx-runtime",
-            "@supabase/supabase-js": "https://esm.sh/@supabase/supabase-js@2"
-        }
-    }
+    %s
     </script>
-    <link rel="stylesheet" type="text/css" href="https://cdn.jsdelivr.net/npm/daisyui@5">
-    <script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>
+    %s
     <style>
         body { margin: 0; padding: 0; font-family: system-ui, -apple-system, sans-serif; }
         #root { width: 100%%; height: 100vh; }
@@ -467,12 +1092,13 @@ func generateComponentHTML(componentName, componentPath string) string {
             font-family: monospace;
         }
     </style>
+    %s
 </head>
 <body>
     <div id="root"></div>
     <script type="module">
         try {
-            const componentModule = await import('/module/%s');
+            const componentModule = await import('%s');
             const React = await import('react');
             const ReactDOM = await import('react-dom/client');
             
@@ -490,6 +1116,7 @@ func generateComponentHTML(componentName, componentPath string) string {
             
         } catch (error) {
             console.error('Runtime Error:', error);
+            window.__claudemdOverlay.show('Runtime error', [error.stack || error.message]);
             document.getElementById('root').innerHTML = 
                 '<div class="error">' +
                 '<h3>Runtime Error:</h3>' +
@@ -499,11 +1126,16 @@ func generateComponentHTML(componentName, componentPath string) string {
         }
     </script>
 </body>
-</html>`, componentName, componentPath, componentName, componentName, componentName)
+</html>`, componentName, pageImportMap(), frameworkTags(), devOverlayScript(watchPath, nil), moduleURL, componentName, componentName, componentName)
 }
 
-// generateProductionHTML creates the production HTML for the app
-func generateProductionHTML() string {
+// generateProductionHTML creates the production HTML for the app, loading
+// the bundled script and any stylesheets it emitted
+func generateProductionHTML(config BuildConfig, script string, stylesheets ...string) string {
+	var links strings.Builder
+	for _, href := range stylesheets {
+		fmt.Fprintf(&links, "\n    <link rel=\"stylesheet\" href=\"%s\">", html.EscapeString(href))
+	}
 	return `
 <!DOCTYPE html>
 <html>
@@ -512,18 +1144,10 @@ func generateProductionHTML() string {
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>Claude.md Platform</title>
     <script type="importmap">
-    {
-        "imports": {
-            "react": "https://esm.sh/react@18",
-            "react-dom": "https://esm.sh/react-dom@18",
-            "react-dom/client": "https://esm.sh/react-dom@18/client",
-            "react/jsx-runtime": "https://esm.sh/react@18/jsx-runtime",
-            "@supabase/supabase-js": "https://esm.sh/@supabase/supabase-js@2"
-        }
-    }
+    ` + config.importMap() + `
     </script>
     <link rel="stylesheet" type="text/css" href="https://cdn.jsdelivr.net/npm/daisyui@5">
-    <script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>
+    <script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>` + links.String() + `
     <style>
         body { margin: 0; padding: 0; font-family: system-ui, -apple-system, sans-serif; }
         #root { width: 100%; height: 100vh; }
@@ -531,7 +1155,7 @@ func generateProductionHTML() string {
 </head>
 <body>
     <div id="root"></div>
-    <script type="module" src="./app.js"></script>
+    <script type="module" src="` + html.EscapeString(script) + `"></script>
 </body>
 </html>`
 }
@@ -547,7 +1171,7 @@ func serveReactApp(w http.ResponseWriter, r *http.Request, componentPath, compon
 	}
 
 	// Generate HTML page for the component
-	htmlPage := generateComponentHTML(componentName, componentPath)
+	htmlPage := generateComponentHTML(componentName, componentPath, componentPath)
 	w.Header().Set("Content-Type", "text/html")
 	w.Write([]byte(htmlPage))
 }
@@ -561,8 +1185,7 @@ func generateDefaultHTML() string {
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>Claude.md Platform</title>
-    <link rel="stylesheet" type="text/css" href="https://cdn.jsdelivr.net/npm/daisyui@5">
-    <script src="https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4"></script>
+    ` + frameworkTags() + `
     <style>
         body { margin: 0; padding: 0; font-family: system-ui, -apple-system, sans-serif; }
         .container { max-width: 800px; margin: 0 auto; padding: 2rem; }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SessionFile summarises the changes a session made to one file. Line counts
+// are of the replaced and replacement text, not a computed diff.
+type SessionFile struct {
+	SessionID       string     `json:"session_id"`
+	Path            string     `json:"path"`
+	RelativePath    string     `json:"relative_path,omitempty"`
+	Edits           int        `json:"edits"`
+	Writes          int        `json:"writes"`
+	LinesAdded      int        `json:"lines_added"`
+	LinesRemoved    int        `json:"lines_removed"`
+	DiffBytes       int64      `json:"diff_bytes"`
+	FirstModifiedAt *time.Time `json:"first_modified_at,omitempty"`
+	LastModifiedAt  *time.Time `json:"last_modified_at,omitempty"`
+}
+
+// textEdit is a single string replacement made by Edit or MultiEdit
+type textEdit struct {
+	OldString string `json:"old_string"`
+	NewString string `json:"new_string"`
+}
+
+// fileEditInput covers the inputs of the Edit, MultiEdit and Write tools
+type fileEditInput struct {
+	FilePath string `json:"file_path"`
+	textEdit
+	Content string     `json:"content"`
+	Edits   []textEdit `json:"edits"`
+}
+
+// countLines counts lines in text, including a final line without a newline
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+// extractFileManifest collects the files changed by successful Edit,
+// MultiEdit and Write calls, ordered by path. Paths inside projectPath also
+// get a path relative to it.
+func extractFileManifest(sessionID, projectPath string, messages []SessionMessage) []SessionFile {
+	byPath := make(map[string]*SessionFile)
+
+	for _, call := range extractToolCalls(messages) {
+		if call.IsError || (call.Name != "Edit" && call.Name != "MultiEdit" && call.Name != "Write") {
+			continue
+		}
+		var input fileEditInput
+		if err := json.Unmarshal(call.Input, &input); err != nil || input.FilePath == "" {
+			continue
+		}
+
+		file, ok := byPath[input.FilePath]
+		if !ok {
+			file = &SessionFile{SessionID: sessionID, Path: input.FilePath}
+			if projectPath != "" {
+				if rel, err := filepath.Rel(projectPath, input.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+					file.RelativePath = filepath.ToSlash(rel)
+				}
+			}
+			byPath[input.FilePath] = file
+		}
+
+		switch call.Name {
+		case "Edit":
+			input.Edits = []textEdit{input.textEdit}
+			fallthrough
+		case "MultiEdit":
+			for _, edit := range input.Edits {
+				file.Edits++
+				file.LinesRemoved += countLines(edit.OldString)
+				file.LinesAdded += countLines(edit.NewString)
+				file.DiffBytes += int64(len(edit.OldString) + len(edit.NewString))
+			}
+		case "Write":
+			file.Writes++
+			file.LinesAdded += countLines(input.Content)
+			file.DiffBytes += int64(len(input.Content))
+		}
+
+		if t, err := time.Parse(time.RFC3339Nano, call.Timestamp); err == nil {
+			if file.FirstModifiedAt == nil {
+				file.FirstModifiedAt = &t
+			}
+			file.LastModifiedAt = &t
+		}
+	}
+
+	files := make([]SessionFile, 0, len(byPath))
+	for _, path := range sortedKeys(byPath) {
+		files = append(files, *byPath[path])
+	}
+	return files
+}
+
+// StoreFileManifest replaces the stored file manifest of a session
+func (p postgresSink) StoreFileManifest(sessionID string, files []SessionFile) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM session_files WHERE session_id = $1`, sessionID); err != nil {
+		return fmt.Errorf("failed to clear file manifest: %w", err)
+	}
+	for _, f := range files {
+		_, err := tx.Exec(`
+			INSERT INTO session_files (session_id, path, relative_path, edits, writes, lines_added, lines_removed, diff_bytes, first_modified_at, last_modified_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			sessionID, f.Path, f.RelativePath, f.Edits, f.Writes, f.LinesAdded, f.LinesRemoved, f.DiffBytes, f.FirstModifiedAt, f.LastModifiedAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert file manifest entry: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+const sessionFileColumns = `f.session_id, f.path, f.relative_path, f.edits, f.writes, f.lines_added, f.lines_removed,
+	f.diff_bytes, f.first_modified_at, f.last_modified_at`
+
+// scanSessionFiles reads manifest rows selected with sessionFileColumns
+func scanSessionFiles(rows *sql.Rows) ([]SessionFile, error) {
+	defer rows.Close()
+	files := []SessionFile{}
+	for rows.Next() {
+		var f SessionFile
+		if err := rows.Scan(&f.SessionID, &f.Path, &f.RelativePath, &f.Edits, &f.Writes, &f.LinesAdded,
+			&f.LinesRemoved, &f.DiffBytes, &f.FirstModifiedAt, &f.LastModifiedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file manifest entry: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// handleSessionFiles serves GET /api/sessions/{id}/files
+func (a *apiServer) handleSessionFiles(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if _, err := loadSession(a.db, sessionID); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+
+	rows, err := a.db.Query(`SELECT `+sessionFileColumns+` FROM session_files f WHERE f.session_id = $1 ORDER BY f.path`, sessionID)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query file manifest: %v", err), nil)
+		return
+	}
+	files, err := scanSessionFiles(rows)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, wholePage(files))
+}
+
+// handleFileSessions serves GET /api/files?path=<path>&limit=&cursor=,
+// listing the sessions that modified a file. The path matches the end of the
+// recorded path, so a project-relative path such as internal/auth/token.go works.
+func (a *apiServer) handleFileSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	path := strings.TrimPrefix(filepath.ToSlash(q.Get("path")), "./")
+	if path == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "path is required", nil)
+		return
+	}
+	page, err := pageFromQuery(q, 100, 1000)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var modifiedAt *time.Time
+	var sessionID, filePath string
+	hasCursor, err := page.decode(&modifiedAt, &sessionID, &filePath)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	where := "(f.path = $1 OR f.relative_path = $1 OR right(f.path, length($1) + 1) = '/' || $1)"
+	args := []interface{}{path}
+	if hasCursor {
+		var cond string
+		cond, args = afterNullableTime("f.last_modified_at", "(f.session_id, f.path)", modifiedAt,
+			[]interface{}{sessionID, filePath}, args)
+		where += " AND " + cond
+	}
+	args = append(args, page.Limit+1)
+
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT `+sessionFileColumns+`
+		FROM session_files f
+		JOIN claude_sessions s ON s.session_id = f.session_id AND s.deleted_at IS NULL
+		WHERE %s
+		ORDER BY f.last_modified_at DESC NULLS LAST, f.session_id, f.path
+		LIMIT $%d`, where, len(args)), args...)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query file manifest: %v", err), nil)
+		return
+	}
+	files, err := scanSessionFiles(rows)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPage(files, page, func(f SessionFile) string {
+		return encodeCursor(f.LastModifiedAt, f.SessionID, f.Path)
+	}))
+}
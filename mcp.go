@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision this server speaks
+const mcpProtocolVersion = "2024-11-05"
+
+// mcpTranscriptLimit bounds a transcript returned to the model, in bytes
+const mcpTranscriptLimit = 100000
+
+// JSON-RPC error codes used by the MCP server
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// rpcRequest is a JSON-RPC 2.0 request, or a notification when ID is absent
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool describes a tool in the tools/list response
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// mcpToolArgs are the arguments of every tool; each uses a subset
+type mcpToolArgs struct {
+	Query     string `json:"query"`
+	Project   string `json:"project"`
+	SessionID string `json:"session_id"`
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
+}
+
+// mcpTools are the tools the server offers
+var mcpTools = []mcpTool{
+	{
+		Name:        "search_sessions",
+		Description: "Search past Claude Code sessions for text in their messages. Returns matching sessions, most recent first, with a snippet around the first match.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"query":   map[string]interface{}{"type": "string", "description": "Text to search for, case-insensitive"},
+			"project": map[string]interface{}{"type": "string", "description": "Only sessions of this ~/.claude/projects directory"},
+			"limit":   map[string]interface{}{"type": "integer", "description": "Maximum number of sessions (default 10)"},
+		}, "query"),
+	},
+	{
+		Name:        "list_recent_sessions",
+		Description: "List the most recently updated Claude Code sessions with their titles and projects.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"project": map[string]interface{}{"type": "string", "description": "Only sessions of this ~/.claude/projects directory"},
+			"limit":   map[string]interface{}{"type": "integer", "description": "Maximum number of sessions (default 10)"},
+		}),
+	},
+	{
+		Name:        "get_session_transcript",
+		Description: "Get the transcript of a session as markdown. Long transcripts are returned in parts; pass the offset from the previous part to continue.",
+		InputSchema: mcpSchema(map[string]interface{}{
+			"session_id": map[string]interface{}{"type": "string", "description": "ID of the session"},
+			"offset":     map[string]interface{}{"type": "integer", "description": "Byte offset to continue from"},
+		}, "session_id"),
+	},
+}
+
+// mcpSchema builds the JSON schema of a tool's arguments
+func mcpSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// mcpServer answers MCP requests from a database of synced sessions
+type mcpServer struct {
+	db    *sql.DB
+	blobs blobStore
+}
+
+// serve reads newline delimited JSON-RPC messages until in is closed
+func (s *mcpServer) serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	enc := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := enc.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		// Notifications such as notifications/initialized get no response
+		if len(req.ID) == 0 {
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		resp.Result, resp.Error = s.handle(req)
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handle dispatches one request
+func (s *mcpServer) handle(req rpcRequest) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "claudemd", "version": "1.0.0"},
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": mcpTools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string      `json:"name"`
+			Arguments mcpToolArgs `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		text, err := s.callTool(params.Name, params.Arguments)
+		if errors.Is(err, errUnknownTool) {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		// Tool failures are reported to the model rather than as protocol errors
+		if err != nil {
+			return mcpToolResult(err.Error(), true), nil
+		}
+		return mcpToolResult(text, false), nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+}
+
+// mcpToolResult wraps tool output in a tools/call result
+func mcpToolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]interface{}{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// errUnknownTool is returned for calls to tools the server does not offer
+var errUnknownTool = errors.New("unknown tool")
+
+// callTool runs a tool and returns its text output
+func (s *mcpServer) callTool(name string, args mcpToolArgs) (string, error) {
+	limit := args.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	switch name {
+	case "search_sessions":
+		if args.Query == "" {
+			return "", fmt.Errorf("query is required")
+		}
+		return s.listSessions(SessionFilter{Text: args.Query, Project: args.Project}, limit)
+	case "list_recent_sessions":
+		return s.listSessions(SessionFilter{Project: args.Project}, limit)
+	case "get_session_transcript":
+		if args.SessionID == "" {
+			return "", fmt.Errorf("session_id is required")
+		}
+		return s.transcript(args.SessionID, args.Offset)
+	}
+	return "", fmt.Errorf("%w %q", errUnknownTool, name)
+}
+
+// listSessions returns matching session summaries as indented JSON
+func (s *mcpServer) listSessions(filter SessionFilter, limit int) (string, error) {
+	summaries, err := listSessionSummaries(s.db, filter, nil, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(summaries) == 0 {
+		return "No sessions found.", nil
+	}
+	data, err := json.MarshalIndent(summaries, "", "  ")
+	return string(data), err
+}
+
+// transcript renders a session as markdown, returning the part starting
+// at offset and noting where the next part starts
+func (s *mcpServer) transcript(sessionID string, offset int) (string, error) {
+	text, err := s.markdown(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 || offset > len(text) {
+		return "", fmt.Errorf("offset %d is beyond the transcript's %d bytes", offset, len(text))
+	}
+	text = text[offset:]
+	if len(text) <= mcpTranscriptLimit {
+		return text, nil
+	}
+	end := mcpTranscriptLimit
+	for end > 0 && !isRuneStart(text[end]) {
+		end--
+	}
+	return fmt.Sprintf("%s\n\n[Transcript truncated; call again with offset %d for the rest]", text[:end], offset+end), nil
+}
+
+// markdown renders a session as markdown. Reading a long transcript takes
+// several calls, so the render of each version of a session is cached, in
+// memory only since the disk tier is readable by other users.
+func (s *mcpServer) markdown(sessionID string) (string, error) {
+	var updatedAt time.Time
+	err := s.db.QueryRow(`SELECT updated_at FROM claude_sessions WHERE session_id = $1 AND deleted_at IS NULL`, sessionID).Scan(&updatedAt)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load session: %w", err)
+	}
+	key := fmt.Sprintf("markdown:%s@%d", sessionID, updatedAt.UnixNano())
+	if entry, ok := devCache.Get(key); ok {
+		return string(entry.Data), nil
+	}
+
+	session, err := loadSession(s.db, sessionID)
+	if err != nil {
+		return "", err
+	}
+	rehydrateSession(session, s.blobs)
+	var buf bytes.Buffer
+	if err := exportMarkdown(&buf, session); err != nil {
+		return "", err
+	}
+	devCache.PutMemory(key, buf.Bytes())
+	return buf.String(), nil
+}
+
+// mcpCommand serves the MCP server over stdin and stdout. Anything else
+// written to stdout would corrupt the protocol, so stdout is pointed at
+// stderr while the server runs.
+func mcpCommand(c *cli.Context) error {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	blobs, err := newBlobStore(config.Blobs)
+	if err != nil {
+		return err
+	}
+	if stopCache, err := startContentCache(workspace.Server.Cache); err != nil {
+		log.Printf("Cache disabled: %v", err)
+	} else {
+		defer stopCache()
+	}
+
+	log.Printf("MCP server ready on stdio")
+	server := &mcpServer{db: db, blobs: blobs}
+	return server.serve(os.Stdin, out)
+}
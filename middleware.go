@@ -0,0 +1,246 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps an http.Handler with additional behaviour
+type Middleware func(http.Handler) http.Handler
+
+// chainMiddleware applies middlewares so the first one listed is the outermost
+func chainMiddleware(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// responseRecorder captures the status code and size written by a handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += n
+	return n, err
+}
+
+// Flush keeps streaming endpoints working through the wrapper
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// recoveryMiddleware turns handler panics into 500 responses instead of dropped connections
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				log.Printf("[trace=%s] panic serving %s %s: %v\n%s", traceIDFromContext(r.Context()), r.Method, r.URL.Path, err, debug.Stack())
+				if rec.status == 0 {
+					writeJSONError(rec, r, http.StatusInternalServerError, "Internal server error", nil)
+				}
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// loggingMiddleware logs every request with its status, size, duration and trace ID
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		log.Printf("[trace=%s] %s %s %d %dB %s", traceIDFromContext(r.Context()), r.Method, r.URL.Path, status, rec.size, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+// instrumentMiddleware records per-endpoint request counts and latency. It
+// must sit directly in front of the mux so the matched route pattern is visible.
+func instrumentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		stats.RecordEndpoint(pattern, time.Since(start), rec.status >= 500)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + strings.TrimPrefix(pattern, r.Method+" "))
+		span.SetAttributes(semconv.HTTPRoute(pattern), semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// gzipResponseWriter compresses the body unless the handler opts out by
+// streaming events or setting its own Content-Encoding
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	decided     bool
+	compressing bool
+}
+
+func (g *gzipResponseWriter) decide() {
+	if g.decided {
+		return
+	}
+	g.decided = true
+
+	header := g.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	g.compressing = true
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	header.Add("Vary", "Accept-Encoding")
+	g.gz = gzipWriterPool.Get().(*gzip.Writer)
+	g.gz.Reset(g.ResponseWriter)
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if status != http.StatusNoContent && status != http.StatusNotModified {
+		g.decide()
+	} else {
+		g.decided = true
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.decided {
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		g.decide()
+	}
+	if g.compressing {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+func (g *gzipResponseWriter) Flush() {
+	if g.compressing {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) close() {
+	if g.compressing {
+		g.gz.Close()
+		gzipWriterPool.Put(g.gz)
+	}
+}
+
+// gzipMiddleware compresses responses for clients that accept gzip
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if negotiateEncoding(r.Header.Get("Accept-Encoding"), []string{"gzip"}) == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// handleMetrics reports runtime and per-endpoint stats
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s := stats.Snapshot()
+	endpoints := make(map[string]interface{}, len(s.Endpoints))
+	for pattern, e := range s.Endpoints {
+		avg := time.Duration(0)
+		if e.Count > 0 {
+			avg = e.Total / time.Duration(e.Count)
+		}
+		endpoints[pattern] = map[string]interface{}{
+			"count":  e.Count,
+			"errors": e.Errors,
+			"avg_ms": float64(avg.Microseconds()) / 1000,
+			"max_ms": float64(e.Max.Microseconds()) / 1000,
+		}
+	}
+
+	metrics := map[string]interface{}{
+		"uptime":          fmt.Sprint(time.Since(s.StartedAt).Round(time.Second)),
+		"queue_depth":     s.QueueDepth,
+		"queue_coalesced": s.QueueCoalesced,
+		"queue_dropped":   s.QueueDropped,
+		"sync_count":      s.SyncCount,
+		"sync_errors":     s.SyncErrors,
+		"sync_unchanged":  s.SyncUnchanged,
+		"build_count":     s.BuildCount,
+		"build_errors":    s.BuildErrors,
+		"endpoints":       endpoints,
+	}
+	if devCache != nil {
+		metrics["cache"] = devCache.Stats()
+	}
+	if sinks := mirrorStatuses(); len(sinks) > 0 {
+		metrics["sinks"] = sinks
+	}
+	writeJSON(w, http.StatusOK, metrics)
+}
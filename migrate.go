@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Schema changes are numbered SQL files applied in order. Never edit a file
+// once it has shipped; add a new one instead. Each storage driver has its
+// own directory and numbering.
+//
+//go:embed schema/migrations/*.sql schema/mysql/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key held while migrating, so the
+// daemon and a CLI command starting together do not race
+const migrationLockID = 0x636c6d64
+
+// migration is a single versioned schema change
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations reads the embedded migrations of a driver, ordered by version
+func loadMigrations(driver storageDriver) ([]migration, error) {
+	dir := driver.MigrationsDir()
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, label, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migration %s must be named NNNN_description.sql", entry.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: label, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// migrationConn is satisfied by both *sql.DB and a pinned *sql.Conn
+type migrationConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+func ensureMigrationsTable(db migrationConn, driver storageDriver) error {
+	_, err := db.ExecContext(context.Background(), driver.MigrationsTableSQL())
+	return err
+}
+
+// appliedMigrations returns the migrations recorded in the database, keyed by version
+func appliedMigrations(db migrationConn) (map[int]appliedMigration, error) {
+	rows, err := db.QueryContext(context.Background(), `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var m appliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[m.Version] = m
+	}
+	return applied, rows.Err()
+}
+
+// checkSchemaNotNewer fails if the database has migrations this binary does not know about
+func checkSchemaNotNewer(migrations []migration, applied map[int]appliedMigration) error {
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("database schema is at version %d but this binary only knows up to %d; upgrade claudemd", version, latest)
+		}
+	}
+	return nil
+}
+
+// migrateDatabase applies all pending migrations, each in its own transaction
+func migrateDatabase(db *sql.DB) (int, error) {
+	driver := storageDriverOf(db)
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	// Migration locks are per connection, so pin one for the whole run
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := driver.Lock(ctx, conn); err != nil {
+		return 0, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer driver.Unlock(ctx, conn)
+
+	if err := ensureMigrationsTable(conn, driver); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSchemaNotNewer(migrations, applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(conn, driver, m); err != nil {
+			return count, err
+		}
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration runs a migration and records it atomically. MySQL commits
+// DDL implicitly, so there a failed migration may be partly applied.
+func applyMigration(db migrationConn, driver storageDriver, m migration) error {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec(driver.RecordMigrationSQL(), m.Version, m.Name); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	return tx.Commit()
+}
+
+// migrateCommand applies pending migrations or reports their status
+func migrateCommand(c *cli.Context) error {
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if config.DatabaseURL == "" && config.Supabase.restMode() && !c.Bool("status") {
+		count, err := migrateSupabase(config.Supabase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Applied %d migration(s) through the Supabase management API\n", count)
+		return nil
+	}
+	db, err := openDatabase(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if !c.Bool("status") {
+		count, err := migrateDatabase(db)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			fmt.Println("✅ Schema is up to date")
+		} else {
+			fmt.Printf("✅ Applied %d migration(s)\n", count)
+		}
+		return nil
+	}
+
+	driver := storageDriverOf(db)
+	migrations, err := loadMigrations(driver)
+	if err != nil {
+		return err
+	}
+	if err := ensureMigrationsTable(db, driver); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if a, ok := applied[m.Version]; ok {
+			fmt.Printf("  ✅ %04d_%s  applied %s\n", m.Version, m.Name, a.AppliedAt.Local().Format("2006-01-02 15:04"))
+		} else {
+			fmt.Printf("  ⏳ %04d_%s  pending\n", m.Version, m.Name)
+		}
+	}
+	return checkSchemaNotNewer(migrations, applied)
+}
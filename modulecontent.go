@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// moduleEncodings are the content codings offered for module bundles, best first
+var moduleEncodings = []string{"br", "gzip"}
+
+// maxEncodedModules bounds how many compressed bundles are kept
+const maxEncodedModules = 64
+
+// negotiateEncoding picks the offered coding the client prefers by its
+// Accept-Encoding q-values, earlier offers winning ties. It returns "" when
+// the bundle should be sent as is.
+func negotiateEncoding(acceptEncoding string, offered []string) string {
+	quality := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality[strings.ToLower(name)] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range offered {
+		q, ok := quality[coding]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// encodedModuleCache keeps compressed bundles by content hash and coding,
+// so reloading a page or resuming a download does not compress again
+type encodedModuleCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+}
+
+var encodedModules = &encodedModuleCache{entries: make(map[string][]byte)}
+
+// get returns data compressed with coding, compressing it on a miss and
+// evicting the oldest entry when full
+func (c *encodedModuleCache) get(hash, coding string, data []byte) ([]byte, error) {
+	key := hash + "/" + coding
+	c.mu.Lock()
+	encoded, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return encoded, nil
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "br":
+		w = brotli.NewWriterLevel(&buf, 5)
+	default:
+		w = gzip.NewWriter(&buf)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	encoded = buf.Bytes()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= maxEncodedModules {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.entries[key] = encoded
+		c.order = append(c.order, key)
+	}
+	return encoded, nil
+}
+
+// serveModuleContent writes a built module, compressed when the client
+// accepts br or gzip. http.ServeContent streams it from the build output
+// without copying and handles Content-Length, conditional requests and
+// byte ranges, which apply to the compressed bytes as HTTP specifies.
+func serveModuleContent(w http.ResponseWriter, r *http.Request, data []byte, cacheControl string) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:8])
+	body, etag := data, `"`+hash+`"`
+
+	header := w.Header()
+	header.Set("Content-Type", "application/javascript")
+	header.Set("Cache-Control", cacheControl)
+	header.Add("Vary", "Accept-Encoding")
+	if coding := negotiateEncoding(r.Header.Get("Accept-Encoding"), moduleEncodings); coding != "" {
+		encoded, err := encodedModules.get(hash, coding, data)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, "Failed to compress module: "+err.Error(), nil)
+			return
+		}
+		body, etag = encoded, `"`+hash+"-"+coding+`"`
+		w = codingWriter{ResponseWriter: w, coding: coding}
+	}
+	header.Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// codingWriter sets Content-Encoding when the status is written.
+// http.ServeContent leaves out Content-Length for responses that already
+// have a coding, so it must not see one. The coding also keeps
+// gzipMiddleware from compressing again.
+type codingWriter struct {
+	http.ResponseWriter
+	coding string
+}
+
+func (c codingWriter) WriteHeader(status int) {
+	if status == http.StatusOK || status == http.StatusPartialContent {
+		c.Header().Set("Content-Encoding", c.coding)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c codingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/urfave/cli/v2"
+)
+
+// offlineCacheDir holds dependencies vendored for serve --offline
+const offlineCacheDir = ".claudemd/cache"
+
+// frameworkAsset is a CSS framework file that pages load from a CDN
+type frameworkAsset struct {
+	pkg  string
+	file string
+	url  string
+	tag  string
+}
+
+var frameworkAssets = []frameworkAsset{
+	{pkg: "daisyui", file: "daisyui.css", url: "https://cdn.jsdelivr.net/npm/daisyui@5", tag: "style"},
+	{pkg: "@tailwindcss/browser", file: "dist/index.global.js", url: "https://cdn.jsdelivr.net/npm/@tailwindcss/browser@4", tag: "script"},
+}
+
+// vendorEntryName names the generated entry module of vendored bundles
+const vendorEntryName = "claudemd-vendor-entry.js"
+
+// cjsExportPattern finds the names a CommonJS module assigns to exports
+var cjsExportPattern = regexp.MustCompile(`(?m)\bexports\.([A-Za-z_$][\w$]*)\s*=[^=]`)
+
+// vendorFilePattern matches the names vendored modules are served under
+var vendorFilePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.+_-]+\.js$`)
+
+// offlineAssets replaces CDN resources with local copies
+type offlineAssets struct {
+	importMap string
+	headTags  string
+}
+
+// offline is set when the server runs with --offline
+var offline *offlineAssets
+
+// offlineFlag is shared by the serve and daemon commands
+func offlineFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "offline",
+		Usage: "Serve react, react-dom and the CSS frameworks from local copies instead of CDNs",
+	}
+}
+
+// enableOffline vendors the import map packages and CSS frameworks when the
+// offline flag is set
+func enableOffline(c *cli.Context) error {
+	if !c.Bool("offline") {
+		return nil
+	}
+	assets, err := prepareOffline(currentBuildConfig().imports())
+	if err != nil {
+		return fmt.Errorf("offline mode unavailable: %w", err)
+	}
+	offline = assets
+	fmt.Printf("📦 Offline mode: dependencies served from %s\n", offlineCacheDir)
+	return nil
+}
+
+// pageImportMap is the import map of pages rendered by the dev server
+func pageImportMap() string {
+	if offline != nil {
+		return offline.importMap
+	}
+	return currentBuildConfig().importMap()
+}
+
+// frameworkTags loads the CSS frameworks into dev server pages
+func frameworkTags() string {
+	if offline != nil {
+		return offline.headTags
+	}
+	var tags []string
+	for _, asset := range frameworkAssets {
+		if asset.tag == "style" {
+			tags = append(tags, fmt.Sprintf(`<link rel="stylesheet" type="text/css" href="%s">`, asset.url))
+		} else {
+			tags = append(tags, fmt.Sprintf(`<script src="%s"></script>`, asset.url))
+		}
+	}
+	return strings.Join(tags, "\n    ")
+}
+
+// prepareOffline builds every import map package from node_modules into the
+// cache, once per installed version, and inlines the CSS frameworks
+func prepareOffline(imports map[string]string) (*offlineAssets, error) {
+	vendorDir := filepath.Join(offlineCacheDir, "vendor")
+	if err := os.MkdirAll(vendorDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	shared := sortedKeys(imports)
+	local := make(map[string]string, len(shared))
+	for _, spec := range shared {
+		file, err := vendorModule(vendorDir, spec, shared)
+		if err != nil {
+			return nil, err
+		}
+		local[spec] = "/vendor/" + file
+	}
+	importMap, _ := json.MarshalIndent(map[string]interface{}{"imports": local}, "    ", "    ")
+
+	var tags []string
+	for _, asset := range frameworkAssets {
+		data, err := frameworkAssetContent(asset)
+		if err != nil {
+			return nil, err
+		}
+		// Keep the inlined content from closing its own element early
+		content := strings.ReplaceAll(string(data), "</"+asset.tag, `<\/`+asset.tag)
+		tags = append(tags, fmt.Sprintf("<%s>%s</%s>", asset.tag, content, asset.tag))
+	}
+	return &offlineAssets{importMap: string(importMap), headTags: strings.Join(tags, "\n    ")}, nil
+}
+
+// specifierPackage returns the package name of a bare import specifier
+func specifierPackage(spec string) string {
+	parts := strings.SplitN(spec, "/", 3)
+	if strings.HasPrefix(spec, "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// vendorModule bundles one import specifier into dir unless the installed
+// version is already cached, and returns the cached file name
+func vendorModule(dir, spec string, shared []string) (string, error) {
+	pkg := specifierPackage(spec)
+	version := packageVersion(filepath.Join("node_modules", pkg))
+	if version == "" {
+		return "", fmt.Errorf("%s is not installed in node_modules; run npm install %s", pkg, pkg)
+	}
+	name := strings.ReplaceAll(strings.TrimPrefix(spec, "@"), "/", "__")
+	file := fmt.Sprintf("%s@%s.js", name, version)
+	path := filepath.Join(dir, file)
+	if _, err := os.Stat(path); err == nil {
+		return file, nil
+	}
+
+	start := time.Now()
+	code, err := bundleVendorModule(spec, pkg, shared)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, code, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	log.Printf("Vendored %s@%s in %s", spec, version, time.Since(start).Round(time.Millisecond))
+	return file, nil
+}
+
+// bundleVendorModule bundles spec as an ES module. The other import map
+// packages stay external so every module shares one copy of react. CommonJS
+// packages only get a default export from esbuild, so their named exports
+// are read from the package sources and re-exported explicitly.
+func bundleVendorModule(spec, pkg string, shared []string) ([]byte, error) {
+	result := buildVendorEntry(fmt.Sprintf("export * from %q;", spec), shared)
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("failed to bundle %s: %s", spec, strings.Join(formatBuildErrors(result.Errors), "; "))
+	}
+
+	var meta struct {
+		Inputs  map[string]json.RawMessage `json:"inputs"`
+		Outputs map[string]struct {
+			Exports []string `json:"exports"`
+		} `json:"outputs"`
+	}
+	json.Unmarshal([]byte(result.Metafile), &meta)
+	for _, out := range meta.Outputs {
+		if len(out.Exports) == 0 {
+			continue
+		}
+		// An ES module: keep its default export too, if it has one
+		withDefault := buildVendorEntry(fmt.Sprintf("export * from %q; export { default } from %q;", spec, spec), shared)
+		if len(withDefault.Errors) == 0 {
+			result = withDefault
+		}
+		return result.OutputFiles[0].Contents, nil
+	}
+
+	names := make(map[string]bool)
+	pkgDir := "node_modules/" + pkg + "/"
+	for input := range meta.Inputs {
+		input = filepath.ToSlash(input)
+		if !strings.HasPrefix(input, pkgDir) || strings.Contains(input[len(pkgDir):], "node_modules/") {
+			continue
+		}
+		source, err := os.ReadFile(input)
+		if err != nil {
+			continue
+		}
+		for _, m := range cjsExportPattern.FindAllStringSubmatch(string(source), -1) {
+			if m[1] != "default" && m[1] != "__esModule" {
+				names[m[1]] = true
+			}
+		}
+	}
+	exported := sortedKeys(names)
+
+	entry := fmt.Sprintf("import m from %q;\nexport default m;\n", spec)
+	if len(exported) > 0 {
+		entry += fmt.Sprintf("export const { %s } = m;\n", strings.Join(exported, ", "))
+	}
+	result = buildVendorEntry(entry, shared)
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("failed to bundle %s: %s", spec, strings.Join(formatBuildErrors(result.Errors), "; "))
+	}
+	return result.OutputFiles[0].Contents, nil
+}
+
+// buildVendorEntry bundles a generated entry module, leaving imports of the
+// shared packages from inside the bundle to the import map. A require of a
+// shared package goes through a shim that imports it, since the browser has
+// no require.
+func buildVendorEntry(contents string, shared []string) api.BuildResult {
+	quoted := make([]string, len(shared))
+	for i, spec := range shared {
+		quoted[i] = regexp.QuoteMeta(spec)
+	}
+	sharedImports := api.Plugin{
+		Name: "shared-imports",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: "^(" + strings.Join(quoted, "|") + ")$"},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					switch {
+					case filepath.Base(args.Importer) == vendorEntryName:
+						return api.OnResolveResult{}, nil
+					case args.Kind == api.ResolveJSRequireCall && args.Namespace != "vendor-shared":
+						return api.OnResolveResult{Path: args.Path, Namespace: "vendor-shared"}, nil
+					}
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				})
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: "vendor-shared"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					shim := fmt.Sprintf("export * from %q;\nexport { default } from %q;\n", args.Path, args.Path)
+					return api.OnLoadResult{Contents: &shim, Loader: api.LoaderJS}, nil
+				})
+		},
+	}
+
+	opts := api.BuildOptions{
+		Stdin: &api.StdinOptions{
+			Contents:   contents,
+			ResolveDir: getCurrentDir(),
+			Sourcefile: vendorEntryName,
+			Loader:     api.LoaderJS,
+		},
+		Bundle:   true,
+		Format:   api.FormatESModule,
+		Write:    false,
+		Metafile: true,
+		LogLevel: api.LogLevelSilent,
+		Target:   api.ES2020,
+		Define:   map[string]string{"process.env.NODE_ENV": `"development"`},
+		Plugins:  []api.Plugin{sharedImports},
+	}
+	return api.Build(opts)
+}
+
+// frameworkAssetContent reads a CSS framework from node_modules, or from the
+// cache, downloading it there on first use
+func frameworkAssetContent(asset frameworkAsset) ([]byte, error) {
+	if data, err := os.ReadFile(filepath.Join("node_modules", asset.pkg, asset.file)); err == nil {
+		return data, nil
+	}
+	cached := filepath.Join(offlineCacheDir, "assets", strings.ReplaceAll(strings.TrimPrefix(asset.pkg, "@"), "/", "__")+filepath.Ext(asset.file))
+	if data, err := os.ReadFile(cached); err == nil {
+		return data, nil
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(asset.url)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not installed or cached and could not be downloaded: %w", asset.pkg, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", asset.url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.url, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(cached, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to cache %s: %w", asset.pkg, err)
+	}
+	return data, nil
+}
+
+// handleVendorModule serves GET /vendor/{file}, the modules vendored by --offline
+func handleVendorModule(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if !vendorFilePattern.MatchString(file) {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(offlineCacheDir, "vendor", file))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/javascript")
+	// File names carry the package version, so they never change
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Write(data)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session outcomes a user can record
+const (
+	outcomeSuccess   = "success"
+	outcomeFailure   = "failure"
+	outcomeAbandoned = "abandoned"
+)
+
+// sessionOutcomes lists the outcomes in the order analytics report them
+var sessionOutcomes = []string{outcomeSuccess, outcomeFailure, outcomeAbandoned}
+
+// SessionOutcome is how a session turned out, as judged by the user
+type SessionOutcome struct {
+	Outcome string    `json:"outcome"`
+	Rating  *int      `json:"rating,omitempty"`
+	Note    string    `json:"note,omitempty"`
+	SetAt   time.Time `json:"set_at"`
+}
+
+// outcomeInput is the body of PUT /api/sessions/{id}/outcome
+type outcomeInput struct {
+	Outcome string `json:"outcome"`
+	Rating  *int   `json:"rating"`
+	Note    string `json:"note"`
+}
+
+func (in outcomeInput) validate() error {
+	switch in.Outcome {
+	case outcomeSuccess, outcomeFailure, outcomeAbandoned:
+	default:
+		return fmt.Errorf("outcome must be one of %s", strings.Join(sessionOutcomes, ", "))
+	}
+	if in.Rating != nil && (*in.Rating < 1 || *in.Rating > 5) {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	return nil
+}
+
+// loadOutcome returns the recorded outcome of a session, or nil if none is set
+func loadOutcome(db *sql.DB, sessionID string) (*SessionOutcome, error) {
+	var outcome, note sql.NullString
+	var rating sql.NullInt64
+	var setAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT outcome, rating, outcome_note, outcome_at
+		FROM claude_sessions
+		WHERE session_id = $1 AND deleted_at IS NULL`, sessionID).Scan(&outcome, &rating, &note, &setAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outcome: %w", err)
+	}
+	if !outcome.Valid {
+		return nil, nil
+	}
+	o := &SessionOutcome{Outcome: outcome.String, Note: note.String, SetAt: setAt.Time}
+	if rating.Valid {
+		r := int(rating.Int64)
+		o.Rating = &r
+	}
+	return o, nil
+}
+
+// handleSetOutcome serves PUT /api/sessions/{id}/outcome
+func (a *apiServer) handleSetOutcome(w http.ResponseWriter, r *http.Request) {
+	var in outcomeInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if err := in.validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	sessionID := r.PathValue("id")
+	previous, err := loadOutcome(a.db, sessionID)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+
+	_, err = a.db.Exec(`
+		UPDATE claude_sessions
+		SET outcome = $2, rating = $3, outcome_note = $4, outcome_at = NOW(), updated_at = NOW()
+		WHERE session_id = $1 AND deleted_at IS NULL`,
+		sessionID, in.Outcome, in.Rating, strings.TrimSpace(in.Note))
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to set outcome: %v", err), nil)
+		return
+	}
+	outcome, err := loadOutcome(a.db, sessionID)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	a.audit(r, "session.outcome", "session", sessionID, previous, outcome)
+	writeJSON(w, http.StatusOK, outcome)
+}
+
+// handleClearOutcome serves DELETE /api/sessions/{id}/outcome
+func (a *apiServer) handleClearOutcome(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	previous, err := loadOutcome(a.db, sessionID)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	_, err = a.db.Exec(`
+		UPDATE claude_sessions
+		SET outcome = NULL, rating = NULL, outcome_note = '', outcome_at = NULL, updated_at = NOW()
+		WHERE session_id = $1 AND deleted_at IS NULL`, sessionID)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to clear outcome: %v", err), nil)
+		return
+	}
+	if previous != nil {
+		a.audit(r, "session.outcome", "session", sessionID, previous, nil)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OutcomeToolStats describes how sessions with one outcome used a tool
+type OutcomeToolStats struct {
+	Name     string `json:"name"`
+	Sessions int    `json:"sessions"`
+	Calls    int    `json:"calls"`
+}
+
+// OutcomeStats aggregates the sessions that share an outcome. Input tokens
+// include cached input, since that is what the model read.
+type OutcomeStats struct {
+	Outcome               string             `json:"outcome"`
+	Sessions              int                `json:"sessions"`
+	Rated                 int                `json:"rated"`
+	AvgRating             float64            `json:"avg_rating,omitempty"`
+	AvgInputTokens        float64            `json:"avg_input_tokens"`
+	AvgOutputTokens       float64            `json:"avg_output_tokens"`
+	MedianTotalTokens     int64              `json:"median_total_tokens"`
+	AvgDurationSeconds    float64            `json:"avg_duration_seconds"`
+	MedianDurationSeconds float64            `json:"median_duration_seconds"`
+	AvgToolCalls          float64            `json:"avg_tool_calls"`
+	ToolErrorRate         float64            `json:"tool_error_rate"`
+	Tools                 []OutcomeToolStats `json:"tools"`
+}
+
+// ToolOutcomeStats is how often sessions using a tool succeeded
+type ToolOutcomeStats struct {
+	Name        string  `json:"name"`
+	Sessions    int     `json:"sessions"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// OutcomeReport is the response of GET /api/analytics/outcomes
+type OutcomeReport struct {
+	Sessions int                `json:"sessions"`
+	Ratings  map[int]int        `json:"ratings"`
+	Outcomes []OutcomeStats     `json:"outcomes"`
+	Tools    []ToolOutcomeStats `json:"tools"`
+}
+
+// maxOutcomeTools bounds the tools listed for each outcome
+const maxOutcomeTools = 10
+
+// sessionUsage is what the outcome analytics measure of a single session
+type sessionUsage struct {
+	outcome      string
+	rating       *int
+	inputTokens  int64
+	outputTokens int64
+	duration     time.Duration
+	toolCalls    map[string]int
+	toolErrors   int
+}
+
+// measureSession totals the tokens, duration and tool calls of a session
+func measureSession(session *ClaudeSession) sessionUsage {
+	usage := sessionUsage{toolCalls: make(map[string]int)}
+	for _, row := range tokenUsageRows(session) {
+		usage.inputTokens += row[6].(int64) + row[8].(int64) + row[9].(int64)
+		usage.outputTokens += row[7].(int64)
+	}
+	var first, last time.Time
+	for _, msg := range session.Messages {
+		if t, ok := messageTime(msg.Timestamp).(time.Time); ok {
+			if first.IsZero() {
+				first = t
+			}
+			last = t
+		}
+	}
+	usage.duration = last.Sub(first)
+	for _, call := range extractToolCalls(session.Messages) {
+		usage.toolCalls[call.Name]++
+		if call.IsError {
+			usage.toolErrors++
+		}
+	}
+	return usage
+}
+
+// handleOutcomeAnalytics serves GET /api/analytics/outcomes, relating the
+// recorded outcomes to token usage, duration and tool use. It accepts the
+// project, tag, before and after filters of the session list.
+func (a *apiServer) handleOutcomeAnalytics(w http.ResponseWriter, r *http.Request) {
+	filter, err := sessionFilterFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	report, err := queryOutcomeReport(a.db, filter)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// queryOutcomeReport measures the sessions matching the filter that have an outcome
+func queryOutcomeReport(db *sql.DB, filter SessionFilter) (OutcomeReport, error) {
+	where, args := filter.where()
+	rows, err := db.Query(`SELECT session_id, outcome, rating FROM claude_sessions WHERE outcome IS NOT NULL AND `+where, args...)
+	if err != nil {
+		return OutcomeReport{}, fmt.Errorf("failed to query outcomes: %w", err)
+	}
+	type rated struct {
+		id      string
+		outcome string
+		rating  sql.NullInt64
+	}
+	var sessions []rated
+	for rows.Next() {
+		var s rated
+		if err := rows.Scan(&s.id, &s.outcome, &s.rating); err != nil {
+			rows.Close()
+			return OutcomeReport{}, fmt.Errorf("failed to scan outcome: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return OutcomeReport{}, fmt.Errorf("failed to query outcomes: %w", err)
+	}
+
+	// Sessions are loaded one at a time, like the table export, and only
+	// their totals are kept
+	usages := make([]sessionUsage, 0, len(sessions))
+	for _, s := range sessions {
+		session, err := loadSession(db, s.id)
+		if err != nil {
+			return OutcomeReport{}, err
+		}
+		usage := measureSession(session)
+		usage.outcome = s.outcome
+		if s.rating.Valid {
+			rating := int(s.rating.Int64)
+			usage.rating = &rating
+		}
+		usages = append(usages, usage)
+	}
+	return buildOutcomeReport(usages), nil
+}
+
+// buildOutcomeReport aggregates measured sessions by outcome and by tool
+func buildOutcomeReport(usages []sessionUsage) OutcomeReport {
+	report := OutcomeReport{Sessions: len(usages), Ratings: make(map[int]int), Outcomes: []OutcomeStats{}, Tools: []ToolOutcomeStats{}}
+	toolSessions := make(map[string]int)
+	toolSuccesses := make(map[string]int)
+
+	for _, outcome := range sessionOutcomes {
+		stats := OutcomeStats{Outcome: outcome, Tools: []OutcomeToolStats{}}
+		var totals []int64
+		var durations []float64
+		var ratingSum, toolCalls, toolErrors int
+		tools := make(map[string]*OutcomeToolStats)
+		for _, u := range usages {
+			if u.outcome != outcome {
+				continue
+			}
+			stats.Sessions++
+			if u.rating != nil {
+				stats.Rated++
+				ratingSum += *u.rating
+				report.Ratings[*u.rating]++
+			}
+			stats.AvgInputTokens += float64(u.inputTokens)
+			stats.AvgOutputTokens += float64(u.outputTokens)
+			totals = append(totals, u.inputTokens+u.outputTokens)
+			durations = append(durations, u.duration.Seconds())
+			stats.AvgDurationSeconds += u.duration.Seconds()
+			for name, calls := range u.toolCalls {
+				t := tools[name]
+				if t == nil {
+					t = &OutcomeToolStats{Name: name}
+					tools[name] = t
+				}
+				t.Sessions++
+				t.Calls += calls
+				toolCalls += calls
+				toolSessions[name]++
+				if outcome == outcomeSuccess {
+					toolSuccesses[name]++
+				}
+			}
+			toolErrors += u.toolErrors
+		}
+		if stats.Sessions == 0 {
+			continue
+		}
+		n := float64(stats.Sessions)
+		if stats.Rated > 0 {
+			stats.AvgRating = float64(ratingSum) / float64(stats.Rated)
+		}
+		stats.AvgInputTokens /= n
+		stats.AvgOutputTokens /= n
+		stats.AvgDurationSeconds /= n
+		stats.AvgToolCalls = float64(toolCalls) / n
+		if toolCalls > 0 {
+			stats.ToolErrorRate = float64(toolErrors) / float64(toolCalls)
+		}
+		sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+		stats.MedianTotalTokens = totals[len(totals)/2]
+		sort.Float64s(durations)
+		stats.MedianDurationSeconds = durations[len(durations)/2]
+
+		for _, t := range tools {
+			stats.Tools = append(stats.Tools, *t)
+		}
+		sort.Slice(stats.Tools, func(i, j int) bool {
+			if stats.Tools[i].Sessions != stats.Tools[j].Sessions {
+				return stats.Tools[i].Sessions > stats.Tools[j].Sessions
+			}
+			return stats.Tools[i].Name < stats.Tools[j].Name
+		})
+		if len(stats.Tools) > maxOutcomeTools {
+			stats.Tools = stats.Tools[:maxOutcomeTools]
+		}
+		report.Outcomes = append(report.Outcomes, stats)
+	}
+
+	for _, name := range sortedKeys(toolSessions) {
+		report.Tools = append(report.Tools, ToolOutcomeStats{
+			Name:        name,
+			Sessions:    toolSessions[name],
+			SuccessRate: float64(toolSuccesses[name]) / float64(toolSessions[name]),
+		})
+	}
+	sort.SliceStable(report.Tools, func(i, j int) bool { return report.Tools[i].Sessions > report.Tools[j].Sessions })
+	return report
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// OutlineConfig configures the summaries of session outlines
+type OutlineConfig struct {
+	// ChunkSize is how many characters of transcript one chunk covers
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// SectionSize is how many chunks one section groups
+	SectionSize int `json:"section_size,omitempty"`
+	// LLM summarizes through the Anthropic API when its key is set;
+	// without one, summaries are extracted from prompts and tool calls
+	LLM LLMTitleConfig `json:"llm"`
+}
+
+const (
+	defaultOutlineChunkSize   = 12000
+	defaultOutlineSectionSize = 6
+	// outlineTitleLength bounds extracted titles, in characters
+	outlineTitleLength = 80
+)
+
+// Outline summarizers
+const (
+	outlineLLM        = "llm"
+	outlineExtractive = "extractive"
+)
+
+// OutlineNode summarizes a stretch of a session. Message indexes point into
+// the session's messages so a viewer can jump to the part a node describes.
+type OutlineNode struct {
+	Title        string     `json:"title"`
+	Summary      string     `json:"summary"`
+	FirstMessage int        `json:"first_message"`
+	LastMessage  int        `json:"last_message"`
+	Start        *time.Time `json:"start,omitempty"`
+	End          *time.Time `json:"end,omitempty"`
+	// Hash identifies what the summary was made from, so summaries of
+	// unchanged chunks and sections are reused when a session grows
+	Hash     string        `json:"hash,omitempty"`
+	Children []OutlineNode `json:"children,omitempty"`
+}
+
+// SessionOutline is the response of GET /api/sessions/{id}/outline: an
+// overall summary of sections, each summarizing a few chunks of transcript
+type SessionOutline struct {
+	SessionID    string        `json:"session_id"`
+	Summarizer   string        `json:"summarizer"`
+	MessageCount int           `json:"message_count"`
+	GeneratedAt  time.Time     `json:"generated_at"`
+	Title        string        `json:"title"`
+	Summary      string        `json:"summary"`
+	Hash         string        `json:"hash,omitempty"`
+	Sections     []OutlineNode `json:"sections"`
+}
+
+// outlineChunk is a run of whole turns of a session, rendered as text
+type outlineChunk struct {
+	node    OutlineNode
+	text    string
+	prompts []string
+	tools   map[string]int
+	files   map[string]bool
+}
+
+// Outliner builds session outlines, summarizing with the model when an API
+// key is available
+type Outliner struct {
+	chunkSize   int
+	sectionSize int
+	model       string
+	apiKey      string
+	client      *http.Client
+}
+
+// NewOutliner fills in the outline defaults
+func NewOutliner(config OutlineConfig) *Outliner {
+	o := &Outliner{
+		chunkSize:   config.ChunkSize,
+		sectionSize: config.SectionSize,
+		model:       config.LLM.Model,
+		client:      &http.Client{Timeout: 60 * time.Second},
+	}
+	if o.chunkSize <= 0 {
+		o.chunkSize = defaultOutlineChunkSize
+	}
+	if o.sectionSize <= 0 {
+		o.sectionSize = defaultOutlineSectionSize
+	}
+	if o.model == "" {
+		o.model = defaultTitleModel
+	}
+	keyEnv := config.LLM.APIKeyEnv
+	if keyEnv == "" {
+		keyEnv = defaultTitleKeyEnv
+	}
+	o.apiKey = os.Getenv(keyEnv)
+	return o
+}
+
+// summarizer names how this outliner summarizes
+func (o *Outliner) summarizer() string {
+	if o.apiKey != "" {
+		return outlineLLM
+	}
+	return outlineExtractive
+}
+
+// chunks splits a session into chunks of about chunkSize characters. A chunk
+// only ends where a user prompt starts, so no turn is split.
+func (o *Outliner) chunks(session *ClaudeSession) []outlineChunk {
+	var chunks []outlineChunk
+	var cur *outlineChunk
+	var text strings.Builder
+
+	flush := func() {
+		if cur != nil {
+			cur.text = text.String()
+			chunks = append(chunks, *cur)
+		}
+		cur = nil
+		text.Reset()
+	}
+
+	for i, msg := range session.Messages {
+		if msg.Type == "summary" {
+			continue
+		}
+		role := messageRole(msg)
+		for _, block := range messageBlocks(msg) {
+			var line string
+			switch block.Type {
+			case "text":
+				t := strings.TrimSpace(block.Text)
+				if t == "" || (role == "user" && isInjectedText(t)) {
+					continue
+				}
+				if role == "user" && cur != nil && text.Len() >= o.chunkSize {
+					flush()
+				}
+				if role == "user" {
+					line = "User: " + t
+				} else {
+					line = "Assistant: " + t
+				}
+			case "tool_use":
+				line = fmt.Sprintf("[%s %s]", block.Name, truncateText(string(block.Input), 200))
+			default:
+				continue
+			}
+
+			if cur == nil {
+				cur = &outlineChunk{node: OutlineNode{FirstMessage: i}, tools: map[string]int{}, files: map[string]bool{}}
+			}
+			cur.node.LastMessage = i
+			if at, ok := messageTime(msg.Timestamp).(time.Time); ok {
+				if cur.node.Start == nil {
+					cur.node.Start = &at
+				}
+				cur.node.End = &at
+			}
+			switch {
+			case block.Type == "tool_use":
+				cur.tools[block.Name]++
+				var input fileEditInput
+				if json.Unmarshal(block.Input, &input) == nil && input.FilePath != "" {
+					cur.files[input.FilePath] = true
+				}
+			case role == "user":
+				cur.prompts = append(cur.prompts, strings.TrimSpace(block.Text))
+			}
+			text.WriteString(line)
+			text.WriteString("\n\n")
+		}
+	}
+	flush()
+	return chunks
+}
+
+// Build outlines a session. Summaries in previous, an outline stored for an
+// earlier version of the session, are reused where their input is unchanged.
+func (o *Outliner) Build(session *ClaudeSession, previous *SessionOutline) *SessionOutline {
+	reuse := map[string]OutlineNode{}
+	if previous != nil && previous.Summarizer == o.summarizer() {
+		for _, section := range previous.Sections {
+			reuse[section.Hash] = section
+			for _, chunk := range section.Children {
+				reuse[chunk.Hash] = chunk
+			}
+		}
+	}
+
+	chunks := o.chunks(session)
+	outline := &SessionOutline{
+		SessionID:    session.SessionID,
+		Summarizer:   o.summarizer(),
+		MessageCount: len(session.Messages),
+		GeneratedAt:  time.Now().UTC(),
+		Sections:     []OutlineNode{},
+	}
+	for start := 0; start < len(chunks); start += o.sectionSize {
+		group := chunks[start:min(start+o.sectionSize, len(chunks))]
+		section := OutlineNode{
+			FirstMessage: group[0].node.FirstMessage,
+			LastMessage:  group[len(group)-1].node.LastMessage,
+			Start:        group[0].node.Start,
+			End:          group[len(group)-1].node.End,
+		}
+		var hashes []string
+		for _, chunk := range group {
+			node := chunk.node
+			node.Hash = outlineHash(chunk.text)
+			if stored, ok := reuse[node.Hash]; ok {
+				node.Title, node.Summary = stored.Title, stored.Summary
+			} else {
+				node.Title, node.Summary, node.Hash = o.summarizeChunk(chunk, node.Hash)
+			}
+			hashes = append(hashes, node.Hash)
+			section.Children = append(section.Children, node)
+		}
+		section.Hash = outlineHash(hashes...)
+		if stored, ok := reuse[section.Hash]; ok {
+			section.Title, section.Summary = stored.Title, stored.Summary
+		} else {
+			section.Title, section.Summary, section.Hash = o.summarizeNodes("part of a coding session", section.Children, section.Hash)
+		}
+		outline.Sections = append(outline.Sections, section)
+	}
+
+	var hashes []string
+	for _, section := range outline.Sections {
+		hashes = append(hashes, section.Hash)
+	}
+	outline.Hash = outlineHash(hashes...)
+	if previous != nil && previous.Hash == outline.Hash && previous.Summarizer == outline.Summarizer {
+		outline.Title, outline.Summary = previous.Title, previous.Summary
+	} else {
+		outline.Title, outline.Summary, outline.Hash = o.summarizeNodes("coding session", outline.Sections, outline.Hash)
+	}
+	// An extracted title would only repeat the first prompt
+	if outline.Title == "" || outline.Summarizer == outlineExtractive {
+		outline.Title = session.Title
+	}
+	return outline
+}
+
+// outlineHash fingerprints the input of a summary
+func outlineHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// summarizeChunk summarizes a chunk of transcript. When the model fails the
+// extracted summary is used and the hash cleared, so it is retried next time.
+func (o *Outliner) summarizeChunk(chunk outlineChunk, hash string) (title, summary, outHash string) {
+	if o.apiKey != "" {
+		title, summary, err := o.complete("Summarize this part of a coding session between a user and Claude.", chunk.text)
+		if err == nil {
+			return title, summary, hash
+		}
+		log.Printf("Outline summary failed, using an extracted one: %v", err)
+		hash = ""
+	}
+
+	if len(chunk.prompts) > 0 {
+		title = truncateText(chunk.prompts[0], outlineTitleLength)
+	} else {
+		title = "Continued work"
+	}
+	var parts []string
+	if len(chunk.prompts) > 1 {
+		var prompts []string
+		for _, p := range chunk.prompts[1:] {
+			prompts = append(prompts, truncateText(p, outlineTitleLength))
+		}
+		parts = append(parts, "Then: "+strings.Join(prompts, "; "))
+	}
+	if len(chunk.tools) > 0 {
+		var tools []string
+		for _, name := range sortedKeys(chunk.tools) {
+			tools = append(tools, fmt.Sprintf("%s ×%d", name, chunk.tools[name]))
+		}
+		parts = append(parts, "Tools: "+strings.Join(tools, ", "))
+	}
+	if len(chunk.files) > 0 {
+		files := sortedKeys(chunk.files)
+		if len(files) > 5 {
+			files = append(files[:5], fmt.Sprintf("and %d more", len(files)-5))
+		}
+		parts = append(parts, "Files: "+strings.Join(files, ", "))
+	}
+	return title, strings.Join(parts, ". "), hash
+}
+
+// summarizeNodes summarizes the summaries of the nodes a section or session
+// is made of
+func (o *Outliner) summarizeNodes(what string, nodes []OutlineNode, hash string) (title, summary, outHash string) {
+	if len(nodes) == 0 {
+		return "", "", hash
+	}
+	if o.apiKey != "" {
+		var text strings.Builder
+		for i, node := range nodes {
+			fmt.Fprintf(&text, "%d. %s: %s\n", i+1, node.Title, node.Summary)
+		}
+		title, summary, err := o.complete(fmt.Sprintf("These are summaries of consecutive parts of a %s. Summarize them as a whole.", what), text.String())
+		if err == nil {
+			return title, summary, hash
+		}
+		log.Printf("Outline summary failed, using an extracted one: %v", err)
+		hash = ""
+	}
+
+	titles := make([]string, len(nodes))
+	for i, node := range nodes {
+		titles[i] = node.Title
+	}
+	return nodes[0].Title, strings.Join(titles, "; "), hash
+}
+
+// complete asks the model for a title line followed by a short summary
+func (o *Outliner) complete(instruction, text string) (title, summary string, err error) {
+	reply, err := anthropicComplete(o.client, o.model, o.apiKey, 300, instruction+
+		" Reply with a title of at most eight words on the first line, then a summary of one to three sentences.\n\n"+text)
+	if err != nil {
+		return "", "", err
+	}
+	title, summary, _ = strings.Cut(strings.TrimSpace(reply), "\n")
+	title = strings.Trim(strings.TrimSpace(strings.TrimLeft(title, "#* ")), `"*`)
+	if title == "" {
+		return "", "", fmt.Errorf("model returned an empty summary")
+	}
+	return title, strings.TrimSpace(summary), nil
+}
+
+// loadOutline returns the stored outline of a session, or nil if it has none
+func loadOutline(db *sql.DB, sessionID string) (*SessionOutline, error) {
+	var data []byte
+	err := db.QueryRow(`SELECT outline FROM session_outlines WHERE session_id = $1`, sessionID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outline: %w", err)
+	}
+	var outline SessionOutline
+	if err := json.Unmarshal(data, &outline); err != nil {
+		return nil, fmt.Errorf("failed to parse stored outline: %w", err)
+	}
+	return &outline, nil
+}
+
+// storeOutline saves the outline of a session, replacing any earlier one
+func storeOutline(db *sql.DB, outline *SessionOutline) error {
+	data, err := json.Marshal(outline)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO session_outlines (session_id, message_count, summarizer, outline, generated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (session_id) DO UPDATE SET
+			message_count = EXCLUDED.message_count,
+			summarizer = EXCLUDED.summarizer,
+			outline = EXCLUDED.outline,
+			generated_at = EXCLUDED.generated_at`,
+		outline.SessionID, outline.MessageCount, outline.Summarizer, string(data), outline.GeneratedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store outline: %w", err)
+	}
+	return nil
+}
+
+// sessionOutline returns the stored outline of a session if it is up to
+// date, and otherwise builds and stores a new one
+func sessionOutline(db *sql.DB, outliner *Outliner, session *ClaudeSession, refresh bool) (*SessionOutline, error) {
+	previous, err := loadOutline(db, session.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && !refresh && previous.MessageCount == len(session.Messages) && previous.Summarizer == outliner.summarizer() {
+		return previous, nil
+	}
+	if refresh {
+		previous = nil
+	}
+	outline := outliner.Build(session, previous)
+	if err := storeOutline(db, outline); err != nil {
+		return nil, err
+	}
+	return outline, nil
+}
+
+// handleSessionOutline serves GET /api/sessions/{id}/outline. The outline is
+// rebuilt when the session has grown since it was stored, or with refresh=true.
+// A read-only server returns the stored outline as is.
+func (a *apiServer) handleSessionOutline(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	var config OutlineConfig
+	if c := a.currentConfig(); c != nil {
+		config = c.Outlines
+	}
+	outliner := NewOutliner(config)
+	var outline *SessionOutline
+	if a.readonly {
+		// A public server neither stores outlines nor spends API credits on them
+		outline, err = loadOutline(a.db, session.SessionID)
+		if err == nil && outline == nil {
+			outliner.apiKey = ""
+			outline = outliner.Build(session, nil)
+		}
+	} else {
+		outline, err = sessionOutline(a.db, outliner, session, r.URL.Query().Get("refresh") == "true")
+	}
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, outline)
+}
+
+// outlineCommand builds the outlines of the named sessions and prints them,
+// or builds those of every long session ahead of time
+func outlineCommand(c *cli.Context) error {
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	outliner := NewOutliner(config.Outlines)
+
+	ids, err := sessionArgs(db, c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		rows, err := db.Query(`
+			SELECT session_id FROM claude_sessions
+			WHERE deleted_at IS NULL AND jsonb_array_length(messages) >= $1
+			ORDER BY updated_at DESC`, c.Int("min-messages"))
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan session: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			fmt.Printf("No sessions with at least %d messages\n", c.Int("min-messages"))
+			return nil
+		}
+	}
+
+	for _, id := range ids {
+		session, err := loadSession(db, id)
+		if err != nil {
+			return err
+		}
+		outline, err := sessionOutline(db, outliner, session, c.Bool("refresh"))
+		if err != nil {
+			return err
+		}
+		if c.NArg() == 0 {
+			fmt.Printf("  %s: %d section(s)\n", id, len(outline.Sections))
+			continue
+		}
+		printOutline(outline)
+	}
+	if c.NArg() == 0 {
+		fmt.Printf("✅ Outlined %d session(s) with the %s summarizer\n", len(ids), outliner.summarizer())
+	}
+	return nil
+}
+
+// printOutline writes an outline as an indented tree with message ranges
+func printOutline(outline *SessionOutline) {
+	fmt.Printf("📑 %s (%s)\n", outline.Title, outline.SessionID)
+	if outline.Summary != "" {
+		fmt.Printf("   %s\n", outline.Summary)
+	}
+	for i, section := range outline.Sections {
+		fmt.Printf("\n%d. %s [messages %d-%d]\n", i+1, section.Title, section.FirstMessage, section.LastMessage)
+		for _, chunk := range section.Children {
+			fmt.Printf("   • %s [%d-%d]\n", chunk.Title, chunk.FirstMessage, chunk.LastMessage)
+			if chunk.Summary != "" {
+				fmt.Printf("     %s\n", chunk.Summary)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Page is the response envelope of every list endpoint. NextCursor is empty
+// on the last page. Total is set where counting is cheap: for lists that are
+// returned whole, and for the first page of the session list.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+// pageRequest is the limit and cursor query parameters of a list endpoint.
+// Cursors are keyset positions rather than offsets, so rows synced while a
+// client scrolls never shift the pages it has not fetched yet.
+type pageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// pageFromQuery reads the limit and cursor parameters
+func pageFromQuery(q url.Values, defaultLimit, maxLimit int) (pageRequest, error) {
+	page := pageRequest{Limit: defaultLimit, Cursor: q.Get("cursor")}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		page.Limit = n
+	}
+	return page, nil
+}
+
+// encodeCursor packs the sort keys of the last row of a page into an opaque cursor
+func encodeCursor(keys ...interface{}) string {
+	data, _ := json.Marshal(keys)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decode unpacks the cursor into pointers to its sort keys. It reports false
+// when the request has no cursor, meaning the first page.
+func (p pageRequest) decode(keys ...interface{}) (bool, error) {
+	if p.Cursor == "" {
+		return false, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return false, fmt.Errorf("invalid cursor")
+	}
+	var raw []json.RawMessage
+	if json.Unmarshal(data, &raw) != nil || len(raw) != len(keys) {
+		return false, fmt.Errorf("invalid cursor")
+	}
+	for i, key := range keys {
+		if json.Unmarshal(raw[i], key) != nil {
+			return false, fmt.Errorf("invalid cursor")
+		}
+	}
+	return true, nil
+}
+
+// newPage builds a page from rows queried with a limit one higher than the
+// page size; the extra row only signals that another page exists
+func newPage[T any](rows []T, p pageRequest, cursor func(T) string) Page[T] {
+	page := Page[T]{Data: rows}
+	if len(rows) > p.Limit {
+		page.Data = rows[:p.Limit]
+		page.NextCursor = cursor(page.Data[p.Limit-1])
+	}
+	return page
+}
+
+// wholePage wraps a list that is always returned in full
+func wholePage[T any](rows []T) Page[T] {
+	total := len(rows)
+	return Page[T]{Data: rows, Total: &total}
+}
+
+// afterNullableTime is the keyset condition for rows after a cursor when
+// rows are ordered by a nullable time descending with nulls last, then by
+// tie-breaking columns ascending. ties is a row constructor such as
+// "(t.session_id, t.position)". It appends the cursor values to args.
+func afterNullableTime(column, ties string, at *time.Time, tieValues []interface{}, args []interface{}) (string, []interface{}) {
+	placeholders := "("
+	for i, v := range tieValues {
+		args = append(args, v)
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "$" + strconv.Itoa(len(args))
+	}
+	placeholders += ")"
+	if at == nil {
+		return fmt.Sprintf("(%s IS NULL AND %s > %s)", column, ties, placeholders), args
+	}
+	args = append(args, *at)
+	n := len(args)
+	return fmt.Sprintf("(%s < $%d OR %s IS NULL OR (%s = $%d AND %s > %s))", column, n, column, column, n, ties, placeholders), args
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A minimal Parquet writer for the analytics export: every column is
+// OPTIONAL, PLAIN encoded and uncompressed, and each row group is written
+// as a single data page per column. That is enough for DuckDB, pandas and
+// Spark to read the files without pulling in a Parquet library.
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// parquetRowGroupSize is how many rows are buffered before a row group is written
+const parquetRowGroupSize = 50000
+
+// Parquet physical types, encodings and converted types from parquet.thrift
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+)
+
+// parquetWriter writes rows of a table to a Parquet file
+type parquetWriter struct {
+	w         io.Writer
+	columns   []tableColumn
+	rows      [][]interface{}
+	offset    int64
+	numRows   int64
+	rowGroups []parquetRowGroup
+}
+
+// parquetRowGroup records where a written row group's column chunks are
+type parquetRowGroup struct {
+	numRows int64
+	size    int64
+	chunks  []parquetChunk
+}
+
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+func newParquetWriter(w io.Writer, columns []tableColumn) (*parquetWriter, error) {
+	if _, err := io.WriteString(w, parquetMagic); err != nil {
+		return nil, err
+	}
+	return &parquetWriter{w: w, columns: columns, offset: int64(len(parquetMagic))}, nil
+}
+
+func (p *parquetWriter) WriteRow(row []interface{}) error {
+	p.rows = append(p.rows, row)
+	if len(p.rows) >= parquetRowGroupSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer
+func (p *parquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	footer := p.fileMetadata()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, length[:], []byte(parquetMagic)} {
+		if _, err := p.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes the buffered rows as one row group
+func (p *parquetWriter) flush() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	group := parquetRowGroup{numRows: int64(len(p.rows))}
+	for i, col := range p.columns {
+		page, err := p.dataPage(i, col)
+		if err != nil {
+			return err
+		}
+		header := parquetPageHeader(len(p.rows), len(page))
+		chunk := parquetChunk{offset: p.offset, size: int64(len(header) + len(page)), numValues: int64(len(p.rows))}
+		if _, err := p.w.Write(header); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(page); err != nil {
+			return err
+		}
+		p.offset += chunk.size
+		group.size += chunk.size
+		group.chunks = append(group.chunks, chunk)
+	}
+	p.rowGroups = append(p.rowGroups, group)
+	p.numRows += group.numRows
+	p.rows = p.rows[:0]
+	return nil
+}
+
+// dataPage encodes one column of the buffered rows: definition levels
+// marking nulls, then the PLAIN encoded non-null values
+func (p *parquetWriter) dataPage(index int, col tableColumn) ([]byte, error) {
+	defined := make([]bool, len(p.rows))
+	var values bytes.Buffer
+	var bools []bool
+	for r, row := range p.rows {
+		v := row[index]
+		if v == nil {
+			continue
+		}
+		defined[r] = true
+		switch col.kind {
+		case columnString:
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected string, got %T", col.name, v)
+			}
+			binary.Write(&values, binary.LittleEndian, uint32(len(s)))
+			values.WriteString(s)
+		case columnInt:
+			n, ok := v.(int64)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected int64, got %T", col.name, v)
+			}
+			binary.Write(&values, binary.LittleEndian, n)
+		case columnTime:
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected time, got %T", col.name, v)
+			}
+			binary.Write(&values, binary.LittleEndian, t.UnixMilli())
+		case columnBool:
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("column %s: expected bool, got %T", col.name, v)
+			}
+			bools = append(bools, b)
+		}
+	}
+	if col.kind == columnBool {
+		values.Write(packBits(bools))
+	}
+
+	// Definition levels use the RLE/bit-packed hybrid, here as one
+	// bit-packed run, prefixed with its byte length
+	levels := packBits(defined)
+	var run bytes.Buffer
+	run.Write(binary.AppendUvarint(nil, uint64(len(levels))<<1|1))
+	run.Write(levels)
+
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(run.Len()))
+	page.Write(run.Bytes())
+	page.Write(values.Bytes())
+	return page.Bytes(), nil
+}
+
+// packBits packs booleans LSB first, padded to whole bytes
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// parquetPageHeader encodes the PageHeader of an uncompressed data page
+func parquetPageHeader(numValues, size int) []byte {
+	var t thriftWriter
+	t.i32(1, 0) // DATA_PAGE
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.beginStruct(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetPlain)
+	t.i32(3, parquetRLE)
+	t.i32(4, parquetRLE)
+	t.endStruct()
+	return t.end()
+}
+
+// fileMetadata encodes the FileMetaData footer
+func (p *parquetWriter) fileMetadata() []byte {
+	var t thriftWriter
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(p.columns)+1)
+	t.beginElement()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(p.columns)))
+	t.endStruct()
+	for _, col := range p.columns {
+		t.beginElement()
+		t.i32(1, col.parquetType())
+		t.i32(3, 1) // OPTIONAL
+		t.binary(4, col.name)
+		switch col.kind {
+		case columnString:
+			t.i32(6, parquetUTF8)
+		case columnTime:
+			t.i32(6, parquetTimestampMillis)
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, p.numRows)
+
+	t.list(4, thriftStruct, len(p.rowGroups))
+	for _, group := range p.rowGroups {
+		t.beginElement()
+		t.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			col := p.columns[i]
+			t.beginElement()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			t.i32(1, col.parquetType())
+			t.list(2, thriftI32, 2)
+			t.varint(parquetPlain)
+			t.varint(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.rawBinary(col.name)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.binary(6, "claudemd")
+	return t.end()
+}
+
+func (c tableColumn) parquetType() int32 {
+	switch c.kind {
+	case columnInt, columnTime:
+		return parquetInt64
+	case columnBool:
+		return parquetBoolean
+	}
+	return parquetByteArray
+}
+
+// Thrift compact protocol type ids
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, which
+// Parquet uses for its page headers and footer
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last holds the previous field id of each open struct
+	last []int16
+}
+
+func (t *thriftWriter) varint(v int64) {
+	// Compact protocol integers are zigzag encoded
+	t.buf.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if len(t.last) == 0 {
+		t.last = []int16{0}
+	}
+	top := &t.last[len(t.last)-1]
+	if delta := id - *top; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	*top = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftWriter) rawBinary(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elem)
+	t.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement opens a struct inside a list, which has no field header
+func (t *thriftWriter) beginElement() {
+	if len(t.last) == 0 {
+		t.last = []int16{0}
+	}
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// end closes the top-level struct and returns the encoding
+func (t *thriftWriter) end() []byte {
+	t.buf.WriteByte(0)
+	return t.buf.Bytes()
+}
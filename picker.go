@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minSessionPrefix is the shortest abbreviation accepted for a session ID
+	minSessionPrefix = 4
+	// pickerSessions is how many recent sessions the picker lists
+	pickerSessions = 1000
+)
+
+// errNoSessionPicked is returned when the picker is cancelled
+var errNoSessionPicked = errors.New("no session selected")
+
+// resolveSessionID expands a unique prefix of a session ID, as git does for
+// commit hashes. An exact match always wins.
+func resolveSessionID(db *sql.DB, id string) (string, error) {
+	rows, err := db.Query(`
+		SELECT session_id FROM claude_sessions
+		WHERE deleted_at IS NULL AND left(session_id, char_length($1)) = $1
+		ORDER BY session_id = $1 DESC, updated_at DESC
+		LIMIT 6`, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve session ID: %w", err)
+	}
+	defer rows.Close()
+	var matches []string
+	for rows.Next() {
+		var match string
+		if err := rows.Scan(&match); err != nil {
+			return "", fmt.Errorf("failed to scan session ID: %w", err)
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(matches) > 0 && matches[0] == id:
+		return id, nil
+	case len(matches) == 0:
+		return "", fmt.Errorf("%w: %s", errSessionNotFound, id)
+	case len(id) < minSessionPrefix:
+		return "", fmt.Errorf("session ID prefix %q is too short; use at least %d characters", id, minSessionPrefix)
+	case len(matches) > 1:
+		if len(matches) > 5 {
+			matches = append(matches[:5], "…")
+		}
+		return "", fmt.Errorf("session ID %s is ambiguous: %s", id, strings.Join(matches, ", "))
+	}
+	return matches[0], nil
+}
+
+// sessionArgs resolves the session IDs given on the command line. When none
+// are given and a terminal is attached, one session is picked for each
+// prompt; otherwise the IDs are required.
+func sessionArgs(db *sql.DB, args []string, prompts ...string) ([]string, error) {
+	if len(args) == 0 && len(prompts) > 0 {
+		return pickSessions(db, prompts)
+	}
+	ids := make([]string, len(args))
+	for i, arg := range args {
+		id, err := resolveSessionID(db, arg)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// pickSessions runs the picker once per prompt
+func pickSessions(db *sql.DB, prompts []string) ([]string, error) {
+	// The terminal is opened directly so output can still be redirected
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("session ID is required")
+	}
+	defer tty.Close()
+
+	sessions, err := listSessionSummaries(db, SessionFilter{}, nil, pickerSessions)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("no sessions have been synced yet")
+	}
+
+	restore, err := rawTerminal(tty)
+	if err != nil {
+		return nil, fmt.Errorf("session ID is required: %w", err)
+	}
+	defer restore()
+
+	p := &sessionPicker{tty: tty, sessions: sessions}
+	p.rows, p.cols = terminalSize(tty)
+	// Draw on the alternate screen so the shell's scrollback is left alone
+	fmt.Fprint(tty, "\033[?1049h")
+	defer fmt.Fprint(tty, "\033[?1049l")
+
+	ids := make([]string, 0, len(prompts))
+	for _, prompt := range prompts {
+		id, err := p.run(prompt)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// rawTerminal switches the terminal to raw mode with stty and returns a
+// function that restores the previous settings
+func rawTerminal(tty *os.File) (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = tty
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read terminal settings: %w", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+	return func() { stty(saved) }, nil
+}
+
+// terminalSize returns the rows and columns of the terminal, or 24 by 80
+// when they cannot be read
+func terminalSize(tty *os.File) (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err == nil {
+		if fields := strings.Fields(string(out)); len(fields) == 2 {
+			rows, err1 := strconv.Atoi(fields[0])
+			cols, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil && rows > 2 && cols > 20 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// sessionPicker is a fuzzy finder over recent sessions, most recent first
+type sessionPicker struct {
+	tty        *os.File
+	sessions   []SessionSummary
+	rows, cols int
+
+	query   []rune
+	matches []int
+	cursor  int
+}
+
+// pickerLine renders a session as one line of the list
+func pickerLine(s SessionSummary) string {
+	id := s.SessionID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("%-8s  %s  %-20s  %s", id, s.UpdatedAt.Local().Format("2006-01-02 15:04"),
+		truncateTitle(s.Project, 20), s.Title)
+}
+
+// clipLine cuts a line to the terminal width, keeping its spacing
+func clipLine(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// fuzzyScore matches query as a subsequence of text, ignoring case. Lower
+// scores are better: substrings beat scattered letters, early beats late.
+func fuzzyScore(query []rune, text string) (int, bool) {
+	if len(query) == 0 {
+		return 0, true
+	}
+	lower := strings.ToLower(text)
+	if i := strings.Index(lower, strings.ToLower(string(query))); i >= 0 {
+		return i, true
+	}
+	score, qi, last := 0, 0, -1
+	for i, r := range lower {
+		if qi < len(query) && r == unicode.ToLower(query[qi]) {
+			if last >= 0 {
+				score += i - last
+			}
+			last = i
+			qi++
+		}
+	}
+	if qi < len(query) {
+		return 0, false
+	}
+	return len(lower) + score, true
+}
+
+// filter recomputes the matches of the query
+func (p *sessionPicker) filter() {
+	type match struct{ index, score int }
+	var found []match
+	for i, s := range p.sessions {
+		text := s.SessionID + " " + s.Project + " " + s.Title + " " + s.UpdatedAt.Local().Format("2006-01-02")
+		if score, ok := fuzzyScore(p.query, text); ok {
+			found = append(found, match{i, score})
+		}
+	}
+	sort.SliceStable(found, func(a, b int) bool { return found[a].score < found[b].score })
+	p.matches = p.matches[:0]
+	for _, m := range found {
+		p.matches = append(p.matches, m.index)
+	}
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// draw renders the prompt line and as many matches as fit below it
+func (p *sessionPicker) draw(prompt string) {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	visible := p.rows - 2
+	start := 0
+	if p.cursor >= visible {
+		start = p.cursor - visible + 1
+	}
+	for i := start; i < len(p.matches) && i < start+visible; i++ {
+		line := clipLine(pickerLine(p.sessions[p.matches[i]]), p.cols-2)
+		if i == p.cursor {
+			fmt.Fprintf(&b, "\033[7m> %s\033[0m\r\n", line)
+		} else {
+			fmt.Fprintf(&b, "  %s\r\n", line)
+		}
+	}
+	fmt.Fprintf(&b, "\033[%d;1H\033[2m  %d/%d · ↑↓ select · enter pick · esc cancel\033[0m", p.rows-1, len(p.matches), len(p.sessions))
+	fmt.Fprintf(&b, "\033[%d;1H%s> %s", p.rows, prompt, string(p.query))
+	fmt.Fprint(p.tty, b.String())
+}
+
+// run reads keys until a session is picked or the picker is cancelled
+func (p *sessionPicker) run(prompt string) (string, error) {
+	p.query, p.cursor = nil, 0
+	p.filter()
+	buf := make([]byte, 64)
+	for {
+		p.draw(prompt)
+		n, err := p.tty.Read(buf)
+		if err != nil {
+			return "", fmt.Errorf("failed to read from terminal: %w", err)
+		}
+		key := string(buf[:n])
+		switch key {
+		case "\r":
+			if len(p.matches) > 0 {
+				return p.sessions[p.matches[p.cursor]].SessionID, nil
+			}
+		case "\x1b", "\x03", "\x04":
+			return "", errNoSessionPicked
+		case "\x1b[A", "\x1bOA", "\x10", "\x0b":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case "\x1b[B", "\x1bOB", "\x0e", "\x0a":
+			if p.cursor < len(p.matches)-1 {
+				p.cursor++
+			}
+		case "\x7f", "\x08":
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case "\x15":
+			p.query = nil
+			p.filter()
+		default:
+			if strings.HasPrefix(key, "\x1b") {
+				continue
+			}
+			for _, r := range key {
+				if unicode.IsPrint(r) {
+					p.query = append(p.query, r)
+				}
+			}
+			p.cursor = 0
+			p.filter()
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultPluginTimeout bounds a run of a command plugin
+const defaultPluginTimeout = 30 * time.Second
+
+// SessionPlugin post-processes a parsed session before it is stored. It may
+// change anything but the session ID: classify it into metadata, mask
+// content, or hand it on to another system.
+type SessionPlugin interface {
+	Process(session *ClaudeSession, filePath string) error
+}
+
+// PluginConfig registers a sync post-processor. Exactly one of Builtin and
+// Command is set. Plugins run in order, after redaction and before titling.
+type PluginConfig struct {
+	Name string `json:"name"`
+	// Builtin names a plugin compiled into claudemd, configured by Options
+	Builtin string          `json:"builtin,omitempty"`
+	Options json.RawMessage `json:"options,omitempty"`
+	// Command runs an executable that reads the session as JSON on stdin and
+	// prints it back, changed or not, on stdout. Printing nothing keeps it.
+	Command []string `json:"command,omitempty"`
+	// Timeout bounds each run of Command, as a Go duration (default 30s)
+	Timeout string `json:"timeout,omitempty"`
+	// Optional plugins that fail are logged and skipped; otherwise the
+	// session is not stored
+	Optional bool `json:"optional,omitempty"`
+}
+
+// pluginFactory builds a compiled-in plugin from its options
+type pluginFactory func(options json.RawMessage) (SessionPlugin, error)
+
+// builtinPlugins are the plugins Builtin can name
+var builtinPlugins = map[string]pluginFactory{
+	"metadata": newMetadataPlugin,
+}
+
+// configuredPlugin is a plugin with the settings of its config entry
+type configuredPlugin struct {
+	SessionPlugin
+	name     string
+	optional bool
+}
+
+// pluginChain runs the configured plugins in order
+type pluginChain []configuredPlugin
+
+// newPluginChain builds the plugins of the config
+func newPluginChain(configs []PluginConfig) (pluginChain, error) {
+	var chain pluginChain
+	for i, config := range configs {
+		name := config.Name
+		if name == "" {
+			name = config.Builtin
+		}
+		if name == "" && len(config.Command) > 0 {
+			name = config.Command[0]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("plugin %d needs a builtin or a command", i+1)
+		}
+
+		var plugin SessionPlugin
+		switch {
+		case config.Builtin != "" && len(config.Command) > 0:
+			return nil, fmt.Errorf("plugin %s cannot have both a builtin and a command", name)
+		case config.Builtin != "":
+			factory, ok := builtinPlugins[config.Builtin]
+			if !ok {
+				return nil, fmt.Errorf("plugin %s: unknown builtin %q", name, config.Builtin)
+			}
+			var err error
+			if plugin, err = factory(config.Options); err != nil {
+				return nil, fmt.Errorf("plugin %s: %w", name, err)
+			}
+		default:
+			timeout := defaultPluginTimeout
+			if config.Timeout != "" {
+				var err error
+				if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+					return nil, fmt.Errorf("plugin %s: invalid timeout %q", name, config.Timeout)
+				}
+			}
+			plugin = execPlugin{name: name, command: config.Command, timeout: timeout}
+		}
+		chain = append(chain, configuredPlugin{SessionPlugin: plugin, name: name, optional: config.Optional})
+	}
+	return chain, nil
+}
+
+// Process runs every plugin over the session. Each works on a copy that
+// replaces the session only when the plugin succeeds, so optional plugins
+// that fail are logged and skipped without leaving half their changes.
+func (chain pluginChain) Process(session *ClaudeSession, filePath string) error {
+	for _, plugin := range chain {
+		result := cloneSession(session)
+		err := plugin.Process(result, filePath)
+		if err == nil && result.SessionID != session.SessionID {
+			err = fmt.Errorf("changed the session ID to %q", result.SessionID)
+		}
+		if err == nil {
+			*session = *result
+			continue
+		}
+		if !plugin.optional {
+			return fmt.Errorf("plugin %s failed on %s: %w", plugin.name, session.SessionID, err)
+		}
+		log.Printf("Plugin %s failed on %s, skipping it: %v", plugin.name, session.SessionID, err)
+	}
+	return nil
+}
+
+// cloneSession copies the session deeply enough that changing the copy's
+// messages or metadata in place leaves the original as it was
+func cloneSession(session *ClaudeSession) *ClaudeSession {
+	clone := *session
+	if session.Messages != nil {
+		clone.Messages = make([]SessionMessage, len(session.Messages))
+		for i, msg := range session.Messages {
+			msg.Message = bytes.Clone(msg.Message)
+			clone.Messages[i] = msg
+		}
+	}
+	if session.Metadata != nil {
+		clone.Metadata = cloneJSONValue(session.Metadata).(map[string]interface{})
+	}
+	return &clone
+}
+
+// cloneJSONValue copies the objects and arrays of a decoded JSON value
+func cloneJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneJSONValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneJSONValue(item)
+		}
+		return clone
+	case json.RawMessage:
+		return bytes.Clone(v)
+	default:
+		return v
+	}
+}
+
+// execPlugin pipes the session through an external command
+type execPlugin struct {
+	name    string
+	command []string
+	timeout time.Duration
+}
+
+// Process passes the session as JSON on stdin, with CLAUDEMD_SOURCE_FILE set
+// to the file it was read from, and reads it back from stdout
+func (p execPlugin) Process(session *ClaudeSession, filePath string) error {
+	input, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "CLAUDEMD_SOURCE_FILE="+filePath, "CLAUDEMD_PLUGIN="+p.name)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", p.timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil
+	}
+
+	var result ClaudeSession
+	if err := json.Unmarshal(output, &result); err != nil {
+		return fmt.Errorf("invalid session on stdout: %w", err)
+	}
+	*session = result
+	return nil
+}
+
+// metadataPlugin sets fixed metadata fields on every session, such as the
+// team or machine it came from. Its options are the fields to set.
+type metadataPlugin struct {
+	fields map[string]interface{}
+}
+
+func newMetadataPlugin(options json.RawMessage) (SessionPlugin, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(options, &fields); err != nil || len(fields) == 0 {
+		return nil, fmt.Errorf("options must be an object of metadata fields")
+	}
+	return metadataPlugin{fields: fields}, nil
+}
+
+func (p metadataPlugin) Process(session *ClaudeSession, filePath string) error {
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]interface{})
+	}
+	for key, value := range p.fields {
+		session.Metadata[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+// previewTTL is how long an unsaved snippet stays available for rendering
+const previewTTL = time.Hour
+
+// preview is an unsaved TSX snippet served under /render/__preview/{id}
+type preview struct {
+	Source    string
+	Component string
+	Created   time.Time
+}
+
+// previewStore holds previews in memory; they disappear when the server stops
+type previewStore struct {
+	mu    sync.Mutex
+	items map[string]*preview
+}
+
+var previews = &previewStore{items: make(map[string]*preview)}
+
+// Add builds the snippet to catch errors early, then stores it and returns its ID
+func (s *previewStore) Add(source, component string) (string, []string) {
+	result := buildAsESModule(source, getCurrentDir(), "preview.tsx")
+	if len(result.Errors) > 0 {
+		return "", formatBuildErrors(result.Errors)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, p := range s.items {
+		if time.Since(p.Created) > previewTTL {
+			delete(s.items, id)
+		}
+	}
+	id := uuid.NewString()[:8]
+	s.items[id] = &preview{Source: source, Component: component, Created: time.Now()}
+	return id, nil
+}
+
+// Get returns a preview that has not expired
+func (s *previewStore) Get(id string) (*preview, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.items[id]
+	if !ok || time.Since(p.Created) > previewTTL {
+		return nil, false
+	}
+	return p, true
+}
+
+// handleRenderPreview serves GET /render/__preview/{id}
+func handleRenderPreview(w http.ResponseWriter, r *http.Request) {
+	p, ok := previews.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Preview not found or expired", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(generateComponentHTML(p.Component, "__preview/"+r.PathValue("id"), "")))
+}
+
+// handlePreviewModule serves GET /module/__preview/{id}
+func handlePreviewModule(w http.ResponseWriter, r *http.Request) {
+	p, ok := previews.Get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Preview not found or expired", nil)
+		return
+	}
+
+	start := time.Now()
+	result := buildAsESModule(p.Source, getCurrentDir(), "preview.tsx")
+	stats.RecordBuild("preview:"+r.PathValue("id"), time.Since(start), len(result.Errors) > 0)
+	if len(result.Errors) > 0 {
+		writeJSONError(w, r, http.StatusBadRequest, "Build failed", formatBuildErrors(result.Errors))
+		return
+	}
+
+	serveModuleContent(w, r, result.OutputFiles[0].Contents, "no-cache")
+}
+
+// controlSocketPath returns the per-project socket a running server listens on
+// for local commands. It lives in the temp dir because socket paths are length limited.
+func controlSocketPath() string {
+	sum := sha1.Sum([]byte(getCurrentDir()))
+	return filepath.Join(os.TempDir(), "claudemd-"+hex.EncodeToString(sum[:6])+".sock")
+}
+
+// previewRequest is sent over the control socket to register a snippet
+type previewRequest struct {
+	Source    string `json:"source"`
+	Component string `json:"component"`
+}
+
+// startControlSocket lets local commands talk to this server. It is only
+// reachable through the filesystem, so it is never exposed on the TCP port.
+func startControlSocket(baseURL string) (func(), error) {
+	socketPath := controlSocketPath()
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another server already owns %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	os.Chmod(socketPath, 0600)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /previews", func(w http.ResponseWriter, r *http.Request) {
+		var req previewRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		id, errs := previews.Add(req.Source, req.Component)
+		if errs != nil {
+			writeJSONError(w, r, http.StatusBadRequest, "Build failed", errs)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"id": id, "url": baseURL + "/render/__preview/" + id})
+	})
+
+	server := &http.Server{Handler: traceMiddleware(mux)}
+	go server.Serve(listener)
+	return func() {
+		server.Close()
+		os.Remove(socketPath)
+	}, nil
+}
+
+// sendPreview registers a snippet with a running server, reporting false if none is listening
+func sendPreview(req previewRequest) (string, bool, error) {
+	socketPath := controlSocketPath()
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := client.Post("http://claudemd/previews", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", false, nil
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		URL     string   `json:"url"`
+		Error   string   `json:"error"`
+		Details []string `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", true, fmt.Errorf("invalid response from server: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", true, buildFailure(result.Error, result.Details)
+	}
+	return result.URL, true, nil
+}
+
+func buildFailure(message string, details []string) error {
+	fmt.Println("❌ Preview build failed:")
+	for _, d := range details {
+		fmt.Printf("   • %s\n", d)
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// previewCommand renders a TSX snippet from stdin or a file without saving it
+// into the project, reusing a running server when there is one
+func previewCommand(c *cli.Context) error {
+	var source []byte
+	var err error
+	switch {
+	case c.String("file") != "":
+		source, err = os.ReadFile(c.String("file"))
+	case c.Args().First() == "-" || c.NArg() == 0:
+		source, err = io.ReadAll(os.Stdin)
+	default:
+		return fmt.Errorf("usage: claudemd preview - | --file <path>")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+	if len(bytes.TrimSpace(source)) == 0 {
+		return fmt.Errorf("no source to preview")
+	}
+
+	if err := resolveBuildConfig(c); err != nil {
+		return err
+	}
+	req := previewRequest{Source: string(source), Component: c.String("component")}
+
+	url, reused, err := sendPreview(req)
+	if err != nil {
+		return err
+	}
+	if reused {
+		fmt.Printf("🔍 Preview ready on the running server: %s\n", url)
+		return openPreview(c, url)
+	}
+
+	// No server for this project; start a throwaway one on a free port
+	id, errs := previews.Add(req.Source, req.Component)
+	if errs != nil {
+		return buildFailure("build failed", errs)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:"+c.String("port"))
+	if err != nil {
+		return fmt.Errorf("failed to start preview server: %w", err)
+	}
+	server := &http.Server{Handler: createHTTPServer(&apiServer{})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	url = fmt.Sprintf("http://%s/render/__preview/%s", listener.Addr(), id)
+	fmt.Printf("🔍 Preview ready: %s\n", url)
+	fmt.Println("   Press Ctrl+C to stop the preview server")
+	if err := openPreview(c, url); err != nil {
+		log.Printf("Failed to open browser: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+	return nil
+}
+
+func openPreview(c *cli.Context, url string) error {
+	if c.Bool("no-open") {
+		return nil
+	}
+	return openBrowser(url)
+}
+
+// openBrowser opens a URL with the platform's default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
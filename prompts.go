@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// SessionPrompt is a prompt typed in a session and how often it was used there
+type SessionPrompt struct {
+	Hash        string
+	Text        string
+	Uses        int
+	FirstUsedAt *time.Time
+	LastUsedAt  *time.Time
+}
+
+// isInjectedText reports whether user message text was written by Claude
+// Code rather than typed: command wrappers, reminders, the local command
+// caveat and interruption notices
+func isInjectedText(text string) bool {
+	return strings.HasPrefix(text, "<") || strings.HasPrefix(text, "Caveat:") || strings.HasPrefix(text, "[Request interrupted")
+}
+
+// promptText returns the text the user typed in a message, or "" for
+// assistant messages, tool results and injected text
+func promptText(msg SessionMessage) string {
+	if messageRole(msg) != "user" {
+		return ""
+	}
+	var parts []string
+	for _, block := range messageBlocks(msg) {
+		text := strings.TrimSpace(block.Text)
+		if block.Type == "text" && text != "" && !isInjectedText(text) {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// promptHash identifies a prompt regardless of case and whitespace
+func promptHash(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// extractPrompts collects the distinct prompts of a session in order of
+// first use, counting repeats
+func extractPrompts(messages []SessionMessage) []SessionPrompt {
+	var prompts []*SessionPrompt
+	byHash := make(map[string]*SessionPrompt)
+	for _, msg := range messages {
+		text := promptText(msg)
+		if text == "" {
+			continue
+		}
+		hash := promptHash(text)
+		prompt, ok := byHash[hash]
+		if !ok {
+			prompt = &SessionPrompt{Hash: hash, Text: text}
+			byHash[hash] = prompt
+			prompts = append(prompts, prompt)
+		}
+		prompt.Uses++
+		if t, err := time.Parse(time.RFC3339Nano, msg.Timestamp); err == nil {
+			if prompt.FirstUsedAt == nil || t.Before(*prompt.FirstUsedAt) {
+				prompt.FirstUsedAt = &t
+			}
+			if prompt.LastUsedAt == nil || t.After(*prompt.LastUsedAt) {
+				prompt.LastUsedAt = &t
+			}
+		}
+	}
+
+	result := make([]SessionPrompt, len(prompts))
+	for i, prompt := range prompts {
+		result[i] = *prompt
+	}
+	return result
+}
+
+// promptRecorder is implemented by sinks that keep the prompt library
+type promptRecorder interface {
+	StorePrompts(sessionID string, prompts []SessionPrompt) error
+}
+
+// StorePrompts replaces the prompt uses of a session, adding prompts the
+// library has not seen yet
+// unusedPrompt matches rows p of prompts that no session uses any more
+const unusedPrompt = `NOT EXISTS (SELECT 1 FROM session_prompts sp WHERE sp.prompt_hash = p.hash)`
+
+func (p postgresSink) StorePrompts(sessionID string, prompts []SessionPrompt) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM session_prompts WHERE session_id = $1`, sessionID); err != nil {
+		return fmt.Errorf("failed to clear prompts: %w", err)
+	}
+	for _, prompt := range prompts {
+		if _, err := tx.Exec(`INSERT INTO prompts (hash, text) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING`,
+			prompt.Hash, prompt.Text); err != nil {
+			return fmt.Errorf("failed to insert prompt: %w", err)
+		}
+		_, err := tx.Exec(`
+			INSERT INTO session_prompts (session_id, prompt_hash, uses, first_used_at, last_used_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			sessionID, prompt.Hash, prompt.Uses, prompt.FirstUsedAt, prompt.LastUsedAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert prompt use: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// PromptUsage is a library prompt with its use across sessions
+type PromptUsage struct {
+	Hash        string     `json:"hash"`
+	Text        string     `json:"text"`
+	Uses        int        `json:"uses"`
+	Sessions    int        `json:"sessions"`
+	FirstUsedAt *time.Time `json:"first_used_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PromptFilter selects library prompts by the sessions that used them and
+// by their text
+type PromptFilter struct {
+	Sessions SessionFilter
+	// Search matches a case-insensitive substring of the prompt
+	Search string
+	// MinLength skips short prompts such as "yes" or "continue"
+	MinLength int
+}
+
+// promptCursor is the position of a prompt in the library, which is
+// ordered by uses, then hash
+type promptCursor struct {
+	Uses int
+	Hash string
+}
+
+// listPrompts returns the prompts used by matching sessions, most used
+// first, starting after the cursor when one is given
+func listPrompts(db *sql.DB, filter PromptFilter, after *promptCursor, limit int) ([]PromptUsage, error) {
+	where, args := filter.Sessions.where()
+	args = append(args, filter.Search, filter.MinLength)
+	search, minLength := len(args)-1, len(args)
+	cursor := "TRUE"
+	if after != nil {
+		args = append(args, after.Uses, after.Hash)
+		cursor = fmt.Sprintf("(t.uses, t.hash) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT t.hash, t.text, t.uses, t.sessions, t.first_used_at, t.last_used_at
+		FROM (
+			SELECT p.hash, p.text, sum(sp.uses) AS uses, count(*) AS sessions,
+			       min(sp.first_used_at) AS first_used_at, max(sp.last_used_at) AS last_used_at
+			FROM prompts p
+			JOIN session_prompts sp ON sp.prompt_hash = p.hash
+			WHERE sp.session_id IN (SELECT session_id FROM claude_sessions WHERE %s)
+			  AND ($%d = '' OR strpos(lower(p.text), lower($%d)) > 0)
+			  AND length(p.text) >= $%d
+			GROUP BY p.hash, p.text
+		) t
+		WHERE %s
+		ORDER BY t.uses DESC, t.hash DESC
+		LIMIT $%d`, where, search, search, minLength, cursor, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prompts: %w", err)
+	}
+	defer rows.Close()
+
+	prompts := []PromptUsage{}
+	for rows.Next() {
+		var prompt PromptUsage
+		if err := rows.Scan(&prompt.Hash, &prompt.Text, &prompt.Uses, &prompt.Sessions,
+			&prompt.FirstUsedAt, &prompt.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt: %w", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, rows.Err()
+}
+
+// handleListPrompts serves GET /api/prompts?search=&min_length=&limit=&cursor=,
+// which also accepts the filters of the session list. Sessions synced
+// before the prompt library was added are missing until synced again.
+func (a *apiServer) handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sessions, err := sessionFilterFromQuery(q)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &sessions); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	filter := PromptFilter{Sessions: sessions, Search: q.Get("search")}
+	if v := q.Get("min_length"); v != "" {
+		if filter.MinLength, err = strconv.Atoi(v); err != nil || filter.MinLength < 0 {
+			writeJSONError(w, r, http.StatusBadRequest, "min_length must be a non-negative integer", nil)
+			return
+		}
+	}
+	page, err := pageFromQuery(q, 50, 500)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var cursor promptCursor
+	var after *promptCursor
+	if ok, err := page.decode(&cursor.Uses, &cursor.Hash); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	} else if ok {
+		after = &cursor
+	}
+
+	prompts, err := listPrompts(a.db, filter, after, page.Limit+1)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPage(prompts, page, func(p PromptUsage) string {
+		return encodeCursor(p.Uses, p.Hash)
+	}))
+}
+
+// promptsCommand groups the prompt library subcommands
+func promptsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "prompts",
+		Usage: "Browse the prompts typed in synced sessions",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "top",
+				Usage: "List the most used prompts",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{Name: "search", Usage: "Only prompts containing this text"},
+					&cli.StringFlag{Name: "project", Usage: "Only prompts from sessions of this ~/.claude/projects directory"},
+					&cli.IntFlag{Name: "min-length", Usage: "Skip prompts shorter than this many characters"},
+					&cli.IntFlag{Name: "limit", Value: 20, Usage: "Maximum number of prompts to print"},
+				}, sessionOutputFlags()...),
+				Action: promptsTopCommand,
+			},
+		},
+	}
+}
+
+// promptsTopCommand implements `prompts top`
+func promptsTopCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	filter := PromptFilter{
+		Sessions:  SessionFilter{Project: c.String("project")},
+		Search:    c.String("search"),
+		MinLength: c.Int("min-length"),
+	}
+	prompts, err := listPrompts(db, filter, nil, c.Int("limit"))
+	if err != nil {
+		return err
+	}
+
+	header := []string{"USES", "SESSIONS", "LAST USED", "PROMPT"}
+	rows := make([][]string, len(prompts))
+	for i, p := range prompts {
+		lastUsed := "-"
+		if p.LastUsedAt != nil {
+			lastUsed = p.LastUsedAt.Local().Format("2006-01-02")
+		}
+		text := p.Text
+		if format == "table" {
+			text = truncateText(text, 80)
+		}
+		rows[i] = []string{strconv.Itoa(p.Uses), strconv.Itoa(p.Sessions), lastUsed, text}
+	}
+	return writeOutput(os.Stdout, format, prompts, header, rows)
+}
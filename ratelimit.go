@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// rateLimitIdle is how long an unused client bucket is kept
+const rateLimitIdle = 10 * time.Minute
+
+// tokenBucket tracks the request allowance of a single client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a per-client token bucket limiter
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// newRateLimiter allows rate requests per second with bursts up to burst.
+// A non-positive rate disables limiting.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = int(math.Ceil(rate))
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket), lastPrune: time.Now()}
+}
+
+// Allow takes a token for the client, returning the tokens left or how long to wait
+func (l *rateLimiter) Allow(key string) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > rateLimitIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// clientKey identifies the caller by bearer token when present, otherwise by IP
+func clientKey(r *http.Request) string {
+	if token, ok := bearerToken(r); ok {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	return "ip:" + clientIP(r)
+}
+
+// bearerToken extracts the token from an Authorization: Bearer header
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:]), true
+	}
+	return "", false
+}
+
+// clientIP returns the remote address without its port. Forwarding headers
+// are ignored because the server is not expected to sit behind a proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitMiddleware rejects API requests from clients over their allowance
+func rateLimitMiddleware(limiter *rateLimiter) Middleware {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ok, remaining, wait := limiter.Allow(clientKey(r))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeJSONError(w, r, http.StatusTooManyRequests, "Rate limit exceeded", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitFlags configure API rate limiting for commands that run the server
+func rateLimitFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.Float64Flag{
+			Name:  "rate-limit",
+			Value: 20,
+			Usage: "API requests per second allowed per client (0 disables)",
+		},
+		&cli.IntFlag{
+			Name:  "rate-burst",
+			Value: 60,
+			Usage: "API requests a client may burst above the rate limit",
+		},
+	}
+}
+
+// rateLimiterFromFlags builds the limiter configured on the command line
+func rateLimiterFromFlags(c *cli.Context) (*rateLimiter, error) {
+	if c.Float64("rate-limit") < 0 {
+		return nil, fmt.Errorf("--rate-limit must not be negative")
+	}
+	return newRateLimiter(c.Float64("rate-limit"), c.Int("rate-burst")), nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rawSources are where GET /api/sessions/{id}/raw can read a session from,
+// most faithful first
+var rawSources = []string{"file", "upload", "snapshot", "messages"}
+
+// rawSessionLine is a transcript line rebuilt from a stored message. Fields
+// sync derives, such as content and thinking, are left out.
+type rawSessionLine struct {
+	Type      string          `json:"type"`
+	SessionID string          `json:"sessionId,omitempty"`
+	UUID      string          `json:"uuid,omitempty"`
+	Timestamp string          `json:"timestamp,omitempty"`
+	Cwd       string          `json:"cwd,omitempty"`
+	Summary   string          `json:"summary,omitempty"`
+	LeafUUID  string          `json:"leafUuid,omitempty"`
+	Message   json.RawMessage `json:"message,omitempty"`
+}
+
+// reconstructJSONL writes the messages of a session back out as JSONL
+func reconstructJSONL(session *ClaudeSession) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, msg := range session.Messages {
+		line := rawSessionLine{Type: msg.Type, UUID: msg.UUID, Timestamp: msg.Timestamp, Cwd: msg.Cwd,
+			Summary: msg.Summary, LeafUUID: msg.LeafUUID, Message: msg.Message}
+		if msg.Type != "summary" {
+			line.SessionID = session.SessionID
+		}
+		if err := enc.Encode(line); err != nil {
+			return nil, fmt.Errorf("failed to encode message %s: %w", msg.UUID, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// readRawSession returns the session as read from source, or nil when that
+// source does not have it
+func (a *apiServer) readRawSession(session *ClaudeSession, source string) ([]byte, error) {
+	switch source {
+	case "file":
+		// Only transcripts under this machine's projects directory, or
+		// archived from it by du --archive, are served
+		sourceFile, _ := session.Metadata["source_file"].(string)
+		claudeDir, err := defaultClaudeDir()
+		if err != nil || sourceFile == "" {
+			return nil, nil
+		}
+		var data []byte
+		switch {
+		case strings.HasSuffix(sourceFile, ".jsonl.gz") && isWithinDir(filepath.Join(claudeDir, archiveDir), sourceFile):
+			data, err = readArchivedFile(sourceFile)
+		case strings.HasSuffix(sourceFile, ".jsonl") && isWithinDir(filepath.Join(claudeDir, "projects"), sourceFile):
+			data, err = os.ReadFile(sourceFile)
+		default:
+			return nil, nil
+		}
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return data, err
+	case "upload":
+		// The end of a line still being uploaded is left out
+		var content []byte
+		err := a.db.QueryRow(`SELECT content FROM session_uploads WHERE session_id = $1`, session.SessionID).Scan(&content)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return content, err
+	case "snapshot":
+		var content []byte
+		var checksum string
+		err := a.db.QueryRow(`SELECT content, sha256 FROM claude_session_raw WHERE session_id = $1`, session.SessionID).Scan(&content, &checksum)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return decompressSnapshot(content, checksum)
+	default:
+		if config := a.currentConfig(); config != nil {
+			store, err := newBlobStore(config.Blobs)
+			if err != nil {
+				return nil, err
+			}
+			rehydrateSession(session, store)
+		}
+		return reconstructJSONL(session)
+	}
+}
+
+// rawRedactor returns the redactor to apply to a session's original file,
+// which is kept as it was written, when sync would have redacted it. Uploads
+// are redacted before they are stored.
+func (a *apiServer) rawRedactor(session *ClaudeSession) (*Redactor, error) {
+	config := a.currentConfig()
+	if config == nil {
+		return nil, nil
+	}
+	redact := config.Redaction.Enabled || session.Metadata["redactions"] != nil
+	if !redact && session.Workspace != "" {
+		if ws, err := getWorkspace(a.db, session.Workspace); err == nil {
+			redact = ws.Settings.Redact
+		}
+	}
+	if !redact {
+		return nil, nil
+	}
+	return NewRedactor(config.Redaction)
+}
+
+// handleSessionRaw serves GET /api/sessions/{id}/raw[?source=] as a JSONL
+// download: the original transcript when this machine still has it, the
+// uploaded or snapshotted copy, or else lines rebuilt from the stored
+// messages. source picks one of file, upload, snapshot or messages, and the
+// X-Raw-Source header says which was used.
+func (a *apiServer) handleSessionRaw(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	sources := rawSources
+	if source := r.URL.Query().Get("source"); source != "" {
+		valid := false
+		for _, known := range rawSources {
+			valid = valid || source == known
+		}
+		if !valid {
+			writeJSONError(w, r, http.StatusBadRequest, fmt.Sprintf("source must be one of %s", strings.Join(rawSources, ", ")), nil)
+			return
+		}
+		sources = []string{source}
+	}
+
+	var data []byte
+	var source string
+	for _, source = range sources {
+		if data, err = a.readRawSession(session, source); err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to read session %s: %v", source, err), nil)
+			return
+		}
+		if data != nil {
+			break
+		}
+	}
+	if data == nil {
+		writeJSONError(w, r, http.StatusNotFound, fmt.Sprintf("Session has no %s", source), nil)
+		return
+	}
+	if source == "file" {
+		redactor, err := a.rawRedactor(session)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		data = redactor.RedactRaw(data)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", session.SessionID+".jsonl"))
+	w.Header().Set("X-Raw-Source", source)
+	w.Write(data)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// routeMux is where API routes are mounted
+type routeMux interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// readonlyHidden are GET routes a public server leaves out: the audit log,
+// which needs the admin token, and ingest status, which needs the ingest one
+var readonlyHidden = map[string]bool{
+	"GET /api/audit":                true,
+	"GET /api/ingest/sessions/{id}": true,
+}
+
+// readonlyRoutes mounts only the routes that cannot change anything. GraphQL
+// takes POST but has no mutations.
+type readonlyRoutes struct {
+	mux *http.ServeMux
+}
+
+func (m readonlyRoutes) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	method, _, _ := strings.Cut(pattern, " ")
+	if (method == "GET" && !readonlyHidden[pattern]) || pattern == "POST /api/graphql" {
+		m.mux.HandleFunc(pattern, handler)
+	}
+}
+
+// createReadonlyServer serves the embedded frontend and the read API and
+// nothing else, so a session archive can be published
+func createReadonlyServer(api *apiServer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet && r.Method != http.MethodHead:
+			writeJSONError(w, r, http.StatusMethodNotAllowed, "This server is read-only", nil)
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			// API routes left out must not fall through to the app page
+			writeJSONError(w, r, http.StatusNotFound, "Not found", nil)
+		default:
+			serveEmbedded(w, r)
+		}
+	})
+	api.registerRoutes(readonlyRoutes{mux: mux})
+
+	return chainMiddleware(mux,
+		recoveryMiddleware,
+		traceMiddleware,
+		loggingMiddleware,
+		rateLimitMiddleware(api.limiter),
+		gzipMiddleware,
+		instrumentMiddleware,
+	)
+}
+
+// serveReadonly runs serve --readonly. Nothing is compiled from the working
+// directory, so the frontend must have been embedded by build --embed.
+func serveReadonly(c *cli.Context, port string) error {
+	if err := enableEmbedded(); err != nil {
+		return fmt.Errorf("serve --readonly needs a prebuilt frontend: %w", err)
+	}
+	limiter, err := rateLimiterFromFlags(c)
+	if err != nil {
+		return err
+	}
+	db, config := openOptionalDatabase()
+	api := &apiServer{db: db, config: config, limiter: limiter, readonly: true}
+	if stopReload, err := watchConfig(c, config, api, nil); err != nil {
+		log.Printf("Config reload disabled: %v", err)
+	} else {
+		defer stopReload()
+	}
+
+	fmt.Printf("🚀 Claude.md Platform Server starting on http://localhost:%s\n", port)
+	fmt.Printf("🔒 Read-only mode: the embedded frontend and GET endpoints of the session API\n")
+	if db == nil {
+		fmt.Printf("⚠️  No database is configured, so the session API returns 503\n")
+	}
+	if config != nil && !config.RequireAPIKeys {
+		fmt.Printf("🌍 Sessions are readable by anyone who can reach this server; set require_api_keys to restrict them\n")
+	}
+	return http.ListenAndServe(":"+port, createReadonlyServer(api))
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// RedactionRule masks text matching Pattern in messages authored by Roles.
+// Roles may contain user, assistant, tool (tool results) and summary; an
+// empty list applies the rule to every message.
+type RedactionRule struct {
+	Name        string   `json:"name"`
+	Pattern     string   `json:"pattern"`
+	Replacement string   `json:"replacement,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+}
+
+// RedactionConfig configures the redaction pipeline applied before upsert
+type RedactionConfig struct {
+	Enabled bool `json:"enabled"`
+	// DisableBuiltins turns off the default secret and email rules
+	DisableBuiltins bool            `json:"disable_builtins,omitempty"`
+	Rules           []RedactionRule `json:"rules,omitempty"`
+}
+
+// builtinRedactionRules cover common credentials and personal data
+var builtinRedactionRules = []RedactionRule{
+	{Name: "aws_access_key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws_secret_key", Pattern: `(?i)aws_secret_access_key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`},
+	{Name: "github_token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}\b`},
+	{Name: "api_key", Pattern: `\bsk-(?:ant-)?[A-Za-z0-9_-]{20,}`},
+	{Name: "slack_token", Pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`},
+	{Name: "bearer_token", Pattern: `(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`},
+	{Name: "private_key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "email", Pattern: `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`},
+}
+
+type compiledRedactionRule struct {
+	RedactionRule
+	re    *regexp.Regexp
+	roles map[string]bool
+}
+
+// Redactor applies redaction rules to sessions before they leave the machine
+type Redactor struct {
+	rules []compiledRedactionRule
+}
+
+// NewRedactor compiles the configured rules, optionally with the builtin set
+func NewRedactor(config RedactionConfig) (*Redactor, error) {
+	var rules []RedactionRule
+	if !config.DisableBuiltins {
+		rules = append(rules, builtinRedactionRules...)
+	}
+	rules = append(rules, config.Rules...)
+
+	r := &Redactor{}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", rule.Name, err)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "[REDACTED:" + rule.Name + "]"
+		}
+		compiled := compiledRedactionRule{RedactionRule: rule, re: re}
+		if len(rule.Roles) > 0 {
+			compiled.roles = make(map[string]bool)
+			for _, role := range rule.Roles {
+				compiled.roles[role] = true
+			}
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// redactionRole classifies a message for role-scoped rules
+func redactionRole(msg SessionMessage) string {
+	if msg.Type == "summary" {
+		return "summary"
+	}
+	role := messageRole(msg)
+	if role == "user" {
+		for _, block := range messageBlocks(msg) {
+			if block.Type == "tool_result" {
+				return "tool"
+			}
+		}
+	}
+	return role
+}
+
+// Redact masks matches in every message of the session and records which
+// messages were touched in the session metadata
+func (r *Redactor) Redact(session *ClaudeSession) {
+	if r == nil || len(r.rules) == 0 {
+		return
+	}
+
+	type redactedMessage struct {
+		Index int            `json:"index"`
+		UUID  string         `json:"uuid,omitempty"`
+		Rules map[string]int `json:"rules"`
+	}
+	var redacted []redactedMessage
+	totals := make(map[string]int)
+
+	for i := range session.Messages {
+		msg := &session.Messages[i]
+		counts := r.redactMessage(msg)
+		if len(counts) == 0 {
+			continue
+		}
+		for name, n := range counts {
+			totals[name] += n
+		}
+		redacted = append(redacted, redactedMessage{Index: i, UUID: msg.UUID, Rules: counts})
+	}
+
+	for _, rule := range r.rules {
+		if rule.roles == nil || rule.roles["summary"] {
+			session.Title = rule.re.ReplaceAllLiteralString(session.Title, rule.Replacement)
+		}
+	}
+
+	if len(redacted) == 0 {
+		return
+	}
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]interface{})
+	}
+	session.Metadata["redactions"] = map[string]interface{}{
+		"messages": redacted,
+		"counts":   totals,
+	}
+}
+
+// redactMessage applies all matching rules to a message, returning per-rule match counts
+func (r *Redactor) redactMessage(msg *SessionMessage) map[string]int {
+	var counts map[string]int
+	role := ""
+
+	var rules []compiledRedactionRule
+	for _, rule := range r.rules {
+		if rule.roles != nil {
+			if role == "" {
+				role = redactionRole(*msg)
+			}
+			if !rule.roles[role] {
+				continue
+			}
+		}
+		rules = append(rules, rule)
+	}
+	// Redact structurally so replacements never break the JSON encoding.
+	// The raw bytes are not matched first: escaping can hide a match that
+	// the decoded strings contain.
+	rawCounts := map[string]int{}
+	if len(msg.Message) > 0 {
+		if redactedRaw, n, err := redactJSON(msg.Message, rules); err == nil {
+			msg.Message, rawCounts = redactedRaw, n
+		}
+	}
+
+	for _, rule := range rules {
+		n := rawCounts[rule.Name]
+		if matches := rule.re.FindAllStringIndex(msg.Content, -1); len(matches) > 0 {
+			n = max(n, len(matches))
+			msg.Content = rule.re.ReplaceAllLiteralString(msg.Content, rule.Replacement)
+		}
+		if rule.re.MatchString(msg.Thinking) {
+			msg.Thinking = rule.re.ReplaceAllLiteralString(msg.Thinking, rule.Replacement)
+			n = max(n, 1)
+		}
+		if rule.re.MatchString(msg.Summary) {
+			msg.Summary = rule.re.ReplaceAllLiteralString(msg.Summary, rule.Replacement)
+			n = max(n, 1)
+		}
+
+		if n > 0 {
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+			counts[rule.Name] += n
+		}
+	}
+	return counts
+}
+
+// redactJSON replaces rule matches inside every string value of a JSON
+// document, returning the matches per rule. A rule matching across an object
+// key and its string value, such as "AWS_SECRET_ACCESS_KEY": "...", replaces
+// the whole value. The document is returned as it was when nothing matched.
+func redactJSON(raw json.RawMessage, rules []compiledRedactionRule) (json.RawMessage, map[string]int, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return raw, nil, err
+	}
+
+	counts := make(map[string]int)
+	redactString := func(s string) string {
+		for _, rule := range rules {
+			if matches := rule.re.FindAllStringIndex(s, -1); len(matches) > 0 {
+				counts[rule.Name] += len(matches)
+				s = rule.re.ReplaceAllLiteralString(s, rule.Replacement)
+			}
+		}
+		return s
+	}
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case string:
+			return redactString(t)
+		case map[string]interface{}:
+			for k, child := range t {
+				child = walk(child)
+				if s, ok := child.(string); ok {
+					child = redactKeyValue(k, s, rules, counts)
+				}
+				t[k] = child
+			}
+			return t
+		case []interface{}:
+			for i := range t {
+				t[i] = walk(t[i])
+			}
+			return t
+		}
+		return v
+	}
+	value = walk(value)
+	if len(counts) == 0 {
+		return raw, counts, nil
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return raw, nil, err
+	}
+	return out, counts, nil
+}
+
+// redactKeyValue replaces the value of an object member when a rule matches
+// the member written out as "key": "value" across both its key and value
+func redactKeyValue(key, value string, rules []compiledRedactionRule, counts map[string]int) string {
+	prefix := `"` + key + `": "`
+	pair := prefix + value + `"`
+	for _, rule := range rules {
+		for _, loc := range rule.re.FindAllStringIndex(pair, -1) {
+			if loc[0] < len(prefix) && loc[1] > len(prefix) {
+				counts[rule.Name]++
+				return rule.Replacement
+			}
+		}
+	}
+	return value
+}
+
+// rewriteJSONStrings passes every string value of a JSON document through
+// rewrite. Object keys and numbers are kept as they are.
+func rewriteJSONStrings(raw json.RawMessage, rewrite func(string) string) (json.RawMessage, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch t := v.(type) {
+		case string:
+			return rewrite(t)
+		case map[string]interface{}:
+			for k, child := range t {
+				t[k] = walk(child)
+			}
+			return t
+		case []interface{}:
+			for i := range t {
+				t[i] = walk(t[i])
+			}
+			return t
+		}
+		return v
+	}
+	return json.Marshal(walk(value))
+}
+
+// RedactRaw applies the rules to each line of a raw JSONL file so snapshots
+// never contain unredacted content. Role scoping is ignored here to err on the
+// side of masking. Redacted lines are re-encoded, so redacted snapshots are no
+// longer byte-identical to the source file.
+func (r *Redactor) RedactRaw(data []byte) []byte {
+	if r == nil || len(r.rules) == 0 {
+		return data
+	}
+
+	lines := bytes.Split(data, []byte{'\n'})
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) > 0 && json.Valid(line) {
+			// JSON lines are only ever redacted structurally, so they stay valid
+			if redacted, _, err := redactJSON(line, r.rules); err == nil {
+				lines[i] = redacted
+			}
+			continue
+		}
+		// Not a JSON line; mask the text directly
+		for _, rule := range r.rules {
+			line = rule.re.ReplaceAllLiteral(line, []byte(rule.Replacement))
+		}
+		lines[i] = line
+	}
+	return bytes.Join(lines, []byte{'\n'})
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+// localConfigDir holds ignored/config.json, the config read by LoadConfig
+const localConfigDir = "ignored"
+
+// configReloadDelay lets an editor finish writing a config file before it is read
+const configReloadDelay = 250 * time.Millisecond
+
+// syncSettings are the sync settings a config reload can replace
+type syncSettings struct {
+	filter        *PathFilter
+	redactor      *Redactor
+	titler        *Titler
+	plugins       pluginChain
+	thinking      string
+	conflicts     ConflictConfig
+	sourceDeletes string
+	workspace     string
+}
+
+// settings returns the sync settings in effect
+func (c *ClaudeSessionSync) settings() syncSettings {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return syncSettings{
+		filter:        c.filter,
+		redactor:      c.redactor,
+		titler:        c.titler,
+		plugins:       c.plugins,
+		thinking:      c.thinking,
+		conflicts:     c.conflicts,
+		sourceDeletes: c.sourceDeletes,
+		workspace:     c.workspace,
+	}
+}
+
+// pathFilter returns the filter in effect
+func (c *ClaudeSessionSync) pathFilter() *PathFilter {
+	return c.settings().filter
+}
+
+// applySettings replaces the reloadable settings with those of next. When
+// the patterns changed, the watcher picks up projects they now include.
+func (c *ClaudeSessionSync) applySettings(next *ClaudeSessionSync, patternsChanged bool) {
+	c.settingsMu.Lock()
+	c.filter = next.filter
+	c.redactor = next.redactor
+	c.titler = next.titler
+	c.plugins = next.plugins
+	c.thinking = next.thinking
+	c.conflicts = next.conflicts
+	c.sourceDeletes = next.sourceDeletes
+	c.workspace = next.workspace
+	c.settingsMu.Unlock()
+
+	if patternsChanged {
+		select {
+		case c.reloaded <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// configReloader applies edits to ignored/config.json and claudemd.config.json
+// to a running serve, daemon or sync. Settings read for each request or
+// synced file change in place; the database connection, blob storage and
+// server port are fixed at startup and need a restart.
+type configReloader struct {
+	c *cli.Context
+	// config is nil when serve runs without ignored/config.json
+	config  *Config
+	project *ProjectConfig
+	api     *apiServer
+	sync    *ClaudeSessionSync
+}
+
+// watchConfig reloads the config files whenever they change. api or sync is
+// nil for commands that run only one of them. The returned func stops watching.
+func watchConfig(c *cli.Context, config *Config, api *apiServer, sync *ClaudeSessionSync) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	// Editors replace files rather than write them, so the directories are
+	// watched instead of the files
+	if err := watcher.Add("."); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", projectConfigFile, err)
+	}
+	if info, err := os.Stat(localConfigDir); err == nil && info.IsDir() {
+		if err := watcher.Add(localConfigDir); err != nil {
+			log.Printf("Failed to watch %s: %v", localConfigDir, err)
+		}
+	}
+
+	r := &configReloader{c: c, config: config, project: workspace, api: api, sync: sync}
+	go r.run(watcher)
+	return func() { watcher.Close() }, nil
+}
+
+// run reloads the config a moment after the last change to either file
+func (r *configReloader) run(watcher *fsnotify.Watcher) {
+	localConfig := filepath.Join(localConfigDir, "config.json")
+	var pending <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(event.Name)
+			if name == localConfigDir && event.Op&fsnotify.Create == fsnotify.Create {
+				if err := watcher.Add(localConfigDir); err != nil {
+					log.Printf("Failed to watch %s: %v", localConfigDir, err)
+				}
+				continue
+			}
+			if name == projectConfigFile || name == localConfig {
+				pending = time.After(configReloadDelay)
+			}
+
+		case <-pending:
+			pending = nil
+			r.reload()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Config watcher error: %v", err)
+		}
+	}
+}
+
+// reload reads both config files and applies what changed. Invalid files
+// leave the previous settings in place.
+func (r *configReloader) reload() {
+	project, err := loadProjectConfig()
+	if err != nil {
+		log.Printf("Config reload failed, keeping the previous settings: %v", err)
+		return
+	}
+	config := r.config
+	if r.config != nil {
+		if config, err = LoadConfig(); err != nil {
+			log.Printf("Config reload failed, keeping the previous settings: %v", err)
+			return
+		}
+	}
+
+	changed, restart := configChanges(r.project, project, r.config, config)
+	if len(changed) == 0 && len(restart) == 0 {
+		return
+	}
+	if config != nil {
+		// Keep the settings a restart applies, so the running server keeps
+		// reading blobs from the store sync writes them to
+		config.DatabaseURL = r.config.DatabaseURL
+		config.Supabase = r.config.Supabase
+		config.Blobs = r.config.Blobs
+		config.SnapshotRaw = r.config.SnapshotRaw
+	}
+
+	previous := workspace
+	workspace = project
+	if err := r.apply(config, patternsChanged(r.project, project, r.config, config)); err != nil {
+		workspace = previous
+		log.Printf("Config reload failed, keeping the previous settings: %v", err)
+		return
+	}
+	r.project, r.config = project, config
+
+	if len(changed) > 0 {
+		log.Printf("Config reloaded: %s changed", strings.Join(changed, ", "))
+	}
+	if len(restart) > 0 {
+		log.Printf("Config changes to %s take effect after a restart", strings.Join(restart, ", "))
+	}
+}
+
+// apply swaps in the reloaded settings once all of them are known to be valid
+func (r *configReloader) apply(config *Config, patternsChanged bool) error {
+	var next *ClaudeSessionSync
+	if r.sync != nil {
+		var err error
+		if next, err = newConfiguredSync(r.c, config, r.sync.db); err != nil {
+			return err
+		}
+	}
+	if err := resolveBuildConfig(r.c); err != nil {
+		return err
+	}
+	if next != nil {
+		r.sync.applySettings(next, patternsChanged)
+	}
+	if r.api != nil {
+		r.api.configMu.Lock()
+		r.api.config = config
+		r.api.configMu.Unlock()
+	}
+	return nil
+}
+
+// patternsChanged reports whether the sync include or ignore patterns changed
+func patternsChanged(oldProject, newProject *ProjectConfig, oldConfig, newConfig *Config) bool {
+	if !reflect.DeepEqual(oldProject.Sync.Include, newProject.Sync.Include) ||
+		!reflect.DeepEqual(oldProject.Sync.Ignore, newProject.Sync.Ignore) {
+		return true
+	}
+	return oldConfig != nil && (!reflect.DeepEqual(oldConfig.Include, newConfig.Include) ||
+		!reflect.DeepEqual(oldConfig.Exclude, newConfig.Exclude))
+}
+
+// configChanges names the settings that differ between two loads of the
+// config files, split into those applied now and those needing a restart.
+// Token values are never logged.
+func configChanges(oldProject, newProject *ProjectConfig, oldConfig, newConfig *Config) (changed, restart []string) {
+	note := func(differs bool, list *[]string, name string) {
+		if differs {
+			*list = append(*list, name)
+		}
+	}
+
+	note(patternsChanged(oldProject, newProject, oldConfig, newConfig), &changed, "ignore patterns")
+	note(oldProject.Sync.Redact != newProject.Sync.Redact, &changed, "sync.redact")
+	note(oldProject.Sync.Workspace != newProject.Sync.Workspace, &changed, "sync.workspace")
+	importMapChanged := !reflect.DeepEqual(oldProject.Build.ImportMap, newProject.Build.ImportMap)
+	if offline != nil {
+		// Offline pages use the import map of the packages vendored at startup
+		note(importMapChanged, &restart, "import map (offline mode)")
+	} else {
+		note(importMapChanged, &changed, "import map")
+	}
+	oldBuild, newBuild := oldProject.Build, newProject.Build
+	oldBuild.ImportMap, newBuild.ImportMap = nil, nil
+	note(!reflect.DeepEqual(oldBuild, newBuild), &changed, "build options")
+	note(oldProject.Server.Port != newProject.Server.Port, &restart, "server.port")
+	note(oldProject.Server.Cache != newProject.Server.Cache, &restart, "server.cache")
+	note(oldProject.Server.Typecheck != newProject.Server.Typecheck, &restart, "server.typecheck")
+	note(oldProject.Sync.SnapshotRaw != newProject.Sync.SnapshotRaw, &restart, "sync.snapshot_raw")
+	note(oldProject.Sync.SnapshotContext != newProject.Sync.SnapshotContext, &restart, "sync.snapshot_context")
+	note(!reflect.DeepEqual(oldProject.Sync.ContextFiles, newProject.Sync.ContextFiles), &restart, "sync.context_files")
+	note(!reflect.DeepEqual(oldProject.Sync.ExtraSources, newProject.Sync.ExtraSources), &restart, "sync.extra_sources")
+
+	if oldConfig == nil {
+		return changed, restart
+	}
+	note(oldConfig.AdminToken != newConfig.AdminToken, &changed, "admin_token")
+	note(oldConfig.IngestToken != newConfig.IngestToken, &changed, "ingest_token")
+	note(!reflect.DeepEqual(oldConfig.Redaction, newConfig.Redaction), &changed, "redaction")
+	note(!reflect.DeepEqual(oldConfig.Titles, newConfig.Titles), &changed, "titles")
+	note(!reflect.DeepEqual(oldConfig.Plugins, newConfig.Plugins), &changed, "plugins")
+	note(oldConfig.Outlines != newConfig.Outlines, &changed, "outlines")
+	note(oldConfig.Thinking != newConfig.Thinking, &changed, "thinking")
+	note(!reflect.DeepEqual(oldConfig.Conflicts, newConfig.Conflicts), &changed, "conflicts")
+	note(oldConfig.SourceDeletes != newConfig.SourceDeletes, &changed, "source_deletes")
+	note(oldConfig.RequireAPIKeys != newConfig.RequireAPIKeys, &changed, "require_api_keys")
+	note(oldConfig.Workspace != newConfig.Workspace, &changed, "workspace")
+	note(oldConfig.DatabaseURL != newConfig.DatabaseURL, &restart, "database_url")
+	note(!reflect.DeepEqual(oldConfig.Supabase, newConfig.Supabase), &restart, "supabase")
+	note(!reflect.DeepEqual(oldConfig.Blobs, newConfig.Blobs), &restart, "blobs")
+	note(oldConfig.Database != newConfig.Database, &restart, "database")
+	note(oldConfig.SnapshotRaw != newConfig.SnapshotRaw, &restart, "snapshot_raw")
+	note(!reflect.DeepEqual(oldConfig.Sinks, newConfig.Sinks), &restart, "sinks")
+	return changed, restart
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Policies for sessions whose file was deleted or renamed away, set with
+// "source_deletes" in ignored/config.json
+const (
+	// sourceDeleteMark records source_deleted_at in the session metadata
+	sourceDeleteMark = "mark"
+	// sourceDeleteSoft also soft deletes the session
+	sourceDeleteSoft = "delete"
+)
+
+// validateSourceDeletes checks a configured source delete policy
+func validateSourceDeletes(policy string) error {
+	switch policy {
+	case "", sourceDeleteMark, sourceDeleteSoft:
+		return nil
+	}
+	return fmt.Errorf("unknown source_deletes policy %q (valid: mark, delete)", policy)
+}
+
+// syncRemovedFile applies the source delete policy to the session of a file
+// that was removed or renamed away
+func (c *ClaudeSessionSync) syncRemovedFile(filePath string) error {
+	delete(c.syncedFiles, filePath)
+
+	sessionID := strings.TrimSuffix(filepath.Base(filePath), ".jsonl")
+	if _, err := findSessionFile(c.claudeDir, sessionID); err == nil {
+		// The file moved to another project and is synced from there
+		return nil
+	}
+	softDelete := c.settings().sourceDeletes == sourceDeleteSoft
+	c.mirrors.enqueueSourceRemoved(sessionID, filePath, softDelete)
+	found, err := c.sink.MarkSourceRemoved(sessionID, filePath, softDelete)
+	if err != nil {
+		return fmt.Errorf("failed to mark %s removed: %w", sessionID, err)
+	}
+	if !found {
+		return nil
+	}
+	if softDelete {
+		log.Printf("Session file %s was removed, deleted session %s", filePath, sessionID)
+	} else {
+		log.Printf("Session file %s was removed, marked session %s", filePath, sessionID)
+	}
+	return nil
+}
+
+// MarkSourceRemoved records that the file a session was synced from is gone,
+// soft deleting the session when asked. Sessions synced from another path
+// are left alone. It reports whether a session was updated.
+func (p postgresSink) MarkSourceRemoved(sessionID, sourceFile string, softDelete bool) (bool, error) {
+	result, err := p.db.Exec(`
+		UPDATE claude_sessions
+		SET metadata = COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('source_deleted_at', $3::text),
+		    deleted_at = CASE WHEN $4 THEN COALESCE(deleted_at, NOW()) ELSE deleted_at END,
+		    updated_at = NOW()
+		WHERE session_id = $1 AND metadata->>'source_file' = $2`,
+		sessionID, sourceFile, time.Now().Format(time.RFC3339), softDelete)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// reextractMessage runs content extraction again over a stored message,
+// rebuilding the transcript line it was parsed from
+func reextractMessage(msg SessionMessage) (SessionMessage, error) {
+	line, err := json.Marshal(rawSessionLine{Type: msg.Type, UUID: msg.UUID, Timestamp: msg.Timestamp, Cwd: msg.Cwd,
+		Summary: msg.Summary, LeafUUID: msg.LeafUUID, Message: msg.Message})
+	if err != nil {
+		return msg, err
+	}
+	fresh, err := parseSessionLine(line)
+	if err != nil {
+		return msg, err
+	}
+	msg.Content = fresh.Content
+	msg.Truncated = fresh.Truncated
+	// Thinking stripped from the message by the storage mode is gone for good
+	if fresh.Thinking != "" {
+		msg.Thinking = fresh.Thinking
+	}
+	return msg, nil
+}
+
+// reprocessor re-derives the content and analytics of stored sessions
+type reprocessor struct {
+	db        *sql.DB
+	store     blobStore
+	offloader *blobOffloader
+	redactor  *Redactor
+	thinking  string
+	dryRun    bool
+}
+
+// reprocessSession brings one session up to date, reporting whether it
+// changed and how many messages got new content
+func (p *reprocessor) reprocessSession(id string) (bool, int, error) {
+	session, err := loadSession(p.db, id)
+	if err != nil {
+		return false, 0, err
+	}
+	beforeMessages, _ := json.Marshal(session.Messages)
+	beforeMetadata, _ := json.Marshal(session.Metadata)
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]interface{})
+	}
+
+	rehydrateSession(session, p.store)
+	contents := make([]string, len(session.Messages))
+	for i, msg := range session.Messages {
+		contents[i] = msg.Content
+		if session.Messages[i], err = reextractMessage(msg); err != nil {
+			return false, 0, fmt.Errorf("failed to re-extract message %d of %s: %w", i, id, err)
+		}
+	}
+	applyThinkingMode(session, p.thinking)
+	p.redactor.Redact(session)
+	for key, enrich := range messageEnrichers {
+		delete(session.Metadata, key)
+		enrich(session, "")
+	}
+	enrichExtractorVersion(session, "")
+	if err := p.offloader.Offload(session); err != nil {
+		return false, 0, fmt.Errorf("failed to offload tool results of %s: %w", id, err)
+	}
+
+	messagesJSON, err := json.Marshal(session.Messages)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to marshal messages: %w", err)
+	}
+	metadataJSON, err := json.Marshal(session.Metadata)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if bytes.Equal(messagesJSON, beforeMessages) && bytes.Equal(metadataJSON, beforeMetadata) {
+		return false, 0, nil
+	}
+	changed := 0
+	for i, msg := range session.Messages {
+		if msg.Content != contents[i] {
+			changed++
+		}
+	}
+	if p.dryRun {
+		return true, changed, nil
+	}
+
+	// updated_at is bumped so /api/sessions/changes reports the new content.
+	// Reprocessed sessions move to the top of the list sorted by update
+	// time; sort by created time to keep the order of the conversations.
+	times := sessionTimes(session.Messages)
+	_, err = p.db.Exec(`
+		UPDATE claude_sessions
+		SET messages = $2, metadata = $3, started_at = $4, ended_at = $5, duration_ms = $6, updated_at = NOW()
+		WHERE session_id = $1`, id, string(messagesJSON), string(metadataJSON), times.StartedAt, times.EndedAt, times.DurationMS)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to update session %s: %w", id, err)
+	}
+	storeDerivedData(postgresSink{db: p.db}, session)
+	return true, changed, nil
+}
+
+// reprocessCommand re-runs message extraction and the analytics derived
+// from messages over stored sessions, in batches ordered by session ID.
+// Sessions already at the current extractor version are skipped unless --all
+// is given, so an interrupted run picks up where it stopped; --resume does
+// the same for --all.
+func reprocessCommand(c *cli.Context) error {
+	if c.Int("batch") <= 0 {
+		return fmt.Errorf("--batch must be positive")
+	}
+	filter, err := sessionFilterFromFlags(c)
+	if err != nil {
+		return err
+	}
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	p := &reprocessor{db: db, thinking: config.Thinking, dryRun: c.Bool("dry-run")}
+	if p.store, err = newBlobStore(config.Blobs); err != nil {
+		return err
+	}
+	if p.offloader, err = newBlobOffloader(config.Blobs); err != nil {
+		return err
+	}
+	if config.Redaction.Enabled {
+		if p.redactor, err = NewRedactor(config.Redaction); err != nil {
+			return err
+		}
+	}
+
+	where, args := filter.where()
+	if !c.Bool("all") {
+		args = append(args, extractorVersion)
+		where += fmt.Sprintf(" AND COALESCE(metadata->>'extractor_version', '0')::int < $%d", len(args))
+	}
+	cursor := c.String("resume")
+	var total int
+	if err := db.QueryRow(`SELECT count(*) FROM claude_sessions WHERE session_id > $`+fmt.Sprint(len(args)+1)+` AND `+where,
+		append(args, cursor)...).Scan(&total); err != nil {
+		return fmt.Errorf("failed to count sessions: %w", err)
+	}
+	if total == 0 {
+		fmt.Fprintf(os.Stderr, "✅ Every session is at extractor version %d\n", extractorVersion)
+		return nil
+	}
+	verb := "Reprocessing"
+	if p.dryRun {
+		verb = "Checking"
+	}
+	fmt.Fprintf(os.Stderr, "🔁 %s %d session(s) with extractor version %d...\n", verb, total, extractorVersion)
+
+	done, updated, messages := 0, 0, 0
+	query := fmt.Sprintf(`SELECT session_id FROM claude_sessions WHERE session_id > $%d AND %s ORDER BY session_id LIMIT $%d`,
+		len(args)+1, where, len(args)+2)
+	for {
+		ids, err := querySessionIDs(db, query, append(args, cursor, c.Int("batch"))...)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		for _, id := range ids {
+			changed, n, err := p.reprocessSession(id)
+			if err != nil {
+				// Without --all, finished sessions are skipped on the next run anyway
+				if c.Bool("all") && cursor != "" {
+					fmt.Fprintf(os.Stderr, "⚠️  Stopped after %d session(s); continue with --resume %s\n", done, cursor)
+				}
+				return err
+			}
+			done++
+			messages += n
+			if changed {
+				updated++
+			}
+			cursor = id
+		}
+		fmt.Fprintf(os.Stderr, "   %d/%d (%d%%), %d changed\n", done, total, done*100/total, updated)
+	}
+
+	if p.dryRun {
+		fmt.Fprintf(os.Stderr, "🔍 %d of %d session(s) would change, %d message(s) with new content\n", updated, done, messages)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "✅ Reprocessed %d session(s): %d updated, %d message(s) with new content\n", done, updated, messages)
+	return nil
+}
+
+// querySessionIDs runs a query selecting session IDs
+func querySessionIDs(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// restartReadyEnv names the descriptor a re-executed server writes to once it
+// is listening, telling the old one to hand over
+const restartReadyEnv = "CLAUDEMD_RESTART_READY_FD"
+
+const (
+	// restartStartTimeout bounds how long the new server may take to listen
+	restartStartTimeout = 60 * time.Second
+	// restartDrainTimeout bounds how long in-flight requests may take to
+	// finish before their connections are closed
+	restartDrainTimeout = 30 * time.Second
+)
+
+var (
+	// streamsDraining is closed when the server starts draining
+	streamsDraining = make(chan struct{})
+	drainOnce       sync.Once
+)
+
+// listenServer listens on the port of the server. The socket can be shared
+// with a restarted copy of the server, so outside of a restart it first
+// checks that nothing else is serving on the port.
+func listenServer(port string) (net.Listener, error) {
+	if os.Getenv(restartReadyEnv) == "" {
+		if conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, 300*time.Millisecond); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("port %s is already in use; send SIGHUP to the running server to restart it", port)
+		}
+	}
+	config := net.ListenConfig{Control: reusePort}
+	listener, err := config.Listen(context.Background(), "tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %s: %w", port, err)
+	}
+	return listener, nil
+}
+
+// notifyRestartReady tells the server that re-executed this one that it is
+// listening
+func notifyRestartReady() {
+	value := os.Getenv(restartReadyEnv)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(restartReadyEnv)
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q", restartReadyEnv, value)
+		return
+	}
+	ready := os.NewFile(uintptr(fd), "restart-ready")
+	ready.Write([]byte("ready\n"))
+	ready.Close()
+}
+
+// streamDrainMiddleware ends event streams when the server drains, which
+// would otherwise hold it open until the drain times out. Browsers reconnect
+// EventSource streams by themselves, reaching the new server.
+func streamDrainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-streamsDraining:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		select {
+		case <-streamsDraining:
+			if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+				// Reconnect sooner than the browser default of a few seconds
+				fmt.Fprint(w, "retry: 500\n\n")
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+			}
+		default:
+		}
+	})
+}
+
+// gracefulServer serves until SIGHUP, then starts a new copy of the process
+// that re-reads the config, and once it listens on the shared port, stops
+// accepting connections and finishes the requests in flight
+type gracefulServer struct {
+	server   *http.Server
+	listener net.Listener
+	// release frees what the new server has to own, such as the control
+	// socket, before it starts; reacquire takes it back if it fails to start
+	release, reacquire func()
+}
+
+// Serve runs the server until it fails or hands over to a restarted one
+func (g *gracefulServer) Serve() error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- g.server.Serve(g.listener)
+	}()
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case <-hangups:
+			pid, err := g.restart()
+			if err != nil {
+				log.Printf("Restart failed, still serving: %v", err)
+				continue
+			}
+			log.Printf("Restarted as pid %d, finishing in-flight requests", pid)
+			g.drain()
+			return nil
+		}
+	}
+}
+
+// restart starts a new copy of the process and waits until it listens,
+// returning its pid
+func (g *gracefulServer) restart() (int, error) {
+	if !canReusePort {
+		return 0, fmt.Errorf("graceful restart needs SO_REUSEPORT, which this platform lacks")
+	}
+	// A config the new server cannot start with keeps this one running
+	if _, err := loadProjectConfig(); err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(filepath.Join(localConfigDir, "config.json")); err == nil {
+		if _, err := LoadConfig(); err != nil {
+			return 0, fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find executable: %w", err)
+	}
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer ready.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// ExtraFiles start at descriptor 3
+	cmd.ExtraFiles = []*os.File{readyWriter}
+	cmd.Env = append(os.Environ(), restartReadyEnv+"=3")
+
+	log.Printf("Restarting %s", executable)
+	g.release()
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		g.reacquire()
+		return 0, fmt.Errorf("failed to start %s: %w", executable, err)
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		line := make([]byte, 6)
+		if _, err := io.ReadFull(ready, line); err != nil {
+			started <- fmt.Errorf("new server exited before it was ready")
+			return
+		}
+		started <- nil
+	}()
+	select {
+	case err = <-started:
+	case <-time.After(restartStartTimeout):
+		err = fmt.Errorf("new server was not ready within %s", restartStartTimeout)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		g.reacquire()
+		return 0, err
+	}
+	// The new server outlives this one, which does not wait for it
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+// drain stops accepting connections, ends event streams and waits for the
+// remaining requests, such as builds, to finish
+func (g *gracefulServer) drain() {
+	drainOnce.Do(func() { close(streamsDraining) })
+	ctx, cancel := context.WithTimeout(context.Background(), restartDrainTimeout)
+	defer cancel()
+	if err := g.server.Shutdown(ctx); err != nil {
+		log.Printf("Closing connections still open after %s: %v", restartDrainTimeout, err)
+		g.server.Close()
+	}
+}
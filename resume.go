@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"time"
+)
+
+// maxPartialRetries bounds how often a file with an unfinished last line is re-read
+// without a new filesystem event
+const maxPartialRetries = 6
+
+// partialRetryDelay is the first retry delay, doubled on every attempt
+const partialRetryDelay = 250 * time.Millisecond
+
+// fileSyncState remembers what was read from a session file on its last sync
+type fileSyncState struct {
+	modTime time.Time
+	size    int64
+	// offset is the end of the last complete, successfully parsed line
+	offset int64
+	// partial is set when the file ended in a line Claude Code was still writing
+	partial  bool
+	attempts int
+}
+
+// unchanged reports whether the file still has the size and mtime seen on the last sync.
+// Size is compared as well because mtime granularity can hide a completed write.
+func (s *fileSyncState) unchanged(modTime time.Time, size int64) bool {
+	return s != nil && s.modTime.Equal(modTime) && s.size == size
+}
+
+// splitCompleteLines separates newline-terminated lines from a trailing line
+// that may still be mid-write
+func splitCompleteLines(data []byte) (complete, partial []byte) {
+	i := bytes.LastIndexByte(data, '\n')
+	return data[:i+1], data[i+1:]
+}
+
+// scheduleRetry re-queues a file whose last line was incomplete, backing off
+// between attempts. Retries only run while the watcher loop is active.
+func (c *ClaudeSessionSync) scheduleRetry(filePath string, state *fileSyncState) {
+	if c.retries == nil || state.attempts >= maxPartialRetries {
+		return
+	}
+	delay := partialRetryDelay << state.attempts
+	state.attempts++
+
+	time.AfterFunc(delay, func() {
+		select {
+		case c.retries <- filePath:
+		default:
+			log.Printf("Retry queue full, waiting for next change to %s", filePath)
+		}
+	})
+}
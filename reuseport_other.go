@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "syscall"
+
+// reusePort is a no-op where SO_REUSEPORT is not available
+func reusePort(network, address string, conn syscall.RawConn) error {
+	return nil
+}
+
+// canReusePort reports whether graceful restarts can hand over the port
+const canReusePort = false
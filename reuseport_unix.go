@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePort sets SO_REUSEPORT on a listening socket, so a restarted server
+// can bind the port while the old one finishes its requests
+func reusePort(network, address string, conn syscall.RawConn) error {
+	var opErr error
+	err := conn.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
+
+// canReusePort reports whether graceful restarts can hand over the port
+const canReusePort = true
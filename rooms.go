@@ -0,0 +1,263 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Room event types sent on GET /api/sessions/{id}/room
+const (
+	roomEventState   = "state"
+	roomEventJoin    = "join"
+	roomEventLeave   = "leave"
+	roomEventPointer = "pointer"
+)
+
+// viewerColors are handed out to viewers in the order they join a room
+var viewerColors = []string{"#2563eb", "#db2777", "#16a34a", "#d97706", "#7c3aed", "#0891b2", "#dc2626", "#4b5563"}
+
+// maxSelectionText caps the selected text relayed with a pointer
+const maxSelectionText = 500
+
+// ViewerSelection is text a viewer has selected within one message
+type ViewerSelection struct {
+	Message int    `json:"message"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Text    string `json:"text,omitempty"`
+}
+
+// ViewerPointer is where a viewer is in the transcript: the message at the
+// top of their viewport, how far down the page they are, and any selection
+type ViewerPointer struct {
+	Message     int              `json:"message"`
+	MessageUUID string           `json:"message_uuid,omitempty"`
+	Scroll      float64          `json:"scroll"`
+	Selection   *ViewerSelection `json:"selection,omitempty"`
+}
+
+// RoomViewer is someone viewing a session
+type RoomViewer struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Color     string         `json:"color"`
+	JoinedAt  time.Time      `json:"joined_at"`
+	Pointer   *ViewerPointer `json:"pointer,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// RoomEvent is sent to the viewers of a session. State events list every
+// viewer and which one is the receiver; the others carry the viewer they
+// are about.
+type RoomEvent struct {
+	Type    string       `json:"type"`
+	You     string       `json:"you,omitempty"`
+	Viewer  *RoomViewer  `json:"viewer,omitempty"`
+	Viewers []RoomViewer `json:"viewers,omitempty"`
+}
+
+// roomMember is a viewer with the stream their events go to
+type roomMember struct {
+	RoomViewer
+	events chan RoomEvent
+}
+
+// roomHub tracks who is viewing each session. Rooms exist while anyone is
+// in them and live in memory, so viewers only see each other when they are
+// connected to the same server.
+type roomHub struct {
+	mu    sync.Mutex
+	rooms map[string]map[string]*roomMember
+	// joins counts joins per room to pick the next color
+	joins map[string]int
+}
+
+var viewerRooms = &roomHub{rooms: make(map[string]map[string]*roomMember), joins: make(map[string]int)}
+
+// Join adds a viewer to the room of a session, returning them and everyone
+// already there
+func (h *roomHub) Join(sessionID, name string) (*roomMember, []RoomViewer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room := h.rooms[sessionID]
+	if room == nil {
+		room = make(map[string]*roomMember)
+		h.rooms[sessionID] = room
+	}
+	now := time.Now()
+	member := &roomMember{
+		RoomViewer: RoomViewer{ID: uuid.NewString(), Name: name, Color: viewerColors[h.joins[sessionID]%len(viewerColors)],
+			JoinedAt: now, UpdatedAt: now},
+		events: make(chan RoomEvent, 64),
+	}
+	h.joins[sessionID]++
+	if member.Name == "" {
+		member.Name = fmt.Sprintf("Viewer %d", h.joins[sessionID])
+	}
+	viewer := member.RoomViewer
+	h.broadcast(room, RoomEvent{Type: roomEventJoin, Viewer: &viewer}, "")
+	room[member.ID] = member
+	return member, h.viewers(room)
+}
+
+// Leave removes a viewer, closing the room when they were the last one
+func (h *roomHub) Leave(sessionID, viewerID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room := h.rooms[sessionID]
+	member := room[viewerID]
+	if member == nil {
+		return
+	}
+	delete(room, viewerID)
+	if len(room) == 0 {
+		delete(h.rooms, sessionID)
+		delete(h.joins, sessionID)
+		return
+	}
+	viewer := member.RoomViewer
+	h.broadcast(room, RoomEvent{Type: roomEventLeave, Viewer: &viewer}, "")
+}
+
+// Move records where a viewer is and tells the others, failing if the
+// viewer is not in the room
+func (h *roomHub) Move(sessionID, viewerID string, pointer ViewerPointer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	member := h.rooms[sessionID][viewerID]
+	if member == nil {
+		return fmt.Errorf("viewer %s is not in the room of %s", viewerID, sessionID)
+	}
+	member.Pointer = &pointer
+	member.UpdatedAt = time.Now()
+	viewer := member.RoomViewer
+	h.broadcast(h.rooms[sessionID], RoomEvent{Type: roomEventPointer, Viewer: &viewer}, viewerID)
+	return nil
+}
+
+// viewers lists the members of a room in the order they joined
+func (h *roomHub) viewers(room map[string]*roomMember) []RoomViewer {
+	viewers := make([]RoomViewer, 0, len(room))
+	for _, member := range room {
+		viewers = append(viewers, member.RoomViewer)
+	}
+	sort.Slice(viewers, func(i, j int) bool { return viewers[i].JoinedAt.Before(viewers[j].JoinedAt) })
+	return viewers
+}
+
+// broadcast delivers an event to every member but one. Like the dev event
+// bus, slow viewers miss events rather than blocking the room.
+func (h *roomHub) broadcast(room map[string]*roomMember, event RoomEvent, except string) {
+	for id, member := range room {
+		if id == except {
+			continue
+		}
+		select {
+		case member.events <- event:
+		default:
+		}
+	}
+}
+
+// sessionExists reports whether a session is stored and not deleted
+func sessionExists(db *sql.DB, sessionID string) error {
+	var found bool
+	err := db.QueryRow(`SELECT TRUE FROM claude_sessions WHERE session_id = $1 AND deleted_at IS NULL`, sessionID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up session: %w", err)
+	}
+	return nil
+}
+
+// handleSessionRoom serves GET /api/sessions/{id}/room[?name=], an SSE
+// stream that joins the viewer to the session's room. It starts with a
+// state event naming the viewer and everyone present, then relays join,
+// leave and pointer events of the others until the stream is closed.
+func (a *apiServer) handleSessionRoom(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if err := sessionExists(a.db, sessionID); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, r, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		if key := apiKeyFromContext(r.Context()); key != nil {
+			name = key.Name
+		}
+	}
+	member, viewers := viewerRooms.Join(sessionID, truncateText(name, 40))
+	defer viewerRooms.Leave(sessionID, member.ID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event RoomEvent) {
+		payload, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+		flusher.Flush()
+	}
+	send(RoomEvent{Type: roomEventState, You: member.ID, Viewers: viewers})
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-member.events:
+			send(event)
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// handleRoomPointer serves POST /api/sessions/{id}/room/pointer with
+// {"viewer": id, "pointer": {...}}, sharing where the viewer is with the
+// rest of the room
+func (a *apiServer) handleRoomPointer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Viewer  string        `json:"viewer"`
+		Pointer ViewerPointer `json:"pointer"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Pointer.Message < 0 || req.Pointer.Scroll < 0 || req.Pointer.Scroll > 1 {
+		writeJSONError(w, r, http.StatusBadRequest, "message must not be negative and scroll must be between 0 and 1", nil)
+		return
+	}
+	if sel := req.Pointer.Selection; sel != nil {
+		if sel.Message < 0 || sel.Start < 0 || sel.End < sel.Start {
+			writeJSONError(w, r, http.StatusBadRequest, "selection must have 0 <= start <= end", nil)
+			return
+		}
+		sel.Text = truncateText(sel.Text, maxSelectionText)
+	}
+	if err := viewerRooms.Move(r.PathValue("id"), req.Viewer, req.Pointer); err != nil {
+		writeJSONError(w, r, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
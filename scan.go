@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/urfave/cli/v2"
+)
+
+// ScanFinding is a redaction rule matching part of a synced session
+type ScanFinding struct {
+	SessionID string `json:"session_id"`
+	// Index is the position of the message, or -1 for the session title
+	Index int    `json:"index"`
+	UUID  string `json:"uuid,omitempty"`
+	Role  string `json:"role"`
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+	// Sample is the first match, masked so the report does not leak it
+	Sample string `json:"sample"`
+}
+
+// ScanReport is what `claudemd scan` found, as printed with --json
+type ScanReport struct {
+	Sessions int            `json:"sessions"`
+	Findings []ScanFinding  `json:"findings"`
+	Counts   map[string]int `json:"counts"`
+	// Redacted is the number of sessions rewritten with --redact
+	Redacted int `json:"redacted"`
+}
+
+// onlyRules keeps the rules with the given names
+func (r *Redactor) onlyRules(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var known []string
+	var kept []compiledRedactionRule
+	for _, name := range names {
+		found := false
+		for _, rule := range r.rules {
+			if rule.Name == name {
+				kept = append(kept, rule)
+				found = true
+			}
+		}
+		if !found {
+			for _, rule := range r.rules {
+				known = append(known, rule.Name)
+			}
+			return fmt.Errorf("unknown rule %q (valid: %s)", name, strings.Join(known, ", "))
+		}
+	}
+	r.rules = kept
+	return nil
+}
+
+// scanSession reports the rules matching each message of a session. The
+// session is left as it is; messages are redacted on copies.
+func (r *Redactor) scanSession(session *ClaudeSession) []ScanFinding {
+	var findings []ScanFinding
+	for _, rule := range r.rules {
+		if rule.roles != nil && !rule.roles["summary"] {
+			continue
+		}
+		if matches := rule.re.FindAllString(session.Title, -1); len(matches) > 0 {
+			findings = append(findings, ScanFinding{SessionID: session.SessionID, Index: -1, Role: "title",
+				Rule: rule.Name, Count: len(matches), Sample: maskMatch(matches[0])})
+		}
+	}
+	for i, msg := range session.Messages {
+		counts := r.redactMessage(&msg)
+		if len(counts) == 0 {
+			continue
+		}
+		msg = session.Messages[i]
+		for _, rule := range r.rules {
+			n := counts[rule.Name]
+			if n == 0 {
+				continue
+			}
+			delete(counts, rule.Name)
+			findings = append(findings, ScanFinding{SessionID: session.SessionID, Index: i, UUID: msg.UUID,
+				Role: redactionRole(msg), Rule: rule.Name, Count: n, Sample: maskMatch(firstMatch(rule, msg))})
+		}
+	}
+	return findings
+}
+
+// firstMatch returns the first text of a message the rule matches
+func firstMatch(rule compiledRedactionRule, msg SessionMessage) string {
+	for _, text := range []string{msg.Content, msg.Thinking, msg.Summary} {
+		if match := rule.re.FindString(text); match != "" {
+			return match
+		}
+	}
+	// Matches in the raw message are still JSON encoded
+	match := rule.re.Find(msg.Message)
+	var decoded string
+	if json.Unmarshal(append(append([]byte{'"'}, match...), '"'), &decoded) == nil {
+		return decoded
+	}
+	return string(match)
+}
+
+// maskMatch keeps enough of a match to recognize it: the first four
+// characters and its length
+func maskMatch(match string) string {
+	runes := []rune(strings.Join(strings.Fields(match), " "))
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return fmt.Sprintf("%s… (%d chars)", string(runes[:4]), len(runes))
+}
+
+// redactStoredSession writes a redacted session back over its row
+func redactStoredSession(db *sql.DB, session *ClaudeSession) error {
+	var promptHashes []string
+	if err := db.QueryRow(`SELECT COALESCE(array_agg(prompt_hash), '{}') FROM session_prompts WHERE session_id = $1`,
+		session.SessionID).Scan(pq.Array(&promptHashes)); err != nil {
+		return fmt.Errorf("failed to read prompts of %s: %w", session.SessionID, err)
+	}
+	messagesJSON, err := json.Marshal(session.Messages)
+	if err != nil {
+		return fmt.Errorf("failed to marshal messages: %w", err)
+	}
+	metadataJSON, err := json.Marshal(session.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	_, err = db.Exec(`
+		UPDATE claude_sessions
+		SET title = $2, messages = $3, metadata = $4, updated_at = NOW()
+		WHERE session_id = $1`, session.SessionID, session.Title, string(messagesJSON), string(metadataJSON))
+	if err != nil {
+		return fmt.Errorf("failed to update session %s: %w", session.SessionID, err)
+	}
+
+	// Rows derived from the messages are rebuilt from the redacted ones, and
+	// prompt texts only this session used are dropped with the old links
+	storeDerivedData(postgresSink{db: db}, session)
+	if _, err := db.Exec(`DELETE FROM prompts p WHERE hash = ANY($1) AND `+unusedPrompt, pq.Array(promptHashes)); err != nil {
+		return fmt.Errorf("failed to remove unredacted prompts of %s: %w", session.SessionID, err)
+	}
+	// Outlines are summaries of the messages; the next request rebuilds it
+	if _, err := db.Exec(`DELETE FROM session_outlines WHERE session_id = $1`, session.SessionID); err != nil {
+		return fmt.Errorf("failed to remove outline of %s: %w", session.SessionID, err)
+	}
+	return nil
+}
+
+// redactStoredUpload applies the rules to the complete lines of a session's
+// upload, reporting whether it changed
+func redactStoredUpload(db *sql.DB, sessionID string, redactor *Redactor) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var content, pending, state []byte
+	err = tx.QueryRow(`SELECT content, pending, hash_state FROM session_uploads WHERE session_id = $1 FOR UPDATE`,
+		sessionID).Scan(&content, &pending, &state)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read upload of %s: %w", sessionID, err)
+	}
+	end := bytes.LastIndexByte(content, '\n') + 1
+	redacted := append(redactor.RedactRaw(bytes.Clone(content[:end])), content[end:]...)
+	if bytes.Equal(redacted, content) {
+		return false, nil
+	}
+
+	// Uploads stored before redaction are checksummed from their content, so
+	// the checksum is saved before the content changes
+	h, err := uploadHash(content, pending, state)
+	if err != nil {
+		return false, err
+	}
+	if state, err = h.(encoding.BinaryMarshaler).MarshalBinary(); err != nil {
+		return false, fmt.Errorf("failed to save upload checksum: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE session_uploads SET content = $2, hash_state = $3 WHERE session_id = $1`,
+		sessionID, redacted, state); err != nil {
+		return false, fmt.Errorf("failed to update upload of %s: %w", sessionID, err)
+	}
+	return true, tx.Commit()
+}
+
+// redactStoredContextFiles applies the rules to the context files of a
+// session. Redacted contents are stored under their new hash and linked in
+// place of the old ones, which are removed once no session links to them.
+func redactStoredContextFiles(db *sql.DB, session *ClaudeSession, redactor *Redactor) (bool, error) {
+	files, err := sessionContextFiles(db, session)
+	if err != nil {
+		return false, err
+	}
+	var oldHashes []string
+	for i, file := range files {
+		redacted := string(redactor.RedactRaw([]byte(file.Content)))
+		if redacted == file.Content {
+			continue
+		}
+		oldHashes = append(oldHashes, file.SHA256)
+		sum := sha256.Sum256([]byte(redacted))
+		files[i].Content, files[i].SHA256, files[i].Size = redacted, hex.EncodeToString(sum[:]), len(redacted)
+	}
+	if len(oldHashes) == 0 {
+		return false, nil
+	}
+
+	if err := (postgresSink{db: db}).StoreContextFiles(files); err != nil {
+		return false, err
+	}
+	for i := range files {
+		files[i].Content = ""
+	}
+	session.Metadata["context_files"] = files
+	links, err := json.Marshal(files)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal context files: %w", err)
+	}
+	if _, err := db.Exec(`
+		UPDATE claude_sessions SET metadata = jsonb_set(metadata, '{context_files}', $2::jsonb), updated_at = NOW()
+		WHERE session_id = $1`, session.SessionID, string(links)); err != nil {
+		return false, fmt.Errorf("failed to update context files of %s: %w", session.SessionID, err)
+	}
+	if _, err := db.Exec(`DELETE FROM context_files c WHERE sha256 = ANY($1) AND `+unlinkedContextFile, pq.Array(oldHashes)); err != nil {
+		return false, fmt.Errorf("failed to remove unredacted context files of %s: %w", session.SessionID, err)
+	}
+	return true, nil
+}
+
+// redactStoredSnapshot applies the rules to the raw snapshot of a session,
+// reporting whether it changed. Sessions without a snapshot are skipped.
+func redactStoredSnapshot(db *sql.DB, sessionID string, redactor *Redactor) (bool, error) {
+	var content []byte
+	var checksum string
+	err := db.QueryRow(`SELECT content, sha256 FROM claude_session_raw WHERE session_id = $1`, sessionID).Scan(&content, &checksum)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read snapshot of %s: %w", sessionID, err)
+	}
+	data, err := decompressSnapshot(content, checksum)
+	if err != nil {
+		return false, fmt.Errorf("failed to read snapshot of %s: %w", sessionID, err)
+	}
+	redacted := redactor.RedactRaw(data)
+	if bytes.Equal(redacted, data) {
+		return false, nil
+	}
+
+	compressed, err := compressSnapshot(redacted)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(redacted)
+	_, err = db.Exec(`
+		UPDATE claude_session_raw
+		SET content = $2, size = $3, sha256 = $4, updated_at = NOW()
+		WHERE session_id = $1`, sessionID, compressed, len(redacted), hex.EncodeToString(sum[:]))
+	if err != nil {
+		return false, fmt.Errorf("failed to update snapshot of %s: %w", sessionID, err)
+	}
+	return true, nil
+}
+
+// scanCommand runs the redaction rules over sessions that are already
+// synced, reporting what they match and with --redact masking it in place.
+// Offloaded tool results are scanned too and offloaded again once redacted.
+func scanCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	filter, err := sessionFilterFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	db, config, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The rules are used whether or not redaction is enabled for syncing
+	redactor, err := NewRedactor(config.Redaction)
+	if err != nil {
+		return err
+	}
+	if err := redactor.onlyRules(c.StringSlice("rule")); err != nil {
+		return err
+	}
+	if len(redactor.rules) == 0 {
+		return fmt.Errorf("no redaction rules to scan with")
+	}
+	store, err := newBlobStore(config.Blobs)
+	if err != nil {
+		return err
+	}
+	var offloader *blobOffloader
+	if c.Bool("redact") {
+		if offloader, err = newBlobOffloader(config.Blobs); err != nil {
+			return err
+		}
+	}
+
+	if c.Args().Len() > 0 {
+		if filter.IDs, err = sessionArgs(db, c.Args().Slice()); err != nil {
+			return err
+		}
+	}
+	where, args := filter.where()
+	rows, err := db.Query(`SELECT session_id FROM claude_sessions WHERE `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	report := ScanReport{Sessions: len(ids), Findings: []ScanFinding{}, Counts: map[string]int{}}
+	fmt.Fprintf(os.Stderr, "🔎 Scanning %d session(s) with %d rule(s)...\n", len(ids), len(redactor.rules))
+	for _, id := range ids {
+		session, err := loadSession(db, id)
+		if err != nil {
+			return err
+		}
+		rehydrateSession(session, store)
+		findings := redactor.scanSession(session)
+		for _, f := range findings {
+			report.Counts[f.Rule] += f.Count
+		}
+		report.Findings = append(report.Findings, findings...)
+		if !c.Bool("redact") {
+			continue
+		}
+
+		// Copies of the transcript and the project files it read are masked
+		// even when the messages themselves matched nothing
+		redacted := false
+		for _, redactCopy := range []func() (bool, error){
+			func() (bool, error) { return redactStoredSnapshot(db, id, redactor) },
+			func() (bool, error) { return redactStoredUpload(db, id, redactor) },
+			func() (bool, error) { return redactStoredContextFiles(db, session, redactor) },
+		} {
+			changed, err := redactCopy()
+			if err != nil {
+				return err
+			}
+			redacted = redacted || changed
+		}
+		if len(findings) > 0 {
+			redactor.Redact(session)
+			if session.Metadata == nil {
+				session.Metadata = make(map[string]interface{})
+			}
+			if err := offloader.Offload(session); err != nil {
+				return fmt.Errorf("failed to offload tool results of %s: %w", id, err)
+			}
+			if err := redactStoredSession(db, session); err != nil {
+				return err
+			}
+			redacted = true
+		}
+		if redacted {
+			report.Redacted++
+		}
+	}
+
+	header := []string{"SESSION", "#", "ROLE", "RULE", "COUNT", "SAMPLE"}
+	table := make([][]string, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		index := strconv.Itoa(f.Index)
+		if f.Index < 0 {
+			index = "title"
+		}
+		table = append(table, []string{f.SessionID, index, f.Role, f.Rule, strconv.Itoa(f.Count), f.Sample})
+	}
+	if len(table) > 0 || format != "table" {
+		if err := writeOutput(os.Stdout, format, report, header, table); err != nil {
+			return err
+		}
+	}
+
+	sessions := map[string]bool{}
+	for _, f := range report.Findings {
+		sessions[f.SessionID] = true
+	}
+	switch {
+	case len(report.Findings) == 0 && report.Redacted == 0:
+		fmt.Fprintf(os.Stderr, "✅ No sensitive content found\n")
+	case c.Bool("redact"):
+		fmt.Fprintf(os.Stderr, "🧹 Redacted %d finding(s) in %d session(s)\n", len(report.Findings), report.Redacted)
+		if !config.Redaction.Enabled {
+			fmt.Fprintf(os.Stderr, "⚠️  Redaction is not enabled for syncing, so sessions synced again from their files will be unmasked\n")
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "🔍 %d finding(s) in %d of %d session(s); run with --redact to mask them\n", len(report.Findings), len(sessions), len(ids))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// minSyncInterval keeps --interval from turning into a busy loop
+const minSyncInterval = 10 * time.Second
+
+// syncJitter is the fraction of the interval a scheduled sync may move by,
+// so machines started together do not hit the database at once
+const syncJitter = 0.1
+
+// validateSyncInterval checks the --interval flag; zero disables scheduled syncs
+func validateSyncInterval(interval time.Duration) error {
+	if interval != 0 && interval < minSyncInterval {
+		return fmt.Errorf("--interval must be at least %s", minSyncInterval)
+	}
+	return nil
+}
+
+// jitteredInterval returns the interval moved by up to syncJitter either way
+func jitteredInterval(interval time.Duration) time.Duration {
+	spread := float64(interval) * syncJitter
+	return interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// SyncEvery runs a full sync, then another every interval, measured from
+// the start of one to the start of the next. A sync that runs past the
+// interval delays the next one rather than overlapping it.
+func (c *ClaudeSessionSync) SyncEvery(interval time.Duration) error {
+	for {
+		start := time.Now()
+		if err := c.SyncAll(); err != nil {
+			log.Printf("Scheduled sync failed: %v", err)
+		}
+		elapsed := time.Since(start)
+		delay := jitteredInterval(interval) - elapsed
+		if delay < 0 {
+			log.Printf("Sync took %s, longer than the %s interval; starting the next one now", elapsed.Round(time.Second), interval)
+			delay = 0
+		} else {
+			log.Printf("Sync finished in %s, next in %s", elapsed.Round(time.Millisecond), delay.Round(time.Second))
+		}
+		time.Sleep(delay)
+	}
+}
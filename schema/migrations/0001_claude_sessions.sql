@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS claude_sessions (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	session_id VARCHAR(255) UNIQUE NOT NULL,
+	user_id UUID,
+	title TEXT NOT NULL,
+	messages JSONB NOT NULL DEFAULT '[]',
+	metadata JSONB DEFAULT '{}',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_session_id ON claude_sessions(session_id);
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_user_id ON claude_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_created_at ON claude_sessions(created_at);
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_title_gin ON claude_sessions USING gin(to_tsvector('english', title));
+
+-- Create trigger for updating updated_at timestamp
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+	NEW.updated_at = NOW();
+	RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS update_claude_sessions_updated_at ON claude_sessions;
+CREATE TRIGGER update_claude_sessions_updated_at
+	BEFORE UPDATE ON claude_sessions
+	FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
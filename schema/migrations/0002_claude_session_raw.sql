@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS claude_session_raw (
+	session_id VARCHAR(255) PRIMARY KEY REFERENCES claude_sessions(session_id) ON DELETE CASCADE,
+	relative_path TEXT NOT NULL,
+	content BYTEA NOT NULL,
+	size BIGINT NOT NULL,
+	sha256 VARCHAR(64) NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS message_annotations (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	session_id VARCHAR(255) NOT NULL REFERENCES claude_sessions(session_id) ON DELETE CASCADE,
+	message_uuid VARCHAR(255) NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	highlight TEXT NOT NULL DEFAULT '',
+	rating SMALLINT CHECK (rating BETWEEN 1 AND 5),
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_annotations_session ON message_annotations(session_id, message_uuid);
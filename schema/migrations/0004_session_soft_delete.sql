@@ -0,0 +1,2 @@
+-- Soft-deleted sessions are hidden until purged
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
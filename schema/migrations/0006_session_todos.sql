@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS session_todos (
+	session_id TEXT NOT NULL,
+	todo_key TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	active_form TEXT NOT NULL DEFAULT '',
+	priority TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	transitions JSONB NOT NULL DEFAULT '[]',
+	created_at TIMESTAMP WITH TIME ZONE,
+	updated_at TIMESTAMP WITH TIME ZONE,
+	completed_at TIMESTAMP WITH TIME ZONE,
+	PRIMARY KEY (session_id, todo_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_todos_status ON session_todos(status, updated_at DESC);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS session_files (
+	session_id TEXT NOT NULL,
+	path TEXT NOT NULL,
+	relative_path TEXT NOT NULL DEFAULT '',
+	edits INTEGER NOT NULL DEFAULT 0,
+	writes INTEGER NOT NULL DEFAULT 0,
+	lines_added INTEGER NOT NULL DEFAULT 0,
+	lines_removed INTEGER NOT NULL DEFAULT 0,
+	diff_bytes BIGINT NOT NULL DEFAULT 0,
+	first_modified_at TIMESTAMP WITH TIME ZONE,
+	last_modified_at TIMESTAMP WITH TIME ZONE,
+	PRIMARY KEY (session_id, path)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_files_relative_path ON session_files(relative_path);
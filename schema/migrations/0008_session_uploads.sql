@@ -0,0 +1,11 @@
+-- Raw JSONL pushed through the ingest API, kept so uploads can resume and
+-- sessions can be re-parsed as more lines arrive
+CREATE TABLE IF NOT EXISTS session_uploads (
+	session_id TEXT PRIMARY KEY,
+	project TEXT NOT NULL DEFAULT '',
+	host TEXT NOT NULL DEFAULT '',
+	content BYTEA NOT NULL DEFAULT '',
+	size BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
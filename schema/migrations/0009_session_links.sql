@@ -0,0 +1,22 @@
+-- Message UUIDs used to detect resumed sessions: the first and last message
+-- of each session, and the leafUuid of its summary lines
+CREATE TABLE IF NOT EXISTS session_anchors (
+	session_id TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	uuid TEXT NOT NULL,
+	PRIMARY KEY (session_id, kind, uuid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_anchors_uuid ON session_anchors(uuid, kind);
+
+-- A session continuing an earlier one, found through a summary leafUuid or
+-- messages copied from the predecessor
+CREATE TABLE IF NOT EXISTS session_links (
+	session_id TEXT NOT NULL,
+	predecessor_id TEXT NOT NULL,
+	method TEXT NOT NULL,
+	detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (session_id, predecessor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_links_predecessor ON session_links(predecessor_id);
@@ -0,0 +1,10 @@
+-- How a session turned out, set by the user: success, failure or abandoned,
+-- with an optional 1-5 rating
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS outcome TEXT
+	CHECK (outcome IN ('success', 'failure', 'abandoned'));
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS rating SMALLINT
+	CHECK (rating BETWEEN 1 AND 5);
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS outcome_note TEXT NOT NULL DEFAULT '';
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS outcome_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_outcome ON claude_sessions(outcome) WHERE outcome IS NOT NULL;
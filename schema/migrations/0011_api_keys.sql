@@ -0,0 +1,13 @@
+-- Keys created with `claudemd apikey create`. Only a SHA-256 of each key is
+-- stored; prefix identifies it in listings. An empty project allows all.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scope TEXT NOT NULL CHECK (scope IN ('read', 'ingest')),
+	project TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMP WITH TIME ZONE,
+	revoked_at TIMESTAMP WITH TIME ZONE
+);
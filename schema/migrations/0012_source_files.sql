@@ -0,0 +1,14 @@
+-- Files from the ~/.claude directories synced next to sessions, such as
+-- todos and shell-snapshots, keeping the latest content of each
+CREATE TABLE IF NOT EXISTS claude_source_files (
+	source TEXT NOT NULL,
+	name TEXT NOT NULL,
+	session_id TEXT NOT NULL DEFAULT '',
+	content TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	modified_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (source, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_claude_source_files_session ON claude_source_files(session_id) WHERE session_id <> '';
@@ -0,0 +1,19 @@
+-- Prompts typed in sessions, deduplicated by the hash of their normalized
+-- text. The text is the first variant seen.
+CREATE TABLE IF NOT EXISTS prompts (
+	hash TEXT PRIMARY KEY,
+	text TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- How often each session used a prompt, replaced whenever the session syncs
+CREATE TABLE IF NOT EXISTS session_prompts (
+	session_id TEXT NOT NULL,
+	prompt_hash TEXT NOT NULL REFERENCES prompts(hash),
+	uses INTEGER NOT NULL,
+	first_used_at TIMESTAMP WITH TIME ZONE,
+	last_used_at TIMESTAMP WITH TIME ZONE,
+	PRIMARY KEY (session_id, prompt_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_prompts_hash ON session_prompts(prompt_hash);
@@ -0,0 +1,24 @@
+-- Workspaces let a team share one deployment. Every session belongs to
+-- one; sessions synced before workspaces existed stay in "personal".
+CREATE TABLE IF NOT EXISTS workspaces (
+	slug TEXT PRIMARY KEY CHECK (slug ~ '^[a-z0-9][a-z0-9_-]{0,62}$'),
+	name TEXT NOT NULL DEFAULT '',
+	settings JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+	workspace TEXT NOT NULL REFERENCES workspaces(slug) ON DELETE CASCADE,
+	member TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'member')),
+	added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (workspace, member)
+);
+
+INSERT INTO workspaces (slug, name) VALUES ('personal', 'Personal') ON CONFLICT DO NOTHING;
+
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS workspace TEXT NOT NULL DEFAULT 'personal' REFERENCES workspaces(slug);
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_workspace ON claude_sessions(workspace, updated_at DESC);
+
+-- An empty workspace lets a key read every workspace
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS workspace TEXT NOT NULL DEFAULT '';
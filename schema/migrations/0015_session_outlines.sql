@@ -0,0 +1,9 @@
+-- Outlines summarize long sessions in chunks, sections and as a whole. The
+-- outline is rebuilt when the session has more messages than it covers.
+CREATE TABLE IF NOT EXISTS session_outlines (
+	session_id TEXT PRIMARY KEY,
+	message_count INTEGER NOT NULL,
+	summarizer TEXT NOT NULL,
+	outline JSONB NOT NULL,
+	generated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
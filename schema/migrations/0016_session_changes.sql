@@ -0,0 +1,2 @@
+-- GET /api/sessions/changes walks sessions in update order
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_changes ON claude_sessions(updated_at, session_id);
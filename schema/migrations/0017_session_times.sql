@@ -0,0 +1,23 @@
+-- The first and last message timestamps of a session and the time between
+-- them, filled in by sync. Existing sessions are backfilled from their
+-- messages, skipping timestamps that do not parse.
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS duration_ms BIGINT;
+
+UPDATE claude_sessions AS s
+SET started_at = t.started_at, ended_at = t.ended_at,
+	duration_ms = (EXTRACT(EPOCH FROM t.ended_at - t.started_at) * 1000)::BIGINT
+FROM (
+	SELECT c.session_id, min(ts) AS started_at, max(ts) AS ended_at
+	FROM claude_sessions AS c,
+		LATERAL (
+			SELECT (m->>'timestamp')::timestamptz AS ts
+			FROM jsonb_array_elements(c.messages) AS m
+			WHERE m->>'timestamp' ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$'
+		) AS m
+	GROUP BY c.session_id
+) AS t
+WHERE s.session_id = t.session_id AND s.started_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_claude_sessions_started_at ON claude_sessions(started_at);
@@ -0,0 +1,4 @@
+-- Hash of what sync last wrote for a session, so a sync whose payload is
+-- unchanged skips rewriting the row. Sessions without one are written on
+-- their next sync.
+ALTER TABLE claude_sessions ADD COLUMN IF NOT EXISTS content_hash TEXT;
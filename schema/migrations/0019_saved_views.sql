@@ -0,0 +1,9 @@
+-- Named session filters shared by the CLI and the frontend. Dates in the
+-- filter are kept as written, so ages such as 14d stay relative.
+CREATE TABLE IF NOT EXISTS saved_views (
+	name VARCHAR(100) PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	filter JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
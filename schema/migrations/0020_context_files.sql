@@ -0,0 +1,9 @@
+-- Project files such as CLAUDE.md as they were when a session started.
+-- Sessions link to them by hash from metadata.context_files, so a file
+-- that did not change between sessions is stored once.
+CREATE TABLE IF NOT EXISTS context_files (
+	sha256 VARCHAR(64) PRIMARY KEY,
+	content TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
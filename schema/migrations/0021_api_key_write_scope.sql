@@ -0,0 +1,3 @@
+-- Write keys open the endpoints that change sessions, annotations and views
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS api_keys_scope_check;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_scope_check CHECK (scope IN ('read', 'write', 'ingest'));
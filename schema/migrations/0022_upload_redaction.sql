@@ -0,0 +1,5 @@
+-- Uploads are redacted line by line before they are stored. pending holds
+-- the end of a line still being uploaded, and hash_state the SHA-256 of
+-- everything received, so resuming clients can still check their prefix.
+ALTER TABLE session_uploads ADD COLUMN IF NOT EXISTS pending BYTEA NOT NULL DEFAULT '';
+ALTER TABLE session_uploads ADD COLUMN IF NOT EXISTS hash_state BYTEA;
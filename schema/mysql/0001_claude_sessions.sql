@@ -0,0 +1,17 @@
+-- MySQL/MariaDB schema for synced sessions. The tables mirror the
+-- PostgreSQL ones the sync writes to, with JSON in place of JSONB and
+-- UTC DATETIME columns. Key columns are VARCHAR so they can be indexed.
+CREATE TABLE IF NOT EXISTS claude_sessions (
+	id CHAR(36) NOT NULL PRIMARY KEY,
+	session_id VARCHAR(255) NOT NULL,
+	user_id CHAR(36),
+	title TEXT NOT NULL,
+	messages JSON NOT NULL,
+	metadata JSON,
+	created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+	deleted_at DATETIME(6) NULL,
+	UNIQUE KEY idx_claude_sessions_session_id (session_id),
+	KEY idx_claude_sessions_user_id (user_id),
+	KEY idx_claude_sessions_created_at (created_at)
+) DEFAULT CHARSET = utf8mb4;
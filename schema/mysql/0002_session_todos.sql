@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS session_todos (
+	session_id VARCHAR(255) NOT NULL,
+	todo_key VARCHAR(255) NOT NULL,
+	position INT NOT NULL,
+	content TEXT NOT NULL,
+	active_form TEXT NOT NULL,
+	priority VARCHAR(32) NOT NULL DEFAULT '',
+	status VARCHAR(32) NOT NULL,
+	transitions JSON NOT NULL,
+	created_at DATETIME(6) NULL,
+	updated_at DATETIME(6) NULL,
+	completed_at DATETIME(6) NULL,
+	PRIMARY KEY (session_id, todo_key),
+	KEY idx_session_todos_status (status, updated_at)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,16 @@
+-- Paths are capped at 512 characters to keep the primary key within
+-- InnoDB's 3072 byte limit
+CREATE TABLE IF NOT EXISTS session_files (
+	session_id VARCHAR(255) NOT NULL,
+	path VARCHAR(512) NOT NULL,
+	relative_path VARCHAR(512) NOT NULL DEFAULT '',
+	edits INT NOT NULL DEFAULT 0,
+	writes INT NOT NULL DEFAULT 0,
+	lines_added INT NOT NULL DEFAULT 0,
+	lines_removed INT NOT NULL DEFAULT 0,
+	diff_bytes BIGINT NOT NULL DEFAULT 0,
+	first_modified_at DATETIME(6) NULL,
+	last_modified_at DATETIME(6) NULL,
+	PRIMARY KEY (session_id, path),
+	KEY idx_session_files_relative_path (relative_path)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,13 @@
+-- Files from the ~/.claude directories synced next to sessions, such as
+-- todos and shell-snapshots, keeping the latest content of each
+CREATE TABLE IF NOT EXISTS claude_source_files (
+	source VARCHAR(64) NOT NULL,
+	name VARCHAR(512) NOT NULL,
+	session_id VARCHAR(255) NOT NULL DEFAULT '',
+	content LONGTEXT NOT NULL,
+	size BIGINT NOT NULL,
+	modified_at DATETIME(6) NOT NULL,
+	synced_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+	PRIMARY KEY (source, name),
+	KEY idx_claude_source_files_session (session_id)
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+-- MySQL databases only receive synced sessions, so they record the
+-- workspace of each session without the workspaces table
+ALTER TABLE claude_sessions ADD COLUMN workspace VARCHAR(63) NOT NULL DEFAULT 'personal';
@@ -0,0 +1,7 @@
+-- The first and last message timestamps of a session and the time between
+-- them, in UTC. Sessions synced before are filled in when synced again.
+ALTER TABLE claude_sessions
+	ADD COLUMN started_at DATETIME(6) NULL,
+	ADD COLUMN ended_at DATETIME(6) NULL,
+	ADD COLUMN duration_ms BIGINT NULL,
+	ADD KEY idx_claude_sessions_started_at (started_at);
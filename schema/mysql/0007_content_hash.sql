@@ -0,0 +1,3 @@
+-- Hash of what sync last wrote for a session, so a sync whose payload is
+-- unchanged skips rewriting the row
+ALTER TABLE claude_sessions ADD COLUMN content_hash CHAR(64) NULL;
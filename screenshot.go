@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/urfave/cli/v2"
+)
+
+// screenshotReadySelector matches once the component has rendered something
+const screenshotReadySelector = "#root > *"
+
+// screenshotSettleScript resolves once web fonts have loaded and two frames
+// have been painted after the render
+const screenshotSettleScript = `document.fonts.ready.then(() => new Promise(resolve =>
+	requestAnimationFrame(() => requestAnimationFrame(() => resolve(true)))))`
+
+// screenshotSkipDirs are never searched for components
+var screenshotSkipDirs = map[string]bool{"node_modules": true, ".git": true, ".claudemd": true, "dist": true, "ignored": true}
+
+// componentExtensions are the files /render can build
+var componentExtensions = map[string]bool{".tsx": true, ".jsx": true, ".ts": true, ".js": true}
+
+// matchComponents returns the component files below the current directory
+// matching any of the glob patterns, which support ** as in sync filters
+func matchComponents(patterns []string) ([]string, error) {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := globToRegexp(strings.TrimPrefix(pattern, "./"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		res = append(res, re)
+	}
+
+	var matches []string
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != "." && screenshotSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !componentExtensions[filepath.Ext(path)] || strings.HasSuffix(path, ".d.ts") {
+			return nil
+		}
+		rel := filepath.ToSlash(path)
+		for _, re := range res {
+			if re.MatchString(rel) {
+				matches = append(matches, rel)
+				break
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// screenshotOptions are shared by every capture of a run
+type screenshotOptions struct {
+	// browser is the headless browser every capture opens a tab in
+	browser   context.Context
+	baseURL   string
+	outDir    string
+	component string
+	width     int
+	height    int
+	wait      time.Duration
+}
+
+// capture renders one component in a new tab and saves it as a PNG
+// mirroring its path under the output directory. Components that fail to
+// build, log console errors or throw are not captured, so an error page
+// never becomes a baseline.
+func (o *screenshotOptions) capture(path string) (string, error) {
+	target := o.baseURL + (&url.URL{Path: "/render/" + path}).String()
+	if o.component != "" {
+		target += "?component=" + url.QueryEscape(o.component)
+	}
+
+	tab, closeTab := chromedp.NewContext(o.browser)
+	defer closeTab()
+	ctx, cancel := context.WithTimeout(tab, o.wait)
+	defer cancel()
+
+	var mu sync.Mutex
+	var pageErrors []string
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		var message string
+		switch ev := ev.(type) {
+		case *runtime.EventExceptionThrown:
+			message = ev.ExceptionDetails.Error()
+		case *runtime.EventConsoleAPICalled:
+			if ev.Type != runtime.APITypeError {
+				return
+			}
+			var args []string
+			for _, arg := range ev.Args {
+				if arg.Description != "" {
+					args = append(args, arg.Description)
+				} else {
+					args = append(args, strings.Trim(string(arg.Value), `"`))
+				}
+			}
+			message = strings.Join(args, " ")
+		default:
+			return
+		}
+		mu.Lock()
+		pageErrors = append(pageErrors, message)
+		mu.Unlock()
+	})
+
+	if err := chromedp.Run(ctx, chromedp.EmulateViewport(int64(o.width), int64(o.height))); err != nil {
+		return "", fmt.Errorf("failed to open a tab: %w", err)
+	}
+	resp, err := chromedp.RunResponse(ctx, chromedp.Navigate(target))
+	if err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", target, err)
+	}
+	if resp.Status != http.StatusOK {
+		return "", fmt.Errorf("render failed: %d %s", resp.Status, resp.StatusText)
+	}
+	var png []byte
+	var settled bool
+	err = chromedp.Run(ctx,
+		chromedp.WaitVisible(screenshotReadySelector, chromedp.ByQuery),
+		chromedp.Evaluate(screenshotSettleScript, &settled, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+		chromedp.CaptureScreenshot(&png),
+	)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pageErrors) > 0 {
+		return "", fmt.Errorf("runtime error: %s", strings.Join(pageErrors, "; "))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("nothing rendered within %s", o.wait)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to take screenshot: %w", err)
+	}
+
+	out, err := filepath.Abs(filepath.Join(o.outDir, strings.TrimSuffix(path, filepath.Ext(path))+".png"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(out, png, 0644); err != nil {
+		return "", fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	return out, nil
+}
+
+// screenshotCommand renders every component matching the glob patterns
+// through /render and saves PNGs, for use as visual regression baselines.
+// A running server can be given with --url; otherwise one is started on a
+// free port for the run.
+func screenshotCommand(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: claudemd screenshot <glob>...")
+	}
+	if c.Int("width") <= 0 || c.Int("height") <= 0 {
+		return fmt.Errorf("--width and --height must be positive")
+	}
+	browser, err := findHeadlessBrowser()
+	if err != nil {
+		return err
+	}
+	paths, err := matchComponents(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no components match %s", strings.Join(c.Args().Slice(), " "))
+	}
+
+	browserCtx, closeBrowser, err := startHeadlessBrowser(browser, c.Int("width"), c.Int("height"))
+	if err != nil {
+		return err
+	}
+	defer closeBrowser()
+
+	opts := &screenshotOptions{
+		browser:   browserCtx,
+		baseURL:   strings.TrimRight(c.String("url"), "/"),
+		outDir:    c.String("out"),
+		component: c.String("component"),
+		width:     c.Int("width"),
+		height:    c.Int("height"),
+		wait:      c.Duration("wait"),
+	}
+	if opts.baseURL == "" {
+		if err := resolveBuildConfig(c); err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("failed to start render server: %w", err)
+		}
+		server := &http.Server{Handler: createHTTPServer(&apiServer{})}
+		go server.Serve(listener)
+		defer server.Close()
+		opts.baseURL = "http://" + listener.Addr().String()
+	}
+
+	fmt.Printf("📸 Capturing %d component(s) into %s...\n", len(paths), opts.outDir)
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var failed []string
+	var wg sync.WaitGroup
+	for i := 0; i < max(c.Int("parallel"), 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				out, err := opts.capture(path)
+				mu.Lock()
+				if err != nil {
+					fmt.Printf("  ❌ %s: %v\n", path, err)
+					failed = append(failed, path)
+				} else {
+					fmt.Printf("  ✅ %s → %s\n", path, out)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d component(s) could not be captured", len(failed), len(paths))
+	}
+	fmt.Printf("✅ Captured %d component(s)\n", len(paths))
+	return nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// serviceName is the systemd unit name, serviceLabel the launchd label
+const (
+	serviceName  = "claudemd-sync"
+	serviceLabel = "com.claudemd.sync"
+)
+
+// serviceEnvVars are copied into the service environment when set, since
+// services do not inherit the login shell's environment
+var serviceEnvVars = []string{
+	supabaseURLEnv,
+	supabaseServiceKeyEnv,
+	defaultSupabaseEnv,
+	supabaseDBPasswordEnv,
+	supabaseAccessTokenEnv,
+	defaultTitleKeyEnv,
+}
+
+// serviceSpec describes the background process to install
+type serviceSpec struct {
+	Args       []string
+	WorkingDir string
+	Env        map[string]string
+	// LogFile receives stdout and stderr; empty leaves systemd logging to the journal
+	LogFile string
+}
+
+// serviceManager installs services with the platform's init system
+type serviceManager interface {
+	// Path is where the unit or plist is installed
+	Path() (string, error)
+	Render(spec serviceSpec) string
+	Start(path string) error
+	Stop(path string) error
+	Status(path string) error
+	// DefaultLogFile is used when --log-file is not given
+	DefaultLogFile() string
+}
+
+// serviceCommand groups the service install, uninstall and status subcommands
+func serviceCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "service",
+		Usage: "Run sync-sessions --watch in the background as a systemd or launchd user service",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "install",
+				Usage: "Install and start the service for the current user, syncing from this directory",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "daemon",
+						Usage: "Run the daemon command (sync watcher and dev server) instead of sync-sessions --watch",
+					},
+					&cli.StringSliceFlag{
+						Name:  "env",
+						Usage: "Copy an environment variable into the service (repeatable); Supabase and title API keys are copied when set",
+					},
+					&cli.StringFlag{
+						Name:  "log-file",
+						Usage: "File for the service's output (defaults to the journal on Linux and ~/Library/Logs/claudemd on macOS)",
+					},
+					&cli.BoolFlag{
+						Name:  "print",
+						Usage: "Print the unit or plist instead of installing it",
+					},
+				},
+				Action: serviceInstallCommand,
+			},
+			{
+				Name:   "uninstall",
+				Usage:  "Stop and remove the service",
+				Action: serviceUninstallCommand,
+			},
+			{
+				Name:   "status",
+				Usage:  "Show whether the service is running and where it logs",
+				Action: serviceStatusCommand,
+			},
+		},
+	}
+}
+
+// currentServiceManager picks the init system of this platform
+func currentServiceManager() (serviceManager, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return systemdService{}, nil
+	case "darwin":
+		return launchdService{}, nil
+	}
+	return nil, fmt.Errorf("services are only supported on Linux (systemd) and macOS (launchd)")
+}
+
+func serviceInstallCommand(c *cli.Context) error {
+	manager, err := currentServiceManager()
+	if err != nil {
+		return err
+	}
+	spec, err := newServiceSpec(c, manager)
+	if err != nil {
+		return err
+	}
+	content := manager.Render(spec)
+	if c.Bool("print") {
+		fmt.Print(content)
+		return nil
+	}
+
+	path, err := manager.Path()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		// Reinstalling replaces the running service with the new definition
+		if err := manager.Stop(path); err != nil {
+			fmt.Printf("⚠️  Could not stop the existing service: %v\n", err)
+		}
+	}
+	if spec.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(spec.LogFile), 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create service directory: %w", err)
+	}
+	// The service file can hold API keys, so only the user may read it
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write service file: %w", err)
+	}
+	if err := manager.Start(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Installed %s\n", path)
+	fmt.Printf("🔄 Running: claudemd %s\n", strings.Join(spec.Args[1:], " "))
+	fmt.Printf("📁 Working directory: %s\n", spec.WorkingDir)
+	if spec.LogFile != "" {
+		fmt.Printf("📜 Logs: %s\n", spec.LogFile)
+	} else {
+		fmt.Printf("📜 Logs: journalctl --user -u %s -f\n", serviceName)
+	}
+	return nil
+}
+
+// newServiceSpec runs this binary from the current directory, so the
+// service finds the same ignored/config.json and claudemd.config.json
+func newServiceSpec(c *cli.Context, manager serviceManager) (serviceSpec, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return serviceSpec{}, fmt.Errorf("failed to locate the claudemd binary: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return serviceSpec{}, fmt.Errorf("failed to locate the claudemd binary: %w", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return serviceSpec{}, err
+	}
+
+	spec := serviceSpec{
+		Args:       []string{exe, "sync-sessions", "--watch"},
+		WorkingDir: dir,
+		Env:        map[string]string{"PATH": os.Getenv("PATH")},
+		LogFile:    c.String("log-file"),
+	}
+	if c.Bool("daemon") {
+		spec.Args = []string{exe, "daemon"}
+	}
+	for _, name := range serviceEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			spec.Env[name] = value
+		}
+	}
+	for _, name := range c.StringSlice("env") {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return serviceSpec{}, fmt.Errorf("environment variable %s is not set", name)
+		}
+		spec.Env[name] = value
+	}
+	if spec.LogFile == "" {
+		spec.LogFile = manager.DefaultLogFile()
+	}
+	if spec.LogFile != "" {
+		if spec.LogFile, err = filepath.Abs(spec.LogFile); err != nil {
+			return serviceSpec{}, err
+		}
+	}
+	return spec, nil
+}
+
+func serviceUninstallCommand(c *cli.Context) error {
+	manager, err := currentServiceManager()
+	if err != nil {
+		return err
+	}
+	path, err := manager.Path()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Println("ℹ️  The service is not installed")
+		return nil
+	}
+	if err := manager.Stop(path); err != nil {
+		fmt.Printf("⚠️  Could not stop the service: %v\n", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove service file: %w", err)
+	}
+	if _, ok := manager.(systemdService); ok {
+		runServiceTool("systemctl", "--user", "daemon-reload")
+	}
+	fmt.Printf("🗑️  Removed %s\n", path)
+	return nil
+}
+
+func serviceStatusCommand(c *cli.Context) error {
+	manager, err := currentServiceManager()
+	if err != nil {
+		return err
+	}
+	path, err := manager.Path()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Println("ℹ️  The service is not installed; run claudemd service install")
+		return nil
+	}
+	fmt.Printf("📄 Service file: %s\n", path)
+	return manager.Status(path)
+}
+
+// runServiceTool runs systemctl or launchctl, including its output in errors
+func runServiceTool(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w\n%s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// systemdService installs a systemd user unit
+type systemdService struct{}
+
+func (systemdService) Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", serviceName+".service"), nil
+}
+
+func (systemdService) DefaultLogFile() string { return "" }
+
+func (systemdService) Render(spec serviceSpec) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=claudemd session sync\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdEscape(spec.WorkingDir))
+	args := make([]string, len(spec.Args))
+	for i, arg := range spec.Args {
+		args[i] = systemdQuote(arg)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	for _, name := range sortedKeys(spec.Env) {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(name+"="+spec.Env[name]))
+	}
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=10\n")
+	if spec.LogFile != "" {
+		fmt.Fprintf(&b, "StandardOutput=append:%s\n", systemdEscape(spec.LogFile))
+		fmt.Fprintf(&b, "StandardError=append:%s\n", systemdEscape(spec.LogFile))
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return b.String()
+}
+
+func (systemdService) Start(path string) error {
+	if err := runServiceTool("systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	return runServiceTool("systemctl", "--user", "enable", "--now", serviceName+".service")
+}
+
+func (systemdService) Stop(path string) error {
+	return runServiceTool("systemctl", "--user", "disable", "--now", serviceName+".service")
+}
+
+func (systemdService) Status(path string) error {
+	// systemctl status exits non-zero for stopped units, which is still a valid answer
+	cmd := exec.Command("systemctl", "--user", "status", "--no-pager", serviceName+".service")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Run()
+	fmt.Printf("📜 Logs: journalctl --user -u %s -f (or the StandardOutput file in the unit)\n", serviceName)
+	return nil
+}
+
+// systemdEscape escapes the specifier character in unit file values
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote quotes a unit file word when it contains spaces or quotes
+func systemdQuote(s string) string {
+	s = systemdEscape(s)
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// launchdService installs a launchd user agent
+type launchdService struct{}
+
+func (launchdService) Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", serviceLabel+".plist"), nil
+}
+
+func (launchdService) DefaultLogFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "Library", "Logs", "claudemd", "sync.log")
+}
+
+func (launchdService) Render(spec serviceSpec) string {
+	var b strings.Builder
+	str := func(s string) string {
+		var buf strings.Builder
+		xml.EscapeText(&buf, []byte(s))
+		return "<string>" + buf.String() + "</string>"
+	}
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "    <key>Label</key>\n    %s\n", str(serviceLabel))
+	b.WriteString("    <key>ProgramArguments</key>\n    <array>\n")
+	for _, arg := range spec.Args {
+		fmt.Fprintf(&b, "        %s\n", str(arg))
+	}
+	b.WriteString("    </array>\n")
+	fmt.Fprintf(&b, "    <key>WorkingDirectory</key>\n    %s\n", str(spec.WorkingDir))
+	b.WriteString("    <key>EnvironmentVariables</key>\n    <dict>\n")
+	for _, name := range sortedKeys(spec.Env) {
+		fmt.Fprintf(&b, "        <key>%s</key>\n        %s\n", name, str(spec.Env[name]))
+	}
+	b.WriteString("    </dict>\n")
+	b.WriteString("    <key>RunAtLoad</key>\n    <true/>\n")
+	// Restart after crashes but not after a clean exit
+	b.WriteString("    <key>KeepAlive</key>\n    <dict>\n        <key>SuccessfulExit</key>\n        <false/>\n    </dict>\n")
+	b.WriteString("    <key>ThrottleInterval</key>\n    <integer>10</integer>\n")
+	if spec.LogFile != "" {
+		fmt.Fprintf(&b, "    <key>StandardOutPath</key>\n    %s\n", str(spec.LogFile))
+		fmt.Fprintf(&b, "    <key>StandardErrorPath</key>\n    %s\n", str(spec.LogFile))
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// launchdDomain is the launchctl domain of the current user's agents
+func launchdDomain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func (launchdService) Start(path string) error {
+	return runServiceTool("launchctl", "bootstrap", launchdDomain(), path)
+}
+
+func (launchdService) Stop(path string) error {
+	return runServiceTool("launchctl", "bootout", launchdDomain(), path)
+}
+
+func (l launchdService) Status(path string) error {
+	output, err := exec.Command("launchctl", "print", launchdDomain()+"/"+serviceLabel).CombinedOutput()
+	if err != nil {
+		fmt.Println("⏹️  The service is installed but not loaded")
+	} else {
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "state =") || strings.HasPrefix(line, "pid =") || strings.HasPrefix(line, "last exit code =") {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+	}
+	fmt.Printf("📜 Logs: %s\n", l.DefaultLogFile())
+	return nil
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// SessionSummary is a session without its messages, as printed by `sessions list`
+type SessionSummary struct {
+	SessionID string    `json:"session_id"`
+	Title     string    `json:"title"`
+	Project   string    `json:"project"`
+	Workspace string    `json:"workspace"`
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	SessionTimes
+	// Match is the first message containing the search text
+	Match string `json:"match,omitempty"`
+}
+
+// snippetRadius is how much context `sessions search` shows around a match
+const snippetRadius = 60
+
+// sessionCursor is the position of a session in the list, which is ordered
+// by a time, update time unless sorted otherwise, and then ID
+type sessionCursor struct {
+	At        time.Time
+	SessionID string
+}
+
+// sessionSort is an order of the session list
+type sessionSort struct {
+	column string
+	desc   bool
+}
+
+// sessionSorts are the orders of the session list by name
+var sessionSorts = map[string]sessionSort{
+	"updated":     {"updated_at", true},
+	"updated_asc": {"updated_at", false},
+	"created":     {"created_at", true},
+	"created_asc": {"created_at", false},
+}
+
+// validateSessionSort checks a sort name, where empty means updated
+func validateSessionSort(name string) error {
+	if _, ok := sessionSorts[name]; name != "" && !ok {
+		return fmt.Errorf("sort must be one of %s", strings.Join(sortedKeys(sessionSorts), ", "))
+	}
+	return nil
+}
+
+// sessionOrder is the sort of the filter
+func (f SessionFilter) sessionOrder() sessionSort {
+	if sort, ok := sessionSorts[f.Sort]; ok {
+		return sort
+	}
+	return sessionSorts["updated"]
+}
+
+// cursor is the position of a session in the list sorted as the filter says
+func (f SessionFilter) cursor(s SessionSummary) string {
+	if f.sessionOrder().column == "created_at" {
+		return encodeCursor(s.CreatedAt, s.SessionID)
+	}
+	return encodeCursor(s.UpdatedAt, s.SessionID)
+}
+
+// listSessionSummaries returns matching sessions in the order of the
+// filter, starting after the cursor when one is given
+func listSessionSummaries(db *sql.DB, filter SessionFilter, after *sessionCursor, limit int) ([]SessionSummary, error) {
+	where, args := filter.where()
+	order := filter.sessionOrder()
+	direction, compare := "DESC", "<"
+	if !order.desc {
+		direction, compare = "ASC", ">"
+	}
+	if after != nil {
+		args = append(args, after.At, after.SessionID)
+		where += fmt.Sprintf(" AND (%s, session_id) %s ($%d, $%d)", order.column, compare, len(args)-1, len(args))
+	}
+	match := "''"
+	if filter.Text != "" {
+		args = append(args, filter.Text)
+		match = fmt.Sprintf(`COALESCE((
+			SELECT m->>'content' FROM jsonb_array_elements(messages) AS m
+			WHERE strpos(lower(m->>'content'), lower($%d)) > 0 LIMIT 1), '')`, len(args))
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		SELECT session_id, title, COALESCE(metadata->>'source_file', ''), workspace, jsonb_array_length(messages),
+		       created_at, updated_at, started_at, ended_at, duration_ms, %s
+		FROM claude_sessions
+		WHERE %s
+		ORDER BY %s %s, session_id %s
+		LIMIT $%d`, match, where, order.column, direction, direction, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []SessionSummary{}
+	for rows.Next() {
+		var s SessionSummary
+		var sourceFile string
+		if err := rows.Scan(&s.SessionID, &s.Title, &sourceFile, &s.Workspace, &s.Messages, &s.CreatedAt, &s.UpdatedAt,
+			&s.StartedAt, &s.EndedAt, &s.DurationMS, &s.Match); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		if sourceFile != "" {
+			s.Project = filepath.Base(filepath.Dir(sourceFile))
+		}
+		s.Match = matchSnippet(s.Match, filter.Text)
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// handleListSessions serves GET /api/sessions?limit=&cursor= with the
+// filters of sessionFilterFromQuery. The total is only counted for the
+// first page.
+func (a *apiServer) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := sessionFilterFromQuery(q)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if name := q.Get("view"); name != "" {
+		if filter, err = applyView(a.db, name, filter); err != nil {
+			a.writeLoadError(w, r, err)
+			return
+		}
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	page, err := pageFromQuery(q, 50, 500)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var cursor sessionCursor
+	var after *sessionCursor
+	if ok, err := page.decode(&cursor.At, &cursor.SessionID); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	} else if ok {
+		after = &cursor
+	}
+
+	summaries, err := listSessionSummaries(a.db, filter, after, page.Limit+1)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	result := newPage(summaries, page, filter.cursor)
+	if after == nil {
+		total, err := countSessions(a.db, filter)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		result.Total = &total
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// countSessions counts the sessions matching the filter
+func countSessions(db *sql.DB, filter SessionFilter) (int, error) {
+	where, args := filter.where()
+	var total int
+	if err := db.QueryRow(`SELECT count(*) FROM claude_sessions WHERE `+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return total, nil
+}
+
+// matchSnippet trims content to the text around the first case-insensitive match
+func matchSnippet(content, text string) string {
+	if text == "" || content == "" {
+		return ""
+	}
+	content = strings.Join(strings.Fields(content), " ")
+	i := strings.Index(strings.ToLower(content), strings.ToLower(text))
+	if i < 0 {
+		return truncateTitle(content, 2*snippetRadius)
+	}
+	start, end := max(i-snippetRadius, 0), min(i+len(text)+snippetRadius, len(content))
+	// Avoid cutting a multi-byte character in half
+	for start > 0 && !isRuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !isRuneStart(content[end]) {
+		end++
+	}
+	snippet := content[start:end]
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(content) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// sessionOutputFlags select the output format shared by the sessions subcommands
+func sessionOutputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{Name: "json", Usage: "Print JSON"},
+		&cli.BoolFlag{Name: "table", Usage: "Print an aligned table (default)"},
+		&cli.BoolFlag{Name: "csv", Usage: "Print CSV"},
+	}
+}
+
+// sessionFilterFlags are the filters accepted by `sessions list` and `sessions search`
+func sessionFilterFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{Name: "project", Usage: "Only sessions from this ~/.claude/projects directory"},
+		&cli.StringFlag{Name: "title", Usage: "Only sessions whose title contains this text"},
+		&cli.StringSliceFlag{Name: "tag", Usage: "Only sessions with this tag; repeat to require several"},
+		&cli.StringFlag{Name: "workspace", Usage: "Only sessions in this workspace"},
+		&cli.StringFlag{Name: "after", Usage: "Only sessions updated on or after this date (YYYY-MM-DD, RFC 3339, or an age such as 14d or 2w)"},
+		&cli.StringFlag{Name: "before", Usage: "Only sessions updated before this date (YYYY-MM-DD, RFC 3339, or an age such as 14d or 2w)"},
+		&cli.StringFlag{Name: "sort", Usage: "Order of the list: updated (default), updated_asc, created or created_asc"},
+		&cli.StringFlag{Name: "view", Usage: "Start from the filters of this saved view; other flags take precedence"},
+		&cli.IntFlag{Name: "limit", Value: 50, Usage: "Maximum number of sessions to print"},
+	}, sessionOutputFlags()...)
+}
+
+// sessionsCommand groups the session inspection subcommands
+func sessionsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "sessions",
+		Usage: "List, search and show synced sessions",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List sessions, most recently updated first",
+				Flags:  sessionFilterFlags(),
+				Action: sessionsListCommand,
+			},
+			{
+				Name:      "search",
+				Usage:     "Find sessions with a message containing text",
+				ArgsUsage: "<text>",
+				Flags:     sessionFilterFlags(),
+				Action:    sessionsListCommand,
+			},
+			{
+				Name:        "show",
+				Usage:       "Show a session and its messages",
+				ArgsUsage:   "[session_id]",
+				Description: "Session IDs may be shortened to a unique prefix of at least 4 characters. Without any, an interactive picker lists recent sessions to choose from.",
+				Flags:       sessionOutputFlags(),
+				Action:      sessionsShowCommand,
+			},
+			{
+				Name:        "context",
+				Usage:       "List the CLAUDE.md and settings files a session started with, or print one",
+				ArgsUsage:   "[session_id] [path]",
+				Description: "Files are snapshotted by sync --snapshot-context or sync.snapshot_context in claudemd.config.json.",
+				Flags:       sessionOutputFlags(),
+				Action:      sessionsContextCommand,
+			},
+		},
+	}
+}
+
+// outputFormat returns the format chosen by --json, --table or --csv
+func outputFormat(c *cli.Context) (string, error) {
+	format := ""
+	for _, name := range []string{"json", "table", "csv"} {
+		if !c.Bool(name) {
+			continue
+		}
+		if format != "" {
+			return "", fmt.Errorf("--%s and --%s cannot be combined", format, name)
+		}
+		format = name
+	}
+	if format == "" {
+		format = "table"
+	}
+	return format, nil
+}
+
+// sessionFilterFromFlags builds a filter from the list and search flags
+func sessionFilterFromFlags(c *cli.Context) (SessionFilter, error) {
+	filter := SessionFilter{
+		Project:   c.String("project"),
+		Query:     c.String("title"),
+		Tags:      c.StringSlice("tag"),
+		Sort:      c.String("sort"),
+		Workspace: c.String("workspace"),
+	}
+	var err error
+	if filter.Before, err = parseFilterTime(c.String("before"), time.UTC); err != nil {
+		return filter, fmt.Errorf("invalid --before: %w", err)
+	}
+	if filter.After, err = parseFilterTime(c.String("after"), time.UTC); err != nil {
+		return filter, fmt.Errorf("invalid --after: %w", err)
+	}
+	if err := validateSessionSort(filter.Sort); err != nil {
+		return filter, fmt.Errorf("invalid --sort: %w", err)
+	}
+	return filter, nil
+}
+
+// sessionsListCommand implements `sessions list` and `sessions search`
+func sessionsListCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	filter, err := sessionFilterFromFlags(c)
+	if err != nil {
+		return err
+	}
+	if c.Command.Name == "search" {
+		filter.Text = strings.Join(c.Args().Slice(), " ")
+		if filter.Text == "" {
+			return fmt.Errorf("search text is required")
+		}
+	}
+
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if name := c.String("view"); name != "" {
+		if filter, err = applyView(db, name, filter); err != nil {
+			return err
+		}
+	}
+	summaries, err := listSessionSummaries(db, filter, nil, c.Int("limit"))
+	if err != nil {
+		return err
+	}
+
+	header := []string{"SESSION", "UPDATED", "PROJECT", "MESSAGES", "TITLE"}
+	if filter.Text != "" {
+		header = append(header, "MATCH")
+	}
+	rows := make([][]string, len(summaries))
+	for i, s := range summaries {
+		rows[i] = []string{s.SessionID, s.UpdatedAt.Local().Format("2006-01-02 15:04"), s.Project, strconv.Itoa(s.Messages), s.Title}
+		if filter.Text != "" {
+			rows[i] = append(rows[i], s.Match)
+		}
+	}
+	return writeOutput(os.Stdout, format, summaries, header, rows)
+}
+
+// sessionsShowCommand prints one session. JSON includes the full session;
+// the table and CSV formats list its messages.
+func sessionsShowCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ids, err := sessionArgs(db, c.Args().Slice(), "Show")
+	if err != nil {
+		return err
+	}
+	session, err := loadSession(db, ids[0])
+	if err != nil {
+		return err
+	}
+
+	header := []string{"#", "TIME", "ROLE", "CONTENT"}
+	rows := make([][]string, 0, len(session.Messages))
+	for i, msg := range session.Messages {
+		content := msg.Content
+		if msg.Type == "summary" {
+			content = msg.Summary
+		}
+		if format == "table" {
+			content = truncateTitle(content, 100)
+		}
+		rows = append(rows, []string{strconv.Itoa(i), msg.Timestamp, messageRole(msg), content})
+	}
+
+	if format == "table" {
+		fmt.Printf("%s\n%s · %d messages · updated %s\n\n", session.Title, session.SessionID,
+			len(session.Messages), session.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return writeOutput(os.Stdout, format, session, header, rows)
+}
+
+// writeOutput prints value as JSON, or rows as an aligned table or CSV
+func writeOutput(w io.Writer, format string, value interface{}, header []string, rows [][]string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = strings.Join(strings.Fields(cell), " ")
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SinkConfig is a destination synced sessions are mirrored to next to the
+// database in database_url. Each sink has its own queue and retries, so one
+// that is down never holds up the others.
+type SinkConfig struct {
+	// Name identifies the sink in logs and its state file; the type by default
+	Name string `json:"name,omitempty"`
+	// Type is database, webhook or archive
+	Type string `json:"type"`
+	// URL is the database_url of a database sink or the endpoint of a webhook
+	URL string `json:"url,omitempty"`
+	// Headers are sent with every webhook request, such as an API key
+	Headers map[string]string `json:"headers,omitempty"`
+	// Dir is where an archive sink writes gzipped session JSON
+	Dir string `json:"dir,omitempty"`
+}
+
+// sinkNamePattern keeps sink names usable as file names
+var sinkNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+const (
+	// mirrorRetryMin and mirrorRetryMax bound the backoff of a failing sink
+	mirrorRetryMin = 2 * time.Second
+	mirrorRetryMax = 5 * time.Minute
+	// mirrorFlushTimeout is how long a one-time sync waits for its sinks
+	mirrorFlushTimeout = time.Minute
+)
+
+// validateSinks checks the sink settings and fills in their names
+func validateSinks(configs []SinkConfig) error {
+	names := map[string]bool{}
+	for i := range configs {
+		sc := &configs[i]
+		if sc.Name == "" {
+			sc.Name = sc.Type
+		}
+		if !sinkNamePattern.MatchString(sc.Name) {
+			return fmt.Errorf("sinks[%d]: name must be lowercase letters, digits, - or _, got %q", i, sc.Name)
+		}
+		if names[sc.Name] {
+			return fmt.Errorf("sinks[%d]: name %q is used twice; give each sink a name", i, sc.Name)
+		}
+		names[sc.Name] = true
+		switch sc.Type {
+		case "database":
+			if _, _, err := storageDriverFor(sc.URL); err != nil || sc.URL == "" {
+				return fmt.Errorf("sinks[%d]: database sinks need a postgres:// or mysql:// url", i)
+			}
+		case "webhook":
+			if !strings.HasPrefix(sc.URL, "http://") && !strings.HasPrefix(sc.URL, "https://") {
+				return fmt.Errorf("sinks[%d]: webhook sinks need an http or https url", i)
+			}
+		case "archive":
+			if sc.Dir == "" {
+				return fmt.Errorf("sinks[%d]: archive sinks need a dir", i)
+			}
+		default:
+			return fmt.Errorf("sinks[%d]: unknown type %q (valid: database, webhook, archive)", i, sc.Type)
+		}
+	}
+	return nil
+}
+
+// openSink returns a function connecting to the sink. Connections are made
+// by the sink's own worker, so a sink that is down at startup is retried.
+func openSink(sc SinkConfig, config *Config) func() (sessionSink, error) {
+	switch sc.Type {
+	case "database":
+		return func() (sessionSink, error) {
+			db, err := InitializeDatabase(&Config{DatabaseURL: sc.URL, Database: config.Database})
+			if err != nil {
+				return nil, err
+			}
+			return storageDriverOf(db).Sink(db), nil
+		}
+	case "webhook":
+		return func() (sessionSink, error) {
+			return webhookSink{url: sc.URL, headers: sc.Headers, client: &http.Client{Timeout: 30 * time.Second}}, nil
+		}
+	default:
+		return func() (sessionSink, error) {
+			return archiveSink{dir: sc.Dir}, os.MkdirAll(sc.Dir, 0755)
+		}
+	}
+}
+
+// mirrorOp is a write queued for a sink. Ops with the same key replace each
+// other, and one whose version the sink already holds is skipped.
+type mirrorOp struct {
+	key     string
+	version string
+	apply   func(sessionSink) error
+}
+
+// SinkStatus reports how far behind a sink is
+type SinkStatus struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Pending   int    `json:"pending"`
+	Delivered int    `json:"delivered"`
+	// Failures counts failed attempts since the last success
+	Failures      int        `json:"failures"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	NextRetryAt   *time.Time `json:"next_retry_at,omitempty"`
+}
+
+// sinkMirror feeds one sink from its own queue. What the sink holds is
+// recorded in a state file, so a restart only sends what changed.
+type sinkMirror struct {
+	config    SinkConfig
+	open      func() (sessionSink, error)
+	statePath string
+
+	mu        sync.Mutex
+	sink      sessionSink
+	pending   map[string]mirrorOp
+	order     []string
+	delivered map[string]string
+	dirty     bool
+	status    SinkStatus
+	wake      chan struct{}
+	idle      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+// mirrorSet is every sink a sync mirrors to
+type mirrorSet []*sinkMirror
+
+// activeMirrors are the sinks of the running sync, reported by /metrics
+// and the daemon dashboard
+var (
+	activeMirrorsMu sync.Mutex
+	activeMirrors   mirrorSet
+)
+
+// startMirrors starts a worker for each configured sink
+func startMirrors(configs []SinkConfig, config *Config) (mirrorSet, error) {
+	// Names are filled in on a copy, so reloads compare the file as written
+	configs = append([]SinkConfig(nil), configs...)
+	if err := validateSinks(configs); err != nil {
+		return nil, err
+	}
+	stateDir := ""
+	if dir, err := os.UserCacheDir(); err == nil {
+		stateDir = filepath.Join(dir, "claudemd", "sinks")
+	}
+
+	mirrors := make(mirrorSet, 0, len(configs))
+	for _, sc := range configs {
+		m := &sinkMirror{
+			config:    sc,
+			open:      openSink(sc, config),
+			pending:   map[string]mirrorOp{},
+			delivered: map[string]string{},
+			status:    SinkStatus{Name: sc.Name, Type: sc.Type},
+			wake:      make(chan struct{}, 1),
+			done:      make(chan struct{}),
+			stopped:   make(chan struct{}),
+		}
+		if stateDir != "" {
+			m.statePath = filepath.Join(stateDir, sinkStateName(sc))
+			m.loadState()
+		}
+		go m.run()
+		mirrors = append(mirrors, m)
+		log.Printf("Mirroring sessions to %s sink %s", sc.Type, sc.Name)
+	}
+
+	activeMirrorsMu.Lock()
+	activeMirrors = mirrors
+	activeMirrorsMu.Unlock()
+	return mirrors, nil
+}
+
+// sinkStateName names a sink's state file after its name and destination,
+// so projects whose sinks share a name never share what was delivered
+func sinkStateName(sc SinkConfig) string {
+	destination := sc.URL
+	if sc.Type == "archive" {
+		destination, _ = filepath.Abs(sc.Dir)
+	}
+	sum := sha256.Sum256([]byte(sc.Type + "\x00" + destination))
+	return sc.Name + "-" + hex.EncodeToString(sum[:6]) + ".json"
+}
+
+// mirrorStatuses reports the sinks of the running sync
+func mirrorStatuses() []SinkStatus {
+	activeMirrorsMu.Lock()
+	mirrors := activeMirrors
+	activeMirrorsMu.Unlock()
+
+	statuses := make([]SinkStatus, len(mirrors))
+	for i, m := range mirrors {
+		m.mu.Lock()
+		statuses[i] = m.status
+		statuses[i].Pending = len(m.pending)
+		statuses[i].Delivered = len(m.delivered)
+		m.mu.Unlock()
+	}
+	return statuses
+}
+
+// enqueue queues op on every sink
+func (ms mirrorSet) enqueue(op mirrorOp) {
+	for _, m := range ms {
+		m.enqueue(op)
+	}
+}
+
+// enqueueSession queues a prepared session and the data derived from it
+func (ms mirrorSet) enqueueSession(session ClaudeSession, contextFiles []ContextFile) {
+	if len(ms) == 0 {
+		return
+	}
+	ms.enqueue(mirrorOp{
+		key:     "session/" + session.SessionID,
+		version: session.ContentHash,
+		apply: func(sink sessionSink) error {
+			if store, ok := sink.(contextFileStore); ok && len(contextFiles) > 0 {
+				if err := store.StoreContextFiles(contextFiles); err != nil {
+					return err
+				}
+			}
+			if err := sink.UpsertSession(session); err != nil {
+				return err
+			}
+			storeDerivedData(sink, &session)
+			return nil
+		},
+	})
+}
+
+// enqueueSourceFile queues an extra source file, or its deletion
+func (ms mirrorSet) enqueueSourceFile(file SourceFile, deleted bool) {
+	op := mirrorOp{key: "source/" + file.Source + "/" + file.Name, version: "deleted"}
+	if deleted {
+		op.apply = func(sink sessionSink) error { return sink.DeleteSourceFile(file.Source, file.Name) }
+	} else {
+		sum := sha256.Sum256([]byte(file.Content))
+		op.version = hex.EncodeToString(sum[:])
+		op.apply = func(sink sessionSink) error { return sink.StoreSourceFile(file) }
+	}
+	ms.enqueue(op)
+}
+
+// enqueueSourceRemoved queues the removal of a session's file
+func (ms mirrorSet) enqueueSourceRemoved(sessionID, sourceFile string, softDelete bool) {
+	ms.enqueue(mirrorOp{
+		key:     "removed/" + sessionID,
+		version: fmt.Sprintf("%s|%t", sourceFile, softDelete),
+		apply: func(sink sessionSink) error {
+			_, err := sink.MarkSourceRemoved(sessionID, sourceFile, softDelete)
+			return err
+		},
+	})
+}
+
+// flush waits until every sink has caught up or timeout passes, then stops
+// the workers. Writes still pending are sent by the next sync.
+func (ms mirrorSet) flush(timeout time.Duration) {
+	// A closed Done channel stops the wait for every sink, not only the first
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, m := range ms {
+		m.mu.Lock()
+		idle := make(chan struct{})
+		if len(m.pending) == 0 {
+			close(idle)
+		} else {
+			m.idle = idle
+		}
+		m.mu.Unlock()
+		select {
+		case <-idle:
+		case <-ctx.Done():
+		}
+	}
+	ms.close()
+}
+
+// close stops the workers and saves what each sink holds
+func (ms mirrorSet) close() {
+	for _, m := range ms {
+		select {
+		case <-m.done:
+			continue
+		default:
+			close(m.done)
+		}
+		<-m.stopped
+		m.mu.Lock()
+		if n := len(m.pending); n > 0 {
+			log.Printf("Sink %s has %d writes pending, sent on the next sync", m.config.Name, n)
+		}
+		m.saveState()
+		m.mu.Unlock()
+	}
+}
+
+func (m *sinkMirror) enqueue(op mirrorOp) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, queued := m.pending[op.key]; !queued {
+		if m.delivered[op.key] == op.version {
+			return
+		}
+		m.order = append(m.order, op.key)
+	}
+	m.pending[op.key] = op
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for the oldest pending op
+func (m *sinkMirror) next() (mirrorOp, bool) {
+	for {
+		m.mu.Lock()
+		for len(m.order) > 0 {
+			key := m.order[0]
+			if op, ok := m.pending[key]; ok {
+				m.mu.Unlock()
+				return op, true
+			}
+			m.order = m.order[1:]
+		}
+		if m.idle != nil {
+			close(m.idle)
+			m.idle = nil
+		}
+		if m.dirty {
+			m.saveState()
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-m.wake:
+		case <-m.done:
+			return mirrorOp{}, false
+		}
+	}
+}
+
+// run applies ops in order, backing off while the sink fails
+func (m *sinkMirror) run() {
+	defer close(m.stopped)
+	for {
+		op, ok := m.next()
+		if !ok {
+			return
+		}
+		err := m.apply(op)
+		m.mu.Lock()
+		now := time.Now()
+		if err == nil {
+			// A newer version queued meanwhile stays pending
+			if m.pending[op.key].version == op.version {
+				delete(m.pending, op.key)
+				m.order = m.order[1:]
+			}
+			m.delivered[op.key] = op.version
+			m.dirty = true
+			if m.status.Failures > 0 {
+				log.Printf("Sink %s recovered after %d failed attempts", m.config.Name, m.status.Failures)
+			}
+			m.status.Failures, m.status.LastError, m.status.NextRetryAt = 0, "", nil
+			m.status.LastSuccessAt = &now
+			m.mu.Unlock()
+			continue
+		}
+
+		m.status.Failures++
+		m.status.LastError = err.Error()
+		wait := min(mirrorRetryMin<<min(m.status.Failures-1, 16), mirrorRetryMax)
+		retryAt := now.Add(wait)
+		m.status.NextRetryAt = &retryAt
+		if m.status.Failures == 1 {
+			log.Printf("Sink %s failed, retrying with backoff: %v", m.config.Name, err)
+		}
+		m.mu.Unlock()
+		select {
+		case <-time.After(wait):
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// apply connects to the sink if needed and runs op against it
+func (m *sinkMirror) apply(op mirrorOp) (err error) {
+	_, span := tracer.Start(context.Background(), "mirror", trace.WithAttributes(
+		attribute.String("claudemd.sink", m.config.Name),
+		attribute.String("claudemd.key", op.key),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if m.sink == nil {
+		sink, err := m.open()
+		if err != nil {
+			return err
+		}
+		m.sink = sink
+	}
+	return op.apply(m.sink)
+}
+
+// loadState reads what the sink held when the last sync stopped
+func (m *sinkMirror) loadState() {
+	data, err := os.ReadFile(m.statePath)
+	if err != nil {
+		return
+	}
+	var state struct {
+		Delivered map[string]string `json:"delivered"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Ignoring unreadable state of sink %s: %v", m.config.Name, err)
+		return
+	}
+	if state.Delivered != nil {
+		m.delivered = state.Delivered
+	}
+}
+
+// saveState records what the sink holds. The caller holds m.mu.
+func (m *sinkMirror) saveState() {
+	if m.statePath == "" {
+		return
+	}
+	data, err := json.Marshal(map[string]interface{}{"delivered": m.delivered})
+	if err == nil {
+		err = writeFileReplacing(m.statePath, data)
+	}
+	if err != nil {
+		log.Printf("Failed to save state of sink %s: %v", m.config.Name, err)
+		return
+	}
+	m.dirty = false
+}
+
+// writeFileReplacing writes data to path through a temporary file, creating
+// the directory if needed, so readers never see a partial file
+func writeFileReplacing(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// webhookSink posts every write as JSON to a URL
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// webhookEvent is the body of every webhook request
+type webhookEvent struct {
+	Event  string      `json:"event"`
+	SentAt time.Time   `json:"sent_at"`
+	Data   interface{} `json:"data"`
+}
+
+func (w webhookSink) post(event string, data interface{}) error {
+	body, err := json.Marshal(webhookEvent{Event: event, SentAt: time.Now(), Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", event, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claudemd")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post %s: %w", event, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s to %s", resp.Status, event)
+	}
+	return nil
+}
+
+func (w webhookSink) UpsertSession(session ClaudeSession) error {
+	return w.post("session.upsert", session)
+}
+
+func (w webhookSink) StoreTodos(sessionID string, todos []SessionTodo) error {
+	return w.post("session.todos", map[string]interface{}{"session_id": sessionID, "todos": todos})
+}
+
+func (w webhookSink) StoreFileManifest(sessionID string, files []SessionFile) error {
+	return w.post("session.files", map[string]interface{}{"session_id": sessionID, "files": files})
+}
+
+func (w webhookSink) MarkSourceRemoved(sessionID, sourceFile string, softDelete bool) (bool, error) {
+	err := w.post("session.source_removed", map[string]interface{}{
+		"session_id": sessionID, "source_file": sourceFile, "soft_delete": softDelete,
+	})
+	return err == nil, err
+}
+
+func (w webhookSink) StoreSourceFile(file SourceFile) error {
+	return w.post("source_file.upsert", file)
+}
+
+func (w webhookSink) DeleteSourceFile(source, name string) error {
+	return w.post("source_file.delete", map[string]string{"source": source, "name": name})
+}
+
+// archiveSink keeps a gzipped JSON copy of every session under a directory.
+// Nothing is ever removed from it; todos and file manifests are derived
+// from the messages and not written.
+type archiveSink struct {
+	dir string
+}
+
+func (a archiveSink) write(rel string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", rel, err)
+	}
+	compressed, err := compressSnapshot(data)
+	if err != nil {
+		return err
+	}
+	if err := writeFileReplacing(filepath.Join(a.dir, rel), compressed); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", rel, err)
+	}
+	return nil
+}
+
+func (a archiveSink) UpsertSession(session ClaudeSession) error {
+	return a.write(filepath.Join("sessions", session.SessionID+".json.gz"), session)
+}
+
+func (a archiveSink) StoreTodos(sessionID string, todos []SessionTodo) error { return nil }
+
+func (a archiveSink) StoreFileManifest(sessionID string, files []SessionFile) error { return nil }
+
+func (a archiveSink) MarkSourceRemoved(sessionID, sourceFile string, softDelete bool) (bool, error) {
+	return false, nil
+}
+
+func (a archiveSink) StoreSourceFile(file SourceFile) error {
+	if strings.ContainsAny(file.Name, `/\`) || strings.HasPrefix(file.Name, ".") {
+		return nil
+	}
+	return a.write(filepath.Join("sources", file.Source, file.Name+".gz"), file)
+}
+
+func (a archiveSink) DeleteSourceFile(source, name string) error { return nil }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// storeRawSnapshot saves a gzip-compressed copy of the raw session file.
+// The write is skipped when the stored checksum already matches.
+func (c *ClaudeSessionSync) storeRawSnapshot(sessionID, filePath string, data []byte) error {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	var existing string
+	err := c.db.QueryRow(`SELECT sha256 FROM claude_session_raw WHERE session_id = $1`, sessionID).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read existing snapshot: %w", err)
+	}
+	if existing == checksum {
+		return nil
+	}
+
+	relPath, err := filepath.Rel(filepath.Join(c.claudeDir, "projects"), filePath)
+	if err != nil {
+		relPath = filepath.Base(filePath)
+	}
+
+	compressed, err := compressSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO claude_session_raw (session_id, relative_path, content, size, sha256, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (session_id) DO UPDATE SET
+			relative_path = EXCLUDED.relative_path,
+			content = EXCLUDED.content,
+			size = EXCLUDED.size,
+			sha256 = EXCLUDED.sha256,
+			updated_at = EXCLUDED.updated_at`
+
+	if _, err := c.db.Exec(query, sessionID, filepath.ToSlash(relPath), compressed, len(data), checksum); err != nil {
+		return fmt.Errorf("failed to upsert snapshot: %w", err)
+	}
+	return nil
+}
+
+// compressSnapshot gzips the contents of a session file for storage
+func compressSnapshot(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	return compressed.Bytes(), nil
+}
+
+// decompressSnapshot inflates a stored snapshot and verifies its checksum
+func decompressSnapshot(content []byte, checksum string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+	return data, nil
+}
+
+// restoreCommand writes raw snapshots from the database back to disk
+func restoreCommand(c *cli.Context) error {
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := InitializeDatabase(config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+	if err := requirePostgres(db); err != nil {
+		return err
+	}
+
+	claudeDir := c.String("dir")
+	if claudeDir == "" {
+		if claudeDir, err = defaultClaudeDir(); err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+	}
+	projectsDir := filepath.Join(claudeDir, "projects")
+
+	query := `SELECT session_id, relative_path, content, sha256 FROM claude_session_raw`
+	var args []interface{}
+	if id := c.String("session"); id != "" {
+		query += ` WHERE session_id = $1`
+		args = append(args, id)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	restored, skipped := 0, 0
+	for rows.Next() {
+		var sessionID, relPath, checksum string
+		var content []byte
+		if err := rows.Scan(&sessionID, &relPath, &content, &checksum); err != nil {
+			return fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+
+		target := filepath.Join(projectsDir, filepath.FromSlash(relPath))
+		if !isWithinDir(projectsDir, target) {
+			log.Printf("Skipping session %s: invalid path %s", sessionID, relPath)
+			skipped++
+			continue
+		}
+		if _, err := os.Stat(target); err == nil && !c.Bool("force") {
+			log.Printf("Skipping %s: file exists (use --force to overwrite)", target)
+			skipped++
+			continue
+		}
+
+		data, err := decompressSnapshot(content, checksum)
+		if err != nil {
+			log.Printf("Skipping session %s: %v", sessionID, err)
+			skipped++
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		restored++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read snapshots: %w", err)
+	}
+
+	fmt.Printf("✅ Restored %d session files (%d skipped) into %s\n", restored, skipped, projectsDir)
+	return nil
+}
+
+// isWithinDir reports whether path is located inside dir
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !filepath.IsAbs(rel) && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
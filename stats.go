@@ -0,0 +1,172 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// recentLimit caps how many recent sessions and builds are remembered
+const recentLimit = 10
+
+// SyncRecord describes a single completed session sync
+type SyncRecord struct {
+	SessionID string    `json:"session_id"`
+	Messages  int       `json:"messages"`
+	At        time.Time `json:"at"`
+}
+
+// BuildRecord describes a single esbuild invocation
+type BuildRecord struct {
+	Path     string        `json:"path"`
+	Duration time.Duration `json:"duration"`
+	Failed   bool          `json:"failed"`
+	At       time.Time     `json:"at"`
+}
+
+// EndpointStats aggregates requests served by a single route pattern
+type EndpointStats struct {
+	Count  int
+	Errors int
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// RuntimeStats collects health counters shared by the sync loop and the dev server
+type RuntimeStats struct {
+	mu sync.Mutex
+
+	StartedAt  time.Time
+	QueueDepth int
+	// QueueCoalesced counts changes to files that were already queued
+	QueueCoalesced int
+	// QueueDropped counts watcher events dropped because the queue or the
+	// kernel buffer was full; the files are picked up by a rescan
+	QueueDropped int
+	SyncCount    int
+	SyncErrors   int
+	BuildCount   int
+	BuildErrors  int
+	DBLatency    time.Duration
+	DBLatencyAvg time.Duration
+	dbSamples    int
+
+	// SyncUnchanged counts syncs skipped because the stored session matched
+	SyncUnchanged int
+
+	RecentSyncs  []SyncRecord
+	RecentBuilds []BuildRecord
+	Endpoints    map[string]EndpointStats
+}
+
+// stats is the process-wide runtime stats instance
+var stats = &RuntimeStats{StartedAt: time.Now()}
+
+// SetQueueDepth records the number of pending sync events
+func (s *RuntimeStats) SetQueueDepth(depth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.QueueDepth = depth
+}
+
+// RecordQueueEvent records a watcher event that was merged into an already
+// queued sync or dropped
+func (s *RuntimeStats) RecordQueueEvent(dropped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dropped {
+		s.QueueDropped++
+	} else {
+		s.QueueCoalesced++
+	}
+}
+
+// RecordSync records the outcome of a session sync
+func (s *RuntimeStats) RecordSync(sessionID string, messages int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.SyncErrors++
+		return
+	}
+	s.SyncCount++
+	s.RecentSyncs = appendRecent(s.RecentSyncs, SyncRecord{SessionID: sessionID, Messages: messages, At: time.Now()})
+}
+
+// RecordSyncUnchanged records a sync that found the stored session current
+func (s *RuntimeStats) RecordSyncUnchanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SyncUnchanged++
+}
+
+// RecordBuild records an esbuild invocation
+func (s *RuntimeStats) RecordBuild(path string, duration time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.BuildCount++
+	if failed {
+		s.BuildErrors++
+	}
+	s.RecentBuilds = appendRecent(s.RecentBuilds, BuildRecord{Path: path, Duration: duration, Failed: failed, At: time.Now()})
+}
+
+// RecordEndpoint records a request served by the route pattern
+func (s *RuntimeStats) RecordEndpoint(pattern string, duration time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Endpoints == nil {
+		s.Endpoints = make(map[string]EndpointStats)
+	}
+	e := s.Endpoints[pattern]
+	e.Count++
+	if failed {
+		e.Errors++
+	}
+	e.Total += duration
+	e.Max = max(e.Max, duration)
+	s.Endpoints[pattern] = e
+}
+
+// RecordDBLatency records the round trip time of a database write
+func (s *RuntimeStats) RecordDBLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.DBLatency = d
+	s.dbSamples++
+	s.DBLatencyAvg += (d - s.DBLatencyAvg) / time.Duration(s.dbSamples)
+}
+
+// Snapshot returns a copy of the current stats that is safe to read
+func (s *RuntimeStats) Snapshot() RuntimeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := make(map[string]EndpointStats, len(s.Endpoints))
+	for pattern, e := range s.Endpoints {
+		endpoints[pattern] = e
+	}
+	return RuntimeStats{
+		StartedAt:      s.StartedAt,
+		QueueDepth:     s.QueueDepth,
+		QueueCoalesced: s.QueueCoalesced,
+		QueueDropped:   s.QueueDropped,
+		SyncCount:      s.SyncCount,
+		SyncErrors:     s.SyncErrors,
+		SyncUnchanged:  s.SyncUnchanged,
+		BuildCount:     s.BuildCount,
+		BuildErrors:    s.BuildErrors,
+		DBLatency:      s.DBLatency,
+		DBLatencyAvg:   s.DBLatencyAvg,
+		RecentSyncs:    append([]SyncRecord(nil), s.RecentSyncs...),
+		RecentBuilds:   append([]BuildRecord(nil), s.RecentBuilds...),
+		Endpoints:      endpoints,
+	}
+}
+
+// appendRecent appends to a bounded history, dropping the oldest entries
+func appendRecent[T any](list []T, item T) []T {
+	list = append(list, item)
+	if len(list) > recentLimit {
+		list = list[len(list)-recentLimit:]
+	}
+	return list
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// errSessionNotFound is returned when no session row matches the requested ID
+var errSessionNotFound = errors.New("session not found")
+
+// openConfiguredDatabase loads the config and opens the database it points at
+func openConfiguredDatabase() (*sql.DB, *Config, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	db, err := InitializeDatabase(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	if err := requirePostgres(db); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, config, nil
+}
+
+// loadSession reads a single session, including its messages, by session ID
+func loadSession(db *sql.DB, sessionID string) (*ClaudeSession, error) {
+	query := `
+		SELECT id, session_id, user_id, title, messages, metadata, created_at, updated_at, workspace,
+		       started_at, ended_at, duration_ms
+		FROM claude_sessions
+		WHERE session_id = $1 AND deleted_at IS NULL`
+
+	var session ClaudeSession
+	var messagesJSON, metadataJSON []byte
+	err := db.QueryRow(query, sessionID).Scan(
+		&session.ID, &session.SessionID, &session.UserID, &session.Title,
+		&messagesJSON, &metadataJSON, &session.CreatedAt, &session.UpdatedAt, &session.Workspace,
+		&session.StartedAt, &session.EndedAt, &session.DurationMS,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	if err := json.Unmarshal(messagesJSON, &session.Messages); err != nil {
+		return nil, fmt.Errorf("failed to decode messages: %w", err)
+	}
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &session.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	return &session, nil
+}
+
+// SessionFilter selects sessions by ID, project, title, content, tag and age
+type SessionFilter struct {
+	IDs []string
+	// Project is a directory name under ~/.claude/projects
+	Project string
+	// Query matches a case-insensitive substring of the title
+	Query string
+	// Text matches a case-insensitive substring of any message
+	Text string
+	// Tags must all be entries of the session's metadata tags
+	Tags []string
+	// Workspace is the slug of the workspace sessions belong to
+	Workspace      string
+	Before         time.Time
+	After          time.Time
+	IncludeDeleted bool
+	// Sort orders the session list, one of sessionSorts; updated by default
+	Sort string
+}
+
+// empty reports whether the filter would match every session
+func (f SessionFilter) empty() bool {
+	return len(f.IDs) == 0 && f.Project == "" && f.Query == "" && f.Text == "" && len(f.Tags) == 0 && f.Workspace == "" &&
+		f.Before.IsZero() && f.After.IsZero()
+}
+
+// where builds the SQL condition for the filter, numbering parameters from 1
+func (f SessionFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if len(f.IDs) > 0 {
+		add("session_id = ANY($%d)", pq.Array(f.IDs))
+	}
+	if f.Project != "" {
+		add("strpos(metadata->>'source_file', '/projects/' || $%d || '/') > 0", f.Project)
+	}
+	if f.Query != "" {
+		add("strpos(lower(title), lower($%d)) > 0", f.Query)
+	}
+	if f.Text != "" {
+		add("EXISTS (SELECT 1 FROM jsonb_array_elements(messages) AS m WHERE strpos(lower(m->>'content'), lower($%d)) > 0)", f.Text)
+	}
+	if len(f.Tags) > 0 {
+		add("metadata->'tags' ?& $%d", pq.Array(f.Tags))
+	}
+	if f.Workspace != "" {
+		add("workspace = $%d", f.Workspace)
+	}
+	if !f.Before.IsZero() {
+		add("updated_at < $%d", f.Before)
+	}
+	if !f.After.IsZero() {
+		add("updated_at >= $%d", f.After)
+	}
+	if !f.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+
+	if len(conds) == 0 {
+		return "TRUE", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// sessionFilterFromQuery reads id, project, q, text, tag, workspace, before,
+// after and sort query parameters. Plain dates are days in the tz time zone.
+func sessionFilterFromQuery(q url.Values) (SessionFilter, error) {
+	filter := SessionFilter{Project: q.Get("project"), Query: q.Get("q"), Text: q.Get("text"), Workspace: q.Get("workspace"), Sort: q.Get("sort")}
+	filter.IDs = splitQueryList(q["id"])
+	filter.Tags = splitQueryList(q["tag"])
+	if err := validateSessionSort(filter.Sort); err != nil {
+		return filter, err
+	}
+
+	loc, err := locationFromQuery(q)
+	if err != nil {
+		return filter, err
+	}
+	if filter.Before, err = parseFilterTime(q.Get("before"), loc); err != nil {
+		return filter, fmt.Errorf("invalid before: %w", err)
+	}
+	if filter.After, err = parseFilterTime(q.Get("after"), loc); err != nil {
+		return filter, fmt.Errorf("invalid after: %w", err)
+	}
+	return filter, nil
+}
+
+// splitQueryList reads a repeatable query parameter whose values may also
+// be comma separated
+func splitQueryList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+	}
+	return list
+}
+
+// parseFilterTime accepts RFC 3339 timestamps, plain dates, which start at
+// midnight in loc, and ages such as 14d or 2w, counted back from now
+func parseFilterTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := parseAgeDays(value); ok {
+		return time.Now().AddDate(0, 0, -days), nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// parseAgeDays reads an age in days (7d) or weeks (2w)
+func parseAgeDays(value string) (int, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	switch value[len(value)-1] {
+	case 'd':
+		return n, true
+	case 'w':
+		return 7 * n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Environment variables read in Supabase mode. Secrets are never read from
+// the config file.
+const (
+	supabaseURLEnv         = "SUPABASE_URL"
+	supabaseServiceKeyEnv  = "SUPABASE_SERVICE_KEY"
+	supabaseDBPasswordEnv  = "SUPABASE_DB_PASSWORD"
+	supabaseAccessTokenEnv = "SUPABASE_ACCESS_TOKEN"
+	supabaseManagementURL  = "https://api.supabase.com/v1"
+)
+
+// SupabaseConfig connects to a Supabase project without a hand-built
+// database_url. Any field left empty is filled from the environment.
+type SupabaseConfig struct {
+	// URL is the project URL, https://<ref>.supabase.co
+	URL string `json:"url,omitempty"`
+	// Mode is postgres to connect through the connection pooler, or rest to
+	// write sessions through PostgREST with the service key. When empty,
+	// postgres is used if SUPABASE_DB_PASSWORD is set.
+	Mode string `json:"mode,omitempty"`
+	// PoolMode is session (default) or transaction
+	PoolMode string `json:"pool_mode,omitempty"`
+	// Region is used to build the pooler host when SUPABASE_ACCESS_TOKEN is
+	// not available to look it up
+	Region string `json:"region,omitempty"`
+}
+
+// enabled reports whether a Supabase project is configured
+func (s SupabaseConfig) enabled() bool {
+	return s.URL != ""
+}
+
+// restMode reports whether sessions are written through PostgREST
+func (s SupabaseConfig) restMode() bool {
+	if !s.enabled() {
+		return false
+	}
+	if s.Mode != "" {
+		return s.Mode == "rest"
+	}
+	return os.Getenv(supabaseDBPasswordEnv) == ""
+}
+
+// validate checks the mode settings and fills the URL from SUPABASE_URL
+func (s *SupabaseConfig) validate() error {
+	if s.URL == "" {
+		s.URL = os.Getenv(supabaseURLEnv)
+	}
+	s.URL = strings.TrimRight(s.URL, "/")
+	switch s.Mode {
+	case "", "postgres", "rest":
+	default:
+		return fmt.Errorf("unknown supabase.mode %q (valid: postgres, rest)", s.Mode)
+	}
+	switch s.PoolMode {
+	case "", "session", "transaction":
+	default:
+		return fmt.Errorf("unknown supabase.pool_mode %q (valid: session, transaction)", s.PoolMode)
+	}
+	if s.enabled() {
+		if _, err := s.projectRef(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectRef extracts the project reference from the project URL
+func (s SupabaseConfig) projectRef() (string, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid supabase url %q", s.URL)
+	}
+	ref, ok := strings.CutSuffix(u.Hostname(), ".supabase.co")
+	if !ok || ref == "" || strings.Contains(ref, ".") {
+		return "", fmt.Errorf("supabase url %q is not a https://<ref>.supabase.co project URL", s.URL)
+	}
+	return ref, nil
+}
+
+// supabaseConnectionString builds a pooled connection string for the
+// project. The pooler host comes from the management API when an access
+// token is set, then from the configured region, and otherwise the direct
+// database host is used.
+func supabaseConnectionString(s SupabaseConfig) (string, error) {
+	ref, err := s.projectRef()
+	if err != nil {
+		return "", err
+	}
+	password := os.Getenv(supabaseDBPasswordEnv)
+	if password == "" {
+		return "", fmt.Errorf("%s is required to connect to the Supabase database", supabaseDBPasswordEnv)
+	}
+
+	// The migration lock is held per connection, which only session mode keeps
+	port := "5432"
+	if s.PoolMode == "transaction" {
+		port = "6543"
+	}
+	host, user := "", "postgres."+ref
+
+	if api := newSupabaseManagement(ref); api != nil {
+		pooler, err := api.pooler()
+		if err != nil {
+			log.Printf("Failed to look up Supabase pooler, falling back: %v", err)
+		} else {
+			host, user = pooler.Host, pooler.User
+		}
+	}
+	if host == "" && s.Region != "" {
+		host = fmt.Sprintf("aws-0-%s.pooler.supabase.com", s.Region)
+	}
+	if host == "" {
+		host, user, port = "db."+ref+".supabase.co", "postgres", "5432"
+		log.Printf("Connecting to the direct Supabase host; set supabase.region or %s to use the pooler", supabaseAccessTokenEnv)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     host + ":" + port,
+		Path:     "/postgres",
+		RawQuery: "sslmode=require",
+	}
+	return dsn.String(), nil
+}
+
+// supabaseManagement calls the Supabase management API for one project
+type supabaseManagement struct {
+	ref    string
+	token  string
+	client *http.Client
+}
+
+// newSupabaseManagement returns nil when SUPABASE_ACCESS_TOKEN is not set
+func newSupabaseManagement(ref string) *supabaseManagement {
+	token := os.Getenv(supabaseAccessTokenEnv)
+	if token == "" {
+		return nil
+	}
+	return &supabaseManagement{ref: ref, token: token, client: &http.Client{Timeout: time.Minute}}
+}
+
+func (m *supabaseManagement) do(method, path string, body, v interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, supabaseManagementURL+"/projects/"+m.ref+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("supabase management API returned %s: %s", resp.Status, detail)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// supabasePooler is the connection pooler of a project
+type supabasePooler struct {
+	Host string `json:"db_host"`
+	User string `json:"db_user"`
+	Type string `json:"database_type"`
+}
+
+// pooler looks up the pooler of the project's primary database
+func (m *supabaseManagement) pooler() (supabasePooler, error) {
+	var poolers []supabasePooler
+	if err := m.do("GET", "/config/database/pooler", nil, &poolers); err != nil {
+		return supabasePooler{}, err
+	}
+	for _, p := range poolers {
+		if p.Type == "PRIMARY" && p.Host != "" {
+			return p, nil
+		}
+	}
+	return supabasePooler{}, errors.New("project has no primary pooler")
+}
+
+// query runs SQL against the project database and decodes the result rows
+func (m *supabaseManagement) query(sql string, rows interface{}) error {
+	return m.do("POST", "/database/query", map[string]string{"query": sql}, rows)
+}
+
+// migrateSupabase applies pending migrations through the management API,
+// for REST mode where there is no database connection
+func migrateSupabase(s SupabaseConfig) (int, error) {
+	ref, err := s.projectRef()
+	if err != nil {
+		return 0, err
+	}
+	api := newSupabaseManagement(ref)
+	if api == nil {
+		return 0, fmt.Errorf("%s is required to set up the schema in Supabase REST mode", supabaseAccessTokenEnv)
+	}
+	migrations, err := loadMigrations(postgresDriver{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	var rows []appliedMigration
+	err = api.query(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		SELECT version, name FROM schema_migrations`, &rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[int]appliedMigration, len(rows))
+	for _, m := range rows {
+		applied[m.Version] = m
+	}
+	if err := checkSchemaNotNewer(migrations, applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		sql := fmt.Sprintf("BEGIN;\n%s;\nINSERT INTO schema_migrations (version, name) VALUES (%d, %s);\nCOMMIT;",
+			m.SQL, m.Version, pq.QuoteLiteral(m.Name))
+		if err := api.query(sql, nil); err != nil {
+			return count, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		count++
+	}
+	if count > 0 {
+		// PostgREST caches the schema and would not see the new tables yet
+		if err := api.query(`NOTIFY pgrst, 'reload schema'`, nil); err != nil {
+			log.Printf("Failed to reload the PostgREST schema cache: %v", err)
+		}
+	}
+	return count, nil
+}
+
+// supabaseRestSink writes sessions through PostgREST with the service key.
+// Unlike postgresSink, replacing todos and manifests is not atomic.
+type supabaseRestSink struct {
+	restURL string
+	key     string
+	client  *http.Client
+}
+
+// newSupabaseRestSink sets up the schema when an access token is available
+// and returns a sink for the project
+func newSupabaseRestSink(s SupabaseConfig) (*supabaseRestSink, error) {
+	key := os.Getenv(supabaseServiceKeyEnv)
+	if key == "" {
+		key = os.Getenv(defaultSupabaseEnv)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%s is required in Supabase REST mode", supabaseServiceKeyEnv)
+	}
+
+	if os.Getenv(supabaseAccessTokenEnv) != "" {
+		if _, err := migrateSupabase(s); err != nil {
+			return nil, fmt.Errorf("failed to set up Supabase schema: %w", err)
+		}
+	} else {
+		log.Printf("%s is not set; assuming the Supabase schema is already in place", supabaseAccessTokenEnv)
+	}
+
+	return &supabaseRestSink{
+		restURL: s.URL + "/rest/v1",
+		key:     key,
+		client:  &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// do sends a PostgREST request, decoding the response into out when it is not nil
+func (s *supabaseRestSink) do(method, path, prefer string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, s.restURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.key)
+	req.Header.Set("apikey", s.key)
+	req.Header.Set("Content-Type", "application/json")
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	stats.RecordDBLatency(time.Since(start))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("PostgREST returned %s: %s", resp.Status, detail)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// replaceRows deletes a session's rows from table and inserts rows in their place
+func (s *supabaseRestSink) replaceRows(table, sessionID string, rows interface{}, count int) error {
+	if err := s.do("DELETE", "/"+table+"?session_id=eq."+url.QueryEscape(sessionID), "", nil, nil); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", table, err)
+	}
+	if count == 0 {
+		return nil
+	}
+	if err := s.do("POST", "/"+table, "return=minimal,missing=default", rows, nil); err != nil {
+		return fmt.Errorf("failed to insert %s: %w", table, err)
+	}
+	return nil
+}
+
+func (s *supabaseRestSink) UpsertSession(session ClaudeSession) error {
+	// id and created_at are left to their column defaults so an update keeps them
+	times := sessionTimes(session.Messages)
+	row := struct {
+		SessionID string                 `json:"session_id"`
+		UserID    *string                `json:"user_id,omitempty"`
+		Title     string                 `json:"title"`
+		Messages  []SessionMessage       `json:"messages"`
+		Metadata  map[string]interface{} `json:"metadata"`
+		// Left out without a workspace so an update keeps the stored one
+		Workspace   string     `json:"workspace,omitempty"`
+		StartedAt   *time.Time `json:"started_at"`
+		EndedAt     *time.Time `json:"ended_at"`
+		DurationMS  *int64     `json:"duration_ms"`
+		ContentHash string     `json:"content_hash,omitempty"`
+	}{session.SessionID, session.UserID, session.Title, session.Messages, session.Metadata, session.Workspace,
+		times.StartedAt, times.EndedAt, times.DurationMS, session.ContentHash}
+
+	if err := s.do("POST", "/claude_sessions?on_conflict=session_id", "resolution=merge-duplicates,return=minimal", row, nil); err != nil {
+		return fmt.Errorf("failed to upsert session: %w", err)
+	}
+	return nil
+}
+
+// todoRow is a session_todos row as PostgREST expects it
+type todoRow struct {
+	SessionID   string           `json:"session_id"`
+	Key         string           `json:"todo_key"`
+	Position    int              `json:"position"`
+	Content     string           `json:"content"`
+	ActiveForm  string           `json:"active_form"`
+	Priority    string           `json:"priority"`
+	Status      string           `json:"status"`
+	Transitions []TodoTransition `json:"transitions"`
+	CreatedAt   *time.Time       `json:"created_at"`
+	UpdatedAt   *time.Time       `json:"updated_at"`
+	CompletedAt *time.Time       `json:"completed_at"`
+}
+
+func (s *supabaseRestSink) StoreTodos(sessionID string, todos []SessionTodo) error {
+	rows := make([]todoRow, len(todos))
+	for i, t := range todos {
+		rows[i] = todoRow{sessionID, t.Key, t.Position, t.Content, t.ActiveForm, t.Priority, t.Status,
+			t.Transitions, t.CreatedAt, t.UpdatedAt, t.CompletedAt}
+	}
+	return s.replaceRows("session_todos", sessionID, rows, len(rows))
+}
+
+func (s *supabaseRestSink) StoreFileManifest(sessionID string, files []SessionFile) error {
+	// SessionFile's JSON names match the session_files columns
+	return s.replaceRows("session_files", sessionID, files, len(files))
+}
+
+func (s *supabaseRestSink) StoreSourceFile(file SourceFile) error {
+	if err := s.do("POST", "/claude_source_files?on_conflict=source,name", "resolution=merge-duplicates,return=minimal", file, nil); err != nil {
+		return fmt.Errorf("failed to upsert source file: %w", err)
+	}
+	return nil
+}
+
+func (s *supabaseRestSink) DeleteSourceFile(source, name string) error {
+	return s.do("DELETE", "/claude_source_files?source=eq."+url.QueryEscape(source)+"&name=eq."+url.QueryEscape(name), "", nil, nil)
+}
+
+func (s *supabaseRestSink) MarkSourceRemoved(sessionID, sourceFile string, softDelete bool) (bool, error) {
+	// PostgREST cannot merge into a jsonb column, so the metadata is read first
+	filter := "?session_id=eq." + url.QueryEscape(sessionID) + "&metadata->>source_file=eq." + url.QueryEscape(sourceFile)
+	var rows []struct {
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if err := s.do("GET", "/claude_sessions"+filter+"&select=metadata", "", nil, &rows); err != nil {
+		return false, err
+	}
+	if len(rows) == 0 {
+		return false, nil
+	}
+	metadata := rows[0].Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	now := time.Now()
+	metadata["source_deleted_at"] = now.Format(time.RFC3339)
+	update := map[string]interface{}{"metadata": metadata}
+	if softDelete {
+		update["deleted_at"] = now
+	}
+	if err := s.do("PATCH", "/claude_sessions"+filter, "return=minimal", update, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// syncQueueSize bounds how many changed files wait for the database writer
+const syncQueueSize = 256
+
+// syncQueueWait is how long the watcher blocks on a full queue before it
+// drops the event and schedules a rescan instead
+const syncQueueWait = time.Second
+
+// syncQueue carries changed session files from the watcher to the goroutine
+// that writes them to the database, so a slow database delays writes
+// instead of stalling the watcher until fsnotify loses events. A file that
+// is already queued is not queued again, since one sync reads the whole file.
+type syncQueue struct {
+	files chan string
+	// rescan asks the writer to walk the projects directory for changes
+	// whose events were dropped
+	rescan chan struct{}
+
+	mu     sync.Mutex
+	queued map[string]bool
+}
+
+func newSyncQueue() *syncQueue {
+	return &syncQueue{
+		files:  make(chan string, syncQueueSize),
+		rescan: make(chan struct{}, 1),
+		queued: make(map[string]bool),
+	}
+}
+
+// Enqueue queues a changed file, blocking for up to syncQueueWait while the
+// queue is full
+func (q *syncQueue) Enqueue(path string) {
+	q.mu.Lock()
+	if q.queued[path] {
+		q.mu.Unlock()
+		stats.RecordQueueEvent(false)
+		return
+	}
+	q.queued[path] = true
+	q.mu.Unlock()
+
+	select {
+	case q.files <- path:
+		stats.SetQueueDepth(len(q.files))
+		return
+	default:
+	}
+
+	timer := time.NewTimer(syncQueueWait)
+	defer timer.Stop()
+	select {
+	case q.files <- path:
+		stats.SetQueueDepth(len(q.files))
+	case <-timer.C:
+		q.mu.Lock()
+		delete(q.queued, path)
+		q.mu.Unlock()
+		log.Printf("Sync queue full, dropped change to %s until the next rescan", path)
+		stats.RecordQueueEvent(true)
+		q.Rescan()
+	}
+}
+
+// Rescan schedules a walk of the projects directory once the writer is free
+func (q *syncQueue) Rescan() {
+	select {
+	case q.rescan <- struct{}{}:
+	default:
+	}
+}
+
+// done marks a file as taken by the writer, so changes made while it is
+// being synced queue it again
+func (q *syncQueue) done(path string) {
+	q.mu.Lock()
+	delete(q.queued, path)
+	q.mu.Unlock()
+	stats.SetQueueDepth(len(q.files))
+}
+
+// Close stops the writer once the queued files are synced
+func (q *syncQueue) Close() {
+	close(q.files)
+}
+
+// consume syncs queued files until the queue is closed. It is the only
+// goroutine that syncs files, so per-file state needs no locking.
+func (c *ClaudeSessionSync) consume(q *syncQueue) {
+	for {
+		select {
+		case path, ok := <-q.files:
+			if !ok {
+				return
+			}
+			q.done(path)
+			if source := c.extraSource(path); source != "" {
+				if err := c.syncSourceFile(source, path); err != nil {
+					log.Printf("Failed to sync file %s: %v", path, err)
+				}
+				continue
+			}
+			// Writers that replace a file through a rename have put it back
+			// by now, so only a file that is still missing was removed
+			err := c.syncFile(path)
+			if errors.Is(err, os.ErrNotExist) {
+				err = c.syncRemovedFile(path)
+			}
+			if err != nil {
+				log.Printf("Failed to sync file %s: %v", path, err)
+			}
+		case <-q.rescan:
+			log.Println("Rescanning projects for changes missed by the watcher")
+			if err := c.syncExistingFiles(); err != nil {
+				log.Printf("Rescan failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// tableFormats are the export formats that write flat analytics tables
+// instead of transcripts, one file per table in an output directory
+var tableFormats = map[string]bool{
+	"csv":     true,
+	"parquet": true,
+}
+
+// defaultTableExportDir is where table exports go without --out
+const defaultTableExportDir = "claudemd-export"
+
+// Column kinds of the analytics tables
+const (
+	columnString = iota
+	columnInt
+	columnBool
+	columnTime
+)
+
+// tableColumn names a column and the kind of value it holds. Rows hold
+// string, int64, bool, time.Time or nil for each column.
+type tableColumn struct {
+	name string
+	kind int
+}
+
+// analyticsTable is one of the flat tables produced by a table export
+type analyticsTable struct {
+	name    string
+	columns []tableColumn
+	// rows returns the table's rows for a session
+	rows func(session *ClaudeSession) [][]interface{}
+}
+
+// analyticsTables are joined on session_id, and on message_index within a session
+var analyticsTables = []analyticsTable{
+	{
+		name: "sessions",
+		columns: []tableColumn{
+			{"session_id", columnString},
+			{"title", columnString},
+			{"project", columnString},
+			{"created_at", columnTime},
+			{"updated_at", columnTime},
+			{"started_at", columnTime},
+			{"ended_at", columnTime},
+			{"message_count", columnInt},
+		},
+		rows: sessionRows,
+	},
+	{
+		name: "messages",
+		columns: []tableColumn{
+			{"session_id", columnString},
+			{"message_index", columnInt},
+			{"uuid", columnString},
+			{"type", columnString},
+			{"role", columnString},
+			{"model", columnString},
+			{"timestamp", columnTime},
+			{"content", columnString},
+		},
+		rows: messageRows,
+	},
+	{
+		name: "tool_invocations",
+		columns: []tableColumn{
+			{"session_id", columnString},
+			{"tool_use_id", columnString},
+			{"tool", columnString},
+			{"message_index", columnInt},
+			{"message_uuid", columnString},
+			{"timestamp", columnTime},
+			{"is_error", columnBool},
+			{"input", columnString},
+			{"result_bytes", columnInt},
+		},
+		rows: toolInvocationRows,
+	},
+	{
+		name: "token_usage",
+		columns: []tableColumn{
+			{"session_id", columnString},
+			{"message_index", columnInt},
+			{"message_uuid", columnString},
+			{"api_message_id", columnString},
+			{"model", columnString},
+			{"timestamp", columnTime},
+			{"input_tokens", columnInt},
+			{"output_tokens", columnInt},
+			{"cache_creation_input_tokens", columnInt},
+			{"cache_read_input_tokens", columnInt},
+		},
+		rows: tokenUsageRows,
+	},
+}
+
+// tableWriter writes the rows of one table to a file
+type tableWriter interface {
+	WriteRow(row []interface{}) error
+	Close() error
+}
+
+// exportTables writes the analytics tables for the given sessions, or for
+// every live session when none are given, into the out directory
+func exportTables(db *sql.DB, blobs blobStore, sessionIDs []string, format, out string, anonymizer *Anonymizer) error {
+	if out == "" {
+		out = defaultTableExportDir
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if len(sessionIDs) == 0 {
+		rows, err := db.Query(`SELECT session_id FROM claude_sessions WHERE deleted_at IS NULL ORDER BY created_at`)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if sessionIDs, err = scanIDs(rows); err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+	}
+
+	writers := make([]tableWriter, len(analyticsTables))
+	for i, table := range analyticsTables {
+		file, err := os.Create(filepath.Join(out, table.name+"."+format))
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		if writers[i], err = newTableWriter(file, format, table.columns); err != nil {
+			return fmt.Errorf("failed to write %s: %w", table.name, err)
+		}
+	}
+
+	// Sessions are loaded one at a time so large exports stay within memory
+	for _, sessionID := range sessionIDs {
+		session, err := loadSession(db, sessionID)
+		if err != nil {
+			return err
+		}
+		rehydrateSession(session, blobs)
+		anonymizer.Anonymize(session)
+		for i, table := range analyticsTables {
+			for _, row := range table.rows(session) {
+				if err := writers[i].WriteRow(row); err != nil {
+					return fmt.Errorf("failed to write %s: %w", table.name, err)
+				}
+			}
+		}
+	}
+
+	for i, w := range writers {
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", analyticsTables[i].name, err)
+		}
+	}
+	fmt.Printf("📊 Exported %d sessions as %s tables to %s\n", len(sessionIDs), format, out)
+	return nil
+}
+
+func newTableWriter(w io.Writer, format string, columns []tableColumn) (tableWriter, error) {
+	if format == "parquet" {
+		return newParquetWriter(w, columns)
+	}
+	return newCSVTableWriter(w, columns)
+}
+
+// csvTableWriter writes a header row followed by one record per row.
+// Times are RFC 3339 and nulls are empty fields.
+type csvTableWriter struct {
+	w *csv.Writer
+}
+
+func newCSVTableWriter(w io.Writer, columns []tableColumn) (*csvTableWriter, error) {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	return &csvTableWriter{w: cw}, cw.Write(header)
+}
+
+func (c *csvTableWriter) WriteRow(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case string:
+			record[i] = v
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case time.Time:
+			record[i] = v.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvTableWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// nullable returns nil for empty strings so they export as nulls
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// messageTime parses a message timestamp, returning nil when it is missing
+func messageTime(timestamp string) interface{} {
+	t, ok := parseMessageTime(timestamp)
+	if !ok {
+		return nil
+	}
+	return t
+}
+
+func sessionRows(session *ClaudeSession) [][]interface{} {
+	var project string
+	if sourceFile, ok := session.Metadata["source_file"].(string); ok && sourceFile != "" {
+		project = filepath.Base(filepath.Dir(sourceFile))
+	}
+	var started, ended interface{}
+	for _, msg := range session.Messages {
+		if t := messageTime(msg.Timestamp); t != nil {
+			if started == nil {
+				started = t
+			}
+			ended = t
+		}
+	}
+	return [][]interface{}{{
+		session.SessionID, session.Title, nullable(project), session.CreatedAt, session.UpdatedAt,
+		started, ended, int64(len(session.Messages)),
+	}}
+}
+
+func messageRows(session *ClaudeSession) [][]interface{} {
+	rows := make([][]interface{}, 0, len(session.Messages))
+	for i, msg := range session.Messages {
+		env := msg.envelope()
+		content := msg.Content
+		if msg.Type == "summary" {
+			content = msg.Summary
+		}
+		rows = append(rows, []interface{}{
+			session.SessionID, int64(i), nullable(msg.UUID), msg.Type, nullable(env.Role), nullable(env.Model),
+			messageTime(msg.Timestamp), content,
+		})
+	}
+	return rows
+}
+
+func toolInvocationRows(session *ClaudeSession) [][]interface{} {
+	calls := extractToolCalls(session.Messages)
+	rows := make([][]interface{}, 0, len(calls))
+	for _, call := range calls {
+		var resultBytes interface{}
+		if call.Result != nil {
+			resultBytes = int64(len(toolResultText(call.Result)))
+		}
+		rows = append(rows, []interface{}{
+			session.SessionID, call.ID, call.Name, int64(call.MessageIndex), nullable(call.MessageUUID),
+			messageTime(call.Timestamp), call.IsError, nullable(string(call.Input)), resultBytes,
+		})
+	}
+	return rows
+}
+
+// messageUsage is the API message id and token usage recorded on assistant messages
+type messageUsage struct {
+	ID    string `json:"id"`
+	Model string `json:"model"`
+	Usage *struct {
+		InputTokens              int64 `json:"input_tokens"`
+		OutputTokens             int64 `json:"output_tokens"`
+		CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+}
+
+// tokenUsageRows returns one row per API response. A response split over
+// several log lines repeats its usage on each, so only the last is kept.
+func tokenUsageRows(session *ClaudeSession) [][]interface{} {
+	var rows [][]interface{}
+	byID := make(map[string]int)
+	for i, msg := range session.Messages {
+		if len(msg.Message) == 0 {
+			continue
+		}
+		var m messageUsage
+		if err := json.Unmarshal(msg.Message, &m); err != nil || m.Usage == nil {
+			continue
+		}
+		row := []interface{}{
+			session.SessionID, int64(i), nullable(msg.UUID), nullable(m.ID), nullable(m.Model), messageTime(msg.Timestamp),
+			m.Usage.InputTokens, m.Usage.OutputTokens, m.Usage.CacheCreationInputTokens, m.Usage.CacheReadInputTokens,
+		}
+		if idx, ok := byID[m.ID]; ok && m.ID != "" {
+			rows[idx] = row
+			continue
+		}
+		byID[m.ID] = len(rows)
+		rows = append(rows, row)
+	}
+	return rows
+}
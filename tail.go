@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// sessionTailer follows a session file and emits newly appended messages
+type sessionTailer struct {
+	path    string
+	offset  int64
+	partial []byte
+	// file is the file last read, to notice when another replaces it
+	file os.FileInfo
+}
+
+// readAppended returns messages written since the last read. A trailing line
+// without a newline is held back until Claude Code finishes writing it.
+func (t *sessionTailer) readAppended() ([]SessionMessage, bool, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+
+	reset := false
+	if info.Size() < t.offset || t.file != nil && !os.SameFile(t.file, info) {
+		// File was truncated or replaced, start over
+		t.offset = 0
+		t.partial = nil
+		reset = true
+	}
+	t.file = info
+
+	if _, err := file.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, reset, err
+	}
+	chunk, err := io.ReadAll(file)
+	if err != nil {
+		return nil, reset, err
+	}
+	t.offset += int64(len(chunk))
+
+	data := append(t.partial, chunk...)
+	lastNewline := bytes.LastIndexByte(data, '\n')
+	if lastNewline < 0 {
+		t.partial = data
+		return nil, reset, nil
+	}
+	t.partial = append([]byte(nil), data[lastNewline+1:]...)
+
+	var messages []SessionMessage
+	for _, line := range bytes.Split(data[:lastNewline], []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		msg, err := parseSessionLine(line)
+		if err != nil {
+			log.Printf("Failed to parse tailed line in %s: %v", t.path, err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, reset, nil
+}
+
+// position is the offset of the first byte not yet emitted as a message
+func (t *sessionTailer) position() int64 {
+	return t.offset - int64(len(t.partial))
+}
+
+// tailRedactor returns the redactor sync would apply to the session, which
+// may not have been synced yet
+func (a *apiServer) tailRedactor(sessionID string) (*Redactor, error) {
+	session := &ClaudeSession{SessionID: sessionID}
+	if a.db != nil {
+		if stored, err := loadSession(a.db, sessionID); err == nil {
+			session = stored
+		}
+	}
+	return a.rawRedactor(session)
+}
+
+// handleSessionTail streams messages appended to a session's JSONL file as
+// server-sent events, redacted as /raw redacts the file
+func (a *apiServer) handleSessionTail(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, r, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+
+	claudeDir, err := defaultClaudeDir()
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, "Claude directory not available", nil)
+		return
+	}
+
+	path, err := findSessionFile(claudeDir, sessionID)
+	if os.IsNotExist(err) {
+		writeJSONError(w, r, http.StatusNotFound, "Session file not found", nil)
+		return
+	} else if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	includeThinking, ok := thinkingParam(w, r)
+	if !ok {
+		return
+	}
+	redactor, err := a.tailRedactor(sessionID)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	tailer := &sessionTailer{path: path}
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		// Resume from the offset the client last acknowledged
+		if offset, err := strconv.ParseInt(lastID, 10, 64); err == nil {
+			tailer.offset = offset
+		}
+	} else if r.URL.Query().Get("from") != "start" {
+		if info, err := os.Stat(path); err == nil {
+			tailer.offset = info.Size()
+			tailer.file = info
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to create watcher: %v", err), nil)
+		return
+	}
+	defer watcher.Close()
+
+	// Watch the directory so atomic replaces of the file are still observed
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to watch session: %v", err), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	emit := func() bool {
+		messages, reset, err := tailer.readAppended()
+		if err != nil {
+			log.Printf("[trace=%s] Failed to tail %s: %v", traceIDFromContext(r.Context()), path, err)
+			return !os.IsNotExist(err)
+		}
+		if reset {
+			fmt.Fprintf(w, "event: reset\ndata: {}\n\n")
+		}
+		if redactor != nil {
+			for i := range messages {
+				redactor.redactMessage(&messages[i])
+			}
+		}
+		if !includeThinking {
+			excludeThinking(messages)
+		}
+		for i, msg := range messages {
+			payload, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			if i == len(messages)-1 {
+				// Only the last event of a batch carries a resumable offset
+				fmt.Fprintf(w, "id: %d\n", tailer.position())
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", payload)
+		}
+		flusher.Flush()
+		return true
+	}
+
+	// Flush anything already pending (when starting from the beginning or resuming)
+	if !emit() {
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Name != path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if !emit() {
+				return
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Tail watcher error: %v", err)
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
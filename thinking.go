@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Thinking storage modes, set with "thinking" in ignored/config.json
+const (
+	// thinkingStore keeps thinking blocks and their text
+	thinkingStore = "store"
+	// thinkingHash keeps only a SHA-256 of the thinking text
+	thinkingHash = "hash"
+	// thinkingDiscard drops thinking entirely
+	thinkingDiscard = "discard"
+)
+
+// validateThinkingMode checks a configured thinking storage mode
+func validateThinkingMode(mode string) error {
+	switch mode {
+	case "", thinkingStore, thinkingHash, thinkingDiscard:
+		return nil
+	}
+	return fmt.Errorf("unknown thinking mode %q (valid: store, hash, discard)", mode)
+}
+
+// isThinkingBlock reports whether a content block type holds extended thinking
+func isThinkingBlock(blockType string) bool {
+	return blockType == "thinking" || blockType == "redacted_thinking"
+}
+
+// extractThinking joins the text of a message's thinking blocks. Redacted
+// thinking is encrypted and contributes nothing.
+func extractThinking(msg SessionMessage) string {
+	if !bytes.Contains(msg.Message, []byte(`"thinking"`)) {
+		return ""
+	}
+	var parts []string
+	for _, block := range messageBlocks(msg) {
+		if block.Type == "thinking" && block.Thinking != "" {
+			parts = append(parts, block.Thinking)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// stripThinkingBlocks removes thinking blocks from a raw message, returning
+// it unchanged when there are none
+func stripThinkingBlocks(raw json.RawMessage) json.RawMessage {
+	if !bytes.Contains(raw, []byte(`thinking"`)) {
+		return raw
+	}
+	var envelope map[string]json.RawMessage
+	var blocks []map[string]json.RawMessage
+	if json.Unmarshal(raw, &envelope) != nil || json.Unmarshal(envelope["content"], &blocks) != nil {
+		return raw
+	}
+
+	kept := blocks[:0]
+	for _, block := range blocks {
+		var blockType string
+		json.Unmarshal(block["type"], &blockType)
+		if !isThinkingBlock(blockType) {
+			kept = append(kept, block)
+		}
+	}
+	if len(kept) == len(blocks) {
+		return raw
+	}
+	envelope["content"], _ = json.Marshal(kept)
+	stripped, err := json.Marshal(envelope)
+	if err != nil {
+		return raw
+	}
+	return stripped
+}
+
+// applyThinkingMode enforces the storage mode on a session before it is saved
+func applyThinkingMode(session *ClaudeSession, mode string) {
+	if mode == "" || mode == thinkingStore {
+		return
+	}
+	for i := range session.Messages {
+		msg := &session.Messages[i]
+		if msg.Thinking != "" && mode == thinkingHash {
+			sum := sha256.Sum256([]byte(msg.Thinking))
+			msg.ThinkingHash = hex.EncodeToString(sum[:])
+		}
+		msg.Thinking = ""
+		msg.Message = stripThinkingBlocks(msg.Message)
+	}
+}
+
+// excludeThinking removes thinking content from messages being returned
+func excludeThinking(messages []SessionMessage) {
+	for i := range messages {
+		messages[i].Thinking = ""
+		messages[i].Message = stripThinkingBlocks(messages[i].Message)
+	}
+}
+
+// thinkingParam reads ?thinking=include|exclude, defaulting to include. The
+// second result is false after a bad value has been answered with 400.
+func thinkingParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	switch r.URL.Query().Get("thinking") {
+	case "", "include":
+		return true, true
+	case "exclude":
+		return false, true
+	}
+	writeJSONError(w, r, http.StatusBadRequest, "thinking must be include or exclude", nil)
+	return false, false
+}
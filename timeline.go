@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeline phase kinds, in the order a turn usually runs through them
+const (
+	phasePrompt   = "prompt"
+	phaseThinking = "thinking"
+	phaseTools    = "tools"
+	phaseText     = "text"
+	phaseAnswer   = "answer"
+)
+
+// timelinePreviewLength bounds the prompt and answer text shown in a timeline
+const timelinePreviewLength = 120
+
+// TimelinePhase is a stretch of a turn spent on one kind of work. A phase
+// starts where the previous one ended, since message timestamps record when
+// each piece of work finished; prompts take no time.
+type TimelinePhase struct {
+	Kind         string    `json:"kind"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	DurationMs   int64     `json:"duration_ms"`
+	FirstMessage int       `json:"first_message"`
+	LastMessage  int       `json:"last_message"`
+	// Tools counts the calls of a tools phase by tool name
+	Tools      map[string]int `json:"tools,omitempty"`
+	ToolErrors int            `json:"tool_errors,omitempty"`
+	// Preview is the start of a prompt or answer
+	Preview string `json:"preview,omitempty"`
+}
+
+// TimelineTurn is a user prompt and the work done until the next one
+type TimelineTurn struct {
+	Index      int             `json:"index"`
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	DurationMs int64           `json:"duration_ms"`
+	Phases     []TimelinePhase `json:"phases"`
+}
+
+// SessionTimeline is the response of GET /api/sessions/{id}/timeline.
+// Totals sums the phase durations by kind; time between an answer and the
+// next prompt belongs to no phase.
+type SessionTimeline struct {
+	SessionID  string           `json:"session_id"`
+	DurationMs int64            `json:"duration_ms"`
+	Totals     map[string]int64 `json:"totals"`
+	Turns      []TimelineTurn   `json:"turns"`
+}
+
+// timelineEvent is a content block of a message, classified by phase kind
+type timelineEvent struct {
+	kind    string
+	at      time.Time
+	index   int
+	tool    string
+	isError bool
+	text    string
+}
+
+// timelineEvents classifies the blocks of every timestamped message. Tool
+// results continue the tool phase of their calls, and thinking stored apart
+// from the message still counts as thinking.
+func timelineEvents(messages []SessionMessage) []timelineEvent {
+	var events []timelineEvent
+	for i, msg := range messages {
+		at, ok := messageTime(msg.Timestamp).(time.Time)
+		if !ok {
+			continue
+		}
+		role := messageRole(msg)
+		if role == "assistant" && (msg.Thinking != "" || msg.ThinkingHash != "") {
+			events = append(events, timelineEvent{kind: phaseThinking, at: at, index: i})
+		}
+		for _, block := range messageBlocks(msg) {
+			e := timelineEvent{at: at, index: i}
+			switch {
+			case isThinkingBlock(block.Type):
+				if msg.Thinking != "" || msg.ThinkingHash != "" {
+					continue
+				}
+				e.kind = phaseThinking
+			case block.Type == "tool_use":
+				e.kind, e.tool = phaseTools, block.Name
+			case block.Type == "tool_result":
+				e.kind, e.isError = phaseTools, block.IsError
+			case block.Type == "text" && strings.TrimSpace(block.Text) != "":
+				e.kind, e.text = phaseText, block.Text
+				if role == "user" {
+					e.kind = phasePrompt
+				}
+			default:
+				continue
+			}
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// buildTimeline groups the events of a session into turns of phases
+func buildTimeline(session *ClaudeSession) SessionTimeline {
+	timeline := SessionTimeline{SessionID: session.SessionID, Totals: map[string]int64{}, Turns: []TimelineTurn{}}
+	var turn *TimelineTurn
+	var phase *TimelinePhase
+
+	for _, e := range timelineEvents(session.Messages) {
+		if e.kind == phasePrompt || turn == nil {
+			// Work logged before the first prompt gets a turn of its own
+			timeline.Turns = append(timeline.Turns, TimelineTurn{Index: len(timeline.Turns), Start: e.at})
+			turn = &timeline.Turns[len(timeline.Turns)-1]
+			phase = nil
+		}
+		if phase == nil || phase.Kind != e.kind || e.kind == phasePrompt {
+			start := e.at
+			if phase != nil && e.kind != phasePrompt {
+				start = phase.End
+			}
+			turn.Phases = append(turn.Phases, TimelinePhase{Kind: e.kind, Start: start, FirstMessage: e.index})
+			phase = &turn.Phases[len(turn.Phases)-1]
+		}
+		if e.at.After(phase.End) {
+			phase.End = e.at
+		}
+		phase.LastMessage = e.index
+		if e.tool != "" {
+			if phase.Tools == nil {
+				phase.Tools = map[string]int{}
+			}
+			phase.Tools[e.tool]++
+		}
+		if e.isError {
+			phase.ToolErrors++
+		}
+		if e.text != "" && phase.Preview == "" {
+			phase.Preview = truncateText(e.text, timelinePreviewLength)
+		}
+	}
+
+	for i := range timeline.Turns {
+		turn := &timeline.Turns[i]
+		// The last text of a turn is its answer; earlier text narrates the work
+		for j := len(turn.Phases) - 1; j >= 0; j-- {
+			if turn.Phases[j].Kind == phaseText {
+				turn.Phases[j].Kind = phaseAnswer
+				break
+			}
+		}
+		for j := range turn.Phases {
+			p := &turn.Phases[j]
+			if p.End.Before(p.Start) {
+				// Out of order timestamps should not produce negative bars
+				p.End = p.Start
+			}
+			p.DurationMs = p.End.Sub(p.Start).Milliseconds()
+			timeline.Totals[p.Kind] += p.DurationMs
+			if p.End.After(turn.End) {
+				turn.End = p.End
+			}
+		}
+		turn.DurationMs = turn.End.Sub(turn.Start).Milliseconds()
+	}
+	if n := len(timeline.Turns); n > 0 {
+		timeline.DurationMs = timeline.Turns[n-1].End.Sub(timeline.Turns[0].Start).Milliseconds()
+	}
+	return timeline
+}
+
+// handleSessionTimeline serves GET /api/sessions/{id}/timeline, the phases
+// of each turn with their durations for a Gantt view of a session
+func (a *apiServer) handleSessionTimeline(w http.ResponseWriter, r *http.Request) {
+	session, err := loadSession(a.db, r.PathValue("id"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, buildTimeline(session))
+}
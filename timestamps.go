@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// parseMessageTime parses the timestamp Claude Code writes on each line.
+// Timestamps without a zone are taken to be UTC.
+func parseMessageTime(timestamp string) (time.Time, bool) {
+	if timestamp == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		return t.UTC(), true
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05.999999999", timestamp); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// SessionTimes spans the timestamped messages of a session, stored in the
+// started_at, ended_at and duration_ms columns
+type SessionTimes struct {
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	DurationMS *int64     `json:"duration_ms,omitempty"`
+}
+
+// sessionTimes finds the first and last message timestamps. Sidechain
+// messages can be written out of order, so the earliest and latest are used
+// rather than the first and last lines.
+func sessionTimes(messages []SessionMessage) SessionTimes {
+	var times SessionTimes
+	for _, msg := range messages {
+		t, ok := parseMessageTime(msg.Timestamp)
+		if !ok {
+			continue
+		}
+		if times.StartedAt == nil || t.Before(*times.StartedAt) {
+			started := t
+			times.StartedAt = &started
+		}
+		if times.EndedAt == nil || t.After(*times.EndedAt) {
+			ended := t
+			times.EndedAt = &ended
+		}
+	}
+	if times.StartedAt != nil {
+		duration := times.EndedAt.Sub(*times.StartedAt).Milliseconds()
+		times.DurationMS = &duration
+	}
+	return times
+}
+
+// locationFromQuery reads the tz query parameter, an IANA time zone such as
+// Europe/Berlin, defaulting to UTC
+func locationFromQuery(q url.Values) (*time.Location, error) {
+	name := q.Get("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tz %q: unknown time zone", name)
+	}
+	return loc, nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// TitleConfig selects how session titles are generated
+type TitleConfig struct {
+	// Strategies are tried in order until one produces a title. Valid names
+	// are summary, first_message, project_date and llm.
+	Strategies []string `json:"strategies,omitempty"`
+	// MaxLength truncates generated titles, in characters
+	MaxLength int `json:"max_length,omitempty"`
+	// LLM configures the llm strategy
+	LLM LLMTitleConfig `json:"llm"`
+}
+
+// LLMTitleConfig configures titles generated by the Anthropic API
+type LLMTitleConfig struct {
+	Model string `json:"model,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+var defaultTitleStrategies = []string{"summary", "first_message", "project_date"}
+
+const (
+	defaultTitleLength = 80
+	defaultTitleModel  = "claude-3-5-haiku-latest"
+	defaultTitleKeyEnv = "ANTHROPIC_API_KEY"
+	// llmTitleExcerpt bounds how much of the conversation is sent to the model
+	llmTitleExcerpt = 4000
+)
+
+// titleStrategy derives a title for a session, returning "" if it cannot
+type titleStrategy func(t *Titler, session *ClaudeSession, filePath string) (string, error)
+
+var titleStrategies = map[string]titleStrategy{
+	"summary":       summaryTitle,
+	"first_message": firstMessageTitle,
+	"project_date":  projectDateTitle,
+	"llm":           llmTitle,
+}
+
+// Titler picks a title for each synced session using the configured strategies
+type Titler struct {
+	strategies []string
+	maxLength  int
+	llm        LLMTitleConfig
+	// db lets the llm strategy reuse a title it generated on an earlier sync
+	db *sql.DB
+	// refresh regenerates llm titles instead of reusing stored ones
+	refresh bool
+	client  *http.Client
+}
+
+// NewTitler validates the configured strategies and fills in defaults
+func NewTitler(config TitleConfig, db *sql.DB) (*Titler, error) {
+	t := &Titler{
+		strategies: config.Strategies,
+		maxLength:  config.MaxLength,
+		llm:        config.LLM,
+		db:         db,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	if len(t.strategies) == 0 {
+		t.strategies = defaultTitleStrategies
+	}
+	for _, name := range t.strategies {
+		if _, ok := titleStrategies[name]; !ok {
+			return nil, fmt.Errorf("unknown title strategy %q (valid: %s)", name, strings.Join(sortedKeys(titleStrategies), ", "))
+		}
+	}
+	if t.maxLength <= 0 {
+		t.maxLength = defaultTitleLength
+	}
+	if t.llm.Model == "" {
+		t.llm.Model = defaultTitleModel
+	}
+	if t.llm.APIKeyEnv == "" {
+		t.llm.APIKeyEnv = defaultTitleKeyEnv
+	}
+	return t, nil
+}
+
+// Title sets the session title and records the strategy that produced it in
+// the session metadata. Sessions no strategy can title fall back to their ID.
+func (t *Titler) Title(session *ClaudeSession, filePath string) {
+	if session.Metadata == nil {
+		session.Metadata = make(map[string]interface{})
+	}
+	for _, name := range t.strategies {
+		title, err := titleStrategies[name](t, session, filePath)
+		if err != nil {
+			log.Printf("Title strategy %s failed for %s: %v", name, session.SessionID, err)
+			continue
+		}
+		if title = truncateTitle(title, t.maxLength); title != "" {
+			session.Title = title
+			session.Metadata["title_strategy"] = name
+			return
+		}
+	}
+	session.Title = fmt.Sprintf("Session %s", session.SessionID)
+	delete(session.Metadata, "title_strategy")
+}
+
+// truncateTitle collapses whitespace and cuts the title at a word boundary
+func truncateTitle(title string, maxLength int) string {
+	title = strings.Join(strings.Fields(title), " ")
+	runes := []rune(title)
+	if len(runes) <= maxLength {
+		return title
+	}
+	cut := string(runes[:maxLength-1])
+	if i := strings.LastIndexByte(cut, ' '); i > maxLength/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+// summaryTitle uses the first summary line Claude Code wrote for the session
+func summaryTitle(_ *Titler, session *ClaudeSession, _ string) (string, error) {
+	for _, msg := range session.Messages {
+		if msg.Type == "summary" && msg.Summary != "" {
+			return msg.Summary, nil
+		}
+	}
+	return "", nil
+}
+
+// firstMessageTitle uses the first prompt the user typed, skipping tool
+// results and the command wrappers Claude Code injects as user messages
+func firstMessageTitle(_ *Titler, session *ClaudeSession, _ string) (string, error) {
+	for _, msg := range session.Messages {
+		if messageRole(msg) != "user" {
+			continue
+		}
+		for _, block := range messageBlocks(msg) {
+			text := strings.TrimSpace(block.Text)
+			if block.Type != "text" || text == "" || isInjectedText(text) {
+				continue
+			}
+			return text, nil
+		}
+	}
+	return "", nil
+}
+
+// projectDateTitle names the session after its project and start date
+func projectDateTitle(_ *Titler, session *ClaudeSession, filePath string) (string, error) {
+	project, _ := session.Metadata["project_path"].(string)
+	if project == "" && filePath != "" {
+		project = sessionProjectPath(session.Messages, filePath)
+	}
+	start, _, ok := sessionWindow(session.Messages)
+	if project == "" || !ok {
+		return "", nil
+	}
+	return fmt.Sprintf("%s · %s", filepath.Base(project), start.Local().Format("2006-01-02")), nil
+}
+
+// llmTitle asks the model for a short title. A title generated on an earlier
+// sync is reused so live sessions are not re-titled on every change.
+func llmTitle(t *Titler, session *ClaudeSession, _ string) (string, error) {
+	if t.db != nil && !t.refresh {
+		var title string
+		err := t.db.QueryRow(`
+			SELECT title FROM claude_sessions
+			WHERE session_id = $1 AND metadata->>'title_strategy' = 'llm'`, session.SessionID).Scan(&title)
+		if err == nil {
+			return title, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("failed to look up stored title: %w", err)
+		}
+	}
+
+	apiKey := os.Getenv(t.llm.APIKeyEnv)
+	if apiKey == "" {
+		return "", fmt.Errorf("%s is not set", t.llm.APIKeyEnv)
+	}
+
+	var excerpt strings.Builder
+	for _, turn := range chatTurns(session, false) {
+		fmt.Fprintf(&excerpt, "%s: %s\n\n", turn.Role, turn.Text)
+		if excerpt.Len() >= llmTitleExcerpt {
+			break
+		}
+	}
+	if excerpt.Len() == 0 {
+		return "", nil
+	}
+	text := excerpt.String()
+	if len(text) > llmTitleExcerpt {
+		text = strings.ToValidUTF8(text[:llmTitleExcerpt], "")
+	}
+
+	title, err := anthropicComplete(t.client, t.llm.Model, apiKey, 40,
+		"Write a title of at most eight words for this coding session. Reply with the title only.\n\n"+text)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(strings.TrimSpace(title), `"`), nil
+}
+
+// anthropicComplete sends a single user prompt to the Anthropic Messages API
+// and returns the text of the reply
+func anthropicComplete(client *http.Client, model, apiKey string, maxTokens int, prompt string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      model,
+		"max_tokens": maxTokens,
+		"messages": []map[string]string{{
+			"role":    "user",
+			"content": prompt,
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Anthropic-Version", "2023-06-01")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call model: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("model returned %s: %s", resp.Status, detail)
+	}
+
+	var result struct {
+		Content []ContentBlock `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid response from model: %w", err)
+	}
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			return block.Text, nil
+		}
+	}
+	return "", nil
+}
+
+// retitleCommand recomputes titles of stored sessions with the current strategies
+func retitleCommand(c *cli.Context) error {
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	db, err := InitializeDatabase(config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+	if err := requirePostgres(db); err != nil {
+		return err
+	}
+
+	titleConfig := config.Titles
+	if c.IsSet("strategy") {
+		titleConfig.Strategies = c.StringSlice("strategy")
+	}
+	titler, err := NewTitler(titleConfig, db)
+	if err != nil {
+		return err
+	}
+	titler.refresh = c.Bool("refresh")
+
+	// By default only sessions still carrying the fallback title are touched
+	query := `SELECT session_id FROM claude_sessions WHERE deleted_at IS NULL`
+	if !c.Bool("all") {
+		query += ` AND title = 'Session ' || session_id`
+	}
+	rows, err := db.Query(query + ` ORDER BY created_at`)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, id := range ids {
+		session, err := loadSession(db, id)
+		if err != nil {
+			return err
+		}
+		previous := session.Title
+		sourceFile, _ := session.Metadata["source_file"].(string)
+		titler.Title(session, sourceFile)
+		if session.Title == previous {
+			continue
+		}
+
+		fmt.Printf("  %s: %s → %s\n", id, previous, session.Title)
+		changed++
+		if c.Bool("dry-run") {
+			continue
+		}
+		strategy, _ := session.Metadata["title_strategy"].(string)
+		_, err = db.Exec(`
+			UPDATE claude_sessions
+			SET title = $2,
+			    metadata = CASE WHEN $3 = '' THEN metadata - 'title_strategy'
+			                    ELSE metadata || jsonb_build_object('title_strategy', $3::text) END,
+			    updated_at = NOW()
+			WHERE session_id = $1`, id, session.Title, strategy)
+		if err != nil {
+			return fmt.Errorf("failed to update title of %s: %w", id, err)
+		}
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Printf("🔍 %d of %d session(s) would be retitled\n", changed, len(ids))
+	} else {
+		fmt.Printf("✅ Retitled %d of %d session(s)\n", changed, len(ids))
+	}
+	return nil
+}
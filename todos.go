@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// todoStatuses are the states a todo can be in. Claude Code writes the first
+// three; removed marks a todo dropped from a later list before it was done.
+var todoStatuses = map[string]bool{
+	"pending":     true,
+	"in_progress": true,
+	"completed":   true,
+	"removed":     true,
+}
+
+// TodoTransition records a status change seen in a TodoWrite call
+type TodoTransition struct {
+	Status      string     `json:"status"`
+	At          *time.Time `json:"at,omitempty"`
+	MessageUUID string     `json:"message_uuid,omitempty"`
+}
+
+// SessionTodo is the latest state of a task from a session's todo list
+type SessionTodo struct {
+	SessionID    string           `json:"session_id"`
+	SessionTitle string           `json:"session_title,omitempty"`
+	Key          string           `json:"key"`
+	Position     int              `json:"position"`
+	Content      string           `json:"content"`
+	ActiveForm   string           `json:"active_form,omitempty"`
+	Priority     string           `json:"priority,omitempty"`
+	Status       string           `json:"status"`
+	Transitions  []TodoTransition `json:"transitions"`
+	CreatedAt    *time.Time       `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time       `json:"updated_at,omitempty"`
+	CompletedAt  *time.Time       `json:"completed_at,omitempty"`
+}
+
+// todoWriteInput is the input of a TodoWrite tool call. Older Claude Code
+// versions sent an id and priority; newer ones send activeForm instead.
+type todoWriteInput struct {
+	Todos []struct {
+		ID         string `json:"id"`
+		Content    string `json:"content"`
+		Status     string `json:"status"`
+		Priority   string `json:"priority"`
+		ActiveForm string `json:"activeForm"`
+	} `json:"todos"`
+}
+
+// extractTodos replays every successful TodoWrite call in the session. Each
+// call replaces the whole list, so todos missing from a later call are marked removed.
+func extractTodos(sessionID string, messages []SessionMessage) []SessionTodo {
+	var todos []*SessionTodo
+	byKey := make(map[string]*SessionTodo)
+
+	transition := func(todo *SessionTodo, status string, at *time.Time, messageUUID string) {
+		if todo.Status == status {
+			return
+		}
+		todo.Status = status
+		todo.UpdatedAt = at
+		todo.Transitions = append(todo.Transitions, TodoTransition{Status: status, At: at, MessageUUID: messageUUID})
+		if status == "completed" {
+			todo.CompletedAt = at
+		} else if status != "removed" {
+			todo.CompletedAt = nil
+		}
+	}
+
+	for _, call := range extractToolCalls(messages) {
+		if call.Name != "TodoWrite" || call.IsError {
+			continue
+		}
+		var input todoWriteInput
+		if err := json.Unmarshal(call.Input, &input); err != nil {
+			continue
+		}
+		var at *time.Time
+		if t, err := time.Parse(time.RFC3339Nano, call.Timestamp); err == nil {
+			at = &t
+		}
+
+		// A list can repeat a todo; the last entry wins, so the todo gets
+		// one transition per call and is stored once
+		last := make(map[string]int)
+		for i, item := range input.Todos {
+			if key := todoKey(item.ID, item.Content); key != "" && todoStatuses[item.Status] {
+				last[key] = i
+			}
+		}
+		seen := make(map[string]bool)
+		for i, item := range input.Todos {
+			key := todoKey(item.ID, item.Content)
+			if j, ok := last[key]; !ok || j != i {
+				continue
+			}
+			seen[key] = true
+
+			todo, ok := byKey[key]
+			if !ok {
+				todo = &SessionTodo{SessionID: sessionID, Key: key, CreatedAt: at, Transitions: []TodoTransition{}}
+				byKey[key] = todo
+				todos = append(todos, todo)
+			}
+			todo.Position = i
+			todo.Content = item.Content
+			todo.ActiveForm = item.ActiveForm
+			todo.Priority = item.Priority
+			transition(todo, item.Status, at, call.MessageUUID)
+		}
+		for _, todo := range todos {
+			if !seen[todo.Key] && todo.Status != "completed" {
+				transition(todo, "removed", at, call.MessageUUID)
+			}
+		}
+	}
+
+	result := make([]SessionTodo, len(todos))
+	for i, todo := range todos {
+		result[i] = *todo
+	}
+	return result
+}
+
+// todoKey identifies a todo across calls by its ID, or its content when it
+// has none
+func todoKey(id, content string) string {
+	if id != "" {
+		return id
+	}
+	return content
+}
+
+// StoreTodos replaces the stored todos of a session with the replayed state
+func (p postgresSink) StoreTodos(sessionID string, todos []SessionTodo) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM session_todos WHERE session_id = $1`, sessionID); err != nil {
+		return fmt.Errorf("failed to clear todos: %w", err)
+	}
+	for _, todo := range todos {
+		transitions, err := json.Marshal(todo.Transitions)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO session_todos (session_id, todo_key, position, content, active_form, priority, status, transitions, created_at, updated_at, completed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			sessionID, todo.Key, todo.Position, todo.Content, todo.ActiveForm, todo.Priority, todo.Status,
+			string(transitions), todo.CreatedAt, todo.UpdatedAt, todo.CompletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert todo: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// todoCursor is the position of a todo in the list, which is ordered by
+// update time, then session and position
+type todoCursor struct {
+	UpdatedAt *time.Time
+	SessionID string
+	Position  int
+}
+
+// listTodos returns todos across all sessions, most recently updated first,
+// starting after the cursor when one is given
+func listTodos(db *sql.DB, status, sessionID string, after *todoCursor, limit int) ([]SessionTodo, error) {
+	where := "($1 = '' OR t.status = $1) AND ($2 = '' OR t.session_id = $2)"
+	args := []interface{}{status, sessionID}
+	if after != nil {
+		var cond string
+		cond, args = afterNullableTime("t.updated_at", "(t.session_id, t.position)", after.UpdatedAt,
+			[]interface{}{after.SessionID, after.Position}, args)
+		where += " AND " + cond
+	}
+	args = append(args, limit)
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT t.session_id, s.title, t.todo_key, t.position, t.content, t.active_form, t.priority,
+		       t.status, t.transitions, t.created_at, t.updated_at, t.completed_at
+		FROM session_todos t
+		JOIN claude_sessions s ON s.session_id = t.session_id AND s.deleted_at IS NULL
+		WHERE %s
+		ORDER BY t.updated_at DESC NULLS LAST, t.session_id, t.position
+		LIMIT $%d`, where, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query todos: %w", err)
+	}
+	defer rows.Close()
+
+	todos := []SessionTodo{}
+	for rows.Next() {
+		var todo SessionTodo
+		var transitions []byte
+		if err := rows.Scan(&todo.SessionID, &todo.SessionTitle, &todo.Key, &todo.Position, &todo.Content,
+			&todo.ActiveForm, &todo.Priority, &todo.Status, &transitions,
+			&todo.CreatedAt, &todo.UpdatedAt, &todo.CompletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan todo: %w", err)
+		}
+		if err := json.Unmarshal(transitions, &todo.Transitions); err != nil {
+			return nil, fmt.Errorf("failed to decode todo transitions: %w", err)
+		}
+		todos = append(todos, todo)
+	}
+	return todos, rows.Err()
+}
+
+// handleListTodos serves GET /api/todos?status=&session=&limit=&cursor=
+func (a *apiServer) handleListTodos(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status != "" && !todoStatuses[status] {
+		writeJSONError(w, r, http.StatusBadRequest, "status must be one of pending, in_progress, completed or removed", nil)
+		return
+	}
+	page, err := pageFromQuery(q, 500, 5000)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var cursor todoCursor
+	var after *todoCursor
+	if ok, err := page.decode(&cursor.UpdatedAt, &cursor.SessionID, &cursor.Position); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	} else if ok {
+		after = &cursor
+	}
+
+	todos, err := listTodos(a.db, status, q.Get("session"), after, page.Limit+1)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPage(todos, page, func(t SessionTodo) string {
+		return encodeCursor(t.UpdatedAt, t.SessionID, t.Position)
+	}))
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errorHourLayout keys the hourly error counts, in UTC
+const errorHourLayout = "2006-01-02T15"
+
+// ToolErrorStats counts the calls of one tool and how many failed
+type ToolErrorStats struct {
+	Calls  int `json:"calls"`
+	Errors int `json:"errors"`
+}
+
+// ErrorCounts are the tool calls and errors of a session on one day
+type ErrorCounts struct {
+	ToolCalls  int `json:"tool_calls"`
+	ToolErrors int `json:"tool_errors"`
+	APIErrors  int `json:"api_errors"`
+}
+
+// SessionErrors summarizes the failed tool calls and API errors of a session
+type SessionErrors struct {
+	ErrorCounts
+	Tools map[string]ToolErrorStats `json:"tools,omitempty"`
+	// Types counts tool errors by classifyToolError
+	Types map[string]int `json:"types,omitempty"`
+	// APITypes counts API errors by classifyAPIError
+	APITypes map[string]int `json:"api_types,omitempty"`
+	// Days splits the counts by the UTC day of the messages
+	Days map[string]ErrorCounts `json:"days,omitempty"`
+	// Hours splits them by the UTC hour, so the trend can be bucketed into
+	// the days of another time zone
+	Hours map[string]ErrorCounts `json:"hours,omitempty"`
+}
+
+// errorPattern classifies error text containing any of its markers
+type errorPattern struct {
+	kind    string
+	markers []string
+}
+
+// toolErrorPatterns are checked in order against lowercased tool results
+var toolErrorPatterns = []errorPattern{
+	{"rejected", []string{"doesn't want to proceed", "doesn't want to take this action", "user rejected", "request interrupted"}},
+	{"permission", []string{"permission denied", "permission to use", "operation not permitted", "eacces", "has been denied", "not allowed"}},
+	{"not_read", []string{"has not been read yet"}},
+	{"stale_read", []string{"modified since read"}},
+	{"no_match", []string{"string to replace not found", "found multiple matches", "matches of the string to replace"}},
+	{"invalid_input", []string{"inputvalidationerror", "invalid tool parameters", "required parameter"}},
+	{"timeout", []string{"timed out", "timeout"}},
+	{"command_not_found", []string{"command not found"}},
+	{"not_found", []string{"no such file", "does not exist", "enoent", "not found"}},
+	{"exit_code", []string{"exit code", "exit status"}},
+}
+
+// apiErrorPatterns are checked in order against lowercased API error messages
+var apiErrorPatterns = []errorPattern{
+	{"rate_limit", []string{"429", "rate limit", "usage limit"}},
+	{"overloaded", []string{"529", "overloaded"}},
+	{"timeout", []string{"timed out", "timeout"}},
+	{"auth", []string{"401", "403", "invalid api key", "oauth", "authentication"}},
+	{"prompt_too_long", []string{"prompt is too long", "context length"}},
+	{"server", []string{"500", "502", "503", "internal server error"}},
+}
+
+// classifyError returns the kind of the first pattern matching text, or "other"
+func classifyError(patterns []errorPattern, text string) string {
+	text = strings.ToLower(text)
+	for _, p := range patterns {
+		for _, marker := range p.markers {
+			if strings.Contains(text, marker) {
+				return p.kind
+			}
+		}
+	}
+	return "other"
+}
+
+// classifyToolError names the kind of failure a tool result reports
+func classifyToolError(text string) string {
+	return classifyError(toolErrorPatterns, text)
+}
+
+// classifyAPIError names the kind of API error Claude Code recorded
+func classifyAPIError(text string) string {
+	return classifyError(apiErrorPatterns, text)
+}
+
+// apiErrorText returns the text of a message Claude Code wrote in place of
+// a response the API failed to give, or ""
+func apiErrorText(msg SessionMessage) string {
+	env := msg.envelope()
+	if env.Role != "assistant" {
+		return ""
+	}
+	for _, block := range decodeContentBlocks(env.Content) {
+		if block.Type != "text" {
+			continue
+		}
+		if strings.HasPrefix(block.Text, "API Error") || (env.Model == "<synthetic>" && strings.Contains(block.Text, "limit reached")) {
+			return block.Text
+		}
+	}
+	return ""
+}
+
+// sessionErrors counts the tool calls, failed tool results and API errors
+// of a session
+func sessionErrors(messages []SessionMessage) SessionErrors {
+	summary := SessionErrors{
+		Tools:    make(map[string]ToolErrorStats),
+		Types:    make(map[string]int),
+		APITypes: make(map[string]int),
+		Days:     make(map[string]ErrorCounts),
+		Hours:    make(map[string]ErrorCounts),
+	}
+	countDay := func(timestamp string, update func(*ErrorCounts)) {
+		t, ok := parseMessageTime(timestamp)
+		if !ok {
+			return
+		}
+		day, hour := t.Format("2006-01-02"), t.Format(errorHourLayout)
+		counts := summary.Days[day]
+		update(&counts)
+		summary.Days[day] = counts
+		counts = summary.Hours[hour]
+		update(&counts)
+		summary.Hours[hour] = counts
+	}
+
+	for _, call := range extractToolCalls(messages) {
+		stats := summary.Tools[call.Name]
+		stats.Calls++
+		summary.ToolCalls++
+		if call.IsError {
+			stats.Errors++
+			summary.ToolErrors++
+			summary.Types[classifyToolError(toolResultText(call.Result))]++
+		}
+		summary.Tools[call.Name] = stats
+		countDay(call.Timestamp, func(c *ErrorCounts) {
+			c.ToolCalls++
+			if call.IsError {
+				c.ToolErrors++
+			}
+		})
+	}
+	for _, msg := range messages {
+		if text := apiErrorText(msg); text != "" {
+			summary.APIErrors++
+			summary.APITypes[classifyAPIError(text)]++
+			countDay(msg.Timestamp, func(c *ErrorCounts) { c.APIErrors++ })
+		}
+	}
+	return summary
+}
+
+// enrichErrors records the error budget of a session that called tools or
+// hit API errors
+func enrichErrors(session *ClaudeSession, filePath string) {
+	if summary := sessionErrors(session.Messages); summary.ToolCalls > 0 || summary.APIErrors > 0 {
+		session.Metadata["errors"] = summary
+	}
+}
+
+// ToolErrorUsage is the failure rate of one tool across sessions
+type ToolErrorUsage struct {
+	Tool      string  `json:"tool"`
+	Calls     int     `json:"calls"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// ErrorTypeCount is how often one kind of error occurred
+type ErrorTypeCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// ProjectErrors is the error budget of one project
+type ProjectErrors struct {
+	Project string `json:"project"`
+	ErrorCounts
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// ErrorTrendPoint is the error budget of one day
+type ErrorTrendPoint struct {
+	Day string `json:"day"`
+	ErrorCounts
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// ErrorReport is the response of GET /api/analytics/errors
+type ErrorReport struct {
+	// Sessions counts the sessions with any tool calls or API errors
+	Sessions int `json:"sessions"`
+	ErrorCounts
+	ErrorRate float64           `json:"error_rate"`
+	Tools     []ToolErrorUsage  `json:"tools"`
+	Types     []ErrorTypeCount  `json:"types"`
+	APITypes  []ErrorTypeCount  `json:"api_types"`
+	Projects  []ProjectErrors   `json:"projects"`
+	Trend     []ErrorTrendPoint `json:"trend"`
+	// TimeZone is the zone the trend's days are in, set by the tz parameter
+	TimeZone string `json:"time_zone"`
+}
+
+// errorRate is the fraction of tool calls that failed
+func errorRate(c ErrorCounts) float64 {
+	if c.ToolCalls == 0 {
+		return 0
+	}
+	return float64(c.ToolErrors) / float64(c.ToolCalls)
+}
+
+// queryErrorCounts sums the counts of e, an errors object from source,
+// grouped by the key expression
+func queryErrorCounts(a *apiServer, source, key, where string, args []interface{}, scan func(key string, counts ErrorCounts)) error {
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT %s, COALESCE(sum((e->>'tool_calls')::int), 0), COALESCE(sum((e->>'tool_errors')::int), 0), COALESCE(sum((e->>'api_errors')::int), 0)
+		FROM claude_sessions, %s
+		WHERE jsonb_typeof(metadata->'errors') = 'object' AND %s
+		GROUP BY 1`, key, source, where), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		var counts ErrorCounts
+		if err := rows.Scan(&k, &counts.ToolCalls, &counts.ToolErrors, &counts.APIErrors); err != nil {
+			return err
+		}
+		scan(k, counts)
+	}
+	return rows.Err()
+}
+
+// queryErrorTypes sums a metadata errors map of counts by its keys
+func queryErrorTypes(a *apiServer, field, where string, args []interface{}) ([]ErrorTypeCount, error) {
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT t.key, sum(t.value::int)
+		FROM claude_sessions, jsonb_each_text(metadata->'errors'->'%s') AS t
+		WHERE jsonb_typeof(metadata->'errors'->'%s') = 'object' AND %s
+		GROUP BY t.key
+		ORDER BY 2 DESC, 1`, field, field, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types := []ErrorTypeCount{}
+	for rows.Next() {
+		var t ErrorTypeCount
+		if err := rows.Scan(&t.Type, &t.Count); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// wholeHourZone reports whether the zone's offsets from UTC, in winter and
+// in summer, are whole hours
+func wholeHourZone(loc *time.Location) bool {
+	year := time.Now().Year()
+	for _, month := range []time.Month{time.January, time.July} {
+		if _, offset := time.Date(year, month, 1, 0, 0, 0, 0, loc).Zone(); offset%3600 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// handleErrorAnalytics serves GET /api/analytics/errors: failure rates of
+// tools, the kinds of tool and API errors, and the error budget per
+// project and per day. It accepts the filters of the session list, and tz
+// to bucket the days in a time zone other than UTC. Errors are counted per
+// UTC hour, so zones such as Asia/Kolkata, whose offset is not a whole
+// number of hours, are refused rather than split across days wrongly.
+// Sessions synced before error tracking was added have no stats until
+// they are synced again.
+func (a *apiServer) handleErrorAnalytics(w http.ResponseWriter, r *http.Request) {
+	filter, err := sessionFilterFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	loc, err := locationFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if !wholeHourZone(loc) {
+		writeJSONError(w, r, http.StatusBadRequest, fmt.Sprintf("tz %s is not a whole number of hours from UTC, which the hourly error counts cannot be bucketed in", loc), nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	where, args := filter.where()
+	fail := func(what string, err error) {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query %s: %v", what, err), nil)
+	}
+
+	report := ErrorReport{TimeZone: loc.String(), Projects: []ProjectErrors{}, Trend: []ErrorTrendPoint{}}
+	err = a.db.QueryRow(`
+		SELECT count(*), COALESCE(sum((metadata->'errors'->>'tool_calls')::int), 0),
+		       COALESCE(sum((metadata->'errors'->>'tool_errors')::int), 0), COALESCE(sum((metadata->'errors'->>'api_errors')::int), 0)
+		FROM claude_sessions
+		WHERE jsonb_typeof(metadata->'errors') = 'object' AND `+where, args...).
+		Scan(&report.Sessions, &report.ToolCalls, &report.ToolErrors, &report.APIErrors)
+	if err != nil {
+		fail("sessions", err)
+		return
+	}
+	report.ErrorRate = errorRate(report.ErrorCounts)
+
+	rows, err := a.db.Query(`
+		SELECT t.key, sum((t.value->>'calls')::int), sum((t.value->>'errors')::int)
+		FROM claude_sessions, jsonb_each(metadata->'errors'->'tools') AS t
+		WHERE jsonb_typeof(metadata->'errors'->'tools') = 'object' AND `+where+`
+		GROUP BY t.key`, args...)
+	if err != nil {
+		fail("tools", err)
+		return
+	}
+	defer rows.Close()
+	report.Tools = []ToolErrorUsage{}
+	for rows.Next() {
+		var tool ToolErrorUsage
+		if err := rows.Scan(&tool.Tool, &tool.Calls, &tool.Errors); err != nil {
+			fail("tools", err)
+			return
+		}
+		tool.ErrorRate = errorRate(ErrorCounts{ToolCalls: tool.Calls, ToolErrors: tool.Errors})
+		report.Tools = append(report.Tools, tool)
+	}
+	if err := rows.Err(); err != nil {
+		fail("tools", err)
+		return
+	}
+	// Top failing tools first
+	sort.Slice(report.Tools, func(i, j int) bool {
+		if report.Tools[i].Errors != report.Tools[j].Errors {
+			return report.Tools[i].Errors > report.Tools[j].Errors
+		}
+		return report.Tools[i].Tool < report.Tools[j].Tool
+	})
+
+	if report.Types, err = queryErrorTypes(a, "types", where, args); err != nil {
+		fail("error types", err)
+		return
+	}
+	if report.APITypes, err = queryErrorTypes(a, "api_types", where, args); err != nil {
+		fail("API error types", err)
+		return
+	}
+
+	project := `COALESCE(substring(metadata->>'source_file' from '/projects/([^/]+)/'), '')`
+	err = queryErrorCounts(a, "LATERAL (SELECT metadata->'errors' AS e) AS s", project, where, args, func(key string, counts ErrorCounts) {
+		report.Projects = append(report.Projects, ProjectErrors{Project: key, ErrorCounts: counts, ErrorRate: errorRate(counts)})
+	})
+	if err != nil {
+		fail("projects", err)
+		return
+	}
+	sort.Slice(report.Projects, func(i, j int) bool {
+		if report.Projects[i].ToolErrors != report.Projects[j].ToolErrors {
+			return report.Projects[i].ToolErrors > report.Projects[j].ToolErrors
+		}
+		return report.Projects[i].Project < report.Projects[j].Project
+	})
+
+	// Hourly counts are moved into the days of the requested time zone.
+	// Sessions synced before they were kept only have UTC days.
+	days := make(map[string]ErrorCounts)
+	addDay := func(day string, counts ErrorCounts) {
+		total := days[day]
+		total.ToolCalls += counts.ToolCalls
+		total.ToolErrors += counts.ToolErrors
+		total.APIErrors += counts.APIErrors
+		days[day] = total
+	}
+	err = queryErrorCounts(a, "jsonb_each(metadata->'errors'->'hours') AS h(hour, e)", "h.hour", where+" AND jsonb_typeof(metadata->'errors'->'hours') = 'object'", args, func(key string, counts ErrorCounts) {
+		if t, err := time.Parse(errorHourLayout, key); err == nil {
+			addDay(t.In(loc).Format("2006-01-02"), counts)
+		}
+	})
+	if err == nil {
+		err = queryErrorCounts(a, "jsonb_each(metadata->'errors'->'days') AS d(day, e)", "d.day", where+" AND jsonb_typeof(metadata->'errors'->'days') = 'object' AND jsonb_typeof(metadata->'errors'->'hours') IS DISTINCT FROM 'object'", args, addDay)
+	}
+	if err != nil {
+		fail("trend", err)
+		return
+	}
+	for day, counts := range days {
+		report.Trend = append(report.Trend, ErrorTrendPoint{Day: day, ErrorCounts: counts, ErrorRate: errorRate(counts)})
+	}
+	sort.Slice(report.Trend, func(i, j int) bool { return report.Trend[i].Day < report.Trend[j].Day })
+	writeJSON(w, http.StatusOK, report)
+}
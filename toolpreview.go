@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Long tool results are summarized by their first and last bytes, since
+// errors and final output tend to be at the end
+const (
+	toolPreviewHead = 200
+	toolPreviewTail = 200
+)
+
+// contentScan controls how message content is summarized. Long tool results
+// are cut to a preview unless full is set; truncated records that one was.
+type contentScan struct {
+	full      bool
+	truncated bool
+}
+
+// preview returns text, or its head and tail when it is too long to list
+func (cs *contentScan) preview(text string) string {
+	if cs.full || len(text) <= toolPreviewHead+toolPreviewTail {
+		return text
+	}
+	head, tail := toolPreviewHead, len(text)-toolPreviewTail
+	for head > 0 && !isRuneStart(text[head]) {
+		head--
+	}
+	for tail < len(text) && !isRuneStart(text[tail]) {
+		tail++
+	}
+	cs.truncated = true
+	return fmt.Sprintf("%s\n… [%d bytes omitted] …\n%s", text[:head], tail-head, text[tail:])
+}
+
+// fullMessageContent summarizes a message like its Content, but with tool
+// results kept whole
+func fullMessageContent(msg SessionMessage) string {
+	if len(msg.Message) == 0 {
+		return msg.Content
+	}
+	s := jsonScanner{data: msg.Message}
+	content, _ := scanMessageContent(&s, &contentScan{full: true})
+	return content
+}
+
+// MessageContent is the response of GET /api/messages/{uuid}/full
+type MessageContent struct {
+	SessionID string `json:"session_id"`
+	UUID      string `json:"uuid"`
+	Content   string `json:"content"`
+}
+
+// handleMessageFull serves GET /api/messages/{uuid}/full[?session=], the
+// content of a message whose tool results were cut to a preview, with
+// offloaded results restored. Passing the session saves searching them all.
+func (a *apiServer) handleMessageFull(w http.ResponseWriter, r *http.Request) {
+	uuid := r.PathValue("uuid")
+	var filter SessionFilter
+	if id := r.URL.Query().Get("session"); id != "" {
+		filter.IDs = []string{id}
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	where, args := filter.where()
+	args = append(args, uuid)
+
+	var sessionID string
+	var raw []byte
+	err := a.db.QueryRow(fmt.Sprintf(`
+		SELECT session_id, m.message
+		FROM claude_sessions, jsonb_array_elements(messages) AS m(message)
+		WHERE %s AND m.message->>'uuid' = $%d
+		LIMIT 1`, where, len(args)), args...).Scan(&sessionID, &raw)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, r, http.StatusNotFound, "Message not found", nil)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	var msg SessionMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to parse message: %v", err), nil)
+		return
+	}
+
+	if config := a.currentConfig(); config != nil {
+		store, err := newBlobStore(config.Blobs)
+		if err != nil {
+			writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+			return
+		}
+		session := &ClaudeSession{Messages: []SessionMessage{msg}}
+		rehydrateSession(session, store)
+		msg = session.Messages[0]
+	}
+	writeJSON(w, http.StatusOK, MessageContent{SessionID: sessionID, UUID: uuid, Content: fullMessageContent(msg)})
+}
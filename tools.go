@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// ToolCall is a tool_use block paired with its tool_result, if one was recorded
+type ToolCall struct {
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	MessageIndex int             `json:"message_index"`
+	MessageUUID  string          `json:"message_uuid,omitempty"`
+	Timestamp    string          `json:"timestamp,omitempty"`
+	IsError      bool            `json:"is_error,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+}
+
+// extractToolCalls walks the session in order and pairs tool_use blocks with their results
+func extractToolCalls(messages []SessionMessage) []ToolCall {
+	var calls []ToolCall
+	byID := make(map[string]int)
+
+	for i, msg := range messages {
+		for _, block := range messageBlocks(msg) {
+			switch block.Type {
+			case "tool_use":
+				byID[block.ID] = len(calls)
+				calls = append(calls, ToolCall{
+					ID:           block.ID,
+					Name:         block.Name,
+					Input:        block.Input,
+					MessageIndex: i,
+					MessageUUID:  msg.UUID,
+					Timestamp:    msg.Timestamp,
+				})
+			case "tool_result":
+				if idx, ok := byID[block.ToolUseID]; ok {
+					calls[idx].IsError = block.IsError
+					calls[idx].Result = block.Content
+				}
+			}
+		}
+	}
+	return calls
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type traceContextKey struct{}
+
+// traceHeader carries the request trace ID in both directions
+const traceHeader = "X-Trace-Id"
+
+// traceMiddleware assigns every request a trace ID, reusing one supplied by
+// the client (X-Trace-Id or W3C traceparent) so reports can be correlated.
+// When traces are exported, the request's span starts here.
+func traceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := incomingTraceID(r)
+		ctx := r.Context()
+		if tracingEndpoint != "" {
+			var span trace.Span
+			ctx, span = startServerSpan(r)
+			defer span.End()
+			// Log the ID the trace is exported under, unless the client chose one
+			if !validTraceID(strings.TrimSpace(r.Header.Get(traceHeader))) {
+				traceID = span.SpanContext().TraceID().String()
+			}
+		}
+		if traceID == "" {
+			traceID = newTraceID()
+		}
+
+		w.Header().Set(traceHeader, traceID)
+		ctx = context.WithValue(ctx, traceContextKey{}, traceID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// incomingTraceID extracts a client-provided trace ID if it looks sane
+func incomingTraceID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get(traceHeader)); validTraceID(id) {
+		return id
+	}
+	// traceparent: version-traceid-parentid-flags
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 && validTraceID(parts[1]) {
+		return parts[1]
+	}
+	return ""
+}
+
+// validTraceID accepts short alphanumeric IDs so they are safe to log and echo
+func validTraceID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, ch := range id {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// newTraceID returns a random 128-bit hex trace ID
+func newTraceID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// traceIDFromContext returns the trace ID of the current request, if any
+func traceIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(traceContextKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an API error body including the request trace ID
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string, details interface{}) {
+	body := map[string]interface{}{
+		"error":    message,
+		"trace_id": traceIDFromContext(r.Context()),
+	}
+	if details != nil {
+		body["details"] = details
+	}
+	writeJSON(w, status, body)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingConfig exports OpenTelemetry traces of session syncs and builds
+// over OTLP/HTTP. The standard OTEL_* environment variables take precedence.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector, such as http://localhost:4318.
+	// Tracing is off unless it or OTEL_EXPORTER_OTLP_ENDPOINT is set.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent with every export, such as a collector API key
+	Headers map[string]string `json:"headers,omitempty"`
+	// ServiceName names this process in traces, claudemd by default
+	ServiceName string `json:"service_name,omitempty"`
+	// SampleRatio is the share of traces kept, from 0 to 1; all by default
+	SampleRatio *float64 `json:"sample_ratio,omitempty"`
+}
+
+// otlpEndpointEnvs configure the collector in place of tracing.endpoint
+var otlpEndpointEnvs = []string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"}
+
+// tracer creates the spans of syncs and builds. Until setupTracing installs
+// an exporter they are discarded.
+var tracer = otel.Tracer("github.com/breadchris/claudemd")
+
+var (
+	// tracingEndpoint is where traces are exported, empty when tracing is off
+	tracingEndpoint string
+	// shutdownTracing flushes the spans still buffered
+	shutdownTracing = func() {}
+)
+
+// envEndpoint returns the collector set in the environment, if any
+func (t TracingConfig) envEndpoint() string {
+	for _, env := range otlpEndpointEnvs {
+		if endpoint := os.Getenv(env); endpoint != "" {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// validate checks the tracing settings
+func (t TracingConfig) validate() error {
+	if t.Endpoint != "" {
+		u, err := url.Parse(t.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("tracing.endpoint must be an http or https URL, got %q", t.Endpoint)
+		}
+	}
+	if t.SampleRatio != nil && (*t.SampleRatio < 0 || *t.SampleRatio > 1) {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// setupTracing installs an OTLP exporter when a collector is configured.
+// Spans are exported in batches in the background.
+func setupTracing(config TracingConfig) error {
+	endpoint := config.envEndpoint()
+	if endpoint == "" {
+		endpoint = config.Endpoint
+	}
+	if endpoint == "" || strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return nil
+	}
+
+	// Options given here override the environment, so only pass those it lacks
+	var opts []otlptracehttp.Option
+	if config.envEndpoint() == "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+	}
+	if len(config.Headers) > 0 && os.Getenv("OTEL_EXPORTER_OTLP_HEADERS") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS") == "" {
+		opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+	}
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "claudemd"
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		log.Printf("Some trace resource attributes are missing: %v", err)
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)}
+	if config.SampleRatio != nil && os.Getenv("OTEL_TRACES_SAMPLER") == "" {
+		providerOpts = append(providerOpts, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(*config.SampleRatio))))
+	}
+	provider := sdktrace.NewTracerProvider(providerOpts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	tracingEndpoint = endpoint
+	shutdownTracing = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}
+	return nil
+}
+
+// startServerSpan starts the span of an HTTP request, continuing a trace
+// the client started with a traceparent header
+func startServerSpan(r *http.Request) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sinkAttribute names the kind of store a session is written to
+func sinkAttribute(sink sessionSink) attribute.KeyValue {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", sink), "*")
+	return attribute.String("claudemd.sink", strings.TrimPrefix(name, "main."))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TurnDuration times one turn of a session: a user prompt and the work done
+// until the next one. Claude Code stamps an assistant message when it is
+// written, so FirstResponseMs runs until the first content block was
+// streamed rather than the first token.
+type TurnDuration struct {
+	// Model answered the turn; empty when no assistant message named one
+	Model           string `json:"model,omitempty"`
+	FirstResponseMs int64  `json:"first_response_ms"`
+	DurationMs      int64  `json:"duration_ms"`
+}
+
+// sessionTurnDurations times the turns of a session. Turns never answered,
+// such as an interrupted prompt, are left out.
+func sessionTurnDurations(messages []SessionMessage) []TurnDuration {
+	type turn struct {
+		start, first, end time.Time
+		model             string
+	}
+	var turns []turn
+	for _, msg := range messages {
+		at, ok := parseMessageTime(msg.Timestamp)
+		if !ok {
+			continue
+		}
+		role := messageRole(msg)
+		if role == "user" && isPromptMessage(msg) {
+			turns = append(turns, turn{start: at, end: at})
+			continue
+		}
+		if len(turns) == 0 {
+			continue
+		}
+		t := &turns[len(turns)-1]
+		if role == "assistant" {
+			if t.first.IsZero() || at.Before(t.first) {
+				t.first = at
+			}
+			if model := msg.envelope().Model; model != "" && model != "<synthetic>" {
+				t.model = model
+			}
+		}
+		if at.After(t.end) {
+			t.end = at
+		}
+	}
+
+	durations := []TurnDuration{}
+	for _, t := range turns {
+		if t.first.IsZero() {
+			continue
+		}
+		durations = append(durations, TurnDuration{
+			Model:           t.model,
+			FirstResponseMs: max(t.first.Sub(t.start), 0).Milliseconds(),
+			DurationMs:      t.end.Sub(t.start).Milliseconds(),
+		})
+	}
+	return durations
+}
+
+// isPromptMessage reports whether a user message holds typed text rather
+// than only tool results
+func isPromptMessage(msg SessionMessage) bool {
+	for _, block := range messageBlocks(msg) {
+		if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichTurnDurations records the turn durations in metadata.turns
+func enrichTurnDurations(session *ClaudeSession, filePath string) {
+	if turns := sessionTurnDurations(session.Messages); len(turns) > 0 {
+		session.Metadata["turns"] = turns
+	}
+}
+
+// DurationStats is the distribution of a duration over many turns
+type DurationStats struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// TurnStats sums up the turns of a group of sessions
+type TurnStats struct {
+	Turns         int           `json:"turns"`
+	FirstResponse DurationStats `json:"first_response"`
+	Duration      DurationStats `json:"duration"`
+}
+
+// ProjectTurns is the turn timing of one project
+type ProjectTurns struct {
+	Project string `json:"project"`
+	TurnStats
+}
+
+// ModelTurns is the turn timing of one model
+type ModelTurns struct {
+	Model string `json:"model"`
+	TurnStats
+}
+
+// TurnReport is the response of GET /api/analytics/turns
+type TurnReport struct {
+	// Sessions counts the sessions with any timed turns
+	Sessions int `json:"sessions"`
+	TurnStats
+	Projects []ProjectTurns `json:"projects"`
+	Models   []ModelTurns   `json:"models"`
+}
+
+// queryTurnStats computes the turn timing grouped by the SQL expression key,
+// which can refer to each turn as t
+func queryTurnStats(a *apiServer, key, where string, args []interface{}, each func(key string, stats TurnStats)) error {
+	rows, err := a.db.Query(`
+		SELECT `+key+`, count(*),
+		       avg(v.f), percentile_cont(0.5) WITHIN GROUP (ORDER BY v.f), percentile_cont(0.9) WITHIN GROUP (ORDER BY v.f),
+		       percentile_cont(0.99) WITHIN GROUP (ORDER BY v.f), max(v.f),
+		       avg(v.d), percentile_cont(0.5) WITHIN GROUP (ORDER BY v.d), percentile_cont(0.9) WITHIN GROUP (ORDER BY v.d),
+		       percentile_cont(0.99) WITHIN GROUP (ORDER BY v.d), max(v.d)
+		FROM claude_sessions, jsonb_array_elements(metadata->'turns') AS t,
+		     LATERAL (SELECT (t->>'first_response_ms')::float8 AS f, (t->>'duration_ms')::float8 AS d) AS v
+		WHERE jsonb_typeof(metadata->'turns') = 'array' AND `+where+`
+		GROUP BY 1`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		var s TurnStats
+		f, d := &s.FirstResponse, &s.Duration
+		if err := rows.Scan(&k, &s.Turns,
+			&f.MeanMs, &f.P50Ms, &f.P90Ms, &f.P99Ms, &f.MaxMs,
+			&d.MeanMs, &d.P50Ms, &d.P90Ms, &d.P99Ms, &d.MaxMs); err != nil {
+			return err
+		}
+		each(k, s)
+	}
+	return rows.Err()
+}
+
+// handleTurnAnalytics serves GET /api/analytics/turns, the distribution of
+// time to first response and turn duration overall, per project and per
+// model. It accepts the filters of the session list. Sessions synced before
+// turns were timed have none until they are reprocessed.
+func (a *apiServer) handleTurnAnalytics(w http.ResponseWriter, r *http.Request) {
+	filter, err := sessionFilterFromQuery(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := scopeSessionFilter(r, &filter); err != nil {
+		writeJSONError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+	where, args := filter.where()
+	fail := func(what string, err error) {
+		writeJSONError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to query %s: %v", what, err), nil)
+	}
+
+	report := TurnReport{Projects: []ProjectTurns{}, Models: []ModelTurns{}}
+	if err := a.db.QueryRow(`SELECT count(*) FROM claude_sessions WHERE jsonb_typeof(metadata->'turns') = 'array' AND `+where, args...).Scan(&report.Sessions); err != nil {
+		fail("sessions", err)
+		return
+	}
+	if err := queryTurnStats(a, "''::text", where, args, func(_ string, stats TurnStats) { report.TurnStats = stats }); err != nil {
+		fail("turns", err)
+		return
+	}
+	project := `COALESCE(substring(metadata->>'source_file' from '/projects/([^/]+)/'), '')`
+	if err := queryTurnStats(a, project, where, args, func(key string, stats TurnStats) {
+		report.Projects = append(report.Projects, ProjectTurns{Project: key, TurnStats: stats})
+	}); err != nil {
+		fail("projects", err)
+		return
+	}
+	if err := queryTurnStats(a, "COALESCE(t->>'model', '')", where, args, func(key string, stats TurnStats) {
+		report.Models = append(report.Models, ModelTurns{Model: key, TurnStats: stats})
+	}); err != nil {
+		fail("models", err)
+		return
+	}
+
+	// Busiest first
+	sort.Slice(report.Projects, func(i, j int) bool {
+		if report.Projects[i].Turns != report.Projects[j].Turns {
+			return report.Projects[i].Turns > report.Projects[j].Turns
+		}
+		return report.Projects[i].Project < report.Projects[j].Project
+	})
+	sort.Slice(report.Models, func(i, j int) bool {
+		if report.Models[i].Turns != report.Models[j].Turns {
+			return report.Models[i].Turns > report.Models[j].Turns
+		}
+		return report.Models[i].Model < report.Models[j].Model
+	})
+	writeJSON(w, http.StatusOK, report)
+}
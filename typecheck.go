@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// typecheckRestart is how long to wait before restarting a tsc that exited
+const typecheckRestart = 5 * time.Second
+
+var (
+	// tscDiagnostic matches a diagnostic printed by tsc --pretty false
+	tscDiagnostic = regexp.MustCompile(`^(.+)\((\d+),(\d+)\): (error|warning|message) (TS\d+): (.*)$`)
+	// tscStarted and tscFound mark the start and end of a watch mode check
+	tscStarted = regexp.MustCompile(`Starting compilation in watch mode|File change detected\. Starting incremental compilation`)
+	tscFound   = regexp.MustCompile(`Found \d+ errors?`)
+)
+
+// TypeDiagnostic is an error or warning reported by tsc
+type TypeDiagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Category string `json:"category"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// String formats the diagnostic like the esbuild errors in the overlay
+func (d TypeDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s %s: %s", d.File, d.Line, d.Column, d.Category, d.Code, d.Message)
+}
+
+// TypecheckStatus is the response of GET /api/typecheck. Diagnostics are
+// those of the last finished check; Running is set while the next one runs.
+type TypecheckStatus struct {
+	Enabled     bool             `json:"enabled"`
+	Running     bool             `json:"running"`
+	CheckedAt   *time.Time       `json:"checked_at,omitempty"`
+	DurationMS  int64            `json:"duration_ms,omitempty"`
+	Diagnostics []TypeDiagnostic `json:"diagnostics"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// typeChecker follows a tsc --noEmit --watch process, since esbuild strips
+// types without checking them
+type typeChecker struct {
+	mu      sync.Mutex
+	status  TypecheckStatus
+	started time.Time
+}
+
+// typecheck is the checker of the running server, nil when it is off
+var typecheck *typeChecker
+
+// findTypeScript locates the project's tsc, falling back to one on the PATH
+func findTypeScript() (string, error) {
+	if _, err := os.Stat("tsconfig.json"); err != nil {
+		return "", fmt.Errorf("no tsconfig.json in the project")
+	}
+	local := filepath.Join("node_modules", ".bin", "tsc")
+	if _, err := os.Stat(local); err == nil {
+		return local, nil
+	}
+	if path, err := exec.LookPath("tsc"); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("TypeScript is not installed; run npm install --save-dev typescript")
+}
+
+// typecheckFlag turns on the background type check of serve and daemon
+func typecheckFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "typecheck",
+		Usage: "Run the project's tsc --noEmit in the background and show type errors in the /render overlay",
+	}
+}
+
+// startTypecheck runs tsc in watch mode in the background until the returned
+// function is called, restarting it if it exits
+func startTypecheck() (func(), error) {
+	tsc, err := findTypeScript()
+	if err != nil {
+		return nil, err
+	}
+	t := &typeChecker{status: TypecheckStatus{Enabled: true, Running: true, Diagnostics: []TypeDiagnostic{}}, started: time.Now()}
+	typecheck = t
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go t.run(ctx, tsc)
+	return cancel, nil
+}
+
+// run keeps tsc running until ctx is done
+func (t *typeChecker) run(ctx context.Context, tsc string) {
+	for {
+		cmd := exec.CommandContext(ctx, tsc, "--noEmit", "--watch", "--preserveWatchOutput", "--pretty", "false")
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			t.read(stdout)
+			err = cmd.Wait()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Type checker exited, restarting in %s: %v", typecheckRestart, err)
+		t.mu.Lock()
+		t.status.Running = false
+		t.status.Error = fmt.Sprintf("tsc exited: %v", err)
+		t.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(typecheckRestart):
+		}
+	}
+}
+
+// read parses the output of tsc, publishing the diagnostics of each check
+// once it finishes
+func (t *typeChecker) read(r io.Reader) {
+	var pending []TypeDiagnostic
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := tscDiagnostic.FindStringSubmatch(line); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			pending = append(pending, TypeDiagnostic{File: filepath.Clean(m[1]), Line: lineNo, Column: column,
+				Category: m[4], Code: m[5], Message: m[6]})
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "  ") && len(pending) > 0:
+			// Related information and elaborations are indented below the diagnostic
+			pending[len(pending)-1].Message += "\n" + strings.TrimRight(line, " ")
+		case tscStarted.MatchString(line):
+			pending = nil
+			t.mu.Lock()
+			t.status.Running = true
+			t.started = time.Now()
+			t.mu.Unlock()
+		case tscFound.MatchString(line):
+			t.finish(pending)
+			pending = nil
+		}
+	}
+}
+
+// finish records the diagnostics of a check and announces them on the dev
+// event bus
+func (t *typeChecker) finish(diagnostics []TypeDiagnostic) {
+	if diagnostics == nil {
+		diagnostics = []TypeDiagnostic{}
+	}
+	now := time.Now()
+	t.mu.Lock()
+	duration := now.Sub(t.started)
+	t.status = TypecheckStatus{Enabled: true, CheckedAt: &now, DurationMS: duration.Milliseconds(), Diagnostics: diagnostics}
+	t.mu.Unlock()
+
+	errors := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		errors[i] = d.String()
+	}
+	devEvents.Publish(DevEvent{Type: eventTypecheck, DurationMS: duration.Milliseconds(), Errors: errors})
+}
+
+// Status returns the outcome of the last check
+func (t *typeChecker) Status() TypecheckStatus {
+	if t == nil {
+		return TypecheckStatus{Diagnostics: []TypeDiagnostic{}}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// errorsFor formats the diagnostics of the given files
+func (t *typeChecker) errorsFor(files map[string]bool) []string {
+	var errors []string
+	for _, d := range t.Status().Diagnostics {
+		if files[d.File] {
+			errors = append(errors, d.String())
+		}
+	}
+	return errors
+}
+
+// handleTypecheck serves GET /api/typecheck[?path=], the diagnostics of the
+// background tsc, limited to a file or directory when path is given
+func handleTypecheck(w http.ResponseWriter, r *http.Request) {
+	status := typecheck.Status()
+	if path := r.URL.Query().Get("path"); path != "" {
+		path = filepath.Clean(path)
+		filtered := []TypeDiagnostic{}
+		for _, d := range status.Diagnostics {
+			if d.File == path || strings.HasPrefix(d.File, path+string(filepath.Separator)) {
+				filtered = append(filtered, d)
+			}
+		}
+		status.Diagnostics = filtered
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -3,7 +3,10 @@ export interface SessionMessage {
   summary?: string;
   content?: string;
   leafUuid?: string;
+  uuid?: string;
   timestamp?: string;
+  // Set when content keeps only the start and end of a long tool result
+  truncated?: boolean;
   // Raw message data for JSON view
   raw?: any; // Complete original message data from Claude session files
 }
@@ -32,6 +35,10 @@ export interface ClaudeSession {
   };
   created_at: string;
   updated_at: string;
+  // First and last message timestamps, set when the session is synced
+  started_at?: string;
+  ended_at?: string;
+  duration_ms?: number;
 }
 
 export interface ClaudeSessionInsert {
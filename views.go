@@ -0,0 +1,454 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	// errViewNotFound is returned when no saved view has the requested name
+	errViewNotFound = errors.New("view not found")
+	// errViewExists is returned when creating a view under a name in use
+	errViewExists = errors.New("view already exists")
+)
+
+// maxViewName bounds the length of view names
+const maxViewName = 100
+
+// ViewFilter is the filter of a saved view. Dates are kept as written, so
+// an age such as 14d stays relative to when the view is applied.
+type ViewFilter struct {
+	Project   string   `json:"project,omitempty"`
+	Query     string   `json:"q,omitempty"`
+	Text      string   `json:"text,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Workspace string   `json:"workspace,omitempty"`
+	After     string   `json:"after,omitempty"`
+	Before    string   `json:"before,omitempty"`
+	// TZ is the time zone of plain dates, UTC by default
+	TZ   string `json:"tz,omitempty"`
+	Sort string `json:"sort,omitempty"`
+}
+
+// SavedView is a named filter of the session list
+type SavedView struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Filter      ViewFilter `json:"filter"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// viewInput is the request body for creating or updating a view. The name
+// of an update comes from the path.
+type viewInput struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Filter      ViewFilter `json:"filter"`
+}
+
+func (in viewInput) validate() error {
+	if err := validateViewName(in.Name); err != nil {
+		return err
+	}
+	_, err := in.Filter.sessionFilter()
+	return err
+}
+
+func validateViewName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(name) > maxViewName || strings.ContainsAny(name, "/?#") {
+		return fmt.Errorf("name must be at most %d characters without /, ? or #", maxViewName)
+	}
+	return nil
+}
+
+// sessionFilter resolves the view into a filter, turning its dates and ages
+// into times as of now
+func (v ViewFilter) sessionFilter() (SessionFilter, error) {
+	q := url.Values{}
+	for key, value := range map[string]string{
+		"project": v.Project, "q": v.Query, "text": v.Text, "workspace": v.Workspace,
+		"after": v.After, "before": v.Before, "tz": v.TZ, "sort": v.Sort,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	q["tag"] = v.Tags
+	return sessionFilterFromQuery(q)
+}
+
+// overlay returns the filter of a view with the fields set in f taking
+// precedence. Tags add to those of the view.
+func (view SessionFilter) overlay(f SessionFilter) SessionFilter {
+	if len(f.IDs) > 0 {
+		view.IDs = f.IDs
+	}
+	for _, field := range [][2]*string{
+		{&view.Project, &f.Project}, {&view.Query, &f.Query}, {&view.Text, &f.Text},
+		{&view.Workspace, &f.Workspace}, {&view.Sort, &f.Sort},
+	} {
+		if *field[1] != "" {
+			*field[0] = *field[1]
+		}
+	}
+	view.Tags = append(view.Tags, f.Tags...)
+	if !f.Before.IsZero() {
+		view.Before = f.Before
+	}
+	if !f.After.IsZero() {
+		view.After = f.After
+	}
+	view.IncludeDeleted = view.IncludeDeleted || f.IncludeDeleted
+	return view
+}
+
+const viewColumns = `name, description, filter, created_at, updated_at`
+
+func scanView(row interface{ Scan(...interface{}) error }) (SavedView, error) {
+	var v SavedView
+	var filter []byte
+	if err := row.Scan(&v.Name, &v.Description, &filter, &v.CreatedAt, &v.UpdatedAt); err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(filter, &v.Filter); err != nil {
+		return v, fmt.Errorf("failed to decode filter of view %s: %w", v.Name, err)
+	}
+	return v, nil
+}
+
+// listViews returns the saved views ordered by name
+func listViews(db *sql.DB) ([]SavedView, error) {
+	rows, err := db.Query(`SELECT ` + viewColumns + ` FROM saved_views ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query views: %w", err)
+	}
+	defer rows.Close()
+
+	views := []SavedView{}
+	for rows.Next() {
+		v, err := scanView(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan view: %w", err)
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// getView loads a saved view by name
+func getView(db *sql.DB, name string) (SavedView, error) {
+	v, err := scanView(db.QueryRow(`SELECT `+viewColumns+` FROM saved_views WHERE name = $1`, name))
+	if err == sql.ErrNoRows {
+		return v, fmt.Errorf("%w: %s", errViewNotFound, name)
+	}
+	if err != nil {
+		return v, fmt.Errorf("failed to load view: %w", err)
+	}
+	return v, nil
+}
+
+// createView saves a new view, failing with errViewExists if the name is taken
+func createView(db *sql.DB, in viewInput) (SavedView, error) {
+	filter, err := json.Marshal(in.Filter)
+	if err != nil {
+		return SavedView{}, fmt.Errorf("failed to encode filter: %w", err)
+	}
+	v, err := scanView(db.QueryRow(`
+		INSERT INTO saved_views (name, description, filter)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING `+viewColumns,
+		in.Name, in.Description, filter))
+	if err == sql.ErrNoRows {
+		return v, fmt.Errorf("%w: %s", errViewExists, in.Name)
+	}
+	if err != nil {
+		return v, fmt.Errorf("failed to create view: %w", err)
+	}
+	return v, nil
+}
+
+// updateView replaces the description and filter of a view
+func updateView(db *sql.DB, in viewInput) (SavedView, error) {
+	filter, err := json.Marshal(in.Filter)
+	if err != nil {
+		return SavedView{}, fmt.Errorf("failed to encode filter: %w", err)
+	}
+	v, err := scanView(db.QueryRow(`
+		UPDATE saved_views SET description = $2, filter = $3, updated_at = NOW()
+		WHERE name = $1
+		RETURNING `+viewColumns,
+		in.Name, in.Description, filter))
+	if err == sql.ErrNoRows {
+		return v, fmt.Errorf("%w: %s", errViewNotFound, in.Name)
+	}
+	if err != nil {
+		return v, fmt.Errorf("failed to update view: %w", err)
+	}
+	return v, nil
+}
+
+// deleteView removes a saved view
+func deleteView(db *sql.DB, name string) error {
+	result, err := db.Exec(`DELETE FROM saved_views WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete view: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", errViewNotFound, name)
+	}
+	return nil
+}
+
+// applyView loads the named view and lays filter over it
+func applyView(db *sql.DB, name string, filter SessionFilter) (SessionFilter, error) {
+	view, err := getView(db, name)
+	if err != nil {
+		return filter, err
+	}
+	resolved, err := view.Filter.sessionFilter()
+	if err != nil {
+		return filter, fmt.Errorf("invalid view %s: %w", name, err)
+	}
+	return resolved.overlay(filter), nil
+}
+
+// handleListViews serves GET /api/views
+func (a *apiServer) handleListViews(w http.ResponseWriter, r *http.Request) {
+	views, err := listViews(a.db)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, wholePage(views))
+}
+
+// handleGetView serves GET /api/views/{name}
+func (a *apiServer) handleGetView(w http.ResponseWriter, r *http.Request) {
+	view, err := getView(a.db, r.PathValue("name"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleCreateView serves POST /api/views
+func (a *apiServer) handleCreateView(w http.ResponseWriter, r *http.Request) {
+	var in viewInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if err := in.validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	view, err := createView(a.db, in)
+	if errors.Is(err, errViewExists) {
+		writeJSONError(w, r, http.StatusConflict, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	a.audit(r, "view.create", "view", view.Name, nil, view)
+	writeJSON(w, http.StatusCreated, view)
+}
+
+// handleUpdateView serves PUT /api/views/{name}
+func (a *apiServer) handleUpdateView(w http.ResponseWriter, r *http.Request) {
+	var in viewInput
+	if !decodeJSONBody(w, r, &in) {
+		return
+	}
+	if in.Name != "" && in.Name != r.PathValue("name") {
+		writeJSONError(w, r, http.StatusBadRequest, "Views cannot be renamed", nil)
+		return
+	}
+	in.Name = r.PathValue("name")
+	if err := in.validate(); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	previous, err := getView(a.db, in.Name)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	view, err := updateView(a.db, in)
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	a.audit(r, "view.update", "view", view.Name, previous, view)
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleDeleteView serves DELETE /api/views/{name}
+func (a *apiServer) handleDeleteView(w http.ResponseWriter, r *http.Request) {
+	previous, err := getView(a.db, r.PathValue("name"))
+	if err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	if err := deleteView(a.db, previous.Name); err != nil {
+		a.writeLoadError(w, r, err)
+		return
+	}
+	a.audit(r, "view.delete", "view", previous.Name, previous, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// viewsCommand manages saved views from the command line
+func viewsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "views",
+		Usage: "Save named session filters to list again with sessions list --view",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List saved views",
+				Flags:  sessionOutputFlags(),
+				Action: viewsListCommand,
+			},
+			{
+				Name:        "save",
+				Usage:       "Save the given filters as a view, replacing one of the same name",
+				ArgsUsage:   "<name>",
+				Description: "Dates may be ages such as 14d or 2w, which stay relative to when the view is applied: `claudemd views save sprint --project -src-repo-x --after 14d`.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "description", Usage: "What the view is for"},
+					&cli.StringFlag{Name: "project", Usage: "Only sessions from this ~/.claude/projects directory"},
+					&cli.StringFlag{Name: "title", Usage: "Only sessions whose title contains this text"},
+					&cli.StringFlag{Name: "text", Usage: "Only sessions with a message containing this text"},
+					&cli.StringSliceFlag{Name: "tag", Usage: "Only sessions with this tag; repeat to require several"},
+					&cli.StringFlag{Name: "workspace", Usage: "Only sessions in this workspace"},
+					&cli.StringFlag{Name: "after", Usage: "Only sessions updated on or after this date or age"},
+					&cli.StringFlag{Name: "before", Usage: "Only sessions updated before this date or age"},
+					&cli.StringFlag{Name: "tz", Usage: "Time zone of plain dates (default UTC)"},
+					&cli.StringFlag{Name: "sort", Usage: "Order of the list: updated (default), updated_asc, created or created_asc"},
+				},
+				Action: viewsSaveCommand,
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete a saved view",
+				ArgsUsage: "<name>",
+				Action:    viewsDeleteCommand,
+			},
+		},
+	}
+}
+
+// viewsListCommand implements `views list`
+func viewsListCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	views, err := listViews(db)
+	if err != nil {
+		return err
+	}
+	header := []string{"NAME", "FILTER", "DESCRIPTION"}
+	rows := make([][]string, len(views))
+	for i, v := range views {
+		rows[i] = []string{v.Name, v.Filter.String(), v.Description}
+	}
+	return writeOutput(os.Stdout, format, views, header, rows)
+}
+
+// String describes the filter as the flags that would recreate it
+func (v ViewFilter) String() string {
+	var parts []string
+	for _, field := range []struct{ flag, value string }{
+		{"project", v.Project}, {"title", v.Query}, {"text", v.Text}, {"workspace", v.Workspace},
+		{"after", v.After}, {"before", v.Before}, {"tz", v.TZ}, {"sort", v.Sort},
+	} {
+		if field.value != "" {
+			parts = append(parts, fmt.Sprintf("--%s %q", field.flag, field.value))
+		}
+	}
+	for _, tag := range v.Tags {
+		parts = append(parts, fmt.Sprintf("--tag %q", tag))
+	}
+	return strings.Join(parts, " ")
+}
+
+// viewsSaveCommand implements `views save`
+func viewsSaveCommand(c *cli.Context) error {
+	in := viewInput{
+		Name:        c.Args().First(),
+		Description: c.String("description"),
+		Filter: ViewFilter{
+			Project:   c.String("project"),
+			Query:     c.String("title"),
+			Text:      c.String("text"),
+			Tags:      c.StringSlice("tag"),
+			Workspace: c.String("workspace"),
+			After:     c.String("after"),
+			Before:    c.String("before"),
+			TZ:        c.String("tz"),
+			Sort:      c.String("sort"),
+		},
+	}
+	if err := in.validate(); err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	view, err := createView(db, in)
+	if errors.Is(err, errViewExists) {
+		view, err = updateView(db, in)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Saved view %s: %s\n", view.Name, view.Filter)
+	fmt.Fprintf(os.Stderr, "💡 List it with: claudemd sessions list --view %q\n", view.Name)
+	return nil
+}
+
+// viewsDeleteCommand implements `views delete`
+func viewsDeleteCommand(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("view name is required")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := deleteView(db, name); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "🧹 Deleted view %s\n", name)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// maxWatchDepth is how many directory levels below ~/.claude/projects are
+// watched, counting a project directory as level 1. Sessions nested deeper
+// are still found by rescans.
+const maxWatchDepth = 4
+
+// watchDepth is the level of a directory given its path relative to the
+// projects directory
+func watchDepth(rel string) int {
+	return len(strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// watchTree watches dir and the directories below it that the filter
+// allows, down to maxWatchDepth
+func (c *ClaudeSessionSync) watchTree(watcher *fsnotify.Watcher, dir string) {
+	filter := c.pathFilter()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("Failed to read directory %s: %v", path, err)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel := c.relativePath(path)
+		if watchDepth(rel) > maxWatchDepth || !filter.AllowDir(rel) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			log.Printf("Failed to watch directory %s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to watch directory %s: %v", dir, err)
+	}
+}
+
+// unwatchTree drops the watches on dir and every directory below it, which
+// keep their old names after the directory is renamed
+func unwatchTree(watcher *fsnotify.Watcher, dir string) {
+	prefix := dir + string(filepath.Separator)
+	for _, path := range watcher.WatchList() {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			watcher.Remove(path)
+		}
+	}
+}
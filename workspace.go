@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// projectConfigFile holds checked-in project settings, unlike ignored/config.json
+const projectConfigFile = "claudemd.config.json"
+
+// projectSchemaFile is written next to the config by init for editor autocomplete
+const projectSchemaFile = "claudemd.schema.json"
+
+//go:embed claudemd.schema.json
+var projectSchema []byte
+
+// ProjectConfig is the contents of claudemd.config.json
+type ProjectConfig struct {
+	// Schema points editors at the JSON schema of this file
+	Schema string       `json:"$schema,omitempty"`
+	Server ServerConfig `json:"server"`
+	Build  BuildConfig  `json:"build"`
+	Sync   SyncConfig   `json:"sync"`
+	// Tracing exports OpenTelemetry traces of syncs and builds
+	Tracing TracingConfig `json:"tracing"`
+}
+
+// ServerConfig holds defaults for the serve and daemon commands
+type ServerConfig struct {
+	Port  string      `json:"port,omitempty"`
+	Cache CacheConfig `json:"cache"`
+	// Typecheck runs tsc --noEmit in the background, as --typecheck does
+	Typecheck bool `json:"typecheck,omitempty"`
+}
+
+// SyncConfig holds defaults for session sync and push. Patterns are globs
+// relative to ~/.claude/projects and add to those in ignored/config.json.
+type SyncConfig struct {
+	Include     []string `json:"include,omitempty"`
+	Ignore      []string `json:"ignore,omitempty"`
+	SnapshotRaw bool     `json:"snapshot_raw,omitempty"`
+	Redact      bool     `json:"redact,omitempty"`
+	// SnapshotContext stores the project's CLAUDE.md and settings as they
+	// were when each session started, linked from its metadata
+	SnapshotContext bool `json:"snapshot_context,omitempty"`
+	// ContextFiles are globs relative to the project directory that replace
+	// defaultContextFiles
+	ContextFiles []string `json:"context_files,omitempty"`
+	// ExtraSources are ~/.claude directories synced next to the sessions:
+	// todos and shell-snapshots
+	ExtraSources []string `json:"extra_sources,omitempty"`
+	// Workspace is where sessions of this project are synced
+	Workspace string `json:"workspace,omitempty"`
+}
+
+// defaultProjectConfig is what init scaffolds and what applies without a config file
+func defaultProjectConfig() *ProjectConfig {
+	return &ProjectConfig{
+		Server: ServerConfig{Port: "3001"},
+		Build:  BuildConfig{Target: defaultBuildTarget, Entry: defaultBuildEntry},
+	}
+}
+
+// workspace is the loaded claudemd.config.json, set before any command runs
+var workspace = defaultProjectConfig()
+
+// loadProjectConfig reads claudemd.config.json, returning defaults if it does not exist
+func loadProjectConfig() (*ProjectConfig, error) {
+	config := defaultProjectConfig()
+
+	data, err := os.ReadFile(projectConfigFile)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", projectConfigFile, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", projectConfigFile, err)
+	}
+	if err := config.Tracing.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", projectConfigFile, err)
+	}
+	return config, nil
+}
+
+// loadWorkspace loads claudemd.config.json for every command
+func loadWorkspace(c *cli.Context) error {
+	config, err := loadProjectConfig()
+	if err != nil {
+		return err
+	}
+	workspace = config
+	buildConfig = config.Build
+	return setupTracing(config.Tracing)
+}
+
+// serverPort is the --port flag, or the configured port when it is not given
+func serverPort(c *cli.Context) string {
+	if !c.IsSet("port") && workspace.Server.Port != "" {
+		return workspace.Server.Port
+	}
+	return c.String("port")
+}
+
+// syncPatterns merges the sync include and ignore patterns from the
+// workspace, the local config and the command line
+func syncPatterns(c *cli.Context, config *Config) ([]string, []string) {
+	include := append([]string{}, workspace.Sync.Include...)
+	exclude := append([]string{}, workspace.Sync.Ignore...)
+	if config != nil {
+		include = append(include, config.Include...)
+		exclude = append(exclude, config.Exclude...)
+	}
+	return append(include, c.StringSlice("include")...), append(exclude, c.StringSlice("exclude")...)
+}
+
+// initCommand scaffolds claudemd.config.json and its JSON schema
+func initCommand(c *cli.Context) error {
+	if _, err := os.Stat(projectConfigFile); err == nil && !c.Bool("force") {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", projectConfigFile)
+	}
+
+	config := defaultProjectConfig()
+	config.Schema = "./" + projectSchemaFile
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(projectConfigFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", projectConfigFile, err)
+	}
+	if err := os.WriteFile(projectSchemaFile, projectSchema, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", projectSchemaFile, err)
+	}
+
+	fmt.Printf("✅ Created %s\n", projectConfigFile)
+	fmt.Printf("📄 Schema written to %s for editor autocomplete\n", projectSchemaFile)
+	return nil
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// defaultWorkspace holds sessions synced without a workspace, keeping
+// personal sessions apart from team ones
+const defaultWorkspace = "personal"
+
+// workspaceSlug is the form of workspace identifiers
+var workspaceSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// errWorkspaceNotFound is returned when no workspace has the requested slug
+var errWorkspaceNotFound = errors.New("workspace not found")
+
+// Workspace is a group of sessions shared by its members. The Go name is
+// distinct from the workspace variable, which is claudemd.config.json.
+type Workspace struct {
+	Slug      string            `json:"slug"`
+	Name      string            `json:"name"`
+	Settings  WorkspaceSettings `json:"settings"`
+	Members   []WorkspaceMember `json:"members,omitempty"`
+	Sessions  int               `json:"sessions"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// WorkspaceSettings apply to every session synced into a workspace
+type WorkspaceSettings struct {
+	// Redact masks secrets before upload even if the syncing machine has
+	// redaction turned off
+	Redact bool `json:"redact,omitempty"`
+}
+
+// WorkspaceMember is a person with access to a workspace
+type WorkspaceMember struct {
+	Member  string    `json:"member"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// validateWorkspaceSlug rejects slugs the workspaces table would refuse
+func validateWorkspaceSlug(slug string) error {
+	if !workspaceSlug.MatchString(slug) {
+		return fmt.Errorf("invalid workspace %q: use lowercase letters, digits, - and _", slug)
+	}
+	return nil
+}
+
+// createWorkspace adds a workspace
+func createWorkspace(db *sql.DB, slug, name string, settings WorkspaceSettings) error {
+	if err := validateWorkspaceSlug(slug); err != nil {
+		return err
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(`INSERT INTO workspaces (slug, name, settings) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		slug, name, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("workspace %s already exists", slug)
+	}
+	return nil
+}
+
+// updateWorkspace replaces the name and settings of a workspace
+func updateWorkspace(db *sql.DB, ws Workspace) error {
+	data, err := json.Marshal(ws.Settings)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(`UPDATE workspaces SET name = $2, settings = $3 WHERE slug = $1`, ws.Slug, ws.Name, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to update workspace: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", errWorkspaceNotFound, ws.Slug)
+	}
+	return nil
+}
+
+const workspaceColumns = `w.slug, w.name, w.settings, w.created_at,
+	(SELECT count(*) FROM claude_sessions s WHERE s.workspace = w.slug AND s.deleted_at IS NULL)`
+
+// scanWorkspace reads a row selected with workspaceColumns
+func scanWorkspace(row interface{ Scan(...interface{}) error }) (Workspace, error) {
+	var ws Workspace
+	var settings []byte
+	if err := row.Scan(&ws.Slug, &ws.Name, &settings, &ws.CreatedAt, &ws.Sessions); err != nil {
+		return ws, err
+	}
+	if err := json.Unmarshal(settings, &ws.Settings); err != nil {
+		return ws, fmt.Errorf("failed to decode settings of workspace %s: %w", ws.Slug, err)
+	}
+	return ws, nil
+}
+
+// listWorkspaces returns workspaces by slug, all of them when slug is empty
+func listWorkspaces(db *sql.DB, slug string) ([]Workspace, error) {
+	rows, err := db.Query(`SELECT `+workspaceColumns+` FROM workspaces w WHERE $1 = '' OR w.slug = $1 ORDER BY w.slug`, slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	workspaces := []Workspace{}
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, rows.Err()
+}
+
+// getWorkspace returns a workspace with its members
+func getWorkspace(db *sql.DB, slug string) (*Workspace, error) {
+	ws, err := scanWorkspace(db.QueryRow(`SELECT `+workspaceColumns+` FROM workspaces w WHERE w.slug = $1`, slug))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", errWorkspaceNotFound, slug)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workspace: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT member, role, added_at FROM workspace_members WHERE workspace = $1 ORDER BY member`, slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %w", err)
+	}
+	defer rows.Close()
+	ws.Members = []WorkspaceMember{}
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.Member, &m.Role, &m.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		ws.Members = append(ws.Members, m)
+	}
+	return &ws, rows.Err()
+}
+
+// addWorkspaceMember adds a member or changes the role of an existing one
+func addWorkspaceMember(db *sql.DB, slug, member, role string) error {
+	if role != "owner" && role != "member" {
+		return fmt.Errorf("role must be owner or member")
+	}
+	if strings.TrimSpace(member) == "" {
+		return fmt.Errorf("member is required")
+	}
+	if _, err := getWorkspace(db, slug); err != nil {
+		return err
+	}
+	_, err := db.Exec(`
+		INSERT INTO workspace_members (workspace, member, role) VALUES ($1, $2, $3)
+		ON CONFLICT (workspace, member) DO UPDATE SET role = EXCLUDED.role`,
+		slug, member, role)
+	if err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+// removeWorkspaceMember removes a member, reporting whether one matched
+func removeWorkspaceMember(db *sql.DB, slug, member string) (bool, error) {
+	result, err := db.Exec(`DELETE FROM workspace_members WHERE workspace = $1 AND member = $2`, slug, member)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove member: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// moveSessions puts sessions into a workspace, returning how many moved.
+// Later syncs keep them there unless the syncing machine selects a
+// workspace itself.
+func moveSessions(db *sql.DB, slug string, sessionIDs []string) (int64, error) {
+	if _, err := getWorkspace(db, slug); err != nil {
+		return 0, err
+	}
+	filter := SessionFilter{IDs: sessionIDs, IncludeDeleted: true}
+	where, args := filter.where()
+	args = append(args, slug)
+	result, err := db.Exec(fmt.Sprintf(`UPDATE claude_sessions SET workspace = $%d, updated_at = NOW() WHERE %s`, len(args), where), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// selectedWorkspace is the workspace sync writes to: --workspace, then
+// sync.workspace in claudemd.config.json, then workspace in
+// ignored/config.json. Empty leaves new sessions in the default workspace.
+func selectedWorkspace(c *cli.Context, config *Config) string {
+	if slug := c.String("workspace"); slug != "" {
+		return slug
+	}
+	if workspace.Sync.Workspace != "" {
+		return workspace.Sync.Workspace
+	}
+	return config.Workspace
+}
+
+// handleListWorkspaces serves GET /api/workspaces. A workspace scoped key
+// only sees its own.
+func (a *apiServer) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	slug := ""
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		slug = key.Workspace
+	}
+	workspaces, err := listWorkspaces(a.db, slug)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, workspaces)
+}
+
+// handleGetWorkspace serves GET /api/workspaces/{slug} with its members
+func (a *apiServer) handleGetWorkspace(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if key := apiKeyFromContext(r.Context()); key != nil && key.Workspace != "" && key.Workspace != slug {
+		writeJSONError(w, r, http.StatusNotFound, fmt.Sprintf("%v: %s", errWorkspaceNotFound, slug), nil)
+		return
+	}
+	ws, err := getWorkspace(a.db, slug)
+	if errors.Is(err, errWorkspaceNotFound) {
+		writeJSONError(w, r, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, ws)
+}
+
+// workspaceCommand groups the workspace subcommands
+func workspaceCommand() *cli.Command {
+	settingsFlags := []cli.Flag{
+		&cli.StringFlag{Name: "name", Usage: "Display name"},
+		&cli.BoolFlag{Name: "redact", Usage: "Always redact sessions synced into this workspace"},
+	}
+	return &cli.Command{
+		Name:  "workspace",
+		Usage: "Manage the workspaces that group sessions for a team",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "create",
+				Usage:     "Create a workspace",
+				ArgsUsage: "<slug>",
+				Flags:     settingsFlags,
+				Action:    workspaceCreateCommand,
+			},
+			{
+				Name:      "set",
+				Usage:     "Change the name or settings of a workspace",
+				ArgsUsage: "<slug>",
+				Flags:     settingsFlags,
+				Action:    workspaceSetCommand,
+			},
+			{
+				Name:   "list",
+				Usage:  "List workspaces and their session counts",
+				Flags:  sessionOutputFlags(),
+				Action: workspaceListCommand,
+			},
+			{
+				Name:      "show",
+				Usage:     "Show a workspace and its members",
+				ArgsUsage: "<slug>",
+				Flags:     sessionOutputFlags(),
+				Action:    workspaceShowCommand,
+			},
+			{
+				Name:      "add-member",
+				Usage:     "Add a member, or change their role",
+				ArgsUsage: "<slug> <member>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "role", Value: "member", Usage: "owner or member"},
+				},
+				Action: workspaceAddMemberCommand,
+			},
+			{
+				Name:      "remove-member",
+				Usage:     "Remove a member",
+				ArgsUsage: "<slug> <member>",
+				Action:    workspaceRemoveMemberCommand,
+			},
+			{
+				Name:      "move",
+				Usage:     "Move sessions into a workspace",
+				ArgsUsage: "<slug> <session_id>...",
+				Action:    workspaceMoveCommand,
+			},
+		},
+	}
+}
+
+// workspaceCreateCommand implements `workspace create`
+func workspaceCreateCommand(c *cli.Context) error {
+	slug := c.Args().First()
+	if slug == "" {
+		return fmt.Errorf("workspace slug is required")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createWorkspace(db, slug, c.String("name"), WorkspaceSettings{Redact: c.Bool("redact")}); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Created workspace %s\n", slug)
+	return nil
+}
+
+// workspaceSetCommand implements `workspace set`, changing only the flags given
+func workspaceSetCommand(c *cli.Context) error {
+	slug := c.Args().First()
+	if slug == "" {
+		return fmt.Errorf("workspace slug is required")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ws, err := getWorkspace(db, slug)
+	if err != nil {
+		return err
+	}
+	if c.IsSet("name") {
+		ws.Name = c.String("name")
+	}
+	if c.IsSet("redact") {
+		ws.Settings.Redact = c.Bool("redact")
+	}
+	if err := updateWorkspace(db, *ws); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Updated workspace %s\n", slug)
+	return nil
+}
+
+// workspaceListCommand implements `workspace list`
+func workspaceListCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	workspaces, err := listWorkspaces(db, "")
+	if err != nil {
+		return err
+	}
+	header := []string{"WORKSPACE", "NAME", "SESSIONS", "REDACT", "CREATED"}
+	rows := make([][]string, len(workspaces))
+	for i, ws := range workspaces {
+		rows[i] = []string{ws.Slug, ws.Name, strconv.Itoa(ws.Sessions), strconv.FormatBool(ws.Settings.Redact),
+			ws.CreatedAt.Local().Format("2006-01-02")}
+	}
+	return writeOutput(os.Stdout, format, workspaces, header, rows)
+}
+
+// workspaceShowCommand implements `workspace show`. JSON includes the
+// settings; the table and CSV formats list the members.
+func workspaceShowCommand(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+	slug := c.Args().First()
+	if slug == "" {
+		return fmt.Errorf("workspace slug is required")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ws, err := getWorkspace(db, slug)
+	if err != nil {
+		return err
+	}
+	header := []string{"MEMBER", "ROLE", "ADDED"}
+	rows := make([][]string, len(ws.Members))
+	for i, m := range ws.Members {
+		rows[i] = []string{m.Member, m.Role, m.AddedAt.Local().Format("2006-01-02")}
+	}
+	return writeOutput(os.Stdout, format, ws, header, rows)
+}
+
+// workspaceAddMemberCommand implements `workspace add-member`
+func workspaceAddMemberCommand(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("usage: claudemd workspace add-member <slug> <member>")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slug, member := c.Args().Get(0), c.Args().Get(1)
+	if err := addWorkspaceMember(db, slug, member, c.String("role")); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Added %s to %s as %s\n", member, slug, c.String("role"))
+	return nil
+}
+
+// workspaceRemoveMemberCommand implements `workspace remove-member`
+func workspaceRemoveMemberCommand(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("usage: claudemd workspace remove-member <slug> <member>")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slug, member := c.Args().Get(0), c.Args().Get(1)
+	removed, err := removeWorkspaceMember(db, slug, member)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%s is not a member of %s", member, slug)
+	}
+	fmt.Printf("✅ Removed %s from %s\n", member, slug)
+	return nil
+}
+
+// workspaceMoveCommand implements `workspace move`
+func workspaceMoveCommand(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: claudemd workspace move <slug> <session_id>...")
+	}
+	db, _, err := openConfiguredDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slug := c.Args().First()
+	moved, err := moveSessions(db, slug, c.Args().Tail())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Moved %d session(s) into %s\n", moved, slug)
+	return nil
+}